@@ -0,0 +1,676 @@
+// Package accounting classifies every change to the balance of the node, on
+// chain and off chain, so that the history of the node can be reconciled
+// without having to match raw wallet transactions to channels by hand.
+package accounting
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/fn"
+	"github.com/lightningnetwork/lnd/invoices"
+	"github.com/lightningnetwork/lnd/labels"
+	"github.com/lightningnetwork/lnd/lnwallet"
+)
+
+// EntryType describes what caused a change to the balance of the node.
+type EntryType uint8
+
+const (
+	// EntryTypeReceive is an on-chain transaction that credits the wallet
+	// and that wasn't created by lnd.
+	EntryTypeReceive EntryType = iota
+
+	// EntryTypeSend is an on-chain transaction that debits the wallet and
+	// that wasn't created by lnd for one of its channels.
+	EntryTypeSend
+
+	// EntryTypeChannelOpen is the funding transaction of a channel.
+	EntryTypeChannelOpen
+
+	// EntryTypeChannelClose is the closing transaction of a channel.
+	EntryTypeChannelClose
+
+	// EntryTypeSweep is a sweep of the sweeper that couldn't be attributed
+	// to a specific contract.
+	EntryTypeSweep
+
+	// EntryTypeCommitSweep is the sweep of our time locked output of a
+	// force closed channel.
+	EntryTypeCommitSweep
+
+	// EntryTypeHtlcResolution is the on-chain resolution of a htlc of a
+	// force closed channel.
+	EntryTypeHtlcResolution
+
+	// EntryTypeAnchorSweep is the sweep of an anchor output, which is used
+	// to bump the fee of a commitment transaction with CPFP.
+	EntryTypeAnchorSweep
+
+	// EntryTypeJustice is a justice transaction that claims the funds of
+	// a breached channel.
+	EntryTypeJustice
+
+	// EntryTypeConsolidation is a consolidation of the UTXOs of the
+	// wallet.
+	EntryTypeConsolidation
+
+	// EntryTypePayment is an off-chain payment that we sent.
+	EntryTypePayment
+
+	// EntryTypeInvoice is an off-chain payment that we received.
+	EntryTypeInvoice
+
+	// EntryTypeForward is a htlc that we forwarded, its amount is the fee
+	// we earned.
+	EntryTypeForward
+)
+
+// String returns a human-readable name of the entry type.
+func (e EntryType) String() string {
+	switch e {
+	case EntryTypeReceive:
+		return "receive"
+
+	case EntryTypeSend:
+		return "send"
+
+	case EntryTypeChannelOpen:
+		return "channel_open"
+
+	case EntryTypeChannelClose:
+		return "channel_close"
+
+	case EntryTypeSweep:
+		return "sweep"
+
+	case EntryTypeCommitSweep:
+		return "commit_sweep"
+
+	case EntryTypeHtlcResolution:
+		return "htlc_resolution"
+
+	case EntryTypeAnchorSweep:
+		return "anchor_sweep"
+
+	case EntryTypeJustice:
+		return "justice"
+
+	case EntryTypeConsolidation:
+		return "consolidation"
+
+	case EntryTypePayment:
+		return "payment"
+
+	case EntryTypeInvoice:
+		return "invoice"
+
+	case EntryTypeForward:
+		return "forward"
+
+	default:
+		return fmt.Sprintf("unknown<%d>", e)
+	}
+}
+
+// Entry is a single change to the balance of the node. Amounts are expressed
+// in millisatoshis and never converted to fiat, so that they can be priced by
+// whatever tool reconciles them.
+type Entry struct {
+	// Timestamp is the time the balance changed. For on-chain entries this
+	// is the time of the block that confirmed the transaction, or the
+	// time the transaction was created if it is unconfirmed.
+	Timestamp time.Time
+
+	// Type describes what caused the change.
+	Type EntryType
+
+	// OnChain is true if the change was caused by an on-chain transaction.
+	OnChain bool
+
+	// TxID is the hash of the transaction of an on-chain entry.
+	TxID string
+
+	// BlockHeight is the height of the block that confirmed the
+	// transaction of an on-chain entry, or zero if it is unconfirmed.
+	BlockHeight int32
+
+	// Reference identifies the object the change belongs to: the channel
+	// point for channel related transactions, the payment hash for
+	// payments and invoices, or the channel pair for forwards.
+	Reference string
+
+	// AmountMsat is the signed change of the balance. On-chain entries
+	// change the balance of the wallet, off-chain entries the balance of
+	// our channels. It includes the fee we paid.
+	AmountMsat int64
+
+	// FeeMsat is the fee we paid, which is part of AmountMsat. The fee of
+	// a closing transaction is paid from the channel balance instead.
+	FeeMsat int64
+
+	// Label is the label of the transaction or the memo of the invoice.
+	Label string
+}
+
+// Config holds the data sources the entries are built from.
+type Config struct {
+	// ListTransactions returns all transactions of the on-chain wallet.
+	ListTransactions func() ([]*lnwallet.TransactionDetail, error)
+
+	// FetchChannels returns all channels that aren't fully closed yet,
+	// including pending ones.
+	FetchChannels func() ([]*channeldb.OpenChannel, error)
+
+	// FetchClosedChannels returns the summaries of all closed channels.
+	FetchClosedChannels func() ([]*channeldb.ChannelCloseSummary, error)
+
+	// FetchChannelReports returns the resolver reports of a closed
+	// channel. Channels that don't have any reports return nil.
+	FetchChannelReports func(chanPoint wire.OutPoint) (
+		[]*channeldb.ResolverReport, error)
+
+	// IsInitiator returns whether we opened the closed channel with the
+	// given funding outpoint, which means we paid the fees of its
+	// commitment and closing transactions. Channels we don't know the
+	// initiator of return false.
+	IsInitiator func(chanPoint wire.OutPoint) (bool, error)
+
+	// ListSweeps returns the hashes of all transactions published by the
+	// sweeper.
+	ListSweeps func() ([]chainhash.Hash, error)
+
+	// ListPayments returns all payments that we sent that were created
+	// up to the given time. A payment settles after it was created, so
+	// these are the only ones that can have settled before then. A zero
+	// time doesn't bound the payments.
+	ListPayments func(end time.Time) ([]*channeldb.MPPayment, error)
+
+	// ListInvoices returns all invoices that were created up to the given
+	// time, with the same semantics as ListPayments.
+	ListInvoices func(end time.Time) ([]invoices.Invoice, error)
+
+	// ListForwards returns at most maxEvents forwarding events in the
+	// given time range, oldest first. A zero maxEvents doesn't limit the
+	// number of events.
+	ListForwards func(start, end time.Time,
+		maxEvents uint64) ([]channeldb.ForwardingEvent, error)
+}
+
+// Query selects the entries ListEntries returns.
+type Query struct {
+	// StartTime is the earliest timestamp of the entries, inclusive.
+	StartTime time.Time
+
+	// EndTime is the latest timestamp of the entries, inclusive. A zero
+	// end time doesn't bound the range.
+	EndTime time.Time
+
+	// IndexOffset is the number of entries in the time range to skip.
+	IndexOffset uint64
+
+	// MaxEntries is the maximum number of entries to return. Zero returns
+	// all entries after the offset.
+	MaxEntries uint64
+}
+
+// chanTx links a transaction to the channel it belongs to.
+type chanTx struct {
+	entryType EntryType
+	chanPoint wire.OutPoint
+
+	// closeFee is the fee of a closing transaction, which is only set if
+	// we paid it.
+	closeFee fn.Option[btcutil.Amount]
+}
+
+// resolvedInputs are the channel outputs spent by a transaction that are known
+// from the resolver reports.
+type resolvedInputs struct {
+	amount btcutil.Amount
+	count  int
+}
+
+// ListEntries returns the entries selected by the query, sorted by timestamp.
+func ListEntries(cfg *Config, query Query) ([]*Entry, error) {
+	start, end := query.StartTime, query.EndTime
+	if end.IsZero() {
+		end = time.Unix(1<<62, 0)
+	}
+	inRange := func(t time.Time) bool {
+		return !t.Before(start) && !t.After(end)
+	}
+
+	onChain, err := onChainEntries(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	payments, err := paymentEntries(cfg, query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	invoiceEntries, err := invoiceEntries(cfg, query.EndTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Forwards are stored by their timestamp, so only the ones up to the
+	// end of the requested page can be part of it.
+	var maxForwards uint64
+	if query.MaxEntries != 0 {
+		maxForwards = query.IndexOffset + query.MaxEntries
+	}
+	forwards, err := forwardEntries(cfg, start, end, maxForwards)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, set := range [][]*Entry{
+		onChain, payments, invoiceEntries, forwards,
+	} {
+		for _, entry := range set {
+			if inRange(entry.Timestamp) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	if query.IndexOffset >= uint64(len(entries)) {
+		return nil, nil
+	}
+	entries = entries[query.IndexOffset:]
+
+	if query.MaxEntries != 0 && query.MaxEntries < uint64(len(entries)) {
+		entries = entries[:query.MaxEntries]
+	}
+
+	return entries, nil
+}
+
+// channelTxs maps the transactions that belong to our channels to their
+// type, and the transactions that spend channel outputs to the resolved
+// outputs they spend.
+func channelTxs(cfg *Config, walletTxs map[chainhash.Hash]*wire.MsgTx) (
+	map[chainhash.Hash]chanTx, map[chainhash.Hash]resolvedInputs, error) {
+
+	txs := make(map[chainhash.Hash]chanTx)
+	resolved := make(map[chainhash.Hash]resolvedInputs)
+
+	channels, err := cfg.FetchChannels()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, channel := range channels {
+		txs[channel.FundingOutpoint.Hash] = chanTx{
+			entryType: EntryTypeChannelOpen,
+			chanPoint: channel.FundingOutpoint,
+		}
+	}
+
+	closed, err := cfg.FetchClosedChannels()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, summary := range closed {
+		txs[summary.ChanPoint.Hash] = chanTx{
+			entryType: EntryTypeChannelOpen,
+			chanPoint: summary.ChanPoint,
+		}
+
+		// The initiator of a channel pays the fee of its closing
+		// transaction, which only spends the funding output.
+		closeTx := chanTx{
+			entryType: EntryTypeChannelClose,
+			chanPoint: summary.ChanPoint,
+		}
+		initiator, err := cfg.IsInitiator(summary.ChanPoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		msgTx, ok := walletTxs[summary.ClosingTXID]
+		if ok && initiator {
+			closeTx.closeFee = fn.Some(
+				summary.Capacity - outputSum(msgTx),
+			)
+		}
+		txs[summary.ClosingTXID] = closeTx
+
+		reports, err := cfg.FetchChannelReports(summary.ChanPoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, report := range reports {
+			if report.SpendTxID == nil {
+				continue
+			}
+
+			inputs := resolved[*report.SpendTxID]
+			inputs.amount += report.Amount
+			inputs.count++
+			resolved[*report.SpendTxID] = inputs
+
+			// The funds of a channel that was closed by a breach
+			// are claimed by the justice transaction, which is
+			// classified by its label.
+			if summary.CloseType == channeldb.BreachClose {
+				continue
+			}
+
+			txs[*report.SpendTxID] = chanTx{
+				entryType: reportEntryType(report.ResolverType),
+				chanPoint: summary.ChanPoint,
+			}
+		}
+	}
+
+	return txs, resolved, nil
+}
+
+// outputSum returns the total value of the outputs of a transaction.
+func outputSum(tx *wire.MsgTx) btcutil.Amount {
+	var sum btcutil.Amount
+	for _, txOut := range tx.TxOut {
+		sum += btcutil.Amount(txOut.Value)
+	}
+
+	return sum
+}
+
+// txFee returns the fee we paid for a wallet transaction, if it is known.
+func txFee(tx *lnwallet.TransactionDetail, msgTx *wire.MsgTx,
+	chanTx chanTx, resolved resolvedInputs) btcutil.Amount {
+
+	if chanTx.entryType == EntryTypeChannelClose {
+		return chanTx.closeFee.UnwrapOr(0)
+	}
+
+	var foreignInputs int
+	for _, prevOut := range tx.PreviousOutpoints {
+		if !prevOut.IsOurOutput {
+			foreignInputs++
+		}
+	}
+
+	// The wallet only knows the fee of transactions it funded.
+	if foreignInputs == 0 {
+		if tx.Value < 0 {
+			return btcutil.Amount(tx.TotalFees)
+		}
+
+		return 0
+	}
+
+	// Sweeps spend channel outputs and possibly wallet outputs to pay the
+	// fee. If we know the values of all channel outputs, the wallet
+	// inputs follow from the net value of the transaction.
+	if msgTx == nil || resolved.count != foreignInputs {
+		return 0
+	}
+
+	var walletOutputs btcutil.Amount
+	for _, output := range tx.OutputDetails {
+		if output.IsOurAddress {
+			walletOutputs += btcutil.Amount(output.Value)
+		}
+	}
+	walletInputs := walletOutputs - tx.Value
+
+	fee := resolved.amount + walletInputs - outputSum(msgTx)
+	if fee < 0 {
+		return 0
+	}
+
+	return fee
+}
+
+// reportEntryType returns the entry type of a transaction that spends an
+// output resolved by a contract resolver.
+func reportEntryType(resolverType channeldb.ResolverType) EntryType {
+	switch resolverType {
+	case channeldb.ResolverTypeAnchor:
+		return EntryTypeAnchorSweep
+
+	case channeldb.ResolverTypeCommit:
+		return EntryTypeCommitSweep
+
+	default:
+		return EntryTypeHtlcResolution
+	}
+}
+
+// labelEntryType returns the entry type of a transaction that was labelled by
+// lnd.
+func labelEntryType(label string) (EntryType, bool) {
+	labelType, ok := labels.ParseLabelType(label)
+	if !ok {
+		return 0, false
+	}
+
+	switch labelType {
+	case labels.LabelTypeChannelOpen:
+		return EntryTypeChannelOpen, true
+
+	case labels.LabelTypeChannelClose:
+		return EntryTypeChannelClose, true
+
+	case labels.LabelTypeJusticeTransaction:
+		return EntryTypeJustice, true
+
+	case labels.LabelTypeSweepTransaction:
+		return EntryTypeSweep, true
+
+	case labels.LabelTypeConsolidation:
+		return EntryTypeConsolidation, true
+
+	default:
+		return 0, false
+	}
+}
+
+// onChainEntries classifies the transactions of the wallet. Transactions are
+// matched to channels and contract resolutions first, as that gives the most
+// precise type, then to the labels lnd sets on its own transactions and the
+// sweeps of the sweeper.
+func onChainEntries(cfg *Config) ([]*Entry, error) {
+	txs, err := cfg.ListTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	msgTxs := make(map[chainhash.Hash]*wire.MsgTx, len(txs))
+	for _, tx := range txs {
+		if len(tx.RawTx) == 0 {
+			continue
+		}
+
+		msgTx := &wire.MsgTx{}
+		err := msgTx.Deserialize(bytes.NewReader(tx.RawTx))
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode transaction "+
+				"%v: %w", tx.Hash, err)
+		}
+		msgTxs[tx.Hash] = msgTx
+	}
+
+	chanTxs, resolved, err := channelTxs(cfg, msgTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	sweepHashes, err := cfg.ListSweeps()
+	if err != nil {
+		return nil, err
+	}
+	sweeps := make(map[chainhash.Hash]struct{}, len(sweepHashes))
+	for _, hash := range sweepHashes {
+		sweeps[hash] = struct{}{}
+	}
+
+	entries := make([]*Entry, 0, len(txs))
+	for _, tx := range txs {
+		entry := &Entry{
+			Timestamp:   time.Unix(tx.Timestamp, 0),
+			OnChain:     true,
+			TxID:        tx.Hash.String(),
+			BlockHeight: tx.BlockHeight,
+			AmountMsat:  int64(tx.Value) * 1000,
+			Label:       tx.Label,
+		}
+
+		chanTx, isChanTx := chanTxs[tx.Hash]
+		fee := txFee(tx, msgTxs[tx.Hash], chanTx, resolved[tx.Hash])
+		entry.FeeMsat = int64(fee) * 1000
+
+		labelType, isLabelled := labelEntryType(tx.Label)
+		_, isSweep := sweeps[tx.Hash]
+
+		switch {
+		case isChanTx:
+			entry.Type = chanTx.entryType
+			entry.Reference = chanTx.chanPoint.String()
+
+		case isLabelled:
+			entry.Type = labelType
+
+		case isSweep:
+			entry.Type = EntryTypeSweep
+
+		case tx.Value >= 0:
+			entry.Type = EntryTypeReceive
+
+		default:
+			entry.Type = EntryTypeSend
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// paymentEntries returns an entry for every payment that succeeded and was
+// created up to the given time.
+func paymentEntries(cfg *Config, end time.Time) ([]*Entry, error) {
+	payments, err := cfg.ListPayments(end)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, payment := range payments {
+		if payment.Status != channeldb.StatusSucceeded {
+			continue
+		}
+
+		timestamp := payment.Info.CreationTime
+		settled, _ := payment.TerminalInfo()
+		if settled != nil && settled.Settle != nil {
+			timestamp = settled.Settle.SettleTime
+		}
+
+		amt, fee := payment.SentAmt()
+		entries = append(entries, &Entry{
+			Timestamp:  timestamp,
+			Type:       EntryTypePayment,
+			Reference:  payment.Info.PaymentIdentifier.String(),
+			AmountMsat: -int64(amt + fee),
+			FeeMsat:    int64(fee),
+		})
+	}
+
+	return entries, nil
+}
+
+// invoiceEntries returns an entry for every settled invoice that was created
+// up to the given time. AMP invoices can be paid multiple times, so they have
+// an entry for every settled set.
+func invoiceEntries(cfg *Config, end time.Time) ([]*Entry, error) {
+	invoiceList, err := cfg.ListInvoices(end)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, invoice := range invoiceList {
+		if len(invoice.AMPState) > 0 {
+			for setID, state := range invoice.AMPState {
+				if state.State != invoices.HtlcStateSettled {
+					continue
+				}
+
+				reference := hex.EncodeToString(setID[:])
+				entries = append(entries, &Entry{
+					Timestamp:  state.SettleDate,
+					Type:       EntryTypeInvoice,
+					Reference:  reference,
+					AmountMsat: int64(state.AmtPaid),
+					Label:      string(invoice.Memo),
+				})
+			}
+
+			continue
+		}
+
+		if invoice.State != invoices.ContractSettled {
+			continue
+		}
+
+		// The invoice doesn't store its payment hash, but we know the
+		// preimage of every invoice we settled.
+		var reference string
+		if preimage := invoice.Terms.PaymentPreimage; preimage != nil {
+			reference = preimage.Hash().String()
+		}
+
+		entries = append(entries, &Entry{
+			Timestamp:  invoice.SettleDate,
+			Type:       EntryTypeInvoice,
+			Reference:  reference,
+			AmountMsat: int64(invoice.AmtPaid),
+			Label:      string(invoice.Memo),
+		})
+	}
+
+	return entries, nil
+}
+
+// forwardEntries returns an entry for at most maxEvents forwards in the given
+// time range. Forwards only change our balance by the fee we earned.
+func forwardEntries(cfg *Config, start, end time.Time,
+	maxEvents uint64) ([]*Entry, error) {
+
+	events, err := cfg.ListForwards(start, end, maxEvents)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(events))
+	for _, event := range events {
+		reference := fmt.Sprintf("%d->%d",
+			event.IncomingChanID.ToUint64(),
+			event.OutgoingChanID.ToUint64())
+
+		entries = append(entries, &Entry{
+			Timestamp:  event.Timestamp,
+			Type:       EntryTypeForward,
+			Reference:  reference,
+			AmountMsat: int64(event.AmtIn) - int64(event.AmtOut),
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,304 @@
+package accounting
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/invoices"
+	"github.com/lightningnetwork/lnd/labels"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// hash returns a distinct hash for each index.
+func hash(i byte) chainhash.Hash {
+	return chainhash.Hash{i}
+}
+
+// walletTx returns a wallet transaction with the given hash, value and label.
+func walletTx(i byte, value btcutil.Amount, label string,
+	timestamp int64) *lnwallet.TransactionDetail {
+
+	return &lnwallet.TransactionDetail{
+		Hash:        hash(i),
+		Value:       value,
+		Timestamp:   timestamp,
+		BlockHeight: int32(i),
+		TotalFees:   100,
+		Label:       label,
+	}
+}
+
+// rawTx returns a serialized transaction with the given output values.
+func rawTx(t *testing.T, values ...int64) []byte {
+	t.Helper()
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{})
+	for _, value := range values {
+		tx.AddTxOut(wire.NewTxOut(value, nil))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+
+	return buf.Bytes()
+}
+
+// testRoute returns a route that delivers the given amount for the given fee.
+func testRoute(amt, fee lnwire.MilliSatoshi) route.Route {
+	return route.Route{
+		TotalAmount: amt + fee,
+		Hops: []*route.Hop{{
+			AmtToForward: amt,
+		}},
+	}
+}
+
+// TestListEntries tests that on-chain and off-chain events are classified
+// correctly.
+func TestListEntries(t *testing.T) {
+	t.Parallel()
+
+	var (
+		openChanPoint   = wire.OutPoint{Hash: hash(1)}
+		closedChanPoint = wire.OutPoint{Hash: hash(2), Index: 1}
+		anchorSweep     = hash(4)
+		commitSweep     = hash(5)
+		htlcSweep       = hash(6)
+		genericSweep    = hash(7)
+		preimage        = lntypes.Preimage{1}
+		incomingHtlc    = channeldb.ResolverTypeIncomingHtlc
+		outgoingHtlc    = channeldb.ResolverTypeOutgoingHtlc
+		settled         = invoices.HtlcStateSettled
+		canceled        = invoices.HtlcStateCanceled
+	)
+
+	txs := []*lnwallet.TransactionDetail{
+		walletTx(1, -100_100, "", 1),
+		walletTx(2, -50_100, "", 2),
+		walletTx(3, 40_000, "", 3),
+		walletTx(4, -200, "", 4),
+		walletTx(5, 9_000, "", 5),
+		walletTx(6, 1_000, "", 6),
+		walletTx(7, 500, "", 7),
+		walletTx(8, -1_000, labels.MakeLabel(
+			labels.LabelTypeJusticeTransaction, nil,
+		), 8),
+		walletTx(9, -300, labels.MakeConsolidationLabel(1), 9),
+		walletTx(10, 20_000, "salary", 10),
+		walletTx(11, -5_100, "rent", 11),
+	}
+
+	// We opened the closed channel, so we paid the fee of its closing
+	// transaction.
+	txs[2].RawTx = rawTx(t, 40_000, 9_500)
+
+	// The commitment sweep spends our 9,500 sat output and a wallet
+	// output of 1,000 sat to pay a fee of 600 sat.
+	txs[4].RawTx = rawTx(t, 9_900)
+	txs[4].Value = 8_900
+	txs[4].OutputDetails = []lnwallet.OutputDetail{{
+		Value:        9_900,
+		IsOurAddress: true,
+	}}
+	txs[4].PreviousOutpoints = []lnwallet.PreviousOutPoint{{
+		IsOurOutput: false,
+	}, {
+		IsOurOutput: true,
+	}}
+
+	settleTime := time.Unix(12, 0)
+	payment := &channeldb.MPPayment{
+		Info: &channeldb.PaymentCreationInfo{
+			PaymentIdentifier: lntypes.Hash{2},
+			Value:             10_000,
+			CreationTime:      time.Unix(1, 0),
+		},
+		HTLCs: []channeldb.HTLCAttempt{{
+			HTLCAttemptInfo: channeldb.HTLCAttemptInfo{
+				Route: testRoute(10_000, 10),
+			},
+			Settle: &channeldb.HTLCSettleInfo{
+				SettleTime: settleTime,
+			},
+		}},
+		Status: channeldb.StatusSucceeded,
+	}
+	failedPayment := &channeldb.MPPayment{
+		Info:   &channeldb.PaymentCreationInfo{},
+		Status: channeldb.StatusFailed,
+	}
+
+	cfg := &Config{
+		ListTransactions: func() ([]*lnwallet.TransactionDetail,
+			error) {
+
+			return txs, nil
+		},
+		FetchChannels: func() ([]*channeldb.OpenChannel, error) {
+			return []*channeldb.OpenChannel{{
+				FundingOutpoint: openChanPoint,
+			}}, nil
+		},
+		FetchClosedChannels: func() ([]*channeldb.ChannelCloseSummary,
+			error) {
+
+			return []*channeldb.ChannelCloseSummary{{
+				ChanPoint:   closedChanPoint,
+				ClosingTXID: hash(3),
+				Capacity:    50_000,
+				CloseType:   channeldb.RemoteForceClose,
+			}}, nil
+		},
+		FetchChannelReports: func(chanPoint wire.OutPoint) (
+			[]*channeldb.ResolverReport, error) {
+
+			require.Equal(t, closedChanPoint, chanPoint)
+
+			return []*channeldb.ResolverReport{{
+				ResolverType: channeldb.ResolverTypeAnchor,
+				SpendTxID:    &anchorSweep,
+			}, {
+				ResolverType: channeldb.ResolverTypeCommit,
+				Amount:       9_500,
+				SpendTxID:    &commitSweep,
+			}, {
+				ResolverType: incomingHtlc,
+				SpendTxID:    &htlcSweep,
+			}, {
+				// Abandoned htlcs don't have a sweep.
+				ResolverType: outgoingHtlc,
+			}}, nil
+		},
+		IsInitiator: func(chanPoint wire.OutPoint) (bool, error) {
+			return chanPoint == closedChanPoint, nil
+		},
+		ListSweeps: func() ([]chainhash.Hash, error) {
+			return []chainhash.Hash{genericSweep, anchorSweep}, nil
+		},
+		ListPayments: func(time.Time) ([]*channeldb.MPPayment, error) {
+			return []*channeldb.MPPayment{
+				payment, failedPayment,
+			}, nil
+		},
+		ListInvoices: func(time.Time) ([]invoices.Invoice, error) {
+			return []invoices.Invoice{{
+				Memo:       []byte("coffee"),
+				SettleDate: time.Unix(13, 0),
+				Terms: invoices.ContractTerm{
+					PaymentPreimage: &preimage,
+				},
+				State:   invoices.ContractSettled,
+				AmtPaid: 3_000,
+			}, {
+				State: invoices.ContractOpen,
+			}, {
+				AMPState: invoices.AMPInvoiceState{
+					{3}: {
+						State:      settled,
+						SettleDate: time.Unix(14, 0),
+						AmtPaid:    4_000,
+					},
+					{4}: {
+						State: canceled,
+					},
+				},
+			}}, nil
+		},
+		ListForwards: func(start, end time.Time, _ uint64) (
+			[]channeldb.ForwardingEvent, error) {
+
+			return []channeldb.ForwardingEvent{{
+				Timestamp:      time.Unix(15, 0),
+				IncomingChanID: lnwire.NewShortChanIDFromInt(1),
+				OutgoingChanID: lnwire.NewShortChanIDFromInt(2),
+				AmtIn:          1_005,
+				AmtOut:         1_000,
+			}}, nil
+		},
+	}
+
+	entries, err := ListEntries(cfg, Query{})
+	require.NoError(t, err)
+
+	expected := []struct {
+		entryType EntryType
+		reference string
+		amount    int64
+		fee       int64
+	}{
+		{EntryTypeChannelOpen, openChanPoint.String(), -100_100_000,
+			100_000},
+		{EntryTypeChannelOpen, closedChanPoint.String(), -50_100_000,
+			100_000},
+		{EntryTypeChannelClose, closedChanPoint.String(), 40_000_000,
+			500_000},
+		{EntryTypeAnchorSweep, closedChanPoint.String(), -200_000,
+			100_000},
+		{EntryTypeCommitSweep, closedChanPoint.String(), 8_900_000,
+			600_000},
+		{EntryTypeHtlcResolution, closedChanPoint.String(),
+			1_000_000, 0},
+		{EntryTypeSweep, "", 500_000, 0},
+		{EntryTypeJustice, "", -1_000_000, 100_000},
+		{EntryTypeConsolidation, "", -300_000, 100_000},
+		{EntryTypeReceive, "", 20_000_000, 0},
+		{EntryTypeSend, "", -5_100_000, 100_000},
+		{EntryTypePayment, lntypes.Hash{2}.String(), -10_010, 10},
+		{EntryTypeInvoice, preimage.Hash().String(), 3_000, 0},
+		{EntryTypeInvoice, lntypes.Hash{3}.String(), 4_000, 0},
+		{EntryTypeForward, "1->2", 5, 0},
+	}
+	require.Len(t, entries, len(expected))
+
+	for i, exp := range expected {
+		entry := entries[i]
+		require.Equal(t, exp.entryType, entry.Type, "entry %d", i)
+		require.Equal(t, exp.reference, entry.Reference, "entry %d", i)
+		require.Equal(t, exp.amount, entry.AmountMsat, "entry %d", i)
+		require.Equal(t, exp.fee, entry.FeeMsat, "entry %d", i)
+		require.Equal(t, i < 11, entry.OnChain, "entry %d", i)
+	}
+
+	// Restricting the time range should only return the entries within
+	// it.
+	entries, err = ListEntries(cfg, Query{
+		StartTime: time.Unix(10, 0),
+		EndTime:   time.Unix(12, 0),
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, EntryTypeReceive, entries[0].Type)
+	require.Equal(t, "salary", entries[0].Label)
+	require.Equal(t, EntryTypeSend, entries[1].Type)
+	require.Equal(t, EntryTypePayment, entries[2].Type)
+	require.Equal(t, settleTime, entries[2].Timestamp)
+
+	// The entries can be fetched page by page.
+	entries, err = ListEntries(cfg, Query{
+		IndexOffset: 10,
+		MaxEntries:  2,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, EntryTypeSend, entries[0].Type)
+	require.Equal(t, EntryTypePayment, entries[1].Type)
+
+	entries, err = ListEntries(cfg, Query{IndexOffset: 14})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, EntryTypeForward, entries[0].Type)
+
+	entries, err = ListEntries(cfg, Query{IndexOffset: 15})
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,1078 @@
+package electrumnotify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+	"github.com/lightningnetwork/lnd/electrum"
+	"github.com/lightningnetwork/lnd/queue"
+)
+
+const (
+	// notifierType uniquely identifies a concrete implementation of the
+	// ChainNotifier interface that makes use of an Electrum or Esplora
+	// server.
+	notifierType = "electrum"
+)
+
+// ElectrumNotifier implements the ChainNotifier interface using an Electrum
+// or Esplora server. As these servers don't serve full blocks (Electrum) or
+// make fetching them expensive (Esplora), the notifier only looks at the
+// transactions found in the histories of the scripts it watches. The blocks
+// handed to the TxNotifier therefore only contain those transactions, along
+// with their real index within the block. Multiple concurrent clients are
+// supported. All notifications are achieved via non-blocking sends on client
+// channels.
+type ElectrumNotifier struct {
+	epochClientCounter uint64 // To be used atomically.
+
+	start   sync.Once
+	active  int32 // To be used atomically.
+	stopped int32 // To be used atomically.
+
+	backend      electrum.Backend
+	chainConn    *electrum.ChainConn
+	chainParams  *chaincfg.Params
+	pollInterval time.Duration
+
+	notificationCancels  chan interface{}
+	notificationRegistry chan interface{}
+
+	txNotifier *chainntnfs.TxNotifier
+
+	blockEpochClients map[uint64]*blockEpochRegistration
+
+	bestBlock chainntnfs.BlockEpoch
+
+	// watchMtx guards watchedScripts.
+	watchMtx sync.Mutex
+
+	// watchedScripts are the output scripts of all confirmation and spend
+	// requests, keyed by their script hash. The histories of these scripts
+	// are looked up for every new block.
+	watchedScripts map[string][]byte
+
+	// mempoolTxs are the unconfirmed transactions that were already handed
+	// to the mempool notifier.
+	mempoolTxs map[chainhash.Hash]struct{}
+
+	// spendHintCache is a cache used to query and update the latest height
+	// hints for an outpoint. Each height hint represents the earliest
+	// height at which the outpoint could have been spent within the chain.
+	spendHintCache chainntnfs.SpendHintCache
+
+	// confirmHintCache is a cache used to query the latest height hints for
+	// a transaction. Each height hint represents the earliest height at
+	// which the transaction could have confirmed within the chain.
+	confirmHintCache chainntnfs.ConfirmHintCache
+
+	// memNotifier notifies clients of events related to the mempool.
+	memNotifier *chainntnfs.MempoolNotifier
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// Ensure ElectrumNotifier implements the ChainNotifier interface at compile
+// time.
+var _ chainntnfs.ChainNotifier = (*ElectrumNotifier)(nil)
+
+// Ensure ElectrumNotifier implements the MempoolWatcher interface at compile
+// time.
+var _ chainntnfs.MempoolWatcher = (*ElectrumNotifier)(nil)
+
+// New returns a new ElectrumNotifier instance which polls the given backend
+// for new blocks in the given interval.
+func New(backend electrum.Backend, chainParams *chaincfg.Params,
+	spendHintCache chainntnfs.SpendHintCache,
+	confirmHintCache chainntnfs.ConfirmHintCache,
+	pollInterval time.Duration) *ElectrumNotifier {
+
+	if pollInterval <= 0 {
+		pollInterval = electrum.DefaultPollInterval
+	}
+
+	return &ElectrumNotifier{
+		backend:      backend,
+		chainConn:    electrum.NewChainConn(backend),
+		chainParams:  chainParams,
+		pollInterval: pollInterval,
+
+		notificationCancels:  make(chan interface{}),
+		notificationRegistry: make(chan interface{}),
+
+		blockEpochClients: make(map[uint64]*blockEpochRegistration),
+
+		watchedScripts: make(map[string][]byte),
+		mempoolTxs:     make(map[chainhash.Hash]struct{}),
+
+		spendHintCache:   spendHintCache,
+		confirmHintCache: confirmHintCache,
+
+		memNotifier: chainntnfs.NewMempoolNotifier(),
+
+		quit: make(chan struct{}),
+	}
+}
+
+// Start fetches the tip of the chain and launches all related helper
+// goroutines.
+func (e *ElectrumNotifier) Start() error {
+	var startErr error
+	e.start.Do(func() {
+		startErr = e.startNotifier()
+	})
+
+	return startErr
+}
+
+// Stop shuts down the ElectrumNotifier. The backend itself isn't closed, as
+// it's shared with the other users of the chain backend.
+func (e *ElectrumNotifier) Stop() error {
+	// Already shutting down?
+	if atomic.AddInt32(&e.stopped, 1) != 1 {
+		return nil
+	}
+
+	chainntnfs.Log.Infof("%v notifier shutting down...", e.backend.Name())
+	defer chainntnfs.Log.Debugf("%v notifier shutdown complete",
+		e.backend.Name())
+
+	close(e.quit)
+	e.wg.Wait()
+
+	// Notify all pending clients of our shutdown by closing the related
+	// notification channels.
+	for _, epochClient := range e.blockEpochClients {
+		close(epochClient.cancelChan)
+		epochClient.wg.Wait()
+
+		close(epochClient.epochChan)
+	}
+
+	// The notifier might not have been started.
+	if e.txNotifier != nil {
+		e.txNotifier.TearDown()
+	}
+
+	// Stop the mempool notifier.
+	e.memNotifier.TearDown()
+
+	return nil
+}
+
+// Started returns true if this instance has been started, and false otherwise.
+func (e *ElectrumNotifier) Started() bool {
+	return atomic.LoadInt32(&e.active) != 0
+}
+
+func (e *ElectrumNotifier) startNotifier() error {
+	currentHash, currentHeight, err := e.backend.BestBlock()
+	if err != nil {
+		return err
+	}
+	blockHeader, err := e.chainConn.GetBlockHeader(currentHash)
+	if err != nil {
+		return err
+	}
+
+	e.txNotifier = chainntnfs.NewTxNotifier(
+		uint32(currentHeight), chainntnfs.ReorgSafetyLimit,
+		e.confirmHintCache, e.spendHintCache,
+	)
+
+	e.bestBlock = chainntnfs.BlockEpoch{
+		Height:      currentHeight,
+		Hash:        currentHash,
+		BlockHeader: blockHeader,
+	}
+
+	e.wg.Add(1)
+	go e.notificationDispatcher()
+
+	// Set the active flag now that we've completed the full
+	// startup.
+	atomic.StoreInt32(&e.active, 1)
+
+	return nil
+}
+
+// notificationDispatcher is the primary goroutine which handles client
+// notification registrations, as well as notification dispatches.
+func (e *ElectrumNotifier) notificationDispatcher() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cancelMsg := <-e.notificationCancels:
+			switch msg := cancelMsg.(type) {
+			case *epochCancel:
+				chainntnfs.Log.Infof("Cancelling epoch "+
+					"notification, epoch_id=%v", msg.epochID)
+
+				// First, we'll lookup the original
+				// registration in order to stop the active
+				// queue goroutine.
+				reg := e.blockEpochClients[msg.epochID]
+				reg.epochQueue.Stop()
+
+				// Next, close the cancel channel for this
+				// specific client, and wait for the client to
+				// exit.
+				close(reg.cancelChan)
+				reg.wg.Wait()
+
+				// Once the client has exited, we can then
+				// safely close the channel used to send epoch
+				// notifications, in order to notify any
+				// listeners that the intent has been
+				// canceled.
+				close(reg.epochChan)
+				delete(e.blockEpochClients, msg.epochID)
+			}
+
+		case registerMsg := <-e.notificationRegistry:
+			switch msg := registerMsg.(type) {
+			case *chainntnfs.HistoricalConfDispatch:
+				// Look up whether the transaction is already
+				// included in the active chain. We'll do this
+				// in a goroutine to prevent blocking the
+				// dispatcher on the history lookups.
+				e.wg.Add(1)
+				go e.dispatchHistoricalConf(msg)
+
+			case *chainntnfs.HistoricalSpendDispatch:
+				e.wg.Add(1)
+				go e.dispatchHistoricalSpend(msg)
+
+			case *blockEpochRegistration:
+				chainntnfs.Log.Infof("New block epoch subscription")
+
+				e.blockEpochClients[msg.epochID] = msg
+
+				// If the client did not provide their best
+				// known block, then we'll immediately dispatch
+				// a notification for the current tip.
+				if msg.bestBlock == nil {
+					e.notifyBlockEpochClient(
+						msg, e.bestBlock.Height,
+						e.bestBlock.Hash,
+						e.bestBlock.BlockHeader,
+					)
+
+					msg.errorChan <- nil
+					continue
+				}
+
+				// Otherwise, we'll attempt to deliver the
+				// backlog of notifications from their best
+				// known block.
+				missedBlocks, err := chainntnfs.GetClientMissedBlocks(
+					e.chainConn, msg.bestBlock,
+					e.bestBlock.Height, true,
+				)
+				if err != nil {
+					msg.errorChan <- err
+					continue
+				}
+
+				for _, block := range missedBlocks {
+					e.notifyBlockEpochClient(
+						msg, block.Height, block.Hash,
+						block.BlockHeader,
+					)
+				}
+
+				msg.errorChan <- nil
+			}
+
+		case <-ticker.C:
+			if err := e.syncChain(); err != nil {
+				chainntnfs.Log.Errorf("Unable to sync with "+
+					"%v backend: %v", e.backend.Name(), err)
+			}
+
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// dispatchHistoricalConf looks up the confirmation details of a request
+// within the range of the historical dispatch and hands them to the
+// TxNotifier.
+//
+// NOTE: This must be run as a goroutine.
+func (e *ElectrumNotifier) dispatchHistoricalConf(
+	msg *chainntnfs.HistoricalConfDispatch) {
+
+	defer e.wg.Done()
+
+	confDetails, err := e.historicalConfDetails(
+		msg.ConfRequest, msg.StartHeight, msg.EndHeight,
+	)
+	if err != nil {
+		chainntnfs.Log.Errorf("Rescan to determine the conf details "+
+			"of %v within range %d-%d failed: %v", msg.ConfRequest,
+			msg.StartHeight, msg.EndHeight, err)
+		return
+	}
+
+	// If the historical dispatch finished without error, we will invoke
+	// UpdateConfDetails even if none were found. This allows the notifier
+	// to begin safely updating the height hint cache at tip, since any
+	// pending rescans have now completed.
+	err = e.txNotifier.UpdateConfDetails(msg.ConfRequest, confDetails)
+	if err != nil {
+		chainntnfs.Log.Errorf("Unable to update conf details of %v: %v",
+			msg.ConfRequest, err)
+	}
+}
+
+// dispatchHistoricalSpend looks up the spend details of a request within the
+// range of the historical dispatch and hands them to the TxNotifier.
+//
+// NOTE: This must be run as a goroutine.
+func (e *ElectrumNotifier) dispatchHistoricalSpend(
+	msg *chainntnfs.HistoricalSpendDispatch) {
+
+	defer e.wg.Done()
+
+	spendDetails, err := e.historicalSpendDetails(
+		msg.SpendRequest, msg.StartHeight, msg.EndHeight,
+	)
+	if err != nil {
+		chainntnfs.Log.Errorf("Rescan to determine the spend details "+
+			"of %v within range %d-%d failed: %v",
+			msg.SpendRequest, msg.StartHeight, msg.EndHeight, err)
+		return
+	}
+
+	chainntnfs.Log.Infof("Historical spend dispatch finished for request "+
+		"%v (start=%v end=%v) with details: %v", msg.SpendRequest,
+		msg.StartHeight, msg.EndHeight, spendDetails)
+
+	// If the historical dispatch finished without error, we will invoke
+	// UpdateSpendDetails even if none were found. This allows the notifier
+	// to begin safely updating the height hint cache at tip, since any
+	// pending rescans have now completed.
+	err = e.txNotifier.UpdateSpendDetails(msg.SpendRequest, spendDetails)
+	if err != nil {
+		chainntnfs.Log.Errorf("Unable to update spend details of %v: "+
+			"%v", msg.SpendRequest, err)
+	}
+}
+
+// syncChain polls the backend for its best block and catches up with it,
+// disconnecting any blocks that were reorged out. Afterwards new unconfirmed
+// transactions of the watched scripts are handed to the mempool notifier.
+func (e *ElectrumNotifier) syncChain() error {
+	tipHash, tipHeight, err := e.backend.BestBlock()
+	if err != nil {
+		return fmt.Errorf("unable to fetch best block: %w", err)
+	}
+
+	if *tipHash != *e.bestBlock.Hash {
+		if err := e.catchUp(tipHeight); err != nil {
+			return err
+		}
+	}
+
+	return e.processMempool()
+}
+
+// catchUp connects all blocks up to the given height of the best chain of
+// the backend, after rewinding to the common ancestor of our best block and
+// the best chain.
+func (e *ElectrumNotifier) catchUp(tipHeight int32) error {
+	// If the backend's chain is shorter than ours, we'll first find the
+	// block we connected at the height of its tip, so we can look for the
+	// common ancestor from there.
+	if tipHeight < e.bestBlock.Height {
+		staleHash := *e.bestBlock.Hash
+		for height := e.bestBlock.Height; height > tipHeight; height-- {
+			header, err := e.chainConn.GetBlockHeader(&staleHash)
+			if err != nil {
+				return fmt.Errorf("unable to fetch header of "+
+					"block %v: %w", staleHash, err)
+			}
+			staleHash = header.PrevBlock
+		}
+
+		hashAtTip, err := e.chainConn.GetBlockHash(int64(tipHeight))
+		if err != nil {
+			return err
+		}
+
+		ancestorHeight, err := chainntnfs.GetCommonBlockAncestorHeight(
+			e.chainConn, staleHash, *hashAtTip,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to find common ancestor: %w",
+				err)
+		}
+
+		newBestBlock, err := chainntnfs.RewindChain(
+			e.chainConn, e.txNotifier, e.bestBlock, ancestorHeight,
+		)
+
+		// Set the bestBlock here in case a chain rewind partially
+		// completed.
+		e.bestBlock = newBestBlock
+		if err != nil {
+			return err
+		}
+	}
+
+	newBestBlock, missedBlocks, err := chainntnfs.HandleMissedBlocks(
+		e.chainConn, e.txNotifier, e.bestBlock, tipHeight, true,
+	)
+
+	// Set the bestBlock here in case a catch up partially completed.
+	e.bestBlock = newBestBlock
+	if err != nil {
+		return err
+	}
+
+	for _, block := range missedBlocks {
+		if err := e.handleBlockConnected(block); err != nil {
+			return err
+		}
+	}
+
+	// HandleMissedBlocks doesn't include the new tip itself.
+	if e.bestBlock.Height >= tipHeight {
+		return nil
+	}
+
+	tipHash, err := e.chainConn.GetBlockHash(int64(tipHeight))
+	if err != nil {
+		return err
+	}
+	tipHeader, err := e.chainConn.GetBlockHeader(tipHash)
+	if err != nil {
+		return err
+	}
+
+	return e.handleBlockConnected(chainntnfs.BlockEpoch{
+		Height:      tipHeight,
+		Hash:        tipHash,
+		BlockHeader: tipHeader,
+	})
+}
+
+// watch adds the script to the set of scripts whose histories are looked up
+// for every new block.
+func (e *ElectrumNotifier) watch(pkScript []byte) {
+	e.watchMtx.Lock()
+	e.watchedScripts[electrum.ScriptHash(pkScript)] = pkScript
+	e.watchMtx.Unlock()
+}
+
+// watched returns the history items of all watched scripts, keyed by the
+// transaction hash.
+func (e *ElectrumNotifier) watched() (map[chainhash.Hash]int32, error) {
+	e.watchMtx.Lock()
+	pkScripts := make([][]byte, 0, len(e.watchedScripts))
+	for _, pkScript := range e.watchedScripts {
+		pkScripts = append(pkScripts, pkScript)
+	}
+	e.watchMtx.Unlock()
+
+	txHeights := make(map[chainhash.Hash]int32)
+	for _, pkScript := range pkScripts {
+		history, err := e.backend.ScriptHistory(pkScript)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range history {
+			height := item.Height
+			if !item.Confirmed() {
+				height = 0
+			}
+			txHeights[item.TxHash] = height
+		}
+	}
+
+	return txHeights, nil
+}
+
+// relevantBlock returns a block with the header of the given block, but only
+// the given transactions, which are assigned their index within the full
+// block.
+func (e *ElectrumNotifier) relevantBlock(block chainntnfs.BlockEpoch,
+	txHashes []chainhash.Hash) (*btcutil.Block, error) {
+
+	type indexedTx struct {
+		tx    *wire.MsgTx
+		index uint32
+	}
+
+	txs := make([]indexedTx, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		txHash := txHash
+		tx, err := e.backend.Transaction(&txHash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch transaction "+
+				"%v: %w", txHash, err)
+		}
+		index, err := e.backend.TxIndex(&txHash, block.Height)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch index of "+
+				"transaction %v: %w", txHash, err)
+		}
+
+		txs = append(txs, indexedTx{tx: tx, index: index})
+	}
+
+	// The transactions are processed in order, which matters if one
+	// spends another.
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].index < txs[j].index
+	})
+
+	msgBlock := &wire.MsgBlock{
+		Header: *block.BlockHeader,
+	}
+	for _, tx := range txs {
+		msgBlock.Transactions = append(msgBlock.Transactions, tx.tx)
+	}
+
+	// The TxNotifier takes the index of the transactions from the block,
+	// so we'll set their index within the full block.
+	utilBlock := btcutil.NewBlock(msgBlock)
+	for i, tx := range utilBlock.Transactions() {
+		tx.SetIndex(int(txs[i].index))
+	}
+
+	return utilBlock, nil
+}
+
+// handleBlockConnected applies a chain update for a new block. Any watched
+// transactions included this block will processed to either send notifications
+// now or after numConfirmations confs.
+func (e *ElectrumNotifier) handleBlockConnected(
+	block chainntnfs.BlockEpoch) error {
+
+	// First, we'll gather the transactions of the watched scripts that
+	// were included in this block.
+	txHeights, err := e.watched()
+	if err != nil {
+		return fmt.Errorf("unable to fetch script histories: %w", err)
+	}
+
+	var txHashes []chainhash.Hash
+	for txHash, height := range txHeights {
+		if height == block.Height {
+			txHashes = append(txHashes, txHash)
+		}
+	}
+
+	utilBlock, err := e.relevantBlock(block, txHashes)
+	if err != nil {
+		return err
+	}
+
+	// We'll then extend the txNotifier's height with the information of
+	// this new block, which will handle all of the notification logic for
+	// us.
+	err = e.txNotifier.ConnectTip(utilBlock, uint32(block.Height))
+	if err != nil {
+		return fmt.Errorf("unable to connect tip: %w", err)
+	}
+
+	// Transactions that confirmed no longer have to be watched in the
+	// mempool.
+	for _, tx := range utilBlock.Transactions() {
+		e.memNotifier.UnsubsribeConfirmedSpentTx(tx)
+		delete(e.mempoolTxs, *tx.Hash())
+	}
+
+	chainntnfs.Log.Infof("New block: height=%v, sha=%v", block.Height,
+		block.Hash)
+
+	// Now that we've guaranteed the new block extends the txNotifier's
+	// current tip, we'll proceed to dispatch notifications to all of our
+	// registered clients whom have had notifications fulfilled. Before
+	// doing so, we'll make sure update our in memory state in order to
+	// satisfy any client requests based upon the new block.
+	e.bestBlock = block
+
+	e.notifyBlockEpochs(block.Height, block.Hash, block.BlockHeader)
+	return e.txNotifier.NotifyHeight(uint32(block.Height))
+}
+
+// processMempool hands the unconfirmed transactions of the watched scripts to
+// the mempool notifier.
+func (e *ElectrumNotifier) processMempool() error {
+	txHeights, err := e.watched()
+	if err != nil {
+		return fmt.Errorf("unable to fetch script histories: %w", err)
+	}
+
+	// Forget about transactions that left the mempool.
+	for txHash := range e.mempoolTxs {
+		if height, ok := txHeights[txHash]; !ok || height != 0 {
+			delete(e.mempoolTxs, txHash)
+		}
+	}
+
+	for txHash, height := range txHeights {
+		if height != 0 {
+			continue
+		}
+		if _, ok := e.mempoolTxs[txHash]; ok {
+			continue
+		}
+
+		txHash := txHash
+		tx, err := e.backend.Transaction(&txHash)
+		if errors.Is(err, electrum.ErrTxNotFound) {
+			// The transaction might have been evicted from the
+			// mempool in the meantime.
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to fetch transaction %v: %w",
+				txHash, err)
+		}
+
+		err = e.memNotifier.ProcessRelevantSpendTx(btcutil.NewTx(tx))
+		if err != nil {
+			chainntnfs.Log.Errorf("Unable to process transaction "+
+				"%v: %v", txHash, err)
+		}
+		e.mempoolTxs[txHash] = struct{}{}
+	}
+
+	return nil
+}
+
+// historyInRange returns the confirmed transactions in the history of the
+// script that were included within the given height range, along with their
+// heights, starting with the earliest.
+func (e *ElectrumNotifier) historyInRange(pkScript []byte, startHeight,
+	endHeight uint32) ([]electrum.HistoryItem, error) {
+
+	history, err := e.backend.ScriptHistory(pkScript)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch script history: %w",
+			err)
+	}
+
+	var items []electrum.HistoryItem
+	for _, item := range history {
+		if !item.Confirmed() || uint32(item.Height) < startHeight ||
+			uint32(item.Height) > endHeight {
+
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Height < items[j].Height
+	})
+
+	return items, nil
+}
+
+// historicalConfDetails looks up whether a confirmation request (txid/output
+// script) has already been included in a block in the active chain and, if so,
+// returns details about said block.
+func (e *ElectrumNotifier) historicalConfDetails(
+	confRequest chainntnfs.ConfRequest, startHeight,
+	endHeight uint32) (*chainntnfs.TxConfirmation, error) {
+
+	items, err := e.historyInRange(
+		confRequest.PkScript.Script(), startHeight, endHeight,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		select {
+		case <-e.quit:
+			return nil, chainntnfs.ErrChainNotifierShuttingDown
+		default:
+		}
+
+		item := item
+		tx, err := e.backend.Transaction(&item.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch transaction "+
+				"%v: %w", item.TxHash, err)
+		}
+		if !confRequest.MatchesTx(tx) {
+			continue
+		}
+
+		blockHash, err := e.backend.BlockHash(item.Height)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get hash from block "+
+				"with height %d: %w", item.Height, err)
+		}
+		txIndex, err := e.backend.TxIndex(&item.TxHash, item.Height)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch index of "+
+				"transaction %v: %w", item.TxHash, err)
+		}
+
+		// The block is only available if the backend serves full
+		// blocks.
+		block, err := e.backend.Block(blockHash)
+		if err != nil &&
+			!errors.Is(err, electrum.ErrBlocksUnsupported) {
+
+			return nil, fmt.Errorf("unable to get block with hash "+
+				"%v: %w", blockHash, err)
+		}
+
+		return &chainntnfs.TxConfirmation{
+			Tx:          tx.Copy(),
+			BlockHash:   blockHash,
+			BlockHeight: uint32(item.Height),
+			TxIndex:     txIndex,
+			Block:       block,
+		}, nil
+	}
+
+	// If we reach here, then we were not able to find the transaction
+	// within a block, so we avoid returning an error.
+	return nil, nil
+}
+
+// historicalSpendDetails looks up a transaction within the given height range
+// that spends the given outpoint/output script. If one is found, the spend
+// details are assembled and returned to the caller. If the spend is not found,
+// a nil spend detail will be returned.
+func (e *ElectrumNotifier) historicalSpendDetails(
+	spendRequest chainntnfs.SpendRequest, startHeight, endHeight uint32) (
+	*chainntnfs.SpendDetail, error) {
+
+	pkScript := spendRequest.PkScript.Script()
+
+	// The TxNotifier replaces the script of taproot outputs, as it can't
+	// be derived from the witness of the spend, so we'll look up the real
+	// one from the funding transaction.
+	if spendRequest.PkScript == chainntnfs.ZeroTaprootPkScript {
+		fundingTx, err := e.backend.Transaction(
+			&spendRequest.OutPoint.Hash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch funding "+
+				"transaction: %w", err)
+		}
+		if int(spendRequest.OutPoint.Index) >= len(fundingTx.TxOut) {
+			return nil, fmt.Errorf("invalid output index %v",
+				spendRequest.OutPoint)
+		}
+		pkScript = fundingTx.TxOut[spendRequest.OutPoint.Index].PkScript
+	}
+
+	items, err := e.historyInRange(pkScript, startHeight, endHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		select {
+		case <-e.quit:
+			return nil, chainntnfs.ErrChainNotifierShuttingDown
+		default:
+		}
+
+		item := item
+		tx, err := e.backend.Transaction(&item.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch transaction "+
+				"%v: %w", item.TxHash, err)
+		}
+
+		matches, inputIdx, err := spendRequest.MatchesTx(tx)
+		if err != nil {
+			return nil, err
+		}
+		if !matches {
+			continue
+		}
+
+		txCopy := tx.Copy()
+		txHash := txCopy.TxHash()
+		spendOutPoint := &txCopy.TxIn[inputIdx].PreviousOutPoint
+		return &chainntnfs.SpendDetail{
+			SpentOutPoint:     spendOutPoint,
+			SpenderTxHash:     &txHash,
+			SpendingTx:        txCopy,
+			SpenderInputIndex: inputIdx,
+			SpendingHeight:    item.Height,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// notifyBlockEpochs notifies all registered block epoch clients of the newly
+// connected block to the main chain.
+func (e *ElectrumNotifier) notifyBlockEpochs(newHeight int32,
+	newSha *chainhash.Hash, blockHeader *wire.BlockHeader) {
+
+	for _, client := range e.blockEpochClients {
+		e.notifyBlockEpochClient(client, newHeight, newSha, blockHeader)
+	}
+}
+
+// notifyBlockEpochClient sends a registered block epoch client a notification
+// about a specific block.
+func (e *ElectrumNotifier) notifyBlockEpochClient(
+	epochClient *blockEpochRegistration, height int32, sha *chainhash.Hash,
+	header *wire.BlockHeader) {
+
+	epoch := &chainntnfs.BlockEpoch{
+		Height:      height,
+		Hash:        sha,
+		BlockHeader: header,
+	}
+
+	select {
+	case epochClient.epochQueue.ChanIn() <- epoch:
+	case <-epochClient.cancelChan:
+	case <-e.quit:
+	}
+}
+
+// RegisterSpendNtfn registers an intent to be notified once the target
+// outpoint/output script has been spent by a transaction on-chain. When
+// intending to be notified of the spend of an output script, a nil outpoint
+// must be used. The heightHint should represent the earliest height in the
+// chain of the transaction that spent the outpoint/output script.
+//
+// Once a spend of has been detected, the details of the spending event will be
+// sent across the 'Spend' channel.
+func (e *ElectrumNotifier) RegisterSpendNtfn(outpoint *wire.OutPoint,
+	pkScript []byte, heightHint uint32) (*chainntnfs.SpendEvent, error) {
+
+	// Spends are found in the history of the output script, so it has to
+	// be watched before registering the spend with the TxNotifier.
+	e.watch(pkScript)
+
+	ntfn, err := e.txNotifier.RegisterSpend(outpoint, pkScript, heightHint)
+	if err != nil {
+		return nil, err
+	}
+
+	// If the txNotifier didn't return any details to perform a historical
+	// scan of the chain, then we can return early as there's nothing left
+	// for us to do.
+	if ntfn.HistoricalDispatch == nil {
+		return ntfn.Event, nil
+	}
+
+	select {
+	case e.notificationRegistry <- ntfn.HistoricalDispatch:
+		return ntfn.Event, nil
+	case <-e.quit:
+		return nil, chainntnfs.ErrChainNotifierShuttingDown
+	}
+}
+
+// RegisterConfirmationsNtfn registers an intent to be notified once the target
+// txid/output script has reached numConfs confirmations on-chain. When
+// intending to be notified of the confirmation of an output script, a nil txid
+// must be used. The heightHint should represent the earliest height at which
+// the txid/output script could have been included in the chain.
+//
+// Progress on the number of confirmations left can be read from the 'Updates'
+// channel. Once it has reached all of its confirmations, a notification will be
+// sent across the 'Confirmed' channel.
+func (e *ElectrumNotifier) RegisterConfirmationsNtfn(txid *chainhash.Hash,
+	pkScript []byte, numConfs, heightHint uint32,
+	opts ...chainntnfs.NotifierOption) (*chainntnfs.ConfirmationEvent, error) {
+
+	// Confirmations are found in the history of the output script, so it
+	// has to be watched before registering the confirmation with the
+	// TxNotifier.
+	e.watch(pkScript)
+
+	// Register the conf notification with the TxNotifier. A non-nil value
+	// for `dispatch` will be returned if we are required to perform a
+	// manual scan for the confirmation. Otherwise the notifier will begin
+	// watching at tip for the transaction to confirm.
+	ntfn, err := e.txNotifier.RegisterConf(
+		txid, pkScript, numConfs, heightHint, opts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if ntfn.HistoricalDispatch == nil {
+		return ntfn.Event, nil
+	}
+
+	select {
+	case e.notificationRegistry <- ntfn.HistoricalDispatch:
+		return ntfn.Event, nil
+	case <-e.quit:
+		return nil, chainntnfs.ErrChainNotifierShuttingDown
+	}
+}
+
+// blockEpochRegistration represents a client's intent to receive a
+// notification with each newly connected block.
+type blockEpochRegistration struct {
+	epochID uint64
+
+	epochChan chan *chainntnfs.BlockEpoch
+
+	epochQueue *queue.ConcurrentQueue
+
+	bestBlock *chainntnfs.BlockEpoch
+
+	errorChan chan error
+
+	cancelChan chan struct{}
+
+	wg sync.WaitGroup
+}
+
+// epochCancel is a message sent to the ElectrumNotifier when a client wishes
+// to cancel an outstanding epoch notification that has yet to be dispatched.
+type epochCancel struct {
+	epochID uint64
+}
+
+// RegisterBlockEpochNtfn returns a BlockEpochEvent which subscribes the
+// caller to receive notifications, of each new block connected to the main
+// chain. Clients have the option of passing in their best known block, which
+// the notifier uses to check if they are behind on blocks and catch them up. If
+// they do not provide one, then a notification will be dispatched immediately
+// for the current tip of the chain upon a successful registration.
+func (e *ElectrumNotifier) RegisterBlockEpochNtfn(
+	bestBlock *chainntnfs.BlockEpoch) (*chainntnfs.BlockEpochEvent, error) {
+
+	reg := &blockEpochRegistration{
+		epochQueue: queue.NewConcurrentQueue(20),
+		epochChan:  make(chan *chainntnfs.BlockEpoch, 20),
+		cancelChan: make(chan struct{}),
+		epochID:    atomic.AddUint64(&e.epochClientCounter, 1),
+		bestBlock:  bestBlock,
+		errorChan:  make(chan error, 1),
+	}
+	reg.epochQueue.Start()
+
+	// Before we send the request to the main goroutine, we'll launch a new
+	// goroutine to proxy items added to our queue to the client itself.
+	// This ensures that all notifications are received *in order*.
+	reg.wg.Add(1)
+	go func() {
+		defer reg.wg.Done()
+
+		for {
+			select {
+			case ntfn := <-reg.epochQueue.ChanOut():
+				blockNtfn := ntfn.(*chainntnfs.BlockEpoch)
+				select {
+				case reg.epochChan <- blockNtfn:
+
+				case <-reg.cancelChan:
+					return
+
+				case <-e.quit:
+					return
+				}
+
+			case <-reg.cancelChan:
+				return
+
+			case <-e.quit:
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-e.quit:
+		// As we're exiting before the registration could be sent,
+		// we'll stop the queue now ourselves.
+		reg.epochQueue.Stop()
+
+		return nil, errors.New("chainntnfs: system interrupt while " +
+			"attempting to register for block epoch notification.")
+	case e.notificationRegistry <- reg:
+		return &chainntnfs.BlockEpochEvent{
+			Epochs: reg.epochChan,
+			Cancel: func() {
+				cancel := &epochCancel{
+					epochID: reg.epochID,
+				}
+
+				// Submit epoch cancellation to notification
+				// dispatcher.
+				select {
+				case e.notificationCancels <- cancel:
+					// Cancellation is being handled, drain
+					// the epoch channel until it is closed
+					// before yielding to caller.
+					for {
+						select {
+						case _, ok := <-reg.epochChan:
+							if !ok {
+								return
+							}
+						case <-e.quit:
+							return
+						}
+					}
+				case <-e.quit:
+				}
+			},
+		}, nil
+	}
+}
+
+// SubscribeMempoolSpent allows the caller to register a subscription to watch
+// for a spend of an outpoint in the mempool.The event will be dispatched once
+// the outpoint is spent in the mempool.
+//
+// NOTE: part of the MempoolWatcher interface.
+func (e *ElectrumNotifier) SubscribeMempoolSpent(
+	outpoint wire.OutPoint) (*chainntnfs.MempoolSpendEvent, error) {
+
+	// Spends are found in the history of the output script, which we'll
+	// take from the funding transaction.
+	fundingTx, err := e.backend.Transaction(&outpoint.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch funding transaction: "+
+			"%w", err)
+	}
+	if int(outpoint.Index) >= len(fundingTx.TxOut) {
+		return nil, fmt.Errorf("invalid output index %v", outpoint)
+	}
+	e.watch(fundingTx.TxOut[outpoint.Index].PkScript)
+
+	return e.memNotifier.SubscribeInput(outpoint), nil
+}
+
+// CancelMempoolSpendEvent allows the caller to cancel a subscription to watch
+// for a spend of an outpoint in the mempool.
+//
+// NOTE: part of the MempoolWatcher interface.
+func (e *ElectrumNotifier) CancelMempoolSpendEvent(
+	sub *chainntnfs.MempoolSpendEvent) {
+
+	e.memNotifier.UnsubscribeEvent(sub)
+}
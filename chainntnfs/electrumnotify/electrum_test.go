@@ -0,0 +1,323 @@
+package electrumnotify
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/electrum"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testPollInterval is the interval in which the notifier polls the
+	// mock backend in tests.
+	testPollInterval = 10 * time.Millisecond
+
+	// testTimeout is the time we wait for a notification.
+	testTimeout = 5 * time.Second
+)
+
+var (
+	testParams = &chaincfg.RegressionNetParams
+
+	// testWitnessScript is an anyone-can-spend witness script, so that
+	// the script of the spent output can be derived from the witness of
+	// test transactions.
+	testWitnessScript = []byte{txscript.OP_TRUE}
+
+	// testScript is the P2WSH output script of testWitnessScript.
+	testScript = func() []byte {
+		scriptHash := sha256.Sum256(testWitnessScript)
+		return append([]byte{txscript.OP_0, txscript.OP_DATA_32},
+			scriptHash[:]...)
+	}()
+)
+
+func initHintCache(t *testing.T) *channeldb.HeightHintCache {
+	t.Helper()
+
+	db, err := channeldb.Open(t.TempDir())
+	require.NoError(t, err, "unable to create db")
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	testCfg := channeldb.CacheConfig{
+		QueryDisable: false,
+	}
+	hintCache, err := channeldb.NewHeightHintCache(testCfg, db.Backend)
+	require.NoError(t, err, "unable to create hint cache")
+
+	return hintCache
+}
+
+// setUpNotifier is a helper function to start a new notifier backed by the
+// given mock backend.
+func setUpNotifier(t *testing.T,
+	backend *electrum.MockBackend) *ElectrumNotifier {
+
+	t.Helper()
+
+	hintCache := initHintCache(t)
+
+	notifier := New(
+		backend, testParams, hintCache, hintCache, testPollInterval,
+	)
+	require.NoError(t, notifier.Start(), "unable to start notifier")
+	t.Cleanup(func() {
+		require.NoError(t, notifier.Stop())
+	})
+
+	return notifier
+}
+
+// newTestTx creates a transaction that spends the given outpoint as an output
+// of the test script and pays to the test script.
+func newTestTx(prevOut wire.OutPoint) *wire.MsgTx {
+	witness := wire.TxWitness{testWitnessScript}
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&prevOut, nil, witness))
+	tx.AddTxOut(wire.NewTxOut(10_000, testScript))
+
+	return tx
+}
+
+// dummyOutPoint returns an outpoint that isn't part of the chain, so that
+// transactions spending it have unique hashes.
+func dummyOutPoint(i uint32) wire.OutPoint {
+	return wire.OutPoint{Hash: chainhash.Hash{0xff}, Index: i}
+}
+
+// waitForHeight waits until the notifier has processed the block at the given
+// height.
+func waitForHeight(t *testing.T, notifier *ElectrumNotifier, height uint32) {
+	t.Helper()
+
+	epochs, err := notifier.RegisterBlockEpochNtfn(nil)
+	require.NoError(t, err)
+	defer epochs.Cancel()
+
+	for {
+		select {
+		case epoch := <-epochs.Epochs:
+			if epoch.Height >= int32(height) {
+				return
+			}
+
+		case <-time.After(testTimeout):
+			t.Fatalf("notifier didn't reach height %v", height)
+		}
+	}
+}
+
+// TestConfirmationNotification checks that confirmations found in the history
+// of the output script are dispatched with the index of the transaction in
+// its block, and that a reorg is reported.
+func TestConfirmationNotification(t *testing.T) {
+	t.Parallel()
+
+	backend := electrum.NewMockBackend(testParams)
+	backend.BlocksUnsupported = true
+	notifier := setUpNotifier(t, backend)
+
+	tx := newTestTx(dummyOutPoint(0))
+	txid := tx.TxHash()
+	confEvent, err := notifier.RegisterConfirmationsNtfn(
+		&txid, testScript, 1, 1,
+	)
+	require.NoError(t, err)
+
+	// Confirm the transaction after an unrelated one.
+	unrelatedTx := wire.NewMsgTx(2)
+	unrelatedTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	block := backend.AddBlock(unrelatedTx, tx)
+
+	select {
+	case conf := <-confEvent.Confirmed:
+		blockHash := block.BlockHash()
+		require.Equal(t, &blockHash, conf.BlockHash)
+		require.EqualValues(t, 1, conf.BlockHeight)
+		require.EqualValues(t, 1, conf.TxIndex)
+		require.Equal(t, txid, conf.Tx.TxHash())
+
+		// The backend doesn't serve blocks.
+		require.Nil(t, conf.Block)
+
+	case <-time.After(testTimeout):
+		t.Fatalf("confirmation notification not received")
+	}
+
+	// Reorg the block that confirmed the transaction out of the chain.
+	backend.DisconnectBlocks(1)
+	backend.AddBlock()
+	backend.AddBlock()
+
+	select {
+	case depth := <-confEvent.NegativeConf:
+		require.EqualValues(t, 1, depth)
+
+	case <-time.After(testTimeout):
+		t.Fatalf("negative confirmation notification not received")
+	}
+}
+
+// TestHistoricalConfDetails checks that a transaction that confirmed before
+// the registration is found in the history of its output script.
+func TestHistoricalConfDetails(t *testing.T) {
+	t.Parallel()
+
+	backend := electrum.NewMockBackend(testParams)
+
+	tx := newTestTx(dummyOutPoint(0))
+	block := backend.AddBlock(tx)
+	backend.AddBlock()
+
+	notifier := setUpNotifier(t, backend)
+	waitForHeight(t, notifier, 2)
+
+	txid := tx.TxHash()
+	confEvent, err := notifier.RegisterConfirmationsNtfn(
+		&txid, testScript, 2, 1, chainntnfs.WithIncludeBlock(),
+	)
+	require.NoError(t, err)
+
+	select {
+	case conf := <-confEvent.Confirmed:
+		blockHash := block.BlockHash()
+		require.Equal(t, &blockHash, conf.BlockHash)
+		require.EqualValues(t, 1, conf.BlockHeight)
+		require.EqualValues(t, 0, conf.TxIndex)
+
+		// The backend serves full blocks.
+		require.NotNil(t, conf.Block)
+		require.Equal(t, blockHash, conf.Block.BlockHash())
+
+	case <-time.After(testTimeout):
+		t.Fatalf("confirmation notification not received")
+	}
+}
+
+// TestSpendNotification checks that spends are dispatched both if they
+// happen after the registration and if they happened before.
+func TestSpendNotification(t *testing.T) {
+	t.Parallel()
+
+	backend := electrum.NewMockBackend(testParams)
+
+	// Confirm two outputs of the test script and spend the first one.
+	fundingTx := newTestTx(dummyOutPoint(0))
+	fundingTx.AddTxOut(wire.NewTxOut(10_000, testScript))
+	backend.AddBlock(fundingTx)
+
+	historicalOutPoint := wire.OutPoint{Hash: fundingTx.TxHash()}
+	historicalSpend := newTestTx(historicalOutPoint)
+	backend.AddBlock(historicalSpend)
+
+	notifier := setUpNotifier(t, backend)
+	waitForHeight(t, notifier, 2)
+
+	historicalEvent, err := notifier.RegisterSpendNtfn(
+		&historicalOutPoint, testScript, 1,
+	)
+	require.NoError(t, err)
+
+	select {
+	case spend := <-historicalEvent.Spend:
+		require.Equal(t, historicalSpend.TxHash(), *spend.SpenderTxHash)
+		require.EqualValues(t, 2, spend.SpendingHeight)
+
+	case <-time.After(testTimeout):
+		t.Fatalf("historical spend notification not received")
+	}
+
+	// Spend the second output after registering for it.
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash(), Index: 1}
+	spendEvent, err := notifier.RegisterSpendNtfn(&outPoint, testScript, 1)
+	require.NoError(t, err)
+
+	spendTx := newTestTx(outPoint)
+	backend.AddBlock(spendTx)
+
+	select {
+	case spend := <-spendEvent.Spend:
+		require.Equal(t, spendTx.TxHash(), *spend.SpenderTxHash)
+		require.Equal(t, outPoint, *spend.SpentOutPoint)
+		require.EqualValues(t, 0, spend.SpenderInputIndex)
+		require.EqualValues(t, 3, spend.SpendingHeight)
+
+	case <-time.After(testTimeout):
+		t.Fatalf("spend notification not received")
+	}
+}
+
+// TestMempoolSpendNotification checks that a spend in the mempool is
+// dispatched to subscribers of the outpoint.
+func TestMempoolSpendNotification(t *testing.T) {
+	t.Parallel()
+
+	backend := electrum.NewMockBackend(testParams)
+
+	fundingTx := newTestTx(dummyOutPoint(0))
+	backend.AddBlock(fundingTx)
+
+	notifier := setUpNotifier(t, backend)
+	waitForHeight(t, notifier, 1)
+
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash()}
+	event, err := notifier.SubscribeMempoolSpent(outPoint)
+	require.NoError(t, err)
+
+	spendTx := newTestTx(outPoint)
+	backend.AddMempoolTx(spendTx)
+
+	select {
+	case spend := <-event.Spend:
+		require.Equal(t, spendTx.TxHash(), *spend.SpenderTxHash)
+		require.Equal(t, outPoint, *spend.SpentOutPoint)
+
+	case <-time.After(testTimeout):
+		t.Fatalf("mempool spend notification not received")
+	}
+}
+
+// TestBlockEpochNotification checks that clients are notified of every new
+// block, including blocks they missed before registering.
+func TestBlockEpochNotification(t *testing.T) {
+	t.Parallel()
+
+	backend := electrum.NewMockBackend(testParams)
+	notifier := setUpNotifier(t, backend)
+
+	genesisHash := testParams.GenesisHash
+	epochs, err := notifier.RegisterBlockEpochNtfn(&chainntnfs.BlockEpoch{
+		Hash:   genesisHash,
+		Height: 0,
+	})
+	require.NoError(t, err)
+	defer epochs.Cancel()
+
+	var blocks []*wire.MsgBlock
+	for i := 0; i < 3; i++ {
+		blocks = append(blocks, backend.AddBlock())
+	}
+
+	for i, block := range blocks {
+		select {
+		case epoch := <-epochs.Epochs:
+			require.EqualValues(t, i+1, epoch.Height)
+			require.Equal(t, block.BlockHash(), *epoch.Hash)
+			require.NotNil(t, epoch.BlockHeader)
+
+		case <-time.After(testTimeout):
+			t.Fatalf("block epoch %v not received", i+1)
+		}
+	}
+}
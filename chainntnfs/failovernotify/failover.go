@@ -0,0 +1,690 @@
+package failovernotify
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+)
+
+const (
+	// primaryIndex is the index of the primary backend.
+	primaryIndex = 0
+
+	// fallbackIndex is the index of the fallback backend.
+	fallbackIndex = 1
+
+	// epochBufferSize is the number of block epochs that are buffered for
+	// a client.
+	epochBufferSize = 20
+)
+
+// ChainView is the part of a chain source the FailoverNotifier uses to
+// compare the chains of its backends.
+type ChainView interface {
+	// GetBestBlock returns the hash and height of the best block of the
+	// backend.
+	GetBestBlock() (*chainhash.Hash, int32, error)
+
+	// GetBlockHash returns the hash of the block at the given height in
+	// the main chain of the backend.
+	GetBlockHash(height int64) (*chainhash.Hash, error)
+}
+
+// Backend is a chain backend the FailoverNotifier dispatches notifications
+// from.
+type Backend struct {
+	// Name identifies the backend in log messages.
+	Name string
+
+	// Notifier is the chain notifier of the backend.
+	Notifier chainntnfs.ChainNotifier
+
+	// NewNotifier optionally creates a new chain notifier for the
+	// backend. If the notifier fails to start, starting the backend is
+	// retried with a new notifier, as a notifier can only be started
+	// once. If it's nil, the start of Notifier is retried instead.
+	NewNotifier func() (chainntnfs.ChainNotifier, error)
+
+	// HealthCheck is a low-cost query that fails if the backend can't be
+	// reached.
+	HealthCheck func() error
+
+	// Chain is used to look up the best block of the backend and the
+	// hashes of its blocks.
+	Chain ChainView
+}
+
+// Config houses the parameters of a FailoverNotifier.
+type Config struct {
+	// Primary is the backend notifications are dispatched from as long
+	// as it's healthy.
+	Primary *Backend
+
+	// Fallback is the backend notifications are dispatched from while the
+	// primary one is down.
+	Fallback *Backend
+
+	// CheckInterval is the interval in which the health of both backends
+	// is checked and their chains are compared.
+	CheckInterval time.Duration
+
+	// FailureThreshold is the number of consecutive failed health checks
+	// after which a backend is considered to be down.
+	FailureThreshold int
+
+	// MaxBlockLag is the number of blocks a backend may fall behind the
+	// other one before it's considered to be down. Zero disables the
+	// check.
+	MaxBlockLag uint32
+
+	// DisagreementThreshold is the number of consecutive checks in which
+	// the backends must report different blocks at the same height before
+	// an alert is logged.
+	DisagreementThreshold int
+}
+
+// backendState is a backend along with the state the monitor keeps about it.
+type backendState struct {
+	*Backend
+
+	// notifier is the notifier of the backend that was started last.
+	notifier chainntnfs.ChainNotifier
+
+	// running is true if notifier was started.
+	//
+	// NOTE: notifier and running are only modified by Start and the
+	// monitor while holding regMtx, so they can be read without it from
+	// there.
+	running bool
+
+	// failures is the number of consecutive failed health checks. It's
+	// only accessed by the monitor.
+	failures int
+
+	// height is the height of the best block at the last successful
+	// health check. It's only accessed by the monitor.
+	height int32
+}
+
+// FailoverNotifier is a ChainNotifier that multiplexes a primary and a
+// fallback chain backend. Every registration is made with both backends, so
+// that no registration is lost when the primary backend goes down. A backend
+// that fails to start is retried, and once it runs, the live registrations are
+// made with it as well.
+// Notifications are only dispatched from the active backend, which is the
+// primary one as long as it's healthy. When the active backend changes, the
+// events the new one has seen but that weren't dispatched yet are dispatched,
+// so clients see a consistent stream of events. The chains of both backends
+// are compared in every check and an alert is logged if they disagree.
+type FailoverNotifier struct {
+	started int32 // To be used atomically.
+	stopped int32 // To be used atomically.
+
+	cfg *Config
+
+	backends [2]*backendState
+
+	// activeMtx guards active and switched.
+	activeMtx sync.RWMutex
+
+	// active is the index of the backend notifications are dispatched
+	// from.
+	active int
+
+	// switched is closed and replaced whenever the active backend
+	// changes.
+	switched chan struct{}
+
+	// disagreements is the number of consecutive checks in which the
+	// backends reported different blocks. It's only accessed by the
+	// monitor.
+	disagreements int
+
+	// chainsDisagree is set to 1 while the backends disagree on the
+	// chain. To be used atomically.
+	chainsDisagree int32
+
+	// regMtx guards registrations and nextRegID, as well as the notifiers
+	// of the backends, so that every live registration is made exactly
+	// once with every running backend.
+	regMtx sync.Mutex
+
+	// registrations are the live registrations of the clients, keyed by
+	// their ID. They're made with a backend once it starts.
+	registrations map[uint64]registerFunc
+
+	// nextRegID is the ID of the next registration.
+	nextRegID uint64
+
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// registerFunc makes a registration with the backend with the given index.
+type registerFunc func(int, chainntnfs.ChainNotifier) error
+
+// Ensure FailoverNotifier implements the ChainNotifier interface at compile
+// time.
+var _ chainntnfs.ChainNotifier = (*FailoverNotifier)(nil)
+
+// New returns a new FailoverNotifier that dispatches notifications from the
+// given primary and fallback backends.
+func New(cfg *Config) *FailoverNotifier {
+	return &FailoverNotifier{
+		cfg: cfg,
+		backends: [2]*backendState{
+			{Backend: cfg.Primary},
+			{Backend: cfg.Fallback},
+		},
+		active:        primaryIndex,
+		switched:      make(chan struct{}),
+		registrations: make(map[uint64]registerFunc),
+		quit:          make(chan struct{}),
+	}
+}
+
+// Start starts the notifiers of both backends and the monitor that checks
+// their health. Failing to start one of the notifiers isn't fatal, as long as
+// the other one starts. The monitor retries to start it then.
+//
+// NOTE: This is part of the chainntnfs.ChainNotifier interface.
+func (f *FailoverNotifier) Start() error {
+	if !atomic.CompareAndSwapInt32(&f.started, 0, 1) {
+		return nil
+	}
+
+	f.regMtx.Lock()
+	var startErr error
+	for _, b := range f.backends {
+		b.notifier = b.Notifier
+		if err := b.notifier.Start(); err != nil {
+			chainntnfs.Log.Errorf("Unable to start notifier of "+
+				"chain backend %v: %v", b.Name, err)
+
+			startErr = err
+			continue
+		}
+
+		b.running = true
+	}
+	f.regMtx.Unlock()
+
+	switch {
+	case !f.backends[primaryIndex].running &&
+		!f.backends[fallbackIndex].running:
+
+		return startErr
+
+	case !f.backends[primaryIndex].running:
+		chainntnfs.Log.Warnf("Using fallback chain backend %v",
+			f.cfg.Fallback.Name)
+
+		f.setActiveBackend(fallbackIndex)
+	}
+
+	f.wg.Add(1)
+	go f.monitor()
+
+	return nil
+}
+
+// Started returns true if the notifier has been started.
+//
+// NOTE: This is part of the chainntnfs.ChainNotifier interface.
+func (f *FailoverNotifier) Started() bool {
+	return atomic.LoadInt32(&f.started) != 0
+}
+
+// Stop shuts down the notifier along with the notifiers of both backends.
+//
+// NOTE: This is part of the chainntnfs.ChainNotifier interface.
+func (f *FailoverNotifier) Stop() error {
+	if !atomic.CompareAndSwapInt32(&f.stopped, 0, 1) {
+		return nil
+	}
+
+	close(f.quit)
+	f.wg.Wait()
+
+	var stopErr error
+	for _, b := range f.backends {
+		if !b.running {
+			continue
+		}
+
+		if err := b.notifier.Stop(); err != nil {
+			chainntnfs.Log.Errorf("Unable to stop notifier of "+
+				"chain backend %v: %v", b.Name, err)
+
+			stopErr = err
+		}
+	}
+
+	return stopErr
+}
+
+// FallbackActive returns true if notifications are currently dispatched from
+// the fallback backend.
+func (f *FailoverNotifier) FallbackActive() bool {
+	active, _ := f.activeBackend()
+	return active == fallbackIndex
+}
+
+// ChainsDisagree returns true if the backends have reported different blocks
+// at the same height in at least DisagreementThreshold consecutive checks.
+func (f *FailoverNotifier) ChainsDisagree() bool {
+	return atomic.LoadInt32(&f.chainsDisagree) != 0
+}
+
+// activeBackend returns the index of the active backend along with a channel
+// that is closed once it changes.
+func (f *FailoverNotifier) activeBackend() (int, <-chan struct{}) {
+	f.activeMtx.RLock()
+	defer f.activeMtx.RUnlock()
+
+	return f.active, f.switched
+}
+
+// setActiveBackend makes the backend with the given index the active one.
+func (f *FailoverNotifier) setActiveBackend(active int) {
+	f.activeMtx.Lock()
+	defer f.activeMtx.Unlock()
+
+	if f.active == active {
+		return
+	}
+
+	f.active = active
+	close(f.switched)
+	f.switched = make(chan struct{})
+}
+
+// monitor checks the health of both backends in the configured interval and
+// switches the active backend if needed.
+//
+// NOTE: This MUST be run as a goroutine.
+func (f *FailoverNotifier) monitor() {
+	defer f.wg.Done()
+
+	ticker := time.NewTicker(f.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			f.checkBackends()
+
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// startBackend retries to start the notifier of a backend that isn't running
+// and makes all live registrations with it once it runs.
+func (f *FailoverNotifier) startBackend(i int) {
+	b := f.backends[i]
+
+	notifier := b.notifier
+	if b.NewNotifier != nil {
+		var err error
+		notifier, err = b.NewNotifier()
+		if err != nil {
+			chainntnfs.Log.Warnf("Unable to create notifier of "+
+				"chain backend %v: %v", b.Name, err)
+			return
+		}
+	}
+
+	if err := notifier.Start(); err != nil {
+		chainntnfs.Log.Debugf("Unable to start notifier of chain "+
+			"backend %v: %v", b.Name, err)
+		return
+	}
+
+	f.regMtx.Lock()
+	defer f.regMtx.Unlock()
+
+	chainntnfs.Log.Infof("Started notifier of chain backend %v, making "+
+		"%d registrations with it", b.Name, len(f.registrations))
+
+	b.notifier = notifier
+	b.running = true
+
+	for _, registerFn := range f.registrations {
+		if err := registerFn(i, notifier); err != nil {
+			chainntnfs.Log.Warnf("Unable to register with chain "+
+				"backend %v: %v", b.Name, err)
+		}
+	}
+}
+
+// checkBackends retries to start the backends that aren't running, runs the
+// health checks of the running ones, compares their chains and selects the
+// backend notifications are dispatched from.
+func (f *FailoverNotifier) checkBackends() {
+	for i, b := range f.backends {
+		if !b.running {
+			f.startBackend(i)
+		}
+		if !b.running {
+			continue
+		}
+
+		err := b.HealthCheck()
+		var height int32
+		if err == nil {
+			_, height, err = b.Chain.GetBestBlock()
+		}
+		if err != nil {
+			b.failures++
+			chainntnfs.Log.Warnf("Health check of chain "+
+				"backend %v failed (%d/%d): %v", b.Name,
+				b.failures, f.cfg.FailureThreshold, err)
+
+			continue
+		}
+
+		if b.failures >= f.cfg.FailureThreshold {
+			chainntnfs.Log.Infof("Chain backend %v is reachable "+
+				"again", b.Name)
+		}
+
+		b.failures = 0
+		b.height = height
+	}
+
+	f.compareChains()
+
+	active, _ := f.activeBackend()
+	next := active
+	switch {
+	case f.healthy(primaryIndex):
+		next = primaryIndex
+
+	case f.healthy(fallbackIndex):
+		next = fallbackIndex
+
+	default:
+		chainntnfs.Log.Errorf("Both chain backends %v and %v are down",
+			f.cfg.Primary.Name, f.cfg.Fallback.Name)
+	}
+
+	if next == active {
+		return
+	}
+
+	if next == fallbackIndex {
+		chainntnfs.Log.Warnf("Primary chain backend %v is down, "+
+			"failing over to %v", f.cfg.Primary.Name,
+			f.cfg.Fallback.Name)
+	} else {
+		chainntnfs.Log.Infof("Primary chain backend %v is healthy "+
+			"again, switching back from %v", f.cfg.Primary.Name,
+			f.cfg.Fallback.Name)
+	}
+
+	f.setActiveBackend(next)
+}
+
+// healthy returns true if the backend with the given index passed its recent
+// health checks and doesn't lag behind the other one.
+func (f *FailoverNotifier) healthy(i int) bool {
+	b := f.backends[i]
+	if !b.running || b.failures >= f.cfg.FailureThreshold {
+		return false
+	}
+
+	// We only trust the height of the other backend if its last health
+	// check passed.
+	other := f.backends[1-i]
+	if f.cfg.MaxBlockLag == 0 || !other.running || other.failures > 0 {
+		return true
+	}
+
+	if other.height > b.height+int32(f.cfg.MaxBlockLag) {
+		chainntnfs.Log.Warnf("Chain backend %v is at height %d, "+
+			"%d blocks behind %v", b.Name, b.height,
+			other.height-b.height, other.Name)
+
+		return false
+	}
+
+	return true
+}
+
+// compareChains compares the blocks both backends report at the height of the
+// lower of their tips, and alerts if they disagree for too long.
+func (f *FailoverNotifier) compareChains() {
+	primary := f.backends[primaryIndex]
+	fallback := f.backends[fallbackIndex]
+	if !primary.running || !fallback.running || primary.failures > 0 ||
+		fallback.failures > 0 {
+
+		return
+	}
+
+	height := primary.height
+	if fallback.height < height {
+		height = fallback.height
+	}
+
+	primaryHash, err := primary.Chain.GetBlockHash(int64(height))
+	if err != nil {
+		chainntnfs.Log.Debugf("Unable to get block at height %d "+
+			"from %v: %v", height, primary.Name, err)
+		return
+	}
+	fallbackHash, err := fallback.Chain.GetBlockHash(int64(height))
+	if err != nil {
+		chainntnfs.Log.Debugf("Unable to get block at height %d "+
+			"from %v: %v", height, fallback.Name, err)
+		return
+	}
+
+	if *primaryHash == *fallbackHash {
+		if f.disagreements >= f.cfg.DisagreementThreshold {
+			chainntnfs.Log.Infof("Chain backends %v and %v agree "+
+				"again at height %d", primary.Name,
+				fallback.Name, height)
+		}
+
+		f.disagreements = 0
+		atomic.StoreInt32(&f.chainsDisagree, 0)
+
+		return
+	}
+
+	f.disagreements++
+	if f.disagreements < f.cfg.DisagreementThreshold {
+		chainntnfs.Log.Debugf("Chain backends %v and %v disagree at "+
+			"height %d (%d/%d)", primary.Name, fallback.Name,
+			height, f.disagreements, f.cfg.DisagreementThreshold)
+		return
+	}
+
+	chainntnfs.Log.Errorf("ALERT: chain backends %v and %v disagree on "+
+		"the block at height %d: %v vs %v", primary.Name,
+		fallback.Name, height, primaryHash, fallbackHash)
+
+	atomic.StoreInt32(&f.chainsDisagree, 1)
+}
+
+// register calls the given registration function for every running backend
+// and records it, so that it's called for backends that start later as well.
+// A failed registration is only logged, as long as another one succeeds. The
+// returned ID must be passed to unregister once the registration ends.
+func (f *FailoverNotifier) register(registerFn registerFunc) (uint64, error) {
+	if atomic.LoadInt32(&f.stopped) != 0 {
+		return 0, chainntnfs.ErrChainNotifierShuttingDown
+	}
+
+	f.regMtx.Lock()
+	defer f.regMtx.Unlock()
+
+	var (
+		registered int
+		regErr     error
+	)
+	for i, b := range f.backends {
+		if !b.running {
+			continue
+		}
+
+		if err := registerFn(i, b.notifier); err != nil {
+			chainntnfs.Log.Warnf("Unable to register with chain "+
+				"backend %v: %v", b.Name, err)
+
+			regErr = err
+			continue
+		}
+
+		registered++
+	}
+
+	if registered == 0 {
+		if regErr == nil {
+			regErr = errors.New("no chain backend running")
+		}
+
+		return 0, regErr
+	}
+
+	id := f.nextRegID
+	f.nextRegID++
+	f.registrations[id] = registerFn
+
+	return id, nil
+}
+
+// unregister removes a registration, so that it isn't made with backends that
+// start later.
+func (f *FailoverNotifier) unregister(id uint64) {
+	f.regMtx.Lock()
+	defer f.regMtx.Unlock()
+
+	delete(f.registrations, id)
+}
+
+// RegisterConfirmationsNtfn registers an intent to be notified once the
+// target txid/output script has reached numConfs confirmations on-chain. The
+// registration is made with both backends.
+//
+// NOTE: This is part of the chainntnfs.ChainNotifier interface.
+func (f *FailoverNotifier) RegisterConfirmationsNtfn(txid *chainhash.Hash,
+	pkScript []byte, numConfs, heightHint uint32,
+	opts ...chainntnfs.NotifierOption) (*chainntnfs.ConfirmationEvent,
+	error) {
+
+	added := make(chan backendEvent[chainntnfs.ConfirmationEvent], 2)
+	id, err := f.register(func(i int, n chainntnfs.ChainNotifier) error {
+		event, err := n.RegisterConfirmationsNtfn(
+			txid, pkScript, numConfs, heightHint, opts...,
+		)
+		if err != nil {
+			return err
+		}
+
+		added <- backendEvent[chainntnfs.ConfirmationEvent]{i, event}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cancelChan := make(chan struct{})
+	var cancelOnce sync.Once
+	event := chainntnfs.NewConfirmationEvent(numConfs, func() {
+		cancelOnce.Do(func() {
+			close(cancelChan)
+		})
+	})
+
+	f.wg.Add(1)
+	go f.forwardConf(id, added, event, cancelChan)
+
+	return event, nil
+}
+
+// RegisterSpendNtfn registers an intent to be notified once the target
+// outpoint/output script has been spent by a transaction on-chain. The
+// registration is made with both backends.
+//
+// NOTE: This is part of the chainntnfs.ChainNotifier interface.
+func (f *FailoverNotifier) RegisterSpendNtfn(outpoint *wire.OutPoint,
+	pkScript []byte, heightHint uint32) (*chainntnfs.SpendEvent, error) {
+
+	added := make(chan backendEvent[chainntnfs.SpendEvent], 2)
+	id, err := f.register(func(i int, n chainntnfs.ChainNotifier) error {
+		event, err := n.RegisterSpendNtfn(
+			outpoint, pkScript, heightHint,
+		)
+		if err != nil {
+			return err
+		}
+
+		added <- backendEvent[chainntnfs.SpendEvent]{i, event}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cancelChan := make(chan struct{})
+	var cancelOnce sync.Once
+	event := chainntnfs.NewSpendEvent(func() {
+		cancelOnce.Do(func() {
+			close(cancelChan)
+		})
+	})
+
+	f.wg.Add(1)
+	go f.forwardSpend(id, added, event, cancelChan)
+
+	return event, nil
+}
+
+// RegisterBlockEpochNtfn returns a BlockEpochEvent which subscribes the caller
+// to receive notifications of each new block connected to the main chain of
+// the active backend. The registration is made with both backends, so that
+// the blocks of the fallback backend are known once it becomes active.
+//
+// NOTE: This is part of the chainntnfs.ChainNotifier interface.
+func (f *FailoverNotifier) RegisterBlockEpochNtfn(
+	bestBlock *chainntnfs.BlockEpoch) (*chainntnfs.BlockEpochEvent, error) {
+
+	added := make(chan backendEvent[chainntnfs.BlockEpochEvent], 2)
+	id, err := f.register(func(i int, n chainntnfs.ChainNotifier) error {
+		event, err := n.RegisterBlockEpochNtfn(bestBlock)
+		if err != nil {
+			return err
+		}
+
+		added <- backendEvent[chainntnfs.BlockEpochEvent]{i, event}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cancelChan := make(chan struct{})
+	var cancelOnce sync.Once
+	epochs := make(chan *chainntnfs.BlockEpoch, epochBufferSize)
+
+	f.wg.Add(1)
+	go f.forwardEpochs(id, added, epochs, cancelChan)
+
+	return &chainntnfs.BlockEpochEvent{
+		Epochs: epochs,
+		Cancel: func() {
+			cancelOnce.Do(func() {
+				close(cancelChan)
+			})
+		},
+	}, nil
+}
@@ -0,0 +1,604 @@
+package failovernotify
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testCheckInterval is the interval in which the backends are checked
+	// in tests.
+	testCheckInterval = 10 * time.Millisecond
+
+	// testTimeout is the time we wait for a notification or a failover.
+	testTimeout = 5 * time.Second
+
+	// startHeight is the height of the chains of the mock backends when a
+	// test starts.
+	startHeight = 10
+)
+
+var errBackendDown = errors.New("backend down")
+
+// blockHash returns the hash of the block at the given height on the given
+// fork of the test chain.
+func blockHash(height int32, fork byte) *chainhash.Hash {
+	return &chainhash.Hash{byte(height), byte(height >> 8), fork}
+}
+
+// blockEpoch returns the epoch of the block at the given height on the given
+// fork of the test chain.
+func blockEpoch(height int32, fork byte) *chainntnfs.BlockEpoch {
+	return &chainntnfs.BlockEpoch{
+		Hash:   blockHash(height, fork),
+		Height: height,
+	}
+}
+
+// mockChain is a ChainView with a configurable tip and fork.
+type mockChain struct {
+	mtx    sync.Mutex
+	height int32
+	fork   byte
+	err    error
+}
+
+// GetBestBlock returns the tip of the chain.
+func (c *mockChain) GetBestBlock() (*chainhash.Hash, int32, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.err != nil {
+		return nil, 0, c.err
+	}
+
+	return blockHash(c.height, c.fork), c.height, nil
+}
+
+// GetBlockHash returns the hash of the block at the given height.
+func (c *mockChain) GetBlockHash(height int64) (*chainhash.Hash, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+	if height > int64(c.height) {
+		return nil, errors.New("block not found")
+	}
+
+	return blockHash(int32(height), c.fork), nil
+}
+
+// healthCheck fails while the chain is down.
+func (c *mockChain) healthCheck() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.err
+}
+
+// setErr makes all queries fail with the given error.
+func (c *mockChain) setErr(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.err = err
+}
+
+// setTip sets the tip of the chain.
+func (c *mockChain) setTip(height int32, fork byte) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.height = height
+	c.fork = fork
+}
+
+// mockNotifier is a ChainNotifier whose events are sent by the tests.
+type mockNotifier struct {
+	mtx      sync.Mutex
+	startErr error
+	confs    []*chainntnfs.ConfirmationEvent
+	spends   []*chainntnfs.SpendEvent
+	epochs   []chan *chainntnfs.BlockEpoch
+}
+
+// RegisterConfirmationsNtfn records a new confirmation event.
+func (n *mockNotifier) RegisterConfirmationsNtfn(*chainhash.Hash, []byte,
+	uint32, uint32,
+	...chainntnfs.NotifierOption) (*chainntnfs.ConfirmationEvent, error) {
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	event := chainntnfs.NewConfirmationEvent(1, func() {})
+	n.confs = append(n.confs, event)
+
+	return event, nil
+}
+
+// RegisterSpendNtfn records a new spend event.
+func (n *mockNotifier) RegisterSpendNtfn(*wire.OutPoint, []byte,
+	uint32) (*chainntnfs.SpendEvent, error) {
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	event := chainntnfs.NewSpendEvent(func() {})
+	n.spends = append(n.spends, event)
+
+	return event, nil
+}
+
+// RegisterBlockEpochNtfn records a new block epoch client.
+func (n *mockNotifier) RegisterBlockEpochNtfn(
+	*chainntnfs.BlockEpoch) (*chainntnfs.BlockEpochEvent, error) {
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	epochs := make(chan *chainntnfs.BlockEpoch, 20)
+	n.epochs = append(n.epochs, epochs)
+
+	return &chainntnfs.BlockEpochEvent{
+		Epochs: epochs,
+		Cancel: func() {},
+	}, nil
+}
+
+// Start fails with the configured error.
+func (n *mockNotifier) Start() error {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	return n.startErr
+}
+
+// setStartErr sets the error Start fails with.
+func (n *mockNotifier) setStartErr(err error) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.startErr = err
+}
+
+// Started returns true.
+func (n *mockNotifier) Started() bool {
+	return true
+}
+
+// Stop does nothing.
+func (n *mockNotifier) Stop() error {
+	return nil
+}
+
+// conf returns the only confirmation event.
+func (n *mockNotifier) conf(t *testing.T) *chainntnfs.ConfirmationEvent {
+	t.Helper()
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	require.Len(t, n.confs, 1)
+	return n.confs[0]
+}
+
+// spend returns the only spend event.
+func (n *mockNotifier) spend(t *testing.T) *chainntnfs.SpendEvent {
+	t.Helper()
+
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	require.Len(t, n.spends, 1)
+	return n.spends[0]
+}
+
+// sendEpochs sends the blocks at the given heights on the given fork to the
+// only block epoch client.
+func (n *mockNotifier) sendEpochs(t *testing.T, fork byte,
+	heights ...int32) {
+
+	t.Helper()
+
+	n.mtx.Lock()
+	require.Len(t, n.epochs, 1)
+	epochs := n.epochs[0]
+	n.mtx.Unlock()
+
+	for _, height := range heights {
+		epochs <- blockEpoch(height, fork)
+	}
+}
+
+// testBackend is a mock backend of the failover notifier.
+type testBackend struct {
+	notifier *mockNotifier
+	chain    *mockChain
+}
+
+// newTestBackend creates a mock backend whose chain is at the start height.
+func newTestBackend(name string) (*testBackend, *Backend) {
+	b := &testBackend{
+		notifier: &mockNotifier{},
+		chain:    &mockChain{height: startHeight},
+	}
+
+	return b, &Backend{
+		Name:        name,
+		Notifier:    b.notifier,
+		HealthCheck: b.chain.healthCheck,
+		Chain:       b.chain,
+	}
+}
+
+// setUpNotifier creates and starts a failover notifier with two mock
+// backends. The given function can modify the backends before the notifier is
+// started.
+func setUpNotifier(t *testing.T,
+	modify func(primary, fallback *testBackend)) (*FailoverNotifier,
+	*testBackend, *testBackend) {
+
+	t.Helper()
+
+	primary, primaryBackend := newTestBackend("primary")
+	fallback, fallbackBackend := newTestBackend("fallback")
+	if modify != nil {
+		modify(primary, fallback)
+	}
+
+	notifier := New(&Config{
+		Primary:               primaryBackend,
+		Fallback:              fallbackBackend,
+		CheckInterval:         testCheckInterval,
+		FailureThreshold:      2,
+		MaxBlockLag:           2,
+		DisagreementThreshold: 2,
+	})
+	require.NoError(t, notifier.Start())
+	t.Cleanup(func() {
+		require.NoError(t, notifier.Stop())
+	})
+
+	return notifier, primary, fallback
+}
+
+// waitForFallback waits until the fallback backend is active or inactive.
+func waitForFallback(t *testing.T, notifier *FailoverNotifier, active bool) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		return notifier.FallbackActive() == active
+	}, testTimeout, testCheckInterval)
+}
+
+// assertNoFailover checks that the primary backend stays active for a few
+// checks.
+func assertNoFailover(t *testing.T, notifier *FailoverNotifier) {
+	t.Helper()
+
+	require.Never(t, notifier.FallbackActive, 10*testCheckInterval,
+		testCheckInterval)
+}
+
+// receive returns the next value sent on the channel.
+func receive[T any](t *testing.T, c <-chan T) T {
+	t.Helper()
+
+	select {
+	case value := <-c:
+		return value
+
+	case <-time.After(testTimeout):
+		t.Fatalf("no notification received")
+
+		var zero T
+		return zero
+	}
+}
+
+// assertNothing checks that nothing is sent on the channel.
+func assertNothing[T any](t *testing.T, c <-chan T) {
+	t.Helper()
+
+	select {
+	case value := <-c:
+		t.Fatalf("unexpected notification: %v", value)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestFailover checks that the fallback backend becomes active while the
+// primary one fails its health checks or lags behind, and that the primary
+// backend becomes active again once it recovers.
+func TestFailover(t *testing.T) {
+	t.Parallel()
+
+	notifier, primary, fallback := setUpNotifier(t, nil)
+	require.False(t, notifier.FallbackActive())
+
+	primary.chain.setErr(errBackendDown)
+	waitForFallback(t, notifier, true)
+
+	primary.chain.setErr(nil)
+	waitForFallback(t, notifier, false)
+
+	// A lag of two blocks is tolerated, a lag of three isn't.
+	fallback.chain.setTip(startHeight+2, 0)
+	assertNoFailover(t, notifier)
+
+	fallback.chain.setTip(startHeight+3, 0)
+	waitForFallback(t, notifier, true)
+
+	primary.chain.setTip(startHeight+3, 0)
+	waitForFallback(t, notifier, false)
+
+	// A lagging fallback doesn't matter while both are down.
+	primary.chain.setErr(errBackendDown)
+	fallback.chain.setErr(errBackendDown)
+	assertNoFailover(t, notifier)
+}
+
+// TestFailoverPrimaryNotStarted checks that the fallback backend is active if
+// the notifier of the primary one fails to start.
+func TestFailoverPrimaryNotStarted(t *testing.T) {
+	t.Parallel()
+
+	notifier, _, fallback := setUpNotifier(t, func(primary,
+		_ *testBackend) {
+
+		primary.notifier.setStartErr(errBackendDown)
+	})
+	require.True(t, notifier.FallbackActive())
+
+	// Registrations are only made with the fallback backend.
+	txid := chainhash.Hash{1}
+	confEvent, err := notifier.RegisterConfirmationsNtfn(
+		&txid, nil, 1, 1,
+	)
+	require.NoError(t, err)
+
+	conf := &chainntnfs.TxConfirmation{BlockHeight: startHeight}
+	fallback.notifier.conf(t).Confirmed <- conf
+	require.Equal(t, conf, receive(t, confEvent.Confirmed))
+}
+
+// TestFailoverPrimaryStartedLater checks that starting a backend that failed
+// to start is retried, and that the live registrations are made with it once
+// it runs.
+func TestFailoverPrimaryStartedLater(t *testing.T) {
+	t.Parallel()
+
+	notifier, primary, fallback := setUpNotifier(t, func(primary,
+		_ *testBackend) {
+
+		primary.notifier.setStartErr(errBackendDown)
+	})
+	require.True(t, notifier.FallbackActive())
+
+	outpoint := wire.OutPoint{Index: 1}
+	spendEvent, err := notifier.RegisterSpendNtfn(&outpoint, nil, 1)
+	require.NoError(t, err)
+	epochEvent, err := notifier.RegisterBlockEpochNtfn(nil)
+	require.NoError(t, err)
+
+	// A canceled registration isn't made with the primary backend.
+	txid := chainhash.Hash{1}
+	confEvent, err := notifier.RegisterConfirmationsNtfn(
+		&txid, nil, 1, 1,
+	)
+	require.NoError(t, err)
+	confEvent.Cancel()
+
+	fallback.notifier.sendEpochs(t, 0, startHeight+1)
+	require.Equal(t, blockEpoch(startHeight+1, 0),
+		receive(t, epochEvent.Epochs))
+
+	// Once the primary backend starts, it becomes active and the live
+	// registrations are made with it.
+	primary.notifier.setStartErr(nil)
+	waitForFallback(t, notifier, false)
+
+	require.Eventually(t, func() bool {
+		primary.notifier.mtx.Lock()
+		defer primary.notifier.mtx.Unlock()
+
+		return len(primary.notifier.spends) == 1 &&
+			len(primary.notifier.epochs) == 1
+	}, testTimeout, testCheckInterval)
+	require.Empty(t, primary.notifier.confs)
+
+	primary.notifier.sendEpochs(t, 0, startHeight+1, startHeight+2)
+	require.Equal(t, blockEpoch(startHeight+2, 0),
+		receive(t, epochEvent.Epochs))
+
+	spend := &chainntnfs.SpendDetail{SpendingHeight: startHeight + 2}
+	primary.notifier.spend(t).Spend <- spend
+	require.Equal(t, spend, receive(t, spendEvent.Spend))
+}
+
+// TestFailoverConfirmation checks that confirmations are dispatched exactly
+// once from the active backend, and that the confirmation the fallback backend
+// saw is dispatched once it becomes active.
+func TestFailoverConfirmation(t *testing.T) {
+	t.Parallel()
+
+	notifier, primary, fallback := setUpNotifier(t, nil)
+
+	txid := chainhash.Hash{1}
+	confEvent, err := notifier.RegisterConfirmationsNtfn(
+		&txid, nil, 1, 1,
+	)
+	require.NoError(t, err)
+
+	primaryEvent := primary.notifier.conf(t)
+	fallbackEvent := fallback.notifier.conf(t)
+
+	// The confirmation of the inactive fallback backend isn't
+	// dispatched.
+	conf := &chainntnfs.TxConfirmation{
+		BlockHash:   blockHash(startHeight+1, 0),
+		BlockHeight: startHeight + 1,
+	}
+	fallbackEvent.Confirmed <- conf
+	assertNothing(t, confEvent.Confirmed)
+
+	// Once the primary backend goes down before it confirms the
+	// transaction, the confirmation of the fallback backend is
+	// dispatched.
+	primary.chain.setErr(errBackendDown)
+	waitForFallback(t, notifier, true)
+	require.Equal(t, conf, receive(t, confEvent.Confirmed))
+
+	// Switching back to the primary backend that lags behind doesn't
+	// dispatch a reorg, and its confirmation isn't dispatched again.
+	primary.chain.setErr(nil)
+	waitForFallback(t, notifier, false)
+	assertNothing(t, confEvent.NegativeConf)
+
+	primaryEvent.Confirmed <- conf
+	assertNothing(t, confEvent.Confirmed)
+
+	// A reorg of the active backend is dispatched, as is the new
+	// confirmation.
+	primaryEvent.NegativeConf <- 1
+	require.EqualValues(t, 1, receive(t, confEvent.NegativeConf))
+
+	newConf := &chainntnfs.TxConfirmation{
+		BlockHash:   blockHash(startHeight+2, 1),
+		BlockHeight: startHeight + 2,
+	}
+	primaryEvent.Confirmed <- newConf
+	require.Equal(t, newConf, receive(t, confEvent.Confirmed))
+
+	// Done is dispatched from the active backend only.
+	fallbackEvent.Done <- struct{}{}
+	assertNothing(t, confEvent.Done)
+
+	primaryEvent.Done <- struct{}{}
+	receive(t, confEvent.Done)
+}
+
+// TestFailoverSpend checks that spends are dispatched from the active backend
+// and that a reorg the new active backend saw is dispatched after a failover.
+func TestFailoverSpend(t *testing.T) {
+	t.Parallel()
+
+	notifier, primary, fallback := setUpNotifier(t, nil)
+
+	outPoint := wire.OutPoint{Index: 1}
+	spendEvent, err := notifier.RegisterSpendNtfn(&outPoint, nil, 1)
+	require.NoError(t, err)
+
+	primaryEvent := primary.notifier.spend(t)
+	fallbackEvent := fallback.notifier.spend(t)
+
+	spend := &chainntnfs.SpendDetail{
+		SpentOutPoint:  &outPoint,
+		SpendingHeight: startHeight + 1,
+	}
+	primaryEvent.Spend <- spend
+	require.Equal(t, spend, receive(t, spendEvent.Spend))
+
+	// The fallback backend sees the spend and its reorg while it's
+	// inactive.
+	fallbackEvent.Spend <- spend
+	fallbackEvent.Reorg <- struct{}{}
+	assertNothing(t, spendEvent.Reorg)
+
+	primary.chain.setErr(errBackendDown)
+	waitForFallback(t, notifier, true)
+	receive(t, spendEvent.Reorg)
+
+	// Cancelling the registration closes the channels.
+	spendEvent.Cancel()
+	_, ok := <-spendEvent.Spend
+	require.False(t, ok)
+}
+
+// TestFailoverBlockEpochs checks that blocks are dispatched once from the
+// active backend, and that the blocks the fallback backend saw are dispatched
+// after a failover.
+func TestFailoverBlockEpochs(t *testing.T) {
+	t.Parallel()
+
+	notifier, primary, fallback := setUpNotifier(t, nil)
+
+	epochEvent, err := notifier.RegisterBlockEpochNtfn(nil)
+	require.NoError(t, err)
+	defer epochEvent.Cancel()
+
+	assertEpoch := func(height int32, fork byte) {
+		t.Helper()
+
+		epoch := receive(t, epochEvent.Epochs)
+		require.Equal(t, blockEpoch(height, fork), epoch)
+	}
+
+	// Blocks both backends see are only dispatched once.
+	primary.notifier.sendEpochs(t, 0, startHeight+1)
+	assertEpoch(startHeight+1, 0)
+
+	fallback.notifier.sendEpochs(t, 0, startHeight+1)
+	assertNothing(t, epochEvent.Epochs)
+
+	// The fallback backend moves ahead while the primary one stalls.
+	fallback.notifier.sendEpochs(
+		t, 0, startHeight+2, startHeight+3, startHeight+4,
+	)
+	assertNothing(t, epochEvent.Epochs)
+
+	fallback.chain.setTip(startHeight+4, 0)
+	waitForFallback(t, notifier, true)
+	assertEpoch(startHeight+2, 0)
+	assertEpoch(startHeight+3, 0)
+	assertEpoch(startHeight+4, 0)
+
+	// The primary backend catches up without dispatching the blocks
+	// again.
+	primary.notifier.sendEpochs(
+		t, 0, startHeight+2, startHeight+3, startHeight+4,
+	)
+	primary.chain.setTip(startHeight+4, 0)
+	waitForFallback(t, notifier, false)
+	assertNothing(t, epochEvent.Epochs)
+
+	// A reorg of the active backend is dispatched.
+	primary.notifier.sendEpochs(t, 1, startHeight+4)
+	assertEpoch(startHeight+4, 1)
+}
+
+// TestFailoverDisagreement checks that the notifier reports when the backends
+// disagree on the chain for too long.
+func TestFailoverDisagreement(t *testing.T) {
+	t.Parallel()
+
+	notifier, _, fallback := setUpNotifier(t, nil)
+
+	// The chains are compared at the lower of the two tips, so a fallback
+	// backend that is ahead on the same chain agrees.
+	fallback.chain.setTip(startHeight+1, 0)
+	require.Never(t, notifier.ChainsDisagree, 10*testCheckInterval,
+		testCheckInterval)
+
+	fallback.chain.setTip(startHeight+1, 1)
+	require.Eventually(t, notifier.ChainsDisagree, testTimeout,
+		testCheckInterval)
+
+	// The active backend doesn't change because of the disagreement.
+	require.False(t, notifier.FallbackActive())
+
+	fallback.chain.setTip(startHeight+1, 0)
+	require.Eventually(t, func() bool {
+		return !notifier.ChainsDisagree()
+	}, testTimeout, testCheckInterval)
+}
@@ -0,0 +1,614 @@
+package failovernotify
+
+import (
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+)
+
+// maxEpochHistory is the number of recent blocks of a backend that are kept
+// to bring clients up to date after a failover.
+const maxEpochHistory = 100
+
+// send sends the value on the channel unless the client cancels its
+// registration or the notifier is shutting down first, in which case false is
+// returned.
+func send[T any](c chan<- T, value T, cancel, quit <-chan struct{}) bool {
+	select {
+	case c <- value:
+		return true
+
+	case <-cancel:
+		return false
+
+	case <-quit:
+		return false
+	}
+}
+
+// backendEvent is the event of a registration with the backend with the given
+// index. As a registration is made at most once with every backend, a buffer
+// of two events is enough to never block the registration.
+type backendEvent[T any] struct {
+	index int
+	event *T
+}
+
+// release removes a registration and cancels its events with the backends,
+// including the ones of backends that started since the client stopped
+// receiving them.
+func release[T any](f *FailoverNotifier, id uint64, events *[2]*T,
+	added <-chan backendEvent[T], cancelFn func(*T)) {
+
+	// No more events can be added once the registration is removed.
+	f.unregister(id)
+
+	for {
+		select {
+		case e := <-added:
+			events[e.index] = e.event
+
+		default:
+			for _, e := range events {
+				if e != nil {
+					cancelFn(e)
+				}
+			}
+
+			return
+		}
+	}
+}
+
+// confState is the state of a confirmation request as reported by a single
+// backend.
+type confState struct {
+	// conf is the confirmation the backend reported last, or nil if the
+	// transaction isn't confirmed in its chain.
+	conf *chainntnfs.TxConfirmation
+
+	// reorgDepth is the depth of the reorg the backend reported after the
+	// last confirmation, or zero if there was none.
+	reorgDepth int32
+
+	// done is true once the backend reported that the confirmation is
+	// safe from reorgs.
+	done bool
+}
+
+// forwardConf dispatches the confirmation events of the active backend to the
+// client. The events of the other backend are only recorded, so that they can
+// be dispatched once it becomes active.
+//
+// NOTE: This MUST be run as a goroutine.
+func (f *FailoverNotifier) forwardConf(id uint64,
+	added <-chan backendEvent[chainntnfs.ConfirmationEvent],
+	event *chainntnfs.ConfirmationEvent, cancel <-chan struct{}) {
+
+	var events [2]*chainntnfs.ConfirmationEvent
+
+	defer f.wg.Done()
+	defer release(f, id, &events, added,
+		func(e *chainntnfs.ConfirmationEvent) {
+			e.Cancel()
+		},
+	)
+
+	var (
+		confirmed    [2]chan *chainntnfs.TxConfirmation
+		updates      [2]chan uint32
+		negativeConf [2]chan int32
+		done         [2]chan struct{}
+		states       [2]confState
+		delivered    *chainntnfs.TxConfirmation
+	)
+
+	// closeBackend forgets a backend whose registration was torn down.
+	// Once both are gone, the client's channels are closed as well.
+	closeBackend := func(i int) bool {
+		confirmed[i], updates[i] = nil, nil
+		negativeConf[i], done[i] = nil, nil
+
+		return confirmed[1-i] != nil
+	}
+
+	closeEvent := func() {
+		close(event.Confirmed)
+		close(event.Updates)
+		close(event.NegativeConf)
+		close(event.Done)
+	}
+
+	// attach starts receiving the events of a registration with a
+	// backend. The registrations made before we started are attached
+	// right away.
+	attach := func(e backendEvent[chainntnfs.ConfirmationEvent]) {
+		i := e.index
+		events[i] = e.event
+		confirmed[i] = e.event.Confirmed
+		updates[i] = e.event.Updates
+		negativeConf[i] = e.event.NegativeConf
+		done[i] = e.event.Done
+	}
+	for len(added) > 0 {
+		attach(<-added)
+	}
+
+	active, switched := f.activeBackend()
+	for {
+		select {
+		case e := <-added:
+			attach(e)
+
+		case conf, ok := <-confirmed[0]:
+			if !ok {
+				if !closeBackend(0) {
+					closeEvent()
+					return
+				}
+				continue
+			}
+			states[0].conf, states[0].reorgDepth = conf, 0
+
+		case conf, ok := <-confirmed[1]:
+			if !ok {
+				if !closeBackend(1) {
+					closeEvent()
+					return
+				}
+				continue
+			}
+			states[1].conf, states[1].reorgDepth = conf, 0
+
+		// The other channels of a backend are closed along with the
+		// confirmation channel, which takes care of the cleanup.
+		case numConfsLeft, ok := <-updates[0]:
+			if !ok {
+				updates[0] = nil
+				continue
+			}
+			forwardUpdate(
+				event, active == 0, delivered, numConfsLeft,
+			)
+
+		case numConfsLeft, ok := <-updates[1]:
+			if !ok {
+				updates[1] = nil
+				continue
+			}
+			forwardUpdate(
+				event, active == 1, delivered, numConfsLeft,
+			)
+
+		case depth, ok := <-negativeConf[0]:
+			if !ok {
+				negativeConf[0] = nil
+				continue
+			}
+			states[0].conf, states[0].reorgDepth = nil, depth
+
+		case depth, ok := <-negativeConf[1]:
+			if !ok {
+				negativeConf[1] = nil
+				continue
+			}
+			states[1].conf, states[1].reorgDepth = nil, depth
+
+		case _, ok := <-done[0]:
+			if !ok {
+				done[0] = nil
+				continue
+			}
+			states[0].done = true
+
+		case _, ok := <-done[1]:
+			if !ok {
+				done[1] = nil
+				continue
+			}
+			states[1].done = true
+
+		case <-switched:
+			active, switched = f.activeBackend()
+
+		case <-cancel:
+			closeEvent()
+			return
+
+		case <-f.quit:
+			closeEvent()
+			return
+		}
+
+		// Bring the client up to date with the state of the active
+		// backend.
+		state := states[active]
+		switch {
+		case state.conf != nil && delivered == nil:
+			if !send(event.Confirmed, state.conf, cancel, f.quit) {
+				closeEvent()
+				return
+			}
+			delivered = state.conf
+
+		// A reorg is only dispatched if the active backend saw the
+		// confirmation before. If it didn't, it's likely just lagging
+		// behind the backend that dispatched the confirmation.
+		case state.conf == nil && delivered != nil &&
+			state.reorgDepth > 0:
+
+			depth := state.reorgDepth
+			if !send(event.NegativeConf, depth, cancel, f.quit) {
+				closeEvent()
+				return
+			}
+			delivered = nil
+		}
+
+		if state.done && delivered != nil {
+			send(event.Done, struct{}{}, cancel, f.quit)
+			return
+		}
+	}
+}
+
+// forwardUpdate dispatches the number of confirmations left until a
+// transaction is confirmed if it was reported by the active backend and the
+// confirmation wasn't dispatched yet. Updates are best effort, so they're
+// dropped if the client doesn't read them.
+func forwardUpdate(event *chainntnfs.ConfirmationEvent, active bool,
+	delivered *chainntnfs.TxConfirmation, numConfsLeft uint32) {
+
+	if !active || delivered != nil {
+		return
+	}
+
+	select {
+	case event.Updates <- numConfsLeft:
+	default:
+	}
+}
+
+// spendState is the state of a spend request as reported by a single backend.
+type spendState struct {
+	// spend is the spend the backend reported last, or nil if the output
+	// isn't spent in its chain.
+	spend *chainntnfs.SpendDetail
+
+	// reorged is true if the backend reported a reorg of the spend after
+	// it reported the spend.
+	reorged bool
+
+	// done is true once the backend reported that the spend is safe from
+	// reorgs.
+	done bool
+}
+
+// forwardSpend dispatches the spend events of the active backend to the
+// client. The events of the other backend are only recorded, so that they can
+// be dispatched once it becomes active.
+//
+// NOTE: This MUST be run as a goroutine.
+func (f *FailoverNotifier) forwardSpend(id uint64,
+	added <-chan backendEvent[chainntnfs.SpendEvent],
+	event *chainntnfs.SpendEvent, cancel <-chan struct{}) {
+
+	var events [2]*chainntnfs.SpendEvent
+
+	defer f.wg.Done()
+	defer release(f, id, &events, added, func(e *chainntnfs.SpendEvent) {
+		e.Cancel()
+	})
+
+	var (
+		spend     [2]chan *chainntnfs.SpendDetail
+		reorg     [2]chan struct{}
+		done      [2]chan struct{}
+		states    [2]spendState
+		delivered *chainntnfs.SpendDetail
+	)
+
+	// closeBackend forgets a backend whose registration was torn down.
+	// Once both are gone, the client's channels are closed as well.
+	closeBackend := func(i int) bool {
+		spend[i], reorg[i], done[i] = nil, nil, nil
+
+		return spend[1-i] != nil
+	}
+
+	closeEvent := func() {
+		close(event.Spend)
+		close(event.Reorg)
+		close(event.Done)
+	}
+
+	// attach starts receiving the events of a registration with a
+	// backend. The registrations made before we started are attached
+	// right away.
+	attach := func(e backendEvent[chainntnfs.SpendEvent]) {
+		i := e.index
+		events[i] = e.event
+		spend[i] = e.event.Spend
+		reorg[i] = e.event.Reorg
+		done[i] = e.event.Done
+	}
+	for len(added) > 0 {
+		attach(<-added)
+	}
+
+	active, switched := f.activeBackend()
+	for {
+		select {
+		case e := <-added:
+			attach(e)
+
+		case details, ok := <-spend[0]:
+			if !ok {
+				if !closeBackend(0) {
+					closeEvent()
+					return
+				}
+				continue
+			}
+			states[0].spend, states[0].reorged = details, false
+
+		case details, ok := <-spend[1]:
+			if !ok {
+				if !closeBackend(1) {
+					closeEvent()
+					return
+				}
+				continue
+			}
+			states[1].spend, states[1].reorged = details, false
+
+		// The other channels of a backend are closed along with the
+		// spend channel, which takes care of the cleanup.
+		case _, ok := <-reorg[0]:
+			if !ok {
+				reorg[0] = nil
+				continue
+			}
+			states[0].spend, states[0].reorged = nil, true
+
+		case _, ok := <-reorg[1]:
+			if !ok {
+				reorg[1] = nil
+				continue
+			}
+			states[1].spend, states[1].reorged = nil, true
+
+		case _, ok := <-done[0]:
+			if !ok {
+				done[0] = nil
+				continue
+			}
+			states[0].done = true
+
+		case _, ok := <-done[1]:
+			if !ok {
+				done[1] = nil
+				continue
+			}
+			states[1].done = true
+
+		case <-switched:
+			active, switched = f.activeBackend()
+
+		case <-cancel:
+			closeEvent()
+			return
+
+		case <-f.quit:
+			closeEvent()
+			return
+		}
+
+		// Bring the client up to date with the state of the active
+		// backend.
+		state := states[active]
+		switch {
+		case state.spend != nil && delivered == nil:
+			if !send(event.Spend, state.spend, cancel, f.quit) {
+				closeEvent()
+				return
+			}
+			delivered = state.spend
+
+		// A reorg is only dispatched if the active backend saw the
+		// spend before. If it didn't, it's likely just lagging behind
+		// the backend that dispatched the spend.
+		case state.spend == nil && delivered != nil && state.reorged:
+			if !send(event.Reorg, struct{}{}, cancel, f.quit) {
+				closeEvent()
+				return
+			}
+			delivered = nil
+		}
+
+		if state.done && delivered != nil {
+			send(event.Done, struct{}{}, cancel, f.quit)
+			return
+		}
+	}
+}
+
+// epochHistory keeps the recent blocks of a chain.
+type epochHistory struct {
+	epochs map[int32]*chainntnfs.BlockEpoch
+	tip    *chainntnfs.BlockEpoch
+}
+
+// newEpochHistory returns an empty epoch history.
+func newEpochHistory() *epochHistory {
+	return &epochHistory{
+		epochs: make(map[int32]*chainntnfs.BlockEpoch),
+	}
+}
+
+// add records the new tip of the chain. Blocks above it were reorged out of
+// the chain, and blocks too far below it are forgotten.
+func (h *epochHistory) add(epoch *chainntnfs.BlockEpoch) {
+	if h.tip != nil {
+		for height := h.tip.Height; height > epoch.Height; height-- {
+			delete(h.epochs, height)
+		}
+	}
+
+	h.epochs[epoch.Height] = epoch
+	h.tip = epoch
+
+	for height := range h.epochs {
+		if height <= epoch.Height-maxEpochHistory {
+			delete(h.epochs, height)
+		}
+	}
+}
+
+// hash returns the hash of the block at the given height, or nil if it isn't
+// known.
+func (h *epochHistory) hash(height int32) *chainhash.Hash {
+	epoch, ok := h.epochs[height]
+	if !ok {
+		return nil
+	}
+
+	return epoch.Hash
+}
+
+// lowest returns the height of the lowest known block.
+func (h *epochHistory) lowest() int32 {
+	lowest := int32(-1)
+	for height := range h.epochs {
+		if lowest == -1 || height < lowest {
+			lowest = height
+		}
+	}
+
+	return lowest
+}
+
+// ordered returns the known blocks in ascending order.
+func (h *epochHistory) ordered() []*chainntnfs.BlockEpoch {
+	epochs := make([]*chainntnfs.BlockEpoch, 0, len(h.epochs))
+	for _, epoch := range h.epochs {
+		epochs = append(epochs, epoch)
+	}
+
+	sort.Slice(epochs, func(i, j int) bool {
+		return epochs[i].Height < epochs[j].Height
+	})
+
+	return epochs
+}
+
+// forwardEpochs dispatches the blocks of the active backend to the client. The
+// blocks of the other backend are recorded, so that the client can be brought
+// up to date once it becomes active. Blocks that were already dispatched
+// aren't dispatched again.
+//
+// NOTE: This MUST be run as a goroutine.
+func (f *FailoverNotifier) forwardEpochs(id uint64,
+	added <-chan backendEvent[chainntnfs.BlockEpochEvent],
+	epochs chan *chainntnfs.BlockEpoch, cancel <-chan struct{}) {
+
+	var events [2]*chainntnfs.BlockEpochEvent
+
+	defer f.wg.Done()
+	defer release(f, id, &events, added,
+		func(e *chainntnfs.BlockEpochEvent) {
+			e.Cancel()
+		},
+	)
+
+	var (
+		backendEpochs [2]<-chan *chainntnfs.BlockEpoch
+		histories     = [2]*epochHistory{
+			newEpochHistory(), newEpochHistory(),
+		}
+		delivered = newEpochHistory()
+	)
+
+	// catchUp dispatches the blocks of the chain of the given backend that
+	// the client hasn't seen yet. Blocks below the ones dispatched so far
+	// are skipped, so a backend that lags behind doesn't cause spurious
+	// reorgs.
+	catchUp := func(i int) bool {
+		lowest := delivered.lowest()
+		for _, epoch := range histories[i].ordered() {
+			if lowest != -1 && epoch.Height < lowest {
+				continue
+			}
+
+			hash := delivered.hash(epoch.Height)
+			if hash != nil && *hash == *epoch.Hash {
+				continue
+			}
+
+			if !send(epochs, epoch, cancel, f.quit) {
+				return false
+			}
+			delivered.add(epoch)
+		}
+
+		return true
+	}
+
+	// attach starts receiving the blocks of a registration with a
+	// backend. The registrations made before we started are attached
+	// right away.
+	attach := func(e backendEvent[chainntnfs.BlockEpochEvent]) {
+		events[e.index] = e.event
+		backendEpochs[e.index] = e.event.Epochs
+	}
+	for len(added) > 0 {
+		attach(<-added)
+	}
+
+	active, switched := f.activeBackend()
+	for {
+		select {
+		case e := <-added:
+			attach(e)
+
+		case epoch, ok := <-backendEpochs[0]:
+			if !ok {
+				backendEpochs[0] = nil
+				if backendEpochs[1] == nil {
+					close(epochs)
+					return
+				}
+				continue
+			}
+			histories[0].add(epoch)
+
+		case epoch, ok := <-backendEpochs[1]:
+			if !ok {
+				backendEpochs[1] = nil
+				if backendEpochs[0] == nil {
+					close(epochs)
+					return
+				}
+				continue
+			}
+			histories[1].add(epoch)
+
+		case <-switched:
+			active, switched = f.activeBackend()
+
+		case <-cancel:
+			close(epochs)
+			return
+
+		case <-f.quit:
+			close(epochs)
+			return
+		}
+
+		if !catchUp(active) {
+			close(epochs)
+			return
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package chainreg
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
@@ -21,14 +23,18 @@ import (
 	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/chainntnfs/bitcoindnotify"
 	"github.com/lightningnetwork/lnd/chainntnfs/btcdnotify"
+	"github.com/lightningnetwork/lnd/chainntnfs/electrumnotify"
+	"github.com/lightningnetwork/lnd/chainntnfs/failovernotify"
 	"github.com/lightningnetwork/lnd/chainntnfs/neutrinonotify"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/electrum"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lncfg"
 	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/btcwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing/chainview"
@@ -55,6 +61,16 @@ type Config struct {
 	// BtcdMode defines settings for connecting to a btcd node.
 	BtcdMode *lncfg.Btcd
 
+	// ElectrumMode defines settings for connecting to an Electrum server.
+	ElectrumMode *lncfg.Electrum
+
+	// EsploraMode defines settings for connecting to an Esplora API.
+	EsploraMode *lncfg.Esplora
+
+	// ChainFailover defines settings for a fallback chain backend that is
+	// used while the primary one is down.
+	ChainFailover *lncfg.ChainFailover
+
 	// HeightHintDB is a pointer to the database that stores the height
 	// hints.
 	HeightHintDB kvdb.Backend
@@ -81,10 +97,17 @@ type Config struct {
 	// optional.
 	FeeURL string
 
+	// Fee defines settings for combining several fee estimation sources.
+	Fee *lncfg.Fee
+
 	// Dialer is a function closure that will be used to establish outbound
 	// TCP connections to Bitcoin peers in the event of a pruned block being
 	// requested.
 	Dialer chain.Dialer
+
+	// fallback is true if the configuration is used to create the
+	// fallback chain backend.
+	fallback bool
 }
 
 const (
@@ -161,6 +184,25 @@ type PartialChainControl struct {
 	// notifications for received funds, etc.
 	ChainSource chain.Interface
 
+	// NewChainSource creates an additional chain interface connected to
+	// the same backend as ChainSource. It is used to operate wallets other
+	// than the main one, which each need their own client.
+	NewChainSource func() (chain.Interface, error)
+
+	// NewChainNotifier creates a new chain notifier connected to the same
+	// backend as ChainNotifier. It is used to retry starting a chain
+	// backend of a failover, as a notifier can only be started once.
+	NewChainNotifier func() (chainntnfs.ChainNotifier, error)
+
+	// ChainFailover multiplexes the chain notifiers of the primary and the
+	// fallback chain backend. It is nil if no fallback is configured.
+	ChainFailover *failovernotify.FailoverNotifier
+
+	// FallbackChainSource is the chain interface of the fallback chain
+	// backend, which is used to query the chain while the primary backend
+	// is down. It is nil if no fallback is configured.
+	FallbackChainSource chain.Interface
+
 	// RoutingPolicy is the routing policy we have decided to use.
 	RoutingPolicy models.ForwardingPolicy
 
@@ -198,6 +240,11 @@ type ChainControl struct {
 	// Wallet is our LightningWallet that also contains the abstract Wc
 	// above. This wallet handles all of the lightning operations.
 	Wallet *lnwallet.LightningWallet
+
+	// NamedWallets manages the additional on-chain wallets with their own
+	// seeds and passwords. It is nil if named wallets aren't supported by
+	// the wallet backend.
+	NamedWallets *walletunlocker.NamedWalletManager
 }
 
 // NewPartialChainControl creates a new partial chain control that contains all
@@ -238,6 +285,14 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 			"cache: %v", err)
 	}
 
+	// closeBackend closes the connection to the chain backend if it's
+	// shared between the chain control interfaces.
+	closeBackend := func() {}
+
+	// mempoolEstimator derives fee rates from the mempool of a bitcoind
+	// backend, if it's configured as fee estimation source.
+	var mempoolEstimator chainfee.Estimator
+
 	// If spv mode is active, then we'll be using a distinct set of
 	// chainControl interfaces that interface directly with the p2p network
 	// of the selected chain.
@@ -249,6 +304,14 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 		cc.ChainNotifier = neutrinonotify.New(
 			cfg.NeutrinoCS, hintCache, hintCache, cfg.BlockCache,
 		)
+		cc.NewChainNotifier = func() (chainntnfs.ChainNotifier,
+			error) {
+
+			return neutrinonotify.New(
+				cfg.NeutrinoCS, hintCache, hintCache,
+				cfg.BlockCache,
+			), nil
+		}
 		cc.ChainView, err = chainview.NewCfFilteredChainView(
 			cfg.NeutrinoCS, cfg.BlockCache,
 		)
@@ -271,6 +334,11 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 		cc.ChainSource = chain.NewNeutrinoClient(
 			cfg.ActiveNetParams.Params, cfg.NeutrinoCS,
 		)
+		cc.NewChainSource = func() (chain.Interface, error) {
+			return chain.NewNeutrinoClient(
+				cfg.ActiveNetParams.Params, cfg.NeutrinoCS,
+			), nil
+		}
 
 		// Get our best block as a health check.
 		cc.HealthCheck = func() error {
@@ -363,11 +431,22 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 
 		cc.ChainNotifier = chainNotifier
 		cc.MempoolNotifier = chainNotifier
+		cc.NewChainNotifier = func() (chainntnfs.ChainNotifier,
+			error) {
+
+			return bitcoindnotify.New(
+				bitcoindConn, cfg.ActiveNetParams.Params,
+				hintCache, hintCache, cfg.BlockCache,
+			), nil
+		}
 
 		cc.ChainView = chainview.NewBitcoindFilteredChainView(
 			bitcoindConn, cfg.BlockCache,
 		)
 		cc.ChainSource = bitcoindConn.NewBitcoindClient()
+		cc.NewChainSource = func() (chain.Interface, error) {
+			return bitcoindConn.NewBitcoindClient(), nil
+		}
 
 		// If we're not in regtest mode, then we'll attempt to use a
 		// proper fee estimator for testnet.
@@ -398,6 +477,19 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 			}
 		}
 
+		if cfg.Fee != nil && cfg.Fee.HasSource(lncfg.FeeSourceMempool) {
+			mempoolSource, err := chainfee.NewBitcoindMempoolSource(
+				*rpcConfig,
+			)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			mempoolEstimator = chainfee.NewMempoolEstimator(
+				mempoolSource, cfg.Fee.MempoolRefreshInterval,
+			)
+		}
+
 		// We need to use some apis that are not exposed by btcwallet,
 		// for a health check function so we create an ad-hoc bitcoind
 		// connection.
@@ -572,6 +664,14 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 
 		cc.ChainNotifier = chainNotifier
 		cc.MempoolNotifier = chainNotifier
+		cc.NewChainNotifier = func() (chainntnfs.ChainNotifier,
+			error) {
+
+			return btcdnotify.New(
+				rpcConfig, cfg.ActiveNetParams.Params,
+				hintCache, hintCache, cfg.BlockCache,
+			)
+		}
 
 		// Finally, we'll create an instance of the default chain view
 		// to be used within the routing layer.
@@ -609,6 +709,12 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 		}
 
 		cc.ChainSource = chainRPC
+		cc.NewChainSource = func() (chain.Interface, error) {
+			return chain.NewRPCClient(
+				cfg.ActiveNetParams.Params, btcdHost, btcdUser,
+				btcdPass, rpcCert, false, 20,
+			)
+		}
 
 		// Use a query for our best block as a health check.
 		cc.HealthCheck = func() error {
@@ -634,6 +740,57 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 			}
 		}
 
+	case "electrum":
+		// A pinned certificate implies a TLS connection.
+		tlsCertPath := cfg.ElectrumMode.TLSCertPath
+		useTLS := cfg.ElectrumMode.TLS || tlsCertPath != ""
+
+		backend, err := electrum.NewElectrumClient(
+			&electrum.ElectrumConfig{
+				Server:         cfg.ElectrumMode.Server,
+				TLS:            useTLS,
+				TLSCertPath:    tlsCertPath,
+				RequestTimeout: cfg.ElectrumMode.RequestTimeout,
+				Dialer:         cfg.Dialer,
+			},
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		closeBackend = initElectrumBackend(
+			cc, cfg, backend, hintCache,
+			cfg.ElectrumMode.PollInterval,
+		)
+
+	case "esplora":
+		httpClient := &http.Client{
+			Timeout: cfg.EsploraMode.RequestTimeout,
+		}
+
+		// Route the requests through the configured dialer, so they
+		// use Tor if it's enabled.
+		if cfg.Dialer != nil {
+			httpClient.Transport = &http.Transport{
+				DialContext: func(_ context.Context, _,
+					addr string) (net.Conn, error) {
+
+					return cfg.Dialer(addr)
+				},
+			}
+		}
+
+		backend := electrum.NewEsploraClient(&electrum.EsploraConfig{
+			URL:            cfg.EsploraMode.URL,
+			RequestTimeout: cfg.EsploraMode.RequestTimeout,
+			Client:         httpClient,
+		})
+
+		closeBackend = initElectrumBackend(
+			cc, cfg, backend, hintCache,
+			cfg.EsploraMode.PollInterval,
+		)
+
 	case "nochainbackend":
 		backend := &NoChainBackend{}
 		source := &NoChainSource{
@@ -641,10 +798,20 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 		}
 
 		cc.ChainNotifier = backend
+		cc.NewChainNotifier = func() (chainntnfs.ChainNotifier,
+			error) {
+
+			return backend, nil
+		}
 		cc.ChainView = backend
 		cc.FeeEstimator = backend
 
 		cc.ChainSource = source
+		cc.NewChainSource = func() (chain.Interface, error) {
+			return &NoChainSource{
+				BestBlockTime: source.BestBlockTime,
+			}, nil
+		}
 		cc.HealthCheck = func() error {
 			return nil
 		}
@@ -654,9 +821,35 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 			cfg.Bitcoin.Node)
 	}
 
+	// If a fallback chain backend is configured, the chain notifier fails
+	// over to it while the primary backend is down.
+	if cfg.ChainFailover != nil && cfg.ChainFailover.Active() {
+		closeFallback, err := initChainFailover(cc, cfg)
+		if err != nil {
+			closeBackend()
+			return nil, nil, err
+		}
+
+		closePrimary := closeBackend
+		closeBackend = func() {
+			closeFallback()
+			closePrimary()
+		}
+	}
+
 	cc.BestBlockTracker =
 		chainntnfs.NewBestBlockTracker(cc.ChainNotifier)
 
+	// The fee estimator of the primary chain backend is used, so there's
+	// nothing left to set up for a fallback backend.
+	if cfg.fallback {
+		cc.FeeEstimator = nil
+		return cc, closeBackend, nil
+	}
+
+	backendEstimator := cc.FeeEstimator
+
+	var webAPIEstimator chainfee.Estimator
 	switch {
 	// If the fee URL isn't set, and the user is running mainnet, then
 	// we'll return an error to instruct them to set a proper fee
@@ -676,12 +869,29 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 		log.Infof("Using external fee estimator %v: cached=%v",
 			cfg.FeeURL, cacheFees)
 
-		cc.FeeEstimator = chainfee.NewWebAPIEstimator(
+		webAPIEstimator = chainfee.NewWebAPIEstimator(
 			chainfee.SparseConfFeeSource{
 				URL: cfg.FeeURL,
 			},
 			!cacheFees,
 		)
+		cc.FeeEstimator = webAPIEstimator
+	}
+
+	// If fee estimation sources are configured explicitly, they're
+	// combined into a single estimator.
+	if cfg.Fee != nil && cfg.Fee.Active() {
+		cc.FeeEstimator, err = newCompositeEstimator(
+			cfg.Fee, map[string]chainfee.Estimator{
+				lncfg.FeeSourceBackend: backendEstimator,
+				lncfg.FeeSourceWebAPI:  webAPIEstimator,
+				lncfg.FeeSourceMempool: mempoolEstimator,
+			},
+		)
+		if err != nil {
+			closeBackend()
+			return nil, nil, err
+		}
 	}
 
 	ccCleanup := func() {
@@ -691,16 +901,76 @@ func NewPartialChainControl(cfg *Config) (*PartialChainControl, func(), error) {
 					err)
 			}
 		}
+
+		closeBackend()
 	}
 
 	// Start fee estimator.
 	if err := cc.FeeEstimator.Start(); err != nil {
+		closeBackend()
 		return nil, nil, err
 	}
 
 	return cc, ccCleanup, nil
 }
 
+// initElectrumBackend sets up the chain control interfaces that are backed by
+// an Electrum or Esplora server, which are all polled in the given interval.
+// It returns a function that closes the connection to the server.
+func initElectrumBackend(cc *PartialChainControl, cfg *Config,
+	backend electrum.Backend, hintCache *channeldb.HeightHintCache,
+	pollInterval time.Duration) func() {
+
+	params := cfg.ActiveNetParams.Params
+
+	chainNotifier := electrumnotify.New(
+		backend, params, hintCache, hintCache, pollInterval,
+	)
+	cc.ChainNotifier = chainNotifier
+	cc.MempoolNotifier = chainNotifier
+	cc.NewChainNotifier = func() (chainntnfs.ChainNotifier, error) {
+		return electrumnotify.New(
+			backend, params, hintCache, hintCache, pollInterval,
+		), nil
+	}
+
+	cc.ChainView = chainview.NewElectrumFilteredChainView(
+		backend, pollInterval,
+	)
+
+	cc.ChainSource = electrum.NewChainClient(backend, params, pollInterval)
+	cc.NewChainSource = func() (chain.Interface, error) {
+		return electrum.NewChainClient(
+			backend, params, pollInterval,
+		), nil
+	}
+
+	// Use a query for our best block as a health check.
+	cc.HealthCheck = func() error {
+		_, _, err := backend.BestBlock()
+		return err
+	}
+
+	// If we're not in simnet or regtest mode, then we'll use the fee
+	// estimates of the server.
+	if !cfg.Bitcoin.SimNet && !cfg.Bitcoin.RegTest {
+		log.Infof("Initializing %v backed fee estimator",
+			backend.Name())
+
+		fallBackFeeRate := chainfee.SatPerKVByte(25 * 1000)
+		cc.FeeEstimator = chainfee.NewElectrumEstimator(
+			backend, fallBackFeeRate.FeePerKWeight(),
+		)
+	}
+
+	return func() {
+		if err := backend.Close(); err != nil {
+			log.Errorf("Unable to close %v backend: %v",
+				backend.Name(), err)
+		}
+	}
+}
+
 // NewChainControl attempts to create a ChainControl instance according
 // to the parameters in the passed configuration. Currently three
 // branches of ChainControl instances exist: one backed by a running btcd
@@ -711,6 +981,16 @@ func NewChainControl(walletConfig lnwallet.Config,
 	msgSigner lnwallet.MessageSigner,
 	pcc *PartialChainControl) (*ChainControl, func(), error) {
 
+	// Queries of the chain fail over to the fallback chain backend along
+	// with the chain notifier.
+	if pcc.ChainFailover != nil {
+		walletConfig.ChainIO = newFailoverChainIO(
+			walletConfig.ChainIO, btcwallet.NewBlockChainIO(
+				pcc.FallbackChainSource, pcc.Cfg.BlockCache,
+			), pcc.ChainFailover,
+		)
+	}
+
 	cc := &ChainControl{
 		PartialChainControl: pcc,
 		MsgSigner:           msgSigner,
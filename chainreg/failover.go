@@ -0,0 +1,246 @@
+package chainreg
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/lightningnetwork/lnd/chainntnfs/failovernotify"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/btcwallet"
+)
+
+// initChainFailover creates the fallback chain backend and replaces the chain
+// notifier and the health check of the partial chain control with ones that
+// fail over to the fallback backend while the primary one is down. It returns
+// a function that closes the fallback backend.
+func initChainFailover(cc *PartialChainControl, cfg *Config) (func(),
+	error) {
+
+	failoverCfg := cfg.ChainFailover
+
+	// The fallback backend is created from the same configuration as the
+	// primary one, except for the node type and the bitcoind section,
+	// which has its own copy so that a second bitcoind can be used.
+	bitcoinCfg := *cfg.Bitcoin
+	bitcoinCfg.Node = failoverCfg.Node
+
+	fallbackCfg := *cfg
+	fallbackCfg.Bitcoin = &bitcoinCfg
+	fallbackCfg.ChainFailover = nil
+	fallbackCfg.Fee = nil
+	fallbackCfg.fallback = true
+	if failoverCfg.Node == "bitcoind" {
+		fallbackCfg.BitcoindMode = failoverCfg.Bitcoind
+	}
+
+	log.Infof("Initializing fallback chain backend %v", failoverCfg.Node)
+
+	fallback, cleanUp, err := NewPartialChainControl(&fallbackCfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize fallback chain "+
+			"backend: %w", err)
+	}
+
+	// The chain source of the fallback backend is only used to query the
+	// chain. Of all clients, only the btcd one needs to be started for
+	// that, as it has to connect first.
+	if _, ok := fallback.ChainSource.(*chain.RPCClient); ok {
+		if err := fallback.ChainSource.Start(); err != nil {
+			cleanUp()
+			return nil, fmt.Errorf("unable to start fallback "+
+				"chain source: %w", err)
+		}
+	}
+
+	notifier := failovernotify.New(&failovernotify.Config{
+		Primary: &failovernotify.Backend{
+			Name:        cfg.Bitcoin.Node + " (primary)",
+			Notifier:    cc.ChainNotifier,
+			NewNotifier: cc.NewChainNotifier,
+			HealthCheck: cc.HealthCheck,
+			Chain:       cc.ChainSource,
+		},
+		Fallback: &failovernotify.Backend{
+			Name:        failoverCfg.Node + " (fallback)",
+			Notifier:    fallback.ChainNotifier,
+			NewNotifier: fallback.NewChainNotifier,
+			HealthCheck: fallback.HealthCheck,
+			Chain:       fallback.ChainSource,
+		},
+		CheckInterval:         failoverCfg.CheckInterval,
+		FailureThreshold:      failoverCfg.FailureThreshold,
+		MaxBlockLag:           failoverCfg.MaxBlockLag,
+		DisagreementThreshold: failoverCfg.DisagreementThreshold,
+	})
+
+	// The chain backend is only considered to be down once neither the
+	// primary nor the fallback backend can be reached.
+	primaryHealthCheck := cc.HealthCheck
+	cc.HealthCheck = func() error {
+		primaryErr := primaryHealthCheck()
+		if primaryErr == nil {
+			return nil
+		}
+
+		if err := fallback.HealthCheck(); err != nil {
+			return fmt.Errorf("primary chain backend: %v, "+
+				"fallback chain backend: %w", primaryErr, err)
+		}
+
+		return nil
+	}
+
+	cc.ChainNotifier = notifier
+	cc.ChainFailover = notifier
+	cc.FallbackChainSource = fallback.ChainSource
+
+	return func() {
+		if _, ok := fallback.ChainSource.(*chain.RPCClient); ok {
+			fallback.ChainSource.Stop()
+		}
+
+		cleanUp()
+	}, nil
+}
+
+// failoverChainIO is a BlockChainIO that queries the chain through the backend
+// the chain notifier currently dispatches notifications from, and retries
+// with the other backend if the query fails.
+type failoverChainIO struct {
+	primary  lnwallet.BlockChainIO
+	fallback lnwallet.BlockChainIO
+	failover *failovernotify.FailoverNotifier
+}
+
+// A compile time check to ensure that failoverChainIO implements the
+// BlockChainIO interface.
+var _ lnwallet.BlockChainIO = (*failoverChainIO)(nil)
+
+// newFailoverChainIO creates a BlockChainIO that fails over to the fallback
+// chain backend along with the given notifier.
+func newFailoverChainIO(primary, fallback lnwallet.BlockChainIO,
+	failover *failovernotify.FailoverNotifier) *failoverChainIO {
+
+	return &failoverChainIO{
+		primary:  primary,
+		fallback: fallback,
+		failover: failover,
+	}
+}
+
+// backends returns the active backend followed by the other one.
+func (f *failoverChainIO) backends() [2]lnwallet.BlockChainIO {
+	if f.failover.FallbackActive() {
+		return [2]lnwallet.BlockChainIO{f.fallback, f.primary}
+	}
+
+	return [2]lnwallet.BlockChainIO{f.primary, f.fallback}
+}
+
+// GetBestBlock returns the current height and hash of the best known block
+// within the main chain.
+//
+// NOTE: This is part of the lnwallet.BlockChainIO interface.
+func (f *failoverChainIO) GetBestBlock() (*chainhash.Hash, int32, error) {
+	var (
+		hash   *chainhash.Hash
+		height int32
+		err    error
+	)
+	for _, backend := range f.backends() {
+		hash, height, err = backend.GetBestBlock()
+		if err == nil {
+			break
+		}
+	}
+
+	return hash, height, err
+}
+
+// GetUtxo attempts to return the passed outpoint if it's still a member of the
+// utxo set. An output that is spent or can't be found is a definite answer, so
+// the other backend is only asked if the query fails otherwise.
+//
+// NOTE: This is part of the lnwallet.BlockChainIO interface.
+func (f *failoverChainIO) GetUtxo(op *wire.OutPoint, pkScript []byte,
+	heightHint uint32, cancel <-chan struct{}) (*wire.TxOut, error) {
+
+	var (
+		txOut *wire.TxOut
+		err   error
+	)
+	for _, backend := range f.backends() {
+		txOut, err = backend.GetUtxo(op, pkScript, heightHint, cancel)
+		if err == nil || errors.Is(err, btcwallet.ErrOutputSpent) ||
+			errors.Is(err, btcwallet.ErrOutputNotFound) {
+
+			break
+		}
+	}
+
+	return txOut, err
+}
+
+// GetBlockHash returns the hash of the block in the best blockchain at the
+// given height.
+//
+// NOTE: This is part of the lnwallet.BlockChainIO interface.
+func (f *failoverChainIO) GetBlockHash(blockHeight int64) (*chainhash.Hash,
+	error) {
+
+	var (
+		hash *chainhash.Hash
+		err  error
+	)
+	for _, backend := range f.backends() {
+		hash, err = backend.GetBlockHash(blockHeight)
+		if err == nil {
+			break
+		}
+	}
+
+	return hash, err
+}
+
+// GetBlock returns the block in the main chain identified by the given hash.
+//
+// NOTE: This is part of the lnwallet.BlockChainIO interface.
+func (f *failoverChainIO) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock,
+	error) {
+
+	var (
+		block *wire.MsgBlock
+		err   error
+	)
+	for _, backend := range f.backends() {
+		block, err = backend.GetBlock(blockHash)
+		if err == nil {
+			break
+		}
+	}
+
+	return block, err
+}
+
+// GetBlockHeader returns the block header for the given block hash.
+//
+// NOTE: This is part of the lnwallet.BlockChainIO interface.
+func (f *failoverChainIO) GetBlockHeader(
+	blockHash *chainhash.Hash) (*wire.BlockHeader, error) {
+
+	var (
+		header *wire.BlockHeader
+		err    error
+	)
+	for _, backend := range f.backends() {
+		header, err = backend.GetBlockHeader(blockHash)
+		if err == nil {
+			break
+		}
+	}
+
+	return header, err
+}
@@ -0,0 +1,45 @@
+package chainreg
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/lncfg"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+// newCompositeEstimator combines the fee estimation sources configured in cfg
+// into a single estimator. The given map holds the estimator of each source,
+// which is nil if the source isn't available.
+func newCompositeEstimator(cfg *lncfg.Fee,
+	sources map[string]chainfee.Estimator) (*chainfee.CompositeEstimator,
+	error) {
+
+	mode, err := chainfee.ParseCombineMode(cfg.Combine)
+	if err != nil {
+		return nil, err
+	}
+
+	namedSources := make([]chainfee.NamedEstimator, 0, len(cfg.Sources))
+	for _, name := range cfg.Sources {
+		estimator := sources[name]
+		if estimator == nil {
+			return nil, fmt.Errorf("fee estimation source %v is "+
+				"not available", name)
+		}
+
+		namedSources = append(namedSources, chainfee.NamedEstimator{
+			Name:      name,
+			Estimator: estimator,
+		})
+	}
+
+	log.Infof("Combining fee estimation sources %v by %v", cfg.Sources,
+		mode)
+
+	return chainfee.NewCompositeEstimator(chainfee.CompositeConfig{
+		Sources:       namedSources,
+		Mode:          mode,
+		SourceTimeout: cfg.SourceTimeout,
+		MaxStaleness:  cfg.MaxStaleness,
+	})
+}
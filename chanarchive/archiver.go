@@ -0,0 +1,232 @@
+package chanarchive
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/ticker"
+)
+
+const (
+	// DefaultSafetyDepth is the default number of blocks that must have
+	// been mined on top of the closing transaction of a fully resolved
+	// channel before its state is archived or deleted.
+	DefaultSafetyDepth = 2016
+
+	// DefaultInterval is the default interval at which we check for
+	// closed channels that can be archived or deleted.
+	DefaultInterval = 6 * time.Hour
+)
+
+// Mode determines what happens to the state of closed channels.
+type Mode uint8
+
+const (
+	// ModeArchive writes the state of closed channels to a compressed
+	// archive file before removing it from the database.
+	ModeArchive Mode = iota
+
+	// ModeDelete removes the state of closed channels from the database
+	// without keeping a copy.
+	ModeDelete
+)
+
+// String returns a human readable name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeArchive:
+		return "archive"
+
+	case ModeDelete:
+		return "delete"
+
+	default:
+		return fmt.Sprintf("unknown mode %d", m)
+	}
+}
+
+// ChannelSource is the database that holds the state of closed channels.
+type ChannelSource interface {
+	ChannelWriter
+
+	// FetchArchivableChannels returns the close summaries of all channels
+	// that are fully closed at or below the given height and whose
+	// historical state hasn't been pruned yet.
+	FetchArchivableChannels(maxCloseHeight uint32) (
+		[]*channeldb.ChannelCloseSummary, error)
+
+	// PruneHistoricalChannel removes the commitments, HTLCs, revocation
+	// state and forwarding packages of a fully closed channel, keeping
+	// only its static info and final balances.
+	PruneHistoricalChannel(chanPoint *wire.OutPoint) error
+}
+
+// Config holds the configuration of the Archiver.
+type Config struct {
+	// DB is the database that holds the state of closed channels.
+	DB ChannelSource
+
+	// Mode determines whether the state is archived or just deleted.
+	Mode Mode
+
+	// Dir is the directory the archive files are written to. It is only
+	// used in ModeArchive.
+	Dir string
+
+	// SafetyDepth is the number of blocks that must have been mined on top
+	// of the closing transaction before the state of a channel is removed.
+	SafetyDepth uint32
+
+	// BestHeight returns the height of the current best block.
+	BestHeight func() (uint32, error)
+
+	// Ticker determines how often we check for closed channels that can be
+	// removed.
+	Ticker ticker.Ticker
+
+	// Clock is used to timestamp archive files.
+	Clock clock.Clock
+}
+
+// Archiver periodically archives or deletes the state of closed channels
+// once they are fully resolved and buried deep enough in the chain. The close
+// summary, the static info and the final balances of the channels are kept in
+// the database, so they can still be reported once the rest of their state is
+// gone.
+type Archiver struct {
+	started sync.Once
+	stopped sync.Once
+
+	cfg *Config
+
+	// pruneMtx makes sure only one pruning run is active at a time.
+	pruneMtx sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a new Archiver from the given config.
+func New(cfg *Config) *Archiver {
+	return &Archiver{
+		cfg:  cfg,
+		quit: make(chan struct{}),
+	}
+}
+
+// Start launches the goroutine that periodically checks for closed channels
+// that can be removed.
+func (a *Archiver) Start() error {
+	a.started.Do(func() {
+		log.Infof("Channel archiver starting, mode=%v, safety_depth=%v",
+			a.cfg.Mode, a.cfg.SafetyDepth)
+
+		a.cfg.Ticker.Resume()
+
+		a.wg.Add(1)
+		go a.pruneLoop()
+	})
+
+	return nil
+}
+
+// Stop signals the archiver to shut down and waits for it to finish.
+func (a *Archiver) Stop() error {
+	a.stopped.Do(func() {
+		log.Info("Channel archiver shutting down...")
+		defer log.Debug("Channel archiver shutdown complete")
+
+		close(a.quit)
+		a.wg.Wait()
+
+		a.cfg.Ticker.Stop()
+	})
+
+	return nil
+}
+
+// pruneLoop removes the state of closed channels right away and then every
+// time the ticker fires.
+//
+// NOTE: This MUST be run as a goroutine.
+func (a *Archiver) pruneLoop() {
+	defer a.wg.Done()
+
+	for {
+		if _, err := a.Prune(); err != nil {
+			log.Errorf("Unable to prune closed channels: %v", err)
+		}
+
+		select {
+		case <-a.cfg.Ticker.Ticks():
+
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// Prune archives or deletes the state of all closed channels that are fully
+// resolved and buried at least SafetyDepth blocks deep. The channel points of
+// the removed channels are returned.
+func (a *Archiver) Prune() ([]wire.OutPoint, error) {
+	a.pruneMtx.Lock()
+	defer a.pruneMtx.Unlock()
+
+	bestHeight, err := a.cfg.BestHeight()
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch best height: %w", err)
+	}
+
+	if bestHeight < a.cfg.SafetyDepth {
+		return nil, nil
+	}
+	maxCloseHeight := bestHeight - a.cfg.SafetyDepth
+
+	summaries, err := a.cfg.DB.FetchArchivableChannels(maxCloseHeight)
+	if err != nil {
+		return nil, err
+	}
+	if len(summaries) == 0 {
+		return nil, nil
+	}
+
+	chanPoints := make([]wire.OutPoint, 0, len(summaries))
+	for _, summary := range summaries {
+		chanPoints = append(chanPoints, summary.ChanPoint)
+	}
+
+	// The state is only removed from the database once the archive file
+	// is safely stored on disk. If we're interrupted before all channels
+	// are removed, they'll be archived again in the next run.
+	if a.cfg.Mode == ModeArchive {
+		fileName, err := writeArchiveFile(
+			a.cfg.Dir, a.cfg.Clock.Now(), a.cfg.DB, chanPoints,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Infof("Archived state of %d closed channels to %v",
+			len(chanPoints), fileName)
+	}
+
+	for i := range chanPoints {
+		err := a.cfg.DB.PruneHistoricalChannel(&chanPoints[i])
+		if err != nil {
+			return nil, fmt.Errorf("unable to prune state of "+
+				"channel %v: %w", chanPoints[i], err)
+		}
+
+		log.Debugf("Removed state of closed channel %v", chanPoints[i])
+	}
+
+	log.Infof("Removed state of %d closed channels from the database",
+		len(chanPoints))
+
+	return chanPoints, nil
+}
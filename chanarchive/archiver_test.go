@@ -0,0 +1,222 @@
+package chanarchive
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/ticker"
+	"github.com/stretchr/testify/require"
+)
+
+// testContext holds a database with closed channels and an archiver for it.
+type testContext struct {
+	t *testing.T
+
+	db         *channeldb.ChannelStateDB
+	bestHeight uint32
+	clock      *clock.TestClock
+	archiver   *Archiver
+}
+
+// newTestContext creates a new test context with an empty database.
+func newTestContext(t *testing.T, mode Mode) *testContext {
+	fullDB, err := channeldb.MakeTestDB(t)
+	require.NoError(t, err)
+
+	ctx := &testContext{
+		t:     t,
+		db:    fullDB.ChannelStateDB(),
+		clock: clock.NewTestClock(time.Unix(1700000000, 0)),
+	}
+	ctx.archiver = New(&Config{
+		DB:          ctx.db,
+		Mode:        mode,
+		Dir:         t.TempDir(),
+		SafetyDepth: 100,
+		BestHeight: func() (uint32, error) {
+			return ctx.bestHeight, nil
+		},
+		Ticker: ticker.NewForce(time.Hour),
+		Clock:  ctx.clock,
+	})
+
+	return ctx
+}
+
+// closeChannel adds a channel to the database that was closed at the given
+// height, and marks it as fully resolved if requested.
+func (c *testContext) closeChannel(closeHeight uint32,
+	resolved bool) wire.OutPoint {
+
+	lChannel, _, err := lnwallet.CreateTestChannels(
+		c.t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(c.t, err)
+
+	channel := lChannel.State()
+	channel.Db = c.db
+
+	addr := &net.TCPAddr{
+		IP:   net.ParseIP("127.0.0.1"),
+		Port: 18556,
+	}
+	require.NoError(c.t, channel.SyncPending(addr, 101))
+
+	require.NoError(c.t, channel.CloseChannel(&channeldb.ChannelCloseSummary{
+		ChanPoint:   channel.FundingOutpoint,
+		RemotePub:   channel.IdentityPub,
+		Capacity:    channel.Capacity,
+		CloseHeight: closeHeight,
+		CloseType:   channeldb.RemoteForceClose,
+		IsPending:   true,
+	}))
+
+	if resolved {
+		err := c.db.MarkChanFullyClosed(&channel.FundingOutpoint)
+		require.NoError(c.t, err)
+	}
+
+	return channel.FundingOutpoint
+}
+
+// requireRemoved asserts that the state of the given channel was pruned from
+// the database, while its summary is still available.
+func (c *testContext) requireRemoved(chanPoint wire.OutPoint) {
+	channel, err := c.db.FetchHistoricalChannel(&chanPoint)
+	require.NoError(c.t, err)
+	require.True(c.t, channel.HasChanStatus(channeldb.ChanStatusPruned))
+	require.Nil(c.t, channel.LocalCommitment.CommitTx)
+	require.Empty(c.t, channel.LocalCommitment.Htlcs)
+
+	// The close summary must still be there.
+	_, err = c.db.FetchClosedChannel(&chanPoint)
+	require.NoError(c.t, err)
+}
+
+// archivedChanPoints returns the channel points of all archived channels.
+func (c *testContext) archivedChanPoints() []wire.OutPoint {
+	channels, err := ReadArchive(c.archiver.cfg.Dir)
+	require.NoError(c.t, err)
+
+	chanPoints := make([]wire.OutPoint, 0, len(channels))
+	for _, channel := range channels {
+		require.Equal(
+			c.t, channel.Summary.ChanPoint,
+			channel.Channel.FundingOutpoint,
+		)
+		chanPoints = append(chanPoints, channel.Summary.ChanPoint)
+	}
+
+	return chanPoints
+}
+
+// TestPruneArchive tests that fully resolved channels are archived once they
+// are buried deep enough.
+func TestPruneArchive(t *testing.T) {
+	t.Parallel()
+
+	ctx := newTestContext(t, ModeArchive)
+
+	// Below the safety depth, nothing can be removed.
+	ctx.bestHeight = 50
+	removed, err := ctx.archiver.Prune()
+	require.NoError(t, err)
+	require.Empty(t, removed)
+
+	chanA := ctx.closeChannel(100, true)
+	chanB := ctx.closeChannel(100, false)
+	chanC := ctx.closeChannel(150, true)
+
+	// Only the first channel is resolved and deep enough.
+	ctx.bestHeight = 220
+	removed, err = ctx.archiver.Prune()
+	require.NoError(t, err)
+	require.Equal(t, []wire.OutPoint{chanA}, removed)
+	ctx.requireRemoved(chanA)
+	require.Equal(t, []wire.OutPoint{chanA}, ctx.archivedChanPoints())
+
+	// The unresolved channel is kept, even once it's deep enough.
+	ctx.bestHeight = 300
+	ctx.clock.SetTime(ctx.clock.Now().Add(time.Second))
+	removed, err = ctx.archiver.Prune()
+	require.NoError(t, err)
+	require.Equal(t, []wire.OutPoint{chanC}, removed)
+	ctx.requireRemoved(chanC)
+
+	_, err = ctx.db.FetchHistoricalChannel(&chanB)
+	require.NoError(t, err)
+
+	files, err := ListArchiveFiles(ctx.archiver.cfg.Dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(
+		t, []wire.OutPoint{chanA, chanC}, ctx.archivedChanPoints(),
+	)
+
+	// A channel that was archived twice because we were interrupted
+	// before its state was removed is only returned once.
+	ctx.clock.SetTime(ctx.clock.Now().Add(time.Second))
+	_, err = writeArchiveFile(
+		ctx.archiver.cfg.Dir, ctx.clock.Now(), ctx.db,
+		[]wire.OutPoint{chanB},
+	)
+	require.NoError(t, err)
+	ctx.clock.SetTime(ctx.clock.Now().Add(time.Second))
+	_, err = writeArchiveFile(
+		ctx.archiver.cfg.Dir, ctx.clock.Now(), ctx.db,
+		[]wire.OutPoint{chanB},
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t, []wire.OutPoint{chanA, chanC, chanB},
+		ctx.archivedChanPoints(),
+	)
+}
+
+// TestPruneDelete tests that no archive is written in delete mode.
+func TestPruneDelete(t *testing.T) {
+	t.Parallel()
+
+	ctx := newTestContext(t, ModeDelete)
+
+	chanPoint := ctx.closeChannel(100, true)
+
+	ctx.bestHeight = 200
+	removed, err := ctx.archiver.Prune()
+	require.NoError(t, err)
+	require.Equal(t, []wire.OutPoint{chanPoint}, removed)
+	ctx.requireRemoved(chanPoint)
+
+	files, err := ListArchiveFiles(ctx.archiver.cfg.Dir)
+	require.NoError(t, err)
+	require.Empty(t, files)
+}
+
+// TestArchiverTicker tests that the archiver prunes channels periodically.
+func TestArchiverTicker(t *testing.T) {
+	t.Parallel()
+
+	ctx := newTestContext(t, ModeArchive)
+	ctx.bestHeight = 200
+
+	require.NoError(t, ctx.archiver.Start())
+	t.Cleanup(func() {
+		require.NoError(t, ctx.archiver.Stop())
+	})
+
+	chanPoint := ctx.closeChannel(100, true)
+
+	forceTicker := ctx.archiver.cfg.Ticker.(*ticker.Force)
+	forceTicker.Force <- time.Now()
+
+	require.Eventually(t, func() bool {
+		channel, err := ctx.db.FetchHistoricalChannel(&chanPoint)
+		return err == nil &&
+			channel.HasChanStatus(channeldb.ChanStatusPruned)
+	}, 5*time.Second, 10*time.Millisecond)
+}
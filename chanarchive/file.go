@@ -0,0 +1,197 @@
+package chanarchive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+)
+
+const (
+	// archiveFilePrefix is the prefix of the name of all archive files.
+	archiveFilePrefix = "closed-channels-"
+
+	// archiveFileSuffix is the suffix of the name of all archive files.
+	archiveFileSuffix = ".gz"
+
+	// archiveTimeFormat is the time layout used to encode the creation
+	// time of an archive file into its name. The layout sorts
+	// lexicographically in chronological order.
+	archiveTimeFormat = "20060102-150405.000000000"
+
+	// archiveTempFileName is the name of the temporary file that is used
+	// to atomically create a new archive file.
+	archiveTempFileName = "temp-dont-use.gz"
+)
+
+// archiveFileName returns the name of an archive file created at the given
+// time.
+func archiveFileName(t time.Time) string {
+	return archiveFilePrefix + t.UTC().Format(archiveTimeFormat) +
+		archiveFileSuffix
+}
+
+// ChannelWriter writes the archived state of a closed channel.
+type ChannelWriter interface {
+	// WriteArchivedChannel writes the close summary and the historical
+	// state of the given closed channel to w.
+	WriteArchivedChannel(w io.Writer, chanPoint *wire.OutPoint) error
+}
+
+// writeArchiveFile writes the given channels to a new, compressed archive file
+// in dir. The file only shows up under its final name once it was completely
+// written and synced to disk.
+func writeArchiveFile(dir string, t time.Time, db ChannelWriter,
+	chanPoints []wire.OutPoint) (string, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("unable to create archive directory: %w",
+			err)
+	}
+
+	fileName := filepath.Join(dir, archiveFileName(t))
+	tempFileName := filepath.Join(dir, archiveTempFileName)
+
+	tempFile, err := os.OpenFile(
+		tempFileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600,
+	)
+	if err != nil {
+		return "", fmt.Errorf("unable to create temp file: %w", err)
+	}
+	defer os.Remove(tempFileName)
+
+	zw := gzip.NewWriter(tempFile)
+	for i := range chanPoints {
+		err := db.WriteArchivedChannel(zw, &chanPoints[i])
+		if err != nil {
+			_ = tempFile.Close()
+
+			return "", fmt.Errorf("unable to archive channel "+
+				"%v: %w", chanPoints[i], err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		_ = tempFile.Close()
+
+		return "", fmt.Errorf("unable to compress archive: %w", err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+
+		return "", fmt.Errorf("unable to sync temp file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return "", fmt.Errorf("unable to close file: %w", err)
+	}
+
+	if err := os.Rename(tempFileName, fileName); err != nil {
+		return "", fmt.Errorf("unable to rename temp file: %w", err)
+	}
+
+	return fileName, nil
+}
+
+// ListArchiveFiles returns the paths of all archive files in the given
+// directory, oldest first. A directory that doesn't exist is treated as an
+// empty archive.
+func ListArchiveFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() ||
+			!strings.HasPrefix(name, archiveFilePrefix) ||
+			!strings.HasSuffix(name, archiveFileSuffix) {
+
+			continue
+		}
+
+		files = append(files, filepath.Join(dir, name))
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// ReadArchiveFile reads all channels stored in the given archive file.
+func ReadArchiveFile(path string) ([]*channeldb.ArchivedChannel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read archive %v: %w", path,
+			err)
+	}
+	defer zr.Close()
+
+	var channels []*channeldb.ArchivedChannel
+	for {
+		channel, err := channeldb.ReadArchivedChannel(zr)
+		switch {
+		case errors.Is(err, io.EOF):
+			return channels, nil
+
+		case err != nil:
+			return nil, fmt.Errorf("unable to read archive %v: %w",
+				path, err)
+		}
+
+		channels = append(channels, channel)
+	}
+}
+
+// ReadArchive reads all channels stored in the archive files in the given
+// directory. If a channel was archived more than once, for example because
+// lnd was shut down before its state was removed from the database, only the
+// most recent copy is returned.
+func ReadArchive(dir string) ([]*channeldb.ArchivedChannel, error) {
+	files, err := ListArchiveFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		channels []*channeldb.ArchivedChannel
+		index    = make(map[wire.OutPoint]int)
+	)
+	for _, file := range files {
+		fileChannels, err := ReadArchiveFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, channel := range fileChannels {
+			chanPoint := channel.Summary.ChanPoint
+			if i, ok := index[chanPoint]; ok {
+				channels[i] = channel
+				continue
+			}
+
+			index[chanPoint] = len(channels)
+			channels = append(channels, channel)
+		}
+	}
+
+	return channels, nil
+}
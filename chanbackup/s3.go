@@ -0,0 +1,484 @@
+package chanbackup
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/keychain"
+)
+
+const (
+	// DefaultS3Region is the region used to sign requests if none is
+	// configured. Most S3-compatible servers accept any region.
+	DefaultS3Region = "us-east-1"
+
+	// DefaultS3Timeout is the default timeout of a single request to the
+	// S3 endpoint.
+	DefaultS3Timeout = 30 * time.Second
+
+	// s3Service is the service name used in the signature scope.
+	s3Service = "s3"
+
+	// s3Algorithm is the signature algorithm we use to sign requests.
+	s3Algorithm = "AWS4-HMAC-SHA256"
+
+	// s3DateFormat is the layout of the x-amz-date header.
+	s3DateFormat = "20060102T150405Z"
+
+	// s3ShortDateFormat is the layout of the date in the signature scope.
+	s3ShortDateFormat = "20060102"
+
+	// s3MaxErrorBodySize is the maximum number of bytes of an error
+	// response that we include in the returned error.
+	s3MaxErrorBodySize = 512
+)
+
+// S3Config houses the parameters of an S3Sink.
+type S3Config struct {
+	// Endpoint is the base URL of the S3-compatible server, e.g.
+	// https://s3.us-east-1.amazonaws.com or http://127.0.0.1:9000.
+	// Objects are addressed path-style below this URL.
+	Endpoint string
+
+	// Bucket is the name of the bucket the backups are stored in.
+	Bucket string
+
+	// Prefix is an optional key prefix for the backup objects, e.g.
+	// "mynode/".
+	Prefix string
+
+	// Region is the region used to sign requests.
+	Region string
+
+	// AccessKeyID is the access key used to sign requests.
+	AccessKeyID string
+
+	// SecretAccessKey is the secret key used to sign requests.
+	SecretAccessKey string
+
+	// MaxVersions is the number of backup versions that are kept in the
+	// bucket. Older versions are deleted after each update.
+	MaxVersions int
+
+	// Timeout is the timeout of a single request.
+	Timeout time.Duration
+
+	// Clock is used to timestamp new versions and to sign requests.
+	Clock clock.Clock
+}
+
+// S3Sink is a backup sink that uploads each new multi backup as a separate,
+// timestamped object to an S3-compatible object store. Requests are signed
+// using AWS signature version 4.
+type S3Sink struct {
+	cfg *S3Config
+
+	// endpoint is the parsed endpoint URL.
+	endpoint *url.URL
+
+	client *http.Client
+
+	// ctx is cancelled once the sink is stopped, aborting any request in
+	// progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// A compile-time check to ensure that S3Sink implements the VersionedSink
+// and StoppableSink interfaces.
+var (
+	_ VersionedSink = (*S3Sink)(nil)
+	_ StoppableSink = (*S3Sink)(nil)
+)
+
+// NewS3Sink creates a new S3 backup sink from the given config.
+func NewS3Sink(cfg *S3Config) (*S3Sink, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid S3 endpoint scheme: %v",
+			endpoint.Scheme)
+	}
+
+	switch {
+	case cfg.Bucket == "":
+		return nil, fmt.Errorf("S3 bucket must be set")
+
+	case cfg.MaxVersions < 1:
+		return nil, fmt.Errorf("at least one backup version must be "+
+			"kept, got %d", cfg.MaxVersions)
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = DefaultS3Region
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultS3Timeout
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewDefaultClock()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &S3Sink{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Name returns the name of the sink.
+//
+// NOTE: This is part of the Sink interface.
+func (s *S3Sink) Name() string {
+	return "s3:" + s.cfg.Bucket + "/" + s.cfg.Prefix
+}
+
+// Stop aborts any request in progress, and makes all further requests fail
+// right away.
+//
+// NOTE: This is part of the StoppableSink interface.
+func (s *S3Sink) Stop() {
+	s.cancel()
+}
+
+// objectURL returns the path-style URL of the object with the given key.
+// Query parameters can optionally be passed.
+func (s *S3Sink) objectURL(key string, query url.Values) *url.URL {
+	u := *s.endpoint
+	u.Path = path.Join("/", u.Path, s.cfg.Bucket, key)
+	if key == "" {
+		// path.Join strips the trailing slash, which S3 expects for
+		// bucket level requests.
+		u.Path += "/"
+	}
+	u.RawQuery = query.Encode()
+
+	return &u
+}
+
+// do signs and executes a request against the S3 endpoint. The response body
+// is returned if the server responded with a 2xx status code.
+func (s *S3Sink) do(method string, u *url.URL, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(
+		s.ctx, method, u.String(), bytes.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	signS3Request(
+		req, body, s.cfg.AccessKeyID, s.cfg.SecretAccessKey,
+		s.cfg.Region, s.cfg.Clock.Now(),
+	)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// The error includes the request URL, which we redact in case
+		// the endpoint carries any credentials.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(req.URL)
+		}
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(respBody) > s3MaxErrorBodySize {
+			respBody = respBody[:s3MaxErrorBodySize]
+		}
+
+		return nil, fmt.Errorf("%v %v failed with status %v: %s",
+			method, u.Path, resp.Status, respBody)
+	}
+
+	return respBody, nil
+}
+
+// UpdateAndSwap uploads the new backup as a new object, and then deletes the
+// oldest versions that exceed the configured limit.
+//
+// NOTE: This is part of the Swapper interface.
+func (s *S3Sink) UpdateAndSwap(newBackup PackedMulti) error {
+	key := s.cfg.Prefix + versionName(s.cfg.Clock.Now())
+
+	log.Infof("Uploading backup version %v to S3 bucket %v", key,
+		s.cfg.Bucket)
+
+	_, err := s.do(http.MethodPut, s.objectURL(key, nil), newBackup)
+	if err != nil {
+		return fmt.Errorf("unable to upload backup: %w", err)
+	}
+
+	versions, err := s.ListVersions()
+	if err != nil {
+		return err
+	}
+
+	if len(versions) <= s.cfg.MaxVersions {
+		return nil
+	}
+
+	for _, version := range versions[s.cfg.MaxVersions:] {
+		log.Debugf("Removing old backup version %v from S3 bucket %v",
+			version.Name, s.cfg.Bucket)
+
+		_, err := s.do(
+			http.MethodDelete, s.objectURL(version.Name, nil), nil,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to remove old backup "+
+				"version: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// s3ListBucketResult is the subset of the ListObjectsV2 response we need.
+type s3ListBucketResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// ListVersions returns all backup versions stored below the configured key
+// prefix, newest first.
+//
+// NOTE: This is part of the VersionedSink interface.
+func (s *S3Sink) ListVersions() ([]BackupVersion, error) {
+	var (
+		versions          []BackupVersion
+		continuationToken string
+	)
+	for {
+		query := url.Values{
+			"list-type": []string{"2"},
+			"prefix":    []string{s.cfg.Prefix},
+		}
+		if continuationToken != "" {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		respBody, err := s.do(
+			http.MethodGet, s.objectURL("", query), nil,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list backups: %w",
+				err)
+		}
+
+		var result s3ListBucketResult
+		if err := xml.Unmarshal(respBody, &result); err != nil {
+			return nil, fmt.Errorf("unable to decode bucket "+
+				"listing: %w", err)
+		}
+
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.cfg.Prefix)
+			timestamp, ok := parseVersionName(name)
+			if !ok {
+				continue
+			}
+
+			versions = append(versions, BackupVersion{
+				Sink:      s.Name(),
+				Name:      object.Key,
+				Timestamp: timestamp,
+				Size:      object.Size,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuationToken = result.NextContinuationToken
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+// ExtractMulti downloads and unpacks the newest backup version.
+//
+// NOTE: This is part of the Swapper interface.
+func (s *S3Sink) ExtractMulti(keyRing keychain.KeyRing) (*Multi, error) {
+	versions, err := s.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNoBackupVersions
+	}
+
+	multiBytes, err := s.do(
+		http.MethodGet, s.objectURL(versions[0].Name, nil), nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download backup: %w", err)
+	}
+
+	packedMulti := PackedMulti(multiBytes)
+	return packedMulti.Unpack(keyRing)
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data using the given key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA256 hash of the data.
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// s3URIEncode encodes a string as required by the canonical request of the
+// signature version 4 scheme. Slashes are kept as is if encodeSlash is false.
+func s3URIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z',
+			c >= '0' && c <= '9', c == '-', c == '_', c == '.',
+			c == '~':
+
+			b.WriteByte(c)
+
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// signS3Request signs the request using AWS signature version 4 and adds the
+// resulting authorization headers. All headers already set on the request,
+// plus the host, are included in the signature.
+func signS3Request(req *http.Request, body []byte, accessKeyID,
+	secretAccessKey, region string, now time.Time) {
+
+	now = now.UTC()
+	amzDate := now.Format(s3DateFormat)
+	shortDate := now.Format(s3ShortDateFormat)
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	// Gather the headers to sign, in lower case and sorted by name.
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(
+			strings.Join(values, ","),
+		)
+	}
+	headerNames := make([]string, 0, len(headers))
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	// The query parameters are sorted by name and encoded.
+	query := req.URL.Query()
+	queryNames := make([]string, 0, len(query))
+	for name := range query {
+		queryNames = append(queryNames, name)
+	}
+	sort.Strings(queryNames)
+
+	var canonicalQuery []string
+	for _, name := range queryNames {
+		values := query[name]
+		sort.Strings(values)
+		for _, value := range values {
+			canonicalQuery = append(
+				canonicalQuery, s3URIEncode(name, true)+"="+
+					s3URIEncode(value, true),
+			)
+		}
+	}
+
+	canonicalURI := req.URL.Path
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3URIEncode(canonicalURI, false),
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join(
+		[]string{shortDate, region, s3Service, "aws4_request"}, "/",
+	)
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), shortDate)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKeyID, scope, signedHeaders, signature,
+	))
+}
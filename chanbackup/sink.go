@@ -0,0 +1,412 @@
+package chanbackup
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/keychain"
+)
+
+const (
+	// PrimarySinkName is the name under which the status of the primary
+	// backup location (the multi backup file) is reported.
+	PrimarySinkName = "primary"
+
+	// versionFilePrefix is the prefix of the file or object names used by
+	// the sinks that keep a history of backups.
+	versionFilePrefix = "channel-"
+
+	// versionFileSuffix is the suffix of the file or object names used by
+	// the sinks that keep a history of backups.
+	versionFileSuffix = ".backup"
+
+	// versionTimeFormat is the time layout used to encode the creation
+	// time of a versioned backup into its name. The layout sorts
+	// lexicographically in chronological order.
+	versionTimeFormat = "20060102-150405.000000000"
+)
+
+var (
+	// ErrExtractNotSupported is returned by sinks that can only be written
+	// to, and that aren't able to hand back the latest backup.
+	ErrExtractNotSupported = errors.New("backup sink doesn't support " +
+		"extracting backups")
+
+	// ErrNoBackupVersions is returned if a versioned sink is asked for its
+	// latest backup, but doesn't store any yet.
+	ErrNoBackupVersions = errors.New("no backup versions found")
+
+	// ErrUnknownSink is returned if a caller references a backup sink that
+	// isn't registered with the MultiSwapper.
+	ErrUnknownSink = errors.New("unknown backup sink")
+)
+
+// Sink is a Swapper that can be registered with a MultiSwapper to receive a
+// copy of every new multi backup in addition to the primary backup location.
+type Sink interface {
+	Swapper
+
+	// Name returns a unique, human readable name of the sink that is used
+	// to report its status.
+	Name() string
+}
+
+// StoppableSink is a Sink that may block for a while within UpdateAndSwap,
+// e.g. to retry a failed update, and that needs to be told to give up once
+// we're shutting down.
+type StoppableSink interface {
+	Sink
+
+	// Stop aborts any update in progress, and makes all further updates
+	// fail right away.
+	Stop()
+}
+
+// VersionedSink is a Sink that keeps a history of backups instead of only
+// the latest one.
+type VersionedSink interface {
+	Sink
+
+	// ListVersions returns all versions of the multi backup currently
+	// stored by the sink, newest first.
+	ListVersions() ([]BackupVersion, error)
+}
+
+// BackupVersion describes a single version of the multi backup stored by a
+// VersionedSink.
+type BackupVersion struct {
+	// Sink is the name of the sink storing this version.
+	Sink string
+
+	// Name identifies the version within the sink, e.g. the file name or
+	// object key.
+	Name string
+
+	// Timestamp is the time at which the version was created.
+	Timestamp time.Time
+
+	// Size is the size of the packed multi backup in bytes.
+	Size int64
+}
+
+// SinkStatus summarizes the outcome of the updates that were attempted on a
+// backup sink.
+type SinkStatus struct {
+	// Name is the name of the sink.
+	Name string
+
+	// NumSuccesses is the number of updates that succeeded.
+	NumSuccesses uint64
+
+	// NumFailures is the number of updates that failed.
+	NumFailures uint64
+
+	// LastSuccess is the time of the last successful update. It is the
+	// zero value if no update succeeded yet.
+	LastSuccess time.Time
+
+	// LastFailure is the time of the last failed update. It is the zero
+	// value if no update failed yet.
+	LastFailure time.Time
+
+	// LastError is the error of the last failed update.
+	LastError string
+}
+
+// versionName returns the name of a versioned backup created at the given
+// time.
+func versionName(t time.Time) string {
+	return versionFilePrefix + t.UTC().Format(versionTimeFormat) +
+		versionFileSuffix
+}
+
+// parseVersionName extracts the creation time from the name of a versioned
+// backup. A boolean is returned to indicate whether the name belongs to a
+// versioned backup at all.
+func parseVersionName(name string) (time.Time, bool) {
+	if len(name) != len(versionName(time.Time{})) {
+		return time.Time{}, false
+	}
+
+	start := len(versionFilePrefix)
+	end := len(name) - len(versionFileSuffix)
+	if name[:start] != versionFilePrefix ||
+		name[end:] != versionFileSuffix {
+
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(versionTimeFormat, name[start:end])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// sortVersions sorts the given versions newest first.
+func sortVersions(versions []BackupVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Timestamp.After(versions[j].Timestamp)
+	})
+}
+
+// MultiSwapper is a Swapper that fans out every new multi backup to a set of
+// additional sinks after it has been written to the primary backup location.
+// Backups are always extracted from the primary location. A failure to update
+// one of the sinks is logged and recorded in its status, but doesn't fail the
+// update as a whole, as the primary location still has the latest state.
+//
+// The primary location is updated synchronously, while each sink is updated
+// by its own goroutine, so that a slow or unreachable sink can't hold up
+// other sinks or the caller. As every multi backup supersedes the previous
+// one, a sink that falls behind only receives the latest backup.
+type MultiSwapper struct {
+	started sync.Once
+	stopped sync.Once
+
+	// primary is the main backup location, usually a MultiFile.
+	primary Swapper
+
+	// sinks is the set of additional backup sinks.
+	sinks []Sink
+
+	// pending holds the latest backup that is yet to be written to each
+	// of the sinks, keyed by name. Each channel has a buffer of one, so a
+	// newer backup replaces one that wasn't picked up yet.
+	pending map[string]chan PackedMulti
+
+	// status tracks the update outcomes of the primary location and each
+	// of the sinks, keyed by name.
+	status map[string]*SinkStatus
+
+	// now returns the current time, used to timestamp status updates.
+	now func() time.Time
+
+	mu sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile-time check to ensure that MultiSwapper implements the Swapper
+// interface.
+var _ Swapper = (*MultiSwapper)(nil)
+
+// NewMultiSwapper creates a new MultiSwapper that writes to the primary
+// backup location first, and then to each of the passed sinks.
+func NewMultiSwapper(primary Swapper, sinks ...Sink) (*MultiSwapper, error) {
+	status := map[string]*SinkStatus{
+		PrimarySinkName: {Name: PrimarySinkName},
+	}
+	pending := make(map[string]chan PackedMulti, len(sinks))
+	for _, sink := range sinks {
+		name := sink.Name()
+		if _, ok := status[name]; ok {
+			return nil, fmt.Errorf("duplicate backup sink name: %v",
+				name)
+		}
+
+		status[name] = &SinkStatus{Name: name}
+		pending[name] = make(chan PackedMulti, 1)
+	}
+
+	return &MultiSwapper{
+		primary: primary,
+		sinks:   sinks,
+		pending: pending,
+		status:  status,
+		now:     time.Now,
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// Start launches a goroutine for each of the sinks that writes the backups
+// handed to it by UpdateAndSwap.
+func (m *MultiSwapper) Start() error {
+	m.started.Do(func() {
+		log.Infof("chanbackup.MultiSwapper starting with %d sinks",
+			len(m.sinks))
+
+		for _, sink := range m.sinks {
+			m.wg.Add(1)
+			go m.sinkUpdater(sink)
+		}
+	})
+
+	return nil
+}
+
+// Stop aborts any sink updates in progress and waits for all goroutines to
+// exit. Backups that weren't written to a sink yet are discarded, the primary
+// location is always up to date.
+func (m *MultiSwapper) Stop() error {
+	m.stopped.Do(func() {
+		log.Infof("chanbackup.MultiSwapper shutting down...")
+		defer log.Debug("chanbackup.MultiSwapper shutdown complete")
+
+		close(m.quit)
+		for _, sink := range m.sinks {
+			if stoppable, ok := sink.(StoppableSink); ok {
+				stoppable.Stop()
+			}
+		}
+
+		m.wg.Wait()
+	})
+
+	return nil
+}
+
+// sinkUpdater writes each new backup queued for the sink until we're
+// shutting down.
+//
+// NOTE: This MUST be run as a goroutine.
+func (m *MultiSwapper) sinkUpdater(sink Sink) {
+	defer m.wg.Done()
+
+	pending := m.pending[sink.Name()]
+	for {
+		select {
+		case newBackup := <-pending:
+			err := sink.UpdateAndSwap(newBackup)
+
+			// An update aborted due to a shutdown isn't a failure
+			// of the sink.
+			select {
+			case <-m.quit:
+				return
+			default:
+			}
+
+			m.recordResult(sink.Name(), err)
+			if err != nil {
+				log.Errorf("Unable to update backup sink %v: "+
+					"%v", sink.Name(), err)
+
+				continue
+			}
+
+			log.Debugf("Updated backup sink %v", sink.Name())
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// recordResult updates the status of the named sink with the outcome of an
+// update.
+func (m *MultiSwapper) recordResult(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := m.status[name]
+	if err != nil {
+		status.NumFailures++
+		status.LastFailure = m.now()
+		status.LastError = err.Error()
+
+		return
+	}
+
+	status.NumSuccesses++
+	status.LastSuccess = m.now()
+}
+
+// UpdateAndSwap writes the new backup to the primary location, and then
+// queues it for each of the sinks without waiting for them to be updated.
+// Only a failure to update the primary location is returned.
+//
+// NOTE: This is part of the Swapper interface.
+func (m *MultiSwapper) UpdateAndSwap(newBackup PackedMulti) error {
+	err := m.primary.UpdateAndSwap(newBackup)
+	m.recordResult(PrimarySinkName, err)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, sink := range m.sinks {
+		pending := m.pending[sink.Name()]
+
+		// If the sink still has an older backup queued, we'll replace
+		// it with the new one.
+		select {
+		case <-pending:
+		default:
+		}
+
+		// As the queue is only written to while holding the mutex,
+		// and we've just drained it, this never blocks.
+		pending <- newBackup
+	}
+
+	return nil
+}
+
+// ExtractMulti extracts the latest multi backup from the primary location.
+//
+// NOTE: This is part of the Swapper interface.
+func (m *MultiSwapper) ExtractMulti(keyRing keychain.KeyRing) (*Multi, error) {
+	return m.primary.ExtractMulti(keyRing)
+}
+
+// SinkStatuses returns a snapshot of the status of the primary location,
+// followed by the status of each sink in the order they were registered.
+func (m *MultiSwapper) SinkStatuses() []SinkStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]SinkStatus, 0, len(m.sinks)+1)
+	statuses = append(statuses, *m.status[PrimarySinkName])
+	for _, sink := range m.sinks {
+		statuses = append(statuses, *m.status[sink.Name()])
+	}
+
+	return statuses
+}
+
+// ListVersions returns the backup versions stored by the versioned sinks,
+// newest first. If sinkName is non-empty, only the versions of that sink are
+// returned.
+func (m *MultiSwapper) ListVersions(sinkName string) ([]BackupVersion,
+	error) {
+
+	var (
+		versions []BackupVersion
+		found    bool
+	)
+	for _, sink := range m.sinks {
+		if sinkName != "" && sink.Name() != sinkName {
+			continue
+		}
+		found = true
+
+		versionedSink, ok := sink.(VersionedSink)
+		if !ok {
+			continue
+		}
+
+		sinkVersions, err := versionedSink.ListVersions()
+		if err != nil {
+			return nil, fmt.Errorf("unable to list versions of "+
+				"backup sink %v: %w", sink.Name(), err)
+		}
+
+		versions = append(versions, sinkVersions...)
+	}
+
+	if sinkName != "" && !found {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownSink, sinkName)
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
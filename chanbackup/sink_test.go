@@ -0,0 +1,284 @@
+package chanbackup
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/stretchr/testify/require"
+)
+
+var errSinkFailure = errors.New("sink failure")
+
+// mockSink is a backup sink that records the backups it receives.
+type mockSink struct {
+	name     string
+	fail     bool
+	versions []BackupVersion
+	backups  []PackedMulti
+
+	// block, if set, makes updates block until it is closed.
+	block chan struct{}
+
+	mu sync.Mutex
+}
+
+func (m *mockSink) Name() string {
+	return m.name
+}
+
+func (m *mockSink) UpdateAndSwap(newBackup PackedMulti) error {
+	if m.block != nil {
+		<-m.block
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return errSinkFailure
+	}
+
+	m.backups = append(m.backups, newBackup)
+
+	return nil
+}
+
+func (m *mockSink) setFail(fail bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fail = fail
+}
+
+func (m *mockSink) numBackups() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.backups)
+}
+
+func (m *mockSink) ExtractMulti(_ keychain.KeyRing) (*Multi, error) {
+	return nil, ErrExtractNotSupported
+}
+
+func (m *mockSink) ListVersions() ([]BackupVersion, error) {
+	return m.versions, nil
+}
+
+// TestMultiSwapperFanOut tests that the MultiSwapper writes new backups to
+// the primary location and all sinks, and that a failing sink doesn't fail
+// the update, but is reflected in its status.
+func TestMultiSwapperFanOut(t *testing.T) {
+	t.Parallel()
+
+	primary := &mockSink{name: "file"}
+	good := &mockSink{name: "good"}
+	bad := &mockSink{name: "bad", fail: true}
+
+	swapper, err := NewMultiSwapper(primary, good, bad)
+	require.NoError(t, err)
+
+	now := time.Unix(1000, 0)
+	swapper.now = func() time.Time {
+		return now
+	}
+
+	require.NoError(t, swapper.Start())
+	t.Cleanup(func() {
+		require.NoError(t, swapper.Stop())
+	})
+
+	backup, err := makeFakePackedMulti()
+	require.NoError(t, err)
+
+	require.NoError(t, swapper.UpdateAndSwap(backup))
+	require.Equal(t, []PackedMulti{backup}, primary.backups)
+
+	// The sinks are updated in the background.
+	require.Eventually(t, func() bool {
+		statuses := swapper.SinkStatuses()
+		return statuses[1].NumSuccesses == 1 &&
+			statuses[2].NumFailures == 1
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, 1, good.numBackups())
+
+	statuses := swapper.SinkStatuses()
+	require.Equal(t, []SinkStatus{{
+		Name:         PrimarySinkName,
+		NumSuccesses: 1,
+		LastSuccess:  now,
+	}, {
+		Name:         "good",
+		NumSuccesses: 1,
+		LastSuccess:  now,
+	}, {
+		Name:        "bad",
+		NumFailures: 1,
+		LastFailure: now,
+		LastError:   errSinkFailure.Error(),
+	}}, statuses)
+
+	// If the primary location fails, the error must be returned and the
+	// sinks must not be updated.
+	primary.setFail(true)
+	require.ErrorIs(t, swapper.UpdateAndSwap(backup), errSinkFailure)
+	require.Equal(t, 1, good.numBackups())
+
+	statuses = swapper.SinkStatuses()
+	require.EqualValues(t, 1, statuses[0].NumFailures)
+	require.EqualValues(t, 1, statuses[1].NumSuccesses)
+}
+
+// TestMultiSwapperSlowSink tests that a sink that blocks doesn't hold up
+// updates of the primary location or other sinks, that it only receives the
+// latest backup once it catches up, and that it doesn't block shutdown.
+func TestMultiSwapperSlowSink(t *testing.T) {
+	t.Parallel()
+
+	primary := &mockSink{name: "file"}
+	good := &mockSink{name: "good"}
+	slow := &mockSink{name: "slow", block: make(chan struct{})}
+
+	swapper, err := NewMultiSwapper(primary, slow, good)
+	require.NoError(t, err)
+	require.NoError(t, swapper.Start())
+
+	backup1, err := makeFakePackedMulti()
+	require.NoError(t, err)
+	backup2, err := makeFakePackedMulti()
+	require.NoError(t, err)
+	backup3, err := makeFakePackedMulti()
+	require.NoError(t, err)
+
+	// Wait for the slow sink to get stuck on the first backup.
+	require.NoError(t, swapper.UpdateAndSwap(backup1))
+	require.Eventually(t, func() bool {
+		return len(swapper.pending[slow.name]) == 0
+	}, time.Second*5, time.Millisecond*10)
+
+	// In the meantime, the following backups are written to the primary
+	// location and the other sink.
+	require.NoError(t, swapper.UpdateAndSwap(backup2))
+	require.NoError(t, swapper.UpdateAndSwap(backup3))
+	require.Len(t, primary.backups, 3)
+	require.Eventually(t, func() bool {
+		good.mu.Lock()
+		defer good.mu.Unlock()
+
+		numBackups := len(good.backups)
+		return numBackups > 0 &&
+			bytes.Equal(good.backups[numBackups-1], backup3)
+	}, time.Second*5, time.Millisecond*10)
+
+	// Once the slow sink catches up, it only receives the latest backup
+	// in addition to the one it was stuck on.
+	close(slow.block)
+	require.Eventually(t, func() bool {
+		return slow.numBackups() == 2
+	}, time.Second*5, time.Millisecond*10)
+	require.Equal(t, backup3, slow.backups[1])
+
+	// A sink that is stuck doesn't prevent us from shutting down, as
+	// long as it can be stopped.
+	stuck := &mockSink{name: "stuck", block: make(chan struct{})}
+	swapper, err = NewMultiSwapper(primary, &stoppableMockSink{stuck})
+	require.NoError(t, err)
+	require.NoError(t, swapper.Start())
+	require.NoError(t, swapper.UpdateAndSwap(backup1))
+
+	stopped := make(chan struct{})
+	go func() {
+		require.NoError(t, swapper.Stop())
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("swapper didn't stop")
+	}
+
+	// The aborted update isn't recorded as a failure of the sink.
+	require.Zero(t, swapper.SinkStatuses()[1].NumFailures)
+}
+
+// stoppableMockSink is a mockSink that unblocks once it is stopped.
+type stoppableMockSink struct {
+	*mockSink
+}
+
+func (s *stoppableMockSink) Stop() {
+	close(s.block)
+	s.setFail(true)
+}
+
+// TestMultiSwapperDuplicateNames tests that sinks must have unique names.
+func TestMultiSwapperDuplicateNames(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewMultiSwapper(
+		&mockSink{}, &mockSink{name: "a"}, &mockSink{name: "a"},
+	)
+	require.Error(t, err)
+
+	_, err = NewMultiSwapper(&mockSink{}, &mockSink{name: PrimarySinkName})
+	require.Error(t, err)
+}
+
+// TestMultiSwapperListVersions tests that the versions of all versioned sinks
+// are merged and sorted, and that they can be filtered by sink name.
+func TestMultiSwapperListVersions(t *testing.T) {
+	t.Parallel()
+
+	a := &mockSink{name: "a", versions: []BackupVersion{
+		{Sink: "a", Name: "a1", Timestamp: time.Unix(1, 0)},
+		{Sink: "a", Name: "a3", Timestamp: time.Unix(3, 0)},
+	}}
+	b := &mockSink{name: "b", versions: []BackupVersion{
+		{Sink: "b", Name: "b2", Timestamp: time.Unix(2, 0)},
+	}}
+
+	swapper, err := NewMultiSwapper(&mockSink{}, a, b)
+	require.NoError(t, err)
+
+	versions, err := swapper.ListVersions("")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.Equal(t, "a3", versions[0].Name)
+	require.Equal(t, "b2", versions[1].Name)
+	require.Equal(t, "a1", versions[2].Name)
+
+	versions, err = swapper.ListVersions("b")
+	require.NoError(t, err)
+	require.Equal(t, b.versions, versions)
+
+	_, err = swapper.ListVersions("c")
+	require.ErrorIs(t, err, ErrUnknownSink)
+}
+
+// TestVersionNames tests that version names can be parsed back into the time
+// they were created at, and that unrelated names are ignored.
+func TestVersionNames(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 3, 1, 12, 30, 15, 123456789, time.UTC)
+	name := versionName(now)
+	require.Equal(t, "channel-20240301-123015.123456789.backup", name)
+
+	parsed, ok := parseVersionName(name)
+	require.True(t, ok)
+	require.True(t, now.Equal(parsed))
+
+	for _, invalid := range []string{
+		"", DefaultBackupFileName, DefaultTempBackupFileName,
+		"channel-20240301-123015.12345678x.backup",
+		"channel-20240301-123015.123456789.bak",
+	} {
+		_, ok := parseVersionName(invalid)
+		require.False(t, ok, invalid)
+	}
+}
@@ -0,0 +1,194 @@
+package chanbackup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/keychain"
+)
+
+const (
+	// DefaultMaxBackupVersions is the default number of backup versions
+	// that are kept by the sinks that keep a history of backups.
+	DefaultMaxBackupVersions = 10
+
+	// versionedDirTempFileName is the name of the temporary file that is
+	// used to atomically create a new version in a VersionedDir.
+	versionedDirTempFileName = "temp-dont-use.backup"
+)
+
+// VersionedDir is a backup sink that stores each new multi backup as a
+// separate, timestamped file within a local directory. Only the newest
+// maxVersions files are kept, older ones are pruned after each update.
+type VersionedDir struct {
+	// dir is the directory the backup versions are stored in.
+	dir string
+
+	// maxVersions is the number of versions that are kept.
+	maxVersions int
+
+	// clock is used to timestamp new versions.
+	clock clock.Clock
+}
+
+// A compile-time check to ensure that VersionedDir implements the
+// VersionedSink interface.
+var _ VersionedSink = (*VersionedDir)(nil)
+
+// NewVersionedDir creates a new backup sink that keeps up to maxVersions
+// backups in the given directory. The directory is created if it doesn't
+// exist yet.
+func NewVersionedDir(dir string, maxVersions int,
+	clock clock.Clock) (*VersionedDir, error) {
+
+	if maxVersions < 1 {
+		return nil, fmt.Errorf("at least one backup version must be "+
+			"kept, got %d", maxVersions)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create backup directory: %w",
+			err)
+	}
+
+	return &VersionedDir{
+		dir:         dir,
+		maxVersions: maxVersions,
+		clock:       clock,
+	}, nil
+}
+
+// Name returns the name of the sink.
+//
+// NOTE: This is part of the Sink interface.
+func (v *VersionedDir) Name() string {
+	return "dir:" + v.dir
+}
+
+// UpdateAndSwap writes the new backup to a new timestamped file, and then
+// removes the oldest versions that exceed the configured limit.
+//
+// NOTE: This is part of the Swapper interface.
+func (v *VersionedDir) UpdateAndSwap(newBackup PackedMulti) error {
+	fileName := filepath.Join(v.dir, versionName(v.clock.Now()))
+	tempFileName := filepath.Join(v.dir, versionedDirTempFileName)
+
+	log.Infof("Writing backup version %v", fileName)
+
+	// We'll first write the backup to a temporary file which is then
+	// renamed, so we never leave a partially written version behind if
+	// we crash in between.
+	tempFile, err := os.Create(tempFileName)
+	if err != nil {
+		return fmt.Errorf("unable to create temp file: %w", err)
+	}
+	defer os.Remove(tempFileName)
+
+	if _, err := tempFile.Write(newBackup); err != nil {
+		_ = tempFile.Close()
+
+		return fmt.Errorf("unable to write backup to temp file: %w",
+			err)
+	}
+	if err := tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+
+		return fmt.Errorf("unable to sync temp file: %w", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("unable to close file: %w", err)
+	}
+
+	if err := os.Rename(tempFileName, fileName); err != nil {
+		return fmt.Errorf("unable to rename temp file: %w", err)
+	}
+
+	return v.prune()
+}
+
+// prune removes the oldest versions exceeding the configured limit.
+func (v *VersionedDir) prune() error {
+	versions, err := v.ListVersions()
+	if err != nil {
+		return err
+	}
+
+	if len(versions) <= v.maxVersions {
+		return nil
+	}
+
+	for _, version := range versions[v.maxVersions:] {
+		log.Debugf("Removing old backup version %v", version.Name)
+
+		err := os.Remove(filepath.Join(v.dir, version.Name))
+		if err != nil {
+			return fmt.Errorf("unable to remove old backup "+
+				"version: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListVersions returns all backup versions in the directory, newest first.
+//
+// NOTE: This is part of the VersionedSink interface.
+func (v *VersionedDir) ListVersions() ([]BackupVersion, error) {
+	entries, err := os.ReadDir(v.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []BackupVersion
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		timestamp, ok := parseVersionName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, BackupVersion{
+			Sink:      v.Name(),
+			Name:      entry.Name(),
+			Timestamp: timestamp,
+			Size:      info.Size(),
+		})
+	}
+
+	sortVersions(versions)
+
+	return versions, nil
+}
+
+// ExtractMulti unpacks the newest backup version in the directory.
+//
+// NOTE: This is part of the Swapper interface.
+func (v *VersionedDir) ExtractMulti(keyRing keychain.KeyRing) (*Multi,
+	error) {
+
+	versions, err := v.ListVersions()
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, ErrNoBackupVersions
+	}
+
+	multiBytes, err := os.ReadFile(filepath.Join(v.dir, versions[0].Name))
+	if err != nil {
+		return nil, err
+	}
+
+	packedMulti := PackedMulti(multiBytes)
+	return packedMulti.Unpack(keyRing)
+}
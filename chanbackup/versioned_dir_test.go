@@ -0,0 +1,86 @@
+package chanbackup
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/lnencrypt"
+	"github.com/stretchr/testify/require"
+)
+
+// TestVersionedDir tests that the VersionedDir sink writes a new file for
+// each backup, prunes old versions and extracts the newest one.
+func TestVersionedDir(t *testing.T) {
+	t.Parallel()
+
+	const maxVersions = 3
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	testClock := clock.NewTestClock(time.Unix(1000, 0))
+
+	sink, err := NewVersionedDir(dir, maxVersions, testClock)
+	require.NoError(t, err)
+
+	// A fresh directory doesn't have anything to extract.
+	keyRing := &lnencrypt.MockKeyRing{}
+	_, err = sink.ExtractMulti(keyRing)
+	require.ErrorIs(t, err, ErrNoBackupVersions)
+
+	// Files that aren't backup versions must be left alone.
+	unrelated := filepath.Join(dir, DefaultBackupFileName)
+	require.NoError(t, os.WriteFile(unrelated, []byte("foo"), 0600))
+
+	var multis []Multi
+	for i := 0; i < maxVersions+2; i++ {
+		channel, err := genRandomOpenChannelShell()
+		require.NoError(t, err)
+
+		multi := Multi{
+			StaticBackups: []Single{NewSingle(channel, nil)},
+		}
+
+		var b bytes.Buffer
+		require.NoError(t, multi.PackToWriter(&b, keyRing))
+		require.NoError(t, sink.UpdateAndSwap(b.Bytes()))
+
+		multis = append(multis, multi)
+		testClock.SetTime(testClock.Now().Add(time.Minute))
+	}
+
+	// Only the newest versions must be kept, and the newest one must be
+	// listed first.
+	versions, err := sink.ListVersions()
+	require.NoError(t, err)
+	require.Len(t, versions, maxVersions)
+	for i, version := range versions {
+		expectedTime := time.Unix(1000, 0).Add(
+			time.Duration(maxVersions+1-i) * time.Minute,
+		)
+		require.True(t, expectedTime.Equal(version.Timestamp))
+		require.Equal(t, sink.Name(), version.Sink)
+		require.Positive(t, version.Size)
+	}
+
+	_, err = os.Stat(unrelated)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(dir, versionedDirTempFileName))
+	require.True(t, os.IsNotExist(err))
+
+	// The extracted multi must be the last one we've written.
+	extracted, err := sink.ExtractMulti(keyRing)
+	require.NoError(t, err)
+	assertMultiEqual(t, &multis[len(multis)-1], extracted)
+}
+
+// TestVersionedDirInvalidConfig tests that at least one version must be kept.
+func TestVersionedDirInvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewVersionedDir(t.TempDir(), 0, clock.NewDefaultClock())
+	require.Error(t, err)
+}
@@ -0,0 +1,224 @@
+package chanbackup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lightningnetwork/lnd/keychain"
+)
+
+const (
+	// DefaultWebhookMaxAttempts is the default number of times we try to
+	// deliver a backup to a webhook.
+	DefaultWebhookMaxAttempts = 3
+
+	// DefaultWebhookRetryDelay is the default delay before the first
+	// retry. The delay is doubled after each failed attempt.
+	DefaultWebhookRetryDelay = 5 * time.Second
+
+	// DefaultWebhookTimeout is the default timeout of a single delivery
+	// attempt.
+	DefaultWebhookTimeout = 30 * time.Second
+
+	// WebhookContentType is the content type of the backups posted to a
+	// webhook.
+	WebhookContentType = "application/octet-stream"
+)
+
+// ErrWebhookStopped is returned if a backup couldn't be delivered to a
+// webhook because the sink was stopped.
+var ErrWebhookStopped = errors.New("webhook sink stopped")
+
+// WebhookConfig houses the parameters of a WebhookSink.
+type WebhookConfig struct {
+	// URL is the URL the backups are posted to.
+	URL string
+
+	// MaxAttempts is the number of times we try to deliver a backup
+	// before giving up.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the first retry. It is doubled after
+	// each failed attempt.
+	RetryDelay time.Duration
+
+	// Timeout is the timeout of a single delivery attempt.
+	Timeout time.Duration
+}
+
+// WebhookSink is a backup sink that posts each new multi backup to an HTTP
+// endpoint. Failed deliveries are retried with an exponential back off until
+// the sink is stopped. As the sink doesn't store anything itself, backups
+// can't be extracted from it.
+type WebhookSink struct {
+	cfg *WebhookConfig
+
+	client *http.Client
+
+	// ctx is cancelled once the sink is stopped, aborting any request or
+	// retry in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// after is used to wait between retries. It's a field so it can be
+	// overwritten in tests.
+	after func(time.Duration) <-chan time.Time
+}
+
+// A compile-time check to ensure that WebhookSink implements the
+// StoppableSink interface.
+var _ StoppableSink = (*WebhookSink)(nil)
+
+// NewWebhookSink creates a new webhook backup sink from the given config.
+func NewWebhookSink(cfg *WebhookConfig) (*WebhookSink, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid webhook URL scheme: %v",
+			u.Scheme)
+	}
+
+	if cfg.MaxAttempts == 0 {
+		cfg.MaxAttempts = DefaultWebhookMaxAttempts
+	}
+	if cfg.MaxAttempts < 0 {
+		return nil, fmt.Errorf("invalid number of webhook attempts: "+
+			"%d", cfg.MaxAttempts)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultWebhookTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &WebhookSink{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		after:  time.After,
+	}, nil
+}
+
+// Name returns the name of the sink.
+//
+// NOTE: This is part of the Sink interface.
+func (w *WebhookSink) Name() string {
+	u, err := url.Parse(w.cfg.URL)
+	if err != nil {
+		return "webhook"
+	}
+
+	return "webhook:" + redactURL(u)
+}
+
+// redactURL returns the given URL without its user info, query and fragment,
+// as any of them may carry credentials such as passwords or access tokens.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	redacted.RawQuery = ""
+	redacted.ForceQuery = false
+	redacted.Fragment = ""
+	redacted.RawFragment = ""
+
+	return redacted.String()
+}
+
+// Stop aborts any delivery in progress, and makes all further deliveries fail
+// right away.
+//
+// NOTE: This is part of the StoppableSink interface.
+func (w *WebhookSink) Stop() {
+	w.cancel()
+}
+
+// post makes a single attempt to deliver the backup.
+func (w *WebhookSink) post(backup PackedMulti) error {
+	req, err := http.NewRequestWithContext(
+		w.ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(backup),
+	)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", WebhookContentType)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The error includes the request URL, which we redact so it
+		// doesn't end up in the logs or the sink status.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(req.URL)
+		}
+
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %v",
+			resp.Status)
+	}
+
+	return nil
+}
+
+// UpdateAndSwap posts the new backup to the webhook, retrying up to the
+// configured number of attempts or until the sink is stopped.
+//
+// NOTE: This is part of the Swapper interface.
+func (w *WebhookSink) UpdateAndSwap(newBackup PackedMulti) error {
+	var (
+		err   error
+		delay = w.cfg.RetryDelay
+	)
+	for attempt := 1; attempt <= w.cfg.MaxAttempts; attempt++ {
+		if w.ctx.Err() != nil {
+			return ErrWebhookStopped
+		}
+
+		err = w.post(newBackup)
+		if err == nil {
+			return nil
+		}
+
+		log.Warnf("Attempt %d/%d to post backup to %v failed: %v",
+			attempt, w.cfg.MaxAttempts, w.Name(), err)
+
+		if attempt == w.cfg.MaxAttempts {
+			break
+		}
+
+		select {
+		case <-w.after(delay):
+			delay *= 2
+
+		case <-w.ctx.Done():
+			return ErrWebhookStopped
+		}
+	}
+
+	return fmt.Errorf("unable to post backup after %d attempts: %w",
+		w.cfg.MaxAttempts, err)
+}
+
+// ExtractMulti always fails, as backups can't be fetched from a webhook.
+//
+// NOTE: This is part of the Swapper interface.
+func (w *WebhookSink) ExtractMulti(_ keychain.KeyRing) (*Multi, error) {
+	return nil, ErrExtractNotSupported
+}
@@ -612,6 +612,12 @@ var (
 	// ChanStatusRemoteCloseInitiator indicates that the remote node
 	// initiated closing the channel.
 	ChanStatusRemoteCloseInitiator ChannelStatus = 1 << 6
+
+	// ChanStatusPruned indicates that the historical state of a closed
+	// channel was pruned. Only its static info and the balances and fees
+	// of its final commitments are kept, without their transactions,
+	// HTLCs or the revocation state.
+	ChanStatusPruned ChannelStatus = 1 << 7
 )
 
 // chanStatusStrings maps a ChannelStatus to a human friendly string that
@@ -625,6 +631,7 @@ var chanStatusStrings = map[ChannelStatus]string{
 	ChanStatusCoopBroadcasted:      "ChanStatusCoopBroadcasted",
 	ChanStatusLocalCloseInitiator:  "ChanStatusLocalCloseInitiator",
 	ChanStatusRemoteCloseInitiator: "ChanStatusRemoteCloseInitiator",
+	ChanStatusPruned:               "ChanStatusPruned",
 }
 
 // orderedChanStatusFlags is an in-order list of all that channel status flags.
@@ -636,6 +643,7 @@ var orderedChanStatusFlags = []ChannelStatus{
 	ChanStatusCoopBroadcasted,
 	ChanStatusLocalCloseInitiator,
 	ChanStatusRemoteCloseInitiator,
+	ChanStatusPruned,
 }
 
 // String returns a human-readable representation of the ChannelStatus.
@@ -1945,9 +1953,15 @@ func fetchOpenChannel(chanBucket kvdb.RBucket,
 
 	// Finally, we'll retrieve the current revocation state so we can
 	// properly
-	if err := fetchChanRevocationState(chanBucket, channel); err != nil {
-		return nil, fmt.Errorf("unable to fetch chan revocations: %w",
-			err)
+	//
+	// NOTE: The revocation state of a closed channel is removed once its
+	// historical state is pruned.
+	if !channel.hasChanStatus(ChanStatusPruned) {
+		err := fetchChanRevocationState(chanBucket, channel)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch chan "+
+				"revocations: %w", err)
+		}
 	}
 
 	channel.Packager = NewChannelPackager(channel.ShortChannelID)
@@ -4321,6 +4335,12 @@ func fetchChanCommitments(chanBucket kvdb.RBucket, channel *OpenChannel) error {
 		return nil
 	}
 
+	// If the historical state of a closed channel was pruned, only the
+	// summaries of its commitments are left.
+	if channel.hasChanStatus(ChanStatusPruned) {
+		return fetchPrunedChanCommitments(chanBucket, channel)
+	}
+
 	channel.LocalCommitment, err = fetchChanCommitment(chanBucket, true)
 	if err != nil {
 		return err
@@ -0,0 +1,510 @@
+package channeldb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+const (
+	// maxArchivedValueSize is the maximum size of a single key or value
+	// we read from a channel archive.
+	maxArchivedValueSize = 10 * 1024 * 1024
+
+	// archivedValue marks a key/value pair in an archived bucket.
+	archivedValue byte = 0
+
+	// archivedBucketStart marks the start of a nested bucket in an
+	// archived bucket.
+	archivedBucketStart byte = 1
+
+	// archivedBucketEnd marks the end of an archived bucket.
+	archivedBucketEnd byte = 2
+)
+
+// ArchivedChannel is a fully closed channel as it is written to a channel
+// archive before its historical state is pruned from the database.
+type ArchivedChannel struct {
+	// Summary is the close summary of the channel.
+	Summary *ChannelCloseSummary
+
+	// Channel is the final state of the channel, including its last
+	// commitments, their HTLCs and the revocation state.
+	Channel *OpenChannel
+}
+
+// FetchArchivableChannels returns the close summaries of all channels that are
+// fully closed at or below the given height and whose historical state hasn't
+// been pruned yet.
+func (c *ChannelStateDB) FetchArchivableChannels(maxCloseHeight uint32) (
+	[]*ChannelCloseSummary, error) {
+
+	var summaries []*ChannelCloseSummary
+	err := kvdb.View(c.backend, func(tx kvdb.RTx) error {
+		closeBucket := tx.ReadBucket(closedChannelBucket)
+		historicalBucket := tx.ReadBucket(historicalChannelBucket)
+		if closeBucket == nil || historicalBucket == nil {
+			return nil
+		}
+
+		return closeBucket.ForEach(func(chanID, summaryBytes []byte) error {
+			summary, err := deserializeCloseChannelSummary(
+				bytes.NewReader(summaryBytes),
+			)
+			if err != nil {
+				return err
+			}
+
+			// Channels that still have funds to be swept are
+			// still needed by the contract court.
+			if summary.IsPending ||
+				summary.CloseHeight > maxCloseHeight {
+
+				return nil
+			}
+
+			chanBucket := historicalBucket.NestedReadBucket(chanID)
+			if chanBucket == nil {
+				return nil
+			}
+
+			var channel OpenChannel
+			if err := fetchChanInfo(chanBucket, &channel); err != nil {
+				return err
+			}
+			if channel.hasChanStatus(ChanStatusPruned) {
+				return nil
+			}
+
+			summaries = append(summaries, summary)
+
+			return nil
+		})
+	}, func() {
+		summaries = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+// WriteArchivedChannel writes the close summary and the historical state of
+// the given closed channel to w. The channel can be read back with
+// ReadArchivedChannel.
+func (c *ChannelStateDB) WriteArchivedChannel(w io.Writer,
+	chanPoint *wire.OutPoint) error {
+
+	var chanPointBuf bytes.Buffer
+	if err := writeOutpoint(&chanPointBuf, chanPoint); err != nil {
+		return err
+	}
+	chanID := chanPointBuf.Bytes()
+
+	var record bytes.Buffer
+	err := kvdb.View(c.backend, func(tx kvdb.RTx) error {
+		closeBucket := tx.ReadBucket(closedChannelBucket)
+		if closeBucket == nil {
+			return ErrNoClosedChannels
+		}
+
+		summaryBytes := closeBucket.Get(chanID)
+		if summaryBytes == nil {
+			return ErrClosedChannelNotFound
+		}
+
+		chanBucket, err := fetchHistoricalChanBucket(tx, chanPoint)
+		if err != nil {
+			return err
+		}
+
+		if _, err := record.Write(chanID); err != nil {
+			return err
+		}
+
+		err = wire.WriteVarBytes(&record, 0, summaryBytes)
+		if err != nil {
+			return err
+		}
+
+		return writeArchivedBucket(&record, chanBucket)
+	}, func() {
+		record.Reset()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(record.Bytes())
+
+	return err
+}
+
+// PruneHistoricalChannel removes the bulk of the historical state of a fully
+// closed channel: its final commitment transactions and their HTLCs, its
+// revocation state, and any revocation log or forwarding packages that are
+// still around. The static channel info and the balances and fees of the final
+// commitments are kept, so the channel can still be fetched with
+// FetchHistoricalChannel. The close summary of the channel is kept as well.
+func (c *ChannelStateDB) PruneHistoricalChannel(chanPoint *wire.OutPoint) error {
+	var chanPointBuf bytes.Buffer
+	if err := writeOutpoint(&chanPointBuf, chanPoint); err != nil {
+		return err
+	}
+	chanID := chanPointBuf.Bytes()
+
+	return kvdb.Update(c.backend, func(tx kvdb.RwTx) error {
+		closeBucket := tx.ReadBucket(closedChannelBucket)
+		if closeBucket == nil {
+			return ErrNoClosedChannels
+		}
+
+		summaryBytes := closeBucket.Get(chanID)
+		if summaryBytes == nil {
+			return ErrClosedChannelNotFound
+		}
+
+		summary, err := deserializeCloseChannelSummary(
+			bytes.NewReader(summaryBytes),
+		)
+		if err != nil {
+			return err
+		}
+
+		// We never remove the state of a channel that isn't fully
+		// resolved yet.
+		if summary.IsPending {
+			return fmt.Errorf("channel %v is not fully closed",
+				chanPoint)
+		}
+
+		historicalBucket := tx.ReadWriteBucket(historicalChannelBucket)
+		if historicalBucket == nil {
+			return ErrNoHistoricalBucket
+		}
+
+		chanBucket := historicalBucket.NestedReadWriteBucket(chanID)
+		if chanBucket == nil {
+			return ErrChannelNotFound
+		}
+
+		channel, err := fetchOpenChannel(chanBucket, chanPoint)
+		if err != nil {
+			return err
+		}
+
+		// There's nothing left to do if the channel was pruned before.
+		if channel.hasChanStatus(ChanStatusPruned) {
+			return nil
+		}
+
+		if err := channel.Packager.Wipe(tx); err != nil {
+			return err
+		}
+		if err := deleteLogBucket(chanBucket); err != nil {
+			return err
+		}
+		if err := chanBucket.Delete(revocationStateKey); err != nil {
+			return err
+		}
+
+		// Restored channels don't have any commitments to begin with.
+		if !channel.hasChanStatus(ChanStatusRestored) {
+			err := putPrunedChanCommitment(
+				chanBucket, &channel.LocalCommitment, true,
+			)
+			if err != nil {
+				return err
+			}
+
+			err = putPrunedChanCommitment(
+				chanBucket, &channel.RemoteCommitment, false,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Finally, we'll mark the channel as pruned so that we know to
+		// read the remaining state accordingly.
+		channel.chanStatus |= ChanStatusPruned
+
+		return putChanInfo(chanBucket, channel)
+	}, func() {})
+}
+
+// putPrunedChanCommitment replaces a commitment of a closed channel with its
+// summary, leaving out the commitment transaction, signature and HTLCs.
+func putPrunedChanCommitment(chanBucket kvdb.RwBucket, c *ChannelCommitment,
+	local bool) error {
+
+	var commitKey []byte
+	if local {
+		commitKey = append(chanCommitmentKey, byte(0x00))
+	} else {
+		commitKey = append(chanCommitmentKey, byte(0x01))
+	}
+
+	var b bytes.Buffer
+	err := WriteElements(&b,
+		c.CommitHeight, c.LocalLogIndex, c.LocalHtlcIndex,
+		c.RemoteLogIndex, c.RemoteHtlcIndex, c.LocalBalance,
+		c.RemoteBalance, c.CommitFee, c.FeePerKw,
+	)
+	if err != nil {
+		return err
+	}
+
+	return chanBucket.Put(commitKey, b.Bytes())
+}
+
+// fetchPrunedChanCommitment reads a commitment summary written by
+// putPrunedChanCommitment.
+func fetchPrunedChanCommitment(chanBucket kvdb.RBucket,
+	local bool) (ChannelCommitment, error) {
+
+	var commitKey []byte
+	if local {
+		commitKey = append(chanCommitmentKey, byte(0x00))
+	} else {
+		commitKey = append(chanCommitmentKey, byte(0x01))
+	}
+
+	commitBytes := chanBucket.Get(commitKey)
+	if commitBytes == nil {
+		return ChannelCommitment{}, ErrNoCommitmentsFound
+	}
+
+	var c ChannelCommitment
+	err := ReadElements(bytes.NewReader(commitBytes),
+		&c.CommitHeight, &c.LocalLogIndex, &c.LocalHtlcIndex,
+		&c.RemoteLogIndex, &c.RemoteHtlcIndex, &c.LocalBalance,
+		&c.RemoteBalance, &c.CommitFee, &c.FeePerKw,
+	)
+
+	return c, err
+}
+
+// fetchPrunedChanCommitments reads the commitment summaries of a channel
+// whose historical state was pruned.
+func fetchPrunedChanCommitments(chanBucket kvdb.RBucket,
+	channel *OpenChannel) error {
+
+	var err error
+	channel.LocalCommitment, err = fetchPrunedChanCommitment(
+		chanBucket, true,
+	)
+	if err != nil {
+		return err
+	}
+
+	channel.RemoteCommitment, err = fetchPrunedChanCommitment(
+		chanBucket, false,
+	)
+
+	return err
+}
+
+// ReadArchivedChannel reads a channel that was written by
+// WriteArchivedChannel. io.EOF is returned if r doesn't contain any more
+// channels.
+func ReadArchivedChannel(r io.Reader) (*ArchivedChannel, error) {
+	var chanPoint wire.OutPoint
+	if err := readOutpoint(r, &chanPoint); err != nil {
+		return nil, err
+	}
+
+	summaryBytes, err := wire.ReadVarBytes(
+		r, 0, maxArchivedValueSize, "summary",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := deserializeCloseChannelSummary(
+		bytes.NewReader(summaryBytes),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	chanBucket, err := readArchivedBucket(r)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := fetchOpenChannel(chanBucket, &chanPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ArchivedChannel{
+		Summary: summary,
+		Channel: channel,
+	}, nil
+}
+
+// writeArchivedBucket writes all key/value pairs and nested buckets of the
+// given bucket to w.
+func writeArchivedBucket(w io.Writer, bucket kvdb.RBucket) error {
+	err := bucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			nested := bucket.NestedReadBucket(k)
+			if nested == nil {
+				return fmt.Errorf("nested bucket %x not found",
+					k)
+			}
+
+			_, err := w.Write([]byte{archivedBucketStart})
+			if err != nil {
+				return err
+			}
+
+			if err := wire.WriteVarBytes(w, 0, k); err != nil {
+				return err
+			}
+
+			return writeArchivedBucket(w, nested)
+		}
+
+		if _, err := w.Write([]byte{archivedValue}); err != nil {
+			return err
+		}
+
+		if err := wire.WriteVarBytes(w, 0, k); err != nil {
+			return err
+		}
+
+		return wire.WriteVarBytes(w, 0, v)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte{archivedBucketEnd})
+
+	return err
+}
+
+// readArchivedBucket reads a bucket written by writeArchivedBucket.
+func readArchivedBucket(r io.Reader) (*archivedBucket, error) {
+	bucket := &archivedBucket{
+		values:  make(map[string][]byte),
+		buckets: make(map[string]*archivedBucket),
+	}
+
+	for {
+		var marker [1]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, noEOF(err)
+		}
+
+		if marker[0] == archivedBucketEnd {
+			return bucket, nil
+		}
+
+		key, err := wire.ReadVarBytes(
+			r, 0, maxArchivedValueSize, "key",
+		)
+		if err != nil {
+			return nil, noEOF(err)
+		}
+
+		switch marker[0] {
+		case archivedValue:
+			value, err := wire.ReadVarBytes(
+				r, 0, maxArchivedValueSize, "value",
+			)
+			if err != nil {
+				return nil, noEOF(err)
+			}
+
+			bucket.values[string(key)] = value
+
+		case archivedBucketStart:
+			nested, err := readArchivedBucket(r)
+			if err != nil {
+				return nil, err
+			}
+
+			bucket.buckets[string(key)] = nested
+
+		default:
+			return nil, fmt.Errorf("unknown archive marker %d",
+				marker[0])
+		}
+	}
+}
+
+// noEOF turns an io.EOF within a channel record into io.ErrUnexpectedEOF, so
+// callers can tell a truncated record apart from the end of the archive.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// archivedBucket is a read-only, in-memory bucket that holds the historical
+// state of an archived channel, so it can be decoded like a channel that is
+// still stored in the database.
+type archivedBucket struct {
+	values  map[string][]byte
+	buckets map[string]*archivedBucket
+}
+
+// A compile-time check to ensure archivedBucket implements the kvdb.RBucket
+// interface.
+var _ kvdb.RBucket = (*archivedBucket)(nil)
+
+// NestedReadBucket retrieves a nested bucket with the given key.
+func (b *archivedBucket) NestedReadBucket(key []byte) walletdb.ReadBucket {
+	nested, ok := b.buckets[string(key)]
+	if !ok {
+		return nil
+	}
+
+	return nested
+}
+
+// ForEach invokes the passed function with every key/value pair in the
+// bucket. Nested buckets are passed with a nil value. The pairs are not
+// visited in any particular order.
+func (b *archivedBucket) ForEach(f func(k, v []byte) error) error {
+	for k, v := range b.values {
+		if err := f([]byte(k), v); err != nil {
+			return err
+		}
+	}
+
+	for k := range b.buckets {
+		if err := f([]byte(k), nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns the value for the given key.
+func (b *archivedBucket) Get(key []byte) []byte {
+	return b.values[string(key)]
+}
+
+// ReadCursor is not supported by archived buckets and always returns nil.
+func (b *archivedBucket) ReadCursor() walletdb.ReadCursor {
+	return nil
+}
+
+// Tx always returns nil, as archived buckets don't belong to a database
+// transaction.
+func (b *archivedBucket) Tx() walletdb.ReadTx {
+	return nil
+}
@@ -0,0 +1,150 @@
+package channeldb
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/require"
+)
+
+// TestArchiveHistoricalChannel tests that the historical state of fully closed
+// channels can be written to an archive, read back and removed from the
+// database.
+func TestArchiveHistoricalChannel(t *testing.T) {
+	t.Parallel()
+
+	fullDB, err := MakeTestDB(t)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+
+	// Without any closed channels, there is nothing to archive.
+	summaries, err := cdb.FetchArchivableChannels(1000)
+	require.NoError(t, err)
+	require.Empty(t, summaries)
+
+	channel := createTestChannel(t, cdb, openChannelOption())
+	summary := &ChannelCloseSummary{
+		ChanPoint:      channel.FundingOutpoint,
+		RemotePub:      channel.IdentityPub,
+		CloseHeight:    100,
+		SettledBalance: btcutil.Amount(500),
+		CloseType:      RemoteForceClose,
+		IsPending:      true,
+	}
+	require.NoError(t, channel.CloseChannel(summary))
+
+	// A channel that isn't fully resolved yet must not be archived.
+	summaries, err = cdb.FetchArchivableChannels(1000)
+	require.NoError(t, err)
+	require.Empty(t, summaries)
+
+	err = cdb.PruneHistoricalChannel(&channel.FundingOutpoint)
+	require.Error(t, err)
+
+	require.NoError(t, cdb.MarkChanFullyClosed(&channel.FundingOutpoint))
+	summary.IsPending = false
+
+	// Channels that were closed above the given height are skipped.
+	summaries, err = cdb.FetchArchivableChannels(99)
+	require.NoError(t, err)
+	require.Empty(t, summaries)
+
+	summaries, err = cdb.FetchArchivableChannels(100)
+	require.NoError(t, err)
+	require.Equal(t, []*ChannelCloseSummary{summary}, summaries)
+
+	// Write the channel to an archive twice and read it back.
+	var archive bytes.Buffer
+	for i := 0; i < 2; i++ {
+		err := cdb.WriteArchivedChannel(
+			&archive, &channel.FundingOutpoint,
+		)
+		require.NoError(t, err)
+	}
+
+	histChannel, err := cdb.FetchHistoricalChannel(
+		&channel.FundingOutpoint,
+	)
+	require.NoError(t, err)
+	histChannel.Db = nil
+
+	for i := 0; i < 2; i++ {
+		archived, err := ReadArchivedChannel(&archive)
+		require.NoError(t, err)
+		require.Equal(t, summary, archived.Summary)
+		require.Equal(t, histChannel, archived.Channel)
+	}
+
+	_, err = ReadArchivedChannel(&archive)
+	require.ErrorIs(t, err, io.EOF)
+
+	// Once the historical state is pruned, the channel is no longer
+	// returned for archival, but its close summary is kept.
+	require.NoError(t, cdb.PruneHistoricalChannel(&channel.FundingOutpoint))
+
+	summaries, err = cdb.FetchArchivableChannels(1000)
+	require.NoError(t, err)
+	require.Empty(t, summaries)
+
+	_, err = cdb.FetchClosedChannel(&channel.FundingOutpoint)
+	require.NoError(t, err)
+
+	// The static info and final balances of the channel can still be
+	// fetched, but its commitment transactions, HTLCs and revocation
+	// state are gone.
+	pruned, err := cdb.FetchHistoricalChannel(&channel.FundingOutpoint)
+	require.NoError(t, err)
+	require.True(t, pruned.HasChanStatus(ChanStatusPruned))
+	require.Equal(t, histChannel.IsInitiator, pruned.IsInitiator)
+	require.Equal(t, histChannel.ChanType, pruned.ChanType)
+	require.Equal(t, histChannel.ShortChannelID, pruned.ShortChannelID)
+	require.Equal(t, histChannel.Capacity, pruned.Capacity)
+
+	for _, commits := range [][2]ChannelCommitment{
+		{histChannel.LocalCommitment, pruned.LocalCommitment},
+		{histChannel.RemoteCommitment, pruned.RemoteCommitment},
+	} {
+		full, summary := commits[0], commits[1]
+		require.Equal(t, full.CommitHeight, summary.CommitHeight)
+		require.Equal(t, full.LocalBalance, summary.LocalBalance)
+		require.Equal(t, full.RemoteBalance, summary.RemoteBalance)
+		require.Equal(t, full.CommitFee, summary.CommitFee)
+		require.Equal(t, full.FeePerKw, summary.FeePerKw)
+		require.Nil(t, summary.CommitTx)
+		require.Nil(t, summary.CommitSig)
+		require.Empty(t, summary.Htlcs)
+	}
+	require.Nil(t, pruned.RevocationProducer)
+	require.Nil(t, pruned.RevocationStore)
+
+	// Pruning a channel again is a no-op.
+	require.NoError(t, cdb.PruneHistoricalChannel(&channel.FundingOutpoint))
+}
+
+// TestReadArchivedChannelTruncated tests that a truncated archive record is
+// reported as such.
+func TestReadArchivedChannelTruncated(t *testing.T) {
+	t.Parallel()
+
+	fullDB, err := MakeTestDB(t)
+	require.NoError(t, err, "unable to make test database")
+
+	cdb := fullDB.ChannelStateDB()
+
+	channel := createTestChannel(t, cdb, openChannelOption())
+	require.NoError(t, channel.CloseChannel(&ChannelCloseSummary{
+		ChanPoint: channel.FundingOutpoint,
+		RemotePub: channel.IdentityPub,
+	}))
+
+	var archive bytes.Buffer
+	err = cdb.WriteArchivedChannel(&archive, &channel.FundingOutpoint)
+	require.NoError(t, err)
+
+	truncated := archive.Bytes()[:archive.Len()-1]
+	_, err = ReadArchivedChannel(bytes.NewReader(truncated))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,708 @@
+package channeldb
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+var (
+	// closeRecordKey is the key under which the close record of a channel
+	// is stored. It lives in the channel's bucket in the close summaries
+	// bucket, next to the resolvers bucket.
+	// [closeSummaryBucket]
+	//	[chainHashBucket]
+	//		[channelBucket]
+	//			closeRecordKey -> CloseRecord
+	closeRecordKey = []byte("close-record")
+
+	// ErrCloseRecordNotFound is returned when no close record has been
+	// stored for a channel.
+	ErrCloseRecordNotFound = errors.New("close record not found")
+)
+
+const (
+	// maxCloseOutputSize is the maximum size of a serialized close output
+	// that we will read from disk.
+	maxCloseOutputSize = 1024
+
+	recordRemotePubType         tlv.Type = 1
+	recordCapacityType          tlv.Type = 2
+	recordClosingTxIDType       tlv.Type = 3
+	recordCloseHeightType       tlv.Type = 4
+	recordCloseTypeType         tlv.Type = 5
+	recordInitiatorType         tlv.Type = 6
+	recordReasonType            tlv.Type = 7
+	recordLastLocalBalanceType  tlv.Type = 8
+	recordDirectBalanceType     tlv.Type = 9
+	recordTimeLockedBalanceType tlv.Type = 10
+	recordCloseFeeType          tlv.Type = 11
+	recordAnchorFeeType         tlv.Type = 12
+	recordOutputsType           tlv.Type = 13
+	recordResolvedType          tlv.Type = 14
+
+	outputOutPointType        tlv.Type = 1
+	outputResolverType        tlv.Type = 2
+	outputOutcomeType         tlv.Type = 3
+	outputAmountType          tlv.Type = 4
+	outputTimeLockedType      tlv.Type = 5
+	outputSweepFeeType        tlv.Type = 6
+	outputRecoveredType       tlv.Type = 7
+	outputSweepTxIDType       tlv.Type = 8
+	outputRecoveredHeightType tlv.Type = 9
+	outputRecoveredTimeType   tlv.Type = 10
+)
+
+// CloseInitiator indicates which party initiated the close of a channel.
+type CloseInitiator uint8
+
+const (
+	// CloseInitiatorUnknown is used when we do not know who initiated the
+	// close, for example for channels closed before we started recording
+	// the close initiator.
+	CloseInitiatorUnknown CloseInitiator = 0
+
+	// CloseInitiatorLocal indicates that we initiated the close.
+	CloseInitiatorLocal CloseInitiator = 1
+
+	// CloseInitiatorRemote indicates that the remote party initiated the
+	// close.
+	CloseInitiatorRemote CloseInitiator = 2
+
+	// CloseInitiatorBoth indicates that both parties initiated the close,
+	// which is possible for cooperative closes.
+	CloseInitiatorBoth CloseInitiator = 3
+)
+
+// String returns a human readable string for the close initiator.
+func (c CloseInitiator) String() string {
+	switch c {
+	case CloseInitiatorLocal:
+		return "local"
+
+	case CloseInitiatorRemote:
+		return "remote"
+
+	case CloseInitiatorBoth:
+		return "both"
+
+	default:
+		return "unknown"
+	}
+}
+
+// CloseReason describes why a channel was closed.
+type CloseReason uint8
+
+const (
+	// CloseReasonUnknown is used when the reason for the close was not
+	// recorded.
+	CloseReasonUnknown CloseReason = 0
+
+	// CloseReasonCooperative indicates that the channel was closed
+	// cooperatively.
+	CloseReasonCooperative CloseReason = 1
+
+	// CloseReasonForceCloseRequest indicates that we force closed the
+	// channel because it was requested, either by the user or by a
+	// subsystem such as the link.
+	CloseReasonForceCloseRequest CloseReason = 2
+
+	// CloseReasonHtlcOnChain indicates that we force closed the channel to
+	// resolve a htlc on chain before it expired.
+	CloseReasonHtlcOnChain CloseReason = 3
+
+	// CloseReasonRemoteForceClose indicates that the remote party broadcast
+	// their commitment transaction.
+	CloseReasonRemoteForceClose CloseReason = 4
+
+	// CloseReasonBreach indicates that the remote party broadcast a revoked
+	// commitment transaction.
+	CloseReasonBreach CloseReason = 5
+
+	// CloseReasonFundingCanceled indicates that the channel never confirmed
+	// and the funding flow was canceled.
+	CloseReasonFundingCanceled CloseReason = 6
+
+	// CloseReasonAbandoned indicates that the channel was abandoned.
+	CloseReasonAbandoned CloseReason = 7
+)
+
+// String returns a human readable string for the close reason.
+func (c CloseReason) String() string {
+	switch c {
+	case CloseReasonCooperative:
+		return "cooperative"
+
+	case CloseReasonForceCloseRequest:
+		return "force close request"
+
+	case CloseReasonHtlcOnChain:
+		return "htlc on chain"
+
+	case CloseReasonRemoteForceClose:
+		return "remote force close"
+
+	case CloseReasonBreach:
+		return "breach"
+
+	case CloseReasonFundingCanceled:
+		return "funding canceled"
+
+	case CloseReasonAbandoned:
+		return "abandoned"
+
+	default:
+		return "unknown"
+	}
+}
+
+// CloseOutput accounts for a single output of a closing transaction that the
+// contract court resolved, following it through both stages of resolution if
+// required.
+type CloseOutput struct {
+	// OutPoint is the output on the closing transaction.
+	OutPoint wire.OutPoint
+
+	// ResolverType is the type of the output.
+	ResolverType ResolverType
+
+	// ResolverOutcome is the final outcome of the output. Two stage htlcs
+	// report the outcome of their second stage once it is known.
+	ResolverOutcome ResolverOutcome
+
+	// Amount is the value of the output on the closing transaction.
+	Amount btcutil.Amount
+
+	// TimeLocked indicates whether the output was encumbered by a relative
+	// or absolute time lock before we could claim it.
+	TimeLocked bool
+
+	// SweepFee is the share of on chain fees we paid to claim the output,
+	// including the fee of a first stage htlc transaction.
+	SweepFee btcutil.Amount
+
+	// Recovered is the amount that made its way back into our wallet as a
+	// result of this output, net of fees. It may be negative if we paid
+	// fees for an output that we did not recover, such as an anchor.
+	Recovered btcutil.Amount
+
+	// SweepTxID is the transaction that finally claimed the output, if
+	// any.
+	SweepTxID *chainhash.Hash
+
+	// RecoveredHeight is the height at which the output was finally
+	// claimed. It is zero if the output has not been claimed by us.
+	RecoveredHeight uint32
+
+	// RecoveredTime is the time at which the output was finally claimed.
+	// It is the zero time if the output has not been claimed by us.
+	RecoveredTime time.Time
+}
+
+// CloseRecord provides a full account of the financial outcome of a channel
+// close. It is built up by the contract court as resolvers finish, and is
+// marked resolved once all of the channel's contracts are resolved.
+type CloseRecord struct {
+	// ChanPoint is the outpoint of the closed channel.
+	ChanPoint wire.OutPoint
+
+	// RemotePub is the identity public key of the remote party.
+	RemotePub *btcec.PublicKey
+
+	// Capacity is the capacity of the channel.
+	Capacity btcutil.Amount
+
+	// ClosingTXID is the txid of the transaction that closed the channel.
+	ClosingTXID chainhash.Hash
+
+	// CloseHeight is the height at which the closing transaction
+	// confirmed.
+	CloseHeight uint32
+
+	// CloseType is the type of the close.
+	CloseType ClosureType
+
+	// Initiator is the party that initiated the close.
+	Initiator CloseInitiator
+
+	// Reason is the reason the channel was closed.
+	Reason CloseReason
+
+	// LastLocalBalance is our balance in the last local commitment before
+	// the channel was closed.
+	LastLocalBalance btcutil.Amount
+
+	// DirectBalance is the part of our balance that was paid to our wallet
+	// directly by the closing transaction, without us having to sweep it.
+	DirectBalance btcutil.Amount
+
+	// TimeLockedBalance is the amount that was time locked at close.
+	TimeLockedBalance btcutil.Amount
+
+	// CloseFee is the fee of the closing transaction that we paid. It is
+	// zero if the remote party funded the channel.
+	CloseFee btcutil.Amount
+
+	// AnchorFee is the amount of fees we paid to sweep anchors in order to
+	// bump the closing transaction.
+	AnchorFee btcutil.Amount
+
+	// Outputs holds the account of each output that was resolved on chain.
+	Outputs []*CloseOutput
+
+	// Resolved indicates whether all of the channel's contracts have been
+	// resolved, meaning that the record is final.
+	Resolved bool
+}
+
+// SweepFees returns the total fees we paid to sweep outputs of the closing
+// transaction, including anchors.
+func (c *CloseRecord) SweepFees() btcutil.Amount {
+	var total btcutil.Amount
+	for _, output := range c.Outputs {
+		total += output.SweepFee
+	}
+
+	return total
+}
+
+// Recovered returns the total amount that made its way back into our wallet
+// as a result of the close, net of all fees.
+func (c *CloseRecord) Recovered() btcutil.Amount {
+	total := c.DirectBalance
+	for _, output := range c.Outputs {
+		total += output.Recovered
+	}
+
+	return total
+}
+
+// NetChange returns our gain (positive) or loss (negative) as a result of the
+// close when compared to our last local balance.
+func (c *CloseRecord) NetChange() btcutil.Amount {
+	return c.Recovered() - c.LastLocalBalance
+}
+
+// PutCloseRecord writes the close record for a channel to disk, replacing any
+// record that was previously stored.
+func (d *DB) PutCloseRecord(chainHash chainhash.Hash,
+	record *CloseRecord) error {
+
+	var valueBuf bytes.Buffer
+	if err := serializeCloseRecord(&valueBuf, record); err != nil {
+		return err
+	}
+
+	return kvdb.Update(d, func(tx kvdb.RwTx) error {
+		channelBucket, err := fetchReportWriteBucket(
+			tx, chainHash, &record.ChanPoint,
+		)
+		if err != nil {
+			return err
+		}
+
+		return channelBucket.Put(closeRecordKey, valueBuf.Bytes())
+	}, func() {})
+}
+
+// FetchCloseRecord fetches the close record for a channel. If no record has
+// been stored, ErrCloseRecordNotFound is returned.
+func (d *DB) FetchCloseRecord(chainHash chainhash.Hash,
+	chanPoint *wire.OutPoint) (*CloseRecord, error) {
+
+	var record *CloseRecord
+	if err := kvdb.View(d, func(tx kvdb.RTx) error {
+		chanBucket, err := fetchReportReadBucket(
+			tx, chainHash, chanPoint,
+		)
+		switch {
+		case errors.Is(err, ErrNoChainHashBucket),
+			errors.Is(err, ErrNoChannelSummaries):
+
+			return ErrCloseRecordNotFound
+
+		case err != nil:
+			return err
+		}
+
+		value := chanBucket.Get(closeRecordKey)
+		if value == nil {
+			return ErrCloseRecordNotFound
+		}
+
+		record, err = deserializeCloseRecord(bytes.NewReader(value))
+		if err != nil {
+			return err
+		}
+		record.ChanPoint = *chanPoint
+
+		return nil
+	}, func() {
+		record = nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// FetchCloseRecords returns all of the close records stored for a chain.
+func (d *DB) FetchCloseRecords(chainHash chainhash.Hash) ([]*CloseRecord,
+	error) {
+
+	var records []*CloseRecord
+	if err := kvdb.View(d, func(tx kvdb.RTx) error {
+		closeBucket := tx.ReadBucket(closeSummaryBucket)
+		if closeBucket == nil {
+			return nil
+		}
+
+		chainHashBucket := closeBucket.NestedReadBucket(chainHash[:])
+		if chainHashBucket == nil {
+			return nil
+		}
+
+		return chainHashBucket.ForEach(func(k, v []byte) error {
+			// Channels are stored as nested buckets, so we skip
+			// any plain key value pairs.
+			if v != nil {
+				return nil
+			}
+
+			chanBucket := chainHashBucket.NestedReadBucket(k)
+			if chanBucket == nil {
+				return nil
+			}
+
+			value := chanBucket.Get(closeRecordKey)
+			if value == nil {
+				return nil
+			}
+
+			record, err := deserializeCloseRecord(
+				bytes.NewReader(value),
+			)
+			if err != nil {
+				return err
+			}
+
+			err = readOutpoint(
+				bytes.NewReader(k), &record.ChanPoint,
+			)
+			if err != nil {
+				return err
+			}
+
+			records = append(records, record)
+
+			return nil
+		})
+	}, func() {
+		records = nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// serializeCloseRecord writes a close record to a TLV stream. The channel
+// point is not included because records are keyed by their channel point.
+func serializeCloseRecord(w io.Writer, record *CloseRecord) error {
+	var (
+		capacity          = uint64(record.Capacity)
+		closingTxID       = [32]byte(record.ClosingTXID)
+		closeHeight       = record.CloseHeight
+		closeType         = uint8(record.CloseType)
+		initiator         = uint8(record.Initiator)
+		reason            = uint8(record.Reason)
+		lastLocalBalance  = uint64(record.LastLocalBalance)
+		directBalance     = uint64(record.DirectBalance)
+		timeLockedBalance = uint64(record.TimeLockedBalance)
+		closeFee          = uint64(record.CloseFee)
+		anchorFee         = uint64(record.AnchorFee)
+		resolved          uint8
+	)
+
+	if record.Resolved {
+		resolved = 1
+	}
+
+	var outputBuf bytes.Buffer
+	for _, output := range record.Outputs {
+		if err := serializeCloseOutput(&outputBuf, output); err != nil {
+			return err
+		}
+	}
+	outputs := outputBuf.Bytes()
+
+	var records []tlv.Record
+	if record.RemotePub != nil {
+		remotePub := record.RemotePub.SerializeCompressed()
+		records = append(records, tlv.MakePrimitiveRecord(
+			recordRemotePubType, &remotePub,
+		))
+	}
+
+	records = append(records,
+		tlv.MakePrimitiveRecord(recordCapacityType, &capacity),
+		tlv.MakePrimitiveRecord(recordClosingTxIDType, &closingTxID),
+		tlv.MakePrimitiveRecord(recordCloseHeightType, &closeHeight),
+		tlv.MakePrimitiveRecord(recordCloseTypeType, &closeType),
+		tlv.MakePrimitiveRecord(recordInitiatorType, &initiator),
+		tlv.MakePrimitiveRecord(recordReasonType, &reason),
+		tlv.MakePrimitiveRecord(
+			recordLastLocalBalanceType, &lastLocalBalance,
+		),
+		tlv.MakePrimitiveRecord(
+			recordDirectBalanceType, &directBalance,
+		),
+		tlv.MakePrimitiveRecord(
+			recordTimeLockedBalanceType, &timeLockedBalance,
+		),
+		tlv.MakePrimitiveRecord(recordCloseFeeType, &closeFee),
+		tlv.MakePrimitiveRecord(recordAnchorFeeType, &anchorFee),
+		tlv.MakePrimitiveRecord(recordOutputsType, &outputs),
+		tlv.MakePrimitiveRecord(recordResolvedType, &resolved),
+	)
+
+	tlvStream, err := tlv.NewStream(records...)
+	if err != nil {
+		return err
+	}
+
+	return tlvStream.Encode(w)
+}
+
+// deserializeCloseRecord reads a close record from a TLV stream. The channel
+// point of the record is not set.
+func deserializeCloseRecord(r io.Reader) (*CloseRecord, error) {
+	var (
+		remotePub         []byte
+		capacity          uint64
+		closingTxID       [32]byte
+		closeHeight       uint32
+		closeType         uint8
+		initiator         uint8
+		reason            uint8
+		lastLocalBalance  uint64
+		directBalance     uint64
+		timeLockedBalance uint64
+		closeFee          uint64
+		anchorFee         uint64
+		outputs           []byte
+		resolved          uint8
+	)
+
+	tlvStream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(recordRemotePubType, &remotePub),
+		tlv.MakePrimitiveRecord(recordCapacityType, &capacity),
+		tlv.MakePrimitiveRecord(recordClosingTxIDType, &closingTxID),
+		tlv.MakePrimitiveRecord(recordCloseHeightType, &closeHeight),
+		tlv.MakePrimitiveRecord(recordCloseTypeType, &closeType),
+		tlv.MakePrimitiveRecord(recordInitiatorType, &initiator),
+		tlv.MakePrimitiveRecord(recordReasonType, &reason),
+		tlv.MakePrimitiveRecord(
+			recordLastLocalBalanceType, &lastLocalBalance,
+		),
+		tlv.MakePrimitiveRecord(
+			recordDirectBalanceType, &directBalance,
+		),
+		tlv.MakePrimitiveRecord(
+			recordTimeLockedBalanceType, &timeLockedBalance,
+		),
+		tlv.MakePrimitiveRecord(recordCloseFeeType, &closeFee),
+		tlv.MakePrimitiveRecord(recordAnchorFeeType, &anchorFee),
+		tlv.MakePrimitiveRecord(recordOutputsType, &outputs),
+		tlv.MakePrimitiveRecord(recordResolvedType, &resolved),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tlvStream.Decode(r); err != nil {
+		return nil, err
+	}
+
+	record := &CloseRecord{
+		Capacity:          btcutil.Amount(capacity),
+		ClosingTXID:       closingTxID,
+		CloseHeight:       closeHeight,
+		CloseType:         ClosureType(closeType),
+		Initiator:         CloseInitiator(initiator),
+		Reason:            CloseReason(reason),
+		LastLocalBalance:  btcutil.Amount(lastLocalBalance),
+		DirectBalance:     btcutil.Amount(directBalance),
+		TimeLockedBalance: btcutil.Amount(timeLockedBalance),
+		CloseFee:          btcutil.Amount(closeFee),
+		AnchorFee:         btcutil.Amount(anchorFee),
+		Resolved:          resolved == 1,
+	}
+
+	if len(remotePub) != 0 {
+		record.RemotePub, err = btcec.ParsePubKey(remotePub)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	outputReader := bytes.NewReader(outputs)
+	for outputReader.Len() > 0 {
+		output, err := deserializeCloseOutput(outputReader)
+		if err != nil {
+			return nil, err
+		}
+
+		record.Outputs = append(record.Outputs, output)
+	}
+
+	return record, nil
+}
+
+// serializeCloseOutput writes a single close output as a length prefixed TLV
+// stream, so that a list of outputs can be stored in a single record.
+func serializeCloseOutput(w io.Writer, output *CloseOutput) error {
+	var (
+		resolver      = uint8(output.ResolverType)
+		outcome       = uint8(output.ResolverOutcome)
+		amount        = uint64(output.Amount)
+		sweepFee      = uint64(output.SweepFee)
+		recovered     = uint64(output.Recovered)
+		recoveredTime uint64
+		timeLocked    uint8
+	)
+
+	var outPointBuf bytes.Buffer
+	if err := writeOutpoint(&outPointBuf, &output.OutPoint); err != nil {
+		return err
+	}
+	outPoint := outPointBuf.Bytes()
+
+	if output.TimeLocked {
+		timeLocked = 1
+	}
+
+	if !output.RecoveredTime.IsZero() {
+		recoveredTime = uint64(output.RecoveredTime.Unix())
+	}
+
+	records := []tlv.Record{
+		tlv.MakePrimitiveRecord(outputOutPointType, &outPoint),
+		tlv.MakePrimitiveRecord(outputResolverType, &resolver),
+		tlv.MakePrimitiveRecord(outputOutcomeType, &outcome),
+		tlv.MakePrimitiveRecord(outputAmountType, &amount),
+		tlv.MakePrimitiveRecord(outputTimeLockedType, &timeLocked),
+		tlv.MakePrimitiveRecord(outputSweepFeeType, &sweepFee),
+		tlv.MakePrimitiveRecord(outputRecoveredType, &recovered),
+	}
+
+	if output.SweepTxID != nil {
+		sweepTxID := [32]byte(*output.SweepTxID)
+		records = append(records, tlv.MakePrimitiveRecord(
+			outputSweepTxIDType, &sweepTxID,
+		))
+	}
+
+	records = append(records,
+		tlv.MakePrimitiveRecord(
+			outputRecoveredHeightType, &output.RecoveredHeight,
+		),
+		tlv.MakePrimitiveRecord(
+			outputRecoveredTimeType, &recoveredTime,
+		),
+	)
+
+	tlvStream, err := tlv.NewStream(records...)
+	if err != nil {
+		return err
+	}
+
+	var streamBuf bytes.Buffer
+	if err := tlvStream.Encode(&streamBuf); err != nil {
+		return err
+	}
+
+	return wire.WriteVarBytes(w, 0, streamBuf.Bytes())
+}
+
+// deserializeCloseOutput reads a single length prefixed close output.
+func deserializeCloseOutput(r io.Reader) (*CloseOutput, error) {
+	stream, err := wire.ReadVarBytes(r, 0, maxCloseOutputSize, "output")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		outPoint        []byte
+		resolver        uint8
+		outcome         uint8
+		amount          uint64
+		timeLocked      uint8
+		sweepFee        uint64
+		recovered       uint64
+		sweepTxID       []byte
+		recoveredHeight uint32
+		recoveredTime   uint64
+	)
+
+	tlvStream, err := tlv.NewStream(
+		tlv.MakePrimitiveRecord(outputOutPointType, &outPoint),
+		tlv.MakePrimitiveRecord(outputResolverType, &resolver),
+		tlv.MakePrimitiveRecord(outputOutcomeType, &outcome),
+		tlv.MakePrimitiveRecord(outputAmountType, &amount),
+		tlv.MakePrimitiveRecord(outputTimeLockedType, &timeLocked),
+		tlv.MakePrimitiveRecord(outputSweepFeeType, &sweepFee),
+		tlv.MakePrimitiveRecord(outputRecoveredType, &recovered),
+		tlv.MakePrimitiveRecord(outputSweepTxIDType, &sweepTxID),
+		tlv.MakePrimitiveRecord(
+			outputRecoveredHeightType, &recoveredHeight,
+		),
+		tlv.MakePrimitiveRecord(
+			outputRecoveredTimeType, &recoveredTime,
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tlvStream.Decode(bytes.NewReader(stream)); err != nil {
+		return nil, err
+	}
+
+	output := &CloseOutput{
+		ResolverType:    ResolverType(resolver),
+		ResolverOutcome: ResolverOutcome(outcome),
+		Amount:          btcutil.Amount(amount),
+		TimeLocked:      timeLocked == 1,
+		SweepFee:        btcutil.Amount(sweepFee),
+		Recovered:       btcutil.Amount(int64(recovered)),
+		RecoveredHeight: recoveredHeight,
+	}
+
+	err = readOutpoint(bytes.NewReader(outPoint), &output.OutPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sweepTxID) != 0 {
+		output.SweepTxID, err = chainhash.NewHash(sweepTxID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if recoveredTime != 0 {
+		output.RecoveredTime = time.Unix(int64(recoveredTime), 0)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,97 @@
+package channeldb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPersistCloseRecord tests the writing and retrieval of close records, and
+// that a record is replaced when it is written again.
+func TestPersistCloseRecord(t *testing.T) {
+	db, err := MakeTestDB(t)
+	require.NoError(t, err)
+
+	// Before anything is written, we expect to get a not found error.
+	_, err = db.FetchCloseRecord(testChainHash, &testChanPoint1)
+	require.ErrorIs(t, err, ErrCloseRecordNotFound)
+
+	records, err := db.FetchCloseRecords(testChainHash)
+	require.NoError(t, err)
+	require.Empty(t, records)
+
+	firstStage := wire.OutPoint{Hash: testChanPoint1.Hash, Index: 2}
+	commitOutpoint := wire.OutPoint{Hash: testChanPoint1.Hash, Index: 3}
+
+	record := &CloseRecord{
+		ChanPoint:         testChanPoint1,
+		RemotePub:         pubKey,
+		Capacity:          100000,
+		ClosingTXID:       testChanPoint1.Hash,
+		CloseHeight:       600,
+		CloseType:         LocalForceClose,
+		Initiator:         CloseInitiatorLocal,
+		Reason:            CloseReasonHtlcOnChain,
+		LastLocalBalance:  60000,
+		TimeLockedBalance: 50000,
+		CloseFee:          1200,
+		AnchorFee:         300,
+		Outputs: []*CloseOutput{
+			{
+				OutPoint:        firstStage,
+				ResolverType:    ResolverTypeOutgoingHtlc,
+				ResolverOutcome: ResolverOutcomeTimeout,
+				Amount:          10000,
+				TimeLocked:      true,
+				SweepFee:        700,
+				Recovered:       9300,
+				SweepTxID:       &testChanPoint1.Hash,
+				RecoveredHeight: 750,
+				RecoveredTime:   time.Unix(1700000000, 0),
+			},
+			{
+				OutPoint:        commitOutpoint,
+				ResolverType:    ResolverTypeAnchor,
+				ResolverOutcome: ResolverOutcomeUnclaimed,
+				Amount:          330,
+				SweepFee:        300,
+				Recovered:       -300,
+			},
+		},
+	}
+
+	require.NoError(t, db.PutCloseRecord(testChainHash, record))
+
+	// A resolver report stored for the same channel should not interfere
+	// with the record.
+	err = db.PutResolverReport(nil, testChainHash, &testChanPoint1,
+		&ResolverReport{
+			OutPoint:        firstStage,
+			Amount:          10000,
+			ResolverType:    ResolverTypeOutgoingHtlc,
+			ResolverOutcome: ResolverOutcomeTimeout,
+		},
+	)
+	require.NoError(t, err)
+
+	fetched, err := db.FetchCloseRecord(testChainHash, &testChanPoint1)
+	require.NoError(t, err)
+	require.Equal(t, record, fetched)
+
+	require.Equal(t, btcutil.Amount(1000), fetched.SweepFees())
+	require.Equal(t, btcutil.Amount(9000), fetched.Recovered())
+	require.Equal(t, btcutil.Amount(-51000), fetched.NetChange())
+
+	// Update the record and make sure it replaces the original one.
+	record.Resolved = true
+	record.DirectBalance = 50000
+	require.NoError(t, db.PutCloseRecord(testChainHash, record))
+
+	records, err = db.FetchCloseRecords(testChainHash)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, record, records[0])
+}
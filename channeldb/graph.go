@@ -22,10 +22,12 @@ import (
 	"github.com/lightningnetwork/lnd/aliasmgr"
 	"github.com/lightningnetwork/lnd/batch"
 	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/fn"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
 )
 
 var (
@@ -163,6 +165,33 @@ const (
 	MaxAllowedExtraOpaqueBytes = 10000
 )
 
+const (
+	// edgeVersionType is the type of the record holding the gossip version
+	// of an edge that was announced with a ChannelAnnouncement2.
+	edgeVersionType tlv.Type = 0
+
+	// edgeSchnorrSigType is the type of the record holding the aggregate
+	// Schnorr signature of an edge that was announced with a
+	// ChannelAnnouncement2.
+	edgeSchnorrSigType tlv.Type = 2
+
+	// edgeMerkleRootType is the type of the record holding the optional
+	// tapscript root of the funding output of a taproot channel.
+	edgeMerkleRootType tlv.Type = 4
+
+	// policyVersionType is the type of the record holding the gossip
+	// version of a policy that was announced with a ChannelUpdate2.
+	policyVersionType tlv.Type = 0
+
+	// policyBlockHeightType is the type of the record holding the block
+	// height of a policy that was announced with a ChannelUpdate2.
+	policyBlockHeightType tlv.Type = 2
+
+	// policyDisableFlagsType is the type of the record holding the
+	// disable flags of a policy that was announced with a ChannelUpdate2.
+	policyDisableFlagsType tlv.Type = 4
+)
+
 // ChannelGraph is a persistent, on-disk graph representation of the Lightning
 // Network. This struct can be used to implement path finding algorithms on top
 // of, and also to update a node's view based on information received from the
@@ -4154,9 +4183,125 @@ func putChanEdgeInfo(edgeIndex kvdb.RwBucket,
 		return err
 	}
 
+	// Taproot channels carry a few more fields, which we append as a TLV
+	// stream so that the serialization of other channels is unchanged.
+	if edgeInfo.Version == lnwire.GossipVersion2 {
+		if err := serializeChanEdgeInfoV2(&b, edgeInfo); err != nil {
+			return err
+		}
+	}
+
 	return edgeIndex.Put(chanID[:], b.Bytes())
 }
 
+// serializeChanEdgeInfoV2 writes the TLV stream holding the fields of an edge
+// that was announced with a ChannelAnnouncement2.
+func serializeChanEdgeInfoV2(w io.Writer,
+	edgeInfo *models.ChannelEdgeInfo) error {
+
+	version := uint8(edgeInfo.Version)
+	records := []tlv.Record{
+		tlv.MakePrimitiveRecord(edgeVersionType, &version),
+	}
+
+	var schnorrSig []byte
+	if edgeInfo.AuthProof != nil &&
+		len(edgeInfo.AuthProof.SchnorrSigBytes) != 0 {
+
+		schnorrSig = edgeInfo.AuthProof.SchnorrSigBytes
+		records = append(records, tlv.MakePrimitiveRecord(
+			edgeSchnorrSigType, &schnorrSig,
+		))
+	}
+
+	var merkleRoot [32]byte
+	edgeInfo.MerkleRootHash.WhenSome(func(root chainhash.Hash) {
+		merkleRoot = root
+		records = append(records, tlv.MakePrimitiveRecord(
+			edgeMerkleRootType, &merkleRoot,
+		))
+	})
+
+	return writeTrailingTLVStream(w, records...)
+}
+
+// deserializeChanEdgeInfoV2 reads the TLV stream that is appended to the
+// serialization of edges that were announced with a ChannelAnnouncement2. If
+// the stream isn't present, the edge is left untouched.
+func deserializeChanEdgeInfoV2(r io.Reader,
+	edgeInfo *models.ChannelEdgeInfo) error {
+
+	var (
+		version    uint8
+		schnorrSig []byte
+		merkleRoot [32]byte
+	)
+	typeMap, err := readTrailingTLVStream(
+		r, tlv.MakePrimitiveRecord(edgeVersionType, &version),
+		tlv.MakePrimitiveRecord(edgeSchnorrSigType, &schnorrSig),
+		tlv.MakePrimitiveRecord(edgeMerkleRootType, &merkleRoot),
+	)
+	if err != nil || typeMap == nil {
+		return err
+	}
+
+	edgeInfo.Version = lnwire.GossipVersion(version)
+
+	if _, ok := typeMap[edgeSchnorrSigType]; ok {
+		edgeInfo.AuthProof = &models.ChannelAuthProof{
+			SchnorrSigBytes: schnorrSig,
+		}
+	}
+
+	if _, ok := typeMap[edgeMerkleRootType]; ok {
+		edgeInfo.MerkleRootHash = fn.Some(chainhash.Hash(merkleRoot))
+	}
+
+	return nil
+}
+
+// writeTrailingTLVStream writes the given records as a length prefixed TLV
+// stream.
+func writeTrailingTLVStream(w io.Writer, records ...tlv.Record) error {
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := stream.Encode(&b); err != nil {
+		return err
+	}
+
+	return wire.WriteVarBytes(w, 0, b.Bytes())
+}
+
+// readTrailingTLVStream reads a length prefixed TLV stream that was written by
+// writeTrailingTLVStream into the given records. If the reader is exhausted,
+// which is the case for data that was written without the stream, a nil type
+// map is returned.
+func readTrailingTLVStream(r io.Reader, records ...tlv.Record) (tlv.TypeMap,
+	error) {
+
+	streamBytes, err := wire.ReadVarBytes(
+		r, 0, MaxAllowedExtraOpaqueBytes, "tlv",
+	)
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		return nil, nil
+
+	case err != nil:
+		return nil, err
+	}
+
+	stream, err := tlv.NewStream(records...)
+	if err != nil {
+		return nil, err
+	}
+
+	return stream.DecodeWithParsedTypes(bytes.NewReader(streamBytes))
+}
+
 func fetchChanEdgeInfo(edgeIndex kvdb.RBucket,
 	chanID []byte) (models.ChannelEdgeInfo, error) {
 
@@ -4243,6 +4388,10 @@ func deserializeChanEdgeInfo(r io.Reader) (models.ChannelEdgeInfo, error) {
 		return models.ChannelEdgeInfo{}, err
 	}
 
+	if err := deserializeChanEdgeInfoV2(r, &edgeInfo); err != nil {
+		return models.ChannelEdgeInfo{}, err
+	}
+
 	return edgeInfo, nil
 }
 
@@ -4492,6 +4641,24 @@ func serializeChanEdgePolicy(w io.Writer, edge *models.ChannelEdgePolicy,
 	if err := wire.WriteVarBytes(w, 0, opaqueBuf.Bytes()); err != nil {
 		return err
 	}
+
+	// The fields of policies announced with a ChannelUpdate2 are appended
+	// as a TLV stream.
+	if edge.Version == lnwire.GossipVersion2 {
+		version := uint8(edge.Version)
+		disableFlags := uint8(edge.DisableFlags)
+
+		return writeTrailingTLVStream(
+			w, tlv.MakePrimitiveRecord(policyVersionType, &version),
+			tlv.MakePrimitiveRecord(
+				policyBlockHeightType, &edge.BlockHeight,
+			),
+			tlv.MakePrimitiveRecord(
+				policyDisableFlagsType, &disableFlags,
+			),
+		)
+	}
+
 	return nil
 }
 
@@ -4572,6 +4739,22 @@ func deserializeChanEdgePolicyRaw(r io.Reader) (*models.ChannelEdgePolicy,
 		return nil, err
 	}
 
+	var version, disableFlags uint8
+	typeMap, err := readTrailingTLVStream(
+		r, tlv.MakePrimitiveRecord(policyVersionType, &version),
+		tlv.MakePrimitiveRecord(
+			policyBlockHeightType, &edge.BlockHeight,
+		),
+		tlv.MakePrimitiveRecord(policyDisableFlagsType, &disableFlags),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if typeMap != nil {
+		edge.Version = lnwire.GossipVersion(version)
+		edge.DisableFlags = lnwire.ChanUpdateDisableFlags(disableFlags)
+	}
+
 	// See if optional fields are present.
 	if edge.MessageFlags.HasMaxHtlc() {
 		// The max_htlc field should be at the beginning of the opaque
@@ -23,6 +23,7 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/fn"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing/route"
@@ -616,6 +617,12 @@ func assertEdgeInfoEqual(t *testing.T, e1 *models.ChannelEdgeInfo,
 		t.Fatalf("extra data doesn't match: %v vs %v",
 			e2.ExtraOpaqueData, e2.ExtraOpaqueData)
 	}
+
+	require.Equal(t, e1.Version, e2.Version)
+	require.Equal(t, e1.MerkleRootHash, e2.MerkleRootHash)
+	require.Equal(
+		t, e1.AuthProof.SchnorrSigBytes, e2.AuthProof.SchnorrSigBytes,
+	)
 }
 
 func createChannelEdge(db kvdb.Backend, node1, node2 *LightningNode) (
@@ -790,6 +797,51 @@ func TestEdgeInfoUpdates(t *testing.T) {
 	assertEdgeInfoEqual(t, dbEdgeInfo, edgeInfo)
 }
 
+// TestTaprootEdgeInfoUpdates asserts that the fields of edges and policies
+// that were announced with the taproot gossip messages survive a round trip
+// through the database.
+func TestTaprootEdgeInfoUpdates(t *testing.T) {
+	t.Parallel()
+
+	graph, err := MakeTestGraph(t)
+	require.NoError(t, err, "unable to make test database")
+
+	node1, err := createTestVertex(graph.db)
+	require.NoError(t, err, "unable to create test node")
+	require.NoError(t, graph.AddLightningNode(node1))
+	node2, err := createTestVertex(graph.db)
+	require.NoError(t, err, "unable to create test node")
+	require.NoError(t, graph.AddLightningNode(node2))
+
+	// Create an edge and turn it into one of a taproot channel that is
+	// authenticated by a single Schnorr signature.
+	edgeInfo, edge1, edge2 := createChannelEdge(graph.db, node1, node2)
+	edgeInfo.Version = lnwire.GossipVersion2
+	edgeInfo.AuthProof = &models.ChannelAuthProof{
+		SchnorrSigBytes: bytes.Repeat([]byte{0x1}, 64),
+	}
+	edgeInfo.MerkleRootHash = fn.Some(chainhash.Hash{0x2})
+
+	for i, edge := range []*models.ChannelEdgePolicy{edge1, edge2} {
+		edge.Version = lnwire.GossipVersion2
+		edge.SigBytes = bytes.Repeat([]byte{0x3}, 64)
+		edge.BlockHeight = uint32(1000 + i)
+		edge.DisableFlags = lnwire.ChanUpdateDisableOutgoing
+	}
+
+	require.NoError(t, graph.AddChannelEdge(edgeInfo))
+	require.NoError(t, graph.UpdateEdgePolicy(edge1))
+	require.NoError(t, graph.UpdateEdgePolicy(edge2))
+
+	dbEdgeInfo, dbEdge1, dbEdge2, err := graph.FetchChannelEdgesByID(
+		edgeInfo.ChannelID,
+	)
+	require.NoError(t, err, "unable to fetch channel by ID")
+	require.NoError(t, compareEdgePolicies(dbEdge1, edge1))
+	require.NoError(t, compareEdgePolicies(dbEdge2, edge2))
+	assertEdgeInfoEqual(t, dbEdgeInfo, edgeInfo)
+}
+
 func assertNodeInCache(t *testing.T, g *ChannelGraph, n *LightningNode,
 	expectedFeatures *lnwire.FeatureVector) {
 
@@ -3645,6 +3697,18 @@ func compareEdgePolicies(a, b *models.ChannelEdgePolicy) error {
 		return fmt.Errorf("ToNode doesn't match: expected %x, got %x",
 			a.ToNode, b.ToNode)
 	}
+	if a.Version != b.Version {
+		return fmt.Errorf("Version doesn't match: expected %v, "+
+			"got %v", a.Version, b.Version)
+	}
+	if a.BlockHeight != b.BlockHeight {
+		return fmt.Errorf("BlockHeight doesn't match: expected %v, "+
+			"got %v", a.BlockHeight, b.BlockHeight)
+	}
+	if a.DisableFlags != b.DisableFlags {
+		return fmt.Errorf("DisableFlags doesn't match: expected %v, "+
+			"got %v", a.DisableFlags, b.DisableFlags)
+	}
 
 	return nil
 }
@@ -9,6 +9,10 @@ import "github.com/btcsuite/btcd/btcec/v2/ecdsa"
 // channel. Each of these signatures signs the following digest: chanID ||
 // nodeID1 || nodeID2 || bitcoinKey1|| bitcoinKey2 || 2-byte-feature-len ||
 // features.
+//
+// Taproot channels that were announced with a ChannelAnnouncement2 are instead
+// authenticated by a single Schnorr signature of the MuSig2 aggregate of the
+// four keys, which is held by SchnorrSigBytes.
 type ChannelAuthProof struct {
 	// nodeSig1 is a cached instance of the first node signature.
 	nodeSig1 *ecdsa.Signature
@@ -37,6 +41,10 @@ type ChannelAuthProof struct {
 	// BitcoinSig2Bytes are the raw bytes of the second bitcoin signature
 	// encoded in DER format.
 	BitcoinSig2Bytes []byte
+
+	// SchnorrSigBytes are the raw bytes of the aggregate Schnorr signature
+	// of a channel that was announced with a ChannelAnnouncement2.
+	SchnorrSigBytes []byte
 }
 
 // Node1Sig is the signature using the identity key of the node that is first
@@ -122,8 +130,13 @@ func (c *ChannelAuthProof) BitcoinSig2() (*ecdsa.Signature, error) {
 }
 
 // IsEmpty check is the authentication proof is empty Proof is empty if at
-// least one of the signatures are equal to nil.
+// least one of the signatures are equal to nil. A proof holding the Schnorr
+// signature of a taproot channel is never empty.
 func (c *ChannelAuthProof) IsEmpty() bool {
+	if len(c.SchnorrSigBytes) != 0 {
+		return false
+	}
+
 	return len(c.NodeSig1Bytes) == 0 ||
 		len(c.NodeSig2Bytes) == 0 ||
 		len(c.BitcoinSig1Bytes) == 0 ||
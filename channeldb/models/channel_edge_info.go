@@ -8,6 +8,8 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/fn"
+	"github.com/lightningnetwork/lnd/lnwire"
 )
 
 // ChannelEdgeInfo represents a fully authenticated channel along with all its
@@ -69,6 +71,16 @@ type ChannelEdgeInfo struct {
 	// and ensure we're able to make upgrades to the network in a forwards
 	// compatible manner.
 	ExtraOpaqueData []byte
+
+	// Version is the version of the gossip protocol the channel was
+	// announced with. Channels announced with GossipVersion2 are taproot
+	// channels that are authenticated by a single Schnorr signature.
+	Version lnwire.GossipVersion
+
+	// MerkleRootHash is the optional tapscript root the internal funding
+	// key of a taproot channel is tweaked with. It is only set for
+	// channels announced with GossipVersion2.
+	MerkleRootHash fn.Option[chainhash.Hash]
 }
 
 // AddNodeKeys is a setter-like method that can be used to replace the set of
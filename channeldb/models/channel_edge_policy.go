@@ -71,6 +71,20 @@ type ChannelEdgePolicy struct {
 	// and ensure we're able to make upgrades to the network in a forwards
 	// compatible manner.
 	ExtraOpaqueData lnwire.ExtraOpaqueData
+
+	// Version is the version of the gossip protocol the policy was
+	// announced with. Policies of GossipVersion2 are announced with a
+	// ChannelUpdate2 and carry a Schnorr signature in SigBytes.
+	Version lnwire.GossipVersion
+
+	// BlockHeight is the block height the ChannelUpdate2 of the policy was
+	// created at, which is used to order the updates of GossipVersion2
+	// policies instead of LastUpdate.
+	BlockHeight uint32
+
+	// DisableFlags signals in which directions a GossipVersion2 policy is
+	// disabled.
+	DisableFlags lnwire.ChanUpdateDisableFlags
 }
 
 // Signature is a channel announcement signature, which is needed for proper
@@ -0,0 +1,167 @@
+package channeldb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+// revocationLogStatsName is the name under which the combined size of the
+// revocation logs of all channels is reported. The revocation logs are nested
+// deep inside the open and closed channel buckets, but are usually the main
+// reason for a large channel database.
+const revocationLogStatsName = "revocation-logs"
+
+// maxNestedBucketStats is the maximum number of nested buckets that are
+// reported for each top level bucket. Some buckets, like the payments bucket,
+// contain a nested bucket per entry, so only the largest ones are reported.
+const maxNestedBucketStats = 10
+
+// BucketStats describes the content of a bucket, including all of its nested
+// buckets.
+type BucketStats struct {
+	// Name is the name of the bucket.
+	Name string
+
+	// NumBuckets is the number of nested buckets.
+	NumBuckets uint64
+
+	// NumKeys is the number of key/value pairs.
+	NumKeys uint64
+
+	// NumBytes is the total size of all keys and values.
+	NumBytes uint64
+
+	// Nested holds the stats of the largest buckets nested directly in
+	// this bucket, sorted by size in descending order. It is only set for
+	// top level buckets.
+	Nested []BucketStats
+}
+
+// add adds the stats of a nested bucket to the stats of its parent.
+func (s *BucketStats) add(other *BucketStats) {
+	s.NumBuckets += other.NumBuckets
+	s.NumKeys += other.NumKeys
+	s.NumBytes += other.NumBytes
+}
+
+// bucketName returns a printable name for the given bucket key. Keys that
+// aren't printable, e.g. channel points or payment hashes, are hex encoded.
+func bucketName(key []byte) string {
+	if !utf8.Valid(key) {
+		return hex.EncodeToString(key)
+	}
+
+	for _, r := range string(key) {
+		if !unicode.IsPrint(r) {
+			return hex.EncodeToString(key)
+		}
+	}
+
+	return string(key)
+}
+
+// sortStats sorts the given stats by size in descending order.
+func sortStats(stats []BucketStats) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].NumBytes > stats[j].NumBytes
+	})
+}
+
+// bucketStats walks the given bucket and all of its nested buckets. The stats
+// of every revocation log that is found on the way are also added to revLog.
+// If withNested is set, the stats of the largest buckets nested directly in
+// the bucket are returned as well.
+func bucketStats(bucket kvdb.RBucket, revLog *BucketStats,
+	withNested bool) *BucketStats {
+
+	stats := &BucketStats{}
+
+	cursor := bucket.ReadCursor()
+	for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+		stats.NumBytes += uint64(len(k))
+
+		nested := bucket.NestedReadBucket(k)
+		if v != nil || nested == nil {
+			stats.NumKeys++
+			stats.NumBytes += uint64(len(v))
+
+			continue
+		}
+
+		nestedStats := bucketStats(nested, revLog, false)
+		stats.NumBuckets++
+		stats.add(nestedStats)
+
+		if withNested {
+			nestedStats.Name = bucketName(k)
+			stats.Nested = append(stats.Nested, *nestedStats)
+		}
+
+		if bytes.Equal(k, revocationLogBucket) ||
+			bytes.Equal(k, revocationLogBucketDeprecated) {
+
+			revLog.NumBuckets++
+			revLog.add(nestedStats)
+		}
+	}
+
+	sortStats(stats.Nested)
+	if len(stats.Nested) > maxNestedBucketStats {
+		stats.Nested = stats.Nested[:maxNestedBucketStats]
+	}
+
+	return stats
+}
+
+// BucketStats walks the whole database and returns the size of each of its top
+// level buckets. See BackendBucketStats for details.
+func (d *DB) BucketStats() ([]BucketStats, error) {
+	return BackendBucketStats(d)
+}
+
+// BackendBucketStats walks the whole database backend and returns the size of
+// each of its top level buckets, sorted by size in descending order, together
+// with their largest nested buckets. If any revocation logs are found, their
+// combined size is reported as an additional entry at the end. Since every
+// key and value is visited, this can take a while for large databases.
+func BackendBucketStats(backend kvdb.Backend) ([]BucketStats, error) {
+	var stats []BucketStats
+	err := kvdb.View(backend, func(tx kvdb.RTx) error {
+		revLog := &BucketStats{Name: revocationLogStatsName}
+
+		err := tx.ForEachBucket(func(key []byte) error {
+			bucket := tx.ReadBucket(key)
+			if bucket == nil {
+				return nil
+			}
+
+			bucketStats := bucketStats(bucket, revLog, true)
+			bucketStats.Name = bucketName(key)
+			stats = append(stats, *bucketStats)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		sortStats(stats)
+		if revLog.NumBuckets > 0 {
+			stats = append(stats, *revLog)
+		}
+
+		return nil
+	}, func() {
+		stats = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,111 @@
+package channeldb
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBucketStats tests that the size of the top level buckets, their nested
+// buckets and the combined size of all revocation logs are reported
+// correctly.
+func TestBucketStats(t *testing.T) {
+	t.Parallel()
+
+	cdb, err := MakeTestDB(t)
+	require.NoError(t, err)
+
+	before, err := cdb.BucketStats()
+	require.NoError(t, err)
+
+	// As there are no revocation logs yet, they aren't reported.
+	for _, bucket := range before {
+		require.NotEqual(t, revocationLogStatsName, bucket.Name)
+	}
+
+	// We now add two channels with a revocation log each, one using the
+	// current and one using the deprecated format.
+	err = kvdb.Update(cdb, func(tx kvdb.RwTx) error {
+		openChans := tx.ReadWriteBucket(openChannelBucket)
+		nodeBucket, err := openChans.CreateBucket([]byte("node"))
+		if err != nil {
+			return err
+		}
+
+		chainBucket, err := nodeBucket.CreateBucket([]byte("chain"))
+		if err != nil {
+			return err
+		}
+
+		for i, logKey := range [][]byte{
+			revocationLogBucket, revocationLogBucketDeprecated,
+		} {
+			chanBucket, err := chainBucket.CreateBucket([]byte{
+				byte(i),
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := chanBucket.Put([]byte("k"), []byte("v")); err != nil {
+				return err
+			}
+
+			logBucket, err := chanBucket.CreateBucket(logKey)
+			if err != nil {
+				return err
+			}
+
+			err = logBucket.Put([]byte("height"), make([]byte, 100))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func() {})
+	require.NoError(t, err)
+
+	stats, err := cdb.BucketStats()
+	require.NoError(t, err)
+	require.Len(t, stats, len(before)+1)
+
+	for i := 1; i < len(stats)-1; i++ {
+		require.GreaterOrEqual(t, stats[i-1].NumBytes, stats[i].NumBytes)
+	}
+
+	// The revocation logs are reported last.
+	revLogStats := stats[len(stats)-1]
+	require.Equal(t, BucketStats{
+		Name:       revocationLogStatsName,
+		NumBuckets: 2,
+		NumKeys:    2,
+		NumBytes:   2 * (6 + 100),
+	}, revLogStats)
+
+	var openChanStats *BucketStats
+	for i := range stats {
+		if stats[i].Name == string(openChannelBucket) {
+			openChanStats = &stats[i]
+		}
+	}
+	require.NotNil(t, openChanStats)
+	require.EqualValues(t, 6, openChanStats.NumBuckets)
+	require.EqualValues(t, 4, openChanStats.NumKeys)
+	require.EqualValues(
+		t, len("node")+len("chain")+2+2*2+
+			len(revocationLogBucket)+
+			len(revocationLogBucketDeprecated)+2*(6+100),
+		openChanStats.NumBytes,
+	)
+	// The node bucket is reported as the only nested bucket, including
+	// everything below it.
+	require.Equal(t, []BucketStats{{
+		Name:       "node",
+		NumBuckets: 5,
+		NumKeys:    4,
+		NumBytes:   openChanStats.NumBytes - uint64(len("node")),
+	}}, openChanStats.Nested)
+	require.Equal(t, "0102", bucketName([]byte{1, 2}))
+}
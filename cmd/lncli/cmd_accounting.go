@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/urfave/cli"
+)
+
+var accountingReportCommand = cli.Command{
+	Name:     "accountingreport",
+	Category: "On-chain",
+	Usage: "List every change to the balance of the node, classified " +
+		"by what caused it.",
+	Description: `
+	List every on-chain and off-chain change to the balance of the node in
+	a time range: wallet sends and receives, channel opens and closes,
+	sweeps, anchor sweeps, htlc resolutions, justice transactions,
+	consolidations, payments, invoices and forwarding fees.
+
+	The start and end times are expressed in seconds since the Unix epoch.
+	Alternatively negative time ranges can be used, e.g. "-3d". Supports
+	s(seconds), m(minutes), h(ours), d(ays), w(eeks), M(onths), y(ears).
+	If --start_time isn't provided, all entries since the creation of the
+	node are listed. If --end_time isn't provided, all entries up to now
+	are listed. Large reports can be fetched page by page with
+	--index_offset and --max_entries, the last_index_offset of a response
+	is the index_offset of the next page.
+
+	Amounts and fees are expressed in millisatoshis. Negative amounts debit
+	the node and include the fee that was paid. The report is printed as
+	JSON by default, --format=csv prints it as CSV instead.
+	`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "start_time",
+			Usage: "the starting time for the report " +
+				`as unix timestamp or relative e.g. "-1w"`,
+		},
+		cli.StringFlag{
+			Name: "end_time",
+			Usage: "the end time for the report " +
+				`as unix timestamp or relative e.g. "-1w"`,
+		},
+		cli.Uint64Flag{
+			Name:  "index_offset",
+			Usage: "the number of entries in the time range to skip",
+		},
+		cli.Uint64Flag{
+			Name: "max_entries",
+			Usage: "the maximum number of entries to return, all " +
+				"entries are returned if not set",
+		},
+		cli.StringFlag{
+			Name:  "format",
+			Usage: "the output format of the report, json or csv",
+			Value: "json",
+		},
+	},
+	Action: actionDecorator(accountingReport),
+}
+
+func accountingReport(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	format := ctx.String("format")
+	if format != "json" && format != "csv" {
+		return fmt.Errorf("unknown format %v, must be json or csv",
+			format)
+	}
+
+	var (
+		req = &lnrpc.ListAccountingEntriesRequest{
+			IndexOffset: ctx.Uint64("index_offset"),
+			MaxEntries:  ctx.Uint64("max_entries"),
+		}
+		now = time.Now()
+		err error
+	)
+	if ctx.IsSet("start_time") {
+		req.StartTime, err = parseTime(ctx.String("start_time"), now)
+		if err != nil {
+			return fmt.Errorf("unable to decode start_time: %w",
+				err)
+		}
+	}
+	if ctx.IsSet("end_time") {
+		req.EndTime, err = parseTime(ctx.String("end_time"), now)
+		if err != nil {
+			return fmt.Errorf("unable to decode end_time: %w", err)
+		}
+	}
+
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.ListAccountingEntries(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		printRespJSON(resp)
+		return nil
+	}
+
+	return writeAccountingCSV(resp.Entries)
+}
+
+// writeAccountingCSV writes the accounting entries to stdout as CSV.
+func writeAccountingCSV(entries []*lnrpc.AccountingEntry) error {
+	w := csv.NewWriter(os.Stdout)
+
+	err := w.Write([]string{
+		"timestamp", "type", "on_chain", "txid", "block_height",
+		"reference", "amount_msat", "fee_msat", "label",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		timestamp := time.Unix(entry.Timestamp, 0).UTC()
+		err := w.Write([]string{
+			timestamp.Format(time.RFC3339),
+			strings.ToLower(entry.Type.String()),
+			strconv.FormatBool(entry.OnChain),
+			entry.Txid,
+			strconv.FormatInt(int64(entry.BlockHeight), 10),
+			entry.Reference,
+			strconv.FormatInt(entry.AmountMsat, 10),
+			strconv.FormatInt(entry.FeeMsat, 10),
+			entry.Label,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+
+	return w.Error()
+}
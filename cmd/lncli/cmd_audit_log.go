@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/urfave/cli"
+)
+
+var auditLogCommand = cli.Command{
+	Name:     "auditlog",
+	Category: "Macaroons",
+	Usage:    "Query the audit log of mutating RPC calls.",
+	Description: `
+	The audit log records every call of a mutating RPC together with the
+	root key ID and caveats of the macaroon it was made with, the IP address
+	of the client, a redacted summary of the request, the result and the
+	latency. It must be enabled with the auditlog.enable option of lnd.
+	`,
+	Subcommands: []cli.Command{
+		auditLogListCommand,
+		auditLogSubscribeCommand,
+	},
+}
+
+var auditLogListCommand = cli.Command{
+	Name:  "list",
+	Usage: "List the entries of the audit log.",
+	Description: `
+	List the entries of the audit log, oldest first. The start and end
+	times are expressed in seconds since the Unix epoch. Alternatively
+	negative time ranges can be used, e.g. "-3d". Supports s(seconds),
+	m(minutes), h(ours), d(ays), w(eeks), M(onths), y(ears).
+	`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "start_time",
+			Usage: "the starting time for the query " +
+				`as unix timestamp or relative e.g. "-1w"`,
+		},
+		cli.StringFlag{
+			Name: "end_time",
+			Usage: "the end time for the query " +
+				`as unix timestamp or relative e.g. "-1w"`,
+		},
+		cli.StringFlag{
+			Name: "method",
+			Usage: "only list calls of the given method, e.g. " +
+				"/lnrpc.Lightning/OpenChannel",
+		},
+	},
+	Action: actionDecorator(auditLogList),
+}
+
+func auditLogList(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	var (
+		req = &lnrpc.ListAuditLogRequest{
+			Method: ctx.String("method"),
+		}
+		now = time.Now()
+		err error
+	)
+	if ctx.IsSet("start_time") {
+		req.StartTime, err = parseTime(ctx.String("start_time"), now)
+		if err != nil {
+			return fmt.Errorf("unable to decode start_time: %w",
+				err)
+		}
+	}
+	if ctx.IsSet("end_time") {
+		req.EndTime, err = parseTime(ctx.String("end_time"), now)
+		if err != nil {
+			return fmt.Errorf("unable to decode end_time: %w", err)
+		}
+	}
+
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.ListAuditLog(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var auditLogSubscribeCommand = cli.Command{
+	Name:  "subscribe",
+	Usage: "Print every new entry of the audit log.",
+	Description: `
+	Print every new entry of the audit log as it is recorded, one JSON
+	object at a time, until the command is interrupted.
+	`,
+	Action: actionDecorator(auditLogSubscribe),
+}
+
+func auditLogSubscribe(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	stream, err := client.SubscribeAuditLog(
+		ctxc, &lnrpc.SubscribeAuditLogRequest{},
+	)
+	if err != nil {
+		return err
+	}
+
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		printRespJSON(entry)
+	}
+}
@@ -5,14 +5,19 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/lightningnetwork/lnd/lncfg"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/lightningnetwork/lnd/routing/route"
 	"github.com/urfave/cli"
 	"google.golang.org/protobuf/proto"
 	"gopkg.in/macaroon-bakery.v2/bakery"
@@ -38,6 +43,69 @@ var (
 		Usage: "the condition of the custom caveat to add, can be " +
 			"empty if custom caveat doesn't need a value",
 	}
+	macMaxPaymentFlag = cli.Int64Flag{
+		Name: "max_payment_sat",
+		Usage: "the maximum amount in satoshis, including the " +
+			"maximum routing fee, of a single payment made with " +
+			"the macaroon",
+	}
+	macSpendLimitFlag = cli.Int64Flag{
+		Name: "spend_limit_sat",
+		Usage: "the maximum amount in satoshis, including the " +
+			"maximum routing fees, of all payments made with the " +
+			"macaroon within the --spend_window",
+	}
+	macSpendWindowFlag = cli.DurationFlag{
+		Name: "spend_window",
+		Usage: "the rolling time window of the --spend_limit_sat, " +
+			"for example 24h",
+	}
+	macAllowedDestinationsFlag = cli.StringSliceFlag{
+		Name: "allowed_destination",
+		Usage: "the hex encoded public key of a node payments made " +
+			"with the macaroon can be sent to, can be specified " +
+			"multiple times",
+	}
+	macAllowedChanPointsFlag = cli.StringSliceFlag{
+		Name: "allowed_chan_point",
+		Usage: "the channel point (txid:index) of a channel that " +
+			"can be closed or have its policy updated with the " +
+			"macaroon, can be specified multiple times",
+	}
+	macMaxInvoiceFlag = cli.Int64Flag{
+		Name: "max_invoice_sat",
+		Usage: "the maximum amount in satoshis of invoices created " +
+			"with the macaroon",
+	}
+	macRateLimitFlag = cli.Float64Flag{
+		Name: "rate_limit",
+		Usage: "the maximum number of calls per second of each RPC " +
+			"method made with the macaroon",
+	}
+	macRateBurstFlag = cli.IntFlag{
+		Name: "rate_burst",
+		Usage: "the number of calls of each RPC method that can be " +
+			"made at once before the --rate_limit applies",
+	}
+	macMaxStreamsFlag = cli.Uint64Flag{
+		Name: "max_streams",
+		Usage: "the maximum number of concurrent streams of each " +
+			"streaming RPC method opened with the macaroon",
+	}
+
+	// macLimitFlags are the flags of all amount, destination and channel
+	// restrictions.
+	macLimitFlags = []cli.Flag{
+		macMaxPaymentFlag,
+		macSpendLimitFlag,
+		macSpendWindowFlag,
+		macAllowedDestinationsFlag,
+		macAllowedChanPointsFlag,
+		macMaxInvoiceFlag,
+		macRateLimitFlag,
+		macRateBurstFlag,
+		macMaxStreamsFlag,
+	}
 )
 
 var bakeMacaroonCommand = cli.Command{
@@ -47,8 +115,11 @@ var bakeMacaroonCommand = cli.Command{
 		"and restrictions.",
 	ArgsUsage: "[--save_to=] [--timeout=] [--ip_address=] " +
 		"[--custom_caveat_name= [--custom_caveat_condition=]] " +
-		"[--root_key_id=] [--allow_external_permissions] " +
-		"permissions...",
+		"[--max_payment_sat=] [--spend_limit_sat= --spend_window=] " +
+		"[--allowed_destination=...] [--allowed_chan_point=...] " +
+		"[--max_invoice_sat=] [--rate_limit= --rate_burst=] " +
+		"[--max_streams=] [--root_key_id=] " +
+		"[--allow_external_permissions] permissions...",
 	Description: `
 	Bake a new macaroon that grants the provided permissions and
 	optionally adds restrictions (timeout, IP address) to it.
@@ -72,10 +143,34 @@ var bakeMacaroonCommand = cli.Command{
 	The macaroon created by this command would only be allowed to use the
 	"lncli getinfo" and "lncli version" commands.
 
+	The payments, invoices and channel operations allowed by a macaroon can
+	be restricted further, for example:
+
+	lncli bakemacaroon --max_payment_sat=10000 --spend_limit_sat=100000 \
+		--spend_window=24h offchain:read offchain:write
+
+	The macaroon created by this command can only make payments of up to
+	10k satoshis each, including routing fees, and no more than 100k
+	satoshis within any 24 hour period. On-chain sends are not limited by
+	these restrictions, so the onchain:write permission should not be
+	granted to such a macaroon.
+
+	The rate of the calls made with a macaroon can be limited as well, for
+	example:
+
+	lncli bakemacaroon --rate_limit=0.5 --rate_burst=5 --max_streams=2 \
+		info:read offchain:read
+
+	Each RPC method can then be called 5 times at once and once every two
+	seconds after that, and each streaming method can have no more than 2
+	concurrent streams. Calls exceeding these limits fail with the gRPC
+	status ResourceExhausted. The limits of a macaroon are enforced even
+	if no rpcratelimit options are configured in lnd.
+
 	To get a list of all available URIs and permissions, use the
 	"lncli listpermissions" command.
 	`,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.StringFlag{
 			Name: "save_to",
 			Usage: "save the created macaroon to this file " +
@@ -95,7 +190,7 @@ var bakeMacaroonCommand = cli.Command{
 			Usage: "whether permissions lnd is not familiar with " +
 				"are allowed",
 		},
-	},
+	}, macLimitFlags...),
 	Action: actionDecorator(bakeMacaroon),
 }
 
@@ -416,18 +511,22 @@ var constrainMacaroonCommand = cli.Command{
 	Category: "Macaroons",
 	Usage:    "Adds one or more restriction(s) to an existing macaroon",
 	ArgsUsage: "[--timeout=] [--ip_address=] [--custom_caveat_name= " +
-		"[--custom_caveat_condition=]] input-macaroon-file " +
+		"[--custom_caveat_condition=]] [--max_payment_sat=] " +
+		"[--spend_limit_sat= --spend_window=] " +
+		"[--allowed_destination=...] [--allowed_chan_point=...] " +
+		"[--max_invoice_sat=] [--rate_limit= --rate_burst=] " +
+		"[--max_streams=] input-macaroon-file " +
 		"constrained-macaroon-file",
 	Description: `
 	Add one or more first-party caveat(s) (a.k.a. constraints/restrictions)
 	to an existing macaroon.
 	`,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		macTimeoutFlag,
 		macIPAddressFlag,
 		macCustomCaveatNameFlag,
 		macCustomCaveatConditionFlag,
-	},
+	}, macLimitFlags...),
 	Action: actionDecorator(constrainMacaroon),
 }
 
@@ -548,6 +647,12 @@ func applyMacaroonConstraints(ctx *cli.Context,
 		)
 	}
 
+	limitConstraints, err := macaroonLimitConstraints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	macConstraints = append(macConstraints, limitConstraints...)
+
 	constrainedMac, err := macaroons.AddConstraints(mac, macConstraints...)
 	if err != nil {
 		return nil, fmt.Errorf("error adding constraints: %w", err)
@@ -556,6 +661,141 @@ func applyMacaroonConstraints(ctx *cli.Context,
 	return constrainedMac, nil
 }
 
+// macaroonLimitConstraints parses the amount, destination and channel
+// restriction flags from the command line.
+func macaroonLimitConstraints(ctx *cli.Context) ([]macaroons.Constraint,
+	error) {
+
+	var macConstraints []macaroons.Constraint
+
+	if ctx.IsSet(macMaxPaymentFlag.Name) {
+		amt := ctx.Int64(macMaxPaymentFlag.Name)
+		if amt < 0 {
+			return nil, fmt.Errorf("max_payment_sat must not be " +
+				"negative")
+		}
+
+		macConstraints = append(
+			macConstraints,
+			macaroons.MaxPaymentConstraint(btcutil.Amount(amt)),
+		)
+	}
+
+	if ctx.IsSet(macSpendLimitFlag.Name) !=
+		ctx.IsSet(macSpendWindowFlag.Name) {
+
+		return nil, fmt.Errorf("spend_limit_sat and spend_window " +
+			"must be set together")
+	}
+
+	if ctx.IsSet(macSpendLimitFlag.Name) {
+		amt := ctx.Int64(macSpendLimitFlag.Name)
+		if amt < 0 {
+			return nil, fmt.Errorf("spend_limit_sat must not be " +
+				"negative")
+		}
+
+		window := ctx.Duration(macSpendWindowFlag.Name)
+		if window < time.Second {
+			return nil, fmt.Errorf("spend_window must be at " +
+				"least one second")
+		}
+
+		macConstraints = append(
+			macConstraints, macaroons.SpendLimitConstraint(
+				btcutil.Amount(amt), window,
+			),
+		)
+	}
+
+	if ctx.IsSet(macAllowedDestinationsFlag.Name) {
+		var dests [][33]byte
+		for _, dest := range ctx.StringSlice(
+			macAllowedDestinationsFlag.Name,
+		) {
+
+			pubKey, err := route.NewVertexFromStr(dest)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse "+
+					"allowed_destination %v: %w", dest,
+					err)
+			}
+			dests = append(dests, pubKey)
+		}
+
+		macConstraints = append(
+			macConstraints,
+			macaroons.DestinationsConstraint(dests...),
+		)
+	}
+
+	if ctx.IsSet(macAllowedChanPointsFlag.Name) {
+		var chanPoints []wire.OutPoint
+		for _, chanPoint := range ctx.StringSlice(
+			macAllowedChanPointsFlag.Name,
+		) {
+
+			op, err := wire.NewOutPointFromString(chanPoint)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse "+
+					"allowed_chan_point %v: %w",
+					chanPoint, err)
+			}
+			chanPoints = append(chanPoints, *op)
+		}
+
+		macConstraints = append(
+			macConstraints,
+			macaroons.ChannelsConstraint(chanPoints...),
+		)
+	}
+
+	if ctx.IsSet(macMaxInvoiceFlag.Name) {
+		amt := ctx.Int64(macMaxInvoiceFlag.Name)
+		if amt < 0 {
+			return nil, fmt.Errorf("max_invoice_sat must not be " +
+				"negative")
+		}
+
+		macConstraints = append(
+			macConstraints,
+			macaroons.MaxInvoiceConstraint(btcutil.Amount(amt)),
+		)
+	}
+
+	if ctx.IsSet(macRateLimitFlag.Name) !=
+		ctx.IsSet(macRateBurstFlag.Name) {
+
+		return nil, fmt.Errorf("rate_limit and rate_burst must be " +
+			"set together")
+	}
+
+	if ctx.IsSet(macRateLimitFlag.Name) {
+		limit := macaroons.RateLimit{
+			Rate:  ctx.Float64(macRateLimitFlag.Name),
+			Burst: ctx.Int(macRateBurstFlag.Name),
+		}
+		macConstraints = append(
+			macConstraints, macaroons.RateLimitConstraint(limit),
+		)
+	}
+
+	if ctx.IsSet(macMaxStreamsFlag.Name) {
+		maxStreams := ctx.Uint64(macMaxStreamsFlag.Name)
+		if maxStreams == 0 || maxStreams > math.MaxUint32 {
+			return nil, fmt.Errorf("max_streams must be between 1 "+
+				"and %d", uint32(math.MaxUint32))
+		}
+
+		macConstraints = append(
+			macConstraints,
+			macaroons.MaxStreamsConstraint(uint32(maxStreams)),
+		)
+	}
+
+	return macConstraints, nil
+}
+
 // containsWhiteSpace returns true if the given string contains any character
 // that is considered to be a white space or non-printable character such as
 // space, tabulator, newline, carriage return and some more exotic ones.
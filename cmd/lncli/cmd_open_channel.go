@@ -283,6 +283,13 @@ var openChannelCommand = cli.Command{
 				has no bearing on the channel's operation. Max
 				allowed length is 500 characters`,
 		},
+		cli.StringFlag{
+			Name: "funding_wallet",
+			Usage: "(optional) the name of the unlocked named " +
+				"wallet to fund the channel from; change is " +
+				"sent back to it, while the channel keys and " +
+				"close outputs stay with the main wallet",
+		},
 	},
 	Action: actionDecorator(openChannel),
 }
@@ -327,6 +334,7 @@ func openChannel(ctx *cli.Context) error {
 		RemoteChanReserveSat:       ctx.Uint64("remote_reserve_sats"),
 		FundMax:                    ctx.Bool("fundmax"),
 		Memo:                       ctx.String("memo"),
+		FundingWallet:              ctx.String("funding_wallet"),
 	}
 
 	switch {
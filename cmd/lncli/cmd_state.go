@@ -20,6 +20,8 @@ var getStateCommand = cli.Command{
 	 - UNLOCKED: wallet was unlocked successfully, but RPC server isn't ready.
 	 - RPC_ACTIVE: RPC server is active but not fully ready for calls.
 	 - SERVER_ACTIVE: RPC server is available and ready to accept calls.
+	 - SIGNER_UNAVAILABLE: server is active, but none of the remote signers
+	   can be reached.
 	`,
 	Flags:  []cli.Flag{},
 	Action: actionDecorator(getState),
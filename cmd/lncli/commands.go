@@ -203,10 +203,10 @@ func newAddress(ctx *cli.Context) error {
 var coinSelectionStrategyFlag = cli.StringFlag{
 	Name: "coin_selection_strategy",
 	Usage: "(optional) the strategy to use for selecting " +
-		"coins. Possible values are 'largest', 'random', or " +
-		"'global-config'. If either 'largest' or 'random' is " +
-		"specified, it will override the globally configured " +
-		"strategy in lnd.conf",
+		"coins. Possible values are 'largest', 'random', " +
+		"'bnb', 'privacy' or 'global-config'. If any value " +
+		"other than 'global-config' is specified, it will " +
+		"override the globally configured strategy in lnd.conf",
 	Value: "global-config",
 }
 
@@ -330,6 +330,12 @@ var sendCoinsCommand = cli.Command{
 				"terminal avoid breaking existing shell " +
 				"scripts",
 		},
+		cli.StringFlag{
+			Name: "payjoin_uri",
+			Usage: "(optional) a BIP 21 URI with a payjoin " +
+				"endpoint to pay to instead of addr; the " +
+				"amount of the URI is used unless amt is set",
+		},
 		coinSelectionStrategyFlag,
 		txLabelFlag,
 	},
@@ -366,7 +372,9 @@ func sendCoins(ctx *cli.Context) error {
 		return err
 	}
 
+	payjoinURI := ctx.String("payjoin_uri")
 	switch {
+	case payjoinURI != "":
 	case ctx.IsSet("addr"):
 		addr = ctx.String("addr")
 	case args.Present():
@@ -376,11 +384,13 @@ func sendCoins(ctx *cli.Context) error {
 		return fmt.Errorf("Address argument missing")
 	}
 
+	// The amount of a payjoin URI is used if no amount is given.
 	switch {
 	case ctx.IsSet("amt"):
 		amt = ctx.Int64("amt")
 	case args.Present():
 		amt, err = strconv.ParseInt(args.First(), 10, 64)
+	case payjoinURI != "":
 	case !ctx.Bool("sweepall"):
 		return fmt.Errorf("Amount argument missing")
 	}
@@ -423,7 +433,11 @@ func sendCoins(ctx *cli.Context) error {
 	// scripts from breaking.
 	if !ctx.Bool("force") && term.IsTerminal(int(os.Stdout.Fd())) {
 		fmt.Printf("Amount: %d\n", displayAmt)
-		fmt.Printf("Destination address: %v\n", addr)
+		if payjoinURI != "" {
+			fmt.Printf("Payjoin URI: %v\n", payjoinURI)
+		} else {
+			fmt.Printf("Destination address: %v\n", addr)
+		}
 
 		confirm := promptForConfirmation("Confirm payment (yes/no): ")
 		if !confirm {
@@ -441,6 +455,7 @@ func sendCoins(ctx *cli.Context) error {
 		MinConfs:              minConfs,
 		SpendUnconfirmed:      minConfs == 0,
 		CoinSelectionStrategy: coinSelectionStrategy,
+		PayjoinUri:            payjoinURI,
 	}
 	txid, err := client.SendCoins(ctxc, req)
 	if err != nil {
@@ -1309,6 +1324,91 @@ func abandonChannel(ctx *cli.Context) error {
 	return nil
 }
 
+var simulateForceCloseCommand = cli.Command{
+	Name:     "simulateforceclose",
+	Category: "Channels",
+	Usage: "Simulates a force close of a channel without broadcasting " +
+		"anything.",
+	Description: `
+	Simulates a force close of an open channel at its current state and
+	reports the estimated weights of the commitment transaction and the
+	HTLC transactions and the fees needed to confirm and sweep them at the
+	given fee rate, along with the amount that would be recovered. Nothing
+	is signed or broadcast.
+
+	The fee rate can be set manually with --sat_per_vbyte, or is estimated
+	for the confirmation target set with --conf_target.
+
+	The format for a channel_point is 'funding_txid:output_index'.`,
+	ArgsUsage: "funding_txid [output_index]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "funding_txid",
+			Usage: "the txid of the channel's funding transaction",
+		},
+		cli.IntFlag{
+			Name: "output_index",
+			Usage: "the output index for the funding output of " +
+				"the funding transaction",
+		},
+		cli.StringFlag{
+			Name: "chan_point",
+			Usage: "(optional) the channel point. If set, " +
+				"funding_txid and output_index flags and " +
+				"positional arguments will be ignored",
+		},
+		cli.Int64Flag{
+			Name: "conf_target",
+			Usage: "(optional) the number of blocks the " +
+				"transactions following the commitment " +
+				"should confirm in",
+		},
+		cli.Uint64Flag{
+			Name: "sat_per_vbyte",
+			Usage: "(optional) a manual fee rate set in " +
+				"sat/vbyte that the transactions following " +
+				"the commitment should pay",
+		},
+	},
+	Action: actionDecorator(simulateForceClose),
+}
+
+func simulateForceClose(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	// Show command help if no arguments and flags were provided.
+	if ctx.NArg() == 0 && ctx.NumFlags() == 0 {
+		cli.ShowCommandHelp(ctx, "simulateforceclose")
+		return nil
+	}
+
+	if ctx.IsSet("conf_target") && ctx.IsSet("sat_per_vbyte") {
+		return fmt.Errorf("either conf_target or sat_per_vbyte " +
+			"should be set, but not both")
+	}
+
+	channelPoint, err := parseChannelPoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := &lnrpc.SimulateForceCloseRequest{
+		ChannelPoint: channelPoint,
+		TargetConf:   uint32(ctx.Int64("conf_target")),
+		SatPerVbyte:  ctx.Uint64("sat_per_vbyte"),
+	}
+
+	resp, err := client.SimulateForceClose(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
+
 // parseChannelPoint parses a funding txid and output index from the command
 // line. Both named options as well as unnamed parameters are supported.
 func parseChannelPoint(ctx *cli.Context) (*lnrpc.ChannelPoint, error) {
@@ -1520,6 +1620,73 @@ func getRecoveryInfo(ctx *cli.Context) error {
 	return nil
 }
 
+var getDBStatsCommand = cli.Command{
+	Name:  "getdbstats",
+	Usage: "Display the size of the database and its main buckets.",
+	Description: `
+	Display the size of the bolt database files and the amount of data
+	stored in the top level buckets of each database, such as the channel
+	state, the revocation logs, the payments, the channel graph and the
+	forwarding log of the channel database. For each bucket, its largest
+	nested buckets are listed as well.
+	`,
+	Action: actionDecorator(getDBStats),
+}
+
+func getDBStats(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	req := &lnrpc.GetDatabaseStatsRequest{}
+	resp, err := client.GetDatabaseStats(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
+
+var compactDBCommand = cli.Command{
+	Name:  "compactdb",
+	Usage: "Compact the bolt database files.",
+	Description: `
+	Compact the bolt database files to reclaim the space of deleted data.
+
+	By default, the compaction of all bolt database files is scheduled for
+	the next restart of lnd. If --online is set, the channel database is
+	compacted right away. This requires lnd to be started with
+	db.online-compaction. Writes to the database are paused for a brief
+	moment while the compacted file is swapped in.
+	`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name: "online",
+			Usage: "compact the channel database right away " +
+				"instead of at the next restart",
+		},
+	},
+	Action: actionDecorator(compactDB),
+}
+
+func compactDB(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	req := &lnrpc.CompactDatabaseRequest{
+		Online: ctx.Bool("online"),
+	}
+	resp, err := client.CompactDatabase(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+	return nil
+}
+
 var pendingChannelsCommand = cli.Command{
 	Name:     "pendingchannels",
 	Category: "Channels",
@@ -1553,6 +1720,118 @@ func pendingChannels(ctx *cli.Context) error {
 	return nil
 }
 
+var listResolversCommand = cli.Command{
+	Name:     "listresolvers",
+	Category: "Channels",
+	Usage: "List the contract resolvers of the channels pending force " +
+		"closing.",
+	Description: `
+	List the contract resolvers that are active on the channels pending
+	force closing. For each resolver, its checkpointed state, the outpoint
+	it is watching, the height at which it can take its next step and the
+	last input it offered to the sweeper are shown.
+
+	The format for a chan_point is 'funding_txid:output_index'.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "chan_point",
+			Usage: "(optional) only list the resolvers of this " +
+				"channel",
+		},
+	},
+	Action: actionDecorator(listResolvers),
+}
+
+func listResolvers(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	req := &lnrpc.ListContractResolversRequest{}
+	if ctx.IsSet("chan_point") {
+		chanPoint, err := parseChanPoint(ctx.String("chan_point"))
+		if err != nil {
+			return fmt.Errorf("unable to parse chan_point: %w", err)
+		}
+		req.ChannelPoint = chanPoint
+	}
+
+	resp, err := client.ListContractResolvers(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var reevaluateResolverCommand = cli.Command{
+	Name:     "reevaluateresolver",
+	Category: "Channels",
+	Usage:    "Make a contract resolver re-evaluate its contract.",
+	Description: `
+	Stop an active contract resolver, reload it from its last checkpoint
+	and launch it again, making it re-evaluate its contract. This can be
+	used to kick a resolver that appears to be stuck, for example to offer
+	its input to the sweeper again.
+
+	The resolver is identified by the hex encoded resolver_key as shown by
+	listresolvers. The anchor resolver is selected by an empty key.
+
+	The format for a chan_point is 'funding_txid:output_index'.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "chan_point",
+			Usage: "the channel the resolver belongs to",
+		},
+		cli.StringFlag{
+			Name:  "resolver_key",
+			Usage: "the hex encoded key of the resolver",
+		},
+	},
+	Action: actionDecorator(reevaluateResolver),
+}
+
+func reevaluateResolver(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	// Show command help if no arguments and flags were provided.
+	if ctx.NumFlags() == 0 {
+		cli.ShowCommandHelp(ctx, "reevaluateresolver")
+		return nil
+	}
+
+	if !ctx.IsSet("chan_point") || !ctx.IsSet("resolver_key") {
+		return fmt.Errorf("chan_point and resolver_key must be set")
+	}
+
+	chanPoint, err := parseChanPoint(ctx.String("chan_point"))
+	if err != nil {
+		return fmt.Errorf("unable to parse chan_point: %w", err)
+	}
+
+	resolverKey, err := hex.DecodeString(ctx.String("resolver_key"))
+	if err != nil {
+		return fmt.Errorf("unable to decode resolver_key: %w", err)
+	}
+
+	req := &lnrpc.ReevaluateContractResolverRequest{
+		ChannelPoint: chanPoint,
+		ResolverKey:  resolverKey,
+	}
+	resp, err := client.ReevaluateContractResolver(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
 var listChannelsCommand = cli.Command{
 	Name:     "listchannels",
 	Category: "Channels",
@@ -1718,6 +1997,158 @@ func closedChannels(ctx *cli.Context) error {
 	return nil
 }
 
+var exportChanArchiveCommand = cli.Command{
+	Name:     "exportchanarchive",
+	Category: "Channels",
+	Usage:    "Export the archived state of closed channels.",
+	Description: `
+	Export the final state of the closed channels that was moved to the
+	channel archive, including their last commitments and HTLCs. Channels
+	are only archived if lnd is started with chanarchive.mode=archive.
+	`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "chan_point",
+			Usage: "(optional) only export the channel with " +
+				"this channel point, in the format txid:index",
+		},
+	},
+	Action: actionDecorator(exportChanArchive),
+}
+
+func exportChanArchive(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	req := &lnrpc.ExportChannelArchiveRequest{
+		ChanPoint: ctx.String("chan_point"),
+	}
+
+	resp, err := client.ExportChannelArchive(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var closeHistoryCommand = cli.Command{
+	Name:     "closehistory",
+	Category: "Channels",
+	Usage:    "Show the financial outcome of closed channels.",
+	Description: `
+	Show the close records of channels that were closed on chain. Each
+	record accounts for the close fee, the fees paid to sweep anchors and
+	htlcs, time locked amounts and when they were recovered, and the total
+	gain or loss compared to the last local balance.
+
+	If no close type flags are set, records of all close types are shown.
+
+	The format for a chan_point is 'funding_txid:output_index'.`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "chan_point",
+			Usage: "(optional) only show the record of the channel " +
+				"with this channel point",
+		},
+		cli.StringFlag{
+			Name: "peer",
+			Usage: "(optional) only show records of channels with " +
+				"this peer, as a hex encoded pubkey",
+		},
+		cli.BoolFlag{
+			Name:  "cooperative",
+			Usage: "show channels that were closed cooperatively",
+		},
+		cli.BoolFlag{
+			Name: "local_force",
+			Usage: "show channels that were force-closed " +
+				"by the local node",
+		},
+		cli.BoolFlag{
+			Name: "remote_force",
+			Usage: "show channels that were force-closed " +
+				"by the remote node",
+		},
+		cli.BoolFlag{
+			Name: "breach",
+			Usage: "show channels for which the remote node " +
+				"broadcast a revoked channel state",
+		},
+		cli.Uint64Flag{
+			Name: "start_height",
+			Usage: "(optional) only show channels closed at or " +
+				"above this height",
+		},
+		cli.Uint64Flag{
+			Name: "end_height",
+			Usage: "(optional) only show channels closed at or " +
+				"below this height",
+		},
+		cli.BoolFlag{
+			Name: "resolved_only",
+			Usage: "only show channels whose contracts have all " +
+				"been resolved",
+		},
+	},
+	Action: actionDecorator(closeHistory),
+}
+
+func closeHistory(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	req := &lnrpc.CloseHistoryRequest{
+		StartHeight:  uint32(ctx.Uint64("start_height")),
+		EndHeight:    uint32(ctx.Uint64("end_height")),
+		ResolvedOnly: ctx.Bool("resolved_only"),
+	}
+
+	if ctx.IsSet("chan_point") {
+		chanPoint, err := parseChanPoint(ctx.String("chan_point"))
+		if err != nil {
+			return fmt.Errorf("unable to parse chan_point: %w", err)
+		}
+		req.ChannelPoint = chanPoint
+	}
+
+	if ctx.IsSet("peer") {
+		peer, err := hex.DecodeString(ctx.String("peer"))
+		if err != nil {
+			return fmt.Errorf("unable to decode peer: %w", err)
+		}
+		req.RemotePubkey = peer
+	}
+
+	closeTypes := []struct {
+		flag      string
+		closeType lnrpc.ChannelCloseSummary_ClosureType
+	}{
+		{"cooperative", lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE},
+		{"local_force", lnrpc.ChannelCloseSummary_LOCAL_FORCE_CLOSE},
+		{"remote_force", lnrpc.ChannelCloseSummary_REMOTE_FORCE_CLOSE},
+		{"breach", lnrpc.ChannelCloseSummary_BREACH_CLOSE},
+	}
+	for _, closeType := range closeTypes {
+		if ctx.Bool(closeType.flag) {
+			req.CloseTypes = append(req.CloseTypes, closeType.closeType)
+		}
+	}
+
+	resp, err := client.CloseHistory(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
 var describeGraphCommand = cli.Command{
 	Name:     "describegraph",
 	Category: "Graph",
@@ -2715,6 +3146,47 @@ func verifyChanBackup(ctx *cli.Context) error {
 	return nil
 }
 
+var listChanBackupVersionsCommand = cli.Command{
+	Name:     "listchanbackupversions",
+	Category: "Channels",
+	Usage: "List the versions of the multi-channel backup kept by the " +
+		"backup sinks.",
+	ArgsUsage: "[--sink=]",
+	Description: `
+	List the versions of the multi-channel backup that are kept by the
+	configured backup sinks that store a history of backups, such as a local
+	backup directory (chanbackup.dir) or an S3 bucket (chanbackup.s3.*).
+	The versions are sorted newest first.
+	`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "sink",
+			Usage: "(optional) only list the versions kept by the " +
+				"backup sink with this name",
+		},
+	},
+	Action: actionDecorator(listChanBackupVersions),
+}
+
+func listChanBackupVersions(ctx *cli.Context) error {
+	ctxc := getContext()
+	client, cleanUp := getClient(ctx)
+	defer cleanUp()
+
+	resp, err := client.ListChannelBackupVersions(
+		ctxc, &lnrpc.ListChannelBackupVersionsRequest{
+			Sink: ctx.String("sink"),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
 var restoreChanBackupCommand = cli.Command{
 	Name:     "restorechanbackup",
 	Category: "Channels",
@@ -456,6 +456,7 @@ func main() {
 		closeChannelCommand,
 		closeAllChannelsCommand,
 		abandonChannelCommand,
+		simulateForceCloseCommand,
 		listPeersCommand,
 		walletBalanceCommand,
 		channelBalanceCommand,
@@ -464,7 +465,11 @@ func main() {
 		encryptDebugPackageCommand,
 		decryptDebugPackageCommand,
 		getRecoveryInfoCommand,
+		getDBStatsCommand,
+		compactDBCommand,
 		pendingChannelsCommand,
+		listResolversCommand,
+		reevaluateResolverCommand,
 		sendPaymentCommand,
 		payInvoiceCommand,
 		sendToRouteCommand,
@@ -473,6 +478,8 @@ func main() {
 		listInvoicesCommand,
 		listChannelsCommand,
 		closedChannelsCommand,
+		exportChanArchiveCommand,
+		closeHistoryCommand,
 		listPaymentsCommand,
 		describeGraphCommand,
 		getNodeMetricsCommand,
@@ -483,6 +490,7 @@ func main() {
 		debugLevelCommand,
 		decodePayReqCommand,
 		listChainTxnsCommand,
+		accountingReportCommand,
 		stopCommand,
 		signMessageCommand,
 		verifyMessageCommand,
@@ -491,11 +499,13 @@ func main() {
 		forwardingHistoryCommand,
 		exportChanBackupCommand,
 		verifyChanBackupCommand,
+		listChanBackupVersionsCommand,
 		restoreChanBackupCommand,
 		bakeMacaroonCommand,
 		listMacaroonIDsCommand,
 		deleteMacaroonIDCommand,
 		listPermissionsCommand,
+		auditLogCommand,
 		printMacaroonCommand,
 		constrainMacaroonCommand,
 		trackPaymentCommand,
@@ -587,6 +597,13 @@ func parseCoinSelectionStrategy(ctx *cli.Context) (
 	case "random":
 		return lnrpc.CoinSelectionStrategy_STRATEGY_RANDOM, nil
 
+	case "bnb":
+		return lnrpc.CoinSelectionStrategy_STRATEGY_BRANCH_AND_BOUND,
+			nil
+
+	case "privacy":
+		return lnrpc.CoinSelectionStrategy_STRATEGY_PRIVACY, nil
+
 	default:
 		return 0, fmt.Errorf("unknown coin selection strategy "+
 			"%v", strategy)
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/base64"
@@ -11,6 +12,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,6 +25,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
 	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
+	"github.com/lightningnetwork/lnd/walletunlocker"
 	"github.com/urfave/cli"
 )
 
@@ -64,6 +67,41 @@ var (
 		},
 	}
 
+	// consolidationCommand is a wallet subcommand that is responsible for
+	// scheduling UTXO consolidations.
+	consolidationCommand = cli.Command{
+		Name:  "consolidation",
+		Usage: "Interact with UTXO consolidation schedules.",
+		Subcommands: []cli.Command{
+			scheduleConsolidationCommand,
+			listConsolidationsCommand,
+			cancelConsolidationCommand,
+		},
+	}
+
+	// payjoinCommand is a wallet subcommand that is responsible for
+	// receiving payjoin payments.
+	payjoinCommand = cli.Command{
+		Name:  "payjoin",
+		Usage: "Receive payjoin (BIP 78) payments.",
+		Subcommands: []cli.Command{
+			newPayjoinURICommand,
+		},
+	}
+
+	// namedWalletCommand is a wallet subcommand that is responsible for
+	// managing named wallets.
+	namedWalletCommand = cli.Command{
+		Name:  "named",
+		Usage: "Manage named wallets with their own seeds.",
+		Subcommands: []cli.Command{
+			createNamedWalletCommand,
+			unlockNamedWalletCommand,
+			lockNamedWalletCommand,
+			listNamedWalletsCommand,
+		},
+	}
+
 	p2TrChangeType = walletrpc.ChangeAddressType_CHANGE_ADDRESS_TYPE_P2TR
 )
 
@@ -89,8 +127,12 @@ func walletCommands() []cli.Command {
 				releaseOutputCommand,
 				leaseOutputCommand,
 				listLeasesCommand,
+				selectCoinsCommand,
 				psbtCommand,
 				accountsCommand,
+				consolidationCommand,
+				payjoinCommand,
+				namedWalletCommand,
 				requiredReserveCommand,
 				addressesCommand,
 			},
@@ -1374,6 +1416,11 @@ var finalizePsbtCommand = cli.Command{
 			Usage: "(optional) the name of the account to " +
 				"finalize the PSBT with",
 		},
+		cli.StringFlag{
+			Name: "wallet",
+			Usage: "(optional) the name of the named wallet to " +
+				"finalize the PSBT with",
+		},
 	},
 	Action: actionDecorator(finalizePsbt),
 }
@@ -1383,7 +1430,7 @@ func finalizePsbt(ctx *cli.Context) error {
 
 	// Display the command's help message if we do not have the expected
 	// number of arguments/flags.
-	if ctx.NArg() > 1 || ctx.NumFlags() > 2 {
+	if ctx.NArg() > 1 || ctx.NumFlags() > 3 {
 		return cli.ShowCommandHelp(ctx, "finalize")
 	}
 
@@ -1407,6 +1454,7 @@ func finalizePsbt(ctx *cli.Context) error {
 	req := &walletrpc.FinalizePsbtRequest{
 		FundedPsbt: psbtBytes,
 		Account:    ctx.String("account"),
+		Wallet:     ctx.String("wallet"),
 	}
 
 	walletClient, cleanUp := getWalletClient(ctx)
@@ -1656,6 +1704,131 @@ func listAccounts(ctx *cli.Context) error {
 	return nil
 }
 
+var selectCoinsCommand = cli.Command{
+	Name:      "selectcoins",
+	Usage:     "Perform a dry run of the coin selection.",
+	ArgsUsage: "--outputs=O [--conf_target=C | --sat_per_vbyte=S]",
+	Description: `
+	The selectcoins command shows the inputs the wallet would spend to pay
+	to the given outputs together with the fee, the change and the waste of
+	the selection. No coins are locked.
+
+	The waste is the fee for spending the inputs now minus the fee for
+	spending them at a long term fee rate of 10 sat/vbyte, plus either the
+	cost of creating and later spending the change output or, without
+	change, the excess value that goes to fees. A lower waste is better.
+
+	The 'outputs' flag decodes addresses and the amount to send respectively
+	in the following JSON format:
+
+	    --outputs='{"ExampleAddr": NumCoinsInSatoshis, "SecondAddr": Sats}'
+	`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name: "outputs",
+			Usage: "a JSON compatible map of destination " +
+				"addresses to amounts to send",
+		},
+		cli.Uint64Flag{
+			Name: "conf_target",
+			Usage: "the number of blocks that the transaction " +
+				"should be confirmed on-chain within",
+			Value: 6,
+		},
+		cli.Uint64Flag{
+			Name: "sat_per_vbyte",
+			Usage: "a manual fee expressed in sat/vbyte that " +
+				"should be used when creating the transaction",
+		},
+		cli.StringFlag{
+			Name: "account",
+			Usage: "(optional) the name of the account to select " +
+				"the coins from",
+		},
+		cli.StringFlag{
+			Name: "change_type",
+			Usage: "(optional) the type of the change address, " +
+				"p2tr or p2wkh (default)",
+		},
+		cli.Uint64Flag{
+			Name: "min_confs",
+			Usage: "(optional) the minimum number of " +
+				"confirmations each input must satisfy",
+			Value: defaultUtxoMinConf,
+		},
+		coinSelectionStrategyFlag,
+	},
+	Action: actionDecorator(selectCoins),
+}
+
+func selectCoins(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if there aren't any flags
+	// specified.
+	if ctx.NumFlags() == 0 {
+		return cli.ShowCommandHelp(ctx, "selectcoins")
+	}
+
+	coinSelectionStrategy, err := parseCoinSelectionStrategy(ctx)
+	if err != nil {
+		return err
+	}
+
+	minConfs := int32(ctx.Uint64("min_confs"))
+	req := &walletrpc.SelectCoinsRequest{
+		Account:               ctx.String("account"),
+		MinConfs:              minConfs,
+		SpendUnconfirmed:      minConfs == 0,
+		CoinSelectionStrategy: coinSelectionStrategy,
+	}
+
+	jsonMap := []byte(ctx.String("outputs"))
+	if err := json.Unmarshal(jsonMap, &req.Outputs); err != nil {
+		return fmt.Errorf("error parsing outputs JSON: %w", err)
+	}
+
+	// Parse fee flags.
+	switch {
+	case ctx.IsSet("conf_target") && ctx.IsSet("sat_per_vbyte"):
+		return fmt.Errorf("cannot set conf_target and sat_per_vbyte " +
+			"at the same time")
+
+	case ctx.Uint64("sat_per_vbyte") > 0:
+		req.Fees = &walletrpc.SelectCoinsRequest_SatPerVbyte{
+			SatPerVbyte: ctx.Uint64("sat_per_vbyte"),
+		}
+
+	// Check conf_target last because it has a default value.
+	case ctx.Uint64("conf_target") > 0:
+		req.Fees = &walletrpc.SelectCoinsRequest_TargetConf{
+			TargetConf: uint32(ctx.Uint64("conf_target")),
+		}
+	}
+
+	switch changeType := ctx.String("change_type"); changeType {
+	case "", "p2wkh":
+
+	case "p2tr":
+		req.ChangeType = p2TrChangeType
+
+	default:
+		return fmt.Errorf("invalid change type: %s", changeType)
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	resp, err := walletClient.SelectCoins(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
 var requiredReserveCommand = cli.Command{
 	Name:  "requiredreserve",
 	Usage: "Returns the wallet reserve.",
@@ -2091,3 +2264,482 @@ func importPubKey(ctx *cli.Context) error {
 	printRespJSON(resp)
 	return nil
 }
+
+var scheduleConsolidationCommand = cli.Command{
+	Name:      "schedule",
+	Usage:     "Schedule the consolidation of small UTXOs.",
+	ArgsUsage: "--max_sat_per_vbyte=S --min_utxos=N",
+	Description: `
+	Schedules the consolidation of the UTXOs of an account. Whenever a new
+	block is found and the fee rate estimated for the confirmation target
+	is at or below max_sat_per_vbyte, all eligible UTXOs are merged into a
+	single new output of the account, as long as there are at least
+	min_utxos of them.
+
+	Leased UTXOs are never spent, and no consolidation is published that
+	would reduce the balance of the default account below the reserve
+	required for anchor channels. Consolidation transactions are labelled
+	with the ID of their schedule.
+
+	Schedules are not persisted. A schedule stays active until it is
+	canceled or lnd is restarted, after which it has to be created again.
+	`,
+	Flags: []cli.Flag{
+		cli.Uint64Flag{
+			Name: "max_sat_per_vbyte",
+			Usage: "the maximum fee rate in sat/vbyte at which " +
+				"UTXOs are consolidated",
+		},
+		cli.Uint64Flag{
+			Name: "min_utxos",
+			Usage: "the minimum number of eligible UTXOs that " +
+				"are required for a consolidation",
+		},
+		cli.StringFlag{
+			Name: "account",
+			Usage: "(optional) the name of the account to " +
+				"consolidate the UTXOs of",
+		},
+		cli.StringFlag{
+			Name: "label_filter",
+			Usage: "(optional) only consolidate UTXOs of " +
+				"transactions whose label contains this string",
+		},
+		cli.Uint64Flag{
+			Name: "conf_target",
+			Usage: "(optional) the confirmation target used to " +
+				"estimate the fee rate",
+			Value: 6,
+		},
+		cli.Int64Flag{
+			Name: "max_utxo_value",
+			Usage: "(optional) only consolidate UTXOs worth at " +
+				"most this many satoshis",
+		},
+		cli.Uint64Flag{
+			Name: "max_inputs",
+			Usage: "(optional) the maximum number of UTXOs spent " +
+				"by a single consolidation",
+			Value: 100,
+		},
+	},
+	Action: actionDecorator(scheduleConsolidation),
+}
+
+func scheduleConsolidation(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if there aren't any flags
+	// specified.
+	if ctx.NArg() > 0 || ctx.NumFlags() == 0 {
+		return cli.ShowCommandHelp(ctx, "schedule")
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	req := &walletrpc.ScheduleConsolidationRequest{
+		MaxSatPerVbyte:  ctx.Uint64("max_sat_per_vbyte"),
+		MinUtxos:        uint32(ctx.Uint64("min_utxos")),
+		Account:         ctx.String("account"),
+		LabelFilter:     ctx.String("label_filter"),
+		TargetConf:      uint32(ctx.Uint64("conf_target")),
+		MaxUtxoValueSat: ctx.Int64("max_utxo_value"),
+		MaxInputs:       uint32(ctx.Uint64("max_inputs")),
+	}
+	resp, err := walletClient.ScheduleConsolidation(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var listConsolidationsCommand = cli.Command{
+	Name:  "list",
+	Usage: "List the consolidation schedules and transactions.",
+	Description: `
+	Lists the active consolidation schedules and the consolidation
+	transactions the wallet has published.
+	`,
+	Action: actionDecorator(listConsolidations),
+}
+
+func listConsolidations(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if we do not have the expected
+	// number of arguments/flags.
+	if ctx.NArg() > 0 || ctx.NumFlags() > 0 {
+		return cli.ShowCommandHelp(ctx, "list")
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	req := &walletrpc.ListConsolidationsRequest{}
+	resp, err := walletClient.ListConsolidations(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var cancelConsolidationCommand = cli.Command{
+	Name:      "cancel",
+	Usage:     "Cancel a consolidation schedule.",
+	ArgsUsage: "schedule_id",
+	Description: `
+	Cancels the active consolidation schedule with the given ID.
+	`,
+	Action: actionDecorator(cancelConsolidation),
+}
+
+func cancelConsolidation(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if we do not have the expected
+	// number of arguments.
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "cancel")
+	}
+
+	scheduleID, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid schedule ID: %w", err)
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	req := &walletrpc.CancelConsolidationRequest{
+		ScheduleId: scheduleID,
+	}
+	resp, err := walletClient.CancelConsolidation(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var newPayjoinURICommand = cli.Command{
+	Name:      "newuri",
+	Usage:     "Create a BIP 21 URI with a payjoin endpoint.",
+	ArgsUsage: "[--amt=A] [--account=N] [--label=L]",
+	Description: `
+	Creates a BIP 21 URI for a new address of the wallet that carries the
+	payjoin endpoint configured with walletrpc.payjoinendpoint. When the
+	sender supports payjoin, the wallet contributes one of its own inputs
+	of the same type as the inputs of the sender to the payment. Each URI
+	can only be paid once.
+
+	If a pending channel is given, the inputs of the wallet also pay for
+	the funding output of the channel. The channel must have been opened
+	with a PSBT funding shim that has no_publish set, e.g. with
+	'lncli openchannel --psbt --no_publish'. The funding transaction is
+	then published by the sender of the payjoin.
+	`,
+	Flags: []cli.Flag{
+		cli.Int64Flag{
+			Name:  "amt",
+			Usage: "(optional) the amount in satoshis to request",
+		},
+		cli.StringFlag{
+			Name: "account",
+			Usage: "(optional) the name of the account to use " +
+				"for the address and the inputs of the wallet",
+		},
+		cli.StringFlag{
+			Name:  "label",
+			Usage: "(optional) a label to add to the URI",
+		},
+		cli.StringFlag{
+			Name: "type",
+			Usage: "(optional) the address type to use, one of " +
+				"p2wkh, p2tr or np2wkh",
+		},
+		cli.Uint64Flag{
+			Name: "expiry",
+			Usage: "(optional) the number of seconds after which " +
+				"the URI can't be paid with a payjoin anymore",
+		},
+		cli.StringFlag{
+			Name: "pending_chan_id",
+			Usage: "(optional) the hex encoded pending channel " +
+				"ID of a channel to fund with the payjoin",
+		},
+		cli.StringFlag{
+			Name:  "funding_address",
+			Usage: "the funding address of the pending channel",
+		},
+		cli.Int64Flag{
+			Name: "funding_amt",
+			Usage: "the amount of the funding output of the " +
+				"pending channel in satoshis",
+		},
+	},
+	Action: actionDecorator(newPayjoinURI),
+}
+
+func newPayjoinURI(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if there are any arguments.
+	if ctx.NArg() > 0 {
+		return cli.ShowCommandHelp(ctx, "newuri")
+	}
+
+	addrType, err := parseAddrType(ctx.String("type"))
+	if err != nil {
+		return err
+	}
+
+	pendingChanID, err := hex.DecodeString(ctx.String("pending_chan_id"))
+	if err != nil {
+		return fmt.Errorf("unable to decode pending channel ID: %w",
+			err)
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	req := &walletrpc.NewPayjoinUriRequest{
+		AmountSat:        ctx.Int64("amt"),
+		Account:          ctx.String("account"),
+		Label:            ctx.String("label"),
+		PendingChanId:    pendingChanID,
+		FundingAddress:   ctx.String("funding_address"),
+		FundingAmountSat: ctx.Int64("funding_amt"),
+		ExpirySeconds:    ctx.Uint64("expiry"),
+		Type:             addrType,
+	}
+	resp, err := walletClient.NewPayjoinUri(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var createNamedWalletCommand = cli.Command{
+	Name:      "create",
+	Usage:     "Create a named wallet with its own seed and password.",
+	ArgsUsage: "name [--restore] [--recovery_window=N]",
+	Description: `
+	Creates a new named on-chain wallet next to the main wallet of lnd and
+	unlocks it. The wallet has its own aezeed seed, which can be protected
+	with a passphrase, and its own password, which are both prompted for.
+
+	Unless --restore is set, a new seed is generated and its mnemonic is
+	printed. It MUST be written down, as the funds of the wallet can't be
+	recovered without it and, if set, its passphrase.
+
+	Named wallets can be selected with the --wallet flag of the wallet
+	commands and fund channels with the --funding_wallet flag of
+	openchannel.
+	`,
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name: "restore",
+			Usage: "restore the wallet from the mnemonic of an " +
+				"existing seed, which is prompted for",
+		},
+		cli.Int64Flag{
+			Name: "recovery_window",
+			Usage: "the number of addresses to look ahead when " +
+				"restoring the wallet; a default of 2500 is " +
+				"used if it is zero",
+		},
+	},
+	Action: actionDecorator(createNamedWallet),
+}
+
+func createNamedWallet(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if we do not have the expected
+	// number of arguments.
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "create")
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	password, err := capturePassword(
+		"Input wallet password: ", false,
+		walletunlocker.ValidatePassword,
+	)
+	if err != nil {
+		return err
+	}
+
+	req := &walletrpc.CreateNamedWalletRequest{
+		Name:           ctx.Args().First(),
+		WalletPassword: password,
+		RecoveryWindow: int32(ctx.Int64("recovery_window")),
+	}
+
+	if ctx.Bool("restore") {
+		fmt.Printf("Input your 24-word mnemonic separated by spaces: ")
+		reader := bufio.NewReader(os.Stdin)
+		mnemonic, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+
+		mnemonic = strings.ToLower(strings.TrimSpace(mnemonic))
+		req.CipherSeedMnemonic = strings.Fields(mnemonic)
+
+		req.AezeedPassphrase, err = readPassword("Input your cipher " +
+			"seed passphrase (press enter if your seed doesn't " +
+			"have a passphrase): ")
+		if err != nil {
+			return err
+		}
+	} else {
+		req.AezeedPassphrase, err = capturePassword(
+			"Input your passphrase if you wish to encrypt the new "+
+				"seed (press enter to proceed without a "+
+				"passphrase): ", true,
+			func([]byte) error { return nil },
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := walletClient.CreateNamedWallet(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	if len(resp.CipherSeedMnemonic) > 0 {
+		printCipherSeedWords(resp.CipherSeedMnemonic)
+	}
+
+	fmt.Printf("Named wallet %v created and unlocked\n", req.Name)
+
+	return nil
+}
+
+var unlockNamedWalletCommand = cli.Command{
+	Name:      "unlock",
+	Usage:     "Unlock a named wallet.",
+	ArgsUsage: "name",
+	Description: `
+	Unlocks the named wallet with its password, which is prompted for.
+	Named wallets are locked when lnd starts.
+	`,
+	Action: actionDecorator(unlockNamedWallet),
+}
+
+func unlockNamedWallet(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if we do not have the expected
+	// number of arguments.
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "unlock")
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	password, err := readPassword("Input wallet password: ")
+	if err != nil {
+		return err
+	}
+
+	req := &walletrpc.UnlockNamedWalletRequest{
+		Name:           ctx.Args().First(),
+		WalletPassword: password,
+	}
+	resp, err := walletClient.UnlockNamedWallet(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var lockNamedWalletCommand = cli.Command{
+	Name:      "lock",
+	Usage:     "Lock a named wallet.",
+	ArgsUsage: "name",
+	Description: `
+	Locks the unlocked named wallet. Its funds can't be spent until it is
+	unlocked again.
+	`,
+	Action: actionDecorator(lockNamedWallet),
+}
+
+func lockNamedWallet(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if we do not have the expected
+	// number of arguments.
+	if ctx.NArg() != 1 {
+		return cli.ShowCommandHelp(ctx, "lock")
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	req := &walletrpc.LockNamedWalletRequest{
+		Name: ctx.Args().First(),
+	}
+	resp, err := walletClient.LockNamedWallet(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
+
+var listNamedWalletsCommand = cli.Command{
+	Name:  "list",
+	Usage: "List the named wallets.",
+	Description: `
+	Lists all named wallets and whether they are unlocked.
+	`,
+	Action: actionDecorator(listNamedWallets),
+}
+
+func listNamedWallets(ctx *cli.Context) error {
+	ctxc := getContext()
+
+	// Display the command's help message if we do not have the expected
+	// number of arguments/flags.
+	if ctx.NArg() > 0 || ctx.NumFlags() > 0 {
+		return cli.ShowCommandHelp(ctx, "list")
+	}
+
+	walletClient, cleanUp := getWalletClient(ctx)
+	defer cleanUp()
+
+	req := &walletrpc.ListNamedWalletsRequest{}
+	resp, err := walletClient.ListNamedWallets(ctxc, req)
+	if err != nil {
+		return err
+	}
+
+	printRespJSON(resp)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+// lnd-unlock-standin is a local stand-in for the key agents and plugins lnd
+// can fetch its wallet password from. It keeps the password sealed in a file
+// bound to the machine ID and serves it either as a plugin, reading a single
+// request from stdin and writing the response to stdout, or as a key agent
+// listening on a Unix socket.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/lightningnetwork/lnd/walletunlocker"
+)
+
+func main() {
+	sealedFile := flag.String(
+		"sealedfile", "", "the file the wallet password is sealed in",
+	)
+	listen := flag.String(
+		"listen", "", "serve as key agent on this Unix socket instead "+
+			"of serving a single request from stdin",
+	)
+	flag.Parse()
+
+	if *sealedFile == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "--sealedfile must be set")
+		os.Exit(1)
+	}
+	provider := walletunlocker.NewSealedFileProvider(*sealedFile)
+
+	ctx, cancel := signal.NotifyContext(
+		context.Background(), os.Interrupt, syscall.SIGTERM,
+	)
+	defer cancel()
+
+	var err error
+	if *listen == "" {
+		err = walletunlocker.ServeProviderRequest(
+			ctx, os.Stdin, os.Stdout, provider,
+		)
+	} else {
+		err = serveAgent(ctx, *listen, provider)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// serveAgent serves the requests of all connections to the socket until the
+// context is canceled.
+func serveAgent(ctx context.Context, socket string,
+	provider walletunlocker.PasswordProvider) error {
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	// Only the user running the agent may connect to it.
+	if err := os.Chmod(socket, 0600); err != nil {
+		return err
+	}
+
+	for {
+		conn, err := listener.Accept()
+		switch {
+		case errors.Is(err, net.ErrClosed):
+			return nil
+
+		case err != nil:
+			return err
+		}
+
+		err = walletunlocker.ServeProviderRequest(
+			ctx, conn, conn, provider,
+		)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+		}
+		_ = conn.Close()
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/lightningnetwork/lnd/autopilot"
 	"github.com/lightningnetwork/lnd/build"
 	"github.com/lightningnetwork/lnd/chainreg"
+	"github.com/lightningnetwork/lnd/chanarchive"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/discovery"
@@ -39,6 +40,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc/routerrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/signrpc"
 	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/signerpolicy"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/routing"
 	"github.com/lightningnetwork/lnd/signal"
@@ -51,6 +53,7 @@ const (
 	defaultChainSubDirname    = "chain"
 	defaultGraphSubDirname    = "graph"
 	defaultTowerSubDirname    = "watchtower"
+	defaultChanArchiveDirname = "channel-archive"
 	defaultTLSCertFilename    = "tls.cert"
 	defaultTLSKeyFilename     = "tls.key"
 	defaultAdminMacFilename   = "admin.macaroon"
@@ -59,6 +62,7 @@ const (
 	defaultLogLevel           = "info"
 	defaultLogDirname         = "logs"
 	defaultLogFilename        = "lnd.log"
+	defaultAuditLogFilename   = "audit.log"
 	defaultRPCPort            = 10009
 	defaultRESTPort           = 8080
 	defaultPeerPort           = 9735
@@ -235,6 +239,8 @@ const (
 	bitcoindBackendName = "bitcoind"
 	btcdBackendName     = "btcd"
 	neutrinoBackendName = "neutrino"
+	electrumBackendName = "electrum"
+	esploraBackendName  = "esplora"
 )
 
 var (
@@ -353,10 +359,16 @@ type Config struct {
 
 	FeeURL string `long:"feeurl" description:"Optional URL for external fee estimation. If no URL is specified, the method for fee estimation will depend on the chosen backend and network. Must be set for neutrino on mainnet."`
 
+	Fee *lncfg.Fee `group:"fee" namespace:"fee"`
+
 	Bitcoin      *lncfg.Chain    `group:"Bitcoin" namespace:"bitcoin"`
 	BtcdMode     *lncfg.Btcd     `group:"btcd" namespace:"btcd"`
 	BitcoindMode *lncfg.Bitcoind `group:"bitcoind" namespace:"bitcoind"`
 	NeutrinoMode *lncfg.Neutrino `group:"neutrino" namespace:"neutrino"`
+	ElectrumMode *lncfg.Electrum `group:"electrum" namespace:"electrum"`
+	EsploraMode  *lncfg.Esplora  `group:"esplora" namespace:"esplora"`
+
+	ChainFailover *lncfg.ChainFailover `group:"chainfailover" namespace:"chainfailover"`
 
 	BlockCacheSize uint64 `long:"blockcachesize" description:"The maximum capacity of the block cache"`
 
@@ -372,11 +384,11 @@ type Config struct {
 
 	NoSeedBackup             bool   `long:"noseedbackup" description:"If true, NO SEED WILL BE EXPOSED -- EVER, AND THE WALLET WILL BE ENCRYPTED USING THE DEFAULT PASSPHRASE. THIS FLAG IS ONLY FOR TESTING AND SHOULD NEVER BE USED ON MAINNET."`
 	WalletUnlockPasswordFile string `long:"wallet-unlock-password-file" description:"The full path to a file (or pipe/device) that contains the password for unlocking the wallet; if set, no unlocking through RPC is possible and lnd will exit if no wallet exists or the password is incorrect; if wallet-unlock-allow-create is also set then lnd will ignore this flag if no wallet exists and allow a wallet to be created through RPC."`
-	WalletUnlockAllowCreate  bool   `long:"wallet-unlock-allow-create" description:"Don't fail with an error if wallet-unlock-password-file is set but no wallet exists yet."`
+	WalletUnlockAllowCreate  bool   `long:"wallet-unlock-allow-create" description:"Don't fail with an error if wallet-unlock-password-file or unlock.provider is set but no wallet exists yet."`
 
 	ResetWalletTransactions bool `long:"reset-wallet-transactions" description:"Removes all transaction history from the on-chain wallet on startup, forcing a full chain rescan starting at the wallet's birthday. Implements the same functionality as btcwallet's dropwtxmgr command. Should be set to false after successful execution to avoid rescanning on every restart of lnd."`
 
-	CoinSelectionStrategy string `long:"coin-selection-strategy" description:"The strategy to use for selecting coins for wallet transactions." choice:"largest" choice:"random"`
+	CoinSelectionStrategy string `long:"coin-selection-strategy" description:"The strategy to use for selecting coins for wallet transactions." choice:"largest" choice:"random" choice:"bnb" choice:"privacy"`
 
 	PaymentsExpirationGracePeriod time.Duration `long:"payments-expiration-grace-period" description:"A period to wait before force closing channels with outgoing htlcs that have timed-out and are a result of this node initiated payments."`
 	TrickleDelay                  int           `long:"trickledelay" description:"Time in milliseconds between each release of announcements to the network"`
@@ -469,10 +481,24 @@ type Config struct {
 
 	RPCMiddleware *lncfg.RPCMiddleware `group:"rpcmiddleware" namespace:"rpcmiddleware"`
 
+	AuditLog *lncfg.AuditLog `group:"auditlog" namespace:"auditlog"`
+
+	RPCRateLimit *lncfg.RPCRateLimit `group:"rpcratelimit" namespace:"rpcratelimit"`
+
 	RemoteSigner *lncfg.RemoteSigner `group:"remotesigner" namespace:"remotesigner"`
 
+	Unlock *lncfg.Unlock `group:"unlock" namespace:"unlock"`
+
 	Sweeper *lncfg.Sweeper `group:"sweeper" namespace:"sweeper"`
 
+	Breach *lncfg.Breach `group:"breach" namespace:"breach"`
+
+	ChanBackup *lncfg.ChanBackup `group:"chanbackup" namespace:"chanbackup"`
+
+	ChanArchive *lncfg.ChanArchive `group:"chanarchive" namespace:"chanarchive"`
+
+	SignerPolicy *lncfg.SignerPolicy `group:"signerpolicy" namespace:"signerpolicy"`
+
 	Htlcswitch *lncfg.Htlcswitch `group:"htlcswitch" namespace:"htlcswitch"`
 
 	GRPC *GRPCConfig `group:"grpc" namespace:"grpc"`
@@ -575,6 +601,10 @@ func DefaultConfig() Config {
 			UserAgentName:    neutrino.UserAgentName,
 			UserAgentVersion: neutrino.UserAgentVersion,
 		},
+		ElectrumMode:       lncfg.DefaultElectrum(),
+		EsploraMode:        lncfg.DefaultEsplora(),
+		ChainFailover:      defaultChainFailover(),
+		Fee:                lncfg.DefaultFee(),
 		BlockCacheSize:     defaultBlockCacheSize,
 		MaxPendingChannels: lncfg.DefaultMaxPendingChannels,
 		NoSeedBackup:       defaultNoSeedBackup,
@@ -680,6 +710,9 @@ func DefaultConfig() Config {
 		DB:                        lncfg.DefaultDB(),
 		Cluster:                   lncfg.DefaultCluster(),
 		RPCMiddleware:             lncfg.DefaultRPCMiddleware(),
+		AuditLog:                  lncfg.DefaultAuditLog(),
+		RPCRateLimit:              lncfg.DefaultRPCRateLimit(),
+		Unlock:                    lncfg.DefaultUnlock(),
 		ActiveNetParams:           chainreg.BitcoinTestNetParams,
 		ChannelCommitInterval:     defaultChannelCommitInterval,
 		PendingCommitInterval:     defaultPendingCommitInterval,
@@ -693,9 +726,35 @@ func DefaultConfig() Config {
 			BatchWindowDuration: sweep.DefaultBatchWindowDuration,
 			MaxFeeRate:          sweep.DefaultMaxFeeRate,
 		},
+		Breach: &lncfg.Breach{
+			HtlcStrategy: lncfg.BreachHtlcStrategyBatch,
+		},
 		Htlcswitch: &lncfg.Htlcswitch{
 			MailboxDeliveryTimeout: htlcswitch.DefaultMailboxDeliveryTimeout,
 		},
+		ChanBackup: &lncfg.ChanBackup{
+			MaxVersions: chanbackup.DefaultMaxBackupVersions,
+			S3: &lncfg.ChanBackupS3{
+				Region:      chanbackup.DefaultS3Region,
+				MaxVersions: chanbackup.DefaultMaxBackupVersions,
+				Timeout:     chanbackup.DefaultS3Timeout,
+			},
+			Webhook: &lncfg.ChanBackupWebhook{
+				MaxAttempts: chanbackup.DefaultWebhookMaxAttempts,
+				RetryDelay:  chanbackup.DefaultWebhookRetryDelay,
+				Timeout:     chanbackup.DefaultWebhookTimeout,
+			},
+		},
+		ChanArchive: &lncfg.ChanArchive{
+			SafetyDepth: chanarchive.DefaultSafetyDepth,
+			Interval:    chanarchive.DefaultInterval,
+		},
+		SignerPolicy: &lncfg.SignerPolicy{
+			SendWindow: signerpolicy.DefaultSendWindow,
+			MaxCommitFeeRate: signerpolicy.DefaultMaxCommitFeeRate.
+				FeePerVByte(),
+			MaxCommitHTLCs: signerpolicy.DefaultMaxCommitHTLCs,
+		},
 		GRPC: &GRPCConfig{
 			ServerPingTime:    defaultGrpcServerPingTime,
 			ServerPingTimeout: defaultGrpcServerPingTimeout,
@@ -940,13 +999,30 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		cfg.BitcoindMode.ConfigPath,
 	)
 	cfg.BitcoindMode.RPCCookie = CleanAndExpandPath(cfg.BitcoindMode.RPCCookie)
+	cfg.ChainFailover.Bitcoind.Dir = CleanAndExpandPath(
+		cfg.ChainFailover.Bitcoind.Dir,
+	)
+	cfg.ChainFailover.Bitcoind.ConfigPath = CleanAndExpandPath(
+		cfg.ChainFailover.Bitcoind.ConfigPath,
+	)
+	cfg.ChainFailover.Bitcoind.RPCCookie = CleanAndExpandPath(
+		cfg.ChainFailover.Bitcoind.RPCCookie,
+	)
+	cfg.ElectrumMode.TLSCertPath = CleanAndExpandPath(
+		cfg.ElectrumMode.TLSCertPath,
+	)
 	cfg.Tor.PrivateKeyPath = CleanAndExpandPath(cfg.Tor.PrivateKeyPath)
 	cfg.Tor.WatchtowerKeyPath = CleanAndExpandPath(cfg.Tor.WatchtowerKeyPath)
 	cfg.Watchtower.TowerDir = CleanAndExpandPath(cfg.Watchtower.TowerDir)
 	cfg.BackupFilePath = CleanAndExpandPath(cfg.BackupFilePath)
+	cfg.ChanBackup.Dir = CleanAndExpandPath(cfg.ChanBackup.Dir)
+	cfg.ChanArchive.Dir = CleanAndExpandPath(cfg.ChanArchive.Dir)
 	cfg.WalletUnlockPasswordFile = CleanAndExpandPath(
 		cfg.WalletUnlockPasswordFile,
 	)
+	cfg.Unlock.AgentSocket = CleanAndExpandPath(cfg.Unlock.AgentSocket)
+	cfg.Unlock.PluginPath = CleanAndExpandPath(cfg.Unlock.PluginPath)
+	cfg.Unlock.SealedFile = CleanAndExpandPath(cfg.Unlock.SealedFile)
 
 	// Ensure that the user didn't attempt to specify negative values for
 	// any of the autopilot params.
@@ -1263,17 +1339,50 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 	case neutrinoBackendName:
 		// No need to get RPC parameters.
 
+	case electrumBackendName:
+		if err := cfg.ElectrumMode.Validate(); err != nil {
+			return nil, mkErr("invalid electrum config: %v", err)
+		}
+
+		// An Electrum server doesn't serve full blocks, so the
+		// funding outputs of channels in the graph can't be
+		// validated.
+		cfg.Routing.AssumeChannelValid = true
+
+	case esploraBackendName:
+		if err := cfg.EsploraMode.Validate(); err != nil {
+			return nil, mkErr("invalid esplora config: %v", err)
+		}
+
 	case "nochainbackend":
 		// Nothing to configure, we're running without any chain
 		// backend whatsoever (pure signing mode).
 
 	default:
-		str := "only btcd, bitcoind, and neutrino mode " +
-			"supported for bitcoin at this time"
+		str := "only btcd, bitcoind, neutrino, electrum and " +
+			"esplora mode supported for bitcoin at this time"
 
 		return nil, mkErr(str)
 	}
 
+	if err := validateChainFailover(&cfg); err != nil {
+		return nil, mkErr("invalid chainfailover config: %v", err)
+	}
+
+	// The fee estimation sources must be available with the configured
+	// chain backend.
+	switch {
+	case cfg.Fee.HasSource(lncfg.FeeSourceWebAPI) && cfg.FeeURL == "":
+		return nil, mkErr("fee.source=%v requires feeurl to be set",
+			lncfg.FeeSourceWebAPI)
+
+	case cfg.Fee.HasSource(lncfg.FeeSourceMempool) &&
+		cfg.Bitcoin.Node != bitcoindBackendName:
+
+		return nil, mkErr("fee.source=%v requires bitcoin.node=%v",
+			lncfg.FeeSourceMempool, bitcoindBackendName)
+	}
+
 	cfg.Bitcoin.ChainDir = filepath.Join(
 		cfg.DataDir, defaultChainSubDirname, BitcoinChainName,
 	)
@@ -1392,6 +1501,14 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		)
 	}
 
+	// The closed channel archive is stored next to the channel database by
+	// default.
+	if cfg.ChanArchive.Dir == "" {
+		cfg.ChanArchive.Dir = filepath.Join(
+			cfg.graphDatabaseDir(), defaultChanArchiveDirname,
+		)
+	}
+
 	// Append the network type to the log directory so it is "namespaced"
 	// per network in the same fashion as the data directory.
 	cfg.LogDir = filepath.Join(
@@ -1482,10 +1599,25 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		return nil, mkErr("cannot set noseedbackup and " +
 			"wallet-unlock-password-file at the same time")
 
-	// The "allow-create" flag cannot be set without the auto unlock file.
-	case cfg.WalletUnlockAllowCreate && cfg.WalletUnlockPasswordFile == "":
+	// The no seed backup and the unlock provider are mutually exclusive
+	// too.
+	case cfg.NoSeedBackup && cfg.Unlock.Enabled():
+		return nil, mkErr("cannot set noseedbackup and " +
+			"unlock.provider at the same time")
+
+	// Only one source of the password for auto unlocking can be used.
+	case cfg.WalletUnlockPasswordFile != "" && cfg.Unlock.Enabled():
+		return nil, mkErr("cannot set wallet-unlock-password-file " +
+			"and unlock.provider at the same time")
+
+	// The "allow-create" flag cannot be set without the auto unlock file
+	// or provider.
+	case cfg.WalletUnlockAllowCreate &&
+		cfg.WalletUnlockPasswordFile == "" && !cfg.Unlock.Enabled():
+
 		return nil, mkErr("cannot set wallet-unlock-allow-create " +
-			"without wallet-unlock-password-file")
+			"without wallet-unlock-password-file or " +
+			"unlock.provider")
 
 	// If a password file was specified, we need it to exist.
 	case cfg.WalletUnlockPasswordFile != "" &&
@@ -1680,9 +1812,17 @@ func ValidateConfig(cfg Config, interceptor signal.Interceptor, fileParser,
 		cfg.Cluster,
 		cfg.HealthChecks,
 		cfg.RPCMiddleware,
+		cfg.AuditLog,
+		cfg.RPCRateLimit,
 		cfg.RemoteSigner,
+		cfg.Unlock,
 		cfg.Sweeper,
+		cfg.Breach,
 		cfg.Htlcswitch,
+		cfg.ChanBackup,
+		cfg.ChanArchive,
+		cfg.SignerPolicy,
+		cfg.Fee,
 	)
 	if err != nil {
 		return nil, err
@@ -1771,6 +1911,67 @@ func CleanAndExpandPath(path string) string {
 	return filepath.Clean(os.ExpandEnv(path))
 }
 
+// defaultChainFailover returns the default configuration of the fallback chain
+// backend. Unlike the primary bitcoind, a fallback bitcoind has no default
+// directory or RPC host, as those would point to the primary one.
+func defaultChainFailover() *lncfg.ChainFailover {
+	return lncfg.DefaultChainFailover(&lncfg.Bitcoind{
+		EstimateMode:       defaultBitcoindEstimateMode,
+		PrunedNodeMaxPeers: defaultPrunedNodeMaxPeers,
+		ZMQReadDeadline:    defaultZMQReadDeadline,
+	})
+}
+
+// validateChainFailover checks the configuration of the fallback chain backend
+// and loads the RPC credentials it needs.
+func validateChainFailover(cfg *Config) error {
+	failover := cfg.ChainFailover
+	if !failover.Active() {
+		return nil
+	}
+
+	if cfg.Bitcoin.Node == "nochainbackend" {
+		return fmt.Errorf("a fallback chain backend can't be used " +
+			"without a primary one")
+	}
+
+	if err := failover.Validate(cfg.Bitcoin.Node); err != nil {
+		return err
+	}
+
+	switch failover.Node {
+	case btcdBackendName:
+		err := parseRPCParams(
+			cfg.Bitcoin, cfg.BtcdMode, cfg.ActiveNetParams,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to load RPC credentials for "+
+				"btcd: %v", err)
+		}
+
+	case bitcoindBackendName:
+		if cfg.Bitcoin.SimNet {
+			return fmt.Errorf("bitcoind does not support simnet")
+		}
+
+		err := parseRPCParams(
+			cfg.Bitcoin, failover.Bitcoind, cfg.ActiveNetParams,
+		)
+		if err != nil {
+			return fmt.Errorf("unable to load RPC credentials for "+
+				"bitcoind: %v", err)
+		}
+
+	case electrumBackendName:
+		return cfg.ElectrumMode.Validate()
+
+	case esploraBackendName:
+		return cfg.EsploraMode.Validate()
+	}
+
+	return nil
+}
+
 func parseRPCParams(cConfig *lncfg.Chain, nodeConfig interface{},
 	netParams chainreg.BitcoinNetParams) error {
 
@@ -2208,10 +2409,22 @@ func configToFlatMap(cfg Config) (map[string]string,
 	// redact is the helper function that redacts sensitive values like
 	// passwords.
 	redact := func(key, value string) string {
+		// The webhook URL may carry credentials in its user info or
+		// query string, e.g. an access token.
+		sensitiveKeys := []string{
+			"chanbackup.webhook.url",
+		}
+		for _, sensitiveKey := range sensitiveKeys {
+			if key == sensitiveKey {
+				return "[redacted]"
+			}
+		}
+
 		sensitiveKeySuffixes := []string{
 			"pass",
 			"password",
 			"dsn",
+			"secret",
 		}
 		for _, suffix := range sensitiveKeySuffixes {
 			if strings.HasSuffix(key, suffix) {
@@ -41,6 +41,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/btcwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 	"github.com/lightningnetwork/lnd/lnwallet/rpcwallet"
 	"github.com/lightningnetwork/lnd/macaroons"
 	"github.com/lightningnetwork/lnd/rpcperms"
@@ -258,11 +259,14 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 	blockCache := blockcache.NewBlockCache(d.cfg.BlockCacheSize)
 
 	// Before starting the wallet, we'll create and start our Neutrino
-	// light client instance, if enabled, in order to allow it to sync
-	// while the rest of the daemon continues startup.
+	// light client instance, if enabled either as the primary or as the
+	// fallback chain backend, in order to allow it to sync while the rest
+	// of the daemon continues startup.
 	mainChain := d.cfg.Bitcoin
 	var neutrinoCS *neutrino.ChainService
-	if mainChain.Node == "neutrino" {
+	if mainChain.Node == "neutrino" ||
+		d.cfg.ChainFailover.Node == "neutrino" {
+
 		neutrinoBackend, neutrinoCleanUp, err := initNeutrinoBackend(
 			ctx, d.cfg, mainChain.ChainDir, blockCache,
 		)
@@ -317,6 +321,58 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 			"the wallet before using auto unlocking")
 	}
 
+	// The same applies if the password is kept by an external unlock
+	// provider.
+	var unlockProvider walletunlocker.PasswordProvider
+	if d.cfg.Unlock.Enabled() {
+		if !walletExists && !d.cfg.WalletUnlockAllowCreate {
+			return nil, nil, nil, fmt.Errorf("unlock provider " +
+				"was specified but wallet does not exist; " +
+				"initialize the wallet before using auto " +
+				"unlocking")
+		}
+
+		unlockProvider, err = walletunlocker.NewPasswordProvider(
+			&walletunlocker.ProviderConfig{
+				Type:        d.cfg.Unlock.Provider,
+				KeyID:       d.cfg.Unlock.KeyID,
+				AgentSocket: d.cfg.Unlock.AgentSocket,
+				PluginPath:  d.cfg.Unlock.PluginPath,
+				PluginArgs:  d.cfg.Unlock.PluginArgs,
+				SealedFile:  d.cfg.Unlock.SealedFile,
+			},
+		)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// Try to unlock the wallet with the password of the unlock provider,
+	// unless the password is to be provided over RPC on this start.
+	var (
+		providerPw     []byte
+		providerWallet *wallet.Wallet
+		providerUnload func() error
+	)
+	if unlockProvider != nil && walletExists && !d.cfg.Unlock.Manual {
+		providerPw, providerWallet, providerUnload, err =
+			unlockWithProvider(
+				ctx, d.cfg, unlockProvider, d.pwService,
+				d.interceptor.ShutdownChannel(),
+			)
+		switch {
+		case err == nil:
+
+		case d.cfg.Unlock.RPCFallback:
+			d.logger.Errorf("Unable to unlock wallet with unlock "+
+				"provider, waiting for password over RPC: %v",
+				err)
+
+		default:
+			return nil, nil, nil, err
+		}
+	}
+
 	// What wallet mode are we running in? We've already made sure the no
 	// seed backup and auto unlock aren't both set during config parsing.
 	switch {
@@ -363,6 +419,20 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 		walletInitParams.Wallet = unlockedWallet
 		walletInitParams.UnloadWallet = unloadWalletFn
 
+	// The wallet was unlocked with the password of the unlock provider.
+	case providerWallet != nil:
+		cleanUpTasks = append(cleanUpTasks, func() {
+			if err := providerUnload(); err != nil {
+				d.logger.Errorf("Could not unload wallet: %v",
+					err)
+			}
+		})
+
+		privateWalletPw = providerPw
+		publicWalletPw = providerPw
+		walletInitParams.Wallet = providerWallet
+		walletInitParams.UnloadWallet = providerUnload
+
 	// If none of the automatic startup options are selected, we fall back
 	// to the default behavior of waiting for the wallet creation/unlocking
 	// over RPC.
@@ -397,6 +467,27 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 				"address lookahead of %d addresses",
 				walletInitParams.RecoveryWindow)
 		}
+
+		// The password provided over RPC is stored in the unlock
+		// provider, so the wallet is unlocked with it on the next
+		// start. This is how a new or changed password gets to the
+		// provider.
+		if unlockProvider != nil {
+			storeCtx, cancel := context.WithTimeout(
+				ctx, d.cfg.Unlock.Timeout,
+			)
+			err := unlockProvider.StorePassword(
+				storeCtx, walletInitParams.Password,
+			)
+			cancel()
+			if err != nil {
+				d.logger.Errorf("Unable to store wallet "+
+					"password in unlock provider: %v", err)
+			} else {
+				d.logger.Infof("Stored wallet password in " +
+					"unlock provider")
+			}
+		}
 	}
 
 	var macaroonService *macaroons.Service
@@ -406,10 +497,13 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 		if err != nil {
 			return nil, nil, nil, err
 		}
-		macaroonService, err = macaroons.NewService(
-			rootKeyStore, "lnd", walletInitParams.StatelessInit,
+		checks := append([]macaroons.Checker{
 			macaroons.IPLockChecker,
 			macaroons.CustomChecker(interceptorChain),
+		}, macaroons.LimitCheckers()...)
+		macaroonService, err = macaroons.NewService(
+			rootKeyStore, "lnd", walletInitParams.StatelessInit,
+			checks...,
 		)
 		if err != nil {
 			err := fmt.Errorf("unable to set up macaroon "+
@@ -417,6 +511,18 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 			d.logger.Error(err)
 			return nil, nil, nil, err
 		}
+
+		// The spending windows of macaroons with a spend limit are
+		// tracked in the macaroon database as well.
+		macaroonService.SpendStore, err = macaroons.NewSpendStore(
+			dbs.MacaroonDB, clock.NewDefaultClock(),
+		)
+		if err != nil {
+			err := fmt.Errorf("unable to set up macaroon spend "+
+				"store: %v", err)
+			d.logger.Error(err)
+			return nil, nil, nil, err
+		}
 		cleanUpTasks = append(cleanUpTasks, func() {
 			if err := macaroonService.Close(); err != nil {
 				d.logger.Errorf("Could not close macaroon "+
@@ -546,11 +652,15 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 		NeutrinoMode:                d.cfg.NeutrinoMode,
 		BitcoindMode:                d.cfg.BitcoindMode,
 		BtcdMode:                    d.cfg.BtcdMode,
+		ElectrumMode:                d.cfg.ElectrumMode,
+		EsploraMode:                 d.cfg.EsploraMode,
+		ChainFailover:               d.cfg.ChainFailover,
 		HeightHintDB:                dbs.HeightHintDB,
 		ChanStateDB:                 dbs.ChanStateDB.ChannelStateDB(),
 		NeutrinoCS:                  neutrinoCS,
 		ActiveNetParams:             d.cfg.ActiveNetParams,
 		FeeURL:                      d.cfg.FeeURL,
+		Fee:                         d.cfg.Fee,
 		Dialer: func(addr string) (net.Conn, error) {
 			return d.cfg.net.Dial(
 				"tcp", addr, d.cfg.ConnectionTimeout,
@@ -596,6 +706,14 @@ func (d *DefaultWalletImpl) BuildWalletConfig(ctx context.Context,
 	case "random":
 		walletConfig.CoinSelectionStrategy = wallet.CoinSelectionRandom
 
+	case "bnb":
+		walletConfig.CoinSelectionStrategy =
+			chanfunding.CoinSelectionBranchAndBound
+
+	case "privacy":
+		walletConfig.CoinSelectionStrategy =
+			chanfunding.CoinSelectionPrivacy
+
 	default:
 		return nil, nil, nil, fmt.Errorf("unknown coin selection "+
 			"strategy %v", d.cfg.CoinSelectionStrategy)
@@ -702,8 +820,10 @@ func (d *DefaultWalletImpl) BuildChainControl(
 	}
 
 	// The broadcast is already always active for neutrino nodes, so we
-	// don't want to create a rebroadcast loop.
-	if partialChainControl.Cfg.NeutrinoCS == nil {
+	// don't want to create a rebroadcast loop. A neutrino light client
+	// that only serves as fallback chain backend doesn't broadcast the
+	// transactions of the wallet though.
+	if partialChainControl.Cfg.Bitcoin.Node != "neutrino" {
 		broadcastCfg := pushtx.Config{
 			Broadcast: func(tx *wire.MsgTx) error {
 				cs := partialChainControl.ChainSource
@@ -739,7 +859,37 @@ func (d *DefaultWalletImpl) BuildChainControl(
 		return nil, nil, err
 	}
 
-	return activeChainControl, cleanUp, nil
+	// Named wallets are stored in their own bbolt databases next to the
+	// main wallet, so they are only available with the bolt backend.
+	if d.cfg.DB.Backend != lncfg.BoltBackend || d.watchOnly {
+		return activeChainControl, cleanUp, nil
+	}
+
+	namedWalletsDir := filepath.Join(
+		d.cfg.networkDir, walletunlocker.NamedWalletsDir,
+	)
+	namedWallets := walletunlocker.NewNamedWalletManager(
+		&walletunlocker.NamedWalletConfig{
+			Dir:            namedWalletsDir,
+			NetParams:      walletConfig.NetParams,
+			CoinType:       walletConfig.CoinType,
+			NewChainSource: partialChainControl.NewChainSource,
+			BlockCache:     partialChainControl.Cfg.BlockCache,
+			NoFreelistSync: !d.cfg.SyncFreelist,
+			DBTimeout:      d.cfg.DB.Bolt.DBTimeout,
+			CoinSelectionStrategy: walletConfig.
+				CoinSelectionStrategy,
+		},
+	)
+	activeChainControl.NamedWallets = namedWallets
+
+	return activeChainControl, func() {
+		if err := namedWallets.Stop(); err != nil {
+			d.logger.Errorf("Unable to lock named wallets: %v", err)
+		}
+
+		cleanUp()
+	}, nil
 }
 
 // RPCSignerWalletImpl is a wallet implementation that uses a remote signer over
@@ -1259,6 +1409,57 @@ func waitForWalletPassword(cfg *Config,
 	}
 }
 
+// unlockWithProvider fetches the wallet password from the unlock provider and
+// unlocks the wallet with it. Both steps are retried as configured, as the
+// provider might not be available yet when lnd starts.
+func unlockWithProvider(ctx context.Context, cfg *Config,
+	provider walletunlocker.PasswordProvider,
+	pwService *walletunlocker.UnlockerService,
+	shutdownChan <-chan struct{}) ([]byte, *wallet.Wallet, func() error,
+	error) {
+
+	ltndLog.Infof("Attempting automatic wallet unlock with password from "+
+		"%v unlock provider", cfg.Unlock.Provider)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var password []byte
+		providerCtx, cancel := context.WithTimeout(
+			ctx, cfg.Unlock.Timeout,
+		)
+		password, err = provider.Password(providerCtx)
+		cancel()
+		if err == nil {
+			w, unload, unlockErr := pwService.LoadAndUnlock(
+				password, 0,
+			)
+			if unlockErr == nil {
+				return password, w, unload, nil
+			}
+
+			err = fmt.Errorf("error unlocking wallet with "+
+				"password from unlock provider: %w", unlockErr)
+		} else {
+			err = fmt.Errorf("error fetching password from unlock "+
+				"provider: %w", err)
+		}
+
+		if attempt >= cfg.Unlock.Retries {
+			return nil, nil, nil, err
+		}
+
+		ltndLog.Warnf("Unable to unlock wallet (attempt %d of %d), "+
+			"retrying in %v: %v", attempt+1, cfg.Unlock.Retries+1,
+			cfg.Unlock.RetryInterval, err)
+
+		select {
+		case <-time.After(cfg.Unlock.RetryInterval):
+		case <-shutdownChan:
+			return nil, nil, nil, fmt.Errorf("shutting down")
+		}
+	}
+}
+
 // importWatchOnlyAccounts imports all individual account xpubs into our wallet
 // which we created as watch-only.
 func importWatchOnlyAccounts(wallet *wallet.Wallet,
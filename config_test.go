@@ -23,6 +23,9 @@ func TestConfigToFlatMap(t *testing.T) {
 	cfg.Tor.Password = testPassword
 	cfg.DB.Etcd.Pass = testPassword
 	cfg.DB.Postgres.Dsn = testPassword
+	cfg.ChanBackup.S3.Secret = testPassword
+	cfg.ChanBackup.Webhook.URL = "https://user:pw@example.com/?token=" +
+		testPassword
 
 	// Set a deprecated field.
 	cfg.Bitcoin.Active = true
@@ -51,6 +54,10 @@ func TestConfigToFlatMap(t *testing.T) {
 	require.Equal(t, redactedPassword, result["tor.password"])
 	require.Equal(t, redactedPassword, result["db.etcd.pass"])
 	require.Equal(t, redactedPassword, result["db.postgres.dsn"])
+	require.Equal(t, redactedPassword, result["chanbackup.s3.secret"])
+	require.Equal(
+		t, redactedPassword, result["chanbackup.webhook.url"],
+	)
 }
 
 // TestSupplyEnvValue tests that the supplyEnvValue function works as
@@ -112,7 +112,7 @@ func (c *anchorResolver) Resolve() (ContractResolver, error) {
 		c.broadcastHeight, nil,
 	)
 
-	resultChan, err := c.Sweeper.SweepInput(
+	resultChan, err := c.sweepInput(
 		&anchorInput,
 		sweep.Params{
 			Fee: sweep.FeePreference{
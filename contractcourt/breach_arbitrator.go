@@ -21,6 +21,7 @@ import (
 	"github.com/lightningnetwork/lnd/labels"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/subscribe"
 )
 
 const (
@@ -171,6 +172,19 @@ type BreachConfig struct {
 	// breached channels. This is used in conjunction with DB to recover
 	// from crashes, restarts, or other failures.
 	Store RetributionStorer
+
+	// Sweeper is used to sweep the outputs of a breached commitment. The
+	// justice spends are offered with a fee preference derived from the
+	// deadline at which the breaching party is able to claim the outputs,
+	// and the outputs of concurrent breaches are batched. If nil, a
+	// justice transaction is created and published for every breach.
+	Sweeper UtxoSweeper
+
+	// HtlcStrategy determines whether the HTLC outputs of a breached
+	// commitment, and the second level outputs the breaching party may
+	// take them to, are batched with the other justice spends or swept
+	// separately. It is only used if a Sweeper is set.
+	HtlcStrategy JusticeHtlcStrategy
 }
 
 // BreachArbitrator is a special subsystem which is responsible for watching and
@@ -189,6 +203,13 @@ type BreachArbitrator struct {
 
 	subscriptions map[wire.OutPoint]chan struct{}
 
+	// batchDeadlines holds the earliest deadline of the batched justice
+	// inputs of every breach that is being swept by the sweeper.
+	batchDeadlines map[wire.OutPoint]uint32
+
+	// ntfnServer dispatches breach events to the subscribers.
+	ntfnServer *subscribe.Server
+
 	quit chan struct{}
 	wg   sync.WaitGroup
 	sync.Mutex
@@ -198,9 +219,11 @@ type BreachArbitrator struct {
 // with its dependent objects.
 func NewBreachArbitrator(cfg *BreachConfig) *BreachArbitrator {
 	return &BreachArbitrator{
-		cfg:           cfg,
-		subscriptions: make(map[wire.OutPoint]chan struct{}),
-		quit:          make(chan struct{}),
+		cfg:            cfg,
+		subscriptions:  make(map[wire.OutPoint]chan struct{}),
+		batchDeadlines: make(map[wire.OutPoint]uint32),
+		ntfnServer:     subscribe.NewServer(),
+		quit:           make(chan struct{}),
 	}
 }
 
@@ -216,6 +239,10 @@ func (b *BreachArbitrator) Start() error {
 }
 
 func (b *BreachArbitrator) start() error {
+	if err := b.ntfnServer.Start(); err != nil {
+		return err
+	}
+
 	// Load all retributions currently persisted in the retribution store.
 	var breachRetInfos map[wire.OutPoint]retributionInfo
 	if err := b.cfg.Store.ForAll(func(ret *retributionInfo) error {
@@ -317,6 +344,11 @@ func (b *BreachArbitrator) Stop() error {
 
 		close(b.quit)
 		b.wg.Wait()
+
+		if err := b.ntfnServer.Stop(); err != nil {
+			brarLog.Warnf("Unable to stop breach event server: %v",
+				err)
+		}
 	})
 	return nil
 }
@@ -694,8 +726,9 @@ func (b *BreachArbitrator) exactRetribution(
 	defer b.wg.Done()
 
 	// TODO(roasbeef): state needs to be checkpointed here
+	var confHeight uint32
 	select {
-	case _, ok := <-confChan.Confirmed:
+	case conf, ok := <-confChan.Confirmed:
 		// If the second value is !ok, then the channel has been closed
 		// signifying a daemon shutdown, so we exit.
 		if !ok {
@@ -704,6 +737,7 @@ func (b *BreachArbitrator) exactRetribution(
 
 		// Otherwise, if this is a real confirmation notification, then
 		// we fall through to complete our duty.
+		confHeight = conf.BlockHeight
 	case <-b.quit:
 		return
 	}
@@ -711,6 +745,21 @@ func (b *BreachArbitrator) exactRetribution(
 	brarLog.Debugf("Breach transaction %v has been confirmed, sweeping "+
 		"revoked funds", breachInfo.commitHash)
 
+	b.notifyBreachEvent(&BreachEvent{
+		Type:           BreachEventConfirmed,
+		ChanPoint:      breachInfo.chanPoint,
+		BreachTxID:     breachInfo.commitHash,
+		BreachHeight:   breachInfo.breachHeight,
+		Height:         confHeight,
+		PendingOutputs: len(breachInfo.breachedOutputs),
+	})
+
+	// If we have a sweeper, the justice spends are handed to it.
+	if b.cfg.Sweeper != nil {
+		b.sweepRetribution(breachInfo)
+		return
+	}
+
 	// We may have to wait for some of the HTLC outputs to be spent to the
 	// second level before broadcasting the justice tx. We'll store the
 	// SpendEvents between each attempt to not re-register unnecessarily.
@@ -805,6 +854,15 @@ Loop:
 						breachInfo.chanPoint, err)
 				}
 
+				b.notifyBreachEvent(&BreachEvent{
+					Type:         BreachEventJusticeServed,
+					ChanPoint:    breachInfo.chanPoint,
+					BreachTxID:   breachInfo.commitHash,
+					BreachHeight: breachInfo.breachHeight,
+					RevokedFunds: revokedFunds,
+					TotalFunds:   totalFunds,
+				})
+
 				// TODO(roasbeef): add peer to blacklist?
 
 				// TODO(roasbeef): close other active channels
@@ -1032,6 +1090,13 @@ func (b *BreachArbitrator) handleBreachHandoff(
 		return
 	}
 
+	b.notifyBreachEvent(&BreachEvent{
+		Type:           BreachEventDetected,
+		ChanPoint:      chanPoint,
+		BreachTxID:     retInfo.commitHash,
+		PendingOutputs: len(retInfo.breachedOutputs),
+	})
+
 	// Now that a new channel contract has been added to the retribution
 	// store, we first register for a notification to be dispatched once
 	// the breach transaction (the revoked commitment transaction) has been
@@ -1059,6 +1124,78 @@ func (b *BreachArbitrator) handleBreachHandoff(
 	go b.exactRetribution(cfChan, retInfo)
 }
 
+// HandleMempoolBreach is called once a revoked commitment of the remote party
+// has been found in the mempool, before it confirms. The link of the channel is
+// closed right away, and a justice transaction sweeping the revoked outputs is
+// published as a child of the unconfirmed commitment, so it competes with any
+// attempt of the cheating party to take the HTLC outputs to the second level.
+//
+// NOTE: Nothing is persisted here. Once the revoked commitment confirms, the
+// breach is handed off as usual, and the justice transaction created then
+// accounts for the outputs already swept by this one.
+func (b *BreachArbitrator) HandleMempoolBreach(chanPoint wire.OutPoint,
+	breachInfo *lnwallet.BreachRetribution) {
+
+	brarLog.Warnf("REVOKED STATE #%v FOR ChannelPoint(%v) found in the "+
+		"mempool, REMOTE PEER IS DOING SOMETHING SKETCHY!!!",
+		breachInfo.RevokedStateNum, chanPoint)
+
+	// Immediately close the link, so no HTLCs are sent over the channel
+	// while the revoked commitment is waiting to confirm.
+	b.cfg.CloseLink(&chanPoint, CloseBreach)
+
+	// If the breach is already known to the retribution store, the revoked
+	// commitment has confirmed in the meantime, and the justice
+	// transaction is taken care of by exactRetribution.
+	breached, err := b.cfg.Store.IsBreached(&chanPoint)
+	if err != nil {
+		brarLog.Errorf("Unable to check breach info in DB: %v", err)
+		return
+	}
+	if breached {
+		return
+	}
+
+	// Outputs with a relative timelock can't be spent before the revoked
+	// commitment confirms, so they're left to the justice transaction
+	// created after the confirmation.
+	retInfo := newRetributionInfo(&chanPoint, breachInfo)
+
+	var inputs []input.Input
+	for i := range retInfo.breachedOutputs {
+		breachedOutput := &retInfo.breachedOutputs[i]
+		if breachedOutput.BlocksToMaturity() > 0 {
+			continue
+		}
+
+		inputs = append(inputs, breachedOutput)
+	}
+
+	if len(inputs) == 0 {
+		return
+	}
+
+	justiceTx, err := b.createSweepTx(inputs...)
+	if err != nil {
+		brarLog.Errorf("Unable to create justice tx for unconfirmed "+
+			"breach of ChannelPoint(%v): %v", chanPoint, err)
+		return
+	}
+
+	brarLog.Debugf("Broadcasting justice tx for unconfirmed breach: %v",
+		newLogClosure(func() string {
+			return spew.Sdump(justiceTx)
+		}))
+
+	label := labels.MakeLabel(labels.LabelTypeJusticeTransaction, nil)
+	err = b.cfg.PublishTransaction(justiceTx, label)
+	if err != nil {
+		brarLog.Errorf("Unable to broadcast justice tx for "+
+			"unconfirmed breach of ChannelPoint(%v): %v",
+			chanPoint, err)
+	}
+}
+
 // breachedOutput contains all the information needed to sweep a breached
 // output. A breached output is an output that we are now entitled to due to a
 // revoked commitment transaction being broadcast.
@@ -1196,6 +1333,11 @@ type retributionInfo struct {
 	breachHeight uint32
 
 	breachedOutputs []breachedOutput
+
+	// breacherDelay is the CSV delay of the to_local output of the
+	// breaching party, which bounds the time we have to sweep the revoked
+	// outputs. It is zero for retributions stored by older versions.
+	breacherDelay uint32
 }
 
 // newRetributionInfo constructs a retributionInfo containing all the
@@ -1340,6 +1482,7 @@ func newRetributionInfo(chanPoint *wire.OutPoint,
 		chanPoint:       *chanPoint,
 		breachedOutputs: breachedOutputs,
 		breachHeight:    breachInfo.BreachHeight,
+		breacherDelay:   breachInfo.RemoteDelay,
 	}
 }
 
@@ -1911,6 +2054,11 @@ func (ret *retributionInfo) Encode(w io.Writer) error {
 		}
 	}
 
+	binary.BigEndian.PutUint32(scratch[:], ret.breacherDelay)
+	if _, err := w.Write(scratch[:]); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1958,6 +2106,18 @@ func (ret *retributionInfo) Decode(r io.Reader) error {
 		}
 	}
 
+	// The delay of the breaching party was added later, so it is absent
+	// in retributions stored by older versions.
+	_, err = io.ReadFull(r, scratch[:4])
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil
+
+	case err != nil:
+		return err
+	}
+	ret.breacherDelay = binary.BigEndian.Uint32(scratch[:4])
+
 	return nil
 }
 
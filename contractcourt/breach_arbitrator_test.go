@@ -284,8 +284,9 @@ var (
 				0xb7, 0x94, 0x38, 0x5f, 0x2d, 0x1e, 0xf7, 0xab,
 				0x6b, 0x49, 0x18, 0x83, 0x31, 0x98, 0x47, 0x53,
 			},
-			chanPoint:    breachOutPoints[0],
-			breachHeight: 337,
+			chanPoint:     breachOutPoints[0],
+			breachHeight:  337,
+			breacherDelay: 144,
 			// Set to breachedOutputs 0 and 1 in init()
 			breachedOutputs: []breachedOutput{{}, {}},
 		},
@@ -494,6 +495,24 @@ func TestRetributionSerialization(t *testing.T) {
 	}
 }
 
+// TestRetributionLegacyDecode tests that a retribution stored without the
+// delay of the breaching party can still be decoded.
+func TestRetributionLegacyDecode(t *testing.T) {
+	ret := copyRetInfo(&retributions[0])
+
+	var buf bytes.Buffer
+	require.NoError(t, ret.Encode(&buf))
+
+	// Strip the trailing delay to get the encoding of older versions.
+	legacy := buf.Bytes()[:buf.Len()-4]
+
+	desRet := &retributionInfo{}
+	require.NoError(t, desRet.Decode(bytes.NewReader(legacy)))
+
+	ret.breacherDelay = 0
+	require.Equal(t, ret, desRet)
+}
+
 // copyRetInfo creates a complete copy of the given retributionInfo.
 func copyRetInfo(retInfo *retributionInfo) *retributionInfo {
 	nOutputs := len(retInfo.breachedOutputs)
@@ -504,6 +523,7 @@ func copyRetInfo(retInfo *retributionInfo) *retributionInfo {
 		chanPoint:       retInfo.chanPoint,
 		breachHeight:    retInfo.breachHeight,
 		breachedOutputs: make([]breachedOutput, nOutputs),
+		breacherDelay:   retInfo.breacherDelay,
 	}
 
 	for i := range retInfo.breachedOutputs {
@@ -1169,6 +1189,80 @@ func TestBreachHandoffFail(t *testing.T) {
 	assertArbiterBreach(t, brar, &chanPoint)
 }
 
+// TestBreachMempoolJustice tests that a breach found in the mempool closes the
+// link and publishes a justice transaction spending the outputs of the
+// unconfirmed commitment, without persisting the breach.
+func TestBreachMempoolJustice(t *testing.T) {
+	brar, alice, _, bobClose, _ := initBreachedState(t)
+
+	var (
+		height       = bobClose.ChanSnapshot.CommitHeight
+		forceCloseTx = bobClose.CloseTx
+		chanPoint    = alice.ChannelPoint()
+		closeTypes   = make(chan ChannelCloseType, 1)
+		publTx       = make(chan *wire.MsgTx, 1)
+	)
+
+	brar.cfg.CloseLink = func(_ *wire.OutPoint,
+		closeType ChannelCloseType) {
+
+		closeTypes <- closeType
+	}
+	brar.cfg.PublishTransaction = func(tx *wire.MsgTx, _ string) error {
+		publTx <- tx
+		return nil
+	}
+
+	retribution, err := lnwallet.NewBreachRetribution(
+		alice.State(), height, 0, forceCloseTx,
+	)
+	require.NoError(t, err, "unable to create breach retribution")
+
+	brar.HandleMempoolBreach(chanPoint, retribution)
+
+	// The link should have been closed right away.
+	require.Equal(t, CloseBreach, <-closeTypes)
+
+	// The justice transaction should spend all outputs of the commitment
+	// that don't have a relative timelock.
+	var numInputs int
+	retInfo := newRetributionInfo(&chanPoint, retribution)
+	for _, breachedOutput := range retInfo.breachedOutputs {
+		if breachedOutput.BlocksToMaturity() == 0 {
+			numInputs++
+		}
+	}
+
+	var tx *wire.MsgTx
+	select {
+	case tx = <-publTx:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("justice tx was not published")
+	}
+
+	require.Len(t, tx.TxIn, numInputs)
+	for _, txIn := range tx.TxIn {
+		require.Equal(
+			t, forceCloseTx.TxHash(), txIn.PreviousOutPoint.Hash,
+		)
+	}
+
+	// Nothing should have been persisted, as the commitment hasn't
+	// confirmed yet.
+	assertNoArbiterBreach(t, brar, &chanPoint)
+
+	// Once the breach is known to the retribution store, a breach found in
+	// the mempool shouldn't lead to another justice transaction.
+	require.NoError(t, brar.cfg.Store.Add(retInfo))
+	brar.HandleMempoolBreach(chanPoint, retribution)
+
+	select {
+	case <-publTx:
+		t.Fatalf("justice tx published for known breach")
+	default:
+	}
+}
+
 // TestBreachCreateJusticeTx tests that we create three different variants of
 // the justice tx.
 func TestBreachCreateJusticeTx(t *testing.T) {
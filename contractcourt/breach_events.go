@@ -0,0 +1,147 @@
+package contractcourt
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/subscribe"
+)
+
+// BreachEventType describes a step in the process of exacting justice on a
+// breached channel.
+type BreachEventType uint8
+
+const (
+	// BreachEventDetected is sent when a revoked commitment of the remote
+	// party has been handed off to the breach arbitrator.
+	BreachEventDetected BreachEventType = iota
+
+	// BreachEventConfirmed is sent when the revoked commitment has
+	// confirmed and the justice spends of its outputs are started.
+	BreachEventConfirmed
+
+	// BreachEventFeeUpdated is sent when the fee preference of the justice
+	// spends of a breach changes as its deadline approaches.
+	BreachEventFeeUpdated
+
+	// BreachEventSecondLevel is sent when the breaching party took an HTLC
+	// output to the second level, and the justice spend moved on to the
+	// output of the second level transaction.
+	BreachEventSecondLevel
+
+	// BreachEventOutputSwept is sent when a breached output has been swept
+	// into our wallet.
+	BreachEventOutputSwept
+
+	// BreachEventOutputLost is sent when a breached output has been spent
+	// by the breaching party before our justice spend confirmed.
+	BreachEventOutputLost
+
+	// BreachEventJusticeServed is sent when all outputs of the revoked
+	// commitment have been resolved and the channel is fully closed.
+	BreachEventJusticeServed
+)
+
+// String returns a human readable representation of the event type.
+func (t BreachEventType) String() string {
+	switch t {
+	case BreachEventDetected:
+		return "Detected"
+
+	case BreachEventConfirmed:
+		return "Confirmed"
+
+	case BreachEventFeeUpdated:
+		return "FeeUpdated"
+
+	case BreachEventSecondLevel:
+		return "SecondLevel"
+
+	case BreachEventOutputSwept:
+		return "OutputSwept"
+
+	case BreachEventOutputLost:
+		return "OutputLost"
+
+	case BreachEventJusticeServed:
+		return "JusticeServed"
+
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(t))
+	}
+}
+
+// BreachEvent reports the progress of the justice spends of a breached
+// channel to the subscribers of the BreachArbitrator.
+type BreachEvent struct {
+	// Type is the step of the retribution this event reports.
+	Type BreachEventType
+
+	// ChanPoint is the channel point of the breached channel.
+	ChanPoint wire.OutPoint
+
+	// BreachTxID is the hash of the revoked commitment transaction.
+	BreachTxID chainhash.Hash
+
+	// BreachHeight is the height at which the revoked commitment
+	// confirmed. It is zero until the commitment has confirmed.
+	BreachHeight uint32
+
+	// Height is the best block height at the time of the event.
+	Height uint32
+
+	// OutPoint is the breached output the event relates to. It is nil for
+	// events that relate to the channel as a whole. For second level
+	// events it points to the output of the second level transaction.
+	OutPoint *wire.OutPoint
+
+	// WitnessType is the witness type used to spend OutPoint.
+	WitnessType input.WitnessType
+
+	// Amount is the value of OutPoint.
+	Amount btcutil.Amount
+
+	// SpendTxID is the transaction that spent the breached output, if
+	// any.
+	SpendTxID *chainhash.Hash
+
+	// Deadline is the height before which the justice spends should
+	// confirm, as after it the breaching party is able to claim the
+	// outputs of the revoked commitment.
+	Deadline uint32
+
+	// ConfTarget is the confirmation target the justice spends are
+	// currently offered to the sweeper with.
+	ConfTarget uint32
+
+	// PendingOutputs is the number of breached outputs that are not yet
+	// resolved.
+	PendingOutputs int
+
+	// RevokedFunds is the amount of revoked funds of the breaching party
+	// that have been swept so far.
+	RevokedFunds btcutil.Amount
+
+	// TotalFunds is the total amount that has been swept so far.
+	TotalFunds btcutil.Amount
+}
+
+// SubscribeBreachEvents returns a subscribe.Client that receives a BreachEvent
+// for every step taken to exact justice on a breached channel, from the point
+// of subscription onwards.
+func (b *BreachArbitrator) SubscribeBreachEvents() (*subscribe.Client, error) {
+	return b.ntfnServer.Subscribe()
+}
+
+// notifyBreachEvent sends the given event to all breach event subscribers.
+func (b *BreachArbitrator) notifyBreachEvent(event *BreachEvent) {
+	brarLog.Debugf("Breach event %v for ChannelPoint(%v)", event.Type,
+		event.ChanPoint)
+
+	if err := b.ntfnServer.SendUpdate(event); err != nil {
+		brarLog.Warnf("Unable to send breach event update: %v", err)
+	}
+}
@@ -0,0 +1,630 @@
+package contractcourt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/sweep"
+)
+
+const (
+	// maxJusticeConfTarget is the most relaxed confirmation target the
+	// justice spends are offered to the sweeper with. The target is
+	// tightened as the deadline of a breach approaches.
+	maxJusticeConfTarget = 6
+
+	// defaultBreacherDelay is the CSV delay assumed for the outputs of the
+	// breaching party if it isn't known, which is the case for breaches
+	// that were persisted by an older version. It is the smallest delay we
+	// accept for the to_local output of the remote party.
+	defaultBreacherDelay = 144
+)
+
+// JusticeHtlcStrategy determines how the HTLC outputs of a revoked commitment,
+// and the outputs of the second level transactions the breaching party may use
+// to contest them, are swept.
+type JusticeHtlcStrategy uint8
+
+const (
+	// JusticeHtlcBatch sweeps the HTLC outputs in the same justice
+	// transaction as the commitment outputs of all active breaches. This
+	// saves fees, but allows the breaching party to delay the whole batch
+	// by pinning an HTLC output with a low fee second level transaction.
+	JusticeHtlcBatch JusticeHtlcStrategy = iota
+
+	// JusticeHtlcSeparate sweeps every HTLC output and second level
+	// output in a transaction of its own, with a fee rate derived from its
+	// own deadline. A contested HTLC can't hold back the justice spends
+	// of the other outputs.
+	JusticeHtlcSeparate
+)
+
+// String returns the name of the strategy as used in the configuration.
+func (s JusticeHtlcStrategy) String() string {
+	switch s {
+	case JusticeHtlcBatch:
+		return "batch"
+
+	case JusticeHtlcSeparate:
+		return "separate"
+
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
+// ParseJusticeHtlcStrategy returns the strategy with the given name. An empty
+// name selects the default strategy.
+func ParseJusticeHtlcStrategy(name string) (JusticeHtlcStrategy, error) {
+	switch name {
+	case "", JusticeHtlcBatch.String():
+		return JusticeHtlcBatch, nil
+
+	case JusticeHtlcSeparate.String():
+		return JusticeHtlcSeparate, nil
+
+	default:
+		return 0, fmt.Errorf("unknown justice htlc strategy: %v", name)
+	}
+}
+
+// justiceConfTarget returns the confirmation target for a justice spend that
+// must confirm before the given deadline. Half of the remaining blocks are
+// used as the target, which leaves room for the sweeper to bump the fee if
+// the spend doesn't confirm in time.
+func justiceConfTarget(deadline, height uint32) uint32 {
+	if height >= deadline {
+		return 1
+	}
+
+	target := (deadline - height) / 2
+	switch {
+	case target < 1:
+		return 1
+
+	case target > maxJusticeConfTarget:
+		return maxJusticeConfTarget
+	}
+
+	return target
+}
+
+// isHtlcRevoke returns true if the witness type spends a commitment level HTLC
+// output with the revocation key.
+func isHtlcRevoke(witnessType input.StandardWitnessType) bool {
+	switch witnessType {
+	case input.HtlcOfferedRevoke, input.HtlcAcceptedRevoke,
+		input.TaprootHtlcOfferedRevoke, input.TaprootHtlcAcceptedRevoke:
+
+		return true
+	}
+
+	return false
+}
+
+// isHtlcJustice returns true if the witness type spends an HTLC output of the
+// revoked commitment, or the output of a second level HTLC transaction.
+func isHtlcJustice(witnessType input.StandardWitnessType) bool {
+	switch witnessType {
+	case input.HtlcSecondLevelRevoke, input.TaprootHtlcSecondLevelRevoke:
+		return true
+	}
+
+	return isHtlcRevoke(witnessType)
+}
+
+// isRevokedFunds returns true if the witness type spends an output that
+// belonged to the breaching party.
+func isRevokedFunds(witnessType input.StandardWitnessType) bool {
+	switch witnessType {
+	case input.CommitmentRevoke, input.TaprootCommitmentRevoke,
+		input.HtlcSecondLevelRevoke, input.TaprootHtlcSecondLevelRevoke,
+		input.TaprootHtlcOfferedRevoke, input.HtlcOfferedRevoke:
+
+		return true
+	}
+
+	return false
+}
+
+// isJusticeSpend determines whether the given input spends a breached output
+// through the path we'd use ourselves, which means the output was claimed by
+// us even if the sweeper doesn't know the spending transaction.
+func isJusticeSpend(bo *breachedOutput, txIn *wire.TxIn) (bool, error) {
+	witness := txIn.Witness
+	signDesc := &bo.signDesc
+
+	switch bo.witnessType {
+	case input.HtlcOfferedRevoke, input.HtlcAcceptedRevoke,
+		input.TaprootHtlcOfferedRevoke, input.TaprootHtlcAcceptedRevoke:
+
+		return input.IsHtlcSpendRevoke(txIn, signDesc)
+
+	// The revocation path of these outputs is selected with a non-empty
+	// element in front of the witness script.
+	case input.CommitmentRevoke, input.HtlcSecondLevelRevoke:
+		return len(witness) == 3 && len(witness[1]) > 0, nil
+
+	// The output of a second level transaction is revoked through the key
+	// spend path.
+	case input.TaprootHtlcSecondLevelRevoke:
+		return len(witness) == 1, nil
+
+	// The revoked to_local output of a taproot channel is spent through
+	// the revocation leaf, which is the script in our sign descriptor.
+	case input.TaprootCommitmentRevoke:
+		return len(witness) == 3 &&
+			bytes.Equal(witness[1], signDesc.WitnessScript), nil
+	}
+
+	// All other outputs of the revoked commitment belong to us.
+	return true, nil
+}
+
+// justiceExclusiveGroup returns the exclusive group an output is swept in if it
+// mustn't be batched with any other input.
+func justiceExclusiveGroup(op wire.OutPoint) uint64 {
+	return binary.BigEndian.Uint64(op.Hash[:8]) ^ uint64(op.Index)
+}
+
+// setBatchDeadline records the earliest deadline of the outputs of a breach
+// that are swept in the batched justice transaction. A zero deadline removes
+// the breach from the batch.
+func (b *BreachArbitrator) setBatchDeadline(chanPoint wire.OutPoint,
+	deadline uint32) {
+
+	b.Lock()
+	defer b.Unlock()
+
+	if deadline == 0 {
+		delete(b.batchDeadlines, chanPoint)
+		return
+	}
+
+	b.batchDeadlines[chanPoint] = deadline
+}
+
+// batchDeadline returns the earliest deadline among all breaches that have
+// outputs in the batched justice transaction. All batched outputs are offered
+// to the sweeper with the fee preference of this deadline, which results in
+// a single justice transaction for all concurrent breaches.
+func (b *BreachArbitrator) batchDeadline() uint32 {
+	b.Lock()
+	defer b.Unlock()
+
+	var deadline uint32
+	for _, d := range b.batchDeadlines {
+		if deadline == 0 || d < deadline {
+			deadline = d
+		}
+	}
+
+	return deadline
+}
+
+// justiceInput is a breached output that is swept by the sweeper.
+type justiceInput struct {
+	output breachedOutput
+
+	// deadline is the height at which the breaching party is able to
+	// spend the output.
+	deadline uint32
+
+	// exclusive is true if the output is swept in a transaction of its
+	// own.
+	exclusive bool
+
+	// confTarget is the confirmation target the output is currently
+	// offered with. It is zero if the output couldn't be offered.
+	confTarget uint32
+}
+
+// justiceResult is a sweep result for one of the justice inputs.
+type justiceResult struct {
+	outpoint wire.OutPoint
+	result   sweep.Result
+}
+
+// justiceSweep offers the outputs of a breached commitment to the sweeper, and
+// tracks them until all of them are resolved.
+type justiceSweep struct {
+	b    *BreachArbitrator
+	info *retributionInfo
+
+	height  uint32
+	inputs  map[wire.OutPoint]*justiceInput
+	results chan justiceResult
+
+	batchTarget  uint32
+	totalFunds   btcutil.Amount
+	revokedFunds btcutil.Amount
+}
+
+// breacherDelay returns the CSV delay of the outputs of the breaching party.
+func (s *justiceSweep) breacherDelay() uint32 {
+	if s.info.breacherDelay == 0 {
+		return defaultBreacherDelay
+	}
+
+	return s.info.breacherDelay
+}
+
+// event returns a breach event of the given type for this breach.
+func (s *justiceSweep) event(eventType BreachEventType,
+	ji *justiceInput) *BreachEvent {
+
+	event := &BreachEvent{
+		Type:           eventType,
+		ChanPoint:      s.info.chanPoint,
+		BreachTxID:     s.info.commitHash,
+		BreachHeight:   s.info.breachHeight,
+		Height:         s.height,
+		ConfTarget:     s.batchTarget,
+		PendingOutputs: len(s.inputs),
+		RevokedFunds:   s.revokedFunds,
+		TotalFunds:     s.totalFunds,
+	}
+
+	if ji != nil {
+		op := ji.output.outpoint
+		event.OutPoint = &op
+		event.WitnessType = ji.output.witnessType
+		event.Amount = ji.output.amt
+		event.Deadline = ji.deadline
+
+		if ji.exclusive {
+			event.ConfTarget = ji.confTarget
+		}
+	}
+
+	return event
+}
+
+// track adds a breached output to the set of justice inputs with the given
+// deadline.
+func (s *justiceSweep) track(bo breachedOutput,
+	deadline uint32) *justiceInput {
+
+	ji := &justiceInput{
+		output:   bo,
+		deadline: deadline,
+		exclusive: s.b.cfg.HtlcStrategy == JusticeHtlcSeparate &&
+			isHtlcJustice(bo.witnessType),
+	}
+	s.inputs[bo.outpoint] = ji
+
+	return ji
+}
+
+// updateBatchDeadline records the earliest deadline of the batched inputs of
+// this breach.
+func (s *justiceSweep) updateBatchDeadline() {
+	var deadline uint32
+	for _, ji := range s.inputs {
+		if ji.exclusive {
+			continue
+		}
+
+		if deadline == 0 || ji.deadline < deadline {
+			deadline = ji.deadline
+		}
+	}
+
+	s.b.setBatchDeadline(s.info.chanPoint, deadline)
+}
+
+// confTarget returns the confirmation target the given input should currently
+// be offered with.
+func (s *justiceSweep) confTarget(ji *justiceInput) uint32 {
+	if ji.exclusive {
+		return justiceConfTarget(ji.deadline, s.height)
+	}
+
+	deadline := s.b.batchDeadline()
+	if deadline == 0 {
+		deadline = ji.deadline
+	}
+
+	return justiceConfTarget(deadline, s.height)
+}
+
+// offer hands the input to the sweeper, or updates its fee preference if it
+// was offered before. The result of the sweep is forwarded to the results
+// channel. It returns true if the confirmation target of the input changed.
+func (s *justiceSweep) offer(ji *justiceInput) bool {
+	confTarget := s.confTarget(ji)
+	if confTarget == ji.confTarget {
+		return false
+	}
+
+	feePref := sweep.FeePreference{ConfTarget: confTarget}
+	op := ji.output.outpoint
+
+	// If the input is pending in the sweeper already, we bump its fee,
+	// which replaces any justice transaction that is still unconfirmed.
+	if ji.confTarget != 0 {
+		_, err := s.b.cfg.Sweeper.UpdateParams(op, sweep.ParamsUpdate{
+			Fee:   feePref,
+			Force: true,
+		})
+		if err != nil {
+			brarLog.Errorf("Unable to update fee preference of "+
+				"justice input %v for ChannelPoint(%v): %v", op,
+				s.info.chanPoint, err)
+
+			return false
+		}
+
+		ji.confTarget = confTarget
+		return true
+	}
+
+	params := sweep.Params{
+		Fee:   feePref,
+		Force: true,
+	}
+	if ji.exclusive {
+		group := justiceExclusiveGroup(op)
+		params.ExclusiveGroup = &group
+	}
+
+	// The sweeper keeps a pointer to the input, so we hand it a copy
+	// that isn't modified by us afterwards.
+	bo := ji.output
+	resultChan, err := s.b.cfg.Sweeper.SweepInput(&bo, params)
+	if err != nil {
+		brarLog.Errorf("Unable to offer justice input %v for "+
+			"ChannelPoint(%v) to the sweeper: %v", op,
+			s.info.chanPoint, err)
+
+		return false
+	}
+
+	brarLog.Debugf("Offered justice input %s(%v) for ChannelPoint(%v) "+
+		"to the sweeper with %v", ji.output.witnessType, op,
+		s.info.chanPoint, params)
+
+	ji.confTarget = confTarget
+
+	s.b.wg.Add(1)
+	go func() {
+		defer s.b.wg.Done()
+
+		select {
+		case result := <-resultChan:
+			select {
+			case s.results <- justiceResult{op, result}:
+			case <-s.b.quit:
+			}
+
+		case <-s.b.quit:
+		}
+	}()
+
+	return true
+}
+
+// offerAll offers all pending inputs to the sweeper, and updates the fee
+// preference of those that were offered before. A fee update event is sent
+// for every exclusive input whose confirmation target changed, and once for
+// the batched inputs.
+func (s *justiceSweep) offerAll() {
+	s.updateBatchDeadline()
+
+	for _, ji := range s.inputs {
+		if s.offer(ji) && ji.exclusive {
+			s.b.notifyBreachEvent(
+				s.event(BreachEventFeeUpdated, ji),
+			)
+		}
+	}
+
+	batchTarget := s.batchTarget
+	for _, ji := range s.inputs {
+		if !ji.exclusive && ji.confTarget != 0 {
+			batchTarget = ji.confTarget
+			break
+		}
+	}
+
+	if batchTarget != s.batchTarget {
+		s.batchTarget = batchTarget
+
+		event := s.event(BreachEventFeeUpdated, nil)
+		event.Deadline = s.b.batchDeadline()
+		s.b.notifyBreachEvent(event)
+	}
+}
+
+// handleResult processes the sweep result of a justice input. It returns an
+// error if the result can't be processed.
+func (s *justiceSweep) handleResult(r justiceResult) error {
+	ji, ok := s.inputs[r.outpoint]
+	if !ok {
+		return nil
+	}
+
+	// The sweeper is done with the input, so it is offered again unless
+	// the result resolves it.
+	ji.confTarget = 0
+
+	// If the sweep failed for any other reason than a spend of the input,
+	// we'll retry.
+	if r.result.Err != nil &&
+		!errors.Is(r.result.Err, sweep.ErrRemoteSpend) {
+
+		brarLog.Warnf("Justice sweep of %v for ChannelPoint(%v) "+
+			"failed, retrying: %v", r.outpoint, s.info.chanPoint,
+			r.result.Err)
+
+		return nil
+	}
+
+	spendingTx := r.result.Tx
+	if spendingTx == nil {
+		return fmt.Errorf("no spending tx for justice input %v",
+			r.outpoint)
+	}
+
+	inputIndex := -1
+	for i, txIn := range spendingTx.TxIn {
+		if txIn.PreviousOutPoint == r.outpoint {
+			inputIndex = i
+			break
+		}
+	}
+	if inputIndex < 0 {
+		return fmt.Errorf("tx %v doesn't spend justice input %v",
+			spendingTx.TxHash(), r.outpoint)
+	}
+
+	// A remote spend may still be our own, for instance if the output was
+	// swept by a justice transaction published before a restart or while
+	// the revoked commitment was in the mempool.
+	ours := r.result.Err == nil
+	if !ours {
+		var err error
+		ours, err = isJusticeSpend(
+			&ji.output, spendingTx.TxIn[inputIndex],
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(s.inputs, r.outpoint)
+	spendTxID := spendingTx.TxHash()
+
+	switch {
+	case ours:
+		if isRevokedFunds(ji.output.witnessType) {
+			s.revokedFunds += ji.output.amt
+		}
+		s.totalFunds += ji.output.amt
+
+		brarLog.Infof("Justice input %s(%v) for ChannelPoint(%v) "+
+			"swept by tx %v", ji.output.witnessType, r.outpoint,
+			s.info.chanPoint, spendTxID)
+
+		event := s.event(BreachEventOutputSwept, ji)
+		event.SpendTxID = &spendTxID
+		s.b.notifyBreachEvent(event)
+
+	// The breaching party took an HTLC output to the second level, so
+	// we'll go after the output of the second level transaction, which
+	// is locked to them by their CSV delay.
+	case isHtlcRevoke(ji.output.witnessType):
+		bo := ji.output
+		convertToSecondLevelRevoke(&bo, s.info, &chainntnfs.SpendDetail{
+			SpentOutPoint:     &r.outpoint,
+			SpenderTxHash:     &spendTxID,
+			SpendingTx:        spendingTx,
+			SpenderInputIndex: uint32(inputIndex),
+			SpendingHeight:    int32(s.height),
+		})
+		bo.confHeight = s.height
+
+		secondLevel := s.track(bo, s.height+s.breacherDelay())
+
+		event := s.event(BreachEventSecondLevel, secondLevel)
+		event.SpendTxID = &spendTxID
+		s.b.notifyBreachEvent(event)
+
+	default:
+		brarLog.Warnf("Justice input %s(%v) for ChannelPoint(%v) "+
+			"was claimed by the breaching party in tx %v",
+			ji.output.witnessType, r.outpoint, s.info.chanPoint,
+			spendTxID)
+
+		event := s.event(BreachEventOutputLost, ji)
+		event.SpendTxID = &spendTxID
+		s.b.notifyBreachEvent(event)
+	}
+
+	return nil
+}
+
+// sweepRetribution sweeps the outputs of a confirmed revoked commitment with
+// the sweeper. The outputs are offered with a fee preference derived from the
+// deadline at which the breaching party is able to claim them. Outputs of
+// concurrent breaches are batched into one justice transaction, unless the
+// HTLC strategy asks for HTLC outputs to be swept separately. The fee
+// preference is updated every block until all outputs are resolved.
+func (b *BreachArbitrator) sweepRetribution(breachInfo *retributionInfo) {
+	blockEpochs, err := b.cfg.Notifier.RegisterBlockEpochNtfn(nil)
+	if err != nil {
+		brarLog.Errorf("Unable to register for block notifications: %v",
+			err)
+		return
+	}
+	defer blockEpochs.Cancel()
+
+	s := &justiceSweep{
+		b:       b,
+		info:    breachInfo,
+		height:  breachInfo.breachHeight,
+		inputs:  make(map[wire.OutPoint]*justiceInput),
+		results: make(chan justiceResult),
+	}
+	defer b.setBatchDeadline(breachInfo.chanPoint, 0)
+
+	deadline := breachInfo.breachHeight + s.breacherDelay()
+	for _, bo := range breachInfo.breachedOutputs {
+		s.track(bo, deadline)
+	}
+
+	// The registration delivers the current best block right away, which
+	// triggers our first sweep attempt.
+	for {
+		select {
+		case epoch, ok := <-blockEpochs.Epochs:
+			if !ok {
+				return
+			}
+			s.height = uint32(epoch.Height)
+
+			s.offerAll()
+
+		case r := <-s.results:
+			err := s.handleResult(r)
+			if err != nil {
+				brarLog.Errorf("Unable to handle justice "+
+					"sweep result for ChannelPoint(%v): %v",
+					breachInfo.chanPoint, err)
+			}
+
+			if len(s.inputs) > 0 {
+				s.offerAll()
+				continue
+			}
+
+			brarLog.Infof("Justice for ChannelPoint(%v) has "+
+				"been served, %v revoked funds (%v total) "+
+				"have been claimed. No more outputs to sweep, "+
+				"marking fully resolved", breachInfo.chanPoint,
+				s.revokedFunds, s.totalFunds)
+
+			err = b.cleanupBreach(&breachInfo.chanPoint)
+			if err != nil {
+				brarLog.Errorf("Failed to cleanup breached "+
+					"ChannelPoint(%v): %v",
+					breachInfo.chanPoint, err)
+			}
+
+			b.notifyBreachEvent(
+				s.event(BreachEventJusticeServed, nil),
+			)
+
+			return
+
+		case <-b.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package contractcourt
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lntest/mock"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/subscribe"
+	"github.com/lightningnetwork/lnd/sweep"
+	"github.com/stretchr/testify/require"
+)
+
+// justiceOffer is an input offered to the justiceSweeper, or a fee update of
+// an input.
+type justiceOffer struct {
+	outpoint    wire.OutPoint
+	witnessType input.WitnessType
+	params      sweep.Params
+}
+
+// justiceSweeper is a mock sweeper that records the justice inputs offered to
+// it, and lets the test decide about their results.
+type justiceSweeper struct {
+	offers  chan justiceOffer
+	updates chan justiceOffer
+
+	mu      sync.Mutex
+	results map[wire.OutPoint]chan sweep.Result
+}
+
+func newJusticeSweeper() *justiceSweeper {
+	return &justiceSweeper{
+		offers:  make(chan justiceOffer, 10),
+		updates: make(chan justiceOffer, 10),
+		results: make(map[wire.OutPoint]chan sweep.Result),
+	}
+}
+
+func (s *justiceSweeper) SweepInput(inp input.Input, params sweep.Params) (
+	chan sweep.Result, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resultChan := make(chan sweep.Result, 1)
+	s.results[*inp.OutPoint()] = resultChan
+
+	s.offers <- justiceOffer{
+		outpoint:    *inp.OutPoint(),
+		witnessType: inp.WitnessType(),
+		params:      params,
+	}
+
+	return resultChan, nil
+}
+
+func (s *justiceSweeper) CreateSweepTx(inputs []input.Input,
+	feePref sweep.FeePreference,
+	currentBlockHeight uint32) (*wire.MsgTx, error) {
+
+	return nil, nil
+}
+
+func (s *justiceSweeper) RelayFeePerKW() chainfee.SatPerKWeight {
+	return 253
+}
+
+func (s *justiceSweeper) UpdateParams(op wire.OutPoint,
+	params sweep.ParamsUpdate) (chan sweep.Result, error) {
+
+	s.updates <- justiceOffer{
+		outpoint: op,
+		params: sweep.Params{
+			Fee:   params.Fee,
+			Force: params.Force,
+		},
+	}
+
+	return make(chan sweep.Result, 1), nil
+}
+
+// resolve delivers the sweep result of the given input.
+func (s *justiceSweeper) resolve(op wire.OutPoint, result sweep.Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.results[op] <- result
+}
+
+var _ UtxoSweeper = (*justiceSweeper)(nil)
+
+// receiveOffers reads the given number of offers from the channel.
+func receiveOffers(t *testing.T, offers chan justiceOffer,
+	n int) map[wire.OutPoint]justiceOffer {
+
+	t.Helper()
+
+	received := make(map[wire.OutPoint]justiceOffer)
+	for i := 0; i < n; i++ {
+		select {
+		case offer := <-offers:
+			received[offer.outpoint] = offer
+
+		case <-time.After(defaultTimeout):
+			t.Fatalf("justice input not offered")
+		}
+	}
+
+	return received
+}
+
+// receiveBreachEvent reads the next breach event and checks its type.
+func receiveBreachEvent(t *testing.T, client *subscribe.Client,
+	eventType BreachEventType) *BreachEvent {
+
+	t.Helper()
+
+	select {
+	case update := <-client.Updates():
+		event, ok := update.(*BreachEvent)
+		require.True(t, ok)
+		require.Equal(t, eventType, event.Type)
+
+		return event
+
+	case <-time.After(defaultTimeout):
+		t.Fatalf("no breach event received")
+	}
+
+	return nil
+}
+
+// handoffBreach hands the breach of alice's channel to the breach arbiter and
+// marks the channel pending closed, like the chain watcher would.
+func handoffBreach(t *testing.T, brar *BreachArbitrator,
+	alice *lnwallet.LightningChannel,
+	contractBreaches chan *ContractBreachEvent,
+	retribution *lnwallet.BreachRetribution) {
+
+	t.Helper()
+
+	chanPoint := alice.ChannelPoint()
+	processACK := make(chan error, 1)
+	breach := &ContractBreachEvent{
+		ChanPoint: chanPoint,
+		ProcessACK: func(brarErr error) {
+			processACK <- brarErr
+		},
+		BreachRetribution: retribution,
+	}
+
+	select {
+	case contractBreaches <- breach:
+	case <-time.After(defaultTimeout):
+		t.Fatalf("breach not delivered")
+	}
+
+	select {
+	case err := <-processACK:
+		require.NoError(t, err, "handoff failed")
+
+	case <-time.After(defaultTimeout):
+		t.Fatalf("breach arbiter didn't send ack back")
+	}
+
+	state := alice.State()
+	err := state.CloseChannel(&channeldb.ChannelCloseSummary{
+		ChanPoint:               state.FundingOutpoint,
+		ChainHash:               state.ChainHash,
+		RemotePub:               state.IdentityPub,
+		CloseType:               channeldb.BreachClose,
+		Capacity:                state.Capacity,
+		IsPending:               true,
+		ShortChanID:             state.ShortChanID(),
+		RemoteCurrentRevocation: state.RemoteCurrentRevocation,
+		RemoteNextRevocation:    state.RemoteNextRevocation,
+		LocalChanConfig:         state.LocalChanCfg,
+	})
+	require.NoError(t, err, "unable to close channel")
+
+	assertArbiterBreach(t, brar, &chanPoint)
+}
+
+// TestJusticeConfTarget tests the confirmation target derived from the
+// deadline of a breach.
+func TestJusticeConfTarget(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		deadline uint32
+		height   uint32
+		target   uint32
+	}{
+		{
+			name:     "far from deadline",
+			deadline: 244,
+			height:   100,
+			target:   maxJusticeConfTarget,
+		},
+		{
+			name:     "approaching deadline",
+			deadline: 108,
+			height:   100,
+			target:   4,
+		},
+		{
+			name:     "one block left",
+			deadline: 101,
+			height:   100,
+			target:   1,
+		},
+		{
+			name:     "deadline passed",
+			deadline: 100,
+			height:   110,
+			target:   1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(
+				t, tc.target,
+				justiceConfTarget(tc.deadline, tc.height),
+			)
+		})
+	}
+}
+
+// TestBreachBatchDeadline tests that the batched justice inputs of concurrent
+// breaches share the earliest deadline among them.
+func TestBreachBatchDeadline(t *testing.T) {
+	t.Parallel()
+
+	brar := NewBreachArbitrator(&BreachConfig{})
+	require.Zero(t, brar.batchDeadline())
+
+	first := wire.OutPoint{Index: 1}
+	second := wire.OutPoint{Index: 2}
+
+	brar.setBatchDeadline(first, 250)
+	brar.setBatchDeadline(second, 200)
+	require.Equal(t, uint32(200), brar.batchDeadline())
+
+	// Once the breach with the earliest deadline is resolved, the other
+	// breach determines the deadline again.
+	brar.setBatchDeadline(second, 0)
+	require.Equal(t, uint32(250), brar.batchDeadline())
+}
+
+// TestParseJusticeHtlcStrategy tests that the HTLC strategies can be parsed
+// from their names.
+func TestParseJusticeHtlcStrategy(t *testing.T) {
+	t.Parallel()
+
+	strategy, err := ParseJusticeHtlcStrategy("")
+	require.NoError(t, err)
+	require.Equal(t, JusticeHtlcBatch, strategy)
+
+	for _, s := range []JusticeHtlcStrategy{
+		JusticeHtlcBatch, JusticeHtlcSeparate,
+	} {
+		strategy, err := ParseJusticeHtlcStrategy(s.String())
+		require.NoError(t, err)
+		require.Equal(t, s, strategy)
+	}
+
+	_, err = ParseJusticeHtlcStrategy("aggressive")
+	require.Error(t, err)
+}
+
+// TestBreachSweeperJustice tests that the outputs of a breached commitment are
+// swept by the sweeper with a fee preference that tightens as the deadline
+// approaches, that an HTLC taken to the second level is followed, and that
+// the progress is reported through breach events.
+func TestBreachSweeperJustice(t *testing.T) {
+	testBreachSweeperJustice(t, JusticeHtlcBatch)
+}
+
+// TestBreachSweeperJusticeSeparate tests that HTLC outputs are swept in their
+// own transaction if the separate HTLC strategy is used.
+func TestBreachSweeperJusticeSeparate(t *testing.T) {
+	testBreachSweeperJustice(t, JusticeHtlcSeparate)
+}
+
+func testBreachSweeperJustice(t *testing.T, strategy JusticeHtlcStrategy) {
+	brar, alice, _, bobClose, contractBreaches := initBreachedState(t)
+
+	sweeper := newJusticeSweeper()
+	brar.cfg.Sweeper = sweeper
+	brar.cfg.HtlcStrategy = strategy
+
+	var (
+		height       = bobClose.ChanSnapshot.CommitHeight
+		breachHeight = uint32(10)
+		forceCloseTx = bobClose.CloseTx
+		chanPoint    = alice.ChannelPoint()
+		notifier     = brar.cfg.Notifier.(*mock.SpendNotifier)
+	)
+
+	client, err := brar.SubscribeBreachEvents()
+	require.NoError(t, err)
+	defer client.Cancel()
+
+	retribution, err := lnwallet.NewBreachRetribution(
+		alice.State(), height, breachHeight, forceCloseTx,
+	)
+	require.NoError(t, err, "unable to create breach retribution")
+
+	handoffBreach(t, brar, alice, contractBreaches, retribution)
+	receiveBreachEvent(t, client, BreachEventDetected)
+
+	select {
+	case notifier.ConfChan <- &chainntnfs.TxConfirmation{
+		BlockHeight: breachHeight,
+	}:
+	case <-time.After(defaultTimeout):
+		t.Fatalf("conf not delivered")
+	}
+
+	confirmed := receiveBreachEvent(t, client, BreachEventConfirmed)
+	require.Equal(t, breachHeight, confirmed.Height)
+	require.Equal(t, 3, confirmed.PendingOutputs)
+
+	// Once the current block is known, all outputs are offered to the
+	// sweeper with a confirmation target derived from the deadline.
+	deadline := breachHeight + retribution.RemoteDelay
+	notifier.EpochChan <- &chainntnfs.BlockEpoch{
+		Height: int32(breachHeight),
+	}
+
+	offers := receiveOffers(t, sweeper.offers, 3)
+	confTarget := justiceConfTarget(deadline, breachHeight)
+
+	var htlcOutpoint wire.OutPoint
+	for op, offer := range offers {
+		require.Equal(t, forceCloseTx.TxHash(), op.Hash)
+		require.Equal(t, confTarget, offer.params.Fee.ConfTarget)
+		require.True(t, offer.params.Force)
+
+		isHtlc := offer.witnessType == input.HtlcOfferedRevoke ||
+			offer.witnessType == input.HtlcAcceptedRevoke
+		if isHtlc {
+			htlcOutpoint = op
+		}
+
+		exclusive := isHtlc && strategy == JusticeHtlcSeparate
+		require.Equal(t, exclusive, offer.params.ExclusiveGroup != nil)
+	}
+	require.NotEqual(t, wire.OutPoint{}, htlcOutpoint)
+
+	feeUpdate := receiveBreachEvent(t, client, BreachEventFeeUpdated)
+	if strategy == JusticeHtlcSeparate {
+		require.Equal(t, htlcOutpoint, *feeUpdate.OutPoint)
+		feeUpdate = receiveBreachEvent(
+			t, client, BreachEventFeeUpdated,
+		)
+	}
+	require.Nil(t, feeUpdate.OutPoint)
+	require.Equal(t, confTarget, feeUpdate.ConfTarget)
+	require.Equal(t, deadline, feeUpdate.Deadline)
+
+	// As the deadline approaches, the fee preference of all inputs is
+	// tightened.
+	notifier.EpochChan <- &chainntnfs.BlockEpoch{
+		Height: int32(deadline - 1),
+	}
+
+	updates := receiveOffers(t, sweeper.updates, 3)
+	for op, update := range updates {
+		require.Contains(t, offers, op)
+		require.Equal(t, uint32(1), update.params.Fee.ConfTarget)
+	}
+
+	// The commitment outputs are swept by our justice transaction.
+	justiceTx := wire.NewMsgTx(2)
+	for op := range offers {
+		if op == htlcOutpoint {
+			continue
+		}
+
+		justiceTx.AddTxIn(&wire.TxIn{PreviousOutPoint: op})
+	}
+	justiceTx.AddTxOut(&wire.TxOut{Value: 1000})
+
+	for _, txIn := range justiceTx.TxIn {
+		sweeper.resolve(txIn.PreviousOutPoint, sweep.Result{
+			Tx: justiceTx,
+		})
+	}
+
+	// Drain the fee update events of the tightened fee preference, then
+	// expect the swept outputs to be reported.
+	var sweptEvents int
+	for sweptEvents < 2 {
+		select {
+		case update := <-client.Updates():
+			event := update.(*BreachEvent)
+			if event.Type == BreachEventFeeUpdated {
+				continue
+			}
+
+			require.Equal(t, BreachEventOutputSwept, event.Type)
+			require.Equal(
+				t, justiceTx.TxHash(), *event.SpendTxID,
+			)
+			sweptEvents++
+
+		case <-time.After(defaultTimeout):
+			t.Fatalf("no breach event received")
+		}
+	}
+
+	// The breaching party takes the HTLC to the second level, so the
+	// output of the second level transaction is offered next.
+	secondLevelTx := wire.NewMsgTx(2)
+	secondLevelTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: htlcOutpoint,
+		Witness:          wire.TxWitness{nil, {1}, {2}, {3}, {4}},
+	})
+	secondLevelTx.AddTxOut(&wire.TxOut{Value: 15000})
+
+	sweeper.resolve(htlcOutpoint, sweep.Result{
+		Tx:  secondLevelTx,
+		Err: sweep.ErrRemoteSpend,
+	})
+
+	secondLevel := receiveBreachEvent(t, client, BreachEventSecondLevel)
+	secondLevelOutpoint := wire.OutPoint{Hash: secondLevelTx.TxHash()}
+	require.Equal(t, secondLevelOutpoint, *secondLevel.OutPoint)
+	require.Equal(
+		t, deadline-1+retribution.RemoteDelay, secondLevel.Deadline,
+	)
+
+	offers = receiveOffers(t, sweeper.offers, 1)
+	offer, ok := offers[secondLevelOutpoint]
+	require.True(t, ok)
+	require.Equal(t, input.HtlcSecondLevelRevoke, offer.witnessType)
+	require.Equal(
+		t, strategy == JusticeHtlcSeparate,
+		offer.params.ExclusiveGroup != nil,
+	)
+
+	// Once the second level output is swept, justice has been served. The
+	// total swept includes the commitment outputs and the second level
+	// output.
+	expectedTotal := btcutil.Amount(15000)
+	retInfo := newRetributionInfo(&chanPoint, retribution)
+	for _, bo := range retInfo.breachedOutputs {
+		if bo.outpoint != htlcOutpoint {
+			expectedTotal += bo.amt
+		}
+	}
+
+	secondJusticeTx := wire.NewMsgTx(2)
+	secondJusticeTx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: secondLevelOutpoint,
+	})
+	secondJusticeTx.AddTxOut(&wire.TxOut{Value: 14000})
+
+	sweeper.resolve(secondLevelOutpoint, sweep.Result{
+		Tx: secondJusticeTx,
+	})
+
+	for {
+		var event *BreachEvent
+		select {
+		case update := <-client.Updates():
+			event = update.(*BreachEvent)
+
+		case <-time.After(defaultTimeout):
+			t.Fatalf("no breach event received")
+		}
+
+		if event.Type != BreachEventJusticeServed {
+			continue
+		}
+
+		require.Zero(t, event.PendingOutputs)
+		require.Equal(t, expectedTotal, event.TotalFunds)
+
+		break
+	}
+
+	assertBrarCleanup(t, brar, &chanPoint, alice.State().Db)
+}
@@ -107,6 +107,12 @@ type ChainArbitratorConfig struct {
 	// to mark the channel closed.
 	ContractBreach func(wire.OutPoint, *lnwallet.BreachRetribution) error
 
+	// MempoolBreach is a function closure that the ChainArbitrator will
+	// use to notify the BreachArbitrator about a contract breach that has
+	// been found in the mempool, but hasn't confirmed yet. It's only used
+	// if Mempool is set.
+	MempoolBreach func(wire.OutPoint, *lnwallet.BreachRetribution)
+
 	// IsOurAddress is a function that returns true if the passed address
 	// is known to the underlying wallet. Otherwise, false should be
 	// returned.
@@ -199,6 +205,13 @@ type ChainArbitratorConfig struct {
 
 	// HtlcNotifier is an interface that htlc events are sent to.
 	HtlcNotifier HtlcNotifier
+
+	// FetchTxDetails looks up a transaction known to the wallet. It is
+	// used to account for the fees paid when resolving a closed channel.
+	// If nil, fees that cannot be derived from the resolver reports are
+	// left out of close records.
+	FetchTxDetails func(txid chainhash.Hash) (*lnwallet.TransactionDetail,
+		error)
 }
 
 // ChainArbitrator is a sub-system that oversees the on-chain resolution of all
@@ -381,6 +394,11 @@ func newActiveChannelArbitrator(channel *channeldb.OpenChannel,
 			chanStateDB := c.chanSource.ChannelStateDB()
 			return chanStateDB.FetchHistoricalChannel(&chanPoint)
 		},
+		UpdateCloseRecord: func(reason channeldb.CloseReason,
+			resolved bool) error {
+
+			return c.updateCloseRecord(chanPoint, reason, resolved)
+		},
 	}
 
 	// The final component needed is an arbitrator log that the arbitrator
@@ -530,6 +548,8 @@ func (c *ChainArbitrator) Start() error {
 				signer:              c.cfg.Signer,
 				isOurAddr:           c.cfg.IsOurAddress,
 				contractBreach:      breachClosure,
+				mempool:             c.cfg.Mempool,
+				mempoolBreach:       c.mempoolBreach(chanPoint),
 				extractStateNumHint: lnwallet.GetStateNumHint,
 			},
 		)
@@ -597,6 +617,13 @@ func (c *ChainArbitrator) Start() error {
 				chanStateDB := c.chanSource.ChannelStateDB()
 				return chanStateDB.FetchHistoricalChannel(&chanPoint)
 			},
+			UpdateCloseRecord: func(reason channeldb.CloseReason,
+				resolved bool) error {
+
+				return c.updateCloseRecord(
+					chanPoint, reason, resolved,
+				)
+			},
 		}
 		chanLog, err := newBoltArbitratorLog(
 			c.chanSource.Backend, arbCfg, c.cfg.ChainHash, chanPoint,
@@ -1117,6 +1144,21 @@ func (c *ChainArbitrator) ForceCloseContract(chanPoint wire.OutPoint) (*wire.Msg
 	return closeTx, nil
 }
 
+// mempoolBreach returns the closure the chain watcher of the given channel
+// uses to hand off a contract breach found in the mempool, or nil if no
+// MempoolBreach is configured.
+func (c *ChainArbitrator) mempoolBreach(
+	chanPoint wire.OutPoint) func(*lnwallet.BreachRetribution) {
+
+	if c.cfg.MempoolBreach == nil {
+		return nil
+	}
+
+	return func(ret *lnwallet.BreachRetribution) {
+		c.cfg.MempoolBreach(chanPoint, ret)
+	}
+}
+
 // WatchNewChannel sends the ChainArbitrator a message to create a
 // ChannelArbitrator tasked with watching over a new channel. Once a new
 // channel has finished its final funding flow, it should be registered with
@@ -1151,6 +1193,8 @@ func (c *ChainArbitrator) WatchNewChannel(newChan *channeldb.OpenChannel) error
 					chanPoint, retInfo,
 				)
 			},
+			mempool:             c.cfg.Mempool,
+			mempoolBreach:       c.mempoolBreach(chanPoint),
 			extractStateNumHint: lnwallet.GetStateNumHint,
 		},
 	)
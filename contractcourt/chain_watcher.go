@@ -179,6 +179,17 @@ type chainWatcherConfig struct {
 	// preserved the necessary breach info for this channel point.
 	contractBreach func(*lnwallet.BreachRetribution) error
 
+	// mempool is an optional mempool watcher. If set, the watcher will
+	// also look for a revoked commitment of the remote party in the
+	// mempool, and hand it to mempoolBreach before it confirms.
+	mempool chainntnfs.MempoolWatcher
+
+	// mempoolBreach is a method that will be called by the watcher if it
+	// detects that a contract breach transaction has been broadcast, but
+	// hasn't confirmed yet. The confirmed breach is still handed off with
+	// contractBreach.
+	mempoolBreach func(*lnwallet.BreachRetribution)
+
 	// isOurAddr is a function that returns true if the passed address is
 	// known to us.
 	isOurAddr func(btcutil.Address) bool
@@ -333,6 +344,24 @@ func (c *chainWatcher) Start() error {
 	c.wg.Add(1)
 	go c.closeObserver(spendNtfn)
 
+	// If we're able to watch the mempool, we'll also look for a spend of
+	// the funding output there, so we can react to a breach before the
+	// revoked commitment confirms.
+	if c.cfg.mempool == nil || c.cfg.mempoolBreach == nil {
+		return nil
+	}
+
+	mempoolSpend, err := c.cfg.mempool.SubscribeMempoolSpent(*fundingOut)
+	if err != nil {
+		log.Warnf("Unable to watch mempool for spend of "+
+			"ChannelPoint(%v): %v", chanState.FundingOutpoint, err)
+
+		return nil
+	}
+
+	c.wg.Add(1)
+	go c.mempoolObserver(mempoolSpend)
+
 	return nil
 }
 
@@ -743,6 +772,98 @@ func (c *chainWatcher) closeObserver(spendNtfn *chainntnfs.SpendEvent) {
 	}
 }
 
+// mempoolObserver dispatches the spends of the funding output found in the
+// mempool to handleMempoolSpend. The confirmed spend is handled by the
+// closeObserver as usual.
+//
+// NOTE: This MUST be run as a goroutine.
+func (c *chainWatcher) mempoolObserver(
+	mempoolSpend *chainntnfs.MempoolSpendEvent) {
+
+	defer c.wg.Done()
+	defer c.cfg.mempool.CancelMempoolSpendEvent(mempoolSpend)
+
+	// The spending transaction may be replaced in the mempool, in which
+	// case we'll be notified again, so we keep track of the ones we've
+	// already looked at.
+	seen := make(map[chainhash.Hash]struct{})
+
+	for {
+		select {
+		case commitSpend, ok := <-mempoolSpend.Spend:
+			if !ok {
+				return
+			}
+
+			if _, ok := seen[*commitSpend.SpenderTxHash]; ok {
+				continue
+			}
+			seen[*commitSpend.SpenderTxHash] = struct{}{}
+
+			err := c.handleMempoolSpend(commitSpend)
+			if err != nil {
+				log.Errorf("Unable to handle mempool spend of "+
+					"ChannelPoint(%v): %v",
+					c.cfg.chanState.FundingOutpoint, err)
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// handleMempoolSpend checks whether the passed unconfirmed spend of the funding
+// output is a revoked commitment of the remote party, and if so, hands the
+// breach retribution to mempoolBreach.
+func (c *chainWatcher) handleMempoolSpend(
+	commitSpend *chainntnfs.SpendDetail) error {
+
+	commitTx := commitSpend.SpendingTx
+
+	// A cooperative close has a finalized input sequence, so it can't be
+	// a revoked commitment.
+	if commitTx.TxIn[0].Sequence == wire.MaxTxInSequenceNum {
+		return nil
+	}
+
+	broadcastStateNum := c.cfg.extractStateNumHint(
+		commitTx, c.stateHintObfuscator,
+	)
+
+	// As the commitment hasn't confirmed yet, we use the height hint of
+	// the channel in place of the breach height.
+	retribution, err := lnwallet.NewBreachRetribution(
+		c.cfg.chanState, broadcastStateNum, c.heightHint, commitTx,
+	)
+
+	switch {
+	// If we had no log entry at this height, this is not a revoked state.
+	case err == channeldb.ErrLogEntryNotFound:
+		return nil
+	case err == channeldb.ErrNoPastDeltas:
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("unable to create breach retribution: %w",
+			err)
+	}
+
+	// The revoked state could still be our own commitment, so we make sure
+	// the transaction is the remote one.
+	if retribution.BreachTxHash != commitTx.TxHash() {
+		return nil
+	}
+
+	log.Warnf("Revoked state #%v for ChannelPoint(%v) found in the "+
+		"mempool in tx %v!!!", broadcastStateNum,
+		c.cfg.chanState.FundingOutpoint, commitSpend.SpenderTxHash)
+
+	c.cfg.mempoolBreach(retribution)
+
+	return nil
+}
+
 // handleKnownLocalState checks whether the passed spend is a local state that
 // is known to us (the current state). If so we will act on this state using
 // the passed chainSet. If this is not a known local state, false is returned.
@@ -78,6 +78,83 @@ func TestChainWatcherRemoteUnilateralClose(t *testing.T) {
 	}
 }
 
+// TestChainWatcherMempoolBreach tests that the chain watcher hands off a
+// revoked commitment of the remote party found in the mempool, while ignoring
+// the current one.
+func TestChainWatcherMempoolBreach(t *testing.T) {
+	t.Parallel()
+
+	// First, we'll create two channels which already have established a
+	// commitment contract between themselves.
+	aliceChannel, bobChannel, err := lnwallet.CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit,
+	)
+	require.NoError(t, err, "unable to create test channels")
+
+	// We'll keep Bob's commitment of the initial state, and then execute a
+	// state transition to revoke it.
+	revokedCommit := bobChannel.State().LocalCommitment.CommitTx.Copy()
+	_, err = executeStateTransitions(t, 1000, aliceChannel, bobChannel, 1)
+	require.NoError(t, err, "unable to trigger state transition")
+
+	// With the channels created, we'll now create a chain watcher instance
+	// which will be watching for any closes of Alice's channel, both in
+	// blocks and in the mempool.
+	aliceNotifier := &mock.ChainNotifier{
+		SpendChan: make(chan *chainntnfs.SpendDetail),
+		EpochChan: make(chan *chainntnfs.BlockEpoch),
+		ConfChan:  make(chan *chainntnfs.TxConfirmation),
+	}
+	mempool := newMockMempool()
+	breaches := make(chan *lnwallet.BreachRetribution, 1)
+	aliceChainWatcher, err := newChainWatcher(chainWatcherConfig{
+		chanState: aliceChannel.State(),
+		notifier:  aliceNotifier,
+		signer:    aliceChannel.Signer,
+		mempool:   mempool,
+		mempoolBreach: func(ret *lnwallet.BreachRetribution) {
+			breaches <- ret
+		},
+		extractStateNumHint: lnwallet.GetStateNumHint,
+	})
+	require.NoError(t, err, "unable to create chain watcher")
+	err = aliceChainWatcher.Start()
+	require.NoError(t, err, "unable to start chain watcher")
+	defer aliceChainWatcher.Stop()
+
+	// If Bob's current commitment shows up in the mempool, nothing should
+	// be handed off.
+	bobCommit := bobChannel.State().LocalCommitment.CommitTx
+	bobTxHash := bobCommit.TxHash()
+	mempool.spendChan <- &chainntnfs.SpendDetail{
+		SpenderTxHash: &bobTxHash,
+		SpendingTx:    bobCommit,
+	}
+
+	select {
+	case <-breaches:
+		t.Fatalf("current commitment handed off as breach")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Once the revoked commitment replaces it, the breach should be handed
+	// off right away.
+	revokedTxHash := revokedCommit.TxHash()
+	mempool.spendChan <- &chainntnfs.SpendDetail{
+		SpenderTxHash: &revokedTxHash,
+		SpendingTx:    revokedCommit,
+	}
+
+	select {
+	case ret := <-breaches:
+		require.Equal(t, revokedTxHash, ret.BreachTxHash)
+		require.EqualValues(t, 0, ret.RevokedStateNum)
+
+	case <-time.After(time.Second * 15):
+		t.Fatalf("breach not handed off")
+	}
+}
+
 func addFakeHTLC(t *testing.T, htlcAmount lnwire.MilliSatoshi, id uint64,
 	aliceChannel, bobChannel *lnwallet.LightningChannel) {
 
@@ -170,6 +170,12 @@ type ChannelArbitratorConfig struct {
 	// additional information required for proper contract resolution.
 	FetchHistoricalChannel func() (*channeldb.OpenChannel, error)
 
+	// UpdateCloseRecord rebuilds the close record of the channel from its
+	// latest resolver reports. A known close reason replaces the stored
+	// one, and resolved marks the record as final.
+	UpdateCloseRecord func(reason channeldb.CloseReason,
+		resolved bool) error
+
 	ChainArbitratorConfig
 }
 
@@ -361,6 +367,16 @@ type ChannelArbitrator struct {
 	// resolvers slice.
 	activeResolversLock sync.RWMutex
 
+	// resolverDone holds a channel for each active resolver, keyed by its
+	// resolver key, that is closed once the goroutine resolving it exits.
+	// It is guarded by activeResolversLock.
+	resolverDone map[string]chan struct{}
+
+	// reevaluateMtx serializes the re-evaluation of resolvers with each
+	// other and with the shutdown of the arbitrator, so a resolver is
+	// never stopped twice.
+	reevaluateMtx sync.Mutex
+
 	// resolutionSignal is a channel that will be sent upon by contract
 	// resolvers once their contract has been fully resolved. With each
 	// send, we'll check to see if the contract is fully resolved.
@@ -404,6 +420,7 @@ func NewChannelArbitrator(cfg ChannelArbitratorConfig,
 		forceCloseReqs:   make(chan *forceCloseReq),
 		activeHTLCs:      htlcSets,
 		unmergedSet:      unmerged,
+		resolverDone:     make(map[string]chan struct{}),
 		cfg:              cfg,
 		quit:             make(chan struct{}),
 	}
@@ -654,17 +671,34 @@ func (c *ChannelArbitrator) relaunchResolvers(commitSet *CommitSet,
 	// We'll now query our log to see if there are any active unresolved
 	// contracts. If this is the case, then we'll relaunch all contract
 	// resolvers.
-	unresolvedContracts, err := c.log.FetchUnresolvedContracts()
+	unresolvedContracts, err := c.loadResolvers(commitSet, heightHint)
 	if err != nil {
 		return err
 	}
 
+	c.launchResolvers(unresolvedContracts)
+
+	return nil
+}
+
+// loadResolvers decodes the resolvers of all unresolved contracts from the log
+// and supplements them with the state they need to be launched. The anchor
+// resolver isn't stored in the log, so it's re-instantiated using the given
+// height hint.
+func (c *ChannelArbitrator) loadResolvers(commitSet *CommitSet,
+	heightHint uint32) ([]ContractResolver, error) {
+
+	unresolvedContracts, err := c.log.FetchUnresolvedContracts()
+	if err != nil {
+		return nil, err
+	}
+
 	// Retrieve the commitment tx hash from the log.
 	contractResolutions, err := c.log.FetchContractResolutions()
 	if err != nil {
 		log.Errorf("unable to fetch contract resolutions: %v",
 			err)
-		return err
+		return nil, err
 	}
 	commitHash := contractResolutions.CommitHash
 
@@ -682,7 +716,7 @@ func (c *ChannelArbitrator) relaunchResolvers(commitSet *CommitSet,
 		chainActions, err := c.log.FetchChainActions()
 		if err != nil {
 			log.Errorf("unable to fetch chain actions: %v", err)
-			return err
+			return nil, err
 		}
 		for _, htlcs := range chainActions {
 			confirmedHTLCs = append(confirmedHTLCs, htlcs...)
@@ -721,11 +755,11 @@ func (c *ChannelArbitrator) relaunchResolvers(commitSet *CommitSet,
 			"state", c.cfg.ChanPoint)
 
 	case err != nil:
-		return err
+		return nil, err
 	}
 
-	log.Infof("ChannelArbitrator(%v): relaunching %v contract "+
-		"resolvers", c.cfg.ChanPoint, len(unresolvedContracts))
+	log.Infof("ChannelArbitrator(%v): loading %v contract resolvers",
+		c.cfg.ChanPoint, len(unresolvedContracts))
 
 	for i := range unresolvedContracts {
 		resolver := unresolvedContracts[i]
@@ -751,7 +785,7 @@ func (c *ChannelArbitrator) relaunchResolvers(commitSet *CommitSet,
 		htlcPoint := htlcResolver.HtlcPoint()
 		htlc, ok := htlcMap[htlcPoint]
 		if !ok {
-			return fmt.Errorf(
+			return nil, fmt.Errorf(
 				"htlc resolver %T unavailable", resolver,
 			)
 		}
@@ -777,9 +811,7 @@ func (c *ChannelArbitrator) relaunchResolvers(commitSet *CommitSet,
 		// TODO(roasbeef): this isn't re-launched?
 	}
 
-	c.launchResolvers(unresolvedContracts)
-
-	return nil
+	return unresolvedContracts, nil
 }
 
 // Report returns htlc reports for the active resolvers.
@@ -817,11 +849,13 @@ func (c *ChannelArbitrator) Stop() error {
 		go c.cfg.ChainEvents.Cancel()
 	}
 
+	c.reevaluateMtx.Lock()
 	c.activeResolversLock.RLock()
 	for _, activeResolver := range c.activeResolvers {
-		activeResolver.Stop()
+		stopResolver(activeResolver)
 	}
 	c.activeResolversLock.RUnlock()
+	c.reevaluateMtx.Unlock()
 
 	close(c.quit)
 	c.wg.Wait()
@@ -962,8 +996,17 @@ func (c *ChannelArbitrator) stateStep(
 		// next state, as we still need to broadcast the commitment
 		// transaction.
 		case chainTrigger:
-			fallthrough
+			c.updateCloseRecord(
+				channeldb.CloseReasonHtlcOnChain, false,
+			)
+			nextState = StateBroadcastCommit
+
+		// If this is a user trigger, we record that the close was
+		// requested before going on chain.
 		case userTrigger:
+			c.updateCloseRecord(
+				channeldb.CloseReasonForceCloseRequest, false,
+			)
 			nextState = StateBroadcastCommit
 
 		// If the trigger is a cooperative close being confirmed, then
@@ -1277,6 +1320,8 @@ func (c *ChannelArbitrator) stateStep(
 		log.Infof("ChannelPoint(%v) has been fully resolved "+
 			"on-chain at height=%v", c.cfg.ChanPoint, triggerHeight)
 
+		c.updateCloseRecord(channeldb.CloseReasonUnknown, true)
+
 		if err := c.cfg.MarkChannelResolved(); err != nil {
 			log.Errorf("unable to mark channel resolved: %v", err)
 			return StateError, closeTx, err
@@ -1289,6 +1334,22 @@ func (c *ChannelArbitrator) stateStep(
 	return nextState, closeTx, nil
 }
 
+// updateCloseRecord rebuilds the close record of the channel. The record is
+// only used for accounting, so a failure to update it is logged rather than
+// interrupting the resolution of the channel.
+func (c *ChannelArbitrator) updateCloseRecord(reason channeldb.CloseReason,
+	resolved bool) {
+
+	if c.cfg.UpdateCloseRecord == nil {
+		return
+	}
+
+	if err := c.cfg.UpdateCloseRecord(reason, resolved); err != nil {
+		log.Errorf("ChannelArbitrator(%v): unable to update close "+
+			"record: %v", c.cfg.ChanPoint, err)
+	}
+}
+
 // sweepAnchors offers all given anchor resolutions to the sweeper. It requests
 // sweeping at the minimum fee rate. This fee rate can be upped manually by the
 // user via the BumpFee rpc.
@@ -1512,9 +1573,13 @@ func (c *ChannelArbitrator) launchResolvers(resolvers []ContractResolver) {
 	defer c.activeResolversLock.Unlock()
 
 	c.activeResolvers = resolvers
+	c.resolverDone = make(map[string]chan struct{}, len(resolvers))
 	for _, contract := range resolvers {
+		done := make(chan struct{})
+		c.resolverDone[string(contract.ResolverKey())] = done
+
 		c.wg.Add(1)
-		go c.resolveContract(contract)
+		go c.resolveContract(contract, done)
 	}
 }
 
@@ -2475,6 +2540,16 @@ func (c *ChannelArbitrator) replaceResolver(oldResolver,
 	for i, r := range c.activeResolvers {
 		if bytes.Equal(r.ResolverKey(), oldKey) {
 			c.activeResolvers[i] = newResolver
+
+			// The goroutine resolving the old resolver carries on
+			// with the new one.
+			done, ok := c.resolverDone[string(oldKey)]
+			if ok {
+				delete(c.resolverDone, string(oldKey))
+				newKey := string(newResolver.ResolverKey())
+				c.resolverDone[newKey] = done
+			}
+
 			return nil
 		}
 	}
@@ -2487,11 +2562,14 @@ func (c *ChannelArbitrator) replaceResolver(oldResolver,
 // or the contract will itself create another contract to be resolved. In
 // either case, one the contract has been fully resolved, we'll signal back to
 // the main goroutine so it can properly keep track of the set of unresolved
-// contracts.
+// contracts. The done channel is closed once the goroutine exits.
 //
 // NOTE: This MUST be run as a goroutine.
-func (c *ChannelArbitrator) resolveContract(currentContract ContractResolver) {
+func (c *ChannelArbitrator) resolveContract(currentContract ContractResolver,
+	done chan struct{}) {
+
 	defer c.wg.Done()
+	defer close(done)
 
 	log.Debugf("ChannelArbitrator(%v): attempting to resolve %T",
 		c.cfg.ChanPoint, currentContract)
@@ -2739,6 +2817,7 @@ func (c *ChannelArbitrator) channelAttendant(bestHeight int32) {
 					"%v", err)
 				return
 			}
+			c.updateCloseRecord(channeldb.CloseReasonUnknown, false)
 
 			// We'll now advance our state machine until it reaches
 			// a terminal state, and the channel is marked resolved.
@@ -2810,6 +2889,7 @@ func (c *ChannelArbitrator) channelAttendant(bestHeight int32) {
 					"channel closed: %v", err)
 				return
 			}
+			c.updateCloseRecord(channeldb.CloseReasonUnknown, false)
 
 			// We'll now advance our state machine until it reaches
 			// a terminal state.
@@ -2879,6 +2959,7 @@ func (c *ChannelArbitrator) channelAttendant(bestHeight int32) {
 					err)
 				return
 			}
+			c.updateCloseRecord(channeldb.CloseReasonUnknown, false)
 
 			// We'll now advance our state machine until it reaches
 			// a terminal state.
@@ -2938,6 +3019,7 @@ func (c *ChannelArbitrator) channelAttendant(bestHeight int32) {
 					err)
 				return
 			}
+			c.updateCloseRecord(channeldb.CloseReasonUnknown, false)
 
 			log.Infof("Breached channel=%v marked pending-closed",
 				breachInfo.BreachResolution.FundingOutPoint)
@@ -2959,6 +3041,8 @@ func (c *ChannelArbitrator) channelAttendant(bestHeight int32) {
 			log.Infof("ChannelArbitrator(%v): a contract has been "+
 				"fully resolved!", c.cfg.ChanPoint)
 
+			c.updateCloseRecord(channeldb.CloseReasonUnknown, false)
+
 			nextState, _, err := c.advanceState(
 				uint32(bestHeight), chainTrigger, nil,
 			)
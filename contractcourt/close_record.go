@@ -0,0 +1,563 @@
+package contractcourt
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwallet"
+)
+
+// updateCloseRecord rebuilds the close record of a channel from its close
+// summary, historical channel state and resolver reports, and writes it to
+// disk. A known close reason replaces the one that is currently stored. If
+// the channel has not been marked closed yet, only the reason is persisted so
+// that it can be included once the close confirms.
+func (c *ChainArbitrator) updateCloseRecord(chanPoint wire.OutPoint,
+	reason channeldb.CloseReason, resolved bool) error {
+
+	chainHash := c.cfg.ChainHash
+
+	record, err := c.chanSource.FetchCloseRecord(chainHash, &chanPoint)
+	switch {
+	case errors.Is(err, channeldb.ErrCloseRecordNotFound):
+		record = &channeldb.CloseRecord{
+			ChanPoint: chanPoint,
+		}
+
+	case err != nil:
+		return err
+	}
+
+	if reason != channeldb.CloseReasonUnknown {
+		record.Reason = reason
+	}
+
+	chanStateDB := c.chanSource.ChannelStateDB()
+	summary, err := chanStateDB.FetchClosedChannel(&chanPoint)
+	switch {
+	case errors.Is(err, channeldb.ErrClosedChannelNotFound):
+		return c.chanSource.PutCloseRecord(chainHash, record)
+
+	case err != nil:
+		return err
+	}
+
+	histChan, err := chanStateDB.FetchHistoricalChannel(&chanPoint)
+	switch {
+	case errors.Is(err, channeldb.ErrNoHistoricalBucket),
+		errors.Is(err, channeldb.ErrChannelNotFound):
+
+		histChan = nil
+
+	case err != nil:
+		return err
+	}
+
+	reports, err := c.chanSource.FetchChannelReports(chainHash, &chanPoint)
+	switch {
+	case errors.Is(err, channeldb.ErrNoChainHashBucket),
+		errors.Is(err, channeldb.ErrNoChannelSummaries):
+
+		reports = nil
+
+	case err != nil:
+		return err
+	}
+
+	builder := newCloseRecordBuilder(
+		summary, histChan, c.cfg.FetchTxDetails,
+	)
+	newRecord := builder.build(record.Reason, reports)
+	newRecord.Resolved = resolved || record.Resolved
+
+	return c.chanSource.PutCloseRecord(chainHash, newRecord)
+}
+
+// CloseRecords returns the close records of all channels that were closed on
+// chain and handled by the chain arbitrator.
+func (c *ChainArbitrator) CloseRecords() ([]*channeldb.CloseRecord, error) {
+	records, err := c.chanSource.FetchCloseRecords(c.cfg.ChainHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// Records that only hold a close reason belong to channels whose
+	// close has not confirmed yet, so we leave them out.
+	closed := make([]*channeldb.CloseRecord, 0, len(records))
+	for _, record := range records {
+		if record.ClosingTXID == (chainhash.Hash{}) {
+			continue
+		}
+
+		closed = append(closed, record)
+	}
+
+	return closed, nil
+}
+
+// closeRecordBuilder assembles the close record of a channel. Transactions are
+// looked up in the wallet to account for fees, and cached so that each one is
+// only fetched once.
+type closeRecordBuilder struct {
+	summary  *channeldb.ChannelCloseSummary
+	histChan *channeldb.OpenChannel
+
+	fetchTx func(chainhash.Hash) (*lnwallet.TransactionDetail, error)
+
+	txCache map[chainhash.Hash]*closeRecordTx
+}
+
+// closeRecordTx is a transaction fetched from the wallet along with its
+// details.
+type closeRecordTx struct {
+	tx     *wire.MsgTx
+	detail *lnwallet.TransactionDetail
+}
+
+// newCloseRecordBuilder creates a builder for the close record of a channel.
+// The historical channel and the tx fetcher are optional.
+func newCloseRecordBuilder(summary *channeldb.ChannelCloseSummary,
+	histChan *channeldb.OpenChannel,
+	fetchTx func(chainhash.Hash) (*lnwallet.TransactionDetail,
+		error)) *closeRecordBuilder {
+
+	return &closeRecordBuilder{
+		summary:  summary,
+		histChan: histChan,
+		fetchTx:  fetchTx,
+		txCache:  make(map[chainhash.Hash]*closeRecordTx),
+	}
+}
+
+// build creates a close record from the close summary and the resolver
+// reports of the channel.
+func (b *closeRecordBuilder) build(storedReason channeldb.CloseReason,
+	reports []*channeldb.ResolverReport) *channeldb.CloseRecord {
+
+	summary := b.summary
+
+	record := &channeldb.CloseRecord{
+		ChanPoint:         summary.ChanPoint,
+		RemotePub:         summary.RemotePub,
+		Capacity:          summary.Capacity,
+		ClosingTXID:       summary.ClosingTXID,
+		CloseHeight:       summary.CloseHeight,
+		CloseType:         summary.CloseType,
+		Initiator:         b.initiator(),
+		Reason:            closeReason(summary.CloseType, storedReason),
+		TimeLockedBalance: summary.TimeLockedBalance,
+		CloseFee:          b.closeFee(),
+	}
+
+	if b.histChan != nil {
+		record.LastLocalBalance =
+			b.histChan.LocalCommitment.LocalBalance.ToSatoshis()
+	}
+
+	// The values of all outputs that were resolved are known from the
+	// reports, which lets us calculate the fees of the transactions that
+	// spend them without having to look up the closing transaction.
+	knownValues := make(map[wire.OutPoint]btcutil.Amount, len(reports))
+	secondStage := make(map[chainhash.Hash][]*channeldb.ResolverReport)
+	for _, report := range reports {
+		knownValues[report.OutPoint] = report.Amount
+
+		if report.OutPoint.Hash != summary.ClosingTXID {
+			hash := report.OutPoint.Hash
+			secondStage[hash] = append(secondStage[hash], report)
+		}
+	}
+
+	var commitAmount btcutil.Amount
+	for _, report := range reports {
+		if report.OutPoint.Hash != summary.ClosingTXID {
+			continue
+		}
+
+		output := b.buildOutput(report, secondStage, knownValues)
+		record.Outputs = append(record.Outputs, output)
+
+		switch report.ResolverType {
+		case channeldb.ResolverTypeAnchor:
+			record.AnchorFee += output.SweepFee
+
+		case channeldb.ResolverTypeCommit:
+			commitAmount += report.Amount
+		}
+	}
+
+	// A cooperative close pays our balance straight to our wallet. For
+	// force closes, the settled balance includes our commitment output
+	// which is accounted for by the commitment resolver, so we only
+	// include what was not swept.
+	record.DirectBalance = summary.SettledBalance
+	if summary.CloseType != channeldb.CooperativeClose {
+		record.DirectBalance -= commitAmount
+		if record.DirectBalance < 0 {
+			record.DirectBalance = 0
+		}
+	}
+
+	return record
+}
+
+// buildOutput accounts for a single output of the closing transaction,
+// following it into the second stage if it was resolved over two stages.
+func (b *closeRecordBuilder) buildOutput(report *channeldb.ResolverReport,
+	secondStage map[chainhash.Hash][]*channeldb.ResolverReport,
+	knownValues map[wire.OutPoint]btcutil.Amount) *channeldb.CloseOutput {
+
+	output := &channeldb.CloseOutput{
+		OutPoint:        report.OutPoint,
+		ResolverType:    report.ResolverType,
+		ResolverOutcome: report.ResolverOutcome,
+		Amount:          report.Amount,
+		TimeLocked:      b.isTimeLocked(report.ResolverType),
+	}
+
+	final := report
+	if report.ResolverOutcome == channeldb.ResolverOutcomeFirstStage &&
+		report.SpendTxID != nil {
+
+		// Htlcs on our own commitment are always time locked, either
+		// by their cltv or by the csv of the second level output.
+		output.TimeLocked = true
+
+		second := b.matchSecondStage(report, secondStage)
+		fee, ok := b.feeShare(
+			*report.SpendTxID, report.OutPoint, knownValues,
+		)
+
+		// If we could not look up the first stage transaction, we
+		// fall back to the difference between the first and second
+		// stage output, which is exact for htlc transactions that are
+		// not batched.
+		if !ok && second != nil {
+			fee = report.Amount - second.Amount
+		}
+		output.SweepFee += fee
+
+		final = second
+		if second != nil {
+			output.ResolverOutcome = second.ResolverOutcome
+		}
+	}
+
+	if final == nil || final.SpendTxID == nil || !claimedByUs(final) {
+		output.Recovered = -output.SweepFee
+		return output
+	}
+
+	output.SweepTxID = final.SpendTxID
+	output.RecoveredHeight = b.summary.CloseHeight
+
+	// Outputs that were paid directly to our wallet by the closing
+	// transaction did not need a sweep, so there are no fees to account
+	// for.
+	if *final.SpendTxID != b.summary.ClosingTXID {
+		fee, _ := b.feeShare(
+			*final.SpendTxID, final.OutPoint, knownValues,
+		)
+		output.SweepFee += fee
+	}
+
+	if sweepTx := b.fetch(*final.SpendTxID); sweepTx != nil &&
+		sweepTx.detail.BlockHeight > 0 {
+
+		output.RecoveredHeight = uint32(sweepTx.detail.BlockHeight)
+		output.RecoveredTime = time.Unix(sweepTx.detail.Timestamp, 0)
+	}
+
+	output.Recovered = output.Amount - output.SweepFee
+
+	return output
+}
+
+// matchSecondStage returns the second stage report for a first stage htlc
+// report, or nil if the second stage has not been resolved yet.
+func (b *closeRecordBuilder) matchSecondStage(
+	report *channeldb.ResolverReport,
+	secondStage map[chainhash.Hash][]*channeldb.ResolverReport,
+) *channeldb.ResolverReport {
+
+	candidates := secondStage[*report.SpendTxID]
+	switch len(candidates) {
+	case 0:
+		return nil
+
+	case 1:
+		return candidates[0]
+	}
+
+	// Several htlcs were batched into the same second level
+	// transaction. As they are signed with SIGHASH_SINGLE, the output
+	// for an htlc shares the index of its input.
+	firstStage := b.fetch(*report.SpendTxID)
+	if firstStage == nil {
+		return nil
+	}
+
+	for i, txIn := range firstStage.tx.TxIn {
+		if txIn.PreviousOutPoint != report.OutPoint {
+			continue
+		}
+
+		for _, candidate := range candidates {
+			if candidate.OutPoint.Index == uint32(i) {
+				return candidate
+			}
+		}
+	}
+
+	return nil
+}
+
+// feeShare returns the share of the fee of the given transaction that is
+// attributable to one of its inputs. The fee is split between all inputs that
+// do not belong to our wallet by weight, since wallet inputs are only added to
+// pay for fees. False is returned if the fee could not be determined.
+func (b *closeRecordBuilder) feeShare(txid chainhash.Hash, op wire.OutPoint,
+	knownValues map[wire.OutPoint]btcutil.Amount) (btcutil.Amount, bool) {
+
+	spendTx := b.fetch(txid)
+	if spendTx == nil {
+		return 0, false
+	}
+
+	ourInputs := make(map[string]struct{})
+	for _, prevOut := range spendTx.detail.PreviousOutpoints {
+		if prevOut.IsOurOutput {
+			ourInputs[prevOut.OutPoint] = struct{}{}
+		}
+	}
+
+	var (
+		fee          btcutil.Amount
+		targetWeight int64
+		totalWeight  int64
+	)
+	for _, txIn := range spendTx.tx.TxIn {
+		value, ok := b.inputValue(txIn.PreviousOutPoint, knownValues)
+		if !ok {
+			return 0, false
+		}
+		fee += value
+
+		_, ours := ourInputs[txIn.PreviousOutPoint.String()]
+		if ours {
+			continue
+		}
+
+		weight := int64(txIn.SerializeSize()*
+			blockchain.WitnessScaleFactor) +
+			int64(txIn.Witness.SerializeSize())
+
+		totalWeight += weight
+		if txIn.PreviousOutPoint == op {
+			targetWeight = weight
+		}
+	}
+
+	for _, txOut := range spendTx.tx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+
+	if targetWeight == 0 || fee < 0 {
+		return 0, false
+	}
+
+	return fee * btcutil.Amount(targetWeight) /
+		btcutil.Amount(totalWeight), true
+}
+
+// inputValue returns the value of an outpoint, looking up the transaction that
+// created it if it is not known.
+func (b *closeRecordBuilder) inputValue(op wire.OutPoint,
+	knownValues map[wire.OutPoint]btcutil.Amount) (btcutil.Amount, bool) {
+
+	if value, ok := knownValues[op]; ok {
+		return value, true
+	}
+
+	prevTx := b.fetch(op.Hash)
+	if prevTx == nil || int(op.Index) >= len(prevTx.tx.TxOut) {
+		return 0, false
+	}
+
+	return btcutil.Amount(prevTx.tx.TxOut[op.Index].Value), true
+}
+
+// fetch returns a transaction from the wallet, or nil if it is not known.
+func (b *closeRecordBuilder) fetch(txid chainhash.Hash) *closeRecordTx {
+	if cached, ok := b.txCache[txid]; ok {
+		return cached
+	}
+
+	result := b.fetchUncached(txid)
+	b.txCache[txid] = result
+
+	return result
+}
+
+// fetchUncached looks up a transaction in the wallet and decodes it.
+func (b *closeRecordBuilder) fetchUncached(txid chainhash.Hash) *closeRecordTx {
+	if b.fetchTx == nil {
+		return nil
+	}
+
+	detail, err := b.fetchTx(txid)
+	if err != nil {
+		log.Debugf("Unable to fetch tx %v for close record of "+
+			"ChannelPoint(%v): %v", txid, b.summary.ChanPoint, err)
+
+		return nil
+	}
+
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(detail.RawTx)); err != nil {
+		log.Debugf("Unable to decode tx %v for close record of "+
+			"ChannelPoint(%v): %v", txid, b.summary.ChanPoint, err)
+
+		return nil
+	}
+
+	return &closeRecordTx{
+		tx:     tx,
+		detail: detail,
+	}
+}
+
+// closeFee returns the fee of the closing transaction if we paid it, which is
+// the case if we funded the channel.
+func (b *closeRecordBuilder) closeFee() btcutil.Amount {
+	if b.histChan == nil || !b.histChan.IsInitiator {
+		return 0
+	}
+
+	// If the wallet knows the closing transaction, we can calculate the
+	// exact fee from its outputs.
+	if closeTx := b.fetch(b.summary.ClosingTXID); closeTx != nil {
+		fee := b.summary.Capacity
+		for _, txOut := range closeTx.tx.TxOut {
+			fee -= btcutil.Amount(txOut.Value)
+		}
+
+		return fee
+	}
+
+	// Otherwise we use the fee of the commitment that confirmed.
+	switch b.summary.CloseType {
+	case channeldb.LocalForceClose:
+		return b.histChan.LocalCommitment.CommitFee
+
+	case channeldb.RemoteForceClose, channeldb.BreachClose:
+		return b.histChan.RemoteCommitment.CommitFee
+
+	default:
+		return 0
+	}
+}
+
+// initiator returns the party that initiated the close, based on the status of
+// the historical channel.
+func (b *closeRecordBuilder) initiator() channeldb.CloseInitiator {
+	if b.histChan == nil {
+		return channeldb.CloseInitiatorUnknown
+	}
+
+	local := b.histChan.HasChanStatus(
+		channeldb.ChanStatusLocalCloseInitiator,
+	)
+	remote := b.histChan.HasChanStatus(
+		channeldb.ChanStatusRemoteCloseInitiator,
+	)
+
+	switch {
+	case local && remote:
+		return channeldb.CloseInitiatorBoth
+
+	case local:
+		return channeldb.CloseInitiatorLocal
+
+	case remote:
+		return channeldb.CloseInitiatorRemote
+
+	default:
+		return channeldb.CloseInitiatorUnknown
+	}
+}
+
+// isTimeLocked returns whether an output of the given type was time locked on
+// the closing transaction.
+func (b *closeRecordBuilder) isTimeLocked(
+	resolverType channeldb.ResolverType) bool {
+
+	switch resolverType {
+	case channeldb.ResolverTypeCommit:
+		return b.summary.CloseType == channeldb.LocalForceClose
+
+	case channeldb.ResolverTypeOutgoingHtlc:
+		return true
+
+	default:
+		return false
+	}
+}
+
+// closeReason returns the reason for a close. Only local force closes can have
+// different reasons, which are recorded by the channel arbitrator when it
+// decides to go on chain.
+func closeReason(closeType channeldb.ClosureType,
+	stored channeldb.CloseReason) channeldb.CloseReason {
+
+	switch closeType {
+	case channeldb.CooperativeClose:
+		return channeldb.CloseReasonCooperative
+
+	case channeldb.LocalForceClose:
+		switch stored {
+		case channeldb.CloseReasonForceCloseRequest,
+			channeldb.CloseReasonHtlcOnChain:
+
+			return stored
+
+		default:
+			return channeldb.CloseReasonUnknown
+		}
+
+	case channeldb.RemoteForceClose:
+		return channeldb.CloseReasonRemoteForceClose
+
+	case channeldb.BreachClose:
+		return channeldb.CloseReasonBreach
+
+	case channeldb.FundingCanceled:
+		return channeldb.CloseReasonFundingCanceled
+
+	case channeldb.Abandoned:
+		return channeldb.CloseReasonAbandoned
+
+	default:
+		return stored
+	}
+}
+
+// claimedByUs returns whether the final report of an output shows that its
+// funds made their way to our wallet.
+func claimedByUs(report *channeldb.ResolverReport) bool {
+	switch report.ResolverType {
+	case channeldb.ResolverTypeOutgoingHtlc:
+		return report.ResolverOutcome ==
+			channeldb.ResolverOutcomeTimeout
+
+	default:
+		return report.ResolverOutcome ==
+			channeldb.ResolverOutcomeClaimed
+	}
+}
@@ -0,0 +1,233 @@
+package contractcourt
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// closeRecordWallet is a mock wallet that serves transaction details for the
+// close record builder.
+type closeRecordWallet struct {
+	txs map[chainhash.Hash]*lnwallet.TransactionDetail
+}
+
+// addTx adds a transaction to the wallet, marking the given inputs as ours.
+func (w *closeRecordWallet) addTx(t *testing.T, tx *wire.MsgTx,
+	height int32, ours ...wire.OutPoint) chainhash.Hash {
+
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+
+	ourInputs := make(map[wire.OutPoint]bool)
+	for _, op := range ours {
+		ourInputs[op] = true
+	}
+
+	var prevOuts []lnwallet.PreviousOutPoint
+	for _, txIn := range tx.TxIn {
+		prevOuts = append(prevOuts, lnwallet.PreviousOutPoint{
+			OutPoint:    txIn.PreviousOutPoint.String(),
+			IsOurOutput: ourInputs[txIn.PreviousOutPoint],
+		})
+	}
+
+	txid := tx.TxHash()
+	w.txs[txid] = &lnwallet.TransactionDetail{
+		Hash:              txid,
+		BlockHeight:       height,
+		Timestamp:         int64(height) * 600,
+		RawTx:             buf.Bytes(),
+		PreviousOutpoints: prevOuts,
+	}
+
+	return txid
+}
+
+func (w *closeRecordWallet) fetchTx(
+	txid chainhash.Hash) (*lnwallet.TransactionDetail, error) {
+
+	detail, ok := w.txs[txid]
+	if !ok {
+		return nil, errors.New("tx not found")
+	}
+
+	return detail, nil
+}
+
+// TestCloseRecordBuilder tests that the close record of a local force close
+// accounts for the fees paid to sweep the anchor, the commitment output and
+// a two stage htlc.
+func TestCloseRecordBuilder(t *testing.T) {
+	t.Parallel()
+
+	wallet := &closeRecordWallet{
+		txs: make(map[chainhash.Hash]*lnwallet.TransactionDetail),
+	}
+
+	closeTxID := chainhash.Hash{1}
+	htlcTxID := chainhash.Hash{2}
+
+	anchorOutpoint := wire.OutPoint{Hash: closeTxID, Index: 0}
+	commitOutpoint := wire.OutPoint{Hash: closeTxID, Index: 1}
+	htlcOutpoint := wire.OutPoint{Hash: closeTxID, Index: 2}
+	secondStageOutpoint := wire.OutPoint{Hash: htlcTxID, Index: 0}
+
+	// The anchor is swept with a wallet input that pays for the fee.
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.AddTxIn(&wire.TxIn{})
+	fundingTx.AddTxOut(&wire.TxOut{Value: 20000})
+	walletUtxo := wire.OutPoint{
+		Hash: wallet.addTx(t, fundingTx, 10),
+	}
+
+	anchorSweep := wire.NewMsgTx(2)
+	anchorSweep.AddTxIn(&wire.TxIn{PreviousOutPoint: anchorOutpoint})
+	anchorSweep.AddTxIn(&wire.TxIn{PreviousOutPoint: walletUtxo})
+	anchorSweep.AddTxOut(&wire.TxOut{Value: 19730})
+	anchorSweepTxID := wallet.addTx(t, anchorSweep, 101, walletUtxo)
+
+	// The commitment output and the second level htlc output are swept in
+	// the same transaction, so they share the fee.
+	sweep := wire.NewMsgTx(2)
+	sweep.AddTxIn(&wire.TxIn{PreviousOutPoint: commitOutpoint})
+	sweep.AddTxIn(&wire.TxIn{PreviousOutPoint: secondStageOutpoint})
+	sweep.AddTxOut(&wire.TxOut{Value: 58800})
+	sweepTxID := wallet.addTx(t, sweep, 250)
+
+	reports := []*channeldb.ResolverReport{
+		{
+			OutPoint:        anchorOutpoint,
+			Amount:          330,
+			ResolverType:    channeldb.ResolverTypeAnchor,
+			ResolverOutcome: channeldb.ResolverOutcomeClaimed,
+			SpendTxID:       &anchorSweepTxID,
+		},
+		{
+			OutPoint:        commitOutpoint,
+			Amount:          50000,
+			ResolverType:    channeldb.ResolverTypeCommit,
+			ResolverOutcome: channeldb.ResolverOutcomeClaimed,
+			SpendTxID:       &sweepTxID,
+		},
+		{
+			OutPoint:        htlcOutpoint,
+			Amount:          10000,
+			ResolverType:    channeldb.ResolverTypeOutgoingHtlc,
+			ResolverOutcome: channeldb.ResolverOutcomeFirstStage,
+			SpendTxID:       &htlcTxID,
+		},
+		{
+			OutPoint:        secondStageOutpoint,
+			Amount:          9000,
+			ResolverType:    channeldb.ResolverTypeOutgoingHtlc,
+			ResolverOutcome: channeldb.ResolverOutcomeTimeout,
+			SpendTxID:       &sweepTxID,
+		},
+	}
+
+	summary := &channeldb.ChannelCloseSummary{
+		ChanPoint:         wire.OutPoint{Hash: chainhash.Hash{3}},
+		ClosingTXID:       closeTxID,
+		Capacity:          100000,
+		CloseHeight:       100,
+		SettledBalance:    50000,
+		TimeLockedBalance: 60000,
+		CloseType:         channeldb.LocalForceClose,
+	}
+
+	histChan := &channeldb.OpenChannel{
+		IsInitiator: true,
+		LocalCommitment: channeldb.ChannelCommitment{
+			LocalBalance: lnwire.NewMSatFromSatoshis(50000),
+			CommitFee:    1000,
+		},
+	}
+
+	builder := newCloseRecordBuilder(summary, histChan, wallet.fetchTx)
+	record := builder.build(channeldb.CloseReasonHtlcOnChain, reports)
+
+	require.Equal(t, channeldb.CloseReasonHtlcOnChain, record.Reason)
+	require.Equal(t, closeTxID, record.ClosingTXID)
+
+	// The closing transaction is not known to the wallet, so the fee of
+	// our commitment is used.
+	require.Equal(t, btcutil.Amount(1000), record.CloseFee)
+	require.Equal(t, btcutil.Amount(600), record.AnchorFee)
+	require.Equal(t, btcutil.Amount(0), record.DirectBalance)
+	require.Equal(t, btcutil.Amount(60000), record.TimeLockedBalance)
+	require.Equal(t, btcutil.Amount(50000), record.LastLocalBalance)
+	require.Len(t, record.Outputs, 3)
+
+	anchor := record.Outputs[0]
+	require.Equal(t, anchorOutpoint, anchor.OutPoint)
+	require.Equal(t, btcutil.Amount(600), anchor.SweepFee)
+	require.Equal(t, btcutil.Amount(-270), anchor.Recovered)
+	require.False(t, anchor.TimeLocked)
+
+	commit := record.Outputs[1]
+	require.Equal(t, commitOutpoint, commit.OutPoint)
+	require.Equal(t, btcutil.Amount(100), commit.SweepFee)
+	require.Equal(t, btcutil.Amount(49900), commit.Recovered)
+	require.True(t, commit.TimeLocked)
+	require.Equal(t, &sweepTxID, commit.SweepTxID)
+	require.Equal(t, uint32(250), commit.RecoveredHeight)
+	require.Equal(t, time.Unix(250*600, 0), commit.RecoveredTime)
+
+	// The htlc transaction is not known to the wallet, so its fee is
+	// derived from the first and second stage amounts.
+	htlc := record.Outputs[2]
+	require.Equal(t, htlcOutpoint, htlc.OutPoint)
+	require.Equal(t, channeldb.ResolverOutcomeTimeout, htlc.ResolverOutcome)
+	require.Equal(t, btcutil.Amount(1100), htlc.SweepFee)
+	require.Equal(t, btcutil.Amount(8900), htlc.Recovered)
+	require.True(t, htlc.TimeLocked)
+
+	require.Equal(t, btcutil.Amount(1800), record.SweepFees())
+	require.Equal(t, btcutil.Amount(8530), record.NetChange())
+}
+
+// TestCloseRecordPendingSecondStage tests that a htlc which has only been
+// resolved in its first stage accounts for the fee paid so far.
+func TestCloseRecordPendingSecondStage(t *testing.T) {
+	t.Parallel()
+
+	closeTxID := chainhash.Hash{1}
+	htlcTxID := chainhash.Hash{2}
+
+	reports := []*channeldb.ResolverReport{
+		{
+			OutPoint:        wire.OutPoint{Hash: closeTxID},
+			Amount:          10000,
+			ResolverType:    channeldb.ResolverTypeIncomingHtlc,
+			ResolverOutcome: channeldb.ResolverOutcomeFirstStage,
+			SpendTxID:       &htlcTxID,
+		},
+	}
+
+	summary := &channeldb.ChannelCloseSummary{
+		ClosingTXID: closeTxID,
+		CloseType:   channeldb.LocalForceClose,
+	}
+
+	builder := newCloseRecordBuilder(summary, nil, nil)
+	record := builder.build(channeldb.CloseReasonUnknown, reports)
+
+	require.Len(t, record.Outputs, 1)
+	require.Equal(
+		t, channeldb.ResolverOutcomeFirstStage,
+		record.Outputs[0].ResolverOutcome,
+	)
+	require.Nil(t, record.Outputs[0].SweepTxID)
+	require.Zero(t, record.Outputs[0].Recovered)
+	require.Equal(t, channeldb.CloseInitiatorUnknown, record.Initiator)
+}
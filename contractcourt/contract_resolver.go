@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btclog"
 	"github.com/lightningnetwork/lnd/build"
 	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/sweep"
 )
 
 var (
@@ -108,6 +112,10 @@ type contractResolverKit struct {
 
 	log btclog.Logger
 
+	// sweepReq records the last request the resolver submitted to the
+	// sweeper.
+	sweepReq *sweepRequestRecord
+
 	quit chan struct{}
 }
 
@@ -115,10 +123,56 @@ type contractResolverKit struct {
 func newContractResolverKit(cfg ResolverConfig) *contractResolverKit {
 	return &contractResolverKit{
 		ResolverConfig: cfg,
+		sweepReq:       &sweepRequestRecord{},
 		quit:           make(chan struct{}),
 	}
 }
 
+// sweepInput offers the input to the sweeper and records the request, so it
+// can be inspected while the resolver waits for the sweep.
+func (r *contractResolverKit) sweepInput(inp input.Input,
+	params sweep.Params) (chan sweep.Result, error) {
+
+	resultChan, err := r.Sweeper.SweepInput(inp, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.sweepReq != nil {
+		r.sweepReq.set(&SweepRequest{
+			Outpoint:    *inp.OutPoint(),
+			WitnessType: inp.WitnessType(),
+			Amount: btcutil.Amount(
+				inp.SignDesc().Output.Value,
+			),
+			Params:    params,
+			Timestamp: time.Now(),
+		})
+	}
+
+	return resultChan, nil
+}
+
+// isStopped returns true if the resolver has been signalled to stop.
+func (r *contractResolverKit) isStopped() bool {
+	select {
+	case <-r.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// lastSweepRequest returns the last request the resolver submitted to the
+// sweeper, or nil if it didn't submit any yet.
+func (r *contractResolverKit) lastSweepRequest() *SweepRequest {
+	if r.sweepReq == nil {
+		return nil
+	}
+
+	return r.sweepReq.get()
+}
+
 // initLogger initializes the resolver-specific logger.
 func (r *contractResolverKit) initLogger(resolver ContractResolver) {
 	logPrefix := fmt.Sprintf("%T(%v):", resolver, r.ChanPoint)
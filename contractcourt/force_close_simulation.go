@@ -0,0 +1,411 @@
+package contractcourt
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+// SimulatedHtlc describes how an HTLC on our commitment would be resolved if
+// we force closed the channel.
+type SimulatedHtlc struct {
+	// PaymentHash is the payment hash of the HTLC.
+	PaymentHash lntypes.Hash
+
+	// Amount is the value of the HTLC output on the commitment.
+	Amount btcutil.Amount
+
+	// Incoming is true if the HTLC was offered to us.
+	Incoming bool
+
+	// CltvExpiry is the height at which the HTLC times out. We can only
+	// sweep an outgoing HTLC from then on, and must have swept an incoming
+	// one before.
+	CltvExpiry uint32
+
+	// PreimageKnown is true if we know the preimage of an incoming HTLC,
+	// so we're able to claim it.
+	PreimageKnown bool
+
+	// SecondLevelWeight is the estimated weight of our HTLC timeout
+	// transaction for an outgoing HTLC, or our HTLC success transaction
+	// for an incoming one.
+	SecondLevelWeight int64
+
+	// SecondLevelFee is the fee of the second level transaction. It's
+	// pre-signed for channels without zero-fee HTLC transactions.
+	// Otherwise it's the fee the sweeper needs to attach at the simulated
+	// fee rate, not counting the wallet input it adds for that.
+	SecondLevelFee btcutil.Amount
+
+	// MaturityHeight is the height at which the CSV delayed output of the
+	// second level transaction can be swept, given the commitment confirms
+	// in the next block and the second level transaction as early as
+	// possible. For leased channels we initiated, it's no earlier than the
+	// lease expiry.
+	MaturityHeight uint32
+
+	// SweepFee is the fee of sweeping the output of the second level
+	// transaction at the simulated fee rate.
+	SweepFee btcutil.Amount
+
+	// Recoverable is true if we expect to sweep the HTLC, which is the
+	// case for outgoing HTLCs and incoming ones we know the preimage of.
+	Recoverable bool
+}
+
+// ForceCloseSimulation describes what a force close of a channel at its
+// current state would cost and how much of the channel balance we'd recover.
+type ForceCloseSimulation struct {
+	// CommitWeight is the estimated weight of our commitment transaction.
+	CommitWeight int64
+
+	// CommitFee is the fee our commitment transaction pays.
+	CommitFee btcutil.Amount
+
+	// FeeRate is the fee rate at which all transactions following the
+	// commitment are simulated.
+	FeeRate chainfee.SatPerKWeight
+
+	// AnchorCPFPFee is the fee the anchor sweep must pay so that our
+	// commitment and the sweep confirm at FeeRate as a package. It's zero
+	// if the channel has no anchors or the commitment already pays
+	// FeeRate.
+	AnchorCPFPFee btcutil.Amount
+
+	// LocalAmount is the value of our output on the commitment, which is
+	// zero if it's dust.
+	LocalAmount btcutil.Amount
+
+	// LocalMaturityHeight is the height at which our commitment output
+	// can be swept, given the commitment confirms in the next block. For
+	// leased channels we initiated, it's no earlier than the lease expiry.
+	LocalMaturityHeight uint32
+
+	// LocalSweepFee is the fee of sweeping our commitment output at
+	// FeeRate.
+	LocalSweepFee btcutil.Amount
+
+	// Htlcs are the HTLCs on our commitment that aren't dust.
+	Htlcs []SimulatedHtlc
+
+	// SweepBudget is the total fee we expect to pay after the commitment
+	// to recover our funds. It's the sum of the anchor CPFP fee and the
+	// second level and sweep fees of our commitment output and the
+	// recoverable HTLCs.
+	SweepBudget btcutil.Amount
+
+	// RecoverableAmount is the value of our commitment output and the
+	// recoverable HTLCs before fees.
+	RecoverableAmount btcutil.Amount
+
+	// NetRecoverable is RecoverableAmount minus SweepBudget. It's negative
+	// if recovering our funds costs more than they're worth.
+	NetRecoverable btcutil.Amount
+}
+
+// SimulateForceClose simulates a force close of the given channel at its
+// current state without broadcasting anything. It resolves the outputs of our
+// commitment like the contract resolvers would, assuming the commitment
+// confirms in the block after currentHeight and all following transactions pay
+// the given fee rate. Nothing is signed: the transactions are built unsigned
+// and their weights are estimated from the sizes of their witnesses.
+func (c *ChainArbitrator) SimulateForceClose(chanPoint wire.OutPoint,
+	feeRate chainfee.SatPerKWeight,
+	currentHeight uint32) (*ForceCloseSimulation, error) {
+
+	channel, err := c.chanSource.ChannelStateDB().FetchChannel(
+		nil, chanPoint,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return simulateForceClose(
+		channel, feeRate, currentHeight, c.cfg.PreimageDB,
+	)
+}
+
+// simulateForceClose derives the ForceCloseSimulation of our current
+// commitment of the given channel.
+func simulateForceClose(chanState *channeldb.OpenChannel,
+	feeRate chainfee.SatPerKWeight, currentHeight uint32,
+	preimageDB WitnessBeacon) (*ForceCloseSimulation, error) {
+
+	// Just like a real force close, we refuse to simulate one if we may
+	// not have the latest commitment.
+	if chanState.HasChanStatus(channeldb.ChanStatusLocalDataLoss) {
+		return nil, fmt.Errorf("%w: channel_state=%v",
+			lnwallet.ErrForceCloseLocalDataLoss,
+			chanState.ChanStatus())
+	}
+
+	chanType := chanState.ChanType
+	localCommit := chanState.LocalCommitment
+
+	// Our commitment is stored without the witness spending the funding
+	// output, which is only added when it's signed for a force close. We
+	// make sure to work on a copy without any witness data regardless.
+	commitTx := localCommit.CommitTx.Copy()
+	for _, txIn := range commitTx.TxIn {
+		txIn.Witness = nil
+	}
+
+	fundingWitnessWeight := int64(input.WitnessCommitmentTxWeight)
+	if chanType.IsTaproot() {
+		fundingWitnessWeight = input.WitnessHeaderSize +
+			input.TaprootKeyPathWitnessSize
+	}
+
+	sim := &ForceCloseSimulation{
+		CommitWeight: blockchain.GetTransactionWeight(
+			btcutil.NewTx(commitTx),
+		) + fundingWitnessWeight,
+		CommitFee: localCommit.CommitFee,
+		FeeRate:   feeRate,
+	}
+
+	// We derive the keys of our commitment to locate our outputs and to
+	// build the second level HTLC transactions.
+	revocation, err := chanState.RevocationProducer.AtIndex(
+		localCommit.CommitHeight,
+	)
+	if err != nil {
+		return nil, err
+	}
+	keyRing := lnwallet.DeriveCommitmentKeys(
+		input.ComputeCommitmentPoint(revocation[:]), true, chanType,
+		&chanState.LocalChanCfg, &chanState.RemoteChanCfg,
+	)
+
+	csvDelay := uint32(chanState.LocalChanCfg.CsvDelay)
+	var leaseExpiry uint32
+	if chanType.HasLeaseExpiration() {
+		leaseExpiry = chanState.ThawHeight
+	}
+
+	// If we're the initiator of a leased channel, our delayed outputs are
+	// additionally locked until the lease expires.
+	scriptEnforcedLease := chanState.IsInitiator &&
+		chanType.HasLeaseExpiration()
+
+	// The CSV delays start once the commitment confirms, which at the
+	// earliest happens in the next block.
+	confHeight := currentHeight + 1
+
+	// maturityHeight returns the height at which a delayed output of ours
+	// can be swept, given the transaction creating it confirms at the
+	// passed height.
+	maturityHeight := func(height uint32) uint32 {
+		maturity := height + csvDelay
+		if scriptEnforcedLease && leaseExpiry > maturity {
+			maturity = leaseExpiry
+		}
+
+		return maturity
+	}
+
+	// The outputs following the commitment are all CSV delayed outputs
+	// paying to us, which we sweep one by one into a wallet output.
+	delayedWitnessSize := input.ToLocalTimeoutWitnessSize
+	secondLevelWitnessSize := input.ToLocalTimeoutWitnessSize
+	anchorWitnessSize := input.AnchorWitnessSize
+	switch {
+	case chanType.IsTaproot():
+		delayedWitnessSize = input.TaprootToLocalWitnessSize
+		secondLevelWitnessSize = input.TaprootSecondLevelHtlcWitnessSize
+		anchorWitnessSize = input.TaprootAnchorWitnessSize
+
+	// The lease adds a CLTV check to the scripts of our delayed outputs.
+	case scriptEnforcedLease:
+		delayedWitnessSize += input.LeaseWitnessScriptSizeOverhead
+		secondLevelWitnessSize += input.LeaseWitnessScriptSizeOverhead
+	}
+	sweepFee := func(witnessSize int) btcutil.Amount {
+		var estimator input.TxWeightEstimator
+		estimator.AddWitnessInput(witnessSize)
+		estimator.AddP2TROutput()
+
+		return feeRate.FeeForWeight(int64(estimator.Weight()))
+	}
+
+	// If the commitment pays less than the fee rate, the anchor sweep has
+	// to bump it by paying for the package of both. Like the sweeper, we
+	// add a wallet input and a change output to the sweep.
+	anchor, err := lnwallet.NewAnchorResolution(
+		chanState, commitTx, keyRing, true,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if anchor != nil {
+		var estimator input.TxWeightEstimator
+		estimator.AddWitnessInput(anchorWitnessSize)
+		estimator.AddTaprootKeySpendInput(txscript.SigHashDefault)
+		estimator.AddP2TROutput()
+
+		packageWeight := sim.CommitWeight + int64(estimator.Weight())
+		packageFee := feeRate.FeeForWeight(packageWeight)
+		if packageFee > sim.CommitFee {
+			sim.AnchorCPFPFee = packageFee - sim.CommitFee
+		}
+	}
+	sim.SweepBudget = sim.AnchorCPFPFee
+
+	// Our commitment output is missing if it's dust.
+	toLocalScript, err := lnwallet.CommitScriptToSelf(
+		chanType, chanState.IsInitiator, keyRing.ToLocalKey,
+		keyRing.RevocationKey, csvDelay, leaseExpiry,
+	)
+	if err != nil {
+		return nil, err
+	}
+	found, toLocalIndex := input.FindScriptOutputIndex(
+		commitTx, toLocalScript.PkScript(),
+	)
+	if found {
+		sim.LocalAmount = btcutil.Amount(
+			commitTx.TxOut[toLocalIndex].Value,
+		)
+		sim.LocalMaturityHeight = maturityHeight(confHeight)
+		sim.LocalSweepFee = sweepFee(delayedWitnessSize)
+
+		sim.RecoverableAmount += sim.LocalAmount
+		sim.SweepBudget += sim.LocalSweepFee
+	}
+
+	// On our own commitment, every HTLC that isn't dust is resolved
+	// through a second level transaction.
+	commitHash := commitTx.TxHash()
+	htlcFeeRate := chainfee.SatPerKWeight(localCommit.FeePerKw)
+	for _, htlc := range localCommit.Htlcs {
+		if htlc.OutputIndex < 0 {
+			continue
+		}
+
+		secondLevelTx, witnessSize, err := unsignedSecondLevelTx(
+			chanState, htlc, commitHash, keyRing, htlcFeeRate,
+			leaseExpiry,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		simHtlc := SimulatedHtlc{
+			PaymentHash: htlc.RHash,
+			Amount:      htlc.Amt.ToSatoshis(),
+			Incoming:    htlc.Incoming,
+			CltvExpiry:  htlc.RefundTimeout,
+			SecondLevelWeight: blockchain.GetTransactionWeight(
+				btcutil.NewTx(secondLevelTx),
+			) + input.WitnessHeaderSize + int64(witnessSize),
+			SweepFee: sweepFee(secondLevelWitnessSize),
+		}
+
+		simHtlc.SecondLevelFee = simHtlc.Amount - btcutil.Amount(
+			secondLevelTx.TxOut[0].Value,
+		)
+		if chanType.ZeroHtlcTxFee() || chanType.IsTaproot() {
+			simHtlc.SecondLevelFee = feeRate.FeeForWeight(
+				simHtlc.SecondLevelWeight,
+			)
+		}
+
+		// An outgoing HTLC can only be timed out once it expired,
+		// while an incoming one can be claimed right away if we know
+		// the preimage.
+		secondLevelHeight := confHeight
+		if htlc.Incoming {
+			_, simHtlc.PreimageKnown = preimageDB.LookupPreimage(
+				htlc.RHash,
+			)
+			simHtlc.Recoverable = simHtlc.PreimageKnown
+		} else {
+			if htlc.RefundTimeout > secondLevelHeight {
+				secondLevelHeight = htlc.RefundTimeout
+			}
+			simHtlc.Recoverable = true
+		}
+
+		if simHtlc.Recoverable {
+			simHtlc.MaturityHeight = maturityHeight(
+				secondLevelHeight,
+			)
+
+			sim.RecoverableAmount += simHtlc.Amount
+			sim.SweepBudget += simHtlc.SecondLevelFee +
+				simHtlc.SweepFee
+		}
+
+		sim.Htlcs = append(sim.Htlcs, simHtlc)
+	}
+
+	sim.NetRecoverable = sim.RecoverableAmount - sim.SweepBudget
+
+	return sim, nil
+}
+
+// unsignedSecondLevelTx creates our unsigned HTLC timeout transaction for an
+// outgoing HTLC on our commitment, or our unsigned HTLC success transaction
+// for an incoming one. It also returns the size of the witness that spends the
+// HTLC output, which isn't part of the transaction.
+func unsignedSecondLevelTx(chanState *channeldb.OpenChannel,
+	htlc channeldb.HTLC, commitHash chainhash.Hash,
+	keyRing *lnwallet.CommitmentKeyRing, htlcFeeRate chainfee.SatPerKWeight,
+	leaseExpiry uint32) (*wire.MsgTx, int, error) {
+
+	chanType := chanState.ChanType
+	csvDelay := uint32(chanState.LocalChanCfg.CsvDelay)
+	htlcOutput := wire.OutPoint{
+		Hash:  commitHash,
+		Index: uint32(htlc.OutputIndex),
+	}
+
+	if !htlc.Incoming {
+		witnessSize := input.OfferedHtlcTimeoutWitnessSize
+		switch {
+		case chanType.IsTaproot():
+			witnessSize = input.TaprootOfferedLocalTimeoutWitnessSize
+
+		case chanType.HasAnchors():
+			witnessSize = input.OfferedHtlcTimeoutWitnessSizeConfirmed
+		}
+
+		htlcFee := lnwallet.HtlcTimeoutFee(chanType, htlcFeeRate)
+		timeoutTx, err := lnwallet.CreateHtlcTimeoutTx(
+			chanType, chanState.IsInitiator, htlcOutput,
+			htlc.Amt.ToSatoshis()-htlcFee, htlc.RefundTimeout,
+			csvDelay, leaseExpiry, keyRing.RevocationKey,
+			keyRing.ToLocalKey,
+		)
+
+		return timeoutTx, witnessSize, err
+	}
+
+	witnessSize := input.AcceptedHtlcSuccessWitnessSize
+	switch {
+	case chanType.IsTaproot():
+		witnessSize = input.TaprootHtlcAcceptedLocalSuccessWitnessSize
+
+	case chanType.HasAnchors():
+		witnessSize = input.AcceptedHtlcSuccessWitnessSizeConfirmed
+	}
+
+	htlcFee := lnwallet.HtlcSuccessFee(chanType, htlcFeeRate)
+	successTx, err := lnwallet.CreateHtlcSuccessTx(
+		chanType, chanState.IsInitiator, htlcOutput,
+		htlc.Amt.ToSatoshis()-htlcFee, csvDelay, leaseExpiry,
+		keyRing.RevocationKey, keyRing.ToLocalKey,
+	)
+
+	return successTx, witnessSize, err
+}
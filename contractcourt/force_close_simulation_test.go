@@ -0,0 +1,206 @@
+package contractcourt
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/lntypes"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSimulateForceClose tests that the simulation of a force close accounts
+// for the commitment output, the outgoing HTLCs and the incoming HTLCs we know
+// the preimage of, and estimates the weights of the unsigned transactions.
+func TestSimulateForceClose(t *testing.T) {
+	t.Parallel()
+
+	const (
+		htlcAmt       = lnwire.MilliSatoshi(500_000_000)
+		currentHeight = 100
+		feeRate       = chainfee.SatPerKWeight(50_000)
+	)
+
+	aliceChannel, bobChannel, err := lnwallet.CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit|channeldb.ZeroHtlcTxFeeBit,
+	)
+	require.NoError(t, err)
+
+	// Alice offers an HTLC to Bob, and Bob offers two HTLCs to Alice, of
+	// which Alice only knows one preimage.
+	addHtlc := func(sender, receiver *lnwallet.LightningChannel,
+		preimage lntypes.Preimage, expiry uint32) {
+
+		htlc := &lnwire.UpdateAddHTLC{
+			PaymentHash: preimage.Hash(),
+			Amount:      htlcAmt,
+			Expiry:      expiry,
+		}
+		id, err := sender.AddHTLC(htlc, nil)
+		require.NoError(t, err)
+
+		htlc.ID = id
+		_, err = receiver.ReceiveHTLC(htlc)
+		require.NoError(t, err)
+	}
+
+	outgoing := lntypes.Preimage{1}
+	known := lntypes.Preimage{2}
+	unknown := lntypes.Preimage{3}
+	addHtlc(aliceChannel, bobChannel, outgoing, 200)
+	addHtlc(bobChannel, aliceChannel, known, 150)
+	addHtlc(bobChannel, aliceChannel, unknown, 150)
+
+	err = lnwallet.ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+
+	preimageDB := newMockWitnessBeacon()
+	preimageDB.lookupPreimage[known.Hash()] = known
+
+	sim, err := simulateForceClose(
+		aliceChannel.State(), feeRate, currentHeight, preimageDB,
+	)
+	require.NoError(t, err)
+	require.NotZero(t, sim.AnchorCPFPFee)
+
+	// The simulation estimates the weights of the transactions without
+	// signing them. The estimates must cover the weights of the signed
+	// transactions of a real force close, with some slack for the
+	// signatures being shorter than their maximum size.
+	summary, err := aliceChannel.ForceClose()
+	require.NoError(t, err)
+
+	requireWeightEstimate := func(estimate int64, tx *wire.MsgTx) {
+		t.Helper()
+
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+		require.GreaterOrEqual(t, estimate, weight)
+		require.LessOrEqual(t, estimate-weight, int64(10))
+	}
+	requireWeightEstimate(sim.CommitWeight, summary.CloseTx)
+	require.Equal(t, summary.ChanSnapshot.CommitFee, sim.CommitFee)
+
+	require.Len(t, summary.HtlcResolutions.OutgoingHTLCs, 1)
+	timeoutTx := summary.HtlcResolutions.OutgoingHTLCs[0].SignedTimeoutTx
+	require.NotNil(t, timeoutTx)
+
+	csvDelay := uint32(aliceChannel.State().LocalChanCfg.CsvDelay)
+	require.Equal(t, currentHeight+1+csvDelay, sim.LocalMaturityHeight)
+
+	require.Len(t, sim.Htlcs, 3)
+	expectedRecoverable := sim.LocalAmount
+	expectedBudget := sim.AnchorCPFPFee + sim.LocalSweepFee
+	for _, htlc := range sim.Htlcs {
+		require.Equal(t, htlcAmt.ToSatoshis(), htlc.Amount)
+
+		// The second level transactions don't pay fees themselves,
+		// so the sweeper has to add them.
+		require.Equal(
+			t, feeRate.FeeForWeight(htlc.SecondLevelWeight),
+			htlc.SecondLevelFee,
+		)
+
+		switch htlc.PaymentHash {
+		case outgoing.Hash():
+			require.False(t, htlc.Incoming)
+			require.True(t, htlc.Recoverable)
+			require.Equal(t, 200+csvDelay, htlc.MaturityHeight)
+			requireWeightEstimate(htlc.SecondLevelWeight, timeoutTx)
+
+		case known.Hash():
+			require.True(t, htlc.Incoming)
+			require.True(t, htlc.PreimageKnown)
+			require.True(t, htlc.Recoverable)
+			require.Equal(
+				t, currentHeight+1+csvDelay,
+				htlc.MaturityHeight,
+			)
+
+		case unknown.Hash():
+			require.True(t, htlc.Incoming)
+			require.False(t, htlc.Recoverable)
+			require.Zero(t, htlc.MaturityHeight)
+
+			continue
+
+		default:
+			t.Fatalf("unexpected htlc %v", htlc.PaymentHash)
+		}
+
+		expectedRecoverable += htlc.Amount
+		expectedBudget += htlc.SecondLevelFee + htlc.SweepFee
+	}
+
+	require.Equal(t, expectedRecoverable, sim.RecoverableAmount)
+	require.Equal(t, expectedBudget, sim.SweepBudget)
+	require.Equal(
+		t, expectedRecoverable-expectedBudget, sim.NetRecoverable,
+	)
+	require.Greater(t, sim.NetRecoverable, btcutil.Amount(0))
+}
+
+// TestSimulateForceCloseLease tests that the outputs of a leased channel we
+// initiated only mature once the lease expired.
+func TestSimulateForceCloseLease(t *testing.T) {
+	t.Parallel()
+
+	const (
+		currentHeight = 100
+		leaseExpiry   = 1000
+		feeRate       = chainfee.SatPerKWeight(50_000)
+	)
+
+	aliceChannel, bobChannel, err := lnwallet.CreateTestChannels(
+		t, channeldb.SingleFunderTweaklessBit|
+			channeldb.AnchorOutputsBit|channeldb.ZeroHtlcTxFeeBit|
+			channeldb.LeaseExpirationBit,
+	)
+	require.NoError(t, err)
+
+	// The lease expiry is part of the scripts of the next commitment.
+	aliceChannel.State().ThawHeight = leaseExpiry
+	bobChannel.State().ThawHeight = leaseExpiry
+
+	preimage := lntypes.Preimage{1}
+	htlc := &lnwire.UpdateAddHTLC{
+		PaymentHash: preimage.Hash(),
+		Amount:      lnwire.MilliSatoshi(500_000_000),
+		Expiry:      200,
+	}
+	_, err = aliceChannel.AddHTLC(htlc, nil)
+	require.NoError(t, err)
+	_, err = bobChannel.ReceiveHTLC(htlc)
+	require.NoError(t, err)
+
+	err = lnwallet.ForceStateTransition(aliceChannel, bobChannel)
+	require.NoError(t, err)
+
+	// Alice initiated the channel, so her outputs are locked until the
+	// lease expires.
+	sim, err := simulateForceClose(
+		aliceChannel.State(), feeRate, currentHeight,
+		newMockWitnessBeacon(),
+	)
+	require.NoError(t, err)
+	require.NotZero(t, sim.LocalAmount)
+	require.EqualValues(t, leaseExpiry, sim.LocalMaturityHeight)
+	require.Len(t, sim.Htlcs, 1)
+	require.EqualValues(t, leaseExpiry, sim.Htlcs[0].MaturityHeight)
+
+	// Bob's outputs only have the CSV delay.
+	sim, err = simulateForceClose(
+		bobChannel.State(), feeRate, currentHeight,
+		newMockWitnessBeacon(),
+	)
+	require.NoError(t, err)
+
+	csvDelay := uint32(bobChannel.State().LocalChanCfg.CsvDelay)
+	require.NotZero(t, sim.LocalAmount)
+	require.Equal(t, currentHeight+1+csvDelay, sim.LocalMaturityHeight)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/lnwallet"
 )
@@ -93,6 +94,23 @@ func (h *htlcOutgoingContestResolver) Resolve() (ContractResolver, error) {
 	default:
 	}
 
+	// If we're able to watch the mempool, we'll also look for the remote
+	// party's spend there, so we learn of the pre-image and can settle
+	// the incoming HTLC before the spend confirms.
+	var mempoolSpend <-chan *chainntnfs.SpendDetail
+	if h.Mempool != nil {
+		mempoolSpendEvent, err := h.Mempool.SubscribeMempoolSpent(
+			*outPointToWatch,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("register mempool spend: %w",
+				err)
+		}
+		defer h.Mempool.CancelMempoolSpendEvent(mempoolSpendEvent)
+
+		mempoolSpend = mempoolSpendEvent.Spend
+	}
+
 	// If we reach this point, then we can't fully act yet, so we'll await
 	// either of our signals triggering: the HTLC expires, or we learn of
 	// the preimage.
@@ -145,6 +163,36 @@ func (h *htlcOutgoingContestResolver) Resolve() (ContractResolver, error) {
 			// claimed.
 			return h.claimCleanUp(commitSpend)
 
+		// The output has been spent in the mempool. As the HTLC hasn't
+		// expired yet, this should be the remote party revealing the
+		// preimage, which we'll verify before cleaning up the contract.
+		//
+		// NOTE: like in the htlcTimeoutResolver, we don't wait for the
+		// spend to confirm, as the preimage is all we need.
+		case commitSpend, ok := <-mempoolSpend:
+			if !ok {
+				return nil, errResolverShuttingDown
+			}
+
+			hasPreimage := isPreimageSpend(
+				h.isTaproot(), commitSpend,
+				h.htlcResolution.SignedTimeoutTx != nil,
+			)
+			if !hasPreimage {
+				log.Debugf("%T(%v): mempool spend %v doesn't "+
+					"reveal preimage", h,
+					h.htlcResolution.ClaimOutpoint,
+					commitSpend.SpenderTxHash)
+
+				continue
+			}
+
+			log.Infof("%T(%v): found preimage spend %v in mempool",
+				h, h.htlcResolution.ClaimOutpoint,
+				commitSpend.SpenderTxHash)
+
+			return h.claimCleanUp(commitSpend)
+
 		case <-h.quit:
 			return nil, fmt.Errorf("resolver canceled")
 		}
@@ -3,6 +3,7 @@ package contractcourt
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/btcsuite/btcd/wire"
 	"github.com/lightningnetwork/lnd/chainntnfs"
@@ -109,6 +110,68 @@ func TestHtlcOutgoingResolverRemoteClaim(t *testing.T) {
 	ctx.waitForResult(false)
 }
 
+// TestHtlcOutgoingResolverMempoolClaim tests resolution of an offered htlc that
+// is claimed by the remote party, which we learn of from the mempool.
+func TestHtlcOutgoingResolverMempoolClaim(t *testing.T) {
+	t.Parallel()
+	defer timeout()()
+
+	// Setup the resolver with our test resolution and a mempool to watch,
+	// and start the resolution process.
+	ctx := newOutgoingResolverTestContext(t)
+	mempool := newMockMempool()
+	ctx.resolver.Mempool = mempool
+
+	ctx.resolve()
+
+	// A spend that doesn't reveal the preimage should be ignored.
+	spendTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{
+			{
+				Witness: [][]byte{{0}, {1}},
+			},
+		},
+	}
+	spendHash := spendTx.TxHash()
+	mempool.spendChan <- &chainntnfs.SpendDetail{
+		SpendingTx:    spendTx,
+		SpenderTxHash: &spendHash,
+	}
+
+	select {
+	case <-ctx.preimageDB.newPreimages:
+		t.Fatalf("unexpected preimage")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The remote party sweeps the htlc with the preimage, which shows up
+	// in the mempool first.
+	preimage := lntypes.Preimage{}
+	spendTx = &wire.MsgTx{
+		TxIn: []*wire.TxIn{
+			{
+				Witness: [][]byte{
+					{0}, {1}, {2}, preimage[:], {4},
+				},
+			},
+		},
+	}
+	spendHash = spendTx.TxHash()
+	mempool.spendChan <- &chainntnfs.SpendDetail{
+		SpendingTx:    spendTx,
+		SpenderTxHash: &spendHash,
+	}
+
+	// We expect the extracted preimage to be added to the witness beacon
+	// and a resolution message to the incoming side of the circuit without
+	// waiting for the spend to confirm.
+	<-ctx.preimageDB.newPreimages
+	<-ctx.resolutionChan
+
+	// Assert that the resolver finishes without error.
+	ctx.waitForResult(false)
+}
+
 type resolveResult struct {
 	err          error
 	nextResolver ContractResolver
@@ -260,7 +260,7 @@ func (h *htlcSuccessResolver) broadcastReSignedSuccessTx() (
 			)
 		}
 
-		_, err := h.Sweeper.SweepInput(
+		_, err := h.sweepInput(
 			&secondLevelInput,
 			sweep.Params{
 				Fee: sweep.FeePreference{
@@ -372,7 +372,7 @@ func (h *htlcSuccessResolver) broadcastReSignedSuccessTx() (
 		h.htlc.RHash,
 	)
 	// TODO(roasbeef): need to update above for leased types
-	_, err = h.Sweeper.SweepInput(
+	_, err = h.sweepInput(
 		inp,
 		sweep.Params{
 			Fee: sweep.FeePreference{
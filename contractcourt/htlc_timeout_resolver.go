@@ -483,7 +483,7 @@ func (h *htlcTimeoutResolver) sweepSecondLevelTx() error {
 			h.broadcastHeight,
 		))
 	}
-	_, err := h.Sweeper.SweepInput(
+	_, err := h.sweepInput(
 		inp,
 		sweep.Params{
 			Fee: sweep.FeePreference{
@@ -699,7 +699,7 @@ func (h *htlcTimeoutResolver) handleCommitSpend(
 			h.htlcResolution.CsvDelay, h.broadcastHeight,
 			h.htlc.RHash,
 		)
-		_, err = h.Sweeper.SweepInput(
+		_, err = h.sweepInput(
 			inp,
 			sweep.Params{
 				Fee: sweep.FeePreference{
@@ -0,0 +1,28 @@
+package contractcourt
+
+import (
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/chainntnfs"
+)
+
+type mockMempool struct {
+	spendChan chan *chainntnfs.SpendDetail
+}
+
+func newMockMempool() *mockMempool {
+	return &mockMempool{
+		spendChan: make(chan *chainntnfs.SpendDetail),
+	}
+}
+
+func (m *mockMempool) SubscribeMempoolSpent(
+	_ wire.OutPoint) (*chainntnfs.MempoolSpendEvent, error) {
+
+	return &chainntnfs.MempoolSpendEvent{
+		Spend: m.spendChan,
+	}, nil
+}
+
+func (m *mockMempool) CancelMempoolSpendEvent(
+	_ *chainntnfs.MempoolSpendEvent) { //nolint:whitespace
+}
@@ -0,0 +1,535 @@
+package contractcourt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/sweep"
+)
+
+var (
+	// ErrResolverNotFound is returned when re-evaluating a resolver that
+	// isn't active on the channel.
+	ErrResolverNotFound = errors.New("contract resolver not found")
+
+	// ErrResolverResolved is returned when re-evaluating a resolver that
+	// has already resolved its contract.
+	ErrResolverResolved = errors.New("contract resolver already resolved")
+)
+
+// ResolverType identifies the kind of contract a resolver is resolving.
+type ResolverType uint8
+
+const (
+	// ResolverTypeCommitSweep is the type of the commitSweepResolver,
+	// which sweeps our output on the commitment transaction.
+	ResolverTypeCommitSweep ResolverType = iota
+
+	// ResolverTypeHtlcTimeout is the type of the htlcTimeoutResolver,
+	// which times out an outgoing HTLC.
+	ResolverTypeHtlcTimeout
+
+	// ResolverTypeHtlcSuccess is the type of the htlcSuccessResolver,
+	// which claims an incoming HTLC we know the preimage of.
+	ResolverTypeHtlcSuccess
+
+	// ResolverTypeHtlcIncomingContest is the type of the
+	// htlcIncomingContestResolver, which waits for the preimage of an
+	// incoming HTLC until it expires.
+	ResolverTypeHtlcIncomingContest
+
+	// ResolverTypeHtlcOutgoingContest is the type of the
+	// htlcOutgoingContestResolver, which waits for an outgoing HTLC to
+	// either expire or be claimed by the remote party.
+	ResolverTypeHtlcOutgoingContest
+
+	// ResolverTypeAnchor is the type of the anchorResolver, which sweeps
+	// our anchor output.
+	ResolverTypeAnchor
+
+	// ResolverTypeBreach is the type of the breachResolver, which waits
+	// for the BreachArbitrator to resolve a breach.
+	ResolverTypeBreach
+)
+
+// String returns a human-readable name of the resolver type.
+func (r ResolverType) String() string {
+	switch r {
+	case ResolverTypeCommitSweep:
+		return "CommitSweep"
+
+	case ResolverTypeHtlcTimeout:
+		return "HtlcTimeout"
+
+	case ResolverTypeHtlcSuccess:
+		return "HtlcSuccess"
+
+	case ResolverTypeHtlcIncomingContest:
+		return "HtlcIncomingContest"
+
+	case ResolverTypeHtlcOutgoingContest:
+		return "HtlcOutgoingContest"
+
+	case ResolverTypeAnchor:
+		return "Anchor"
+
+	case ResolverTypeBreach:
+		return "Breach"
+
+	default:
+		return fmt.Sprintf("Unknown(%d)", uint8(r))
+	}
+}
+
+// SweepRequest is an input a resolver offered to the sweeper.
+type SweepRequest struct {
+	// Outpoint is the outpoint of the input.
+	Outpoint wire.OutPoint
+
+	// WitnessType is the witness type of the input.
+	WitnessType input.WitnessType
+
+	// Amount is the value of the input.
+	Amount btcutil.Amount
+
+	// Params are the sweep parameters the input was offered with.
+	Params sweep.Params
+
+	// Timestamp is the time at which the input was offered.
+	Timestamp time.Time
+}
+
+// sweepRequestRecord holds the last sweep request of a resolver. It is shared
+// by pointer, so a resolver and the contest resolver wrapping it record to the
+// same place.
+type sweepRequestRecord struct {
+	mtx sync.Mutex
+	req *SweepRequest
+}
+
+// set records the given sweep request.
+func (s *sweepRequestRecord) set(req *SweepRequest) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.req = req
+}
+
+// get returns a copy of the recorded sweep request, or nil if there is none.
+func (s *sweepRequestRecord) get() *SweepRequest {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.req == nil {
+		return nil
+	}
+
+	req := *s.req
+	return &req
+}
+
+// ResolverState describes an active resolver of a channel and what it is
+// waiting for.
+type ResolverState struct {
+	// Key is the resolver key identifying the resolver within the
+	// channel. It is empty for the anchor resolver, which isn't persisted.
+	Key []byte
+
+	// Type is the type of the resolver.
+	Type ResolverType
+
+	// Resolved is true if the resolver checkpointed its contract as fully
+	// resolved.
+	Resolved bool
+
+	// Stage is the checkpointed stage of an HTLC resolver. It is 1 while
+	// the HTLC output on the commitment is unresolved, and 2 once the
+	// output of the second level transaction is being swept. It is zero
+	// for all other resolvers.
+	Stage uint32
+
+	// Outpoint is the outpoint the resolver is currently watching.
+	Outpoint wire.OutPoint
+
+	// Amount is the value of the contract.
+	Amount btcutil.Amount
+
+	// NextActionHeight is the height at which the resolver can take its
+	// next step, like the expiry of an HTLC or the maturity of a CSV
+	// delayed output. It is zero if the resolver isn't waiting for a
+	// height.
+	NextActionHeight uint32
+
+	// SweepRequest is the last input the resolver offered to the sweeper,
+	// or nil if it hasn't offered any yet.
+	SweepRequest *SweepRequest
+}
+
+// resolverTypeOf returns the type of the given resolver.
+func resolverTypeOf(resolver ContractResolver) (ResolverType, error) {
+	switch resolver.(type) {
+	case *commitSweepResolver:
+		return ResolverTypeCommitSweep, nil
+
+	case *htlcTimeoutResolver:
+		return ResolverTypeHtlcTimeout, nil
+
+	case *htlcSuccessResolver:
+		return ResolverTypeHtlcSuccess, nil
+
+	case *htlcIncomingContestResolver:
+		return ResolverTypeHtlcIncomingContest, nil
+
+	case *htlcOutgoingContestResolver:
+		return ResolverTypeHtlcOutgoingContest, nil
+
+	case *anchorResolver:
+		return ResolverTypeAnchor, nil
+
+	case *breachResolver:
+		return ResolverTypeBreach, nil
+
+	default:
+		return 0, fmt.Errorf("unknown resolver type %T", resolver)
+	}
+}
+
+// describeResolver returns the state of an active resolver. The checkpointed
+// state is read from the persisted resolver as decoded from the log, which is
+// nil if the contract is no longer unresolved. The live resolver only provides
+// its lock protected report and sweep request.
+func describeResolver(live, persisted ContractResolver) (*ResolverState,
+	error) {
+
+	resolverType, err := resolverTypeOf(live)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &ResolverState{
+		Key:  live.ResolverKey(),
+		Type: resolverType,
+	}
+
+	// The anchor resolver isn't persisted, but its outpoint never
+	// changes. It is resolved once nothing is left in limbo.
+	if anchor, ok := live.(*anchorResolver); ok {
+		report := anchor.report()
+
+		state.Resolved = report.LimboBalance == 0
+		state.Outpoint = anchor.anchor
+		state.Amount = report.Amount
+		state.SweepRequest = anchor.lastSweepRequest()
+
+		return state, nil
+	}
+
+	switch r := persisted.(type) {
+	// The contract is no longer unresolved.
+	case nil:
+		state.Resolved = true
+
+	case *commitSweepResolver:
+		state.Resolved = r.resolved
+		state.Outpoint = r.commitResolution.SelfOutPoint
+		state.Amount = btcutil.Amount(
+			r.commitResolution.SelfOutputSignDesc.Output.Value,
+		)
+
+	case *htlcTimeoutResolver:
+		describeTimeoutResolver(state, r)
+
+	case *htlcOutgoingContestResolver:
+		describeTimeoutResolver(state, r.htlcTimeoutResolver)
+
+	case *htlcSuccessResolver:
+		describeSuccessResolver(state, r)
+
+	case *htlcIncomingContestResolver:
+		describeSuccessResolver(state, r.htlcSuccessResolver)
+		state.NextActionHeight = r.htlcExpiry
+
+	case *breachResolver:
+		state.Resolved = r.resolved
+		state.Outpoint = r.ChanPoint
+	}
+
+	// The report of the live resolver is more accurate regarding the
+	// amount and the maturity of the contract, if it has one. A report
+	// lagging behind the checkpointed stage is ignored.
+	if reporter, ok := live.(reportingContractResolver); ok {
+		report := reporter.report()
+		if report != nil &&
+			(state.Stage == 0 || report.Stage == state.Stage) {
+
+			state.Amount = report.Amount
+			state.NextActionHeight = report.MaturityHeight
+		}
+	}
+
+	if requester, ok := live.(interface {
+		lastSweepRequest() *SweepRequest
+	}); ok {
+
+		state.SweepRequest = requester.lastSweepRequest()
+	}
+
+	return state, nil
+}
+
+// describeTimeoutResolver fills in the checkpointed state of an HTLC timeout
+// resolver.
+func describeTimeoutResolver(state *ResolverState, r *htlcTimeoutResolver) {
+	state.Resolved = r.resolved
+	state.Stage = 1
+	state.Outpoint = r.HtlcPoint()
+	state.Amount = btcutil.Amount(
+		r.htlcResolution.SweepSignDesc.Output.Value,
+	)
+	state.NextActionHeight = r.htlcResolution.Expiry
+
+	if r.outputIncubating && r.htlcResolution.SignedTimeoutTx != nil {
+		state.Stage = 2
+		state.Outpoint = r.htlcResolution.ClaimOutpoint
+		state.NextActionHeight = 0
+	}
+}
+
+// describeSuccessResolver fills in the checkpointed state of an HTLC success
+// resolver.
+func describeSuccessResolver(state *ResolverState, r *htlcSuccessResolver) {
+	state.Resolved = r.resolved
+	state.Stage = 1
+	state.Outpoint = r.HtlcPoint()
+	state.Amount = btcutil.Amount(
+		r.htlcResolution.SweepSignDesc.Output.Value,
+	)
+
+	if r.outputIncubating && r.htlcResolution.SignedSuccessTx != nil {
+		state.Stage = 2
+		state.Outpoint = r.htlcResolution.ClaimOutpoint
+	}
+}
+
+// ResolverStates returns the state of all active resolvers of the channel.
+func (c *ChannelArbitrator) ResolverStates() ([]*ResolverState, error) {
+	c.activeResolversLock.RLock()
+	resolvers := make([]ContractResolver, len(c.activeResolvers))
+	copy(resolvers, c.activeResolvers)
+	c.activeResolversLock.RUnlock()
+
+	if len(resolvers) == 0 {
+		return nil, nil
+	}
+
+	// Decode the checkpointed state of the unresolved contracts from the
+	// log, so we don't race with the resolvers updating their own state.
+	unresolved, err := c.log.FetchUnresolvedContracts()
+	if err != nil {
+		return nil, err
+	}
+	persisted := make(map[string]ContractResolver, len(unresolved))
+	for _, resolver := range unresolved {
+		persisted[string(resolver.ResolverKey())] = resolver
+	}
+
+	states := make([]*ResolverState, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		state, err := describeResolver(
+			resolver, persisted[string(resolver.ResolverKey())],
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// stopResolver stops the given resolver unless it has been stopped already.
+//
+// NOTE: The caller must hold reevaluateMtx.
+func stopResolver(resolver ContractResolver) {
+	stopper, ok := resolver.(interface{ isStopped() bool })
+	if ok && stopper.isStopped() {
+		return
+	}
+
+	resolver.Stop()
+}
+
+// ReevaluateResolver stops the active resolver with the given key, reloads it
+// from its last checkpoint in the log and launches it again. This makes the
+// resolver re-evaluate its contract, for example to offer its input to the
+// sweeper again. The anchor resolver is identified by an empty key.
+func (c *ChannelArbitrator) ReevaluateResolver(key []byte) error {
+	c.reevaluateMtx.Lock()
+	defer c.reevaluateMtx.Unlock()
+
+	if atomic.LoadInt32(&c.stopped) == 1 {
+		return errors.New("channel arbitrator is shutting down")
+	}
+
+	var oldResolver ContractResolver
+	c.activeResolversLock.RLock()
+	for _, resolver := range c.activeResolvers {
+		if bytes.Equal(resolver.ResolverKey(), key) {
+			oldResolver = resolver
+			break
+		}
+	}
+	done := c.resolverDone[string(key)]
+	c.activeResolversLock.RUnlock()
+
+	if oldResolver == nil {
+		return ErrResolverNotFound
+	}
+
+	// The anchor resolver isn't persisted, so it is re-instantiated with
+	// the height it was originally launched with.
+	var heightHint uint32
+	if anchor, ok := oldResolver.(*anchorResolver); ok {
+		heightHint = anchor.broadcastHeight
+	}
+
+	state, err := c.describeActiveResolver(oldResolver)
+	if err != nil {
+		return err
+	}
+	if state.Resolved {
+		return ErrResolverResolved
+	}
+
+	commitSet, err := c.log.FetchConfirmedCommitSet(nil)
+	if err != nil && err != errNoCommitSet && err != errScopeBucketNoExist {
+		return err
+	}
+
+	log.Infof("ChannelArbitrator(%v): re-evaluating %T", c.cfg.ChanPoint,
+		oldResolver)
+
+	// Stop the resolver and wait for its goroutine to exit, so it doesn't
+	// checkpoint any state while we reload it.
+	stopResolver(oldResolver)
+	if done != nil {
+		select {
+		case <-done:
+		case <-c.quit:
+			return errors.New("channel arbitrator is shutting down")
+		}
+	}
+
+	resolvers, err := c.loadResolvers(commitSet, heightHint)
+	if err != nil {
+		return err
+	}
+
+	var newResolver ContractResolver
+	for _, resolver := range resolvers {
+		if bytes.Equal(resolver.ResolverKey(), key) {
+			newResolver = resolver
+			break
+		}
+	}
+
+	// The contract may have been resolved right before we stopped the
+	// resolver, in which case there's nothing left to do.
+	if newResolver == nil {
+		log.Infof("ChannelArbitrator(%v): %T resolved before being "+
+			"re-evaluated", c.cfg.ChanPoint, oldResolver)
+
+		return nil
+	}
+
+	c.activeResolversLock.Lock()
+	defer c.activeResolversLock.Unlock()
+
+	for i, resolver := range c.activeResolvers {
+		if !bytes.Equal(resolver.ResolverKey(), key) {
+			continue
+		}
+
+		c.activeResolvers[i] = newResolver
+
+		done := make(chan struct{})
+		c.resolverDone[string(key)] = done
+
+		c.wg.Add(1)
+		go c.resolveContract(newResolver, done)
+
+		return nil
+	}
+
+	return ErrResolverNotFound
+}
+
+// describeActiveResolver returns the state of a single active resolver.
+func (c *ChannelArbitrator) describeActiveResolver(
+	resolver ContractResolver) (*ResolverState, error) {
+
+	unresolved, err := c.log.FetchUnresolvedContracts()
+	if err != nil {
+		return nil, err
+	}
+
+	var persisted ContractResolver
+	for _, r := range unresolved {
+		if bytes.Equal(r.ResolverKey(), resolver.ResolverKey()) {
+			persisted = r
+			break
+		}
+	}
+
+	return describeResolver(resolver, persisted)
+}
+
+// ResolverStates returns the state of the active resolvers of all channels
+// that have any, keyed by channel point.
+func (c *ChainArbitrator) ResolverStates() (
+	map[wire.OutPoint][]*ResolverState, error) {
+
+	c.Lock()
+	arbitrators := make([]*ChannelArbitrator, 0, len(c.activeChannels))
+	for _, arbitrator := range c.activeChannels {
+		arbitrators = append(arbitrators, arbitrator)
+	}
+	c.Unlock()
+
+	states := make(map[wire.OutPoint][]*ResolverState)
+	for _, arbitrator := range arbitrators {
+		chanStates, err := arbitrator.ResolverStates()
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch resolver "+
+				"states of %v: %w", arbitrator.cfg.ChanPoint,
+				err)
+		}
+
+		if len(chanStates) > 0 {
+			states[arbitrator.cfg.ChanPoint] = chanStates
+		}
+	}
+
+	return states, nil
+}
+
+// ReevaluateResolver makes the active resolver with the given key of the given
+// channel re-evaluate its contract.
+func (c *ChainArbitrator) ReevaluateResolver(chanPoint wire.OutPoint,
+	key []byte) error {
+
+	arbitrator, err := c.GetChannelArbitrator(chanPoint)
+	if err != nil {
+		return err
+	}
+
+	return arbitrator.ReevaluateResolver(key)
+}
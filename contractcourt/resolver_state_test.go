@@ -0,0 +1,112 @@
+package contractcourt
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/sweep"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDescribeResolver asserts that the state of a resolver is derived from
+// its checkpoint in the log.
+func TestDescribeResolver(t *testing.T) {
+	t.Parallel()
+
+	htlcPoint := wire.OutPoint{Index: 1}
+	claimPoint := wire.OutPoint{Index: 2}
+
+	timeoutTx := wire.NewMsgTx(2)
+	timeoutTx.AddTxIn(&wire.TxIn{PreviousOutPoint: htlcPoint})
+	timeoutTx.AddTxOut(&wire.TxOut{Value: 1000})
+
+	resolution := lnwallet.OutgoingHtlcResolution{
+		Expiry:          500,
+		SignedTimeoutTx: timeoutTx,
+		ClaimOutpoint:   claimPoint,
+		SignDetails:     &input.SignDetails{},
+		SweepSignDesc: input.SignDescriptor{
+			Output: &wire.TxOut{Value: 1000},
+		},
+	}
+
+	newResolver := func() *htlcOutgoingContestResolver {
+		return newOutgoingContestResolver(
+			resolution, 100, channeldb.HTLC{}, ResolverConfig{},
+		)
+	}
+	live := newResolver()
+
+	// While the HTLC output on the commitment is unresolved, the resolver
+	// waits for the HTLC to expire.
+	persisted := newResolver()
+	state, err := describeResolver(live, persisted)
+	require.NoError(t, err)
+	require.Equal(t, ResolverTypeHtlcOutgoingContest, state.Type)
+	require.False(t, state.Resolved)
+	require.EqualValues(t, 1, state.Stage)
+	require.Equal(t, htlcPoint, state.Outpoint)
+	require.Equal(t, btcutil.Amount(1000), state.Amount)
+	require.EqualValues(t, 500, state.NextActionHeight)
+	require.Nil(t, state.SweepRequest)
+
+	// Once the timeout transaction confirmed, the output of it is watched.
+	persisted.outputIncubating = true
+	state, err = describeResolver(live, persisted)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, state.Stage)
+	require.Equal(t, claimPoint, state.Outpoint)
+	require.Zero(t, state.NextActionHeight)
+
+	// The live resolver reports the maturity of the CSV delayed output
+	// once it reached the second stage as well.
+	live.htlcTimeoutResolver.currentReport.Stage = 2
+	live.htlcTimeoutResolver.currentReport.MaturityHeight = 644
+	state, err = describeResolver(live.htlcTimeoutResolver, persisted)
+	require.NoError(t, err)
+	require.EqualValues(t, 644, state.NextActionHeight)
+
+	// A resolver that's no longer in the log is resolved.
+	state, err = describeResolver(live, nil)
+	require.NoError(t, err)
+	require.True(t, state.Resolved)
+}
+
+// TestSweepInputRecordsRequest asserts that the last input a resolver offered
+// to the sweeper is recorded.
+func TestSweepInputRecordsRequest(t *testing.T) {
+	t.Parallel()
+
+	kit := newContractResolverKit(ResolverConfig{
+		ChannelArbitratorConfig: ChannelArbitratorConfig{
+			ChainArbitratorConfig: ChainArbitratorConfig{
+				Sweeper: newMockSweeper(),
+			},
+		},
+	})
+	require.Nil(t, kit.lastSweepRequest())
+
+	outpoint := wire.OutPoint{Index: 3}
+	inp := input.MakeBaseInput(
+		&outpoint, input.CommitmentTimeLock, &input.SignDescriptor{
+			Output: &wire.TxOut{Value: 2000},
+		}, 0, nil,
+	)
+	params := sweep.Params{
+		Fee: sweep.FeePreference{ConfTarget: 6},
+	}
+
+	_, err := kit.sweepInput(&inp, params)
+	require.NoError(t, err)
+
+	req := kit.lastSweepRequest()
+	require.NotNil(t, req)
+	require.Equal(t, outpoint, req.Outpoint)
+	require.Equal(t, input.CommitmentTimeLock, req.WitnessType)
+	require.Equal(t, btcutil.Amount(2000), req.Amount)
+	require.Equal(t, params, req.Params)
+}
@@ -0,0 +1,306 @@
+package dbcompact
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/lightningnetwork/lnd/dbmigrate"
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+const (
+	// DefaultMaxPause is the default maximum time we wait for the
+	// transactions in flight to finish when pausing the database.
+	DefaultMaxPause = 10 * time.Second
+)
+
+// Config holds the configuration of a bolt database that can be compacted
+// while it is in use.
+type Config struct {
+	// DBPath is the directory of the database file.
+	DBPath string
+
+	// DBFileName is the file name of the database.
+	DBFileName string
+
+	// NoFreelistSync is the freelist sync option the database is opened
+	// with.
+	NoFreelistSync bool
+
+	// DBTimeout is the timeout for obtaining the file lock of the
+	// database.
+	DBTimeout time.Duration
+
+	// MaxPause is the maximum time we wait for the transactions in flight
+	// to finish before the compacted database is swapped in. If they
+	// don't finish in time, the compaction is aborted.
+	MaxPause time.Duration
+
+	// Copy configures the batches in which the database is copied.
+	Copy *dbmigrate.Config
+}
+
+// Backend is a kvdb.Backend for a bolt database that can be compacted while
+// lnd is running. All transactions are passed through to the underlying
+// database. While a compaction is in progress, all modifications are recorded
+// so they can be applied to the compacted copy before it replaces the
+// original database.
+type Backend struct {
+	cfg *Config
+
+	gate txGate
+
+	// mu protects db and tracker, which are replaced while the gate is
+	// paused.
+	mu      sync.RWMutex
+	db      kvdb.Backend
+	tracker *changeTracker
+
+	// compactMtx makes sure only a single compaction runs at a time.
+	compactMtx sync.Mutex
+}
+
+// A compile-time check to ensure Backend implements the kvdb.Backend and
+// walletdb.BatchDB interfaces.
+var _ kvdb.Backend = (*Backend)(nil)
+var _ walletdb.BatchDB = (*Backend)(nil)
+
+// NewBackend wraps an opened bolt database, so it can be compacted while it is
+// in use.
+func NewBackend(db kvdb.Backend, cfg *Config) *Backend {
+	return &Backend{
+		cfg: cfg,
+		db:  db,
+	}
+}
+
+// current returns the underlying database and the change tracker of the
+// compaction in progress, if any.
+func (b *Backend) current() (kvdb.Backend, *changeTracker) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.db, b.tracker
+}
+
+// BeginReadTx opens a database read transaction.
+func (b *Backend) BeginReadTx() (walletdb.ReadTx, error) {
+	b.gate.enter(false)
+
+	db, _ := b.current()
+	tx, err := db.BeginReadTx()
+	if err != nil {
+		b.gate.exit(false)
+		return nil, err
+	}
+
+	return &readTx{
+		ReadTx: tx,
+		gate:   &b.gate,
+	}, nil
+}
+
+// BeginReadWriteTx opens a database read+write transaction.
+func (b *Backend) BeginReadWriteTx() (walletdb.ReadWriteTx, error) {
+	b.gate.enter(true)
+
+	db, tracker := b.current()
+	tx, err := db.BeginReadWriteTx()
+	if err != nil {
+		b.gate.exit(true)
+		return nil, err
+	}
+
+	rwTx := &readWriteTx{
+		ReadWriteTx: tx,
+		gate:        &b.gate,
+	}
+	if tracker != nil {
+		rwTx.tracker = tracker
+		rwTx.changes = make(changeSet)
+		rwTx.ReadWriteTx = &trackingTx{
+			ReadWriteTx: tx,
+			changes:     rwTx.changes,
+		}
+	}
+
+	return rwTx, nil
+}
+
+// Copy writes a copy of the database to the provided writer.
+func (b *Backend) Copy(w io.Writer) error {
+	b.gate.enter(false)
+	defer b.gate.exit(false)
+
+	db, _ := b.current()
+
+	return db.Copy(w)
+}
+
+// Close cleanly shuts down the database and syncs all data.
+func (b *Backend) Close() error {
+	db, _ := b.current()
+
+	return db.Close()
+}
+
+// PrintStats returns all collected stats pretty printed into a string.
+func (b *Backend) PrintStats() string {
+	db, _ := b.current()
+
+	return db.PrintStats()
+}
+
+// View opens a database read transaction and executes the function f with the
+// transaction passed as a parameter.
+func (b *Backend) View(f func(tx walletdb.ReadTx) error, reset func()) error {
+	b.gate.enter(false)
+	defer b.gate.exit(false)
+
+	db, _ := b.current()
+
+	return db.View(f, reset)
+}
+
+// Update opens a database read/write transaction and executes the function f
+// with the transaction passed as a parameter.
+func (b *Backend) Update(f func(tx walletdb.ReadWriteTx) error,
+	reset func()) error {
+
+	b.gate.enter(true)
+	defer b.gate.exit(true)
+
+	db, tracker := b.current()
+
+	return b.updateLocked(db, tracker, f, reset)
+}
+
+// Batch is similar to Update, but it allows the underlying database to combine
+// the transactions of multiple concurrent callers.
+func (b *Backend) Batch(f func(tx walletdb.ReadWriteTx) error) error {
+	b.gate.enter(true)
+	defer b.gate.exit(true)
+
+	db, tracker := b.current()
+	batchDB, ok := db.(walletdb.BatchDB)
+	if !ok {
+		return b.updateLocked(db, tracker, f, func() {})
+	}
+
+	if tracker == nil {
+		return batchDB.Batch(f)
+	}
+
+	// The function might be retried in a transaction of its own, so we
+	// collect the changes of all attempts.
+	var (
+		mu      sync.Mutex
+		changes = make(changeSet)
+	)
+	err := batchDB.Batch(func(tx walletdb.ReadWriteTx) error {
+		attemptChanges := make(changeSet)
+		err := f(&trackingTx{
+			ReadWriteTx: tx,
+			changes:     attemptChanges,
+		})
+
+		mu.Lock()
+		for id, item := range attemptChanges {
+			changes[id] = item
+		}
+		mu.Unlock()
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	tracker.merge(changes)
+
+	return nil
+}
+
+// updateLocked runs an update on the given database. The caller must have
+// already entered the gate.
+func (b *Backend) updateLocked(db kvdb.Backend, tracker *changeTracker,
+	f func(tx walletdb.ReadWriteTx) error, reset func()) error {
+
+	if tracker == nil {
+		return db.Update(f, reset)
+	}
+
+	var changes changeSet
+	err := db.Update(func(tx walletdb.ReadWriteTx) error {
+		changes = make(changeSet)
+
+		return f(&trackingTx{
+			ReadWriteTx: tx,
+			changes:     changes,
+		})
+	}, reset)
+	if err != nil {
+		return err
+	}
+
+	tracker.merge(changes)
+
+	return nil
+}
+
+// readTx is a read transaction that leaves the gate once it is closed.
+type readTx struct {
+	walletdb.ReadTx
+
+	gate *txGate
+	once sync.Once
+}
+
+// Rollback closes the transaction.
+func (tx *readTx) Rollback() error {
+	err := tx.ReadTx.Rollback()
+	tx.once.Do(func() {
+		tx.gate.exit(false)
+	})
+
+	return err
+}
+
+// readWriteTx is a read-write transaction that leaves the gate once it is
+// committed or rolled back, and hands its changes to the tracker of a running
+// compaction on commit.
+type readWriteTx struct {
+	walletdb.ReadWriteTx
+
+	gate *txGate
+	once sync.Once
+
+	tracker *changeTracker
+	changes changeSet
+}
+
+// Commit commits all changes of the transaction.
+func (tx *readWriteTx) Commit() error {
+	err := tx.ReadWriteTx.Commit()
+	if err == nil && tx.tracker != nil {
+		tx.tracker.merge(tx.changes)
+	}
+
+	tx.once.Do(func() {
+		tx.gate.exit(true)
+	})
+
+	return err
+}
+
+// Rollback closes the transaction, discarding all changes.
+func (tx *readWriteTx) Rollback() error {
+	err := tx.ReadWriteTx.Rollback()
+	tx.once.Do(func() {
+		tx.gate.exit(true)
+	})
+
+	return err
+}
@@ -0,0 +1,473 @@
+package dbcompact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/lightningnetwork/lnd/dbmigrate"
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+const (
+	// compactTempSuffix is the suffix of the file the compacted copy of
+	// the database is written to.
+	compactTempSuffix = ".compact"
+
+	// compactOldSuffix is the suffix the original database file is renamed
+	// to while the compacted copy is swapped in.
+	compactOldSuffix = ".precompact"
+
+	// maxCatchUpRounds is the maximum number of times the changes made
+	// during the compaction are applied to the copy before the writers are
+	// paused.
+	maxCatchUpRounds = 10
+
+	// pauseThreshold is the number of changes below which we stop
+	// catching up and pause the writers to apply the remaining changes.
+	pauseThreshold = 1000
+)
+
+// ErrCompactionInProgress is returned if a compaction is requested while
+// another one is still running.
+var ErrCompactionInProgress = errors.New("compaction already in progress")
+
+// Result describes a finished compaction.
+type Result struct {
+	// SizeBefore is the size of the database file before the compaction.
+	SizeBefore int64
+
+	// SizeAfter is the size of the database file after the compaction.
+	SizeAfter int64
+
+	// Duration is the total time the compaction took.
+	Duration time.Duration
+
+	// PauseDuration is the time all database transactions were held back
+	// while the compacted copy was swapped in.
+	PauseDuration time.Duration
+}
+
+// fileSize returns the size of the file at the given path.
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size(), nil
+}
+
+// Compact creates a compacted copy of the database while it is in use and
+// swaps it in for the original database file.
+//
+// The database is first copied from a consistent snapshot while all writes go
+// to the original database as usual. The writes made in the meantime are
+// recorded and applied to the copy in a few catch-up rounds. Once only a few
+// changes remain, all transactions are held back, the remaining changes are
+// applied and the compacted copy replaces the original file. If the
+// transactions in flight don't finish within the configured maximum pause,
+// the compaction is aborted and the original database stays in place.
+func (b *Backend) Compact(ctx context.Context) (*Result, error) {
+	if !b.compactMtx.TryLock() {
+		return nil, ErrCompactionInProgress
+	}
+	defer b.compactMtx.Unlock()
+
+	start := time.Now()
+	dbPath := filepath.Join(b.cfg.DBPath, b.cfg.DBFileName)
+	tempPath := dbPath + compactTempSuffix
+
+	sizeBefore, err := fileSize(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove any leftovers of an earlier attempt that was interrupted.
+	if err := os.Remove(tempPath); err != nil &&
+		!errors.Is(err, os.ErrNotExist) {
+
+		return nil, err
+	}
+
+	dst, err := kvdb.Create(
+		kvdb.BoltBackendName, tempPath, b.cfg.NoFreelistSync,
+		b.cfg.DBTimeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %v: %w", tempPath, err)
+	}
+
+	swapped := false
+	defer func() {
+		if swapped {
+			return
+		}
+
+		if err := dst.Close(); err != nil {
+			log.Errorf("Unable to close %v: %v", tempPath, err)
+		}
+		if err := os.Remove(tempPath); err != nil {
+			log.Errorf("Unable to remove %v: %v", tempPath, err)
+		}
+	}()
+
+	log.Infof("Starting online compaction of %v (%d bytes)", dbPath,
+		sizeBefore)
+
+	if err := b.startTracking(); err != nil {
+		return nil, err
+	}
+	defer b.stopTracking()
+
+	src, tracker := b.current()
+
+	numItems, err := dbmigrate.Copy(ctx, src, dst, b.cfg.Copy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy database: %w", err)
+	}
+	if err := dbmigrate.ClearProgress(dst); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Copied %d items of %v in %v, catching up with new changes",
+		numItems, dbPath, time.Since(start).Round(time.Millisecond))
+
+	// Apply the changes that were made in the meantime, as long as there
+	// are too many of them to do so while the writers are paused.
+	for i := 0; i < maxCatchUpRounds; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		changes := tracker.take()
+		if err := applyChanges(src, dst, changes); err != nil {
+			return nil, err
+		}
+
+		log.Debugf("Applied %d changes to the compacted copy of %v",
+			len(changes), dbPath)
+
+		if len(changes) < pauseThreshold {
+			break
+		}
+	}
+
+	pauseStart := time.Now()
+	if err := b.gate.pause(b.cfg.MaxPause, true); err != nil {
+		return nil, err
+	}
+	defer b.gate.resume()
+
+	// No transaction is in flight anymore, so after applying the last
+	// changes the copy has exactly the same content as the original.
+	if err := applyChanges(src, dst, tracker.take()); err != nil {
+		return nil, err
+	}
+
+	if err := b.swap(dst, dbPath, tempPath); err != nil {
+		return nil, err
+	}
+	swapped = true
+
+	pauseDuration := time.Since(pauseStart)
+
+	sizeAfter, err := fileSize(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		SizeBefore:    sizeBefore,
+		SizeAfter:     sizeAfter,
+		Duration:      time.Since(start),
+		PauseDuration: pauseDuration,
+	}
+
+	log.Infof("Online compaction of %v finished in %v, size went from "+
+		"%d to %d bytes, database was paused for %v", dbPath,
+		result.Duration.Round(time.Millisecond), sizeBefore, sizeAfter,
+		pauseDuration.Round(time.Millisecond))
+
+	return result, nil
+}
+
+// startTracking starts recording all changes to the database. To make sure
+// no change is missed, we wait for all writers in flight to finish first.
+func (b *Backend) startTracking() error {
+	if err := b.gate.pause(b.cfg.MaxPause, false); err != nil {
+		return err
+	}
+	defer b.gate.resume()
+
+	b.mu.Lock()
+	b.tracker = newChangeTracker()
+	b.mu.Unlock()
+
+	return nil
+}
+
+// stopTracking stops recording changes to the database.
+func (b *Backend) stopTracking() {
+	b.mu.Lock()
+	b.tracker = nil
+	b.mu.Unlock()
+}
+
+// swap replaces the original database file with the compacted copy and opens
+// it. This must only be called while the gate is paused and no transactions
+// are in flight. If the compacted copy can't be opened, the original database
+// is restored.
+func (b *Backend) swap(dst kvdb.Backend, dbPath, tempPath string) error {
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("unable to close compacted copy: %w", err)
+	}
+
+	oldPath := dbPath + compactOldSuffix
+
+	// reopen opens the database file at its original location and makes
+	// it the one all transactions are passed to.
+	reopen := func() error {
+		db, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+			DBPath:         b.cfg.DBPath,
+			DBFileName:     b.cfg.DBFileName,
+			NoFreelistSync: b.cfg.NoFreelistSync,
+			DBTimeout:      b.cfg.DBTimeout,
+		})
+		if err != nil {
+			return err
+		}
+
+		b.mu.Lock()
+		b.db = db
+		b.mu.Unlock()
+
+		return nil
+	}
+
+	b.mu.RLock()
+	oldDB := b.db
+	b.mu.RUnlock()
+
+	if err := oldDB.Close(); err != nil {
+		return fmt.Errorf("unable to close database: %w", err)
+	}
+
+	if err := os.Rename(dbPath, oldPath); err != nil {
+		if reopenErr := reopen(); reopenErr != nil {
+			log.Criticalf("Unable to reopen %v: %v", dbPath,
+				reopenErr)
+		}
+
+		return err
+	}
+
+	if err := os.Rename(tempPath, dbPath); err != nil {
+		return b.restore(oldPath, dbPath, reopen, err)
+	}
+
+	if err := reopen(); err != nil {
+		return b.restore(oldPath, dbPath, reopen, err)
+	}
+
+	if err := os.Remove(oldPath); err != nil {
+		log.Errorf("Unable to remove %v: %v", oldPath, err)
+	}
+
+	return nil
+}
+
+// restore moves the original database file back in place after swapping in
+// the compacted copy failed, and reopens it.
+func (b *Backend) restore(oldPath, dbPath string, reopen func() error,
+	swapErr error) error {
+
+	log.Errorf("Unable to swap in compacted copy of %v, restoring "+
+		"original database: %v", dbPath, swapErr)
+
+	if err := os.Rename(oldPath, dbPath); err != nil {
+		log.Criticalf("Unable to restore %v from %v: %v", dbPath,
+			oldPath, err)
+
+		return err
+	}
+
+	if err := reopen(); err != nil {
+		log.Criticalf("Unable to reopen %v: %v", dbPath, err)
+
+		return err
+	}
+
+	return swapErr
+}
+
+// readBucketAt returns the bucket with the given path, or nil if it doesn't
+// exist.
+func readBucketAt(tx kvdb.RTx, path [][]byte) walletdb.ReadBucket {
+	bucket := tx.ReadBucket(path[0])
+	for i := 1; i < len(path) && bucket != nil; i++ {
+		bucket = bucket.NestedReadBucket(path[i])
+	}
+
+	return bucket
+}
+
+// writeBucketAt returns the bucket with the given path, or nil if it doesn't
+// exist.
+func writeBucketAt(tx kvdb.RwTx, path [][]byte) walletdb.ReadWriteBucket {
+	bucket := tx.ReadWriteBucket(path[0])
+	for i := 1; i < len(path) && bucket != nil; i++ {
+		bucket = bucket.NestedReadWriteBucket(path[i])
+	}
+
+	return bucket
+}
+
+// sequencer is implemented by all buckets that expose their sequence number.
+type sequencer interface {
+	Sequence() uint64
+}
+
+// copyBucket copies the sequence number and the whole content of a bucket.
+func copyBucket(src walletdb.ReadBucket, dst walletdb.ReadWriteBucket) error {
+	if s, ok := src.(sequencer); ok {
+		if err := dst.SetSequence(s.Sequence()); err != nil {
+			return err
+		}
+	}
+
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			if nested := src.NestedReadBucket(k); nested != nil {
+				dstNested, err := dst.CreateBucket(k)
+				if err != nil {
+					return err
+				}
+
+				return copyBucket(nested, dstNested)
+			}
+		}
+
+		if v == nil {
+			v = []byte{}
+		}
+
+		return dst.Put(k, v)
+	})
+}
+
+// applyChanges copies the current state of all changed items from src to dst.
+// Items are applied parents first, so a bucket that was created during the
+// compaction is copied as a whole before any of the items in it.
+func applyChanges(src, dst kvdb.Backend, changes changeSet) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	items := changes.sorted()
+
+	return kvdb.View(src, func(srcTx kvdb.RTx) error {
+		return kvdb.Update(dst, func(dstTx kvdb.RwTx) error {
+			for _, item := range items {
+				err := applyChange(srcTx, dstTx, item)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}, func() {})
+	}, func() {})
+}
+
+// applyChange copies the current state of a single changed item from srcTx to
+// dstTx.
+func applyChange(srcTx kvdb.RTx, dstTx kvdb.RwTx, item changedItem) error {
+	if item.sequence {
+		srcBucket := readBucketAt(srcTx, item.path)
+		dstBucket := writeBucketAt(dstTx, item.path)
+		if srcBucket == nil || dstBucket == nil {
+			// The bucket was deleted again, or it was created
+			// during the compaction and copied with its sequence.
+			return nil
+		}
+
+		s, ok := srcBucket.(sequencer)
+		if !ok {
+			return nil
+		}
+
+		return dstBucket.SetSequence(s.Sequence())
+	}
+
+	key := item.path[len(item.path)-1]
+	parentPath := item.path[:len(item.path)-1]
+
+	// Top level buckets are handled by the transactions themselves.
+	if len(parentPath) == 0 {
+		if dstTx.ReadWriteBucket(key) != nil {
+			if err := dstTx.DeleteTopLevelBucket(key); err != nil {
+				return err
+			}
+		}
+
+		srcBucket := srcTx.ReadBucket(key)
+		if srcBucket == nil {
+			return nil
+		}
+
+		dstBucket, err := dstTx.CreateTopLevelBucket(key)
+		if err != nil {
+			return err
+		}
+
+		return copyBucket(srcBucket, dstBucket)
+	}
+
+	// If the parent bucket doesn't exist in the source anymore, it was
+	// deleted after the item was changed, which is handled by the change
+	// of the parent.
+	srcParent := readBucketAt(srcTx, parentPath)
+	if srcParent == nil {
+		return nil
+	}
+
+	dstParent := writeBucketAt(dstTx, parentPath)
+	if dstParent == nil {
+		return fmt.Errorf("bucket %x missing in compacted copy",
+			parentPath)
+	}
+
+	// We always start from scratch for nested buckets. A nested bucket is
+	// only recorded as changed if it was created or deleted.
+	if dstParent.NestedReadWriteBucket(key) != nil {
+		if err := dstParent.DeleteNestedBucket(key); err != nil {
+			return err
+		}
+	}
+
+	if srcNested := srcParent.NestedReadBucket(key); srcNested != nil {
+		dstNested, err := dstParent.CreateBucket(key)
+		if err != nil {
+			return err
+		}
+
+		return copyBucket(srcNested, dstNested)
+	}
+
+	if value := srcParent.Get(key); value != nil {
+		return dstParent.Put(key, value)
+	}
+
+	if dstParent.Get(key) != nil {
+		return dstParent.Delete(key)
+	}
+
+	return nil
+}
@@ -0,0 +1,370 @@
+package dbcompact
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/dbmigrate"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testBucket   = []byte("test")
+	nestedBucket = []byte("nested")
+)
+
+// newTestBackend creates a compactable bolt database in a temporary
+// directory.
+func newTestBackend(t *testing.T) (*Backend, *Config) {
+	t.Helper()
+
+	cfg := &Config{
+		DBPath:     t.TempDir(),
+		DBFileName: "test.db",
+		DBTimeout:  kvdb.DefaultDBTimeout,
+		MaxPause:   DefaultMaxPause,
+		Copy: &dbmigrate.Config{
+			BatchSize:     50,
+			MaxBatchBytes: dbmigrate.DefaultMaxBatchBytes,
+		},
+	}
+
+	db, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+		DBPath:     cfg.DBPath,
+		DBFileName: cfg.DBFileName,
+		DBTimeout:  cfg.DBTimeout,
+	})
+	require.NoError(t, err)
+
+	backend := NewBackend(db, cfg)
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	return backend, cfg
+}
+
+// newReferenceDB creates a plain bolt database.
+func newReferenceDB(t *testing.T) kvdb.Backend {
+	t.Helper()
+
+	db, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+		DBPath:     t.TempDir(),
+		DBFileName: "reference.db",
+		DBTimeout:  kvdb.DefaultDBTimeout,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	return db
+}
+
+// testOp applies the i-th operation of a deterministic sequence of
+// modifications to the given transaction.
+func testOp(tx kvdb.RwTx, i int) error {
+	bucket, err := tx.CreateTopLevelBucket(testBucket)
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(i%500))
+
+	switch i % 7 {
+	// Delete a key through a cursor.
+	case 0:
+		cursor := bucket.ReadWriteCursor()
+		if k, v := cursor.Seek(key); k != nil && v != nil {
+			return cursor.Delete()
+		}
+
+		return nil
+
+	// Recreate a nested bucket.
+	case 1:
+		nested := bucket.NestedReadWriteBucket(nestedBucket)
+		if nested != nil {
+			err := bucket.DeleteNestedBucket(nestedBucket)
+			if err != nil {
+				return err
+			}
+		}
+
+		nested, err = bucket.CreateBucket(nestedBucket)
+		if err != nil {
+			return err
+		}
+
+		return nested.Put(key, key)
+
+	// Add to the nested bucket and bump its sequence.
+	case 2:
+		nested, err := bucket.CreateBucketIfNotExists(nestedBucket)
+		if err != nil {
+			return err
+		}
+
+		if _, err := nested.NextSequence(); err != nil {
+			return err
+		}
+
+		return nested.Put(key, []byte(fmt.Sprintf("nested-%d", i)))
+
+	// Delete a key.
+	case 3:
+		return bucket.Delete(key)
+
+	// Recreate a top level bucket.
+	case 4:
+		other := []byte(fmt.Sprintf("other-%d", i%3))
+		if tx.ReadWriteBucket(other) != nil {
+			if err := tx.DeleteTopLevelBucket(other); err != nil {
+				return err
+			}
+		}
+
+		otherBucket, err := tx.CreateTopLevelBucket(other)
+		if err != nil {
+			return err
+		}
+
+		return otherBucket.Put(key, key)
+
+	// Overwrite or add a key.
+	default:
+		return bucket.Put(key, make([]byte, 100+i%100))
+	}
+}
+
+// fill adds a lot of data to the database and deletes most of it again, which
+// leaves a lot of free pages for the compaction to remove.
+func fill(t *testing.T, db kvdb.Backend) {
+	t.Helper()
+
+	for i := 0; i < 10; i++ {
+		err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+			bucket, err := tx.CreateTopLevelBucket([]byte("bulk"))
+			if err != nil {
+				return err
+			}
+
+			for j := 0; j < 200; j++ {
+				key := []byte(fmt.Sprintf("%d-%d", i, j))
+				err := bucket.Put(key, make([]byte, 1000))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}, func() {})
+		require.NoError(t, err)
+	}
+
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		bucket := tx.ReadWriteBucket([]byte("bulk"))
+		cursor := bucket.ReadWriteCursor()
+		for k, _ := cursor.First(); k != nil; k, _ = cursor.Next() {
+			if k[0] != '0' {
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}, func() {})
+	require.NoError(t, err)
+}
+
+// requireSameContent makes sure both databases have the same content.
+func requireSameContent(t *testing.T, a, b kvdb.Backend) {
+	t.Helper()
+
+	aSummary, err := dbmigrate.Checksum(a)
+	require.NoError(t, err)
+
+	bSummary, err := dbmigrate.Checksum(b)
+	require.NoError(t, err)
+
+	require.Equal(t, aSummary, bSummary)
+}
+
+// TestApplyChanges tests that all kinds of modifications are recorded and
+// applied to a copy of the database.
+func TestApplyChanges(t *testing.T) {
+	t.Parallel()
+
+	backend, _ := newTestBackend(t)
+	reference := newReferenceDB(t)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, kvdb.Update(backend, func(tx kvdb.RwTx) error {
+			return testOp(tx, i)
+		}, func() {}))
+	}
+
+	// Take a copy of the database and record all changes from now on.
+	_, err := dbmigrate.Copy(context.Background(), backend, reference,
+		dbmigrate.DefaultConfig())
+	require.NoError(t, err)
+	require.NoError(t, dbmigrate.ClearProgress(reference))
+
+	require.NoError(t, backend.startTracking())
+
+	for i := 100; i < 400; i++ {
+		op := func(tx kvdb.RwTx) error {
+			return testOp(tx, i)
+		}
+
+		// We use all the different ways of running a transaction.
+		switch i % 3 {
+		case 0:
+			err = kvdb.Update(backend, op, func() {})
+
+		case 1:
+			err = kvdb.Batch(backend, op)
+
+		default:
+			var tx kvdb.RwTx
+			tx, err = backend.BeginReadWriteTx()
+			require.NoError(t, err)
+			require.NoError(t, op(tx))
+			err = tx.Commit()
+		}
+		require.NoError(t, err)
+	}
+
+	// A rolled back transaction must not be recorded.
+	tx, err := backend.BeginReadWriteTx()
+	require.NoError(t, err)
+	bucket, err := tx.CreateTopLevelBucket([]byte("rolled-back"))
+	require.NoError(t, err)
+	require.NoError(t, bucket.Put([]byte("k"), []byte("v")))
+	require.NoError(t, tx.Rollback())
+
+	changes := backend.tracker.take()
+	for _, item := range changes {
+		require.NotEqual(t, "rolled-back", string(item.path[0]))
+	}
+
+	require.NoError(t, applyChanges(backend, reference, changes))
+	requireSameContent(t, backend, reference)
+}
+
+// TestCompact tests that a database can be compacted while it is being
+// written to.
+func TestCompact(t *testing.T) {
+	t.Parallel()
+
+	backend, cfg := newTestBackend(t)
+	reference := newReferenceDB(t)
+
+	fill(t, backend)
+	fill(t, reference)
+
+	// We keep on writing the same modifications to both databases while
+	// the compaction is running.
+	var (
+		wg   sync.WaitGroup
+		quit = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; ; i++ {
+			select {
+			case <-quit:
+				return
+			default:
+			}
+
+			op := func(tx kvdb.RwTx) error {
+				return testOp(tx, i)
+			}
+			require.NoError(t, kvdb.Update(backend, op, func() {}))
+			require.NoError(t, kvdb.Update(reference, op, func() {}))
+		}
+	}()
+
+	result, err := backend.Compact(context.Background())
+	close(quit)
+	wg.Wait()
+	require.NoError(t, err)
+
+	require.Less(t, result.SizeAfter, result.SizeBefore)
+	require.NoFileExists(
+		t, filepath.Join(cfg.DBPath, cfg.DBFileName+compactTempSuffix),
+	)
+	require.NoFileExists(
+		t, filepath.Join(cfg.DBPath, cfg.DBFileName+compactOldSuffix),
+	)
+
+	requireSameContent(t, backend, reference)
+
+	// The database must still be usable after the compaction.
+	op := func(tx kvdb.RwTx) error {
+		return testOp(tx, 1)
+	}
+	require.NoError(t, kvdb.Update(backend, op, func() {}))
+	require.NoError(t, kvdb.Update(reference, op, func() {}))
+	requireSameContent(t, backend, reference)
+}
+
+// TestCompactPauseTimeout tests that the compaction is aborted if a
+// transaction doesn't finish in time, and that the original database is left
+// untouched.
+func TestCompactPauseTimeout(t *testing.T) {
+	t.Parallel()
+
+	backend, cfg := newTestBackend(t)
+	cfg.MaxPause = 100 * time.Millisecond
+
+	fill(t, backend)
+
+	tx, err := backend.BeginReadTx()
+	require.NoError(t, err)
+
+	_, err = backend.Compact(context.Background())
+	require.ErrorIs(t, err, ErrPauseTimeout)
+	require.NoError(t, tx.Rollback())
+
+	require.NoFileExists(
+		t, filepath.Join(cfg.DBPath, cfg.DBFileName+compactTempSuffix),
+	)
+	require.Nil(t, backend.tracker)
+
+	// New transactions must not be held back anymore.
+	require.NoError(t, kvdb.Update(backend, func(tx kvdb.RwTx) error {
+		return testOp(tx, 5)
+	}, func() {}))
+
+	// Without the long running transaction, the compaction succeeds.
+	_, err = backend.Compact(context.Background())
+	require.NoError(t, err)
+}
+
+// TestScheduleCompaction tests that compaction requests are persisted.
+func TestScheduleCompaction(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.False(t, CompactionScheduled(dir, "channel.db"))
+	require.NoError(t, ClearScheduledCompaction(dir, "channel.db"))
+
+	require.NoError(t, ScheduleCompaction(dir, "channel.db"))
+	require.True(t, CompactionScheduled(dir, "channel.db"))
+	require.False(t, CompactionScheduled(dir, "wallet.db"))
+
+	require.NoError(t, ClearScheduledCompaction(dir, "channel.db"))
+	require.False(t, CompactionScheduled(dir, "channel.db"))
+}
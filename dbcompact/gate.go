@@ -0,0 +1,119 @@
+package dbcompact
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPauseTimeout is returned if the database transactions that are in flight
+// don't finish within the maximum pause duration.
+var ErrPauseTimeout = errors.New("timeout waiting for database " +
+	"transactions to finish")
+
+// txGate keeps track of the transactions that are in flight and allows new
+// transactions to be held back for a short while.
+type txGate struct {
+	mu sync.Mutex
+
+	// numReaders and numWriters are the number of read-only and read-write
+	// transactions that are in flight.
+	numReaders int
+	numWriters int
+
+	// paused is non-nil while new transactions are held back. It is closed
+	// once they may continue.
+	paused chan struct{}
+
+	// drained is closed once all transactions we wait for have finished
+	// while the gate is paused.
+	drained     chan struct{}
+	waitReaders bool
+}
+
+// enter blocks while the gate is paused and then registers a new transaction.
+func (g *txGate) enter(write bool) {
+	for {
+		g.mu.Lock()
+		paused := g.paused
+		if paused == nil {
+			if write {
+				g.numWriters++
+			} else {
+				g.numReaders++
+			}
+			g.mu.Unlock()
+
+			return
+		}
+		g.mu.Unlock()
+
+		<-paused
+	}
+}
+
+// exit unregisters a transaction that was registered with enter.
+func (g *txGate) exit(write bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if write {
+		g.numWriters--
+	} else {
+		g.numReaders--
+	}
+
+	g.signalDrained()
+}
+
+// signalDrained closes the drained channel if we're paused and all
+// transactions we're waiting for have finished. The mutex must be held.
+func (g *txGate) signalDrained() {
+	if g.drained == nil || g.numWriters > 0 {
+		return
+	}
+
+	if g.waitReaders && g.numReaders > 0 {
+		return
+	}
+
+	close(g.drained)
+	g.drained = nil
+}
+
+// pause holds back all new transactions and waits for the ones in flight to
+// finish. If waitReaders is false, only read-write transactions are waited
+// for. If they don't finish within the timeout, the gate is opened again and
+// ErrPauseTimeout is returned. On success, resume must be called to let new
+// transactions through again.
+func (g *txGate) pause(timeout time.Duration, waitReaders bool) error {
+	g.mu.Lock()
+	drained := make(chan struct{})
+	g.paused = make(chan struct{})
+	g.drained = drained
+	g.waitReaders = waitReaders
+	g.signalDrained()
+	g.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+
+	case <-time.After(timeout):
+		g.resume()
+
+		return ErrPauseTimeout
+	}
+}
+
+// resume lets all transactions that were held back by pause continue.
+func (g *txGate) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.paused != nil {
+		close(g.paused)
+	}
+	g.paused = nil
+	g.drained = nil
+}
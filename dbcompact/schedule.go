@@ -0,0 +1,47 @@
+package dbcompact
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// scheduledSuffix is the suffix of the marker file that is created next to a
+// database file to request its compaction on the next start.
+const scheduledSuffix = ".compact-on-start"
+
+// scheduledMarkerPath returns the path of the marker file of the given
+// database file.
+func scheduledMarkerPath(dir, fileName string) string {
+	return filepath.Join(dir, fileName+scheduledSuffix)
+}
+
+// ScheduleCompaction requests the compaction of the given bolt database file
+// the next time it is opened. The request is persisted as a marker file next
+// to the database.
+func ScheduleCompaction(dir, fileName string) error {
+	return os.WriteFile(
+		scheduledMarkerPath(dir, fileName),
+		[]byte(time.Now().UTC().Format(time.RFC3339)), 0600,
+	)
+}
+
+// CompactionScheduled returns true if a compaction of the given database file
+// was requested for its next start.
+func CompactionScheduled(dir, fileName string) bool {
+	_, err := os.Stat(scheduledMarkerPath(dir, fileName))
+
+	return err == nil
+}
+
+// ClearScheduledCompaction removes the compaction request of the given
+// database file, if there is one.
+func ClearScheduledCompaction(dir, fileName string) error {
+	err := os.Remove(scheduledMarkerPath(dir, fileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,361 @@
+package dbcompact
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// changedItem is a bucket or key/value pair that was modified while a
+// compaction was in progress.
+type changedItem struct {
+	// path contains the keys of all parent buckets followed by the key of
+	// the modified item.
+	path [][]byte
+
+	// sequence is true if the sequence number of the bucket with the
+	// given path was modified, rather than the item itself.
+	sequence bool
+}
+
+// changeSet is a set of modified items.
+type changeSet map[string]changedItem
+
+// add adds an item to the set. The path is copied, so the caller is free to
+// reuse its memory.
+func (s changeSet) add(parent [][]byte, key []byte, sequence bool) {
+	path := make([][]byte, 0, len(parent)+1)
+	for _, k := range parent {
+		path = append(path, append([]byte(nil), k...))
+	}
+	if key != nil {
+		path = append(path, append([]byte(nil), key...))
+	}
+
+	var id []byte
+	for _, k := range path {
+		id = binary.AppendUvarint(id, uint64(len(k)))
+		id = append(id, k...)
+	}
+	if sequence {
+		id = append(id, 0xff)
+	}
+
+	s[string(id)] = changedItem{
+		path:     path,
+		sequence: sequence,
+	}
+}
+
+// sorted returns all items of the set, sorted so that parent buckets are
+// visited before the items nested in them.
+func (s changeSet) sorted() []changedItem {
+	items := make([]changedItem, 0, len(s))
+	for _, item := range s {
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if c := comparePaths(a.path, b.path); c != 0 {
+			return c < 0
+		}
+
+		return !a.sequence && b.sequence
+	})
+
+	return items
+}
+
+// comparePaths compares two paths component by component. A path that is a
+// prefix of another path sorts before it.
+func comparePaths(a, b [][]byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := bytes.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+
+	case len(a) > len(b):
+		return 1
+
+	default:
+		return 0
+	}
+}
+
+// changeTracker collects the items modified by all committed transactions.
+type changeTracker struct {
+	mu      sync.Mutex
+	changes changeSet
+}
+
+// newChangeTracker creates a new, empty change tracker.
+func newChangeTracker() *changeTracker {
+	return &changeTracker{
+		changes: make(changeSet),
+	}
+}
+
+// merge adds the items modified by a committed transaction.
+func (t *changeTracker) merge(changes changeSet) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, item := range changes {
+		t.changes[id] = item
+	}
+}
+
+// take returns all items collected so far and starts a new set.
+func (t *changeTracker) take() changeSet {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changes := t.changes
+	t.changes = make(changeSet)
+
+	return changes
+}
+
+// trackingTx is a read-write transaction that records all modifications. The
+// changes are only handed to the tracker once the transaction is committed,
+// so that a compaction never misses a change that isn't visible to it yet.
+type trackingTx struct {
+	walletdb.ReadWriteTx
+
+	changes changeSet
+}
+
+// ReadBucket opens the root bucket for read only access.
+func (tx *trackingTx) ReadBucket(key []byte) walletdb.ReadBucket {
+	bucket := tx.ReadWriteBucket(key)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket
+}
+
+// ReadWriteBucket opens the root bucket for read/write access.
+func (tx *trackingTx) ReadWriteBucket(key []byte) walletdb.ReadWriteBucket {
+	bucket := tx.ReadWriteTx.ReadWriteBucket(key)
+	if bucket == nil {
+		return nil
+	}
+
+	return tx.wrapBucket(bucket, nil, key)
+}
+
+// CreateTopLevelBucket creates the top level bucket for a key if it does not
+// exist.
+func (tx *trackingTx) CreateTopLevelBucket(
+	key []byte) (walletdb.ReadWriteBucket, error) {
+
+	existed := tx.ReadWriteTx.ReadWriteBucket(key) != nil
+
+	bucket, err := tx.ReadWriteTx.CreateTopLevelBucket(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !existed {
+		tx.changes.add(nil, key, false)
+	}
+
+	return tx.wrapBucket(bucket, nil, key), nil
+}
+
+// DeleteTopLevelBucket deletes the top level bucket for a key.
+func (tx *trackingTx) DeleteTopLevelBucket(key []byte) error {
+	tx.changes.add(nil, key, false)
+
+	return tx.ReadWriteTx.DeleteTopLevelBucket(key)
+}
+
+// wrapBucket returns a tracking bucket for the nested bucket with the given
+// key.
+func (tx *trackingTx) wrapBucket(bucket walletdb.ReadWriteBucket,
+	parent [][]byte, key []byte) *trackingBucket {
+
+	path := make([][]byte, len(parent)+1)
+	copy(path, parent)
+	path[len(parent)] = append([]byte(nil), key...)
+
+	return &trackingBucket{
+		ReadWriteBucket: bucket,
+		tx:              tx,
+		path:            path,
+	}
+}
+
+// trackingBucket is a bucket that records all modifications in the changes
+// of its transaction.
+type trackingBucket struct {
+	walletdb.ReadWriteBucket
+
+	tx   *trackingTx
+	path [][]byte
+}
+
+// NestedReadBucket retrieves a nested bucket with the given key.
+func (b *trackingBucket) NestedReadBucket(key []byte) walletdb.ReadBucket {
+	bucket := b.NestedReadWriteBucket(key)
+	if bucket == nil {
+		return nil
+	}
+
+	return bucket
+}
+
+// NestedReadWriteBucket retrieves a nested bucket with the given key.
+func (b *trackingBucket) NestedReadWriteBucket(
+	key []byte) walletdb.ReadWriteBucket {
+
+	bucket := b.ReadWriteBucket.NestedReadWriteBucket(key)
+	if bucket == nil {
+		return nil
+	}
+
+	return b.tx.wrapBucket(bucket, b.path, key)
+}
+
+// CreateBucket creates and returns a new nested bucket with the given key.
+func (b *trackingBucket) CreateBucket(
+	key []byte) (walletdb.ReadWriteBucket, error) {
+
+	bucket, err := b.ReadWriteBucket.CreateBucket(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b.tx.changes.add(b.path, key, false)
+
+	return b.tx.wrapBucket(bucket, b.path, key), nil
+}
+
+// CreateBucketIfNotExists creates and returns a new nested bucket with the
+// given key if it does not already exist.
+func (b *trackingBucket) CreateBucketIfNotExists(
+	key []byte) (walletdb.ReadWriteBucket, error) {
+
+	existed := b.ReadWriteBucket.NestedReadWriteBucket(key) != nil
+
+	bucket, err := b.ReadWriteBucket.CreateBucketIfNotExists(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !existed {
+		b.tx.changes.add(b.path, key, false)
+	}
+
+	return b.tx.wrapBucket(bucket, b.path, key), nil
+}
+
+// DeleteNestedBucket removes a nested bucket with the given key.
+func (b *trackingBucket) DeleteNestedBucket(key []byte) error {
+	b.tx.changes.add(b.path, key, false)
+
+	return b.ReadWriteBucket.DeleteNestedBucket(key)
+}
+
+// Put saves the specified key/value pair to the bucket.
+func (b *trackingBucket) Put(key, value []byte) error {
+	b.tx.changes.add(b.path, key, false)
+
+	return b.ReadWriteBucket.Put(key, value)
+}
+
+// Delete removes the specified key from the bucket.
+func (b *trackingBucket) Delete(key []byte) error {
+	b.tx.changes.add(b.path, key, false)
+
+	return b.ReadWriteBucket.Delete(key)
+}
+
+// ReadWriteCursor returns a new cursor, allowing for iteration over the
+// bucket's key/value pairs and nested buckets in forward or backward order.
+func (b *trackingBucket) ReadWriteCursor() walletdb.ReadWriteCursor {
+	return &trackingCursor{
+		ReadWriteCursor: b.ReadWriteBucket.ReadWriteCursor(),
+		bucket:          b,
+	}
+}
+
+// Tx returns the bucket's transaction.
+func (b *trackingBucket) Tx() walletdb.ReadWriteTx {
+	return b.tx
+}
+
+// NextSequence returns an autoincrementing integer for the bucket.
+func (b *trackingBucket) NextSequence() (uint64, error) {
+	b.tx.changes.add(b.path, nil, true)
+
+	return b.ReadWriteBucket.NextSequence()
+}
+
+// SetSequence updates the sequence number for the bucket.
+func (b *trackingBucket) SetSequence(v uint64) error {
+	b.tx.changes.add(b.path, nil, true)
+
+	return b.ReadWriteBucket.SetSequence(v)
+}
+
+// trackingCursor is a cursor that records the keys it deletes.
+type trackingCursor struct {
+	walletdb.ReadWriteCursor
+
+	bucket *trackingBucket
+	key    []byte
+}
+
+// track remembers the key the cursor currently points to.
+func (c *trackingCursor) track(k, v []byte) ([]byte, []byte) {
+	c.key = k
+
+	return k, v
+}
+
+// First positions the cursor at the first key/value pair and returns the
+// pair.
+func (c *trackingCursor) First() ([]byte, []byte) {
+	return c.track(c.ReadWriteCursor.First())
+}
+
+// Last positions the cursor at the last key/value pair and returns the pair.
+func (c *trackingCursor) Last() ([]byte, []byte) {
+	return c.track(c.ReadWriteCursor.Last())
+}
+
+// Next moves the cursor one key/value pair forward and returns the new pair.
+func (c *trackingCursor) Next() ([]byte, []byte) {
+	return c.track(c.ReadWriteCursor.Next())
+}
+
+// Prev moves the cursor one key/value pair backward and returns the new pair.
+func (c *trackingCursor) Prev() ([]byte, []byte) {
+	return c.track(c.ReadWriteCursor.Prev())
+}
+
+// Seek positions the cursor at the passed seek key.
+func (c *trackingCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.track(c.ReadWriteCursor.Seek(seek))
+}
+
+// Delete removes the current key/value pair the cursor is at without
+// invalidating the cursor.
+func (c *trackingCursor) Delete() error {
+	if c.key != nil {
+		c.bucket.tx.changes.add(c.bucket.path, c.key, false)
+	}
+
+	return c.ReadWriteCursor.Delete()
+}
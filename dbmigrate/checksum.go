@@ -0,0 +1,115 @@
+package dbmigrate
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+// ErrChecksumMismatch is returned if the content of the destination DB doesn't
+// match the content of the source DB after the migration.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Summary describes the content of a DB.
+type Summary struct {
+	// NumBuckets is the number of buckets in the DB, including the top
+	// level buckets.
+	NumBuckets uint64
+
+	// NumKeys is the number of key/value pairs in the DB.
+	NumKeys uint64
+
+	// NumBytes is the total size of all keys and values in the DB.
+	NumBytes uint64
+
+	// Checksum is the SHA256 hash over all buckets, their sequence numbers
+	// and all key/value pairs of the DB, in the order they are visited in.
+	Checksum [sha256.Size]byte
+}
+
+// String returns a human readable representation of the summary.
+func (s *Summary) String() string {
+	return fmt.Sprintf("buckets=%d, keys=%d, bytes=%d, checksum=%x",
+		s.NumBuckets, s.NumKeys, s.NumBytes, s.Checksum)
+}
+
+// Checksum walks the whole DB and returns a summary of its content. Because
+// the summary only depends on the logical content of the DB, it can be used to
+// compare DBs of different backend types. The meta bucket of an unfinished
+// migration is not included.
+func Checksum(db kvdb.Backend) (*Summary, error) {
+	var summary *Summary
+	err := kvdb.View(db, func(tx kvdb.RTx) error {
+		var (
+			hash = sha256.New()
+			s    Summary
+			buf  [binary.MaxVarintLen64]byte
+		)
+
+		err := walk(tx, nil, func(path [][]byte, value []byte,
+			isBucket bool, sequence uint64) error {
+
+			_, _ = hash.Write(encodePath(path))
+
+			if isBucket {
+				s.NumBuckets++
+
+				_, _ = hash.Write([]byte{0})
+				binary.BigEndian.PutUint64(buf[:8], sequence)
+				_, _ = hash.Write(buf[:8])
+
+				return nil
+			}
+
+			s.NumKeys++
+			s.NumBytes += uint64(len(path[len(path)-1]))
+			s.NumBytes += uint64(len(value))
+
+			_, _ = hash.Write([]byte{1})
+			n := binary.PutUvarint(buf[:], uint64(len(value)))
+			_, _ = hash.Write(buf[:n])
+			_, _ = hash.Write(value)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		copy(s.Checksum[:], hash.Sum(nil))
+		summary = &s
+
+		return nil
+	}, func() {
+		summary = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// Verify makes sure the source and destination DB have the same content.
+func Verify(src, dst kvdb.Backend) (*Summary, error) {
+	srcSummary, err := Checksum(src)
+	if err != nil {
+		return nil, fmt.Errorf("unable to checksum source: %w", err)
+	}
+
+	dstSummary, err := Checksum(dst)
+	if err != nil {
+		return nil, fmt.Errorf("unable to checksum destination: %w",
+			err)
+	}
+
+	if *srcSummary != *dstSummary {
+		return nil, fmt.Errorf("%w: source (%v) vs. destination (%v)",
+			ErrChecksumMismatch, srcSummary, dstSummary)
+	}
+
+	return srcSummary, nil
+}
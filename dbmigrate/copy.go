@@ -0,0 +1,474 @@
+package dbmigrate
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+var (
+	// metaBucket is the top level bucket in the destination DB that keeps
+	// track of the progress of an unfinished migration. It is removed
+	// once the migration has been verified.
+	metaBucket = []byte("dbmigrate-meta")
+
+	// resumeKey is the key in the meta bucket that stores the path of the
+	// last bucket or key/value pair that was copied to the destination.
+	resumeKey = []byte("resume-path")
+
+	// startedKey is the key in the meta bucket that marks the destination
+	// as the target of a migration, even before the first batch is
+	// committed.
+	startedKey = []byte("started")
+
+	// ErrDestinationNotEmpty is returned if the destination DB already
+	// contains data that wasn't written by a previous migration attempt.
+	ErrDestinationNotEmpty = errors.New("destination database is not " +
+		"empty")
+)
+
+// walkFunc is called for every bucket and key/value pair of a DB. The path
+// contains the keys of all parent buckets followed by the key of the item
+// itself. The value is nil for buckets and the sequence is zero for key/value
+// pairs.
+type walkFunc func(path [][]byte, value []byte, isBucket bool,
+	sequence uint64) error
+
+// sequencer is implemented by all buckets that expose their sequence number.
+// The read-only bucket interface doesn't include the sequence, even though all
+// backends keep track of it.
+type sequencer interface {
+	Sequence() uint64
+}
+
+// bucketSequence returns the sequence number of the given bucket, or zero if
+// the bucket doesn't expose it.
+func bucketSequence(bucket walletdb.ReadBucket) uint64 {
+	if s, ok := bucket.(sequencer); ok {
+		return s.Sequence()
+	}
+
+	return 0
+}
+
+// comparePaths compares two paths component by component. A path that is a
+// prefix of another path sorts before it, which results in the same order the
+// items are visited in by walk.
+func comparePaths(a, b [][]byte) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := bytes.Compare(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+
+	case len(a) > len(b):
+		return 1
+
+	default:
+		return 0
+	}
+}
+
+// isPrefix returns true if prefix is a strict prefix of path.
+func isPrefix(prefix, path [][]byte) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+
+	return comparePaths(prefix, path[:len(prefix)]) == 0
+}
+
+// encodePath serializes a path as a series of length prefixed keys.
+func encodePath(path [][]byte) []byte {
+	var b []byte
+	for _, key := range path {
+		b = binary.AppendUvarint(b, uint64(len(key)))
+		b = append(b, key...)
+	}
+
+	return b
+}
+
+// decodePath deserializes a path that was serialized with encodePath.
+func decodePath(b []byte) ([][]byte, error) {
+	var path [][]byte
+	for len(b) > 0 {
+		length, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < length {
+			return nil, fmt.Errorf("invalid path encoding")
+		}
+
+		key := make([]byte, length)
+		copy(key, b[n:n+int(length)])
+		path = append(path, key)
+
+		b = b[n+int(length):]
+	}
+
+	return path, nil
+}
+
+// walk visits all buckets and key/value pairs of the DB in depth-first
+// pre-order, with the keys of each bucket in lexicographic order. If after is
+// set, all items up to and including the item with that path are skipped.
+// The meta bucket of a migration is never visited.
+func walk(tx kvdb.RTx, after [][]byte, cb walkFunc) error {
+	return tx.ForEachBucket(func(key []byte) error {
+		if bytes.Equal(key, metaBucket) {
+			return nil
+		}
+
+		bucket := tx.ReadBucket(key)
+		if bucket == nil {
+			return fmt.Errorf("top level bucket %x not found", key)
+		}
+
+		return walkBucket(bucket, [][]byte{key}, after, cb)
+	})
+}
+
+// walkBucket visits the given bucket and everything nested in it. See walk
+// for the order and the meaning of after.
+func walkBucket(bucket walletdb.ReadBucket, path, after [][]byte,
+	cb walkFunc) error {
+
+	if after != nil {
+		// If the resume position lies behind this bucket and isn't
+		// nested in it, then the whole bucket was already visited.
+		c := comparePaths(path, after)
+		if c < 0 && !isPrefix(path, after) {
+			return nil
+		}
+
+		// Everything in a bucket that sorts after the resume position
+		// needs to be visited.
+		if c > 0 {
+			after = nil
+		}
+	}
+
+	if after == nil {
+		err := cb(path, nil, true, bucketSequence(bucket))
+		if err != nil {
+			return err
+		}
+	}
+
+	cursor := bucket.ReadCursor()
+	k, v := cursor.First()
+
+	// If the resume position is nested in this bucket, we can seek right
+	// to it instead of iterating over all keys before it.
+	if after != nil && isPrefix(path, after) {
+		k, v = cursor.Seek(after[len(path)])
+	}
+
+	for ; k != nil; k, v = cursor.Next() {
+		childPath := make([][]byte, len(path)+1)
+		copy(childPath, path)
+		childPath[len(path)] = k
+
+		if v == nil {
+			nested := bucket.NestedReadBucket(k)
+			if nested != nil {
+				err := walkBucket(nested, childPath, after, cb)
+				if err != nil {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		if after != nil && comparePaths(childPath, after) <= 0 {
+			continue
+		}
+
+		if err := cb(childPath, v, false, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyItem is a single bucket or key/value pair that is waiting to be written
+// to the destination DB.
+type copyItem struct {
+	path     [][]byte
+	value    []byte
+	isBucket bool
+	sequence uint64
+}
+
+// copier copies the content of a source DB into a destination DB in batches.
+type copier struct {
+	ctx context.Context
+	dst kvdb.Backend
+	cfg *Config
+
+	batch      []copyItem
+	batchBytes int
+
+	numItems uint64
+	numBytes uint64
+}
+
+// readResumePath returns the path of the last item that was copied to the
+// destination DB by a previous attempt, or nil if the migration hasn't
+// started yet. An error is returned if the destination contains unrelated
+// data.
+func readResumePath(dst kvdb.Backend) ([][]byte, bool, error) {
+	var (
+		path    [][]byte
+		started bool
+	)
+	err := kvdb.View(dst, func(tx kvdb.RTx) error {
+		meta := tx.ReadBucket(metaBucket)
+		if meta == nil {
+			// Without a meta bucket, the destination must be
+			// completely empty.
+			return tx.ForEachBucket(func(key []byte) error {
+				return ErrDestinationNotEmpty
+			})
+		}
+
+		started = true
+
+		encoded := meta.Get(resumeKey)
+		if encoded == nil {
+			return nil
+		}
+
+		var err error
+		path, err = decodePath(encoded)
+
+		return err
+	}, func() {
+		path = nil
+		started = false
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return path, started, nil
+}
+
+// Copy copies every bucket and key/value pair of src into dst. The copy is
+// committed in batches, and the position of the last committed item is stored
+// in the meta bucket of the destination, so an interrupted copy continues
+// where it left off when called again. The meta bucket is kept until
+// ClearProgress is called. The number of copied items is returned.
+func Copy(ctx context.Context, src, dst kvdb.Backend,
+	cfg *Config) (uint64, error) {
+
+	after, started, err := readResumePath(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	if !started {
+		err := kvdb.Update(dst, func(tx kvdb.RwTx) error {
+			meta, err := tx.CreateTopLevelBucket(metaBucket)
+			if err != nil {
+				return err
+			}
+
+			return meta.Put(startedKey, []byte{1})
+		}, func() {})
+		if err != nil {
+			return 0, fmt.Errorf("unable to create meta bucket: %w",
+				err)
+		}
+	}
+
+	if after != nil {
+		log.Infof("Resuming interrupted migration")
+	}
+
+	c := &copier{
+		ctx: ctx,
+		dst: dst,
+		cfg: cfg,
+	}
+
+	// The items we collect in a batch reference memory of the source read
+	// transaction, so all batches must be written before it is closed.
+	err = kvdb.View(src, func(tx kvdb.RTx) error {
+		if err := walk(tx, after, c.add); err != nil {
+			return err
+		}
+
+		return c.flush()
+	}, func() {
+		c.batch = nil
+		c.batchBytes = 0
+		c.numItems = 0
+		c.numBytes = 0
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return c.numItems, nil
+}
+
+// add queues a single item for the destination and writes the current batch
+// once it is full.
+func (c *copier) add(path [][]byte, value []byte, isBucket bool,
+	sequence uint64) error {
+
+	c.batch = append(c.batch, copyItem{
+		path:     path,
+		value:    value,
+		isBucket: isBucket,
+		sequence: sequence,
+	})
+	for _, key := range path {
+		c.batchBytes += len(key)
+	}
+	c.batchBytes += len(value)
+
+	if len(c.batch) < c.cfg.BatchSize &&
+		c.batchBytes < c.cfg.MaxBatchBytes {
+
+		return nil
+	}
+
+	return c.flush()
+}
+
+// flush writes all queued items to the destination in a single transaction,
+// together with the new resume position.
+func (c *copier) flush() error {
+	if len(c.batch) == 0 {
+		return nil
+	}
+
+	select {
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	default:
+	}
+
+	err := kvdb.Update(c.dst, func(tx kvdb.RwTx) error {
+		// Most items of a batch share their parent bucket, so we cache
+		// the buckets we've opened in this transaction.
+		buckets := make(map[string]walletdb.ReadWriteBucket)
+		parentBucket := func(path [][]byte) (walletdb.ReadWriteBucket,
+			error) {
+
+			cacheKey := string(encodePath(path))
+			if bucket, ok := buckets[cacheKey]; ok {
+				return bucket, nil
+			}
+
+			bucket := tx.ReadWriteBucket(path[0])
+			for i := 1; i < len(path) && bucket != nil; i++ {
+				bucket = bucket.NestedReadWriteBucket(path[i])
+			}
+			if bucket == nil {
+				return nil, fmt.Errorf("bucket %x not found",
+					path)
+			}
+			buckets[cacheKey] = bucket
+
+			return bucket, nil
+		}
+
+		for _, item := range c.batch {
+			key := item.path[len(item.path)-1]
+			parentPath := item.path[:len(item.path)-1]
+
+			switch {
+			case item.isBucket && len(parentPath) == 0:
+				bucket, err := tx.CreateTopLevelBucket(key)
+				if err != nil {
+					return err
+				}
+
+				err = bucket.SetSequence(item.sequence)
+				if err != nil {
+					return err
+				}
+
+			case item.isBucket:
+				parent, err := parentBucket(parentPath)
+				if err != nil {
+					return err
+				}
+
+				bucket, err := parent.CreateBucketIfNotExists(
+					key,
+				)
+				if err != nil {
+					return err
+				}
+
+				err = bucket.SetSequence(item.sequence)
+				if err != nil {
+					return err
+				}
+
+			default:
+				parent, err := parentBucket(parentPath)
+				if err != nil {
+					return err
+				}
+
+				value := item.value
+				if value == nil {
+					value = []byte{}
+				}
+				if err := parent.Put(key, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		meta := tx.ReadWriteBucket(metaBucket)
+		if meta == nil {
+			return fmt.Errorf("meta bucket not found")
+		}
+
+		lastPath := c.batch[len(c.batch)-1].path
+
+		return meta.Put(resumeKey, encodePath(lastPath))
+	}, func() {})
+	if err != nil {
+		return fmt.Errorf("unable to write batch: %w", err)
+	}
+
+	c.numItems += uint64(len(c.batch))
+	c.numBytes += uint64(c.batchBytes)
+
+	log.Debugf("Copied %d items (%d bytes) so far", c.numItems,
+		c.numBytes)
+
+	c.batch = c.batch[:0]
+	c.batchBytes = 0
+
+	return nil
+}
+
+// ClearProgress deletes the meta bucket that keeps track of the progress of
+// Copy from the destination DB, if it exists.
+func ClearProgress(dst kvdb.Backend) error {
+	return kvdb.Update(dst, func(tx kvdb.RwTx) error {
+		if tx.ReadBucket(metaBucket) == nil {
+			return nil
+		}
+
+		return tx.DeleteTopLevelBucket(metaBucket)
+	}, func() {})
+}
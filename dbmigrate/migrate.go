@@ -0,0 +1,178 @@
+package dbmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultBatchSize is the default maximum number of buckets and
+	// key/value pairs that are written to the destination in a single
+	// transaction.
+	DefaultBatchSize = 5000
+
+	// DefaultMaxBatchBytes is the default maximum size of the keys and
+	// values that are written to the destination in a single transaction.
+	DefaultMaxBatchBytes = 16 * 1024 * 1024
+
+	// DefaultSourceTimeout is the default time we wait for the lock of a
+	// bolt source DB before we assume it is still in use.
+	DefaultSourceTimeout = 5 * time.Second
+
+	// tombstoneValue is the value of the tombstone marker we add to the
+	// source DB once its content was migrated.
+	tombstoneValue = "Database was migrated to a different backend, " +
+		"using this database is no longer safe"
+)
+
+var (
+	// ErrSourceNotFound is returned if a bolt source DB doesn't exist.
+	ErrSourceNotFound = errors.New("source database not found")
+
+	// ErrSourceLocked is returned if a bolt source DB is still opened by
+	// another process, most likely a running lnd.
+	ErrSourceLocked = errors.New("source database is in use, make sure " +
+		"lnd is not running")
+)
+
+// Config holds the options of a migration.
+type Config struct {
+	// BatchSize is the maximum number of buckets and key/value pairs that
+	// are written to the destination in a single transaction.
+	BatchSize int
+
+	// MaxBatchBytes is the maximum size of the keys and values that are
+	// written to the destination in a single transaction.
+	MaxBatchBytes int
+}
+
+// DefaultConfig returns the default migration config.
+func DefaultConfig() *Config {
+	return &Config{
+		BatchSize:     DefaultBatchSize,
+		MaxBatchBytes: DefaultMaxBatchBytes,
+	}
+}
+
+// OpenBoltSource opens an existing bolt DB for migration. In contrast to
+// kvdb.GetBoltBackend, the DB file is never created. If the DB is still locked
+// by another process after the given timeout, ErrSourceLocked is returned.
+func OpenBoltSource(dir, fileName string,
+	timeout time.Duration) (kvdb.Backend, error) {
+
+	dbPath := filepath.Join(dir, fileName)
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", ErrSourceNotFound, dbPath)
+	}
+
+	db, err := kvdb.Open(kvdb.BoltBackendName, dbPath, true, timeout)
+	switch {
+	case errors.Is(err, bbolt.ErrTimeout):
+		return nil, fmt.Errorf("%w: %v", ErrSourceLocked, dbPath)
+
+	case errors.Is(err, walletdb.ErrDbDoesNotExist):
+		return nil, fmt.Errorf("%w: %v", ErrSourceNotFound, dbPath)
+
+	case err != nil:
+		return nil, fmt.Errorf("unable to open %v: %w", dbPath, err)
+	}
+
+	return db, nil
+}
+
+// isTombstoned returns true if the DB carries the tombstone marker that is
+// added after a successful migration.
+func isTombstoned(db kvdb.Backend) (bool, error) {
+	var tombstoned bool
+	err := kvdb.View(db, func(tx kvdb.RTx) error {
+		_, err := channeldb.CheckMarkerPresent(
+			tx, channeldb.TombstoneKey,
+		)
+		switch {
+		case err == nil:
+			tombstoned = true
+
+		case errors.Is(err, channeldb.ErrMarkerNotPresent):
+			tombstoned = false
+
+		default:
+			return err
+		}
+
+		return nil
+	}, func() {
+		tombstoned = false
+	})
+
+	return tombstoned, err
+}
+
+// Migrate copies the content of the source DB into the destination DB and
+// verifies the result. If the migration is interrupted, calling Migrate again
+// with the same source and destination continues where the last attempt left
+// off. A destination that contains data from anything but an earlier attempt
+// is refused.
+//
+// Once the destination is verified, a tombstone marker is added to the source
+// DB, which prevents lnd from accidentally using it again. A source that
+// already carries the marker is skipped.
+func Migrate(ctx context.Context, name string, src, dst kvdb.Backend,
+	cfg *Config) error {
+
+	tombstoned, err := isTombstoned(src)
+	if err != nil {
+		return fmt.Errorf("unable to check %s for tombstone: %w", name,
+			err)
+	}
+
+	if tombstoned {
+		log.Infof("Database %s was already migrated, skipping", name)
+
+		// We might have been interrupted after tombstoning the source
+		// but before cleaning up the destination.
+		return ClearProgress(dst)
+	}
+
+	log.Infof("Migrating database %s", name)
+	start := time.Now()
+
+	numItems, err := Copy(ctx, src, dst, cfg)
+	if err != nil {
+		return fmt.Errorf("unable to copy %s: %w", name, err)
+	}
+
+	log.Infof("Copied %d items of database %s in %v, verifying", numItems,
+		name, time.Since(start).Round(time.Second))
+
+	summary, err := Verify(src, dst)
+	if err != nil {
+		return fmt.Errorf("unable to verify %s: %w", name, err)
+	}
+
+	err = kvdb.Update(src, func(tx kvdb.RwTx) error {
+		return channeldb.AddMarker(
+			tx, channeldb.TombstoneKey, []byte(tombstoneValue),
+		)
+	}, func() {})
+	if err != nil {
+		return fmt.Errorf("unable to add tombstone to %s: %w", name,
+			err)
+	}
+
+	if err := ClearProgress(dst); err != nil {
+		return fmt.Errorf("unable to clean up %s: %w", name, err)
+	}
+
+	log.Infof("Migrated database %s: %v", name, summary)
+
+	return nil
+}
@@ -0,0 +1,347 @@
+package dbmigrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/stretchr/testify/require"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// interruptedBackend is a backend that fails all write transactions after a
+// given number of successful ones, simulating a migration that is killed.
+type interruptedBackend struct {
+	kvdb.Backend
+
+	numUpdates int
+}
+
+func (b *interruptedBackend) Update(f func(tx kvdb.RwTx) error,
+	reset func()) error {
+
+	if b.numUpdates == 0 {
+		return errInterrupted
+	}
+	b.numUpdates--
+
+	return b.Backend.Update(f, reset)
+}
+
+// makeSourceDB creates a bolt DB with nested buckets, bucket sequences and
+// empty values.
+func makeSourceDB(t *testing.T) kvdb.Backend {
+	t.Helper()
+
+	db, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+		DBPath:         t.TempDir(),
+		DBFileName:     "source.db",
+		NoFreelistSync: true,
+		DBTimeout:      kvdb.DefaultDBTimeout,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		for i := 0; i < 3; i++ {
+			top, err := tx.CreateTopLevelBucket(
+				[]byte(fmt.Sprintf("top-%d", i)),
+			)
+			if err != nil {
+				return err
+			}
+
+			if err := top.SetSequence(uint64(i * 100)); err != nil {
+				return err
+			}
+
+			for j := 0; j < 20; j++ {
+				err := top.Put(
+					[]byte(fmt.Sprintf("key-%02d", j)),
+					[]byte(fmt.Sprintf("value-%d-%d", i, j)),
+				)
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := top.Put([]byte("empty"), []byte{}); err != nil {
+				return err
+			}
+
+			nested, err := top.CreateBucket([]byte("nested"))
+			if err != nil {
+				return err
+			}
+
+			if _, err := nested.NextSequence(); err != nil {
+				return err
+			}
+
+			for j := 0; j < 10; j++ {
+				err := nested.Put(
+					[]byte{byte(j)}, []byte{byte(i), byte(j)},
+				)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = nested.CreateBucket([]byte("deep"))
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.CreateTopLevelBucket([]byte("empty-bucket"))
+
+		return err
+	}, func() {})
+	require.NoError(t, err)
+
+	return db
+}
+
+// makeDestDB creates an empty DB of the backend type selected by the build
+// tags.
+func makeDestDB(t *testing.T) kvdb.Backend {
+	t.Helper()
+
+	db, cleanup, err := kvdb.GetTestBackend(t.TempDir(), "dest")
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	return db
+}
+
+// walkAll returns the paths of all items of the DB after the given resume
+// position.
+func walkAll(t *testing.T, db kvdb.Backend, after [][]byte) [][][]byte {
+	t.Helper()
+
+	var paths [][][]byte
+	err := kvdb.View(db, func(tx kvdb.RTx) error {
+		return walk(tx, after, func(path [][]byte, _ []byte, _ bool,
+			_ uint64) error {
+
+			paths = append(paths, path)
+
+			return nil
+		})
+	}, func() {
+		paths = nil
+	})
+	require.NoError(t, err)
+
+	return paths
+}
+
+// TestWalkResume tests that resuming a walk at any position visits exactly the
+// items after that position.
+func TestWalkResume(t *testing.T) {
+	t.Parallel()
+
+	db := makeSourceDB(t)
+
+	paths := walkAll(t, db, nil)
+	require.Len(t, paths, 3*(1+20+1+1+10+1)+1)
+
+	for i := 1; i < len(paths); i++ {
+		require.Equal(t, -1, comparePaths(paths[i-1], paths[i]))
+	}
+
+	for i, path := range paths {
+		remaining := walkAll(t, db, path)
+		require.Len(t, remaining, len(paths)-i-1)
+		if len(remaining) > 0 {
+			require.Equal(t, paths[i+1:], remaining, i)
+		}
+	}
+}
+
+// TestMigrate tests that a migration copies the whole DB, tombstones the
+// source and that running it again is a no-op.
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	src := makeSourceDB(t)
+	dst := makeDestDB(t)
+
+	srcSummary, err := Checksum(src)
+	require.NoError(t, err)
+	require.EqualValues(t, 3*3+1, srcSummary.NumBuckets)
+	require.EqualValues(t, 3*31, srcSummary.NumKeys)
+
+	cfg := &Config{BatchSize: 7, MaxBatchBytes: DefaultMaxBatchBytes}
+	require.NoError(t, Migrate(context.Background(), "test", src, dst, cfg))
+
+	dstSummary, err := Checksum(dst)
+	require.NoError(t, err)
+	require.Equal(t, srcSummary, dstSummary)
+
+	// The sequence numbers must have been copied.
+	err = kvdb.View(dst, func(tx kvdb.RTx) error {
+		top := tx.ReadBucket([]byte("top-2"))
+		require.EqualValues(t, 200, bucketSequence(top))
+
+		nested := top.NestedReadBucket([]byte("nested"))
+		require.EqualValues(t, 1, bucketSequence(nested))
+
+		require.NotNil(t, top.Get([]byte("empty")))
+
+		// The meta bucket must have been removed.
+		require.Nil(t, tx.ReadBucket(metaBucket))
+
+		return nil
+	}, func() {})
+	require.NoError(t, err)
+
+	// The source must now be tombstoned, which makes channeldb refuse it.
+	tombstoned, err := isTombstoned(src)
+	require.NoError(t, err)
+	require.True(t, tombstoned)
+
+	err = kvdb.View(src, channeldb.EnsureNoTombstone, func() {})
+	require.Error(t, err)
+
+	// Running the migration again must not change anything.
+	require.NoError(t, Migrate(context.Background(), "test", src, dst, cfg))
+
+	dstSummary, err = Checksum(dst)
+	require.NoError(t, err)
+	require.Equal(t, srcSummary, dstSummary)
+}
+
+// TestMigrateResume tests that an interrupted migration continues where it
+// left off.
+func TestMigrateResume(t *testing.T) {
+	t.Parallel()
+
+	src := makeSourceDB(t)
+	dst := makeDestDB(t)
+	cfg := &Config{BatchSize: 10, MaxBatchBytes: DefaultMaxBatchBytes}
+
+	total := len(walkAll(t, src, nil))
+
+	// We let the meta bucket and two batches be written before we
+	// interrupt the migration.
+	interrupted := &interruptedBackend{Backend: dst, numUpdates: 3}
+	err := Migrate(context.Background(), "test", src, interrupted, cfg)
+	require.ErrorIs(t, err, errInterrupted)
+
+	tombstoned, err := isTombstoned(src)
+	require.NoError(t, err)
+	require.False(t, tombstoned)
+
+	// The next attempt must only copy the remaining items.
+	numItems, err := Copy(context.Background(), src, dst, cfg)
+	require.NoError(t, err)
+	require.EqualValues(t, total-2*cfg.BatchSize, numItems)
+
+	_, err = Verify(src, dst)
+	require.NoError(t, err)
+
+	// A cancelled context stops the migration before the next batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = Migrate(ctx, "test", makeSourceDB(t), makeDestDB(t), cfg)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+// TestMigrateDestinationNotEmpty tests that we refuse to migrate into a DB
+// that already contains data.
+func TestMigrateDestinationNotEmpty(t *testing.T) {
+	t.Parallel()
+
+	src := makeSourceDB(t)
+	dst := makeDestDB(t)
+
+	err := kvdb.Update(dst, func(tx kvdb.RwTx) error {
+		_, err := tx.CreateTopLevelBucket([]byte("existing"))
+		return err
+	}, func() {})
+	require.NoError(t, err)
+
+	err = Migrate(context.Background(), "test", src, dst, DefaultConfig())
+	require.ErrorIs(t, err, ErrDestinationNotEmpty)
+}
+
+// TestVerifyMismatch tests that a destination that differs from the source in
+// any way fails verification.
+func TestVerifyMismatch(t *testing.T) {
+	t.Parallel()
+
+	modifications := []func(tx kvdb.RwTx) error{
+		func(tx kvdb.RwTx) error {
+			return tx.ReadWriteBucket([]byte("top-1")).Put(
+				[]byte("key-03"), []byte("modified"),
+			)
+		},
+		func(tx kvdb.RwTx) error {
+			return tx.ReadWriteBucket([]byte("top-1")).Delete(
+				[]byte("key-03"),
+			)
+		},
+		func(tx kvdb.RwTx) error {
+			return tx.ReadWriteBucket([]byte("top-0")).
+				NestedReadWriteBucket([]byte("nested")).
+				SetSequence(42)
+		},
+		func(tx kvdb.RwTx) error {
+			_, err := tx.CreateTopLevelBucket([]byte("extra"))
+			return err
+		},
+	}
+
+	for i, modify := range modifications {
+		src := makeSourceDB(t)
+		dst := makeDestDB(t)
+
+		_, err := Copy(context.Background(), src, dst, DefaultConfig())
+		require.NoError(t, err)
+
+		_, err = Verify(src, dst)
+		require.NoError(t, err)
+
+		require.NoError(t, kvdb.Update(dst, modify, func() {}))
+
+		_, err = Verify(src, dst)
+		require.ErrorIs(t, err, ErrChecksumMismatch, i)
+	}
+}
+
+// TestOpenBoltSource tests that missing and locked source DBs are detected.
+func TestOpenBoltSource(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, err := OpenBoltSource(dir, "missing.db", time.Second)
+	require.ErrorIs(t, err, ErrSourceNotFound)
+	require.NoFileExists(t, filepath.Join(dir, "missing.db"))
+
+	db, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
+		DBPath:     dir,
+		DBFileName: "running.db",
+		DBTimeout:  kvdb.DefaultDBTimeout,
+	})
+	require.NoError(t, err)
+
+	_, err = OpenBoltSource(dir, "running.db", 100*time.Millisecond)
+	require.ErrorIs(t, err, ErrSourceLocked)
+
+	require.NoError(t, db.Close())
+
+	db, err = OpenBoltSource(dir, "running.db", time.Second)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+}
@@ -124,34 +124,32 @@ func (c *ChanSeries) UpdatesInHorizon(chain chainhash.Hash,
 			continue
 		}
 
-		chanAnn, edge1, edge2, err := netann.CreateChanAnnouncement(
-			channel.Info.AuthProof, channel.Info, channel.Policy1,
-			channel.Policy2,
-		)
+		chanAnn, edge1, edge2, err := createChanAnnouncement(channel)
 		if err != nil {
 			return nil, err
 		}
 
 		updates = append(updates, chanAnn)
-		if edge1 != nil {
+		for _, edge := range []lnwire.Message{edge1, edge2} {
+			if edge == nil {
+				continue
+			}
+
 			// We don't want to send channel updates that don't
 			// conform to the spec (anymore).
-			err := routing.ValidateChannelUpdateFields(0, edge1)
-			if err != nil {
-				log.Errorf("not sending invalid channel "+
-					"update %v: %v", edge1, err)
-			} else {
-				updates = append(updates, edge1)
-			}
-		}
-		if edge2 != nil {
-			err := routing.ValidateChannelUpdateFields(0, edge2)
-			if err != nil {
-				log.Errorf("not sending invalid channel "+
-					"update %v: %v", edge2, err)
-			} else {
-				updates = append(updates, edge2)
+			if upd, ok := edge.(*lnwire.ChannelUpdate); ok {
+				err := routing.ValidateChannelUpdateFields(
+					0, upd,
+				)
+				if err != nil {
+					log.Errorf("not sending invalid "+
+						"channel update %v: %v", upd,
+						err)
+					continue
+				}
 			}
+
+			updates = append(updates, edge)
 		}
 	}
 
@@ -268,10 +266,7 @@ func (c *ChanSeries) FetchChanAnns(chain chainhash.Hash,
 			continue
 		}
 
-		chanAnn, edge1, edge2, err := netann.CreateChanAnnouncement(
-			channel.Info.AuthProof, channel.Info, channel.Policy1,
-			channel.Policy2,
-		)
+		chanAnn, edge1, edge2, err := createChanAnnouncement(channel)
 		if err != nil {
 			return nil, err
 		}
@@ -356,6 +351,61 @@ func (c *ChanSeries) FetchChanUpdates(chain chainhash.Hash,
 	return chanUpdates, nil
 }
 
+// createChanAnnouncement creates the announcement of the passed channel along
+// with the updates of its policies, using the taproot gossip messages for
+// channels that were announced with them. The returned updates are nil if the
+// respective policy is unknown.
+func createChanAnnouncement(channel channeldb.ChannelEdge) (lnwire.Message,
+	lnwire.Message, lnwire.Message, error) {
+
+	var edge1, edge2 lnwire.Message
+	if channel.Info.Version != lnwire.GossipVersion2 {
+		chanAnn, e1, e2, err := netann.CreateChanAnnouncement(
+			channel.Info.AuthProof, channel.Info, channel.Policy1,
+			channel.Policy2,
+		)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if e1 != nil {
+			edge1 = e1
+		}
+		if e2 != nil {
+			edge2 = e2
+		}
+
+		return chanAnn, edge1, edge2, nil
+	}
+
+	// The policies of a taproot channel may still be the ones that were
+	// sent as a ChannelUpdate before the channel was announced, which
+	// can't be relayed along with its ChannelAnnouncement2.
+	policy1, policy2 := channel.Policy1, channel.Policy2
+	if policy1 != nil && policy1.Version != lnwire.GossipVersion2 {
+		policy1 = nil
+	}
+	if policy2 != nil && policy2.Version != lnwire.GossipVersion2 {
+		policy2 = nil
+	}
+
+	chanAnn, e1, e2, err := netann.CreateChanAnnouncement2(
+		channel.Info.AuthProof, channel.Info, policy1, policy2,
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if e1 != nil {
+		edge1 = e1
+	}
+	if e2 != nil {
+		edge2 = e2
+	}
+
+	return chanAnn, edge1, edge2, nil
+}
+
 // A compile-time assertion to ensure that ChanSeries meets the
 // ChannelGraphTimeSeries interface.
 var _ ChannelGraphTimeSeries = (*ChanSeries)(nil)
@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"bytes"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"golang.org/x/time/rate"
+)
+
+// BandwidthBudget describes the number of gossip bytes we're willing to
+// exchange with a single peer. A zero rate disables the budget for that
+// direction.
+type BandwidthBudget struct {
+	// InboundBytesPerSec is the rate at which we'll accept gossip from a
+	// peer. Once exceeded, we'll pause the peer's real-time gossip until
+	// the budget has recovered, and delay our next sync query.
+	InboundBytesPerSec uint64
+
+	// OutboundBytesPerSec is the rate at which we'll send gossip to a
+	// peer. Once exceeded, relayed announcements are dropped and replies
+	// to the peer's queries are delayed.
+	OutboundBytesPerSec uint64
+
+	// BurstBytes is the number of bytes that can be exchanged in either
+	// direction before the budget starts to apply. If zero, a burst of
+	// one second worth of traffic, but at least a single message, is
+	// used.
+	BurstBytes uint64
+}
+
+// byteBudget is a token bucket measured in bytes. A nil byteBudget is an
+// unlimited one.
+type byteBudget struct {
+	limiter *rate.Limiter
+}
+
+// newByteBudget returns a byteBudget that refills at the given rate, or nil
+// if the rate is zero.
+func newByteBudget(bytesPerSec, burst uint64) *byteBudget {
+	if bytesPerSec == 0 {
+		return nil
+	}
+
+	// Make sure the burst always allows for at least a single message,
+	// otherwise we'd never be able to charge a full sized one.
+	if burst == 0 {
+		burst = bytesPerSec
+	}
+	if burst < lnwire.MaxMsgBody {
+		burst = lnwire.MaxMsgBody
+	}
+
+	return &byteBudget{
+		limiter: rate.NewLimiter(rate.Limit(bytesPerSec), int(burst)),
+	}
+}
+
+// clamp ensures the number of bytes to charge doesn't exceed the budget's
+// burst, which would otherwise cause the limiter to reject the reservation.
+func (b *byteBudget) clamp(n int) int {
+	if burst := b.limiter.Burst(); n > burst {
+		return burst
+	}
+
+	return n
+}
+
+// charge unconditionally takes n bytes from the budget and returns how long
+// it'll take for the budget to recover from any resulting deficit.
+func (b *byteBudget) charge(n int) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	return b.limiter.ReserveN(time.Now(), b.clamp(n)).Delay()
+}
+
+// allow takes n bytes from the budget only if they're available right away.
+func (b *byteBudget) allow(n int) bool {
+	if b == nil {
+		return true
+	}
+
+	return b.limiter.AllowN(time.Now(), b.clamp(n))
+}
+
+// recoveryDelay returns how long it'll take for the budget to recover from
+// its current deficit, if any.
+func (b *byteBudget) recoveryDelay() time.Duration {
+	return b.charge(0)
+}
+
+// wait blocks until n bytes can be taken from the budget, or the quit channel
+// is closed.
+func (b *byteBudget) wait(n int, quit <-chan struct{}) error {
+	delay := b.charge(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-quit:
+		return ErrGossipSyncerExiting
+	}
+}
+
+// SyncerStats is a snapshot of the gossip traffic exchanged with a peer
+// through its GossipSyncer.
+type SyncerStats struct {
+	// MsgsReceived is the number of gossip messages received from the
+	// peer.
+	MsgsReceived uint64
+
+	// BytesReceived is the number of gossip bytes received from the peer.
+	BytesReceived uint64
+
+	// MsgsSent is the number of gossip messages sent to the peer.
+	MsgsSent uint64
+
+	// BytesSent is the number of gossip bytes sent to the peer.
+	BytesSent uint64
+
+	// StaleMsgsFiltered is the number of announcements received from the
+	// peer that were dropped before their signatures were verified since
+	// we already knew of them or of a more recent version.
+	StaleMsgsFiltered uint64
+
+	// OutOfScopeMsgs is the number of channel announcements received from
+	// the peer that were dropped for being outside of the subgraph we
+	// sync, either due to their distance from our node or their capacity.
+	OutOfScopeMsgs uint64
+
+	// OutboundMsgsDropped is the number of announcements we didn't relay
+	// to the peer due to its outbound budget being exhausted.
+	OutboundMsgsDropped uint64
+
+	// InboundThrottles is the number of times we've paused the peer's
+	// real-time gossip due to its inbound budget being exhausted.
+	InboundThrottles uint64
+}
+
+// syncerStats holds the counters behind SyncerStats.
+type syncerStats struct {
+	msgsReceived        atomic.Uint64
+	bytesReceived       atomic.Uint64
+	msgsSent            atomic.Uint64
+	bytesSent           atomic.Uint64
+	staleMsgsFiltered   atomic.Uint64
+	outOfScopeMsgs      atomic.Uint64
+	outboundMsgsDropped atomic.Uint64
+	inboundThrottles    atomic.Uint64
+}
+
+// snapshot returns the current value of all counters.
+func (s *syncerStats) snapshot() SyncerStats {
+	return SyncerStats{
+		MsgsReceived:        s.msgsReceived.Load(),
+		BytesReceived:       s.bytesReceived.Load(),
+		MsgsSent:            s.msgsSent.Load(),
+		BytesSent:           s.bytesSent.Load(),
+		StaleMsgsFiltered:   s.staleMsgsFiltered.Load(),
+		OutOfScopeMsgs:      s.outOfScopeMsgs.Load(),
+		OutboundMsgsDropped: s.outboundMsgsDropped.Load(),
+		InboundThrottles:    s.inboundThrottles.Load(),
+	}
+}
+
+// msgSize returns the number of bytes the message takes up on the wire,
+// excluding the transport's framing.
+func msgSize(msg lnwire.Message) int {
+	// Encoding the channel range messages packs their TLV records into
+	// their extra data, so we'll encode a copy to leave them untouched.
+	switch m := msg.(type) {
+	case *lnwire.QueryChannelRange:
+		c := *m
+		msg = &c
+
+	case *lnwire.ReplyChannelRange:
+		c := *m
+		msg = &c
+	}
+
+	var b bytes.Buffer
+	n, err := lnwire.WriteMessage(&b, msg, 0)
+	if err != nil {
+		log.Debugf("Unable to determine size of %v: %v", msg.MsgType(),
+			err)
+		return 0
+	}
+
+	return n
+}
+
+// isAnnouncement returns true if the message is gossip a peer sends us
+// unsolicited once we've set a gossip filter, rather than as a reply to one
+// of our queries.
+func isAnnouncement(msg lnwire.Message) bool {
+	switch msg.(type) {
+	case *lnwire.ChannelAnnouncement, *lnwire.ChannelAnnouncement2,
+		*lnwire.ChannelUpdate, *lnwire.ChannelUpdate2,
+		*lnwire.NodeAnnouncement:
+
+		return true
+
+	default:
+		return false
+	}
+}
+
+// relayPriority returns the priority with which a message is relayed to a
+// peer, lower values being sent first. When a peer's outbound budget is
+// limited this ensures channel announcements precede the updates that depend
+// on them. Our own announcements don't need a priority, as they're sent to
+// all peers regardless of their budget.
+func relayPriority(msg msgWithSenders) int {
+	switch msg.msg.(type) {
+	case *lnwire.ChannelAnnouncement, *lnwire.ChannelAnnouncement2:
+		return 0
+
+	case *lnwire.ChannelUpdate, *lnwire.ChannelUpdate2:
+		return 1
+
+	default:
+		return 2
+	}
+}
+
+// sortByRelayPriority returns a copy of the given messages sorted by their
+// relay priority, keeping the original order of messages of equal priority.
+func sortByRelayPriority(msgs []msgWithSenders) []msgWithSenders {
+	sorted := make([]msgWithSenders, len(msgs))
+	copy(sorted, msgs)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return relayPriority(sorted[i]) < relayPriority(sorted[j])
+	})
+
+	return sorted
+}
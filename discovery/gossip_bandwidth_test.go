@@ -0,0 +1,193 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+// TestByteBudget tests that a byteBudget allows bytes up to its burst, and
+// reports the time needed to recover from a deficit.
+func TestByteBudget(t *testing.T) {
+	t.Parallel()
+
+	// A nil budget is unlimited.
+	var unlimited *byteBudget
+	require.True(t, unlimited.allow(lnwire.MaxMsgBody))
+	require.Zero(t, unlimited.charge(lnwire.MaxMsgBody))
+	require.Zero(t, unlimited.recoveryDelay())
+	require.Nil(t, newByteBudget(0, 1000))
+
+	// The burst should always allow for at least a full sized message.
+	budget := newByteBudget(1000, 1)
+	require.Equal(t, lnwire.MaxMsgBody, budget.limiter.Burst())
+
+	// We should be able to spend the full burst right away, after which
+	// further bytes are no longer allowed.
+	require.True(t, budget.allow(lnwire.MaxMsgBody))
+	require.False(t, budget.allow(1))
+	require.Zero(t, budget.recoveryDelay())
+
+	// Charging bytes regardless of the budget should put it in a deficit
+	// it takes time to recover from. Charges beyond the burst are clamped.
+	delay := budget.charge(2 * lnwire.MaxMsgBody)
+	require.InDelta(
+		t, float64(lnwire.MaxMsgBody)/1000, delay.Seconds(), 1,
+	)
+	require.Greater(t, budget.recoveryDelay(), time.Duration(0))
+
+	// Waiting on the exhausted budget should return once we quit.
+	quit := make(chan struct{})
+	close(quit)
+	require.ErrorIs(t, budget.wait(1, quit), ErrGossipSyncerExiting)
+}
+
+// TestSortByRelayPriority tests that channel announcements are relayed first,
+// followed by channel updates and node announcements, while keeping the order
+// of messages of equal priority.
+func TestSortByRelayPriority(t *testing.T) {
+	t.Parallel()
+
+	var (
+		nodeAnn1  = &lnwire.NodeAnnouncement{Timestamp: 1}
+		nodeAnn2  = &lnwire.NodeAnnouncement{Timestamp: 2}
+		chanAnn1  = &lnwire.ChannelAnnouncement{}
+		chanAnn2  = &lnwire.ChannelAnnouncement2{}
+		update1   = &lnwire.ChannelUpdate{Timestamp: 1}
+		update2   = &lnwire.ChannelUpdate2{}
+		unchanged = []msgWithSenders{
+			{msg: nodeAnn1},
+			{msg: update1},
+			{msg: chanAnn1},
+			{msg: nodeAnn2},
+			{msg: chanAnn2},
+			{msg: update2},
+		}
+	)
+
+	sorted := sortByRelayPriority(unchanged)
+
+	var order []lnwire.Message
+	for _, msg := range sorted {
+		order = append(order, msg.msg)
+	}
+	require.Equal(t, []lnwire.Message{
+		chanAnn1, chanAnn2, update1, update2, nodeAnn1, nodeAnn2,
+	}, order)
+
+	// The original slice should be left untouched.
+	require.Equal(t, nodeAnn1, unchanged[0].msg)
+}
+
+// TestGossipSyncerFilterGossipMsgsOutboundBudget tests that once a peer's
+// outbound budget is exhausted, the remaining announcements are dropped in
+// favor of the ones with a higher relay priority.
+func TestGossipSyncerFilterGossipMsgsOutboundBudget(t *testing.T) {
+	t.Parallel()
+
+	msgChan, syncer, _ := newTestSyncer(
+		lnwire.NewShortChanIDFromInt(10), defaultEncoding,
+		defaultChunkSize,
+	)
+	syncer.remoteUpdateHorizon = &lnwire.GossipTimestampRange{
+		FirstTimestamp: unixStamp(25000),
+		TimestampRange: uint32(1000),
+	}
+
+	newNodeAnn := func(timestamp int64) *lnwire.NodeAnnouncement {
+		return &lnwire.NodeAnnouncement{
+			Features:  lnwire.NewRawFeatureVector(),
+			Timestamp: unixStamp(timestamp),
+		}
+	}
+	remoteAnn1 := newNodeAnn(25001)
+	remoteAnn2 := newNodeAnn(25002)
+	update := &lnwire.ChannelUpdate{
+		ShortChannelID: lnwire.NewShortChanIDFromInt(1),
+		Timestamp:      unixStamp(25003),
+	}
+
+	// We'll limit the budget to a single update, so that only the channel
+	// update should make it through even though it was added last.
+	size := msgSize(update)
+	syncer.outboundBudget = &byteBudget{
+		limiter: rate.NewLimiter(rate.Limit(1), size),
+	}
+
+	syncer.FilterGossipMsgs(
+		msgWithSenders{msg: remoteAnn1},
+		msgWithSenders{msg: remoteAnn2},
+		msgWithSenders{msg: update},
+	)
+
+	select {
+	case msgs := <-msgChan:
+		require.Equal(t, []lnwire.Message{update}, msgs)
+
+	case <-time.After(time.Second):
+		t.Fatalf("expected channel update to be sent")
+	}
+
+	stats := syncer.Stats()
+	require.EqualValues(t, 2, stats.OutboundMsgsDropped)
+	require.EqualValues(t, 1, stats.MsgsSent)
+	require.EqualValues(t, size, stats.BytesSent)
+
+	// With the budget exhausted, none of the remote announcements should
+	// be relayed either.
+	syncer.FilterGossipMsgs(msgWithSenders{msg: remoteAnn1})
+
+	select {
+	case msgs := <-msgChan:
+		t.Fatalf("received message but shouldn't have: %v", msgs)
+
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.EqualValues(t, 3, syncer.Stats().OutboundMsgsDropped)
+}
+
+// TestGossipSyncerRecordLocalSend tests that our own announcements, which are
+// broadcast outside of the syncer, are accounted for in the syncer's stats and
+// outbound budget.
+func TestGossipSyncerRecordLocalSend(t *testing.T) {
+	t.Parallel()
+
+	msgChan, syncer, _ := newTestSyncer(
+		lnwire.NewShortChanIDFromInt(10), defaultEncoding,
+		defaultChunkSize,
+	)
+	syncer.remoteUpdateHorizon = &lnwire.GossipTimestampRange{
+		FirstTimestamp: unixStamp(25000),
+		TimestampRange: uint32(1000),
+	}
+
+	localAnn := &lnwire.NodeAnnouncement{
+		Features:  lnwire.NewRawFeatureVector(),
+		Timestamp: unixStamp(25001),
+	}
+	size := msgSize(localAnn)
+	syncer.outboundBudget = &byteBudget{
+		limiter: rate.NewLimiter(rate.Limit(1), size),
+	}
+
+	// Our own announcement is always sent, and uses up the budget.
+	syncer.recordLocalSend(localAnn)
+
+	stats := syncer.Stats()
+	require.EqualValues(t, 1, stats.MsgsSent)
+	require.EqualValues(t, size, stats.BytesSent)
+
+	// So there's no budget left to relay a remote announcement.
+	syncer.FilterGossipMsgs(msgWithSenders{msg: localAnn})
+
+	select {
+	case msgs := <-msgChan:
+		t.Fatalf("received message but shouldn't have: %v", msgs)
+
+	case <-time.After(10 * time.Millisecond):
+	}
+	require.EqualValues(t, 1, syncer.Stats().OutboundMsgsDropped)
+}
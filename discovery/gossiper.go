@@ -20,6 +20,7 @@ import (
 	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lnpeer"
@@ -256,6 +257,15 @@ type Config struct {
 	// here?
 	AnnSigner lnwallet.MessageSigner
 
+	// SchnorrSigner is used to sign the ChannelUpdate2 messages of our
+	// taproot channels with our node key.
+	SchnorrSigner netann.SchnorrMessageSigner
+
+	// MuSig2Signer is used to create the MuSig2 partial signatures of our
+	// node and bitcoin keys over the ChannelAnnouncement2 messages of our
+	// taproot channels.
+	MuSig2Signer input.MuSig2Signer
+
 	// NumActiveSyncers is the number of peers for which we should have
 	// active syncers with. After reaching NumActiveSyncers, any future
 	// gossip syncers will be passive.
@@ -326,6 +336,12 @@ type Config struct {
 	FindBaseByAlias func(alias lnwire.ShortChannelID) (
 		lnwire.ShortChannelID, error)
 
+	// GetAliases returns the aliases stored under the given base SCID of
+	// an option-scid-alias channel. Together with FindBaseByAlias, this
+	// is used to determine whether the funding manager has already
+	// processed the channel after six confirmations.
+	GetAliases func(base lnwire.ShortChannelID) []lnwire.ShortChannelID
+
 	// GetAlias allows the gossiper to look up the peer's alias for a given
 	// ChannelID. This is used to sign updates for them if the channel has
 	// no AuthProof and the option-scid-alias feature bit was negotiated.
@@ -340,6 +356,18 @@ type Config struct {
 	// updates for a channel and returns true if the channel should be
 	// considered a zombie based on these timestamps.
 	IsStillZombieChannel func(time.Time, time.Time) bool
+
+	// Bandwidth is the budget of gossip bytes we're willing to exchange
+	// with each peer.
+	Bandwidth BandwidthBudget
+
+	// MaxSyncHops, if non-zero, restricts the channels we accept from the
+	// network to the subgraph within this number of hops of our own node.
+	MaxSyncHops uint32
+
+	// ForEachChannel iterates over all channels within our graph. It's
+	// used to compute the subgraph within MaxSyncHops of our node.
+	ForEachChannel ForEachChannelFunc
 }
 
 // processedNetworkMsg is a wrapper around networkMsg and a boolean. It is
@@ -490,6 +518,18 @@ type AuthenticatedGossiper struct {
 	// AuthenticatedGossiper lock.
 	chanUpdateRateLimiter map[uint64][2]*rate.Limiter
 
+	// chanAnn2Sessions tracks the MuSig2 signing sessions of the
+	// ChannelAnnouncement2 messages of our taproot channels that are being
+	// announced, indexed by their short channel ID.
+	//
+	// NOTE: This map is only accessed by the networkHandler goroutine, as
+	// announcement signatures are processed serially.
+	chanAnn2Sessions map[lnwire.ShortChannelID]*chanAnn2State
+
+	// subgraph restricts the channels we accept from the network to those
+	// within cfg.MaxSyncHops of our own node. It's nil if unrestricted.
+	subgraph *subgraphFilter
+
 	sync.Mutex
 }
 
@@ -512,6 +552,13 @@ func New(cfg Config, selfKeyDesc *keychain.KeyDescriptor) *AuthenticatedGossiper
 			maxRejectedUpdates,
 		),
 		chanUpdateRateLimiter: make(map[uint64][2]*rate.Limiter),
+		chanAnn2Sessions: make(
+			map[lnwire.ShortChannelID]*chanAnn2State,
+		),
+		subgraph: newSubgraphFilter(
+			route.NewVertex(selfKeyDesc.PubKey), cfg.MaxSyncHops,
+			cfg.ForEachChannel,
+		),
 	}
 
 	gossiper.syncMgr = newSyncManager(&SyncManagerCfg{
@@ -525,6 +572,7 @@ func New(cfg Config, selfKeyDesc *keychain.KeyDescriptor) *AuthenticatedGossiper
 		BestHeight:              gossiper.latestHeight,
 		PinnedSyncers:           cfg.PinnedSyncers,
 		IsStillZombieChannel:    cfg.IsStillZombieChannel,
+		Bandwidth:               cfg.Bandwidth,
 	})
 
 	gossiper.reliableSender = newReliableSender(&reliableSenderCfg{
@@ -778,6 +826,13 @@ func (d *AuthenticatedGossiper) ProcessRemoteAnnouncement(msg lnwire.Message,
 
 	errChan := make(chan error, 1)
 
+	// If the peer has a gossip syncer, we'll account for the message in
+	// its stats and inbound budget.
+	syncer, hasSyncer := d.syncMgr.GossipSyncer(peer.PubKey())
+	if hasSyncer {
+		syncer.recordInbound(msg)
+	}
+
 	// For messages in the known set of channel series queries, we'll
 	// dispatch the message directly to the GossipSyncer, and skip the main
 	// processing loop.
@@ -787,8 +842,7 @@ func (d *AuthenticatedGossiper) ProcessRemoteAnnouncement(msg lnwire.Message,
 		*lnwire.ReplyChannelRange,
 		*lnwire.ReplyShortChanIDsEnd:
 
-		syncer, ok := d.syncMgr.GossipSyncer(peer.PubKey())
-		if !ok {
+		if !hasSyncer {
 			log.Warnf("Gossip syncer for peer=%x not found",
 				peer.PubKey())
 
@@ -806,8 +860,7 @@ func (d *AuthenticatedGossiper) ProcessRemoteAnnouncement(msg lnwire.Message,
 	// If a peer is updating its current update horizon, then we'll dispatch
 	// that directly to the proper GossipSyncer.
 	case *lnwire.GossipTimestampRange:
-		syncer, ok := d.syncMgr.GossipSyncer(peer.PubKey())
-		if !ok {
+		if !hasSyncer {
 			log.Warnf("Gossip syncer for peer=%x not found",
 				peer.PubKey())
 
@@ -843,6 +896,37 @@ func (d *AuthenticatedGossiper) ProcessRemoteAnnouncement(msg lnwire.Message,
 			errChan <- ownErr
 			return errChan
 		}
+
+	case *lnwire.ChannelAnnouncement2:
+		ownKey := d.selfKey.SerializeCompressed()
+		ownErr := fmt.Errorf("ignoring remote ChannelAnnouncement2 " +
+			"for own channel")
+
+		// Unlike for the original announcement, the remote peer of a
+		// taproot channel may send us the fully signed announcement of
+		// our channel if it assembled the signature first, so we
+		// only reject it if we don't know of the channel.
+		isOwn := bytes.Equal(m.NodeID1.Val[:], ownKey) ||
+			bytes.Equal(m.NodeID2.Val[:], ownKey)
+		if isOwn && !d.cfg.Router.IsKnownEdge(m.ShortChannelID.Val) {
+			log.Warn(ownErr)
+			errChan <- ownErr
+			return errChan
+		}
+	}
+
+	// Before handing the announcement off to be validated, we'll check
+	// whether it's stale so that we can skip verifying its signatures.
+	if d.isStaleRemoteAnn(msg) {
+		log.Tracef("Ignoring stale %v from peer=%x", msg.MsgType(),
+			peer.PubKey())
+
+		if hasSyncer {
+			syncer.stats.staleMsgsFiltered.Add(1)
+		}
+
+		errChan <- nil
+		return errChan
 	}
 
 	nMsg := &networkMsg{
@@ -867,6 +951,44 @@ func (d *AuthenticatedGossiper) ProcessRemoteAnnouncement(msg lnwire.Message,
 	return nMsg.err
 }
 
+// isStaleRemoteAnn returns true if we already know of the announcement, or of
+// a more recent version of it. Only cheap graph lookups are performed, so that
+// stale announcements can be dropped before their signatures are verified.
+func (d *AuthenticatedGossiper) isStaleRemoteAnn(msg lnwire.Message) bool {
+	switch msg := msg.(type) {
+	case *lnwire.ChannelAnnouncement:
+		return d.cfg.Router.IsKnownEdge(msg.ShortChannelID)
+
+	case *lnwire.ChannelUpdate:
+		// Updates may refer to the channel by one of its aliases, while
+		// the graph is indexed by its confirmed SCID.
+		scid, err := d.cfg.FindBaseByAlias(msg.ShortChannelID)
+		if err != nil {
+			scid = msg.ShortChannelID
+		}
+
+		timestamp := time.Unix(int64(msg.Timestamp), 0)
+
+		return d.cfg.Router.IsStaleEdgePolicy(
+			scid, timestamp, msg.ChannelFlags,
+		)
+
+	case *lnwire.ChannelUpdate2:
+		return d.cfg.Router.IsStaleEdgePolicy2(
+			msg.ShortChannelID.Val, msg.BlockHeight.Val,
+			msg.Direction(),
+		)
+
+	case *lnwire.NodeAnnouncement:
+		timestamp := time.Unix(int64(msg.Timestamp), 0)
+
+		return d.cfg.Router.IsStaleNode(msg.NodeID, timestamp)
+
+	default:
+		return false
+	}
+}
+
 // ProcessLocalAnnouncement sends a new remote announcement message along with
 // the peer that sent the routing message. The announcement will be processed
 // then added to a queue for batched trickled announcement to all connected
@@ -1015,6 +1137,30 @@ func (d *deDupedAnnouncements) addMsg(message networkMsg) {
 		mws.senders[sender] = struct{}{}
 		d.channelAnnouncements[deDupKey] = mws
 
+	// Taproot channel announcements are identified by the short channel
+	// id field as well.
+	case *lnwire.ChannelAnnouncement2:
+		deDupKey := msg.ShortChannelID.Val
+		sender := route.NewVertex(message.source)
+
+		mws, ok := d.channelAnnouncements[deDupKey]
+		if !ok {
+			mws = msgWithSenders{
+				msg:     msg,
+				isLocal: !message.isRemote,
+				senders: make(map[route.Vertex]struct{}),
+			}
+			mws.senders[sender] = struct{}{}
+
+			d.channelAnnouncements[deDupKey] = mws
+
+			return
+		}
+
+		mws.msg = msg
+		mws.senders[sender] = struct{}{}
+		d.channelAnnouncements[deDupKey] = mws
+
 	// Channel updates are identified by the (short channel id,
 	// channelflags) tuple.
 	case *lnwire.ChannelUpdate:
@@ -1027,9 +1173,20 @@ func (d *deDupedAnnouncements) addMsg(message networkMsg) {
 		oldTimestamp := uint32(0)
 		mws, ok := d.channelUpdates[deDupKey]
 		if ok {
+			// If we already have a ChannelUpdate2 for the same
+			// channel direction, then it supersedes this one.
+			oldMsg, isV1 := mws.msg.(*lnwire.ChannelUpdate)
+			if !isV1 {
+				log.Debugf("Ignored network message "+
+					"superseded by %v: peer=%v, msg=%s",
+					mws.msg.MsgType(), message.peer,
+					msg.MsgType())
+				return
+			}
+
 			// If we already have seen this message, record its
 			// timestamp.
-			oldTimestamp = mws.msg.(*lnwire.ChannelUpdate).Timestamp
+			oldTimestamp = oldMsg.Timestamp
 		}
 
 		// If we already had this message with a strictly newer
@@ -1067,6 +1224,58 @@ func (d *deDupedAnnouncements) addMsg(message networkMsg) {
 		mws.senders[sender] = struct{}{}
 		d.channelUpdates[deDupKey] = mws
 
+	// Taproot channel updates are identified by the (short channel id,
+	// direction) tuple, and ordered by their block height.
+	case *lnwire.ChannelUpdate2:
+		sender := route.NewVertex(message.source)
+		deDupKey := channelUpdateID{
+			msg.ShortChannelID.Val,
+			msg.Direction(),
+		}
+
+		// A ChannelUpdate2 always replaces a ChannelUpdate for the
+		// same channel direction.
+		oldHeight := uint32(0)
+		mws, ok := d.channelUpdates[deDupKey]
+		if ok {
+			oldMsg, isV2 := mws.msg.(*lnwire.ChannelUpdate2)
+			if isV2 {
+				oldHeight = oldMsg.BlockHeight.Val
+			} else {
+				ok = false
+			}
+		}
+
+		// If we already had this message with a strictly newer
+		// block height, then we'll just discard the message we got.
+		if ok && oldHeight > msg.BlockHeight.Val {
+			log.Debugf("Ignored outdated network message: "+
+				"peer=%v, msg=%s", message.peer, msg.MsgType())
+			return
+		}
+
+		// If the message we just got is newer than what we previously
+		// have seen, or this is the first time we see it, then we'll
+		// add it to our map of announcements.
+		if !ok || oldHeight < msg.BlockHeight.Val {
+			mws = msgWithSenders{
+				msg:     msg,
+				isLocal: !message.isRemote,
+				senders: make(map[route.Vertex]struct{}),
+			}
+			mws.senders[sender] = struct{}{}
+
+			d.channelUpdates[deDupKey] = mws
+
+			return
+		}
+
+		// Otherwise, we've seen this exact message before, so we'll
+		// only add the sender to the map of senders.
+		mws.msg = msg
+		mws.senders[sender] = struct{}{}
+		d.channelUpdates[deDupKey] = mws
+
 	// Node announcements are identified by the Vertex field.  Use the
 	// NodeID to create the corresponding Vertex.
 	case *lnwire.NodeAnnouncement:
@@ -1301,6 +1510,13 @@ func (d *AuthenticatedGossiper) sendLocalBatch(annBatch []msgWithSenders) {
 	err := d.cfg.Broadcast(nil, msgsToSend...)
 	if err != nil {
 		log.Errorf("Unable to send local batch announcements: %v", err)
+		return
+	}
+
+	// The announcements bypassed the gossip syncers, so we'll account for
+	// them in the syncers' stats and outbound budgets separately.
+	for _, syncer := range d.syncMgr.GossipSyncers() {
+		syncer.recordLocalSend(msgsToSend...)
 	}
 }
 
@@ -1398,7 +1614,9 @@ func (d *AuthenticatedGossiper) networkHandler() {
 			switch announcement.msg.(type) {
 			// Channel announcement signatures are amongst the only
 			// messages that we'll process serially.
-			case *lnwire.AnnounceSignatures:
+			case *lnwire.AnnounceSignatures,
+				*lnwire.AnnounceSignatures2:
+
 				emittedAnnouncements, _ := d.processNetworkAnnouncement(
 					announcement,
 				)
@@ -1570,6 +1788,12 @@ func (d *AuthenticatedGossiper) isRecentlyRejectedMsg(msg lnwire.Message,
 	case *lnwire.ChannelAnnouncement:
 		scid = m.ShortChannelID.ToUint64()
 
+	case *lnwire.ChannelUpdate2:
+		scid = m.ShortChannelID.Val.ToUint64()
+
+	case *lnwire.ChannelAnnouncement2:
+		scid = m.ShortChannelID.Val.ToUint64()
+
 	default:
 		return false
 	}
@@ -1653,6 +1877,24 @@ func (d *AuthenticatedGossiper) retransmitStaleAnns(now time.Time) error {
 
 	var signedUpdates []lnwire.Message
 	for _, chanToUpdate := range edgesToUpdate {
+		// Taproot channels are announced with the new gossip messages
+		// instead.
+		if chanToUpdate.info.Version == lnwire.GossipVersion2 {
+			chanAnn, chanUpdate, err := d.updateChannel2(
+				chanToUpdate.info, chanToUpdate.edge,
+			)
+			if err != nil {
+				return fmt.Errorf("unable to update channel: "+
+					"%w", err)
+			}
+
+			signedUpdates = append(
+				signedUpdates, chanAnn, chanUpdate,
+			)
+
+			continue
+		}
+
 		// Re-sign and update the channel on disk and retrieve our
 		// ChannelUpdate to broadcast.
 		chanAnn, chanUpdate, err := d.updateChannel(
@@ -1728,6 +1970,26 @@ func (d *AuthenticatedGossiper) processChanPolicyUpdate(
 
 	var chanUpdates []networkMsg
 	for _, edgeInfo := range edgesToUpdate {
+		// Taproot channels are only announced with the new gossip
+		// messages once they're public, so we can broadcast their
+		// update right away.
+		if edgeInfo.Info.Version == lnwire.GossipVersion2 {
+			_, chanUpdate, err := d.updateChannel2(
+				edgeInfo.Info, edgeInfo.Edge,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			chanUpdates = append(chanUpdates, networkMsg{
+				source:   d.selfKey,
+				isRemote: false,
+				msg:      chanUpdate,
+			})
+
+			continue
+		}
+
 		// Now that we've collected all the channels we need to update,
 		// we'll re-sign and update the backing ChannelGraphSource, and
 		// retrieve our ChannelUpdate to broadcast.
@@ -2018,6 +2280,23 @@ func (d *AuthenticatedGossiper) processNetworkAnnouncement(
 	// that the directional information for an already known channel has
 	// been updated.
 	case *lnwire.ChannelUpdate:
+		// Our own taproot channels are announced with the new gossip
+		// messages once they're public, so local updates of their
+		// policy are converted first.
+		if !nMsg.isRemote {
+			update, err := d.localChanUpdate2(msg)
+			if err != nil {
+				log.Error(err)
+				nMsg.err <- err
+				return nil, false
+			}
+			if update != nil {
+				return d.handleChanUpdate2(
+					nMsg, update, schedulerOp,
+				)
+			}
+		}
+
 		return d.handleChanUpdate(nMsg, msg, schedulerOp)
 
 	// A new signature announcement has been received. This indicates
@@ -2026,6 +2305,16 @@ func (d *AuthenticatedGossiper) processNetworkAnnouncement(
 	case *lnwire.AnnounceSignatures:
 		return d.handleAnnSig(nMsg, msg)
 
+	// The taproot channel variants of the messages above.
+	case *lnwire.ChannelAnnouncement2:
+		return d.handleChanAnnouncement2(nMsg, msg, schedulerOp)
+
+	case *lnwire.ChannelUpdate2:
+		return d.handleChanUpdate2(nMsg, msg, schedulerOp)
+
+	case *lnwire.AnnounceSignatures2:
+		return d.handleAnnSig2(nMsg, msg)
+
 	default:
 		err := errors.New("wrong type of the announcement")
 		nMsg.err <- err
@@ -2133,6 +2422,25 @@ func (d *AuthenticatedGossiper) isMsgStale(msg lnwire.Message) bool {
 		// can safely delete the local proof from the database.
 		return chanInfo.AuthProof != nil
 
+	case *lnwire.AnnounceSignatures2:
+		chanInfo, _, _, err := d.cfg.Router.GetChannelByID(
+			msg.ShortChannelID,
+		)
+
+		// Just like above, a missing channel was most likely closed,
+		// and an existing proof means that the signing session is
+		// complete.
+		if err == channeldb.ErrEdgeNotFound {
+			return true
+		}
+		if err != nil {
+			log.Debugf("Unable to retrieve channel=%v from graph: "+
+				"%v", msg.ShortChannelID, err)
+			return false
+		}
+
+		return chanInfo.AuthProof != nil
+
 	case *lnwire.ChannelUpdate:
 		_, p1, p2, err := d.cfg.Router.GetChannelByID(msg.ShortChannelID)
 
@@ -2452,6 +2760,13 @@ func (d *AuthenticatedGossiper) handleChanAnnouncement(nMsg *networkMsg,
 		return nil, true
 	}
 
+	// If we only sync the subgraph around our node, we'll drop channels
+	// outside of it before validating their signatures.
+	if nMsg.isRemote && !d.inSyncScope(nMsg, ann.NodeID1, ann.NodeID2) {
+		nMsg.err <- nil
+		return nil, false
+	}
+
 	// If this is a remote channel announcement, then we'll validate all
 	// the signatures within the proof as it should be well formed.
 	var proof *models.ChannelAuthProof
@@ -2572,6 +2887,12 @@ func (d *AuthenticatedGossiper) handleChanAnnouncement(nMsg *networkMsg,
 				sourceToPub(nMsg.source),
 			)
 			_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+			// Channels that are too small are outside of the
+			// subgraph we sync.
+			if routing.IsError(err, routing.ErrChannelTooSmall) {
+				d.recordOutOfScope(nMsg)
+			}
 		}
 
 		nMsg.err <- err
@@ -2581,12 +2902,100 @@ func (d *AuthenticatedGossiper) handleChanAnnouncement(nMsg *networkMsg,
 	// If err is nil, release the lock immediately.
 	d.channelMtx.Unlock(ann.ShortChannelID.ToUint64())
 
+	// The new channel may have extended the subgraph we sync.
+	d.subgraph.addChannel(ann.NodeID1, ann.NodeID2)
+
 	log.Debugf("Finish adding edge for short_chan_id: %v",
 		ann.ShortChannelID.ToUint64())
 
 	// If we earlier received any ChannelUpdates for this channel, we can
 	// now process them, as the channel is added to the graph.
-	shortChanID := ann.ShortChannelID.ToUint64()
+	d.reprocessPrematureUpdates(ann.ShortChannelID.ToUint64())
+
+	// Channel announcement was successfully processed and now it might be
+	// broadcast to other connected nodes if it was an announcement with
+	// proof (remote).
+	var announcements []networkMsg
+
+	if proof != nil {
+		announcements = append(announcements, networkMsg{
+			peer:     nMsg.peer,
+			isRemote: nMsg.isRemote,
+			source:   nMsg.source,
+			msg:      ann,
+		})
+	}
+
+	nMsg.err <- nil
+
+	log.Debugf("Processed ChannelAnnouncement: peer=%v, short_chan_id=%v",
+		nMsg.peer, ann.ShortChannelID.ToUint64())
+
+	return announcements, true
+}
+
+// inSyncScope returns whether a remote channel between the two nodes is within
+// the subgraph we sync.
+func (d *AuthenticatedGossiper) inSyncScope(nMsg *networkMsg,
+	node1, node2 route.Vertex) bool {
+
+	if d.subgraph.inScope(node1, node2) {
+		return true
+	}
+
+	log.Debugf("Ignoring %v outside of %v hops of our node: peer=%v",
+		nMsg.msg.MsgType(), d.cfg.MaxSyncHops, nMsg.peer)
+
+	d.recordOutOfScope(nMsg)
+
+	return false
+}
+
+// recordOutOfScope accounts for a remote announcement outside of the subgraph
+// we sync in the stats of the syncer of the peer that sent it.
+func (d *AuthenticatedGossiper) recordOutOfScope(nMsg *networkMsg) {
+	if nMsg.peer == nil {
+		return
+	}
+
+	syncer, ok := d.syncMgr.GossipSyncer(nMsg.peer.PubKey())
+	if ok {
+		syncer.stats.outOfScopeMsgs.Add(1)
+	}
+}
+
+// addPrematureChannelUpdate stashes a channel update for a channel that isn't
+// yet known in our graph, so that it can be reprocessed once the channel
+// announcement is.
+func (d *AuthenticatedGossiper) addPrematureChannelUpdate(shortChanID uint64,
+	nMsg *networkMsg) {
+
+	pMsg := &processedNetworkMsg{msg: nMsg}
+
+	earlyMsgs, err := d.prematureChannelUpdates.Get(shortChanID)
+	switch {
+	// Nothing in the cache yet, we can just directly insert this element.
+	case err == cache.ErrElementNotFound:
+		_, _ = d.prematureChannelUpdates.Put(
+			shortChanID, &cachedNetworkMsg{
+				msgs: []*processedNetworkMsg{pMsg},
+			})
+
+	// There's already something in the cache, so we'll combine the set of
+	// messages into a single value.
+	default:
+		msgs := earlyMsgs.msgs
+		msgs = append(msgs, pMsg)
+		_, _ = d.prematureChannelUpdates.Put(
+			shortChanID, &cachedNetworkMsg{
+				msgs: msgs,
+			})
+	}
+}
+
+// reprocessPrematureUpdates reprocesses the channel updates that we received
+// for the given channel before it was added to the graph.
+func (d *AuthenticatedGossiper) reprocessPrematureUpdates(shortChanID uint64) {
 	var channelUpdates []*processedNetworkMsg
 
 	earlyChanUpdates, err := d.prematureChannelUpdates.Get(shortChanID)
@@ -2620,10 +3029,10 @@ func (d *AuthenticatedGossiper) handleChanAnnouncement(nMsg *networkMsg,
 			// Reprocess the message, making sure we return an
 			// error to the original caller in case the gossiper
 			// shuts down.
-			case *lnwire.ChannelUpdate:
-				log.Debugf("Reprocessing ChannelUpdate for "+
-					"shortChanID=%v",
-					msg.ShortChannelID.ToUint64())
+			case *lnwire.ChannelUpdate, *lnwire.ChannelUpdate2:
+				log.Debugf("Reprocessing %v for "+
+					"shortChanID=%v", msg.MsgType(),
+					shortChanID)
 
 				select {
 				case d.networkMsgs <- updMsg:
@@ -2639,27 +3048,31 @@ func (d *AuthenticatedGossiper) handleChanAnnouncement(nMsg *networkMsg,
 			}
 		}(cu.msg)
 	}
+}
 
-	// Channel announcement was successfully processed and now it might be
-	// broadcast to other connected nodes if it was an announcement with
-	// proof (remote).
-	var announcements []networkMsg
+// allowChanUpdate returns whether the rate limit of remote channel updates
+// for the given channel direction allows another update. We'll allow an
+// update per ChannelUpdateInterval with a maximum burst of
+// MaxChannelUpdateBurst.
+func (d *AuthenticatedGossiper) allowChanUpdate(baseScid uint64,
+	direction lnwire.ChanUpdateChanFlags) bool {
 
-	if proof != nil {
-		announcements = append(announcements, networkMsg{
-			peer:     nMsg.peer,
-			isRemote: nMsg.isRemote,
-			source:   nMsg.source,
-			msg:      ann,
-		})
+	// If we haven't seen an update for this channel before, we'll need to
+	// initialize a rate limiter for each direction.
+	d.Lock()
+	rls, ok := d.chanUpdateRateLimiter[baseScid]
+	if !ok {
+		r := rate.Every(d.cfg.ChannelUpdateInterval)
+		b := d.cfg.MaxChannelUpdateBurst
+		rls = [2]*rate.Limiter{
+			rate.NewLimiter(r, b),
+			rate.NewLimiter(r, b),
+		}
+		d.chanUpdateRateLimiter[baseScid] = rls
 	}
+	d.Unlock()
 
-	nMsg.err <- nil
-
-	log.Debugf("Processed ChannelAnnouncement: peer=%v, short_chan_id=%v",
-		nMsg.peer, ann.ShortChannelID.ToUint64())
-
-	return announcements, true
+	return rls[direction&lnwire.ChanUpdateDirection].Allow()
 }
 
 // handleChanUpdate processes a new channel update.
@@ -2790,28 +3203,7 @@ func (d *AuthenticatedGossiper) handleChanUpdate(nMsg *networkMsg,
 		// since we don't have an edge in the graph and if the peer is
 		// not buggy, we should be able to use it once the gossiper
 		// receives the local announcement.
-		pMsg := &processedNetworkMsg{msg: nMsg}
-
-		earlyMsgs, err := d.prematureChannelUpdates.Get(shortChanID)
-		switch {
-		// Nothing in the cache yet, we can just directly insert this
-		// element.
-		case err == cache.ErrElementNotFound:
-			_, _ = d.prematureChannelUpdates.Put(
-				shortChanID, &cachedNetworkMsg{
-					msgs: []*processedNetworkMsg{pMsg},
-				})
-
-		// There's already something in the cache, so we'll combine the
-		// set of messages into a single value.
-		default:
-			msgs := earlyMsgs.msgs
-			msgs = append(msgs, pMsg)
-			_, _ = d.prematureChannelUpdates.Put(
-				shortChanID, &cachedNetworkMsg{
-					msgs: msgs,
-				})
-		}
+		d.addPrematureChannelUpdate(shortChanID, nMsg)
 
 		log.Debugf("Got ChannelUpdate for edge not found in graph"+
 			"(shortChanID=%v), saving for reprocessing later",
@@ -2837,6 +3229,22 @@ func (d *AuthenticatedGossiper) handleChanUpdate(nMsg *networkMsg,
 		return nil, false
 	}
 
+	// Channels that are announced with a ChannelAnnouncement2 can only be
+	// updated with a ChannelUpdate2.
+	if chanInfo.Version == lnwire.GossipVersion2 {
+		err := fmt.Errorf("ignoring ChannelUpdate for taproot "+
+			"channel short_chan_id=%v", shortChanID)
+		log.Error(err)
+		nMsg.err <- err
+
+		key := newRejectCacheKey(
+			chanInfo.ChannelID, sourceToPub(nMsg.source),
+		)
+		_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+		return nil, false
+	}
+
 	// The least-significant bit in the flag on the channel update
 	// announcement tells us "which" side of the channels directed edge is
 	// being updated.
@@ -2854,6 +3262,24 @@ func (d *AuthenticatedGossiper) handleChanUpdate(nMsg *networkMsg,
 		edgeToUpdate = e2
 	}
 
+	// If it's a keep-alive update of an edge we know of, we'll only
+	// propagate one if it's been a day since the previous. This follows our
+	// own heuristic of sending keep-alive updates after the same duration
+	// (see retransmitStaleAnns). As dropping it doesn't affect our state,
+	// we can do so before verifying its signature.
+	isKeepAlive := edgeToUpdate != nil &&
+		IsKeepAliveUpdate(upd, edgeToUpdate)
+	if nMsg.isRemote && isKeepAlive &&
+		timestamp.Sub(edgeToUpdate.LastUpdate) <
+			d.cfg.RebroadcastInterval {
+
+		log.Debugf("Ignoring keep alive update not within %v period "+
+			"for channel %v", d.cfg.RebroadcastInterval,
+			shortChanID)
+		nMsg.err <- nil
+		return nil, false
+	}
+
 	log.Debugf("Validating ChannelUpdate: channel=%v, from node=%x, has "+
 		"edge=%v", chanInfo.ChannelID, pubKey.SerializeCompressed(),
 		edgeToUpdate != nil)
@@ -2874,54 +3300,25 @@ func (d *AuthenticatedGossiper) handleChanUpdate(nMsg *networkMsg,
 
 	// If we have a previous version of the edge being updated, we'll want
 	// to rate limit its updates to prevent spam throughout the network.
-	if nMsg.isRemote && edgeToUpdate != nil {
-		// If it's a keep-alive update, we'll only propagate one if
-		// it's been a day since the previous. This follows our own
-		// heuristic of sending keep-alive updates after the same
-		// duration (see retransmitStaleAnns).
-		timeSinceLastUpdate := timestamp.Sub(edgeToUpdate.LastUpdate)
-		if IsKeepAliveUpdate(upd, edgeToUpdate) {
-			if timeSinceLastUpdate < d.cfg.RebroadcastInterval {
-				log.Debugf("Ignoring keep alive update not "+
-					"within %v period for channel %v",
-					d.cfg.RebroadcastInterval, shortChanID)
-				nMsg.err <- nil
-				return nil, false
-			}
-		} else {
-			// If it's not, we'll allow an update per minute with a
-			// maximum burst of 10. If we haven't seen an update
-			// for this channel before, we'll need to initialize a
-			// rate limiter for each direction.
-			//
-			// Since the edge exists in the graph, we'll create a
-			// rate limiter for chanInfo.ChannelID rather then the
-			// SCID the peer sent. This is because there may be
-			// multiple aliases for a channel and we may otherwise
-			// rate-limit only a single alias of the channel,
-			// instead of the whole channel.
-			baseScid := chanInfo.ChannelID
-			d.Lock()
-			rls, ok := d.chanUpdateRateLimiter[baseScid]
-			if !ok {
-				r := rate.Every(d.cfg.ChannelUpdateInterval)
-				b := d.cfg.MaxChannelUpdateBurst
-				rls = [2]*rate.Limiter{
-					rate.NewLimiter(r, b),
-					rate.NewLimiter(r, b),
-				}
-				d.chanUpdateRateLimiter[baseScid] = rls
-			}
-			d.Unlock()
-
-			if !rls[direction].Allow() {
-				log.Debugf("Rate limiting update for channel "+
-					"%v from direction %x", shortChanID,
-					pubKey.SerializeCompressed())
-				nMsg.err <- nil
-				return nil, false
-			}
-		}
+	// Unless it's a keep-alive update, we'll allow an update per minute
+	// with a maximum burst of 10. If we haven't seen an update for this
+	// channel before, we'll need to initialize a rate limiter for each
+	// direction. This is only done once the signature has been verified,
+	// so that invalid updates can't use up the channel's allowance.
+	//
+	// Since the edge exists in the graph, we'll create a rate limiter for
+	// chanInfo.ChannelID rather then the SCID the peer sent. This is
+	// because there may be multiple aliases for a channel and we may
+	// otherwise rate-limit only a single alias of the channel, instead of
+	// the whole channel.
+	if nMsg.isRemote && edgeToUpdate != nil && !isKeepAlive &&
+		!d.allowChanUpdate(chanInfo.ChannelID, direction) {
+
+		log.Debugf("Rate limiting update for channel %v from "+
+			"direction %x", shortChanID,
+			pubKey.SerializeCompressed())
+		nMsg.err <- nil
+		return nil, false
 	}
 
 	// We'll use chanInfo.ChannelID rather than the peer-supplied
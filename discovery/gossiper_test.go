@@ -181,6 +181,9 @@ func (r *mockGraphSource) AddProof(chanID lnwire.ShortChannelID,
 	}
 
 	info.AuthProof = proof
+	if len(proof.SchnorrSigBytes) != 0 {
+		info.Version = lnwire.GossipVersion2
+	}
 	r.infos[chanIDInt] = info
 
 	return nil
@@ -369,6 +372,30 @@ func (r *mockGraphSource) IsStaleEdgePolicy(chanID lnwire.ShortChannelID,
 	}
 }
 
+// IsStaleEdgePolicy2 returns true if the graph source has a taproot channel
+// policy for the passed channel ID (and flags) that was created at the same or
+// a later block height.
+//
+// NOTE: This method is part of the ChannelGraphSource interface.
+func (r *mockGraphSource) IsStaleEdgePolicy2(chanID lnwire.ShortChannelID,
+	blockHeight uint32, flags lnwire.ChanUpdateChanFlags) bool {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	edges, ok := r.edges[chanID.ToUint64()]
+	if !ok || len(edges) != 2 {
+		return false
+	}
+
+	policy := edges[flags&lnwire.ChanUpdateDirection]
+	if policy.Version != lnwire.GossipVersion2 {
+		return false
+	}
+
+	return policy.BlockHeight >= blockHeight
+}
+
 // MarkEdgeLive clears an edge from our zombie index, deeming it as live.
 //
 // NOTE: This method is part of the ChannelGraphSource interface.
@@ -0,0 +1,177 @@
+package discovery
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/routing/route"
+)
+
+const (
+	// subgraphRefreshInterval is the interval in which we'll recompute the
+	// distance of all nodes in our graph to our own node. In between, the
+	// distances are only updated as new channels are added, so this
+	// ensures that channels that were closed are eventually accounted for.
+	subgraphRefreshInterval = 10 * time.Minute
+)
+
+// ForEachChannelFunc iterates over all channels within our channel graph.
+type ForEachChannelFunc func(cb func(*models.ChannelEdgeInfo,
+	*models.ChannelEdgePolicy, *models.ChannelEdgePolicy) error) error
+
+// subgraphFilter restricts the channels we accept from the network to the
+// subgraph within a maximum number of hops of our own node. A channel is
+// within the subgraph if either of its nodes can be reached from our node in
+// less than the maximum number of hops.
+//
+// NOTE: Channels that aren't connected to the subgraph yet when we receive
+// them are dropped, and will only be picked up by a later historical sync once
+// the subgraph has grown towards them.
+type subgraphFilter struct {
+	// self is our own node, which is always part of the subgraph.
+	self route.Vertex
+
+	// maxHops is the maximum number of hops a node can be away from our
+	// own node for it to be part of the subgraph.
+	maxHops uint32
+
+	// forEachChannel iterates over all channels within our graph.
+	forEachChannel ForEachChannelFunc
+
+	mu sync.Mutex
+
+	// distances holds the number of hops each node within the subgraph
+	// is away from our own node.
+	distances map[route.Vertex]uint32
+
+	// lastRefresh is the last time we computed the distances from
+	// scratch.
+	lastRefresh time.Time
+}
+
+// newSubgraphFilter returns a subgraphFilter for the subgraph within maxHops
+// of our own node. A nil filter, which accepts all channels, is returned if
+// maxHops is zero.
+func newSubgraphFilter(self route.Vertex, maxHops uint32,
+	forEachChannel ForEachChannelFunc) *subgraphFilter {
+
+	if maxHops == 0 || forEachChannel == nil {
+		return nil
+	}
+
+	return &subgraphFilter{
+		self:           self,
+		maxHops:        maxHops,
+		forEachChannel: forEachChannel,
+	}
+}
+
+// inScope returns whether a channel between the two nodes is part of the
+// subgraph we sync.
+func (f *subgraphFilter) inScope(node1, node2 route.Vertex) bool {
+	if f == nil {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.lastRefresh) >= subgraphRefreshInterval {
+		f.refresh()
+	}
+
+	return f.isReachable(node1) || f.isReachable(node2)
+}
+
+// addChannel extends the subgraph with a channel that was just added to our
+// graph, without having to recompute the distances from scratch.
+func (f *subgraphFilter) addChannel(node1, node2 route.Vertex) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.relax(node1, node2)
+	f.relax(node2, node1)
+}
+
+// isReachable returns whether a channel of the node extends no further than
+// the maximum number of hops from our node.
+//
+// NOTE: This must be called with the mutex held.
+func (f *subgraphFilter) isReachable(node route.Vertex) bool {
+	dist, ok := f.distances[node]
+	return ok && dist < f.maxHops
+}
+
+// relax updates the distance of the node at the end of a channel if it's now
+// closer to our node through the channel's other node.
+//
+// NOTE: This must be called with the mutex held.
+func (f *subgraphFilter) relax(from, to route.Vertex) {
+	fromDist, ok := f.distances[from]
+	if !ok || fromDist >= f.maxHops {
+		return
+	}
+
+	toDist, ok := f.distances[to]
+	if !ok || fromDist+1 < toDist {
+		f.distances[to] = fromDist + 1
+	}
+}
+
+// refresh recomputes the distances of all nodes within the subgraph with a
+// breadth-first search from our node.
+//
+// NOTE: This must be called with the mutex held.
+func (f *subgraphFilter) refresh() {
+	f.lastRefresh = time.Now()
+
+	neighbours := make(map[route.Vertex][]route.Vertex)
+	err := f.forEachChannel(func(info *models.ChannelEdgeInfo,
+		_, _ *models.ChannelEdgePolicy) error {
+
+		node1, node2 := info.NodeKey1Bytes, info.NodeKey2Bytes
+		neighbours[node1] = append(neighbours[node1], node2)
+		neighbours[node2] = append(neighbours[node2], node1)
+
+		return nil
+	})
+	if err != nil {
+		log.Errorf("Unable to compute subgraph within %v hops: %v",
+			f.maxHops, err)
+
+		// If we've never computed the distances before, we'll at least
+		// make sure our own channels are in scope.
+		if f.distances == nil {
+			f.distances = map[route.Vertex]uint32{f.self: 0}
+		}
+
+		return
+	}
+
+	distances := map[route.Vertex]uint32{f.self: 0}
+	frontier := []route.Vertex{f.self}
+	for hops := uint32(1); hops <= f.maxHops; hops++ {
+		var next []route.Vertex
+		for _, node := range frontier {
+			for _, neighbour := range neighbours[node] {
+				if _, ok := distances[neighbour]; ok {
+					continue
+				}
+
+				distances[neighbour] = hops
+				next = append(next, neighbour)
+			}
+		}
+		frontier = next
+	}
+
+	log.Debugf("Computed subgraph within %v hops: %v nodes", f.maxHops,
+		len(distances))
+
+	f.distances = distances
+}
@@ -0,0 +1,76 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSubgraphFilter tests that the subgraph filter only accepts channels
+// with a node within the maximum number of hops of our own node, and that it
+// extends the subgraph as new channels are added.
+func TestSubgraphFilter(t *testing.T) {
+	t.Parallel()
+
+	vertex := func(b byte) route.Vertex {
+		return route.Vertex{b}
+	}
+	self := vertex(0)
+
+	// Our graph is the line self - 1 - 2 - 3 - 4.
+	edges := [][2]route.Vertex{
+		{self, vertex(1)},
+		{vertex(1), vertex(2)},
+		{vertex(2), vertex(3)},
+		{vertex(3), vertex(4)},
+	}
+	forEachChannel := func(cb func(*models.ChannelEdgeInfo,
+		*models.ChannelEdgePolicy, *models.ChannelEdgePolicy) error) error {
+
+		for _, edge := range edges {
+			err := cb(&models.ChannelEdgeInfo{
+				NodeKey1Bytes: edge[0],
+				NodeKey2Bytes: edge[1],
+			}, nil, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// Without a maximum number of hops, all channels are in scope.
+	var unlimited *subgraphFilter
+	require.Nil(t, newSubgraphFilter(self, 0, forEachChannel))
+	require.True(t, unlimited.inScope(vertex(8), vertex(9)))
+
+	filter := newSubgraphFilter(self, 2, forEachChannel)
+
+	// Our own channels and the ones of our direct neighbours are in scope,
+	// but not the ones beyond that.
+	require.True(t, filter.inScope(self, vertex(9)))
+	require.True(t, filter.inScope(vertex(1), vertex(9)))
+	require.True(t, filter.inScope(vertex(9), vertex(1)))
+	require.False(t, filter.inScope(vertex(2), vertex(3)))
+	require.False(t, filter.inScope(vertex(3), vertex(4)))
+	require.False(t, filter.inScope(vertex(8), vertex(9)))
+
+	// Once we add a channel from our node to a previously distant one, its
+	// channels should be in scope as well.
+	filter.addChannel(self, vertex(3))
+	require.True(t, filter.inScope(vertex(3), vertex(4)))
+
+	// Adding a channel between two nodes outside of the subgraph shouldn't
+	// affect it.
+	filter.addChannel(vertex(8), vertex(9))
+	require.False(t, filter.inScope(vertex(8), vertex(9)))
+
+	// A channel added to a neighbour of our node is in scope, but the
+	// channels of the node at its other end are not.
+	filter.addChannel(vertex(1), vertex(7))
+	require.True(t, filter.inScope(vertex(1), vertex(7)))
+	require.False(t, filter.inScope(vertex(7), vertex(9)))
+}
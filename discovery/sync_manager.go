@@ -107,6 +107,10 @@ type SyncManagerCfg struct {
 	// updates for a channel and returns true if the channel should be
 	// considered a zombie based on these timestamps.
 	IsStillZombieChannel func(time.Time, time.Time) bool
+
+	// Bandwidth is the budget of gossip bytes we're willing to exchange
+	// with each peer.
+	Bandwidth BandwidthBudget
 }
 
 // SyncManager is a subsystem of the gossiper that manages the gossip syncers
@@ -507,6 +511,7 @@ func (m *SyncManager) createGossipSyncer(peer lnpeer.Peer) *GossipSyncer {
 		maxQueryChanRangeReplies:  maxQueryChanRangeReplies,
 		noTimestampQueryOption:    m.cfg.NoTimestampQueries,
 		isStillZombieChannel:      m.cfg.IsStillZombieChannel,
+		bandwidth:                 m.cfg.Bandwidth,
 	})
 
 	// Gossip syncers are initialized by default in a PassiveSync type
@@ -301,6 +301,10 @@ type gossipSyncerCfg struct {
 	// updates for a channel and returns true if the channel should be
 	// considered a zombie based on these timestamps.
 	isStillZombieChannel func(time.Time, time.Time) bool
+
+	// bandwidth is the budget of gossip bytes we're willing to exchange
+	// with the peer.
+	bandwidth BandwidthBudget
 }
 
 // GossipSyncer is a struct that handles synchronizing the channel graph state
@@ -395,6 +399,28 @@ type GossipSyncer struct {
 	// number of queries.
 	rateLimiter *rate.Limiter
 
+	// inboundBudget and outboundBudget limit the rate of gossip bytes we
+	// receive from and send to the peer. They're nil if unlimited.
+	inboundBudget  *byteBudget
+	outboundBudget *byteBudget
+
+	// inboundExhausted is signalled with the time it'll take for the
+	// inbound budget to recover once announcements received from the peer
+	// exhaust it.
+	inboundExhausted chan time.Duration
+
+	// gossipPausedAt is the time at which we paused the peer's real-time
+	// gossip due to the inbound budget being exhausted, or zero if it
+	// isn't paused. Once the budget has recovered, resumeGossip fires and
+	// we'll ask the peer for any gossip since this time.
+	//
+	// NOTE: These must only be accessed by the channelGraphSyncer.
+	gossipPausedAt time.Time
+	resumeGossip   <-chan time.Time
+
+	// stats tracks the gossip traffic exchanged with the peer.
+	stats syncerStats
+
 	// syncedSignal is a channel that, if set, will be closed when the
 	// GossipSyncer reaches its terminal chansSynced state.
 	syncedSignal chan struct{}
@@ -437,8 +463,17 @@ func newGossipSyncer(cfg gossipSyncerCfg) *GossipSyncer {
 	}
 
 	return &GossipSyncer{
-		cfg:                cfg,
-		rateLimiter:        rateLimiter,
+		cfg:         cfg,
+		rateLimiter: rateLimiter,
+		inboundBudget: newByteBudget(
+			cfg.bandwidth.InboundBytesPerSec,
+			cfg.bandwidth.BurstBytes,
+		),
+		outboundBudget: newByteBudget(
+			cfg.bandwidth.OutboundBytesPerSec,
+			cfg.bandwidth.BurstBytes,
+		),
+		inboundExhausted:   make(chan time.Duration, 1),
 		syncTransitionReqs: make(chan *syncTransitionReq),
 		historicalSyncReqs: make(chan *historicalSyncReq),
 		gossipMsgs:         make(chan lnwire.Message, 100),
@@ -511,7 +546,7 @@ func (g *GossipSyncer) channelGraphSyncer() {
 				return
 			}
 
-			err = g.cfg.sendToPeer(queryRangeMsg)
+			err = g.sendToPeer(queryRangeMsg)
 			if err != nil {
 				log.Errorf("Unable to send chan range "+
 					"query: %v", err)
@@ -560,6 +595,14 @@ func (g *GossipSyncer) channelGraphSyncer() {
 		// the remote party knows of that we don't yet know of
 		// ourselves.
 		case queryNewChannels:
+			// If the replies to our previous query exhausted the
+			// peer's inbound budget, we'll hold off on the next
+			// one until it has recovered.
+			err := g.inboundBudget.wait(0, g.quit)
+			if err != nil {
+				return
+			}
+
 			// First, we'll attempt to continue our channel
 			// synchronization by continuing to send off another
 			// query chunk.
@@ -622,8 +665,10 @@ func (g *GossipSyncer) channelGraphSyncer() {
 
 			// If we haven't yet sent out our update horizon, and
 			// we want to receive real-time channel updates, we'll
-			// do so now.
+			// do so now, unless we've paused them due to the
+			// inbound budget.
 			if g.localUpdateHorizon == nil &&
+				g.gossipPausedAt.IsZero() &&
 				syncType.IsActiveSync() {
 
 				err := g.sendGossipTimestampRange(
@@ -652,6 +697,13 @@ func (g *GossipSyncer) channelGraphSyncer() {
 			case req := <-g.historicalSyncReqs:
 				g.handleHistoricalSync(req)
 
+			case delay := <-g.inboundExhausted:
+				g.pauseGossip(delay)
+
+			case <-g.resumeGossip:
+				g.resumeGossip = nil
+				g.unpauseGossip()
+
 			case <-g.quit:
 				return
 			}
@@ -659,6 +711,62 @@ func (g *GossipSyncer) channelGraphSyncer() {
 	}
 }
 
+// pauseGossip asks the remote peer to stop sending us real-time gossip as
+// their inbound budget has been exhausted. It'll be resumed once the given
+// delay has passed.
+func (g *GossipSyncer) pauseGossip(delay time.Duration) {
+	// If we aren't receiving real-time gossip from the peer, or we've
+	// already paused it, there's nothing to do.
+	if g.localUpdateHorizon == nil || !g.gossipPausedAt.IsZero() {
+		return
+	}
+
+	log.Debugf("GossipSyncer(%x): inbound budget exhausted, pausing "+
+		"gossip for %v", g.cfg.peerPub[:], delay)
+
+	pausedAt := time.Now()
+	err := g.sendGossipTimestampRange(zeroTimestamp, 0)
+	if err != nil {
+		log.Errorf("Unable to pause gossip from %x: %v",
+			g.cfg.peerPub, err)
+		return
+	}
+
+	g.gossipPausedAt = pausedAt
+	g.resumeGossip = time.After(delay)
+	g.stats.inboundThrottles.Add(1)
+}
+
+// unpauseGossip resumes the remote peer's real-time gossip once their inbound
+// budget has recovered. We'll ask for all gossip since we paused it, so that
+// we don't miss any updates in between.
+func (g *GossipSyncer) unpauseGossip() {
+	// Messages that were already in flight when we paused may have
+	// further drained the budget, in which case we'll wait a bit longer.
+	if delay := g.inboundBudget.recoveryDelay(); delay > 0 {
+		g.resumeGossip = time.After(delay)
+		return
+	}
+
+	pausedAt := g.gossipPausedAt
+	g.gossipPausedAt = time.Time{}
+
+	// If we've transitioned to a passive sync in the meantime, we no
+	// longer want to receive real-time gossip.
+	if !g.SyncType().IsActiveSync() {
+		return
+	}
+
+	log.Debugf("GossipSyncer(%x): inbound budget recovered, resuming "+
+		"gossip", g.cfg.peerPub[:])
+
+	err := g.sendGossipTimestampRange(pausedAt, math.MaxUint32)
+	if err != nil {
+		log.Errorf("Unable to resume gossip from %x: %v",
+			g.cfg.peerPub, err)
+	}
+}
+
 // replyHandler is an event loop whose sole purpose is to reply to the remote
 // peers queries. Our replyHandler will respond to messages generated by their
 // channelGraphSyncer, and vice versa. Each party's channelGraphSyncer drives
@@ -709,7 +817,7 @@ func (g *GossipSyncer) sendGossipTimestampRange(firstTimestamp time.Time,
 		TimestampRange: timestampRange,
 	}
 
-	if err := g.cfg.sendToPeer(localUpdateHorizon); err != nil {
+	if err := g.sendToPeer(localUpdateHorizon); err != nil {
 		return err
 	}
 
@@ -761,7 +869,7 @@ func (g *GossipSyncer) synchronizeChanIDs() (bool, error) {
 
 	// With our chunk obtained, we'll send over our next query, then return
 	// false indicating that we're net yet fully synced.
-	err := g.cfg.sendToPeer(&lnwire.QueryShortChanIDs{
+	err := g.sendToPeer(&lnwire.QueryShortChanIDs{
 		ChainHash:    g.cfg.chainHash,
 		EncodingType: lnwire.EncodingSortedPlain,
 		ShortChanIDs: queryChunk,
@@ -1052,7 +1160,7 @@ func (g *GossipSyncer) replyChanRangeQuery(query *lnwire.QueryChannelRange) erro
 			"chain=%v, we're on chain=%v", query.ChainHash,
 			g.cfg.chainHash)
 
-		return g.cfg.sendToPeerSync(&lnwire.ReplyChannelRange{
+		return g.sendToPeerSync(&lnwire.ReplyChannelRange{
 			ChainHash:        query.ChainHash,
 			FirstBlockHeight: query.FirstBlockHeight,
 			NumBlocks:        query.NumBlocks,
@@ -1125,7 +1233,7 @@ func (g *GossipSyncer) replyChanRangeQuery(query *lnwire.QueryChannelRange) erro
 			)
 		}
 
-		return g.cfg.sendToPeerSync(&lnwire.ReplyChannelRange{
+		return g.sendToPeerSync(&lnwire.ReplyChannelRange{
 			ChainHash:        query.ChainHash,
 			NumBlocks:        numBlocks,
 			FirstBlockHeight: firstHeight,
@@ -1229,7 +1337,7 @@ func (g *GossipSyncer) replyShortChanIDs(query *lnwire.QueryShortChanIDs) error
 			"chain=%v, we're on chain=%v", query.ChainHash,
 			g.cfg.chainHash)
 
-		return g.cfg.sendToPeerSync(&lnwire.ReplyShortChanIDsEnd{
+		return g.sendToPeerSync(&lnwire.ReplyShortChanIDsEnd{
 			ChainHash: query.ChainHash,
 			Complete:  0,
 		})
@@ -1260,7 +1368,7 @@ func (g *GossipSyncer) replyShortChanIDs(query *lnwire.QueryShortChanIDs) error
 	// each one individually and synchronously to throttle the sends and
 	// perform buffering of responses in the syncer as opposed to the peer.
 	for _, msg := range replyMsgs {
-		err := g.cfg.sendToPeerSync(msg)
+		err := g.sendToPeerSync(msg)
 		if err != nil {
 			return err
 		}
@@ -1268,7 +1376,7 @@ func (g *GossipSyncer) replyShortChanIDs(query *lnwire.QueryShortChanIDs) error
 
 	// Regardless of whether we had any messages to reply with, send over
 	// the sentinel message to signal that the stream has terminated.
-	return g.cfg.sendToPeerSync(&lnwire.ReplyShortChanIDsEnd{
+	return g.sendToPeerSync(&lnwire.ReplyShortChanIDsEnd{
 		ChainHash: query.ChainHash,
 		Complete:  1,
 	})
@@ -1328,7 +1436,7 @@ func (g *GossipSyncer) ApplyGossipFilter(filter *lnwire.GossipTimestampRange) er
 		}()
 
 		for _, msg := range newUpdatestoSend {
-			err := g.cfg.sendToPeerSync(msg)
+			err := g.sendToPeerSync(msg)
 			switch {
 			case err == ErrGossipSyncerExiting:
 				return
@@ -1402,6 +1510,11 @@ func (g *GossipSyncer) FilterGossipMsgs(msgs ...msgWithSenders) {
 			(t.After(startTime) && t.Before(endTime))
 	}
 
+	// We'll consider the messages in order of their relay priority, so
+	// that the most important ones make it within the peer's outbound
+	// budget.
+	msgs = sortByRelayPriority(msgs)
+
 	msgsToSend := make([]lnwire.Message, 0, len(msgs))
 	for _, msg := range msgs {
 		// If the target peer is the peer that sent us this message,
@@ -1458,9 +1571,34 @@ func (g *GossipSyncer) FilterGossipMsgs(msgs ...msgWithSenders) {
 			if passesFilter(msg.Timestamp) {
 				msgsToSend = append(msgsToSend, msg)
 			}
+
+		// The taproot gossip messages are ordered by block height
+		// rather than by timestamp, so the filter can't be applied to
+		// them and they're always sent. Their message types are odd,
+		// so they'll be ignored by peers that don't understand them.
+		case *lnwire.ChannelAnnouncement2, *lnwire.ChannelUpdate2:
+			msgsToSend = append(msgsToSend, msg)
 		}
 	}
 
+	// Relaying announcements is best effort, so if the peer's outbound
+	// budget is exhausted, we'll drop the remaining ones. The peer will
+	// learn of them through its own sync queries.
+	for i, msg := range msgsToSend {
+		if g.outboundBudget.allow(msgSize(msg)) {
+			continue
+		}
+
+		dropped := len(msgsToSend) - i
+		g.stats.outboundMsgsDropped.Add(uint64(dropped))
+
+		log.Debugf("GossipSyncer(%x): outbound budget exhausted, "+
+			"dropping %v msgs", g.cfg.peerPub[:], dropped)
+
+		msgsToSend = msgsToSend[:i]
+		break
+	}
+
 	log.Tracef("GossipSyncer(%x): filtered gossip msgs: set=%v, sent=%v",
 		g.cfg.peerPub[:], len(msgs), len(msgsToSend))
 
@@ -1468,7 +1606,80 @@ func (g *GossipSyncer) FilterGossipMsgs(msgs ...msgWithSenders) {
 		return
 	}
 
-	g.cfg.sendToPeer(msgsToSend...)
+	g.sendToPeer(msgsToSend...)
+}
+
+// sendToPeer sends the messages to the remote peer without waiting for them
+// to be written to the wire, and accounts for them in the syncer's stats.
+func (g *GossipSyncer) sendToPeer(msgs ...lnwire.Message) error {
+	for _, msg := range msgs {
+		g.stats.msgsSent.Add(1)
+		g.stats.bytesSent.Add(uint64(msgSize(msg)))
+	}
+
+	return g.cfg.sendToPeer(msgs...)
+}
+
+// sendToPeerSync sends the messages to the remote peer one by one, blocking
+// until each has been written to the wire. The sends are paced according to
+// the peer's outbound budget.
+func (g *GossipSyncer) sendToPeerSync(msgs ...lnwire.Message) error {
+	for _, msg := range msgs {
+		size := msgSize(msg)
+		if err := g.outboundBudget.wait(size, g.quit); err != nil {
+			return err
+		}
+
+		if err := g.cfg.sendToPeerSync(msg); err != nil {
+			return err
+		}
+
+		g.stats.msgsSent.Add(1)
+		g.stats.bytesSent.Add(uint64(size))
+	}
+
+	return nil
+}
+
+// recordLocalSend accounts for our own announcements that were broadcast to
+// the remote peer outside of the syncer. They're always sent, but still count
+// against the peer's outbound budget, so the announcements we relay next
+// respect it.
+func (g *GossipSyncer) recordLocalSend(msgs ...lnwire.Message) {
+	for _, msg := range msgs {
+		size := msgSize(msg)
+		g.outboundBudget.charge(size)
+
+		g.stats.msgsSent.Add(1)
+		g.stats.bytesSent.Add(uint64(size))
+	}
+}
+
+// recordInbound accounts for a gossip message received from the remote peer.
+// If the announcements sent to us by the peer exhaust its inbound budget, its
+// real-time gossip will be paused until the budget has recovered.
+func (g *GossipSyncer) recordInbound(msg lnwire.Message) {
+	size := msgSize(msg)
+	g.stats.msgsReceived.Add(1)
+	g.stats.bytesReceived.Add(uint64(size))
+
+	// Replies to our own queries are paced by holding off on the next
+	// query instead.
+	delay := g.inboundBudget.charge(size)
+	if delay <= 0 || !isAnnouncement(msg) {
+		return
+	}
+
+	select {
+	case g.inboundExhausted <- delay:
+	default:
+	}
+}
+
+// Stats returns a snapshot of the gossip traffic exchanged with the remote
+// peer.
+func (g *GossipSyncer) Stats() SyncerStats {
+	return g.stats.snapshot()
 }
 
 // ProcessQueryMsg is used by outside callers to pass new channel time series
@@ -1579,8 +1790,14 @@ func (g *GossipSyncer) handleSyncTransition(req *syncTransitionReq) error {
 
 	switch req.newSyncType {
 	// If an active sync has been requested, then we should resume receiving
-	// new graph updates from the remote peer.
+	// new graph updates from the remote peer. If we've paused them due to
+	// the inbound budget, they'll be resumed once it has recovered.
 	case ActiveSync, PinnedSync:
+		if !g.gossipPausedAt.IsZero() {
+			g.setSyncType(req.newSyncType)
+			return nil
+		}
+
 		firstTimestamp = time.Now()
 		timestampRange = math.MaxUint32
 
@@ -1592,6 +1809,9 @@ func (g *GossipSyncer) handleSyncTransition(req *syncTransitionReq) error {
 		firstTimestamp = zeroTimestamp
 		timestampRange = 0
 
+		g.gossipPausedAt = time.Time{}
+		g.resumeGossip = nil
+
 	default:
 		return fmt.Errorf("unhandled sync transition %v",
 			req.newSyncType)
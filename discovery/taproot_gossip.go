@@ -0,0 +1,1093 @@
+package discovery
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/batch"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/fn"
+	"github.com/lightningnetwork/lnd/lnpeer"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/netann"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/lightningnetwork/lnd/routing/route"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// chanAnn2State tracks the creation of the MuSig2 signature of the
+// ChannelAnnouncement2 of one of our taproot channels together with its remote
+// peer.
+//
+// The exchange is driven by AnnounceSignatures2 messages. Each peer first sends
+// the public nonces of its signing session, and signs once it knows the nonces
+// of the remote peer, after which it sends its nonces along with its partial
+// signature. If a peer loses its session, e.g. due to a restart, it'll start
+// out with new nonces. As a MuSig2 nonce can never be used to sign twice, we'll
+// then also have to start a new session, of which we only send the nonces
+// until we've received the new nonces of the remote peer as well.
+type chanAnn2State struct {
+	// session is our current signing session.
+	session *netann.ChanAnn2Session
+
+	// remoteNonces are the public nonces of the node and bitcoin key of
+	// the remote peer that we sign with.
+	remoteNonces *[2]lnwire.Musig2Nonce
+
+	// localSig is our partial signature, which is only set once we've
+	// signed with the remote nonces.
+	localSig *lnwire.PartialSig
+}
+
+// newChanAnn2Session creates a new signing session for the announcement of
+// the given taproot channel that we have with the passed remote peer.
+func (d *AuthenticatedGossiper) newChanAnn2Session(
+	chanInfo *models.ChannelEdgeInfo, remotePub [33]byte,
+	chanID lnwire.ChannelID) (*netann.ChanAnn2Session, error) {
+
+	remoteKey, err := btcec.ParsePubKey(remotePub[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// We'll need to know the key locator of our funding key, which is the
+	// bitcoin key of the announcement.
+	channel, err := d.cfg.FindChannel(remoteKey, chanID)
+	if err != nil {
+		return nil, err
+	}
+	if !channel.ChanType.IsTaproot() {
+		return nil, fmt.Errorf("channel %v is not a taproot channel",
+			chanID)
+	}
+
+	ann, err := netann.UnsignedChanAnnouncement2(chanInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return netann.NewChanAnn2Session(
+		d.cfg.MuSig2Signer, ann, d.selfKeyLoc,
+		channel.LocalChanCfg.MultiSigKey.KeyLocator,
+	)
+}
+
+// awaitingAliasCleanup returns true if the given channel negotiated the
+// option-scid-alias feature bit, and its aliases still map to the confirmed
+// SCID. The funding manager deletes these mappings once the channel has six
+// confirmations, right before it adds the channel back to the graph and starts
+// the signing of its announcement.
+func (d *AuthenticatedGossiper) awaitingAliasCleanup(
+	scid lnwire.ShortChannelID) bool {
+
+	if d.cfg.GetAliases == nil {
+		return false
+	}
+
+	for _, alias := range d.cfg.GetAliases(scid) {
+		base, err := d.cfg.FindBaseByAlias(alias)
+		if err == nil && base == scid {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleAnnSig2 processes a new AnnounceSignatures2 message, which is either
+// sent by the funding manager to start the signing of the announcement of one
+// of our taproot channels, or sent by the remote peer of the channel.
+func (d *AuthenticatedGossiper) handleAnnSig2(nMsg *networkMsg,
+	ann *lnwire.AnnounceSignatures2) ([]networkMsg, bool) {
+
+	scid := ann.ShortChannelID
+	shortChanID := scid.ToUint64()
+
+	prefix := "local"
+	if nMsg.isRemote {
+		prefix = "remote"
+	}
+
+	log.Infof("Received new %v announcement signature for %v", prefix,
+		scid)
+
+	// Just like the original announcement, the announcement of a taproot
+	// channel should only be created once the channel has matured.
+	d.Lock()
+	premature := d.isPremature(scid, d.cfg.ProofMatureDelta, nMsg)
+	if premature {
+		log.Warnf("Premature proof announcement, current block height"+
+			"lower than needed: %v < %v", d.bestHeight,
+			scid.BlockHeight+d.cfg.ProofMatureDelta)
+		d.Unlock()
+		nMsg.err <- nil
+		return nil, false
+	}
+	d.Unlock()
+
+	d.channelMtx.Lock(shortChanID)
+	defer d.channelMtx.Unlock(shortChanID)
+
+	chanInfo, e1, e2, err := d.cfg.Router.GetChannelByID(scid)
+	if err != nil {
+		err := fmt.Errorf("unable to find channel with "+
+			"short_chan_id=%v for announcement signature: %w",
+			shortChanID, err)
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	// Determine the remote peer of the channel, and make sure that it's
+	// the one that sent us the message.
+	var (
+		selfKey      = d.selfKey.SerializeCompressed()
+		remotePubKey [33]byte
+	)
+	switch {
+	case bytes.Equal(selfKey, chanInfo.NodeKey1Bytes[:]):
+		remotePubKey = chanInfo.NodeKey2Bytes
+
+	case bytes.Equal(selfKey, chanInfo.NodeKey2Bytes[:]):
+		remotePubKey = chanInfo.NodeKey1Bytes
+
+	default:
+		err := fmt.Errorf("received announcement signature for "+
+			"channel we're not party to, short_chan_id=%v",
+			shortChanID)
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	sourceKey := nMsg.source.SerializeCompressed()
+	if nMsg.isRemote && !bytes.Equal(sourceKey, remotePubKey[:]) {
+		err := fmt.Errorf("channel that was received doesn't belong "+
+			"to the peer which sent the proof, short_chan_id=%v",
+			shortChanID)
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	// If we already have the full proof, then the remote peer likely
+	// hasn't received our signature yet, so we'll send it the complete
+	// announcement instead.
+	if chanInfo.AuthProof != nil {
+		delete(d.chanAnn2Sessions, scid)
+
+		if nMsg.isRemote && chanInfo.Version == lnwire.GossipVersion2 {
+			d.sendFullChanAnn2(nMsg, chanInfo)
+		}
+
+		log.Debugf("Already have proof for channel with chanID=%v",
+			ann.ChannelID)
+		nMsg.err <- nil
+		return nil, true
+	}
+
+	// Just like the proof of a regular channel, which is only assembled
+	// once the funding manager has handed us our half of it, we won't sign
+	// on behalf of the remote peer before the funding manager is done with
+	// the channel. Otherwise, the proof could be completed before the edge
+	// of an option-scid-alias channel is deleted and added back to the
+	// graph, which would lose the proof again. We'll send our nonces once
+	// the signing is started locally.
+	state, ok := d.chanAnn2Sessions[scid]
+	if !ok && nMsg.isRemote && d.awaitingAliasCleanup(scid) {
+		log.Debugf("Deferring remote announcement signature for "+
+			"short_chan_id=%v until the channel has been "+
+			"processed after six confirmations", shortChanID)
+
+		nMsg.err <- nil
+		return nil, false
+	}
+	if !ok {
+		session, err := d.newChanAnn2Session(
+			chanInfo, remotePubKey, ann.ChannelID,
+		)
+		if err != nil {
+			err := fmt.Errorf("unable to create announcement "+
+				"signing session for short_chan_id=%v: %w",
+				shortChanID, err)
+			log.Error(err)
+			nMsg.err <- err
+			return nil, false
+		}
+
+		state = &chanAnn2State{session: session}
+		d.chanAnn2Sessions[scid] = state
+	}
+
+	// We'll send our nonces to the remote peer when the signing is
+	// started locally, and whenever our part of the exchange changed.
+	sendUpdate := !nMsg.isRemote || !ok
+
+	var remoteSig *lnwire.PartialSig
+	if nMsg.isRemote && ann.HasNonces() {
+		var nonces [2]lnwire.Musig2Nonce
+		ann.NodeNonce.WhenSomeV(func(n lnwire.Musig2Nonce) {
+			nonces[0] = n
+		})
+		ann.BitcoinNonce.WhenSomeV(func(n lnwire.Musig2Nonce) {
+			nonces[1] = n
+		})
+
+		switch {
+		// If we haven't signed yet, we'll sign with the nonces we just
+		// received.
+		case state.localSig == nil:
+			state.remoteNonces = &nonces
+
+		// If we already signed with different nonces, then the remote
+		// peer must have restarted its session. As we can't sign
+		// again with the nonces of our current session, we'll start a
+		// new one and only send its nonces, which the remote peer will
+		// then sign with.
+		case *state.remoteNonces != nonces:
+			log.Debugf("Restarting announcement signing session "+
+				"for short_chan_id=%v with new remote nonces",
+				shortChanID)
+
+			session, err := d.newChanAnn2Session(
+				chanInfo, remotePubKey, ann.ChannelID,
+			)
+			if err != nil {
+				err := fmt.Errorf("unable to create "+
+					"announcement signing session for "+
+					"short_chan_id=%v: %w", shortChanID,
+					err)
+				log.Error(err)
+				nMsg.err <- err
+				return nil, false
+			}
+
+			state = &chanAnn2State{session: session}
+			d.chanAnn2Sessions[scid] = state
+			sendUpdate = true
+
+		// Otherwise, this is the signature of the session we already
+		// signed.
+		default:
+			ann.PartialSignature.WhenSomeV(
+				func(sig lnwire.PartialSig) {
+					remoteSig = &sig
+				},
+			)
+		}
+	}
+
+	// Once we know the nonces of the remote peer, we can sign.
+	if state.localSig == nil && state.remoteNonces != nil {
+		state.localSig, err = state.session.Sign(
+			state.remoteNonces[0], state.remoteNonces[1],
+		)
+		if err != nil {
+			err := fmt.Errorf("unable to sign announcement of "+
+				"short_chan_id=%v: %w", shortChanID, err)
+			log.Error(err)
+			nMsg.err <- err
+			return nil, false
+		}
+
+		sendUpdate = true
+
+		// The remote peer might have sent its signature along with its
+		// nonces already.
+		if nMsg.isRemote {
+			ann.PartialSignature.WhenSomeV(
+				func(sig lnwire.PartialSig) {
+					remoteSig = &sig
+				},
+			)
+		}
+	}
+
+	if sendUpdate {
+		err := d.sendAnnSig2(state, ann.ChannelID, scid, remotePubKey)
+		if err != nil {
+			nMsg.err <- err
+			return nil, false
+		}
+	}
+
+	if state.localSig == nil || remoteSig == nil {
+		nMsg.err <- nil
+		return nil, false
+	}
+
+	// With both partial signatures known, we can create the final
+	// signature of the announcement.
+	finalSig, err := state.session.CombineSig(*remoteSig)
+	if err != nil {
+		// The remote peer may have signed with outdated nonces of ours,
+		// in which case it'll sign again once it receives our current
+		// ones.
+		log.Debugf("Unable to combine announcement signature for "+
+			"short_chan_id=%v: %v", shortChanID, err)
+		nMsg.err <- nil
+		return nil, false
+	}
+
+	delete(d.chanAnn2Sessions, scid)
+
+	proof := &models.ChannelAuthProof{
+		SchnorrSigBytes: finalSig.Serialize(),
+	}
+	err = d.cfg.Router.AddProof(scid, proof)
+	if err != nil {
+		err := fmt.Errorf("unable add proof to the channel chanID=%v:"+
+			" %v", ann.ChannelID, err)
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	log.Infof("Fully valid taproot channel proof for short_chan_id=%v "+
+		"constructed, adding to next ann batch", shortChanID)
+
+	chanInfo.AuthProof = proof
+	chanInfo.Version = lnwire.GossipVersion2
+
+	announcements, err := d.chanAnn2Announcements(
+		nMsg.peer, nMsg.source, chanInfo, e1, e2,
+	)
+	if err != nil {
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	nMsg.err <- nil
+	return announcements, true
+}
+
+// sendAnnSig2 reliably sends the nonces, and partial signature if known, of
+// the given signing session to the remote peer.
+func (d *AuthenticatedGossiper) sendAnnSig2(state *chanAnn2State,
+	chanID lnwire.ChannelID, scid lnwire.ShortChannelID,
+	remotePubKey [33]byte) error {
+
+	msg := &lnwire.AnnounceSignatures2{
+		ChannelID:      chanID,
+		ShortChannelID: scid,
+	}
+
+	nodeNonce, bitcoinNonce := state.session.LocalNonces()
+	msg.NodeNonce = tlv.SomeRecordT(
+		tlv.NewRecordT[tlv.TlvType1, lnwire.Musig2Nonce](nodeNonce),
+	)
+	msg.BitcoinNonce = tlv.SomeRecordT(
+		tlv.NewRecordT[tlv.TlvType3, lnwire.Musig2Nonce](bitcoinNonce),
+	)
+	if state.localSig != nil {
+		msg.PartialSignature = tlv.SomeRecordT(
+			tlv.NewRecordT[tlv.TlvType5](*state.localSig),
+		)
+	}
+
+	// Since the remote peer might not be online we'll call a method that
+	// will attempt to deliver the message when it comes online.
+	err := d.reliableSender.sendMessage(msg, remotePubKey)
+	if err != nil {
+		return fmt.Errorf("unable to reliably send %v for "+
+			"channel=%v to peer=%x: %w", msg.MsgType(), scid,
+			remotePubKey, err)
+	}
+
+	return nil
+}
+
+// sendFullChanAnn2 sends the complete announcement of the given taproot
+// channel to the peer that sent us the passed message.
+func (d *AuthenticatedGossiper) sendFullChanAnn2(nMsg *networkMsg,
+	chanInfo *models.ChannelEdgeInfo) {
+
+	peerID := nMsg.source.SerializeCompressed()
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		log.Debugf("Received announcement signature for channel %v "+
+			"with existing full proof. Sending full proof to "+
+			"peer=%x", chanInfo.ChannelID, peerID)
+
+		ca, _, _, err := netann.CreateChanAnnouncement2(
+			chanInfo.AuthProof, chanInfo, nil, nil,
+		)
+		if err != nil {
+			log.Errorf("unable to gen ann: %v", err)
+			return
+		}
+
+		err = nMsg.peer.SendMessage(false, ca)
+		if err != nil {
+			log.Errorf("Failed sending full proof to peer=%x: %v",
+				peerID, err)
+			return
+		}
+
+		log.Debugf("Full proof sent to peer=%x for chanID=%v", peerID,
+			chanInfo.ChannelID)
+	}()
+}
+
+// chanAnn2Announcements assembles the announcements to broadcast once the
+// proof of one of our taproot channels is complete. Our own policy of the
+// channel is re-signed as a ChannelUpdate2 in the process.
+func (d *AuthenticatedGossiper) chanAnn2Announcements(peer lnpeer.Peer,
+	source *btcec.PublicKey, chanInfo *models.ChannelEdgeInfo,
+	e1, e2 *models.ChannelEdgePolicy) ([]networkMsg, error) {
+
+	ourPolicy, remotePolicy := e1, e2
+	remoteKey, err := chanInfo.NodeKey2()
+	if bytes.Equal(
+		d.selfKey.SerializeCompressed(), chanInfo.NodeKey2Bytes[:],
+	) {
+
+		ourPolicy, remotePolicy = e2, e1
+		remoteKey, err = chanInfo.NodeKey1()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chanAnn, _, _, err := netann.CreateChanAnnouncement2(
+		chanInfo.AuthProof, chanInfo, nil, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	announcements := []networkMsg{{
+		peer:   peer,
+		source: source,
+		msg:    chanAnn,
+	}}
+
+	// Our current policy was announced with a ChannelUpdate, so we'll
+	// need to sign a new ChannelUpdate2 for it. We set ourselves as the
+	// source, to make sure our channel peer receives it as well.
+	if ourPolicy != nil {
+		_, chanUpdate, err := d.updateChannel2(chanInfo, ourPolicy)
+		if err != nil {
+			return nil, err
+		}
+
+		announcements = append(announcements, networkMsg{
+			peer:   peer,
+			source: d.selfKey,
+			msg:    chanUpdate,
+		})
+	}
+
+	// The policy of the remote peer can only be included if it was
+	// already announced with a ChannelUpdate2.
+	if remotePolicy != nil &&
+		remotePolicy.Version == lnwire.GossipVersion2 {
+
+		chanUpdate, err := netann.ChannelUpdate2FromEdge(
+			chanInfo, remotePolicy,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		announcements = append(announcements, networkMsg{
+			peer:   peer,
+			source: remoteKey,
+			msg:    chanUpdate,
+		})
+	}
+
+	// We'll also send along the node announcements of both channel
+	// participants if we know of them, with each node as the source so
+	// that they also reach our channel counterparty.
+	for _, nodeKey := range []*btcec.PublicKey{d.selfKey, remoteKey} {
+		nodeAnn, err := d.fetchNodeAnn(route.NewVertex(nodeKey))
+		if err != nil {
+			log.Debugf("Unable to fetch node announcement for "+
+				"%x: %v", nodeKey.SerializeCompressed(), err)
+			continue
+		}
+
+		announcements = append(announcements, networkMsg{
+			peer:   peer,
+			source: nodeKey,
+			msg:    nodeAnn,
+		})
+	}
+
+	return announcements, nil
+}
+
+// signChannelUpdate2 signs a ChannelUpdate2 for the given policy of one of our
+// taproot channels with our node key. The policy is updated in place to match
+// the signed update.
+func (d *AuthenticatedGossiper) signChannelUpdate2(
+	info *models.ChannelEdgeInfo,
+	policy *models.ChannelEdgePolicy) (*lnwire.ChannelUpdate2, error) {
+
+	// Taproot channel updates are ordered by the block height they were
+	// created at, so we'll need to make sure that the height of a new
+	// update is always greater than the one of the previous update.
+	blockHeight := d.latestHeight()
+	if policy.Version == lnwire.GossipVersion2 &&
+		policy.BlockHeight >= blockHeight {
+
+		blockHeight = policy.BlockHeight + 1
+	}
+
+	var disableFlags lnwire.ChanUpdateDisableFlags
+	if policy.ChannelFlags.IsDisabled() {
+		disableFlags = lnwire.ChanUpdateDisableIncoming |
+			lnwire.ChanUpdateDisableOutgoing
+	}
+
+	policy.Version = lnwire.GossipVersion2
+	policy.BlockHeight = blockHeight
+	policy.DisableFlags = disableFlags
+	policy.MessageFlags |= lnwire.ChanUpdateRequiredMaxHtlc
+	policy.LastUpdate = time.Now()
+
+	update := netann.UnsignedChannelUpdate2FromEdge(info, policy)
+	err := netann.SignChannelUpdate2(
+		d.cfg.SchnorrSigner, d.selfKeyLoc, update,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.SigBytes = update.Signature.ToSignatureBytes()
+
+	return update, nil
+}
+
+// updateChannel2 creates a new fully signed ChannelUpdate2 for one of our
+// taproot channels, and updates the underlying graph with the new state. The
+// announcement of the channel is returned along with it.
+func (d *AuthenticatedGossiper) updateChannel2(info *models.ChannelEdgeInfo,
+	edge *models.ChannelEdgePolicy) (*lnwire.ChannelAnnouncement2,
+	*lnwire.ChannelUpdate2, error) {
+
+	chanUpdate, err := d.signChannelUpdate2(info, edge)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// To ensure that our signature is valid, we'll verify it ourself
+	// before committing it.
+	err = routing.ValidateChannelUpdate2Ann(
+		d.selfKey, info.Capacity, chanUpdate,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generated invalid channel "+
+			"update sig: %v", err)
+	}
+
+	if err := d.cfg.Router.UpdateEdge(edge); err != nil {
+		return nil, nil, err
+	}
+
+	chanAnn, _, _, err := netann.CreateChanAnnouncement2(
+		info.AuthProof, info, nil, nil,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return chanAnn, chanUpdate, nil
+}
+
+// localChanUpdate2 converts a local ChannelUpdate of one of our public taproot
+// channels into a signed ChannelUpdate2. If the channel isn't announced with
+// the taproot gossip messages, nil is returned.
+func (d *AuthenticatedGossiper) localChanUpdate2(
+	upd *lnwire.ChannelUpdate) (*lnwire.ChannelUpdate2, error) {
+
+	chanInfo, e1, e2, err := d.cfg.Router.GetChannelByID(
+		upd.ShortChannelID,
+	)
+	if err != nil || chanInfo.Version != lnwire.GossipVersion2 {
+		return nil, nil
+	}
+
+	policy := &models.ChannelEdgePolicy{
+		ChannelID:                 chanInfo.ChannelID,
+		MessageFlags:              upd.MessageFlags,
+		ChannelFlags:              upd.ChannelFlags,
+		TimeLockDelta:             upd.TimeLockDelta,
+		MinHTLC:                   upd.HtlcMinimumMsat,
+		MaxHTLC:                   upd.HtlcMaximumMsat,
+		FeeBaseMSat:               lnwire.MilliSatoshi(upd.BaseFee),
+		FeeProportionalMillionths: lnwire.MilliSatoshi(upd.FeeRate),
+		ExtraOpaqueData:           upd.ExtraOpaqueData,
+	}
+
+	// Carry over the version and block height of our previous policy, so
+	// that the new update supersedes it.
+	prev := e1
+	if upd.ChannelFlags&lnwire.ChanUpdateDirection == 1 {
+		prev = e2
+	}
+	if prev != nil {
+		policy.Version = prev.Version
+		policy.BlockHeight = prev.BlockHeight
+	}
+
+	update, err := d.signChannelUpdate2(chanInfo, policy)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign ChannelUpdate2 for "+
+			"short_chan_id=%v: %w", upd.ShortChannelID, err)
+	}
+
+	return update, nil
+}
+
+// handleChanUpdate2 processes a new taproot channel update.
+func (d *AuthenticatedGossiper) handleChanUpdate2(nMsg *networkMsg,
+	upd *lnwire.ChannelUpdate2,
+	ops []batch.SchedulerOption) ([]networkMsg, bool) {
+
+	scid := upd.ShortChannelID.Val
+	shortChanID := scid.ToUint64()
+
+	log.Debugf("Processing ChannelUpdate2: peer=%v, short_chan_id=%v, ",
+		nMsg.peer, shortChanID)
+
+	// We'll ignore any channel updates that target any chain other than
+	// the set of chains we know of.
+	if !bytes.Equal(upd.ChainHash.Val[:], d.cfg.ChainHash[:]) {
+		err := fmt.Errorf("ignoring ChannelUpdate2 from chain=%v, "+
+			"gossiper on chain=%v",
+			chainhash.Hash(upd.ChainHash.Val), d.cfg.ChainHash)
+		log.Errorf(err.Error())
+
+		key := newRejectCacheKey(shortChanID, sourceToPub(nMsg.source))
+		_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+		nMsg.err <- err
+		return nil, false
+	}
+
+	// If the advertised inclusionary block is beyond our knowledge of the
+	// chain tip, then we'll put the announcement in limbo to be fully
+	// verified once we advance forward in the chain.
+	d.Lock()
+	if nMsg.isRemote && d.isPremature(scid, 0, nMsg) {
+		log.Warnf("Update announcement for short_chan_id(%v), is "+
+			"premature: advertises height %v, only height %v is "+
+			"known", shortChanID, scid.BlockHeight, d.bestHeight)
+		d.Unlock()
+		nMsg.err <- nil
+		return nil, false
+	}
+	d.Unlock()
+
+	// Before we perform any of the expensive checks below, we'll check
+	// whether this update is stale in order to quickly reject it.
+	if d.cfg.Router.IsStaleEdgePolicy2(
+		scid, upd.BlockHeight.Val, upd.Direction(),
+	) {
+
+		log.Debugf("Ignored stale edge policy for short_chan_id(%v): "+
+			"peer=%v, msg=%s, is_remote=%v", shortChanID,
+			nMsg.peer, nMsg.msg.MsgType(), nMsg.isRemote,
+		)
+
+		nMsg.err <- nil
+		return nil, true
+	}
+
+	// We make sure to obtain the mutex for this channel ID before we
+	// access the database. This ensures the state we read from the
+	// database has not changed between this point and when we call
+	// UpdateEdge() later.
+	d.channelMtx.Lock(shortChanID)
+	defer d.channelMtx.Unlock(shortChanID)
+
+	chanInfo, e1, e2, err := d.cfg.Router.GetChannelByID(scid)
+	switch {
+	case err == nil:
+
+	// If the channel isn't known yet, we'll stash the update until we
+	// receive its announcement.
+	case errors.Is(err, channeldb.ErrGraphNotFound),
+		errors.Is(err, channeldb.ErrGraphNoEdgesFound),
+		errors.Is(err, channeldb.ErrEdgeNotFound):
+
+		d.addPrematureChannelUpdate(shortChanID, nMsg)
+
+		log.Debugf("Got ChannelUpdate2 for edge not found in graph"+
+			"(shortChanID=%v), saving for reprocessing later",
+			shortChanID)
+
+		// NOTE: We don't return anything on the error channel for this
+		// message, as we expect that will be done when this
+		// ChannelUpdate2 is later reprocessed.
+		return nil, false
+
+	default:
+		err := fmt.Errorf("unable to validate channel update "+
+			"short_chan_id=%v: %v", shortChanID, err)
+		log.Error(err)
+		nMsg.err <- err
+
+		key := newRejectCacheKey(shortChanID, sourceToPub(nMsg.source))
+		_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+		return nil, false
+	}
+
+	// A taproot channel update is only valid for a channel that was
+	// announced with a ChannelAnnouncement2, or for one of our own
+	// channels that isn't announced yet.
+	if chanInfo.Version != lnwire.GossipVersion2 &&
+		chanInfo.AuthProof != nil {
+
+		err := fmt.Errorf("ignoring ChannelUpdate2 for channel "+
+			"short_chan_id=%v that isn't a taproot channel",
+			shortChanID)
+		log.Error(err)
+		nMsg.err <- err
+
+		key := newRejectCacheKey(shortChanID, sourceToPub(nMsg.source))
+		_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+		return nil, false
+	}
+
+	var (
+		pubKey       *btcec.PublicKey
+		edgeToUpdate *models.ChannelEdgePolicy
+		toNode       [33]byte
+	)
+	if upd.SecondPeer.Val {
+		pubKey, err = chanInfo.NodeKey2()
+		edgeToUpdate = e2
+		toNode = chanInfo.NodeKey1Bytes
+	} else {
+		pubKey, err = chanInfo.NodeKey1()
+		edgeToUpdate = e1
+		toNode = chanInfo.NodeKey2Bytes
+	}
+	if err != nil {
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	// Validate the channel update with the expected public key and
+	// channel capacity. In the case of an invalid channel update, we'll
+	// return an error to the caller and exit early.
+	err = routing.ValidateChannelUpdate2Ann(pubKey, chanInfo.Capacity, upd)
+	if err != nil {
+		rErr := fmt.Errorf("unable to validate channel update "+
+			"announcement for short_chan_id=%v: %v", shortChanID,
+			err)
+
+		log.Error(rErr)
+		nMsg.err <- rErr
+		return nil, false
+	}
+
+	// If we have a previous version of the edge being updated, we'll want
+	// to rate limit its updates to prevent spam throughout the network.
+	if nMsg.isRemote && edgeToUpdate != nil &&
+		!d.allowChanUpdate(chanInfo.ChannelID, upd.Direction()) {
+
+		log.Debugf("Rate limiting update for channel %v from "+
+			"direction %x", shortChanID,
+			pubKey.SerializeCompressed())
+		nMsg.err <- nil
+		return nil, false
+	}
+
+	chanFlags := upd.Direction()
+	if !upd.DisabledFlags.Val.IsEnabled() {
+		chanFlags |= lnwire.ChanUpdateDisabled
+	}
+
+	var (
+		feeBase = lnwire.MilliSatoshi(upd.FeeBaseMsat.Val)
+		feeRate = lnwire.MilliSatoshi(upd.FeeProportionalMillionths.Val)
+	)
+	update := &models.ChannelEdgePolicy{
+		SigBytes:                  upd.Signature.ToSignatureBytes(),
+		ChannelID:                 chanInfo.ChannelID,
+		LastUpdate:                time.Now(),
+		MessageFlags:              lnwire.ChanUpdateRequiredMaxHtlc,
+		ChannelFlags:              chanFlags,
+		TimeLockDelta:             upd.CLTVExpiryDelta.Val,
+		MinHTLC:                   upd.HTLCMinimumMsat.Val,
+		MaxHTLC:                   upd.HTLCMaximumMsat.Val,
+		FeeBaseMSat:               feeBase,
+		FeeProportionalMillionths: feeRate,
+		ToNode:                    toNode,
+		ExtraOpaqueData:           upd.ExtraOpaqueData,
+		Version:                   lnwire.GossipVersion2,
+		BlockHeight:               upd.BlockHeight.Val,
+		DisableFlags:              upd.DisabledFlags.Val,
+	}
+
+	if err := d.cfg.Router.UpdateEdge(update, ops...); err != nil {
+		if routing.IsError(
+			err, routing.ErrOutdated,
+			routing.ErrIgnored,
+			routing.ErrVBarrierShuttingDown,
+		) {
+
+			log.Debugf("Update edge for short_chan_id(%v) got: %v",
+				shortChanID, err)
+		} else {
+			key := newRejectCacheKey(
+				shortChanID, sourceToPub(nMsg.source),
+			)
+			_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+			log.Errorf("Update edge for short_chan_id(%v) got: %v",
+				shortChanID, err)
+		}
+
+		nMsg.err <- err
+		return nil, false
+	}
+
+	// The channel update can only be broadcast to the rest of the network
+	// once the channel is announced.
+	var announcements []networkMsg
+	if chanInfo.AuthProof != nil {
+		announcements = append(announcements, networkMsg{
+			peer:     nMsg.peer,
+			source:   nMsg.source,
+			isRemote: nMsg.isRemote,
+			msg:      upd,
+		})
+	}
+
+	nMsg.err <- nil
+
+	log.Debugf("Processed ChannelUpdate2: peer=%v, short_chan_id=%v, "+
+		"block_height=%v", nMsg.peer, shortChanID, upd.BlockHeight.Val)
+
+	return announcements, true
+}
+
+// handleChanAnnouncement2 processes a new taproot channel announcement.
+func (d *AuthenticatedGossiper) handleChanAnnouncement2(nMsg *networkMsg,
+	ann *lnwire.ChannelAnnouncement2,
+	ops []batch.SchedulerOption) ([]networkMsg, bool) {
+
+	scid := ann.ShortChannelID.Val
+	shortChanID := scid.ToUint64()
+
+	log.Debugf("Processing ChannelAnnouncement2: peer=%v, "+
+		"short_chan_id=%v", nMsg.peer, shortChanID)
+
+	// We'll ignore any channel announcements that target any chain other
+	// than the set of chains we know of.
+	if !bytes.Equal(ann.ChainHash.Val[:], d.cfg.ChainHash[:]) {
+		err := fmt.Errorf("ignoring ChannelAnnouncement2 from "+
+			"chain=%v, gossiper on chain=%v",
+			chainhash.Hash(ann.ChainHash.Val), d.cfg.ChainHash)
+		log.Errorf(err.Error())
+
+		key := newRejectCacheKey(shortChanID, sourceToPub(nMsg.source))
+		_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+		nMsg.err <- err
+		return nil, false
+	}
+
+	// If the advertised inclusionary block is beyond our knowledge of the
+	// chain tip, then we'll ignore it for now.
+	d.Lock()
+	if nMsg.isRemote && d.isPremature(scid, 0, nMsg) {
+		log.Warnf("Announcement for chan_id=(%v), is premature: "+
+			"advertises height %v, only height %v is known",
+			shortChanID, scid.BlockHeight, d.bestHeight)
+		d.Unlock()
+		nMsg.err <- nil
+		return nil, false
+	}
+	d.Unlock()
+
+	// If we only sync the subgraph around our node, we'll drop channels
+	// outside of it before validating their signatures.
+	if nMsg.isRemote &&
+		!d.inSyncScope(nMsg, ann.NodeID1.Val, ann.NodeID2.Val) {
+
+		nMsg.err <- nil
+		return nil, false
+	}
+
+	// Taproot channel announcements are always fully signed, so we'll
+	// validate the signature right away.
+	if err := routing.ValidateChannelAnn2(ann); err != nil {
+		err := fmt.Errorf("unable to validate announcement: %v", err)
+
+		key := newRejectCacheKey(shortChanID, sourceToPub(nMsg.source))
+		_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+		log.Error(err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	proof := &models.ChannelAuthProof{
+		SchnorrSigBytes: ann.Signature.ToSignatureBytes(),
+	}
+
+	// If we already know of the channel, then this may be the
+	// announcement of one of our own channels that the remote peer was
+	// able to complete before us.
+	if d.cfg.Router.IsKnownEdge(scid) {
+		d.channelMtx.Lock(shortChanID)
+		anns, err := d.processRejectedEdge2(nMsg, ann, proof)
+		d.channelMtx.Unlock(shortChanID)
+		if err != nil {
+			key := newRejectCacheKey(
+				shortChanID, sourceToPub(nMsg.source),
+			)
+			_, _ = d.recentRejects.Put(key, &cachedReject{})
+
+			nMsg.err <- err
+			return nil, false
+		}
+
+		nMsg.err <- nil
+		return anns, true
+	}
+
+	var featureBuf bytes.Buffer
+	if err := ann.Features.Val.Encode(&featureBuf); err != nil {
+		log.Errorf("unable to encode features: %v", err)
+		nMsg.err <- err
+		return nil, false
+	}
+
+	edge := &models.ChannelEdgeInfo{
+		ChannelID:       shortChanID,
+		ChainHash:       ann.ChainHash.Val,
+		NodeKey1Bytes:   ann.NodeID1.Val,
+		NodeKey2Bytes:   ann.NodeID2.Val,
+		Capacity:        btcutil.Amount(ann.Capacity.Val),
+		AuthProof:       proof,
+		Features:        featureBuf.Bytes(),
+		ExtraOpaqueData: ann.ExtraOpaqueData,
+		Version:         lnwire.GossipVersion2,
+	}
+	ann.BitcoinKey1.WhenSomeV(func(key [33]byte) {
+		edge.BitcoinKey1Bytes = key
+	})
+	ann.BitcoinKey2.WhenSomeV(func(key [33]byte) {
+		edge.BitcoinKey2Bytes = key
+	})
+	ann.MerkleRootHash.WhenSomeV(func(root [32]byte) {
+		edge.MerkleRootHash = fn.Some(chainhash.Hash(root))
+	})
+
+	log.Debugf("Adding edge for short_chan_id: %v", shortChanID)
+
+	// We will add the edge to the channel router. If the nodes present in
+	// this channel are not present in the database, a partial node will be
+	// added to represent each node while we wait for a node announcement.
+	d.channelMtx.Lock(shortChanID)
+	err := d.cfg.Router.AddEdge(edge, ops...)
+	d.channelMtx.Unlock(shortChanID)
+	if err != nil {
+		log.Debugf("Router rejected edge for short_chan_id(%v): %v",
+			shortChanID, err)
+
+		if !routing.IsError(err, routing.ErrIgnored) {
+			key := newRejectCacheKey(
+				shortChanID, sourceToPub(nMsg.source),
+			)
+			_, _ = d.recentRejects.Put(key, &cachedReject{})
+		}
+
+		nMsg.err <- err
+		return nil, false
+	}
+
+	log.Debugf("Finish adding edge for short_chan_id: %v", shortChanID)
+
+	// The new channel may have extended the subgraph we sync.
+	d.subgraph.addChannel(ann.NodeID1.Val, ann.NodeID2.Val)
+
+	// If we earlier received any ChannelUpdates for this channel, we can
+	// now process them, as the channel is added to the graph.
+	d.reprocessPrematureUpdates(shortChanID)
+
+	nMsg.err <- nil
+
+	log.Debugf("Processed ChannelAnnouncement2: peer=%v, "+
+		"short_chan_id=%v", nMsg.peer, shortChanID)
+
+	return []networkMsg{{
+		peer:     nMsg.peer,
+		isRemote: nMsg.isRemote,
+		source:   nMsg.source,
+		msg:      ann,
+	}}, true
+}
+
+// processRejectedEdge2 examines a ChannelAnnouncement2 of a channel that is
+// already known. If the channel is one of our own taproot channels that is not
+// yet announced, then the remote peer was able to assemble the signature of
+// the announcement before us, so we'll add its proof to our channel.
+func (d *AuthenticatedGossiper) processRejectedEdge2(nMsg *networkMsg,
+	ann *lnwire.ChannelAnnouncement2,
+	proof *models.ChannelAuthProof) ([]networkMsg, error) {
+
+	chanInfo, e1, e2, err := d.cfg.Router.GetChannelByID(
+		ann.ShortChannelID.Val,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The edge is in the graph, and has a proof attached, then we'll just
+	// reject it as normal.
+	if chanInfo.AuthProof != nil {
+		return nil, nil
+	}
+
+	// Otherwise, we'll make sure that the signature covers the channel as
+	// we know it, by validating the announcement recreated from our edge.
+	chanInfo.AuthProof = proof
+	chanInfo.Version = lnwire.GossipVersion2
+
+	chanAnn, _, _, err := netann.CreateChanAnnouncement2(
+		proof, chanInfo, nil, nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := routing.ValidateChannelAnn2(chanAnn); err != nil {
+		err := fmt.Errorf("assembled channel announcement proof "+
+			"for shortChanID=%v isn't valid: %v",
+			ann.ShortChannelID.Val, err)
+		log.Error(err)
+		return nil, err
+	}
+
+	err = d.cfg.Router.AddProof(ann.ShortChannelID.Val, proof)
+	if err != nil {
+		err := fmt.Errorf("unable add proof to shortChanID=%v: %v",
+			ann.ShortChannelID.Val, err)
+		log.Error(err)
+		return nil, err
+	}
+
+	return d.chanAnn2Announcements(
+		nMsg.peer, d.selfKey, chanInfo, e1, e2,
+	)
+}
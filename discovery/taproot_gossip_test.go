@@ -0,0 +1,392 @@
+package discovery
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnpeer"
+	"github.com/lightningnetwork/lnd/lntest/mock"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/netann"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/stretchr/testify/require"
+)
+
+// testBitcoinKeyLoc is the key locator of the funding key of our taproot test
+// channel.
+var testBitcoinKeyLoc = keychain.KeyLocator{
+	Family: keychain.KeyFamilyMultiSig,
+}
+
+// newTestMuSig2Signer returns a MuSig2 signer that signs with the given node
+// key for the node key locator, and with the bitcoin key otherwise.
+func newTestMuSig2Signer(nodeKey,
+	bitcoinKey *btcec.PrivateKey) input.MuSig2Signer {
+
+	return input.NewMusigSessionManager(
+		func(desc *keychain.KeyDescriptor) (*btcec.PrivateKey, error) {
+			if desc.KeyLocator == testKeyLoc {
+				return nodeKey, nil
+			}
+
+			return bitcoinKey, nil
+		},
+	)
+}
+
+// newTaprootTestCtx creates a test context whose channel is a taproot channel
+// that the gossiper can sign the announcement of. The returned channel
+// receives the messages the gossiper sends directly to its peers.
+func newTaprootTestCtx(t *testing.T) (*testCtx, chan lnwire.Message) {
+	t.Helper()
+
+	ctx, err := createTestCtx(t, proofMatureDelta)
+	require.NoError(t, err, "can't create context")
+
+	ctx.gossiper.cfg.SchnorrSigner = &mock.SecretKeyRing{
+		RootKey: selfKeyPriv,
+	}
+	ctx.gossiper.cfg.MuSig2Signer = newTestMuSig2Signer(
+		selfKeyPriv, bitcoinKeyPriv1,
+	)
+	ctx.gossiper.cfg.FindChannel = func(*btcec.PublicKey,
+		lnwire.ChannelID) (*channeldb.OpenChannel, error) {
+
+		return &channeldb.OpenChannel{
+			ChanType: channeldb.SimpleTaprootFeatureBit,
+			LocalChanCfg: channeldb.ChannelConfig{
+				MultiSigKey: keychain.KeyDescriptor{
+					KeyLocator: testBitcoinKeyLoc,
+				},
+			},
+		}, nil
+	}
+
+	// Set up a channel that we can use to inspect the messages sent
+	// directly from the gossiper.
+	sentMsgs := make(chan lnwire.Message, 10)
+	ctx.gossiper.reliableSender.cfg.NotifyWhenOnline = func(target [33]byte,
+		peerChan chan<- lnpeer.Peer) {
+
+		pk, _ := btcec.ParsePubKey(target[:])
+
+		select {
+		case peerChan <- &mockPeer{pk, sentMsgs, ctx.gossiper.quit}:
+		case <-ctx.gossiper.quit:
+		}
+	}
+
+	return ctx, sentMsgs
+}
+
+// TestTaprootChanAnnouncementSigning tests that the gossiper creates the
+// MuSig2 signature of the announcement of one of our taproot channels together
+// with the remote peer, and broadcasts the resulting ChannelAnnouncement2
+// along with our policy as a ChannelUpdate2.
+func TestTaprootChanAnnouncementSigning(t *testing.T) {
+	t.Parallel()
+
+	ctx, sentMsgs := newTaprootTestCtx(t)
+
+	batch, err := createLocalAnnouncements(0)
+	require.NoError(t, err, "can't generate announcements")
+
+	remotePeer := &mockPeer{remoteKeyPriv1.PubKey(), sentMsgs, nil}
+
+	// Add our channel along with our policy, which is sent directly to the
+	// remote peer.
+	for _, msg := range []lnwire.Message{batch.chanAnn, batch.chanUpdAnn1} {
+		select {
+		case err = <-ctx.gossiper.ProcessLocalAnnouncement(msg):
+		case <-time.After(2 * time.Second):
+			t.Fatal("did not process local announcement")
+		}
+		require.NoError(t, err, "unable to process local announcement")
+	}
+
+	select {
+	case msg := <-sentMsgs:
+		assertMessage(t, batch.chanUpdAnn1, msg)
+	case <-time.After(time.Second):
+		t.Fatal("gossiper did not send channel update to peer")
+	}
+
+	scid := batch.chanAnn.ShortChannelID
+	chanInfo, _, _, err := ctx.router.GetChannelByID(scid)
+	require.NoError(t, err)
+
+	// The remote peer signs the same announcement with its keys.
+	unsignedAnn, err := netann.UnsignedChanAnnouncement2(chanInfo)
+	require.NoError(t, err)
+
+	remoteSession, err := netann.NewChanAnn2Session(
+		newTestMuSig2Signer(remoteKeyPriv1, bitcoinKeyPriv2),
+		unsignedAnn, testKeyLoc, testBitcoinKeyLoc,
+	)
+	require.NoError(t, err)
+
+	// The funding manager kicks off the signing, which should make the
+	// gossiper send the nonces of its session to the remote peer.
+	localAnnSig := &lnwire.AnnounceSignatures2{
+		ChannelID:      batch.localProofAnn.ChannelID,
+		ShortChannelID: scid,
+	}
+	select {
+	case err = <-ctx.gossiper.ProcessLocalAnnouncement(localAnnSig):
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not process local announcement signature")
+	}
+	require.NoError(t, err, "unable to process local proof")
+
+	recvAnnSig := func() *lnwire.AnnounceSignatures2 {
+		t.Helper()
+
+		select {
+		case msg := <-sentMsgs:
+			annSig, ok := msg.(*lnwire.AnnounceSignatures2)
+			require.Truef(t, ok, "unexpected message %T", msg)
+
+			return annSig
+
+		case <-time.After(time.Second):
+			t.Fatal("gossiper did not send announcement signature")
+		}
+
+		return nil
+	}
+
+	nonces := func(annSig *lnwire.AnnounceSignatures2) (lnwire.Musig2Nonce,
+		lnwire.Musig2Nonce) {
+
+		t.Helper()
+
+		require.True(t, annSig.NodeNonce.IsSome())
+		require.True(t, annSig.BitcoinNonce.IsSome())
+
+		var nodeNonce, bitcoinNonce lnwire.Musig2Nonce
+		annSig.NodeNonce.WhenSomeV(func(n lnwire.Musig2Nonce) {
+			nodeNonce = n
+		})
+		annSig.BitcoinNonce.WhenSomeV(func(n lnwire.Musig2Nonce) {
+			bitcoinNonce = n
+		})
+
+		return nodeNonce, bitcoinNonce
+	}
+
+	annSig := recvAnnSig()
+	require.False(t, annSig.PartialSignature.IsSome())
+	localNodeNonce, localBitcoinNonce := nonces(annSig)
+
+	select {
+	case <-ctx.broadcastedMessage:
+		t.Fatal("announcements were broadcast")
+	case <-time.After(2 * trickleDelay):
+	}
+
+	// The remote peer can now sign, and sends its nonces along with its
+	// partial signature.
+	remoteSig, err := remoteSession.Sign(localNodeNonce, localBitcoinNonce)
+	require.NoError(t, err)
+
+	remoteNodeNonce, remoteBitcoinNonce := remoteSession.LocalNonces()
+	remoteAnnSig := &lnwire.AnnounceSignatures2{
+		ChannelID:      localAnnSig.ChannelID,
+		ShortChannelID: scid,
+		NodeNonce: tlv.SomeRecordT(
+			tlv.NewRecordT[tlv.TlvType1](remoteNodeNonce),
+		),
+		BitcoinNonce: tlv.SomeRecordT(
+			tlv.NewRecordT[tlv.TlvType3](remoteBitcoinNonce),
+		),
+		PartialSignature: tlv.SomeRecordT(
+			tlv.NewRecordT[tlv.TlvType5](*remoteSig),
+		),
+	}
+	select {
+	case err = <-ctx.gossiper.ProcessRemoteAnnouncement(
+		remoteAnnSig, remotePeer,
+	):
+	case <-time.After(2 * time.Second):
+		t.Fatal("did not process remote announcement signature")
+	}
+	require.NoError(t, err, "unable to process remote proof")
+
+	// The gossiper should send its partial signature to the remote peer,
+	// which can then create the final signature as well.
+	annSig = recvAnnSig()
+	nodeNonce, bitcoinNonce := nonces(annSig)
+	require.Equal(t, localNodeNonce, nodeNonce)
+	require.Equal(t, localBitcoinNonce, bitcoinNonce)
+
+	var localSig lnwire.PartialSig
+	require.True(t, annSig.PartialSignature.IsSome())
+	annSig.PartialSignature.WhenSomeV(func(sig lnwire.PartialSig) {
+		localSig = sig
+	})
+
+	finalSig, err := remoteSession.CombineSig(localSig)
+	require.NoError(t, err)
+
+	// Finally, the channel should be announced with a valid
+	// ChannelAnnouncement2 and our policy as a ChannelUpdate2.
+	var (
+		chanAnn    *lnwire.ChannelAnnouncement2
+		chanUpdate *lnwire.ChannelUpdate2
+	)
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-ctx.broadcastedMessage:
+			switch m := msg.msg.(type) {
+			case *lnwire.ChannelAnnouncement2:
+				chanAnn = m
+			case *lnwire.ChannelUpdate2:
+				chanUpdate = m
+			default:
+				t.Fatalf("unexpected broadcast %T", m)
+			}
+
+		case <-time.After(time.Second):
+			t.Fatal("announcement wasn't broadcast")
+		}
+	}
+
+	require.NotNil(t, chanAnn)
+	require.NoError(t, routing.ValidateChannelAnn2(chanAnn))
+	require.Equal(t, finalSig.Serialize(), chanAnn.Signature.RawBytes())
+
+	require.NotNil(t, chanUpdate)
+	require.NoError(t, routing.VerifyChannelUpdate2Signature(
+		chanUpdate, selfKeyPriv.PubKey(),
+	))
+
+	chanInfo, _, _, err = ctx.router.GetChannelByID(scid)
+	require.NoError(t, err)
+	require.Equal(t, lnwire.GossipVersion2, chanInfo.Version)
+	require.NotNil(t, chanInfo.AuthProof)
+}
+
+// TestTaprootAnnSigAwaitingAliasCleanup tests that the gossiper won't sign the
+// announcement of an option-scid-alias taproot channel on behalf of the remote
+// peer before the funding manager has processed the channel after six
+// confirmations.
+func TestTaprootAnnSigAwaitingAliasCleanup(t *testing.T) {
+	t.Parallel()
+
+	ctx, sentMsgs := newTaprootTestCtx(t)
+
+	batch, err := createLocalAnnouncements(0)
+	require.NoError(t, err, "can't generate announcements")
+
+	scid := batch.chanAnn.ShortChannelID
+	alias := lnwire.ShortChannelID{BlockHeight: 16_000_000}
+
+	// The alias of the channel maps to its confirmed SCID until the
+	// funding manager is done with the channel.
+	var cleanedUp atomic.Bool
+	ctx.gossiper.cfg.GetAliases = func(
+		base lnwire.ShortChannelID) []lnwire.ShortChannelID {
+
+		if base != scid {
+			return nil
+		}
+
+		return []lnwire.ShortChannelID{alias}
+	}
+	ctx.gossiper.cfg.FindBaseByAlias = func(
+		a lnwire.ShortChannelID) (lnwire.ShortChannelID, error) {
+
+		if a != alias || cleanedUp.Load() {
+			return lnwire.ShortChannelID{}, fmt.Errorf("no base")
+		}
+
+		return scid, nil
+	}
+
+	for _, msg := range []lnwire.Message{batch.chanAnn, batch.chanUpdAnn1} {
+		select {
+		case err = <-ctx.gossiper.ProcessLocalAnnouncement(msg):
+		case <-time.After(2 * time.Second):
+			t.Fatal("did not process local announcement")
+		}
+		require.NoError(t, err, "unable to process local announcement")
+	}
+
+	select {
+	case msg := <-sentMsgs:
+		assertMessage(t, batch.chanUpdAnn1, msg)
+	case <-time.After(time.Second):
+		t.Fatal("gossiper did not send channel update to peer")
+	}
+
+	chanInfo, _, _, err := ctx.router.GetChannelByID(scid)
+	require.NoError(t, err)
+
+	unsignedAnn, err := netann.UnsignedChanAnnouncement2(chanInfo)
+	require.NoError(t, err)
+
+	remoteSession, err := netann.NewChanAnn2Session(
+		newTestMuSig2Signer(remoteKeyPriv1, bitcoinKeyPriv2),
+		unsignedAnn, testKeyLoc, testBitcoinKeyLoc,
+	)
+	require.NoError(t, err)
+
+	// The remote peer starts the signing by sending its nonces.
+	remoteNodeNonce, remoteBitcoinNonce := remoteSession.LocalNonces()
+	remoteAnnSig := &lnwire.AnnounceSignatures2{
+		ChannelID:      batch.localProofAnn.ChannelID,
+		ShortChannelID: scid,
+		NodeNonce: tlv.SomeRecordT(
+			tlv.NewRecordT[tlv.TlvType1](remoteNodeNonce),
+		),
+		BitcoinNonce: tlv.SomeRecordT(
+			tlv.NewRecordT[tlv.TlvType3](remoteBitcoinNonce),
+		),
+	}
+	remotePeer := &mockPeer{remoteKeyPriv1.PubKey(), sentMsgs, nil}
+	processRemote := func() {
+		t.Helper()
+
+		select {
+		case err = <-ctx.gossiper.ProcessRemoteAnnouncement(
+			remoteAnnSig, remotePeer,
+		):
+		case <-time.After(2 * time.Second):
+			t.Fatal("did not process remote announcement signature")
+		}
+		require.NoError(t, err, "unable to process remote proof")
+	}
+
+	// As the funding manager hasn't processed the channel yet, we
+	// shouldn't sign.
+	processRemote()
+
+	select {
+	case msg := <-sentMsgs:
+		t.Fatalf("unexpected message sent: %T", msg)
+	case <-time.After(2 * trickleDelay):
+	}
+
+	// Once the alias mappings are gone, the nonces of the remote peer
+	// should be signed with right away.
+	cleanedUp.Store(true)
+	processRemote()
+
+	select {
+	case msg := <-sentMsgs:
+		annSig, ok := msg.(*lnwire.AnnounceSignatures2)
+		require.Truef(t, ok, "unexpected message %T", msg)
+		require.True(t, annSig.NodeNonce.IsSome())
+		require.True(t, annSig.PartialSignature.IsSome())
+
+	case <-time.After(time.Second):
+		t.Fatal("gossiper did not send announcement signature")
+	}
+}
@@ -0,0 +1,194 @@
+package electrum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightninglabs/neutrino/cache/lru"
+)
+
+const (
+	// ElectrumBackendName is the name of the backend that talks to an
+	// Electrum protocol server.
+	ElectrumBackendName = "electrum"
+
+	// EsploraBackendName is the name of the backend that talks to an
+	// Esplora REST API.
+	EsploraBackendName = "esplora"
+
+	// defaultRelayFee is the relay fee in sat/kvB assumed for backends
+	// that don't report one. It matches the default minimum relay fee of
+	// bitcoind.
+	defaultRelayFee = btcutil.Amount(1000)
+
+	// headerCacheSize is the number of block headers that are cached by
+	// their hash.
+	headerCacheSize = 10000
+)
+
+var (
+	// ErrBlocksUnsupported is returned by backends that can't serve full
+	// blocks, like Electrum servers.
+	ErrBlocksUnsupported = errors.New("backend does not serve full " +
+		"blocks")
+
+	// ErrTxNotFound is returned if the backend doesn't know a
+	// transaction.
+	ErrTxNotFound = errors.New("transaction not found")
+
+	// ErrBlockNotFound is returned if the backend doesn't know a block.
+	ErrBlockNotFound = errors.New("block not found")
+
+	// ErrNoFeeEstimate is returned if the backend has no fee estimate for
+	// the requested confirmation target.
+	ErrNoFeeEstimate = errors.New("no fee estimate available")
+)
+
+// HistoryItem is a transaction that pays to or spends from a script.
+type HistoryItem struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Height is the height of the block that includes the transaction.
+	// Transactions in the mempool have a height of zero or below.
+	Height int32
+}
+
+// Confirmed returns true if the transaction is included in a block.
+func (h HistoryItem) Confirmed() bool {
+	return h.Height > 0
+}
+
+// Backend is a chain backend that indexes transactions by the scripts they
+// pay to and spend from, like an Electrum server or an Esplora API. Instead of
+// scanning blocks, the users of a backend look up the history of the scripts
+// they watch.
+type Backend interface {
+	// Name returns the name of the backend.
+	Name() string
+
+	// BestBlock returns the hash and height of the tip of the best chain.
+	BestBlock() (*chainhash.Hash, int32, error)
+
+	// BlockHash returns the hash of the block at the given height in the
+	// best chain.
+	BlockHash(height int32) (*chainhash.Hash, error)
+
+	// BlockHeader returns the header and height of the block with the
+	// given hash.
+	BlockHeader(hash *chainhash.Hash) (*wire.BlockHeader, int32, error)
+
+	// Block returns the full block with the given hash. Backends that
+	// don't serve blocks return ErrBlocksUnsupported.
+	Block(hash *chainhash.Hash) (*wire.MsgBlock, error)
+
+	// ScriptHistory returns all confirmed and unconfirmed transactions
+	// that pay to or spend from the given output script, ordered by
+	// height.
+	ScriptHistory(pkScript []byte) ([]HistoryItem, error)
+
+	// Transaction returns the transaction with the given hash.
+	Transaction(txid *chainhash.Hash) (*wire.MsgTx, error)
+
+	// TxIndex returns the index of a transaction within the block at the
+	// given height.
+	TxIndex(txid *chainhash.Hash, height int32) (uint32, error)
+
+	// Broadcast publishes a transaction to the network.
+	Broadcast(tx *wire.MsgTx) error
+
+	// EstimateFee returns the fee rate in sat/kvB for a transaction to
+	// confirm within the given number of blocks.
+	EstimateFee(numBlocks uint32) (btcutil.Amount, error)
+
+	// RelayFee returns the minimum fee rate in sat/kvB of transactions
+	// relayed by the backend.
+	RelayFee() (btcutil.Amount, error)
+
+	// Close closes the connection to the backend.
+	Close() error
+}
+
+// ScriptHash returns the hash of an output script that Electrum servers and
+// Esplora APIs index transactions by: the hex encoded SHA256 of the script in
+// reverse byte order.
+func ScriptHash(pkScript []byte) string {
+	hash := sha256.Sum256(pkScript)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+
+	return hex.EncodeToString(hash[:])
+}
+
+// sortHistory orders the history of a script by height, with unconfirmed
+// transactions last.
+func sortHistory(history []HistoryItem) {
+	sort.SliceStable(history, func(i, j int) bool {
+		a, b := history[i], history[j]
+		switch {
+		case a.Confirmed() && b.Confirmed():
+			return a.Height < b.Height
+
+		default:
+			return a.Confirmed() && !b.Confirmed()
+		}
+	})
+}
+
+// cachedHeader is a block header with its height that is cached by the hash
+// of the block.
+type cachedHeader struct {
+	header wire.BlockHeader
+	height int32
+}
+
+// Size returns the size of the entry in the cache. Every header counts as
+// one entry.
+//
+// NOTE: This is part of the cache.Value interface.
+func (c *cachedHeader) Size() (uint64, error) {
+	return 1, nil
+}
+
+// headerCache caches block headers and their heights by block hash. As the
+// hash commits to the header, entries never have to be invalidated.
+type headerCache struct {
+	cache *lru.Cache[chainhash.Hash, *cachedHeader]
+}
+
+// newHeaderCache creates a new header cache.
+func newHeaderCache() *headerCache {
+	return &headerCache{
+		cache: lru.NewCache[chainhash.Hash, *cachedHeader](
+			headerCacheSize,
+		),
+	}
+}
+
+// add caches a header and its height.
+func (h *headerCache) add(header *wire.BlockHeader, height int32) {
+	_, _ = h.cache.Put(header.BlockHash(), &cachedHeader{
+		header: *header,
+		height: height,
+	})
+}
+
+// get returns the cached header and height of a block, if known.
+func (h *headerCache) get(hash *chainhash.Hash) (*wire.BlockHeader, int32,
+	bool) {
+
+	entry, err := h.cache.Get(*hash)
+	if err != nil {
+		return nil, 0, false
+	}
+
+	header := entry.header
+
+	return &header, entry.height, true
+}
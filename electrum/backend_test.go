@@ -0,0 +1,235 @@
+package electrum
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+var testParams = &chaincfg.RegressionNetParams
+
+// testScript returns a unique P2WPKH output script for the given index.
+func testScript(t *testing.T, i byte) []byte {
+	t.Helper()
+
+	var pubKeyHash [20]byte
+	pubKeyHash[0] = i
+
+	pkScript, err := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_0).
+		AddData(pubKeyHash[:]).
+		Script()
+	require.NoError(t, err)
+
+	return pkScript
+}
+
+// testAddress returns the address of a script created with testScript.
+func testAddress(t *testing.T, pkScript []byte) btcutil.Address {
+	t.Helper()
+
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, testParams)
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+
+	return addrs[0]
+}
+
+// newTestTx creates a transaction that spends the given outpoint and pays
+// to the given scripts. A zero outpoint is replaced by a unique dummy input.
+func newTestTx(prevOut wire.OutPoint, pkScripts ...[]byte) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	if prevOut == (wire.OutPoint{}) {
+		prevOut.Hash = chainhash.Hash{byte(len(pkScripts)), 0xff}
+		prevOut.Index = nextDummyIndex()
+	}
+	tx.AddTxIn(wire.NewTxIn(&prevOut, nil, nil))
+	for _, pkScript := range pkScripts {
+		tx.AddTxOut(wire.NewTxOut(10_000, pkScript))
+	}
+
+	return tx
+}
+
+// dummyIndex is the last output index used for a dummy input.
+var dummyIndex uint32
+
+// nextDummyIndex returns a new output index for dummy inputs, so that
+// transactions created by newTestTx have unique hashes.
+func nextDummyIndex() uint32 {
+	return atomic.AddUint32(&dummyIndex, 1)
+}
+
+// backendScenario is a chain with a funding transaction, a spend of it and an
+// unconfirmed spend of the spend.
+type backendScenario struct {
+	scriptA []byte
+	scriptB []byte
+
+	fundingTx *wire.MsgTx
+	spendTx   *wire.MsgTx
+	mempoolTx *wire.MsgTx
+}
+
+// newBackendScenario sets up the scenario on the given mock backend. The
+// funding transaction pays to script A in block 1, the spend pays to script B
+// as the second transaction of block 2 and the unconfirmed transaction spends
+// the output of the spend.
+func newBackendScenario(t *testing.T, mock *MockBackend) *backendScenario {
+	s := &backendScenario{
+		scriptA: testScript(t, 1),
+		scriptB: testScript(t, 2),
+	}
+
+	s.fundingTx = newTestTx(wire.OutPoint{}, s.scriptA)
+	s.spendTx = newTestTx(
+		wire.OutPoint{Hash: s.fundingTx.TxHash()}, s.scriptB,
+	)
+	s.mempoolTx = newTestTx(
+		wire.OutPoint{Hash: s.spendTx.TxHash()}, testScript(t, 3),
+	)
+
+	mock.AddBlock(s.fundingTx)
+	mock.AddBlock(newTestTx(wire.OutPoint{}), s.spendTx)
+	mock.AddMempoolTx(s.mempoolTx)
+
+	return s
+}
+
+// testBackend checks that a backend reports the scenario set up on the mock
+// backend it talks to.
+func testBackend(t *testing.T, backend Backend, mock *MockBackend,
+	s *backendScenario) {
+
+	t.Helper()
+
+	// The tip is block 2.
+	tipHash, tipHeight, err := backend.BestBlock()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, tipHeight)
+
+	mockHash, err := mock.BlockHash(2)
+	require.NoError(t, err)
+	require.Equal(t, mockHash, tipHash)
+
+	// The headers of all blocks can be looked up.
+	for height := int32(0); height <= tipHeight; height++ {
+		hash, err := backend.BlockHash(height)
+		require.NoError(t, err)
+
+		mockHash, err := mock.BlockHash(height)
+		require.NoError(t, err)
+		require.Equal(t, mockHash, hash)
+
+		header, headerHeight, err := backend.BlockHeader(hash)
+		require.NoError(t, err)
+		require.Equal(t, height, headerHeight)
+		require.Equal(t, *hash, header.BlockHash())
+	}
+	_, err = backend.BlockHash(tipHeight + 1)
+	require.Error(t, err)
+
+	// The histories contain confirmed transactions before unconfirmed
+	// ones.
+	fundingHash := s.fundingTx.TxHash()
+	spendHash := s.spendTx.TxHash()
+	mempoolHash := s.mempoolTx.TxHash()
+
+	history, err := backend.ScriptHistory(s.scriptA)
+	require.NoError(t, err)
+	require.Equal(t, []HistoryItem{
+		{TxHash: fundingHash, Height: 1},
+		{TxHash: spendHash, Height: 2},
+	}, history)
+
+	history, err = backend.ScriptHistory(s.scriptB)
+	require.NoError(t, err)
+	require.Equal(t, []HistoryItem{
+		{TxHash: spendHash, Height: 2},
+		{TxHash: mempoolHash, Height: 0},
+	}, history)
+
+	history, err = backend.ScriptHistory(testScript(t, 4))
+	require.NoError(t, err)
+	require.Empty(t, history)
+
+	// Transactions can be fetched with their position in the block.
+	tx, err := backend.Transaction(&spendHash)
+	require.NoError(t, err)
+	require.Equal(t, spendHash, tx.TxHash())
+
+	txIndex, err := backend.TxIndex(&spendHash, 2)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, txIndex)
+
+	unknownHash := chainhash.Hash{0x01}
+	_, err = backend.Transaction(&unknownHash)
+	require.ErrorIs(t, err, ErrTxNotFound)
+
+	// A broadcast transaction ends up in the mempool.
+	broadcastTx := newTestTx(wire.OutPoint{}, testScript(t, 5))
+	require.NoError(t, backend.Broadcast(broadcastTx))
+
+	var mempool []chainhash.Hash
+	for _, tx := range mock.Mempool() {
+		mempool = append(mempool, tx.TxHash())
+	}
+	require.Contains(t, mempool, broadcastTx.TxHash())
+
+	// Fee estimates are only available if the mock has a fee rate.
+	mock.SetFeeRate(0)
+	_, err = backend.EstimateFee(6)
+	require.ErrorIs(t, err, ErrNoFeeEstimate)
+
+	mock.SetFeeRate(20_000)
+	fee, err := backend.EstimateFee(6)
+	require.NoError(t, err)
+	require.EqualValues(t, 20_000, fee)
+
+	relayFee, err := backend.RelayFee()
+	require.NoError(t, err)
+	require.Equal(t, defaultRelayFee, relayFee)
+}
+
+// TestMockBackend checks that the mock backend used to test the clients
+// reports the test scenario itself.
+func TestMockBackend(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	s := newBackendScenario(t, mock)
+
+	testBackend(t, mock, mock, s)
+
+	// Blocks are served unless disabled.
+	hash, err := mock.BlockHash(1)
+	require.NoError(t, err)
+
+	block, err := mock.Block(hash)
+	require.NoError(t, err)
+	require.Equal(t, s.fundingTx, block.Transactions[0])
+
+	mock.BlocksUnsupported = true
+	_, err = mock.Block(hash)
+	require.ErrorIs(t, err, ErrBlocksUnsupported)
+
+	// Disconnecting blocks returns their transactions to the mempool.
+	mock.DisconnectBlocks(1)
+	_, height, err := mock.BestBlock()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, height)
+	require.Contains(t, mock.Mempool(), s.spendTx)
+
+	history, err := mock.ScriptHistory(s.scriptA)
+	require.NoError(t, err)
+	require.Equal(t, []HistoryItem{
+		{TxHash: s.fundingTx.TxHash(), Height: 1},
+		{TxHash: s.spendTx.TxHash(), Height: 0},
+	}, history)
+}
@@ -0,0 +1,871 @@
+package electrum
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightningnetwork/lnd/queue"
+)
+
+const (
+	// DefaultPollInterval is the default interval in which the tip of the
+	// chain and the watched scripts are polled.
+	DefaultPollInterval = 10 * time.Second
+
+	// isCurrentDelta is the maximum age of the best block for the backend
+	// to be considered current.
+	isCurrentDelta = 2 * time.Hour
+)
+
+var (
+	// ErrOutputSpent is returned by GetUtxo if the output has been spent.
+	ErrOutputSpent = errors.New("target output has been spent")
+
+	// ErrOutputNotFound is returned by GetUtxo if the output can't be
+	// found.
+	ErrOutputNotFound = errors.New("target output was not found")
+)
+
+// ChainClient implements the chain.Interface of btcwallet on top of a
+// Backend. Instead of scanning blocks, it syncs the wallet by looking up the
+// history of the wallet's addresses.
+type ChainClient struct {
+	*ChainConn
+
+	started int32 // To be used atomically.
+	stopped int32 // To be used atomically.
+
+	backend      Backend
+	chainParams  *chaincfg.Params
+	pollInterval time.Duration
+
+	notificationQueue *queue.ConcurrentQueue
+
+	// watchMtx guards watchedScripts.
+	watchMtx sync.Mutex
+
+	// watchedScripts are the output scripts of the addresses and outputs
+	// the wallet watches, keyed by their script hash.
+	watchedScripts map[string][]byte
+
+	// updateMtx serializes chain updates and rescans and guards the fields
+	// below.
+	updateMtx sync.Mutex
+
+	// bestBlock is the last block the wallet was notified about.
+	bestBlock waddrmgr.BlockStamp
+
+	// notifiedTxs holds the height at which each relevant transaction was
+	// last notified, zero for transactions in the mempool.
+	notifiedTxs map[chainhash.Hash]int32
+
+	// notifyBlocks is set once the wallet requested block notifications.
+	notifyBlocks bool
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile time check to ensure ChainClient implements the chain.Interface
+// of btcwallet.
+var _ chain.Interface = (*ChainClient)(nil)
+
+// NewChainClient creates a new wallet chain client backed by the given
+// Backend, which polls for new blocks and transactions in the given
+// interval.
+func NewChainClient(backend Backend, chainParams *chaincfg.Params,
+	pollInterval time.Duration) *ChainClient {
+
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+
+	return &ChainClient{
+		ChainConn:         NewChainConn(backend),
+		backend:           backend,
+		chainParams:       chainParams,
+		pollInterval:      pollInterval,
+		notificationQueue: queue.NewConcurrentQueue(20),
+		watchedScripts:    make(map[string][]byte),
+		notifiedTxs:       make(map[chainhash.Hash]int32),
+		quit:              make(chan struct{}),
+	}
+}
+
+// Start fetches the tip of the chain and starts polling the backend for
+// updates.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) Start() error {
+	if atomic.AddInt32(&c.started, 1) != 1 {
+		return nil
+	}
+
+	hash, height, err := c.backend.BestBlock()
+	if err != nil {
+		return fmt.Errorf("unable to fetch best block: %w", err)
+	}
+	header, err := c.GetBlockHeader(hash)
+	if err != nil {
+		return fmt.Errorf("unable to fetch best block header: %w", err)
+	}
+
+	c.updateMtx.Lock()
+	c.bestBlock = waddrmgr.BlockStamp{
+		Hash:      *hash,
+		Height:    height,
+		Timestamp: header.Timestamp,
+	}
+	c.updateMtx.Unlock()
+
+	c.notificationQueue.Start()
+	c.notify(chain.ClientConnected{})
+
+	c.wg.Add(1)
+	go c.pollLoop()
+
+	return nil
+}
+
+// Stop stops polling the backend. The backend itself isn't closed, as it's
+// shared with the other users of the chain backend.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) Stop() {
+	if atomic.AddInt32(&c.stopped, 1) != 1 {
+		return
+	}
+
+	close(c.quit)
+	c.wg.Wait()
+
+	if atomic.LoadInt32(&c.started) != 0 {
+		c.notificationQueue.Stop()
+	}
+}
+
+// WaitForShutdown blocks until the client has stopped polling.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) WaitForShutdown() {
+	c.wg.Wait()
+}
+
+// Notifications returns the channel the wallet receives chain notifications
+// on.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) Notifications() <-chan interface{} {
+	return c.notificationQueue.ChanOut()
+}
+
+// notify queues a notification for the wallet.
+func (c *ChainClient) notify(ntfn interface{}) {
+	select {
+	case c.notificationQueue.ChanIn() <- ntfn:
+	case <-c.quit:
+	}
+}
+
+// BackEnd returns the name of the backend.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) BackEnd() string {
+	return c.backend.Name()
+}
+
+// GetBestBlock returns the hash and height of the tip of the best chain.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) GetBestBlock() (*chainhash.Hash, int32, error) {
+	return c.backend.BestBlock()
+}
+
+// GetBlock returns the block with the given hash, if the backend serves full
+// blocks.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) GetBlock(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	return c.backend.Block(hash)
+}
+
+// BlockStamp returns the last block the wallet was notified about.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) BlockStamp() (*waddrmgr.BlockStamp, error) {
+	c.updateMtx.Lock()
+	defer c.updateMtx.Unlock()
+
+	bestBlock := c.bestBlock
+
+	return &bestBlock, nil
+}
+
+// IsCurrent returns true if the best block of the backend is recent.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) IsCurrent() bool {
+	bestHash, _, err := c.GetBestBlock()
+	if err != nil {
+		return false
+	}
+	bestHeader, err := c.GetBlockHeader(bestHash)
+	if err != nil {
+		return false
+	}
+
+	return bestHeader.Timestamp.After(time.Now().Add(-isCurrentDelta))
+}
+
+// SendRawTransaction broadcasts a transaction through the backend.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) SendRawTransaction(tx *wire.MsgTx,
+	_ bool) (*chainhash.Hash, error) {
+
+	if err := c.backend.Broadcast(tx); err != nil {
+		return nil, err
+	}
+
+	txHash := tx.TxHash()
+
+	return &txHash, nil
+}
+
+// TestMempoolAccept isn't supported by the backends, so the wallet falls back
+// to broadcasting transactions directly.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) TestMempoolAccept([]*wire.MsgTx,
+	float64) ([]*btcjson.TestMempoolAcceptResult, error) {
+
+	return nil, rpcclient.ErrBackendVersion
+}
+
+// NotifyBlocks requests notifications for connected and disconnected blocks.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) NotifyBlocks() error {
+	c.updateMtx.Lock()
+	c.notifyBlocks = true
+	c.updateMtx.Unlock()
+
+	return nil
+}
+
+// NotifyReceived adds the addresses to the set of addresses whose
+// transactions are sent to the wallet.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) NotifyReceived(addrs []btcutil.Address) error {
+	_, err := c.watchAddrs(addrs)
+	return err
+}
+
+// watchAddrs adds the addresses to the watched scripts and returns their
+// output scripts.
+func (c *ChainClient) watchAddrs(addrs []btcutil.Address) ([][]byte, error) {
+	pkScripts := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to watch address %v: %w",
+				addr, err)
+		}
+		pkScripts = append(pkScripts, pkScript)
+	}
+
+	c.watchMtx.Lock()
+	for _, pkScript := range pkScripts {
+		c.watchedScripts[ScriptHash(pkScript)] = pkScript
+	}
+	c.watchMtx.Unlock()
+
+	return pkScripts, nil
+}
+
+// watched returns the output scripts of all watched addresses.
+func (c *ChainClient) watched() [][]byte {
+	c.watchMtx.Lock()
+	defer c.watchMtx.Unlock()
+
+	pkScripts := make([][]byte, 0, len(c.watchedScripts))
+	for _, pkScript := range c.watchedScripts {
+		pkScripts = append(pkScripts, pkScript)
+	}
+
+	return pkScripts
+}
+
+// histories returns the height of every transaction in the histories of the
+// given scripts.
+func (c *ChainClient) histories(
+	pkScripts [][]byte) (map[chainhash.Hash]int32, error) {
+
+	txHeights := make(map[chainhash.Hash]int32)
+	for _, pkScript := range pkScripts {
+		select {
+		case <-c.quit:
+			return nil, errors.New("chain client shutting down")
+		default:
+		}
+
+		history, err := c.backend.ScriptHistory(pkScript)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range history {
+			height := item.Height
+			if !item.Confirmed() {
+				height = 0
+			}
+			txHeights[item.TxHash] = height
+		}
+	}
+
+	return txHeights, nil
+}
+
+// blockMeta returns the hash, height and time of the block at the given
+// height.
+func (c *ChainClient) blockMeta(height int32) (*wtxmgr.BlockMeta, error) {
+	hash, err := c.backend.BlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+	header, err := c.GetBlockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{
+			Hash:   *hash,
+			Height: height,
+		},
+		Time: header.Timestamp,
+	}, nil
+}
+
+// txRecord fetches a transaction and creates its wallet record.
+func (c *ChainClient) txRecord(txHash *chainhash.Hash,
+	received time.Time) (*wtxmgr.TxRecord, error) {
+
+	tx, err := c.backend.Transaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return wtxmgr.NewTxRecordFromMsgTx(tx, received)
+}
+
+// pollLoop periodically looks for new blocks and transactions.
+func (c *ChainClient) pollLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.update(); err != nil {
+				log.Errorf("Unable to update wallet chain "+
+					"state: %v", err)
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// update notifies the wallet of blocks that were disconnected and connected
+// since the last update, and of new relevant transactions.
+func (c *ChainClient) update() error {
+	c.updateMtx.Lock()
+	defer c.updateMtx.Unlock()
+
+	tipHash, tipHeight, err := c.backend.BestBlock()
+	if err != nil {
+		return err
+	}
+
+	// First, we'll rewind to the last block we notified about that is
+	// still part of the best chain.
+	if *tipHash != c.bestBlock.Hash {
+		if err := c.rewind(tipHeight); err != nil {
+			return err
+		}
+	}
+
+	txHeights, err := c.histories(c.watched())
+	if err != nil {
+		return err
+	}
+
+	// Group the transactions we haven't notified about by the height of
+	// the block that includes them.
+	newTxs := make(map[int32][]chainhash.Hash)
+	for txHash, height := range txHeights {
+		notifiedHeight, ok := c.notifiedTxs[txHash]
+		if ok && notifiedHeight == height {
+			continue
+		}
+
+		newTxs[height] = append(newTxs[height], txHash)
+	}
+
+	// Transactions can show up in the history of a script only after we
+	// notified about the block that includes them, so we'll send them on
+	// their own.
+	for height, txHashes := range newTxs {
+		if height == 0 || height > c.bestBlock.Height {
+			continue
+		}
+
+		meta, err := c.blockMeta(height)
+		if err != nil {
+			return err
+		}
+
+		for _, txHash := range txHashes {
+			txHash := txHash
+			rec, err := c.txRecord(&txHash, meta.Time)
+			if err != nil {
+				return err
+			}
+
+			c.notify(chain.RelevantTx{
+				TxRecord: rec,
+				Block:    meta,
+			})
+			c.notifiedTxs[txHash] = height
+		}
+	}
+
+	// Then we'll connect the new blocks, along with the transactions
+	// they include.
+	for height := c.bestBlock.Height + 1; height <= tipHeight; height++ {
+		meta, err := c.blockMeta(height)
+		if err != nil {
+			return err
+		}
+
+		var records []*wtxmgr.TxRecord
+		for _, txHash := range newTxs[height] {
+			txHash := txHash
+			rec, err := c.txRecord(&txHash, meta.Time)
+			if err != nil {
+				return err
+			}
+			records = append(records, rec)
+		}
+
+		if c.notifyBlocks {
+			c.notify(chain.FilteredBlockConnected{
+				Block:       meta,
+				RelevantTxs: records,
+			})
+			c.notify(chain.BlockConnected(*meta))
+		} else {
+			for _, rec := range records {
+				c.notify(chain.RelevantTx{
+					TxRecord: rec,
+					Block:    meta,
+				})
+			}
+		}
+
+		for _, rec := range records {
+			c.notifiedTxs[rec.Hash] = height
+		}
+		c.bestBlock = waddrmgr.BlockStamp{
+			Hash:      meta.Hash,
+			Height:    height,
+			Timestamp: meta.Time,
+		}
+	}
+
+	// Finally, we'll notify the wallet of new transactions in the mempool.
+	for _, txHash := range newTxs[0] {
+		txHash := txHash
+		rec, err := c.txRecord(&txHash, time.Now())
+		if errors.Is(err, ErrTxNotFound) {
+			// The transaction might have been evicted from the
+			// mempool in the meantime.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		c.notify(chain.RelevantTx{
+			TxRecord: rec,
+		})
+		c.notifiedTxs[txHash] = 0
+	}
+
+	return nil
+}
+
+// rewind notifies the wallet of the blocks that were disconnected from the
+// best chain, until the last notified block is part of the best chain again.
+//
+// NOTE: Must be called with the updateMtx held.
+func (c *ChainClient) rewind(tipHeight int32) error {
+	for c.bestBlock.Height > 0 {
+		if c.bestBlock.Height <= tipHeight {
+			hash, err := c.backend.BlockHash(c.bestBlock.Height)
+			if err != nil {
+				return err
+			}
+			if *hash == c.bestBlock.Hash {
+				return nil
+			}
+		}
+
+		header, err := c.GetBlockHeader(&c.bestBlock.Hash)
+		if err != nil {
+			return err
+		}
+		prevHeader, err := c.GetBlockHeader(&header.PrevBlock)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Block %v at height %v was disconnected",
+			c.bestBlock.Hash, c.bestBlock.Height)
+
+		if c.notifyBlocks {
+			c.notify(chain.BlockDisconnected(wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{
+					Hash:   c.bestBlock.Hash,
+					Height: c.bestBlock.Height,
+				},
+				Time: c.bestBlock.Timestamp,
+			}))
+		}
+
+		// Transactions of the disconnected block have to be notified
+		// again once they confirm in another block.
+		for txHash, height := range c.notifiedTxs {
+			if height >= c.bestBlock.Height {
+				delete(c.notifiedTxs, txHash)
+			}
+		}
+
+		c.bestBlock = waddrmgr.BlockStamp{
+			Hash:      header.PrevBlock,
+			Height:    c.bestBlock.Height - 1,
+			Timestamp: prevHeader.Timestamp,
+		}
+	}
+
+	return nil
+}
+
+// Rescan looks up the history of the given addresses and outputs and sends
+// the transactions confirmed since the given block, or unconfirmed, to the
+// wallet. The addresses and outputs are watched from now on.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) Rescan(startHash *chainhash.Hash,
+	addrs []btcutil.Address,
+	outPoints map[wire.OutPoint]btcutil.Address) error {
+
+	// Spends of the outputs are part of the history of their addresses.
+	for _, addr := range outPoints {
+		addrs = append(addrs, addr)
+	}
+	pkScripts, err := c.watchAddrs(addrs)
+	if err != nil {
+		return err
+	}
+
+	// As transactions are looked up by address, the start of the rescan
+	// only filters the transactions we send. If the backend doesn't know
+	// the start block, we send all of them, which the wallet ignores if
+	// it already knows them.
+	var startHeight int32
+	if startHash != nil {
+		_, height, err := c.backend.BlockHeader(startHash)
+		if err == nil {
+			startHeight = height
+		} else {
+			log.Debugf("Unable to look up rescan start block %v, "+
+				"rescanning from genesis: %v", startHash, err)
+		}
+	}
+
+	c.updateMtx.Lock()
+	defer c.updateMtx.Unlock()
+
+	log.Infof("Rescanning %d addresses from height %d to %d", len(addrs),
+		startHeight, c.bestBlock.Height)
+
+	txHeights, err := c.histories(pkScripts)
+	if err != nil {
+		return err
+	}
+
+	txHashes := make([]chainhash.Hash, 0, len(txHeights))
+	for txHash, height := range txHeights {
+		// Transactions confirmed before the start of the rescan are
+		// already known to the wallet, and transactions confirmed
+		// after our best block will be sent once their block is
+		// connected.
+		if height != 0 && height < startHeight {
+			c.notifiedTxs[txHash] = height
+			continue
+		}
+		if height > c.bestBlock.Height {
+			continue
+		}
+		txHashes = append(txHashes, txHash)
+	}
+
+	// Send the confirmed transactions in the order they confirmed in, and
+	// the unconfirmed ones last.
+	sort.Slice(txHashes, func(i, j int) bool {
+		a, b := txHeights[txHashes[i]], txHeights[txHashes[j]]
+		if a == 0 || b == 0 {
+			return a != 0 && b == 0
+		}
+
+		return a < b
+	})
+
+	var meta *wtxmgr.BlockMeta
+	for _, txHash := range txHashes {
+		txHash := txHash
+		height := txHeights[txHash]
+
+		received := time.Now()
+		if height != 0 {
+			if meta == nil || meta.Height != height {
+				meta, err = c.blockMeta(height)
+				if err != nil {
+					return err
+				}
+			}
+			received = meta.Time
+		}
+
+		rec, err := c.txRecord(&txHash, received)
+		if err != nil {
+			return err
+		}
+
+		ntfn := chain.RelevantTx{
+			TxRecord: rec,
+		}
+		if height != 0 {
+			ntfn.Block = meta
+		}
+		c.notify(ntfn)
+		c.notifiedTxs[txHash] = height
+	}
+
+	c.notify(&chain.RescanFinished{
+		Hash:   &c.bestBlock.Hash,
+		Height: c.bestBlock.Height,
+		Time:   c.bestBlock.Timestamp,
+	})
+
+	return nil
+}
+
+// FilterBlocks looks up the history of the addresses and outputs of the
+// request and returns the relevant transactions of the first block of the
+// request that includes any.
+//
+// NOTE: This is part of the chain.Interface interface.
+func (c *ChainClient) FilterBlocks(
+	req *chain.FilterBlocksRequest) (*chain.FilterBlocksResponse, error) {
+
+	addrs := make(
+		[]btcutil.Address, 0,
+		len(req.ExternalAddrs)+len(req.InternalAddrs)+
+			len(req.WatchedOutPoints),
+	)
+	for _, addr := range req.ExternalAddrs {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range req.InternalAddrs {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range req.WatchedOutPoints {
+		addrs = append(addrs, addr)
+	}
+
+	pkScripts := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		pkScripts = append(pkScripts, pkScript)
+	}
+
+	txHeights, err := c.histories(pkScripts)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTxs := make(map[int32][]chainhash.Hash)
+	for txHash, height := range txHeights {
+		if height != 0 {
+			blockTxs[height] = append(blockTxs[height], txHash)
+		}
+	}
+
+	blockFilterer := chain.NewBlockFilterer(c.chainParams, req)
+	for i, block := range req.Blocks {
+		txHashes, ok := blockTxs[block.Height]
+		if !ok {
+			continue
+		}
+
+		hash, err := c.backend.BlockHash(block.Height)
+		if err != nil {
+			return nil, err
+		}
+		if *hash != block.Hash {
+			return nil, fmt.Errorf("block %v at height %v is no "+
+				"longer part of the best chain", block.Hash,
+				block.Height)
+		}
+
+		msgBlock, err := c.partialBlock(txHashes, block.Height)
+		if err != nil {
+			return nil, err
+		}
+
+		if !blockFilterer.FilterBlock(msgBlock) {
+			continue
+		}
+
+		return &chain.FilterBlocksResponse{
+			BatchIndex:         uint32(i),
+			BlockMeta:          block,
+			FoundExternalAddrs: blockFilterer.FoundExternal,
+			FoundInternalAddrs: blockFilterer.FoundInternal,
+			FoundOutPoints:     blockFilterer.FoundOutPoints,
+			RelevantTxns:       blockFilterer.RelevantTxns,
+		}, nil
+	}
+
+	// No addresses were found for this range.
+	return nil, nil
+}
+
+// partialBlock fetches the given transactions of the block at the given
+// height and returns them in the order they appear in the block.
+func (c *ChainClient) partialBlock(txHashes []chainhash.Hash,
+	height int32) (*wire.MsgBlock, error) {
+
+	type indexedTx struct {
+		tx    *wire.MsgTx
+		index uint32
+	}
+
+	txs := make([]indexedTx, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		txHash := txHash
+		tx, err := c.backend.Transaction(&txHash)
+		if err != nil {
+			return nil, err
+		}
+
+		// The order matters if one transaction spends another.
+		index, err := c.backend.TxIndex(&txHash, height)
+		if err != nil {
+			return nil, err
+		}
+
+		txs = append(txs, indexedTx{tx: tx, index: index})
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].index < txs[j].index
+	})
+
+	msgBlock := &wire.MsgBlock{}
+	for _, tx := range txs {
+		msgBlock.Transactions = append(msgBlock.Transactions, tx.tx)
+	}
+
+	return msgBlock, nil
+}
+
+// GetUtxo returns the output referenced by the outpoint if it's confirmed and
+// unspent. The output and its spends are looked up in the history of its
+// output script.
+func (c *ChainClient) GetUtxo(op *wire.OutPoint,
+	pkScript []byte) (*wire.TxOut, error) {
+
+	history, err := c.backend.ScriptHistory(pkScript)
+	if err != nil {
+		return nil, err
+	}
+
+	var txOut *wire.TxOut
+	for _, item := range history {
+		if item.TxHash != op.Hash || !item.Confirmed() {
+			continue
+		}
+
+		tx, err := c.backend.Transaction(&item.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		if int(op.Index) >= len(tx.TxOut) {
+			return nil, ErrOutputNotFound
+		}
+
+		if !bytes.Equal(tx.TxOut[op.Index].PkScript, pkScript) {
+			return nil, ErrOutputNotFound
+		}
+
+		txOut = tx.TxOut[op.Index]
+	}
+	if txOut == nil {
+		return nil, ErrOutputNotFound
+	}
+
+	// Any other confirmed transaction in the history of the script might
+	// spend the output.
+	for _, item := range history {
+		if item.TxHash == op.Hash || !item.Confirmed() {
+			continue
+		}
+
+		tx, err := c.backend.Transaction(&item.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		for _, txIn := range tx.TxIn {
+			if txIn.PreviousOutPoint == *op {
+				return nil, ErrOutputSpent
+			}
+		}
+	}
+
+	return txOut, nil
+}
@@ -0,0 +1,287 @@
+package electrum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestChainClient creates and starts a chain client on top of the mock
+// backend. The client doesn't poll on its own, so the tests have to call
+// update.
+func newTestChainClient(t *testing.T, mock *MockBackend) *ChainClient {
+	t.Helper()
+
+	c := NewChainClient(mock, testParams, time.Hour)
+	require.NoError(t, c.Start())
+	t.Cleanup(c.Stop)
+
+	_, ok := receiveNtfn(t, c).(chain.ClientConnected)
+	require.True(t, ok)
+
+	return c
+}
+
+// receiveNtfn returns the next notification of the chain client.
+func receiveNtfn(t *testing.T, c *ChainClient) interface{} {
+	t.Helper()
+
+	select {
+	case ntfn := <-c.Notifications():
+		return ntfn
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no notification received")
+		return nil
+	}
+}
+
+// assertNoNtfn checks that the chain client has no pending notification.
+func assertNoNtfn(t *testing.T, c *ChainClient) {
+	t.Helper()
+
+	select {
+	case ntfn := <-c.Notifications():
+		t.Fatalf("unexpected notification: %v", ntfn)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// assertBlockConnected checks that the next notifications connect the block
+// at the given height with the given relevant transactions.
+func assertBlockConnected(t *testing.T, c *ChainClient, mock *MockBackend,
+	height int32, txs ...*wire.MsgTx) {
+
+	t.Helper()
+
+	hash, err := mock.BlockHash(height)
+	require.NoError(t, err)
+
+	filtered, ok := receiveNtfn(t, c).(chain.FilteredBlockConnected)
+	require.True(t, ok)
+	require.Equal(t, *hash, filtered.Block.Hash)
+	require.Equal(t, height, filtered.Block.Height)
+	require.Len(t, filtered.RelevantTxs, len(txs))
+	for i, tx := range txs {
+		require.Equal(t, tx.TxHash(), filtered.RelevantTxs[i].Hash)
+	}
+
+	connected, ok := receiveNtfn(t, c).(chain.BlockConnected)
+	require.True(t, ok)
+	require.Equal(t, *hash, connected.Hash)
+	require.Equal(t, height, connected.Height)
+}
+
+// TestChainClientUpdate checks that the chain client notifies the wallet of
+// new blocks, reorgs and relevant transactions.
+func TestChainClientUpdate(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	c := newTestChainClient(t, mock)
+
+	pkScript := testScript(t, 1)
+	require.NoError(t, c.NotifyBlocks())
+	require.NoError(t, c.NotifyReceived([]btcutil.Address{
+		testAddress(t, pkScript),
+	}))
+
+	// An irrelevant block is connected without transactions.
+	mock.AddBlock(newTestTx(wire.OutPoint{}))
+	require.NoError(t, c.update())
+	assertBlockConnected(t, c, mock, 1)
+
+	// A relevant transaction is notified once it enters the mempool.
+	fundingTx := newTestTx(wire.OutPoint{}, pkScript)
+	mock.AddMempoolTx(fundingTx)
+	require.NoError(t, c.update())
+
+	relevant, ok := receiveNtfn(t, c).(chain.RelevantTx)
+	require.True(t, ok)
+	require.Equal(t, fundingTx.TxHash(), relevant.TxRecord.Hash)
+	require.Nil(t, relevant.Block)
+
+	// Without changes, nothing is notified again.
+	require.NoError(t, c.update())
+	assertNoNtfn(t, c)
+
+	// And once more with the block that confirms it.
+	mock.AddBlock(fundingTx)
+	require.NoError(t, c.update())
+	assertBlockConnected(t, c, mock, 2, fundingTx)
+
+	// After a reorg, the disconnected block is notified and the
+	// transaction is notified again in the block that confirms it now.
+	staleHash, err := mock.BlockHash(2)
+	require.NoError(t, err)
+
+	mock.DisconnectBlocks(1)
+	mock.AddBlock()
+	mock.AddBlock(fundingTx)
+	require.NoError(t, c.update())
+
+	disconnected, ok := receiveNtfn(t, c).(chain.BlockDisconnected)
+	require.True(t, ok)
+	require.Equal(t, *staleHash, disconnected.Hash)
+	require.EqualValues(t, 2, disconnected.Height)
+
+	assertBlockConnected(t, c, mock, 2)
+	assertBlockConnected(t, c, mock, 3, fundingTx)
+
+	stamp, err := c.BlockStamp()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, stamp.Height)
+}
+
+// TestChainClientRescan checks that a rescan sends the relevant transactions
+// confirmed since the start block, followed by the unconfirmed ones.
+func TestChainClientRescan(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	pkScript := testScript(t, 1)
+
+	oldTx := newTestTx(wire.OutPoint{}, pkScript)
+	mock.AddBlock(oldTx)
+	startBlock := mock.AddBlock()
+	newTx := newTestTx(wire.OutPoint{}, pkScript)
+	mock.AddBlock(newTx)
+	spendTx := newTestTx(wire.OutPoint{Hash: newTx.TxHash()})
+	mock.AddMempoolTx(spendTx)
+
+	c := newTestChainClient(t, mock)
+
+	startHash := startBlock.BlockHash()
+	err := c.Rescan(
+		&startHash, []btcutil.Address{testAddress(t, pkScript)}, nil,
+	)
+	require.NoError(t, err)
+
+	relevant, ok := receiveNtfn(t, c).(chain.RelevantTx)
+	require.True(t, ok)
+	require.Equal(t, newTx.TxHash(), relevant.TxRecord.Hash)
+	require.NotNil(t, relevant.Block)
+	require.EqualValues(t, 3, relevant.Block.Height)
+
+	relevant, ok = receiveNtfn(t, c).(chain.RelevantTx)
+	require.True(t, ok)
+	require.Equal(t, spendTx.TxHash(), relevant.TxRecord.Hash)
+	require.Nil(t, relevant.Block)
+
+	finished, ok := receiveNtfn(t, c).(*chain.RescanFinished)
+	require.True(t, ok)
+	require.EqualValues(t, 3, finished.Height)
+
+	// The rescanned address is watched from now on, and transactions
+	// sent by the rescan aren't notified again.
+	require.NoError(t, c.update())
+	assertNoNtfn(t, c)
+
+	mock.AddBlock(spendTx)
+	require.NoError(t, c.update())
+
+	relevant, ok = receiveNtfn(t, c).(chain.RelevantTx)
+	require.True(t, ok)
+	require.Equal(t, spendTx.TxHash(), relevant.TxRecord.Hash)
+	require.EqualValues(t, 4, relevant.Block.Height)
+}
+
+// TestChainClientFilterBlocks checks that FilterBlocks returns the first
+// block of the request that includes relevant transactions.
+func TestChainClientFilterBlocks(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	pkScript := testScript(t, 1)
+
+	mock.AddBlock(newTestTx(wire.OutPoint{}))
+	fundingTx := newTestTx(wire.OutPoint{}, testScript(t, 2), pkScript)
+	mock.AddBlock(newTestTx(wire.OutPoint{}), fundingTx)
+
+	c := NewChainClient(mock, testParams, time.Hour)
+
+	var blocks []wtxmgr.BlockMeta
+	for height := int32(1); height <= 2; height++ {
+		hash, err := mock.BlockHash(height)
+		require.NoError(t, err)
+
+		blocks = append(blocks, wtxmgr.BlockMeta{
+			Block: wtxmgr.Block{Hash: *hash, Height: height},
+		})
+	}
+
+	scopedIndex := waddrmgr.ScopedIndex{
+		Scope: waddrmgr.KeyScopeBIP0084,
+		Index: 7,
+	}
+	resp, err := c.FilterBlocks(&chain.FilterBlocksRequest{
+		Blocks: blocks,
+		ExternalAddrs: map[waddrmgr.ScopedIndex]btcutil.Address{
+			scopedIndex: testAddress(t, pkScript),
+		},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.EqualValues(t, 1, resp.BatchIndex)
+	require.Equal(t, blocks[1], resp.BlockMeta)
+	require.Contains(
+		t, resp.FoundExternalAddrs[scopedIndex.Scope], uint32(7),
+	)
+	require.Len(t, resp.RelevantTxns, 1)
+	require.Equal(t, fundingTx.TxHash(), resp.RelevantTxns[0].TxHash())
+
+	// Without relevant transactions, nothing is found.
+	resp, err = c.FilterBlocks(&chain.FilterBlocksRequest{
+		Blocks: blocks[:1],
+		ExternalAddrs: map[waddrmgr.ScopedIndex]btcutil.Address{
+			scopedIndex: testAddress(t, pkScript),
+		},
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp)
+}
+
+// TestChainClientGetUtxo checks that GetUtxo reports whether a confirmed
+// output is unspent.
+func TestChainClientGetUtxo(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	c := NewChainClient(mock, testParams, time.Hour)
+
+	pkScript := testScript(t, 1)
+	fundingTx := newTestTx(wire.OutPoint{}, testScript(t, 2), pkScript)
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash(), Index: 1}
+
+	// Unconfirmed outputs aren't found.
+	mock.AddMempoolTx(fundingTx)
+	_, err := c.GetUtxo(&outPoint, pkScript)
+	require.ErrorIs(t, err, ErrOutputNotFound)
+
+	mock.AddBlock(fundingTx)
+	txOut, err := c.GetUtxo(&outPoint, pkScript)
+	require.NoError(t, err)
+	require.Equal(t, fundingTx.TxOut[1], txOut)
+
+	// The output doesn't pay to the script of the other output.
+	wrongOutPoint := wire.OutPoint{Hash: fundingTx.TxHash()}
+	_, err = c.GetUtxo(&wrongOutPoint, pkScript)
+	require.ErrorIs(t, err, ErrOutputNotFound)
+
+	// An unconfirmed spend doesn't count, a confirmed one does.
+	spendTx := newTestTx(outPoint)
+	mock.AddMempoolTx(spendTx)
+	_, err = c.GetUtxo(&outPoint, pkScript)
+	require.NoError(t, err)
+
+	mock.AddBlock(spendTx)
+	_, err = c.GetUtxo(&outPoint, pkScript)
+	require.ErrorIs(t, err, ErrOutputSpent)
+}
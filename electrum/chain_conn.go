@@ -0,0 +1,60 @@
+package electrum
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ChainConn wraps a Backend to provide the block lookups of the ChainConn
+// interface of the chainntnfs package, so its helpers for catching up on
+// missed blocks and rewinding the chain can be used with a Backend.
+type ChainConn struct {
+	backend Backend
+}
+
+// NewChainConn creates a new ChainConn backed by the given Backend.
+func NewChainConn(backend Backend) *ChainConn {
+	return &ChainConn{
+		backend: backend,
+	}
+}
+
+// GetBlockHeader returns the block header for a hash.
+func (c *ChainConn) GetBlockHeader(
+	blockHash *chainhash.Hash) (*wire.BlockHeader, error) {
+
+	header, _, err := c.backend.BlockHeader(blockHash)
+	return header, err
+}
+
+// GetBlockHeaderVerbose returns the verbose block header for a hash.
+func (c *ChainConn) GetBlockHeaderVerbose(
+	blockHash *chainhash.Hash) (*btcjson.GetBlockHeaderVerboseResult,
+	error) {
+
+	header, height, err := c.backend.BlockHeader(blockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &btcjson.GetBlockHeaderVerboseResult{
+		Hash:         blockHash.String(),
+		Height:       height,
+		Version:      header.Version,
+		VersionHex:   fmt.Sprintf("%08x", header.Version),
+		MerkleRoot:   header.MerkleRoot.String(),
+		Time:         header.Timestamp.Unix(),
+		Nonce:        uint64(header.Nonce),
+		Bits:         strconv.FormatInt(int64(header.Bits), 16),
+		PreviousHash: header.PrevBlock.String(),
+	}, nil
+}
+
+// GetBlockHash returns the hash from a block height.
+func (c *ChainConn) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	return c.backend.BlockHash(int32(blockHeight))
+}
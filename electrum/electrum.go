@@ -0,0 +1,905 @@
+package electrum
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+)
+
+const (
+	// protocolVersion is the version of the Electrum protocol the client
+	// speaks.
+	protocolVersion = "1.4"
+
+	// clientName is the name the client identifies itself with.
+	clientName = "lnd"
+
+	// pingInterval is the interval in which the server is pinged to keep
+	// the connection alive.
+	pingInterval = time.Minute
+
+	// DefaultElectrumRequestTimeout is the default time after which a
+	// request to an Electrum server is aborted.
+	DefaultElectrumRequestTimeout = 30 * time.Second
+)
+
+var (
+	// ErrClientShuttingDown is returned if a request is made while the
+	// client is shutting down.
+	ErrClientShuttingDown = errors.New("client shutting down")
+
+	// errConnectionClosed is returned for pending requests if the
+	// connection to the server is lost.
+	errConnectionClosed = errors.New("connection to electrum server " +
+		"closed")
+)
+
+// RPCError is an error returned by an Electrum server.
+type RPCError struct {
+	// Code is the error code.
+	Code int `json:"code"`
+
+	// Message describes the error.
+	Message string `json:"message"`
+}
+
+// Error returns the message of the error.
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// UnmarshalJSON decodes an error, which some servers send as a plain string
+// instead of an object.
+func (e *RPCError) UnmarshalJSON(data []byte) error {
+	var msg string
+	if err := json.Unmarshal(data, &msg); err == nil {
+		e.Message = msg
+		return nil
+	}
+
+	type rpcError RPCError
+	return json.Unmarshal(data, (*rpcError)(e))
+}
+
+// rpcRequest is a JSON-RPC request sent to the server.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcMessage is a JSON-RPC response or notification received from the
+// server. Responses carry an ID, notifications a method.
+type rpcMessage struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// headerNotification is the tip of the chain reported by the server.
+type headerNotification struct {
+	Height int32  `json:"height"`
+	Hex    string `json:"hex"`
+}
+
+// historyEntry is an entry of the history of a script hash.
+type historyEntry struct {
+	TxHash string `json:"tx_hash"`
+	Height int32  `json:"height"`
+}
+
+// scriptStatus is the state of a script hash the client is subscribed to.
+type scriptStatus struct {
+	// history is the cached history of the script hash. It is reset to
+	// nil whenever the server notifies us of a change.
+	history []HistoryItem
+
+	// generation is increased with every change notification, so a
+	// history fetched before the change isn't cached.
+	generation uint64
+}
+
+// electrumConn is a single connection to an Electrum server.
+type electrumConn struct {
+	conn net.Conn
+
+	writeMtx sync.Mutex
+
+	mtx     sync.Mutex
+	pending map[uint64]chan *rpcMessage
+	closed  bool
+}
+
+// ElectrumConfig holds the configuration of a client of an Electrum server.
+type ElectrumConfig struct {
+	// Server is the host:port of the Electrum server.
+	Server string
+
+	// TLS enables TLS for the connection to the server.
+	TLS bool
+
+	// TLSCertPath is the optional path of the certificate of the server.
+	// If set, the server must present exactly this certificate, which
+	// allows self-signed certificates. Otherwise the certificate is
+	// verified with the root certificates of the system.
+	TLSCertPath string
+
+	// RequestTimeout is the time after which a request is aborted.
+	RequestTimeout time.Duration
+
+	// Dialer is used to establish the connection to the server. If nil, a
+	// direct TCP connection is made.
+	Dialer chain.Dialer
+}
+
+// ElectrumClient is a Backend that talks to an Electrum protocol server. The
+// client subscribes to the tip of the chain and to every script hash it looks
+// up, so histories are only fetched again after the server reports a change.
+type ElectrumClient struct {
+	nextID uint64 // To be used atomically.
+
+	cfg *ElectrumConfig
+
+	tlsConfig *tls.Config
+
+	// dialMtx serializes connection attempts.
+	dialMtx sync.Mutex
+
+	// mtx guards the fields below.
+	mtx       sync.Mutex
+	conn      *electrumConn
+	tip       *wire.BlockHeader
+	tipHeight int32
+	scripts   map[string]*scriptStatus
+
+	headers *headerCache
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile time check to ensure ElectrumClient implements the Backend
+// interface.
+var _ Backend = (*ElectrumClient)(nil)
+
+// NewElectrumClient creates a new client of an Electrum server. The
+// connection is established with the first request.
+func NewElectrumClient(cfg *ElectrumConfig) (*ElectrumClient, error) {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultElectrumRequestTimeout
+	}
+
+	c := &ElectrumClient{
+		cfg:     cfg,
+		scripts: make(map[string]*scriptStatus),
+		headers: newHeaderCache(),
+		quit:    make(chan struct{}),
+	}
+
+	if cfg.TLS {
+		tlsConfig, err := newTLSConfig(cfg.Server, cfg.TLSCertPath)
+		if err != nil {
+			return nil, err
+		}
+		c.tlsConfig = tlsConfig
+	}
+
+	c.wg.Add(1)
+	go c.keepAlive()
+
+	return c, nil
+}
+
+// newTLSConfig creates the TLS configuration for a connection to the given
+// server, pinning its certificate if a path is given.
+func newTLSConfig(server, certPath string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, fmt.Errorf("invalid electrum server %v: %w", server,
+			err)
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName: host,
+		MinVersion: tls.VersionTLS12,
+	}
+	if certPath == "" {
+		return tlsConfig, nil
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read electrum certificate: "+
+			"%w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM certificate found in %v",
+			certPath)
+	}
+	if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+		return nil, fmt.Errorf("invalid electrum certificate: %w", err)
+	}
+
+	// The certificate is pinned, so we skip the verification against the
+	// system roots and only accept the exact certificate.
+	pinned := block.Bytes
+	tlsConfig.InsecureSkipVerify = true
+	tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte,
+		_ [][]*x509.Certificate) error {
+
+		if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], pinned) {
+			return errors.New("electrum server certificate " +
+				"doesn't match the pinned certificate")
+		}
+
+		return nil
+	}
+
+	return tlsConfig, nil
+}
+
+// Name returns the name of the backend.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) Name() string {
+	return ElectrumBackendName
+}
+
+// connection returns the current connection to the server, connecting and
+// subscribing to the tip of the chain if there is none.
+func (c *ElectrumClient) connection() (*electrumConn, error) {
+	c.mtx.Lock()
+	conn := c.conn
+	c.mtx.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	c.dialMtx.Lock()
+	defer c.dialMtx.Unlock()
+
+	// Another caller might have connected while we were waiting.
+	c.mtx.Lock()
+	conn = c.conn
+	c.mtx.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	select {
+	case <-c.quit:
+		return nil, ErrClientShuttingDown
+	default:
+	}
+
+	var (
+		netConn net.Conn
+		err     error
+	)
+	if c.cfg.Dialer != nil {
+		netConn, err = c.cfg.Dialer(c.cfg.Server)
+	} else {
+		netConn, err = net.DialTimeout(
+			"tcp", c.cfg.Server, c.cfg.RequestTimeout,
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to electrum server "+
+			"%v: %w", c.cfg.Server, err)
+	}
+	if c.tlsConfig != nil {
+		netConn = tls.Client(netConn, c.tlsConfig)
+	}
+
+	conn = &electrumConn{
+		conn:    netConn,
+		pending: make(map[uint64]chan *rpcMessage),
+	}
+
+	c.wg.Add(1)
+	go c.readLoop(conn)
+
+	var version []string
+	err = c.request(
+		conn, "server.version", []interface{}{
+			clientName, protocolVersion,
+		}, &version,
+	)
+	if err != nil {
+		c.disconnect(conn)
+		return nil, fmt.Errorf("unable to negotiate electrum protocol "+
+			"version: %w", err)
+	}
+
+	var tip headerNotification
+	err = c.request(conn, "blockchain.headers.subscribe", nil, &tip)
+	if err != nil {
+		c.disconnect(conn)
+		return nil, fmt.Errorf("unable to subscribe to headers: %w",
+			err)
+	}
+	if err := c.updateTip(&tip); err != nil {
+		c.disconnect(conn)
+		return nil, err
+	}
+
+	log.Infof("Connected to electrum server %v (%v)", c.cfg.Server,
+		version)
+
+	c.mtx.Lock()
+	c.conn = conn
+	c.mtx.Unlock()
+
+	return conn, nil
+}
+
+// disconnect closes a connection, fails all of its pending requests and
+// forgets the subscriptions made over it.
+func (c *ElectrumClient) disconnect(conn *electrumConn) {
+	conn.mtx.Lock()
+	if conn.closed {
+		conn.mtx.Unlock()
+		return
+	}
+	conn.closed = true
+	for id, respChan := range conn.pending {
+		close(respChan)
+		delete(conn.pending, id)
+	}
+	conn.mtx.Unlock()
+
+	_ = conn.conn.Close()
+
+	c.mtx.Lock()
+	if c.conn == conn {
+		c.conn = nil
+		c.scripts = make(map[string]*scriptStatus)
+	}
+	c.mtx.Unlock()
+}
+
+// readLoop reads the responses and notifications sent by the server over a
+// connection until it's closed.
+func (c *ElectrumClient) readLoop(conn *electrumConn) {
+	defer c.wg.Done()
+	defer c.disconnect(conn)
+
+	reader := bufio.NewReader(conn.conn)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			select {
+			case <-c.quit:
+			default:
+				log.Warnf("Connection to electrum server %v "+
+					"lost: %v", c.cfg.Server, err)
+			}
+
+			return
+		}
+
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			log.Errorf("Unable to decode message of electrum "+
+				"server: %v", err)
+			return
+		}
+
+		if msg.ID == nil {
+			c.handleNotification(&msg)
+			continue
+		}
+
+		conn.mtx.Lock()
+		respChan, ok := conn.pending[*msg.ID]
+		delete(conn.pending, *msg.ID)
+		conn.mtx.Unlock()
+
+		if ok {
+			respChan <- &msg
+		}
+	}
+}
+
+// handleNotification processes a notification of a subscription.
+func (c *ElectrumClient) handleNotification(msg *rpcMessage) {
+	switch msg.Method {
+	case "blockchain.headers.subscribe":
+		var params []headerNotification
+		if err := json.Unmarshal(msg.Params, &params); err != nil ||
+			len(params) == 0 {
+
+			log.Errorf("Invalid header notification: %s",
+				msg.Params)
+			return
+		}
+
+		if err := c.updateTip(&params[0]); err != nil {
+			log.Errorf("Invalid header notification: %v", err)
+		}
+
+	case "blockchain.scripthash.subscribe":
+		var params []json.RawMessage
+		if err := json.Unmarshal(msg.Params, &params); err != nil ||
+			len(params) == 0 {
+
+			log.Errorf("Invalid script hash notification: %s",
+				msg.Params)
+			return
+		}
+
+		var scriptHash string
+		if err := json.Unmarshal(params[0], &scriptHash); err != nil {
+			log.Errorf("Invalid script hash notification: %s",
+				msg.Params)
+			return
+		}
+
+		log.Tracef("Status of script hash %v changed", scriptHash)
+
+		c.mtx.Lock()
+		if status, ok := c.scripts[scriptHash]; ok {
+			status.history = nil
+			status.generation++
+		}
+		c.mtx.Unlock()
+
+	default:
+		log.Debugf("Ignoring electrum notification %v", msg.Method)
+	}
+}
+
+// updateTip records the tip of the chain reported by the server.
+func (c *ElectrumClient) updateTip(tip *headerNotification) error {
+	header, err := decodeHeader(tip.Hex)
+	if err != nil {
+		return err
+	}
+	c.headers.add(header, tip.Height)
+
+	c.mtx.Lock()
+	c.tip = header
+	c.tipHeight = tip.Height
+	c.mtx.Unlock()
+
+	log.Debugf("Electrum server tip at height %v", tip.Height)
+
+	return nil
+}
+
+// request sends a request over a connection and decodes the result into the
+// given value.
+func (c *ElectrumClient) request(conn *electrumConn, method string,
+	params []interface{}, result interface{}) error {
+
+	if params == nil {
+		params = []interface{}{}
+	}
+	req := rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.nextID, 1),
+		Method:  method,
+		Params:  params,
+	}
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	reqBytes = append(reqBytes, '\n')
+
+	respChan := make(chan *rpcMessage, 1)
+	conn.mtx.Lock()
+	if conn.closed {
+		conn.mtx.Unlock()
+		return errConnectionClosed
+	}
+	conn.pending[req.ID] = respChan
+	conn.mtx.Unlock()
+
+	conn.writeMtx.Lock()
+	_ = conn.conn.SetWriteDeadline(time.Now().Add(c.cfg.RequestTimeout))
+	_, err = conn.conn.Write(reqBytes)
+	conn.writeMtx.Unlock()
+	if err != nil {
+		c.disconnect(conn)
+		return fmt.Errorf("unable to send %v request: %w", method, err)
+	}
+
+	var resp *rpcMessage
+	select {
+	case resp = <-respChan:
+		if resp == nil {
+			return errConnectionClosed
+		}
+
+	case <-time.After(c.cfg.RequestTimeout):
+		// We don't know the state of the connection anymore, so we
+		// start over with a fresh one.
+		c.disconnect(conn)
+		return fmt.Errorf("%v request timed out", method)
+
+	case <-c.quit:
+		return ErrClientShuttingDown
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(resp.Result, result)
+}
+
+// call sends a request to the server, connecting first if needed.
+func (c *ElectrumClient) call(method string, params []interface{},
+	result interface{}) error {
+
+	conn, err := c.connection()
+	if err != nil {
+		return err
+	}
+
+	return c.request(conn, method, params, result)
+}
+
+// keepAlive pings the server periodically, so it doesn't drop the
+// connection while we are idle.
+func (c *ElectrumClient) keepAlive() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mtx.Lock()
+			conn := c.conn
+			c.mtx.Unlock()
+			if conn == nil {
+				continue
+			}
+
+			err := c.request(conn, "server.ping", nil, nil)
+			if err != nil {
+				log.Debugf("Unable to ping electrum server: %v",
+					err)
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// BestBlock returns the hash and height of the tip of the best chain. The
+// tip is kept up to date by the header subscription.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) BestBlock() (*chainhash.Hash, int32, error) {
+	if _, err := c.connection(); err != nil {
+		return nil, 0, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.tip == nil {
+		return nil, 0, errors.New("chain tip unknown")
+	}
+	hash := c.tip.BlockHash()
+
+	return &hash, c.tipHeight, nil
+}
+
+// BlockHash returns the hash of the block at the given height in the best
+// chain.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) BlockHash(height int32) (*chainhash.Hash, error) {
+	var headerHex string
+	err := c.call(
+		"blockchain.block.header", []interface{}{height}, &headerHex,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch header at height %v: "+
+			"%w", height, err)
+	}
+
+	header, err := decodeHeader(headerHex)
+	if err != nil {
+		return nil, err
+	}
+	c.headers.add(header, height)
+
+	hash := header.BlockHash()
+
+	return &hash, nil
+}
+
+// BlockHeader returns the header and height of the block with the given
+// hash. As Electrum servers can only look up headers by height, only blocks
+// that were looked up by height before are known.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) BlockHeader(hash *chainhash.Hash) (*wire.BlockHeader,
+	int32, error) {
+
+	header, height, ok := c.headers.get(hash)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: %v", ErrBlockNotFound, hash)
+	}
+
+	return header, height, nil
+}
+
+// Block returns ErrBlocksUnsupported as Electrum servers don't serve full
+// blocks.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) Block(*chainhash.Hash) (*wire.MsgBlock, error) {
+	return nil, ErrBlocksUnsupported
+}
+
+// ScriptHistory returns all confirmed and unconfirmed transactions that pay
+// to or spend from the given output script. The client subscribes to the
+// script hash, so the history is cached until the server reports a change.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) ScriptHistory(pkScript []byte) ([]HistoryItem,
+	error) {
+
+	conn, err := c.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	scriptHash := ScriptHash(pkScript)
+
+	c.mtx.Lock()
+	status, subscribed := c.scripts[scriptHash]
+	if subscribed && status.history != nil {
+		history := append([]HistoryItem(nil), status.history...)
+		c.mtx.Unlock()
+
+		return history, nil
+	}
+	if !subscribed {
+		status = &scriptStatus{}
+		c.scripts[scriptHash] = status
+	}
+	generation := status.generation
+	c.mtx.Unlock()
+
+	if !subscribed {
+		err := c.request(
+			conn, "blockchain.scripthash.subscribe",
+			[]interface{}{scriptHash}, nil,
+		)
+		if err != nil {
+			c.mtx.Lock()
+			if c.scripts[scriptHash] == status {
+				delete(c.scripts, scriptHash)
+			}
+			c.mtx.Unlock()
+
+			return nil, fmt.Errorf("unable to subscribe to script "+
+				"hash %v: %w", scriptHash, err)
+		}
+	}
+
+	var entries []historyEntry
+	err = c.request(
+		conn, "blockchain.scripthash.get_history",
+		[]interface{}{scriptHash}, &entries,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch history of script "+
+			"hash %v: %w", scriptHash, err)
+	}
+
+	history := make([]HistoryItem, 0, len(entries))
+	for _, entry := range entries {
+		txHash, err := chainhash.NewHashFromStr(entry.TxHash)
+		if err != nil {
+			return nil, err
+		}
+
+		history = append(history, HistoryItem{
+			TxHash: *txHash,
+			Height: entry.Height,
+		})
+	}
+	sortHistory(history)
+
+	// Only cache the history if the status didn't change while we were
+	// fetching it.
+	c.mtx.Lock()
+	if c.scripts[scriptHash] == status &&
+		status.generation == generation {
+
+		status.history = append([]HistoryItem(nil), history...)
+	}
+	c.mtx.Unlock()
+
+	return history, nil
+}
+
+// Transaction returns the transaction with the given hash.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) Transaction(txid *chainhash.Hash) (*wire.MsgTx,
+	error) {
+
+	var txHex string
+	err := c.call(
+		"blockchain.transaction.get", []interface{}{txid.String()},
+		&txHex,
+	)
+	var rpcErr *RPCError
+	switch {
+	case errors.As(err, &rpcErr):
+		return nil, fmt.Errorf("%w: %v: %v", ErrTxNotFound, txid, err)
+
+	case err != nil:
+		return nil, err
+	}
+
+	return decodeTx(txHex)
+}
+
+// TxIndex returns the index of a transaction within the block at the given
+// height.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) TxIndex(txid *chainhash.Hash, height int32) (uint32,
+	error) {
+
+	var merkle struct {
+		Pos uint32 `json:"pos"`
+	}
+	err := c.call(
+		"blockchain.transaction.get_merkle",
+		[]interface{}{txid.String(), height}, &merkle,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch merkle proof of %v: %w",
+			txid, err)
+	}
+
+	return merkle.Pos, nil
+}
+
+// Broadcast publishes a transaction to the network. The errors returned by
+// the server contain the reject reason of bitcoind, so they can be mapped
+// like the errors of a bitcoind backend.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) Broadcast(tx *wire.MsgTx) error {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return err
+	}
+
+	var txid string
+	return c.call(
+		"blockchain.transaction.broadcast",
+		[]interface{}{hex.EncodeToString(buf.Bytes())}, &txid,
+	)
+}
+
+// EstimateFee returns the fee rate in sat/kvB for a transaction to confirm
+// within the given number of blocks.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) EstimateFee(numBlocks uint32) (btcutil.Amount,
+	error) {
+
+	var btcPerKB float64
+	err := c.call(
+		"blockchain.estimatefee", []interface{}{numBlocks}, &btcPerKB,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	// The server returns -1 if it has no estimate.
+	if btcPerKB <= 0 {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return btcutil.NewAmount(btcPerKB)
+}
+
+// RelayFee returns the minimum fee rate in sat/kvB of transactions relayed by
+// the server.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) RelayFee() (btcutil.Amount, error) {
+	var btcPerKB float64
+	if err := c.call("blockchain.relayfee", nil, &btcPerKB); err != nil {
+		return 0, err
+	}
+
+	if btcPerKB <= 0 {
+		return defaultRelayFee, nil
+	}
+
+	return btcutil.NewAmount(btcPerKB)
+}
+
+// Close closes the connection to the server.
+//
+// NOTE: This is part of the Backend interface.
+func (c *ElectrumClient) Close() error {
+	select {
+	case <-c.quit:
+		return nil
+	default:
+	}
+	close(c.quit)
+
+	c.mtx.Lock()
+	conn := c.conn
+	c.mtx.Unlock()
+	if conn != nil {
+		c.disconnect(conn)
+	}
+
+	c.wg.Wait()
+
+	return nil
+}
+
+// decodeHeader decodes a hex encoded block header.
+func decodeHeader(headerHex string) (*wire.BlockHeader, error) {
+	headerBytes, err := hex.DecodeString(headerHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid block header: %w", err)
+	}
+
+	var header wire.BlockHeader
+	err = header.Deserialize(bytes.NewReader(headerBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid block header: %w", err)
+	}
+
+	return &header, nil
+}
+
+// decodeTx decodes a hex encoded transaction.
+func decodeTx(txHex string) (*wire.MsgTx, error) {
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("invalid transaction: %w", err)
+	}
+
+	return &tx, nil
+}
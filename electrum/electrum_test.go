@@ -0,0 +1,465 @@
+package electrum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// mockElectrumServer is an Electrum protocol server that serves the chain of
+// a mock backend.
+type mockElectrumServer struct {
+	t *testing.T
+
+	backend *MockBackend
+
+	listener net.Listener
+
+	mtx   sync.Mutex
+	conns []net.Conn
+}
+
+// newMockElectrumServer starts a new Electrum server on a local port.
+func newMockElectrumServer(t *testing.T,
+	backend *MockBackend) *mockElectrumServer {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &mockElectrumServer{
+		t:        t,
+		backend:  backend,
+		listener: listener,
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			s.mtx.Lock()
+			s.conns = append(s.conns, conn)
+			s.mtx.Unlock()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+
+	t.Cleanup(func() {
+		listener.Close()
+
+		s.mtx.Lock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+		s.mtx.Unlock()
+
+		wg.Wait()
+	})
+
+	return s
+}
+
+// addr returns the address the server listens on.
+func (s *mockElectrumServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+// write sends a message to a client.
+func (s *mockElectrumServer) write(conn net.Conn, msg interface{}) {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		s.t.Errorf("unable to encode message: %v", err)
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, _ = conn.Write(append(b, '\n'))
+}
+
+// notify sends a notification to all connected clients.
+func (s *mockElectrumServer) notify(method string, params ...interface{}) {
+	s.mtx.Lock()
+	conns := append([]net.Conn(nil), s.conns...)
+	s.mtx.Unlock()
+
+	for _, conn := range conns {
+		s.write(conn, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"method":  method,
+			"params":  params,
+		})
+	}
+}
+
+// notifyTip sends the tip of the chain to all connected clients.
+func (s *mockElectrumServer) notifyTip() {
+	s.notify("blockchain.headers.subscribe", s.tip())
+}
+
+// tip returns the tip of the chain as reported by the server.
+func (s *mockElectrumServer) tip() map[string]interface{} {
+	hash, height, err := s.backend.BestBlock()
+	require.NoError(s.t, err)
+
+	header, _, err := s.backend.BlockHeader(hash)
+	require.NoError(s.t, err)
+
+	return map[string]interface{}{
+		"height": height,
+		"hex":    encodeHeader(s.t, header),
+	}
+}
+
+// serve answers the requests of a client until the connection is closed.
+func (s *mockElectrumServer) serve(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req struct {
+			ID     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			s.t.Errorf("invalid request: %v", err)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+		}
+		result, err := s.handle(req.Method, req.Params)
+		if err != nil {
+			resp["error"] = map[string]interface{}{
+				"code":    1,
+				"message": err.Error(),
+			}
+		} else {
+			resp["result"] = result
+		}
+
+		s.write(conn, resp)
+	}
+}
+
+// handle answers a single request.
+func (s *mockElectrumServer) handle(method string,
+	params []json.RawMessage) (interface{}, error) {
+
+	var (
+		height     int32
+		scriptHash string
+		txid       string
+		numBlocks  uint32
+	)
+	parse := func(values ...interface{}) error {
+		if len(params) < len(values) {
+			return errors.New("missing params")
+		}
+		for i, value := range values {
+			err := json.Unmarshal(params[i], value)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	switch method {
+	case "server.version":
+		return []string{"mock", "1.4"}, nil
+
+	case "server.ping":
+		return nil, nil
+
+	case "blockchain.headers.subscribe":
+		return s.tip(), nil
+
+	case "blockchain.block.header":
+		if err := parse(&height); err != nil {
+			return nil, err
+		}
+
+		hash, err := s.backend.BlockHash(height)
+		if err != nil {
+			return nil, err
+		}
+		header, _, err := s.backend.BlockHeader(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeHeader(s.t, header), nil
+
+	case "blockchain.scripthash.subscribe":
+		if err := parse(&scriptHash); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+
+	case "blockchain.scripthash.get_history":
+		if err := parse(&scriptHash); err != nil {
+			return nil, err
+		}
+
+		entries := []historyEntry{}
+		pkScript := s.backend.scriptByHash(scriptHash)
+		if pkScript == nil {
+			return entries, nil
+		}
+
+		history, err := s.backend.ScriptHistory(pkScript)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range history {
+			entries = append(entries, historyEntry{
+				TxHash: item.TxHash.String(),
+				Height: item.Height,
+			})
+		}
+
+		return entries, nil
+
+	case "blockchain.transaction.get":
+		if err := parse(&txid); err != nil {
+			return nil, err
+		}
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err := s.backend.Transaction(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeTx(s.t, tx), nil
+
+	case "blockchain.transaction.get_merkle":
+		if err := parse(&txid, &height); err != nil {
+			return nil, err
+		}
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return nil, err
+		}
+
+		pos, err := s.backend.TxIndex(hash, height)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"block_height": height,
+			"merkle":       []string{},
+			"pos":          pos,
+		}, nil
+
+	case "blockchain.transaction.broadcast":
+		var txHex string
+		if err := parse(&txHex); err != nil {
+			return nil, err
+		}
+
+		tx, err := decodeTx(txHex)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.backend.Broadcast(tx); err != nil {
+			return nil, err
+		}
+
+		return tx.TxHash().String(), nil
+
+	case "blockchain.estimatefee":
+		if err := parse(&numBlocks); err != nil {
+			return nil, err
+		}
+
+		fee, err := s.backend.EstimateFee(numBlocks)
+		if errors.Is(err, ErrNoFeeEstimate) {
+			return -1, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return fee.ToBTC(), nil
+
+	case "blockchain.relayfee":
+		fee, err := s.backend.RelayFee()
+		if err != nil {
+			return nil, err
+		}
+
+		return fee.ToBTC(), nil
+
+	default:
+		return nil, errors.New("unknown method " + method)
+	}
+}
+
+// encodeHeader returns the hex encoding of a block header.
+func encodeHeader(t *testing.T, header *wire.BlockHeader) string {
+	var buf bytes.Buffer
+	require.NoError(t, header.Serialize(&buf))
+
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// encodeTx returns the hex encoding of a transaction.
+func encodeTx(t *testing.T, tx *wire.MsgTx) string {
+	var buf bytes.Buffer
+	require.NoError(t, tx.Serialize(&buf))
+
+	return hex.EncodeToString(buf.Bytes())
+}
+
+// newTestElectrumClient creates a client of a new Electrum server that serves
+// the chain of the given mock backend.
+func newTestElectrumClient(t *testing.T,
+	mock *MockBackend) (*ElectrumClient, *mockElectrumServer) {
+
+	server := newMockElectrumServer(t, mock)
+
+	client, err := NewElectrumClient(&ElectrumConfig{
+		Server:         server.addr(),
+		RequestTimeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	return client, server
+}
+
+// TestElectrumClient checks that the Electrum client reports the chain of the
+// server.
+func TestElectrumClient(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	s := newBackendScenario(t, mock)
+	client, _ := newTestElectrumClient(t, mock)
+
+	testBackend(t, client, mock, s)
+
+	require.Equal(t, ElectrumBackendName, client.Name())
+
+	// Electrum servers don't serve full blocks.
+	hash, err := client.BlockHash(1)
+	require.NoError(t, err)
+
+	_, err = client.Block(hash)
+	require.ErrorIs(t, err, ErrBlocksUnsupported)
+}
+
+// TestElectrumClientNotifications checks that the Electrum client follows the
+// tip of the chain and only fetches the history of a script again once the
+// server reports a change.
+func TestElectrumClientNotifications(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	s := newBackendScenario(t, mock)
+	client, server := newTestElectrumClient(t, mock)
+
+	_, height, err := client.BestBlock()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, height)
+
+	history, err := client.ScriptHistory(s.scriptA)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	// Mining a block isn't noticed until the server sends the new tip.
+	block := mock.AddBlock(newTestTx(wire.OutPoint{}, s.scriptA))
+
+	_, height, err = client.BestBlock()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, height)
+
+	server.notifyTip()
+	require.Eventually(t, func() bool {
+		hash, height, err := client.BestBlock()
+		return err == nil && height == 3 && *hash == block.BlockHash()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The new tip is known by hash without looking it up by height.
+	hash := block.BlockHash()
+	_, height, err = client.BlockHeader(&hash)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, height)
+
+	// The history of script A is served from the cache until the server
+	// notifies us of the new transaction.
+	history, err = client.ScriptHistory(s.scriptA)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+
+	server.notify(
+		"blockchain.scripthash.subscribe", ScriptHash(s.scriptA),
+		"status",
+	)
+	require.Eventually(t, func() bool {
+		history, err := client.ScriptHistory(s.scriptA)
+		return err == nil && len(history) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestElectrumClientReconnect checks that the Electrum client reconnects
+// after the connection to the server is lost.
+func TestElectrumClientReconnect(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	s := newBackendScenario(t, mock)
+	client, server := newTestElectrumClient(t, mock)
+
+	_, err := client.ScriptHistory(s.scriptA)
+	require.NoError(t, err)
+
+	server.mtx.Lock()
+	for _, conn := range server.conns {
+		conn.Close()
+	}
+	server.mtx.Unlock()
+
+	require.Eventually(t, func() bool {
+		history, err := client.ScriptHistory(s.scriptB)
+		return err == nil && len(history) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	fee, err := client.RelayFee()
+	require.NoError(t, err)
+	require.Equal(t, btcutil.Amount(defaultRelayFee), fee)
+}
@@ -0,0 +1,476 @@
+package electrum
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultEsploraRequestTimeout is the default time after which a
+	// request to an Esplora API is aborted.
+	DefaultEsploraRequestTimeout = 30 * time.Second
+
+	// esploraChainPageSize is the number of confirmed transactions the
+	// Esplora API returns per page of a script hash history.
+	esploraChainPageSize = 25
+
+	// maxResponseSize is the maximum size of a response of the Esplora
+	// API we read, which is enough for the largest raw blocks.
+	maxResponseSize = 32 * 1024 * 1024
+)
+
+// errHTTPNotFound is returned if the Esplora API responds with a 404.
+var errHTTPNotFound = errors.New("not found")
+
+// esploraTx is a transaction as returned by the history endpoints of the
+// Esplora API.
+type esploraTx struct {
+	TxID   string `json:"txid"`
+	Status struct {
+		Confirmed   bool  `json:"confirmed"`
+		BlockHeight int32 `json:"block_height"`
+	} `json:"status"`
+}
+
+// esploraBlock is a block as returned by the block endpoint of the Esplora
+// API.
+type esploraBlock struct {
+	ID                string `json:"id"`
+	Height            int32  `json:"height"`
+	Version           int32  `json:"version"`
+	Timestamp         int64  `json:"timestamp"`
+	Bits              uint32 `json:"bits"`
+	Nonce             uint32 `json:"nonce"`
+	MerkleRoot        string `json:"merkle_root"`
+	PreviousBlockHash string `json:"previousblockhash"`
+}
+
+// EsploraConfig holds the configuration of a client of an Esplora API.
+type EsploraConfig struct {
+	// URL is the base URL of the API, for example
+	// https://blockstream.info/api.
+	URL string
+
+	// RequestTimeout is the time after which a request is aborted.
+	RequestTimeout time.Duration
+
+	// Client is the HTTP client used for requests. If nil, a client with
+	// the request timeout is created.
+	Client *http.Client
+}
+
+// EsploraClient is a Backend that talks to an Esplora REST API.
+type EsploraClient struct {
+	cfg *EsploraConfig
+
+	client *http.Client
+
+	headers *headerCache
+
+	ctx    context.Context
+	cancel func()
+}
+
+// A compile time check to ensure EsploraClient implements the Backend
+// interface.
+var _ Backend = (*EsploraClient)(nil)
+
+// NewEsploraClient creates a new client of an Esplora API.
+func NewEsploraClient(cfg *EsploraConfig) *EsploraClient {
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = DefaultEsploraRequestTimeout
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{
+			Timeout: cfg.RequestTimeout,
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &EsploraClient{
+		cfg:     cfg,
+		client:  client,
+		headers: newHeaderCache(),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Name returns the name of the backend.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) Name() string {
+	return EsploraBackendName
+}
+
+// do sends a request to the API and returns the body of the response.
+func (e *EsploraClient) do(method, path string, body []byte) ([]byte,
+	error) {
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(
+		e.ctx, method, e.cfg.URL+path, bodyReader,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("esplora request %v failed: %w", path,
+			err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read esplora response: %w",
+			err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %v", errHTTPNotFound, path)
+
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("esplora request %v failed with "+
+			"status %v: %s", path, resp.StatusCode,
+			bytes.TrimSpace(respBody))
+	}
+
+	return respBody, nil
+}
+
+// get sends a GET request to the API and returns the body of the response.
+func (e *EsploraClient) get(path string) ([]byte, error) {
+	return e.do(http.MethodGet, path, nil)
+}
+
+// getJSON sends a GET request to the API and decodes the JSON response into
+// the given value.
+func (e *EsploraClient) getJSON(path string, result interface{}) error {
+	body, err := e.get(path)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// BestBlock returns the hash and height of the tip of the best chain.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) BestBlock() (*chainhash.Hash, int32, error) {
+	body, err := e.get("/blocks/tip/hash")
+	if err != nil {
+		return nil, 0, err
+	}
+	hash, err := chainhash.NewHashFromStr(string(bytes.TrimSpace(body)))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, height, err := e.BlockHeader(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return hash, height, nil
+}
+
+// BlockHash returns the hash of the block at the given height in the best
+// chain.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) BlockHash(height int32) (*chainhash.Hash, error) {
+	body, err := e.get(fmt.Sprintf("/block-height/%d", height))
+	if err != nil {
+		return nil, err
+	}
+
+	return chainhash.NewHashFromStr(string(bytes.TrimSpace(body)))
+}
+
+// BlockHeader returns the header and height of the block with the given
+// hash.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) BlockHeader(hash *chainhash.Hash) (*wire.BlockHeader,
+	int32, error) {
+
+	if header, height, ok := e.headers.get(hash); ok {
+		return header, height, nil
+	}
+
+	var block esploraBlock
+	err := e.getJSON("/block/"+hash.String(), &block)
+	switch {
+	case errors.Is(err, errHTTPNotFound):
+		return nil, 0, fmt.Errorf("%w: %v", ErrBlockNotFound, hash)
+
+	case err != nil:
+		return nil, 0, err
+	}
+
+	merkleRoot, err := chainhash.NewHashFromStr(block.MerkleRoot)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := &wire.BlockHeader{
+		Version:    block.Version,
+		MerkleRoot: *merkleRoot,
+		Timestamp:  time.Unix(block.Timestamp, 0),
+		Bits:       block.Bits,
+		Nonce:      block.Nonce,
+	}
+
+	// The genesis block has no previous block.
+	if block.PreviousBlockHash != "" {
+		prevHash, err := chainhash.NewHashFromStr(
+			block.PreviousBlockHash,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		header.PrevBlock = *prevHash
+	}
+
+	if header.BlockHash() != *hash {
+		return nil, 0, fmt.Errorf("esplora returned header that "+
+			"doesn't match block %v", hash)
+	}
+	e.headers.add(header, block.Height)
+
+	return header, block.Height, nil
+}
+
+// Block returns the full block with the given hash.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) Block(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	body, err := e.get("/block/" + hash.String() + "/raw")
+	switch {
+	case errors.Is(err, errHTTPNotFound):
+		return nil, fmt.Errorf("%w: %v", ErrBlockNotFound, hash)
+
+	case err != nil:
+		return nil, err
+	}
+
+	var block wire.MsgBlock
+	if err := block.Deserialize(bytes.NewReader(body)); err != nil {
+		return nil, fmt.Errorf("invalid block %v: %w", hash, err)
+	}
+
+	return &block, nil
+}
+
+// ScriptHistory returns all confirmed and unconfirmed transactions that pay
+// to or spend from the given output script.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) ScriptHistory(pkScript []byte) ([]HistoryItem,
+	error) {
+
+	path := "/scripthash/" + ScriptHash(pkScript) + "/txs"
+
+	var mempoolTxs []esploraTx
+	if err := e.getJSON(path+"/mempool", &mempoolTxs); err != nil {
+		return nil, err
+	}
+
+	// Confirmed transactions are returned newest first, in pages that
+	// continue after the last transaction of the previous page.
+	var (
+		chainTxs []esploraTx
+		lastTxID string
+	)
+	for {
+		pagePath := path + "/chain"
+		if lastTxID != "" {
+			pagePath += "/" + lastTxID
+		}
+
+		var page []esploraTx
+		if err := e.getJSON(pagePath, &page); err != nil {
+			return nil, err
+		}
+		chainTxs = append(chainTxs, page...)
+
+		if len(page) < esploraChainPageSize {
+			break
+		}
+		lastTxID = page[len(page)-1].TxID
+	}
+
+	history := make([]HistoryItem, 0, len(mempoolTxs)+len(chainTxs))
+	for _, tx := range append(chainTxs, mempoolTxs...) {
+		txHash, err := chainhash.NewHashFromStr(tx.TxID)
+		if err != nil {
+			return nil, err
+		}
+
+		var height int32
+		if tx.Status.Confirmed {
+			height = tx.Status.BlockHeight
+		}
+
+		history = append(history, HistoryItem{
+			TxHash: *txHash,
+			Height: height,
+		})
+	}
+	sortHistory(history)
+
+	return history, nil
+}
+
+// Transaction returns the transaction with the given hash.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) Transaction(txid *chainhash.Hash) (*wire.MsgTx,
+	error) {
+
+	body, err := e.get("/tx/" + txid.String() + "/hex")
+	switch {
+	case errors.Is(err, errHTTPNotFound):
+		return nil, fmt.Errorf("%w: %v", ErrTxNotFound, txid)
+
+	case err != nil:
+		return nil, err
+	}
+
+	return decodeTx(string(bytes.TrimSpace(body)))
+}
+
+// TxIndex returns the index of a transaction within the block at the given
+// height.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) TxIndex(txid *chainhash.Hash, height int32) (uint32,
+	error) {
+
+	var proof struct {
+		BlockHeight int32  `json:"block_height"`
+		Pos         uint32 `json:"pos"`
+	}
+	err := e.getJSON("/tx/"+txid.String()+"/merkle-proof", &proof)
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch merkle proof of %v: %w",
+			txid, err)
+	}
+
+	if proof.BlockHeight != height {
+		return 0, fmt.Errorf("transaction %v confirmed at height %v, "+
+			"not %v", txid, proof.BlockHeight, height)
+	}
+
+	return proof.Pos, nil
+}
+
+// Broadcast publishes a transaction to the network. The errors returned by
+// the API contain the reject reason of bitcoind, so they can be mapped like
+// the errors of a bitcoind backend.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) Broadcast(tx *wire.MsgTx) error {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return err
+	}
+
+	_, err := e.do(
+		http.MethodPost, "/tx",
+		[]byte(hex.EncodeToString(buf.Bytes())),
+	)
+
+	return err
+}
+
+// EstimateFee returns the fee rate in sat/kvB for a transaction to confirm
+// within the given number of blocks. The API only has estimates for some
+// targets, so the estimate of the closest lower target is used.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) EstimateFee(numBlocks uint32) (btcutil.Amount,
+	error) {
+
+	var estimates map[string]float64
+	if err := e.getJSON("/fee-estimates", &estimates); err != nil {
+		return 0, err
+	}
+
+	targets := make([]uint32, 0, len(estimates))
+	satPerVByte := make(map[uint32]float64, len(estimates))
+	for target, fee := range estimates {
+		confTarget, err := strconv.ParseUint(target, 10, 32)
+		if err != nil {
+			continue
+		}
+
+		targets = append(targets, uint32(confTarget))
+		satPerVByte[uint32(confTarget)] = fee
+	}
+	if len(targets) == 0 {
+		return 0, ErrNoFeeEstimate
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i] < targets[j]
+	})
+
+	// Use the highest target that still confirms within the requested
+	// number of blocks, or the lowest target if there is none.
+	target := targets[0]
+	for _, t := range targets {
+		if t > numBlocks {
+			break
+		}
+		target = t
+	}
+
+	fee := btcutil.Amount(satPerVByte[target] * 1000)
+	if fee <= 0 {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return fee, nil
+}
+
+// RelayFee returns the minimum fee rate in sat/kvB of transactions relayed by
+// the API. Esplora doesn't report one, so the default of bitcoind is
+// assumed.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) RelayFee() (btcutil.Amount, error) {
+	return defaultRelayFee, nil
+}
+
+// Close aborts all pending requests.
+//
+// NOTE: This is part of the Backend interface.
+func (e *EsploraClient) Close() error {
+	e.cancel()
+	return nil
+}
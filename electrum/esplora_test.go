@@ -0,0 +1,395 @@
+package electrum
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+// mockEsploraHandler is an Esplora API that serves the chain of a mock
+// backend.
+type mockEsploraHandler struct {
+	t *testing.T
+
+	backend *MockBackend
+}
+
+// ServeHTTP answers a single request to the API.
+func (h *mockEsploraHandler) ServeHTTP(w http.ResponseWriter,
+	r *http.Request) {
+
+	result, err := h.handle(r)
+	switch {
+	case errors.Is(err, ErrBlockNotFound), errors.Is(err, ErrTxNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+	default:
+		switch result := result.(type) {
+		case string:
+			_, _ = w.Write([]byte(result))
+
+		case []byte:
+			_, _ = w.Write(result)
+
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(result)
+		}
+	}
+}
+
+// handle returns the response to a request, which is either a string, a raw
+// byte slice or a value that is encoded as JSON.
+func (h *mockEsploraHandler) handle(r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/tx":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		tx, err := decodeTx(string(body))
+		if err != nil {
+			return nil, err
+		}
+		if err := h.backend.Broadcast(tx); err != nil {
+			return nil, err
+		}
+
+		return tx.TxHash().String(), nil
+
+	case r.URL.Path == "/blocks/tip/hash":
+		hash, _, err := h.backend.BestBlock()
+		if err != nil {
+			return nil, err
+		}
+
+		return hash.String(), nil
+
+	case len(parts) == 2 && parts[0] == "block-height":
+		height, err := strconv.ParseInt(parts[1], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := h.backend.BlockHash(int32(height))
+		if err != nil {
+			return nil, err
+		}
+
+		return hash.String(), nil
+
+	case len(parts) >= 2 && parts[0] == "block":
+		return h.handleBlock(parts[1], parts[2:])
+
+	case len(parts) >= 4 && parts[0] == "scripthash":
+		return h.handleHistory(parts[1], parts[3:])
+
+	case len(parts) == 3 && parts[0] == "tx":
+		return h.handleTx(parts[1], parts[2])
+
+	case r.URL.Path == "/fee-estimates":
+		estimates := make(map[string]float64)
+		for _, target := range []uint32{1, 6, 144} {
+			fee, err := h.backend.EstimateFee(target)
+			if errors.Is(err, ErrNoFeeEstimate) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			estimates[strconv.Itoa(int(target))] =
+				float64(fee) / 1000
+		}
+
+		return estimates, nil
+
+	default:
+		return nil, fmt.Errorf("unknown path %v", r.URL.Path)
+	}
+}
+
+// handleBlock answers a request for the header or the raw block with the
+// given hash.
+func (h *mockEsploraHandler) handleBlock(hashStr string,
+	rest []string) (interface{}, error) {
+
+	hash, err := chainhash.NewHashFromStr(hashStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rest) == 1 && rest[0] == "raw" {
+		block, err := h.backend.Block(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		if err := block.Serialize(&buf); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+
+	header, height, err := h.backend.BlockHeader(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	block := esploraBlock{
+		ID:         hash.String(),
+		Height:     height,
+		Version:    header.Version,
+		Timestamp:  header.Timestamp.Unix(),
+		Bits:       header.Bits,
+		Nonce:      header.Nonce,
+		MerkleRoot: header.MerkleRoot.String(),
+	}
+	if height > 0 {
+		block.PreviousBlockHash = header.PrevBlock.String()
+	}
+
+	return block, nil
+}
+
+// handleHistory answers a request for the unconfirmed transactions or a page
+// of the confirmed transactions of a script hash.
+func (h *mockEsploraHandler) handleHistory(scriptHash string,
+	rest []string) (interface{}, error) {
+
+	var history []HistoryItem
+	if pkScript := h.backend.scriptByHash(scriptHash); pkScript != nil {
+		var err error
+		history, err = h.backend.ScriptHistory(pkScript)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	txs := []esploraTx{}
+	if rest[0] == "mempool" {
+		for _, item := range history {
+			if !item.Confirmed() {
+				txs = append(txs, esploraTx{
+					TxID: item.TxHash.String(),
+				})
+			}
+		}
+
+		return txs, nil
+	}
+
+	// Confirmed transactions are returned newest first, after the given
+	// last transaction of the previous page.
+	lastTxID := ""
+	if len(rest) > 1 {
+		lastTxID = rest[1]
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		item := history[i]
+		if !item.Confirmed() {
+			continue
+		}
+
+		if lastTxID != "" {
+			if item.TxHash.String() == lastTxID {
+				lastTxID = ""
+			}
+			continue
+		}
+
+		tx := esploraTx{TxID: item.TxHash.String()}
+		tx.Status.Confirmed = true
+		tx.Status.BlockHeight = item.Height
+		txs = append(txs, tx)
+
+		if len(txs) == esploraChainPageSize {
+			break
+		}
+	}
+
+	return txs, nil
+}
+
+// handleTx answers a request for a transaction or its merkle proof.
+func (h *mockEsploraHandler) handleTx(txid, resource string) (interface{},
+	error) {
+
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resource {
+	case "hex":
+		tx, err := h.backend.Transaction(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		return encodeTx(h.t, tx), nil
+
+	case "merkle-proof":
+		height := h.backend.txHeight(hash)
+		if height <= 0 {
+			return nil, fmt.Errorf("%w: %v", ErrTxNotFound, hash)
+		}
+
+		pos, err := h.backend.TxIndex(hash, height)
+		if err != nil {
+			return nil, err
+		}
+
+		return map[string]interface{}{
+			"block_height": height,
+			"merkle":       []string{},
+			"pos":          pos,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown resource %v", resource)
+	}
+}
+
+// newTestEsploraClient creates a client of a new Esplora API that serves the
+// chain of the given mock backend.
+func newTestEsploraClient(t *testing.T, mock *MockBackend) *EsploraClient {
+	server := httptest.NewServer(&mockEsploraHandler{
+		t:       t,
+		backend: mock,
+	})
+	t.Cleanup(server.Close)
+
+	client := NewEsploraClient(&EsploraConfig{
+		URL: server.URL + "/",
+	})
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+
+	return client
+}
+
+// TestEsploraClient checks that the Esplora client reports the chain of the
+// API.
+func TestEsploraClient(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	s := newBackendScenario(t, mock)
+	client := newTestEsploraClient(t, mock)
+
+	testBackend(t, client, mock, s)
+
+	require.Equal(t, EsploraBackendName, client.Name())
+
+	// Full blocks are served.
+	hash, err := client.BlockHash(2)
+	require.NoError(t, err)
+
+	block, err := client.Block(hash)
+	require.NoError(t, err)
+	require.Equal(t, *hash, block.BlockHash())
+	require.Equal(t, s.spendTx.TxHash(), block.Transactions[1].TxHash())
+
+	unknownHash := chainhash.Hash{0x01}
+	_, err = client.Block(&unknownHash)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+
+	_, _, err = client.BlockHeader(&unknownHash)
+	require.ErrorIs(t, err, ErrBlockNotFound)
+
+	// The transaction index must be requested at the height the
+	// transaction confirmed at.
+	spendHash := s.spendTx.TxHash()
+	_, err = client.TxIndex(&spendHash, 1)
+	require.Error(t, err)
+}
+
+// TestEsploraClientHistoryPages checks that the Esplora client fetches all
+// pages of the confirmed history of a script.
+func TestEsploraClientHistoryPages(t *testing.T) {
+	t.Parallel()
+
+	mock := NewMockBackend(testParams)
+	client := newTestEsploraClient(t, mock)
+
+	// Confirm exactly two pages of transactions, so the client also has
+	// to request the empty third page.
+	pkScript := testScript(t, 1)
+	numTxs := 2 * esploraChainPageSize
+
+	var expected []HistoryItem
+	for i := 0; i < numTxs; i++ {
+		tx := newTestTx(wire.OutPoint{}, pkScript)
+		mock.AddBlock(tx)
+
+		expected = append(expected, HistoryItem{
+			TxHash: tx.TxHash(),
+			Height: int32(i + 1),
+		})
+	}
+
+	tx := newTestTx(wire.OutPoint{}, pkScript)
+	mock.AddMempoolTx(tx)
+	expected = append(expected, HistoryItem{TxHash: tx.TxHash()})
+
+	history, err := client.ScriptHistory(pkScript)
+	require.NoError(t, err)
+	require.Equal(t, expected, history)
+}
+
+// TestEsploraClientEstimateFee checks that the Esplora client uses the
+// estimate of the closest target that confirms within the requested number
+// of blocks.
+func TestEsploraClientEstimateFee(t *testing.T) {
+	t.Parallel()
+
+	estimates := map[string]float64{
+		"1":   30,
+		"6":   20,
+		"144": 1.5,
+	}
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(estimates)
+		},
+	))
+	t.Cleanup(server.Close)
+
+	client := NewEsploraClient(&EsploraConfig{URL: server.URL})
+
+	tests := []struct {
+		numBlocks uint32
+		fee       int64
+	}{
+		{numBlocks: 1, fee: 30_000},
+		{numBlocks: 2, fee: 30_000},
+		{numBlocks: 6, fee: 20_000},
+		{numBlocks: 100, fee: 20_000},
+		{numBlocks: 1008, fee: 1_500},
+	}
+	for _, test := range tests {
+		fee, err := client.EstimateFee(test.numBlocks)
+		require.NoError(t, err)
+		require.EqualValues(t, test.fee, fee, "target %v",
+			test.numBlocks)
+	}
+}
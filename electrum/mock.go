@@ -0,0 +1,400 @@
+package electrum
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// staleBlock is a block that was disconnected from the chain of the mock
+// backend.
+type staleBlock struct {
+	block  *wire.MsgBlock
+	height int32
+}
+
+// MockBackend is an in-memory Backend used in tests. Its chain starts at the
+// genesis block of the given network and is extended with AddBlock.
+type MockBackend struct {
+	mtx sync.Mutex
+
+	// BlocksUnsupported makes the backend behave like an Electrum server
+	// that doesn't serve full blocks.
+	BlocksUnsupported bool
+
+	blocks  []*wire.MsgBlock
+	mempool []*wire.MsgTx
+	nonce   uint32
+
+	// staleBlocks are the blocks that were disconnected, keyed by their
+	// hash. Like a full node, the backend still knows them by hash.
+	staleBlocks map[chainhash.Hash]*staleBlock
+
+	// feeRate is the fee rate in sat/kvB returned for all confirmation
+	// targets. No estimate is available if it's zero.
+	feeRate btcutil.Amount
+}
+
+// A compile time check to ensure MockBackend implements the Backend
+// interface.
+var _ Backend = (*MockBackend)(nil)
+
+// NewMockBackend creates a new mock backend for the given network.
+func NewMockBackend(params *chaincfg.Params) *MockBackend {
+	return &MockBackend{
+		blocks:      []*wire.MsgBlock{params.GenesisBlock},
+		staleBlocks: make(map[chainhash.Hash]*staleBlock),
+	}
+}
+
+// AddBlock mines a block with the given transactions on top of the tip and
+// removes them from the mempool.
+func (m *MockBackend) AddBlock(txs ...*wire.MsgTx) *wire.MsgBlock {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.nonce++
+	tip := m.blocks[len(m.blocks)-1]
+	block := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: tip.BlockHash(),
+			Timestamp: time.Unix(time.Now().Unix(), 0),
+			Nonce:     m.nonce,
+		},
+		Transactions: txs,
+	}
+	m.blocks = append(m.blocks, block)
+
+	mined := make(map[chainhash.Hash]struct{}, len(txs))
+	for _, tx := range txs {
+		mined[tx.TxHash()] = struct{}{}
+	}
+	mempool := m.mempool[:0]
+	for _, tx := range m.mempool {
+		if _, ok := mined[tx.TxHash()]; !ok {
+			mempool = append(mempool, tx)
+		}
+	}
+	m.mempool = mempool
+
+	return block
+}
+
+// DisconnectBlocks removes the given number of blocks from the tip of the
+// chain and returns their transactions to the mempool.
+func (m *MockBackend) DisconnectBlocks(n int) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for i := 0; i < n && len(m.blocks) > 1; i++ {
+		height := int32(len(m.blocks) - 1)
+		tip := m.blocks[height]
+		m.blocks = m.blocks[:height]
+		m.mempool = append(m.mempool, tip.Transactions...)
+		m.staleBlocks[tip.BlockHash()] = &staleBlock{
+			block:  tip,
+			height: height,
+		}
+	}
+}
+
+// SetFeeRate sets the fee rate in sat/kvB returned for all confirmation
+// targets. No estimate is available if it's zero.
+func (m *MockBackend) SetFeeRate(feeRate btcutil.Amount) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.feeRate = feeRate
+}
+
+// AddMempoolTx adds a transaction to the mempool.
+func (m *MockBackend) AddMempoolTx(tx *wire.MsgTx) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.mempool = append(m.mempool, tx)
+}
+
+// Mempool returns the transactions in the mempool.
+func (m *MockBackend) Mempool() []*wire.MsgTx {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return append([]*wire.MsgTx(nil), m.mempool...)
+}
+
+// Name returns the name of the backend.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) Name() string {
+	return "mock"
+}
+
+// BestBlock returns the hash and height of the tip of the chain.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) BestBlock() (*chainhash.Hash, int32, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	hash := m.blocks[len(m.blocks)-1].BlockHash()
+
+	return &hash, int32(len(m.blocks) - 1), nil
+}
+
+// BlockHash returns the hash of the block at the given height.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) BlockHash(height int32) (*chainhash.Hash, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if height < 0 || int(height) >= len(m.blocks) {
+		return nil, fmt.Errorf("%w: height %v", ErrBlockNotFound,
+			height)
+	}
+	hash := m.blocks[height].BlockHash()
+
+	return &hash, nil
+}
+
+// block returns the block with the given hash and its height, which may be
+// a stale block.
+//
+// NOTE: Must be called with the mutex held.
+func (m *MockBackend) block(hash *chainhash.Hash) (*wire.MsgBlock, int32,
+	error) {
+
+	for height, block := range m.blocks {
+		if block.BlockHash() == *hash {
+			return block, int32(height), nil
+		}
+	}
+	if stale, ok := m.staleBlocks[*hash]; ok {
+		return stale.block, stale.height, nil
+	}
+
+	return nil, 0, fmt.Errorf("%w: %v", ErrBlockNotFound, hash)
+}
+
+// BlockHeader returns the header and height of the block with the given
+// hash.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) BlockHeader(hash *chainhash.Hash) (*wire.BlockHeader,
+	int32, error) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	block, height, err := m.block(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := block.Header
+
+	return &header, height, nil
+}
+
+// Block returns the block with the given hash.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) Block(hash *chainhash.Hash) (*wire.MsgBlock, error) {
+	if m.BlocksUnsupported {
+		return nil, ErrBlocksUnsupported
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	block, _, err := m.block(hash)
+
+	return block, err
+}
+
+// ScriptHistory returns the transactions that pay to or spend from the given
+// output script.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) ScriptHistory(pkScript []byte) ([]HistoryItem,
+	error) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	var (
+		history []HistoryItem
+		outputs = make(map[wire.OutPoint]struct{})
+	)
+	addRelevant := func(tx *wire.MsgTx, height int32) {
+		txHash := tx.TxHash()
+
+		relevant := false
+		for i, txOut := range tx.TxOut {
+			if bytes.Equal(txOut.PkScript, pkScript) {
+				outputs[wire.OutPoint{
+					Hash:  txHash,
+					Index: uint32(i),
+				}] = struct{}{}
+				relevant = true
+			}
+		}
+		for _, txIn := range tx.TxIn {
+			if _, ok := outputs[txIn.PreviousOutPoint]; ok {
+				relevant = true
+			}
+		}
+
+		if relevant {
+			history = append(history, HistoryItem{
+				TxHash: txHash,
+				Height: height,
+			})
+		}
+	}
+
+	for height, block := range m.blocks {
+		for _, tx := range block.Transactions {
+			addRelevant(tx, int32(height))
+		}
+	}
+	for _, tx := range m.mempool {
+		addRelevant(tx, 0)
+	}
+
+	return history, nil
+}
+
+// Transaction returns the transaction with the given hash.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) Transaction(txid *chainhash.Hash) (*wire.MsgTx,
+	error) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for _, block := range m.blocks {
+		for _, tx := range block.Transactions {
+			if tx.TxHash() == *txid {
+				return tx, nil
+			}
+		}
+	}
+	for _, tx := range m.mempool {
+		if tx.TxHash() == *txid {
+			return tx, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %v", ErrTxNotFound, txid)
+}
+
+// TxIndex returns the index of a transaction within the block at the given
+// height.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) TxIndex(txid *chainhash.Hash, height int32) (uint32,
+	error) {
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if height < 0 || int(height) >= len(m.blocks) {
+		return 0, fmt.Errorf("%w: height %v", ErrBlockNotFound, height)
+	}
+	for i, tx := range m.blocks[height].Transactions {
+		if tx.TxHash() == *txid {
+			return uint32(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %v", ErrTxNotFound, txid)
+}
+
+// Broadcast adds the transaction to the mempool.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) Broadcast(tx *wire.MsgTx) error {
+	m.AddMempoolTx(tx)
+	return nil
+}
+
+// EstimateFee returns the configured fee rate.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) EstimateFee(uint32) (btcutil.Amount, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if m.feeRate == 0 {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return m.feeRate, nil
+}
+
+// RelayFee returns the default relay fee of bitcoind.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) RelayFee() (btcutil.Amount, error) {
+	return defaultRelayFee, nil
+}
+
+// Close does nothing.
+//
+// NOTE: This is part of the Backend interface.
+func (m *MockBackend) Close() error {
+	return nil
+}
+
+// scriptByHash returns the output script with the given script hash among
+// the outputs of all known transactions.
+func (m *MockBackend) scriptByHash(scriptHash string) []byte {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	txs := append([]*wire.MsgTx(nil), m.mempool...)
+	for _, block := range m.blocks {
+		txs = append(txs, block.Transactions...)
+	}
+
+	for _, tx := range txs {
+		for _, txOut := range tx.TxOut {
+			if ScriptHash(txOut.PkScript) == scriptHash {
+				return txOut.PkScript
+			}
+		}
+	}
+
+	return nil
+}
+
+// txHeight returns the height of the block that includes the transaction
+// with the given hash, zero if it's in the mempool, or -1 if it's unknown.
+func (m *MockBackend) txHeight(txid *chainhash.Hash) int32 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	for height, block := range m.blocks {
+		for _, tx := range block.Transactions {
+			if tx.TxHash() == *txid {
+				return int32(height)
+			}
+		}
+	}
+	for _, tx := range m.mempool {
+		if tx.TxHash() == *txid {
+			return 0
+		}
+	}
+
+	return -1
+}
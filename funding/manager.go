@@ -1583,15 +1583,6 @@ func (f *Manager) fundeeProcessOpenChannel(peer lnpeer.Peer,
 		log.Error(err)
 		f.failFundingFlow(peer, cid, err)
 
-		return
-
-	// The current variant of taproot channels can only be used with
-	// unadvertised channels for now.
-	case commitType.IsTaproot() && public:
-		err = fmt.Errorf("taproot channel type for public channel")
-		log.Error(err)
-		f.failFundingFlow(peer, cid, err)
-
 		return
 	}
 
@@ -4336,7 +4327,19 @@ func (f *Manager) announceChannel(localIDKey, remoteIDKey *btcec.PublicKey,
 	// because addToRouterGraph previously sent the ChannelAnnouncement and
 	// the ChannelUpdate announcement messages. The channel proof and node
 	// announcements are broadcast to the greater network.
-	errChan := f.cfg.SendAnnouncement(ann.chanProof)
+	var chanProof lnwire.Message = ann.chanProof
+
+	// The announcement of a taproot channel is signed with MuSig2 together
+	// with the remote peer, so we'll instead instruct the gossiper to
+	// start the signing of the ChannelAnnouncement2.
+	if chanType.IsTaproot() {
+		chanProof = &lnwire.AnnounceSignatures2{
+			ChannelID:      chanID,
+			ShortChannelID: shortChanID,
+		}
+	}
+
+	errChan := f.cfg.SendAnnouncement(chanProof)
 	select {
 	case err := <-errChan:
 		if err != nil {
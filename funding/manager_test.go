@@ -35,7 +35,6 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lntest/mock"
 	"github.com/lightningnetwork/lnd/lntest/wait"
-	"github.com/lightningnetwork/lnd/lnutils"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
 	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
@@ -793,12 +792,6 @@ func fundChannel(t *testing.T, alice, bob *testNode, localFundingAmt,
 		Err:             errChan,
 	}
 
-	// If this is a taproot channel, then we want to force it to be a
-	// private channel, as that's the only channel type supported for now.
-	if isTaprootChanType(chanType) {
-		initReq.Private = true
-	}
-
 	alice.fundingMgr.InitFundingWorkflow(initReq)
 
 	// Alice should have sent the OpenChannel message to Bob.
@@ -1259,9 +1252,63 @@ func assertChannelAnnouncements(t *testing.T, alice, bob *testNode,
 	}
 }
 
+// assertAliasEdgeReAdded asserts that both nodes add the edge of their
+// option-scid-alias channel back to the graph after six confirmations, before
+// the channel is announced.
+func assertAliasEdgeReAdded(t *testing.T, alice, bob *testNode) {
+	t.Helper()
+
+	for j, node := range []*testNode{alice, bob} {
+		var chanAnn *lnwire.ChannelAnnouncement
+		select {
+		case msg := <-node.announceChan:
+			var ok bool
+			chanAnn, ok = msg.(*lnwire.ChannelAnnouncement)
+			require.Truef(t, ok, "node %d sent %T instead of "+
+				"ChannelAnnouncement", j, msg)
+
+		case <-time.After(time.Second * 5):
+			t.Fatalf("node %d didn't re-add the channel", j)
+		}
+
+		select {
+		case msg := <-node.announceChan:
+			chanUpdate, ok := msg.(*lnwire.ChannelUpdate)
+			require.Truef(t, ok, "node %d sent %T instead of "+
+				"ChannelUpdate", j, msg)
+			require.Equal(
+				t, chanAnn.ShortChannelID,
+				chanUpdate.ShortChannelID,
+			)
+
+		case <-time.After(time.Second * 5):
+			t.Fatalf("node %d didn't re-add its policy", j)
+		}
+	}
+}
+
 func assertAnnouncementSignatures(t *testing.T, alice, bob *testNode) {
 	t.Helper()
 
+	assertProofAnnouncements(t, alice, bob, false)
+}
+
+// assertTaprootAnnouncementSignatures asserts that both nodes start the MuSig2
+// signing of the announcement of their taproot channel.
+func assertTaprootAnnouncementSignatures(t *testing.T, alice, bob *testNode) {
+	t.Helper()
+
+	assertProofAnnouncements(t, alice, bob, true)
+}
+
+// assertProofAnnouncements asserts that both nodes send the proof of their
+// channel and their node announcement once the channel is announced. The proof
+// is an AnnounceSignatures2 message for taproot channels.
+func assertProofAnnouncements(t *testing.T, alice, bob *testNode,
+	taproot bool) {
+
+	t.Helper()
+
 	// After the ChannelReady message is sent and six confirmations have
 	// been reached, the channel will be announced to the greater network
 	// by having the nodes exchange announcement signatures.
@@ -1286,7 +1333,9 @@ func assertAnnouncementSignatures(t *testing.T, alice, bob *testNode) {
 		for _, msg := range announcements {
 			switch msg.(type) {
 			case *lnwire.AnnounceSignatures:
-				gotAnnounceSignatures = true
+				gotAnnounceSignatures = !taproot
+			case *lnwire.AnnounceSignatures2:
+				gotAnnounceSignatures = taproot
 			case *lnwire.NodeAnnouncement:
 				gotNodeAnnouncement = true
 			}
@@ -1302,25 +1351,6 @@ func assertAnnouncementSignatures(t *testing.T, alice, bob *testNode) {
 	}
 }
 
-func assertType[T any](t *testing.T, typ any) T {
-	value, ok := typ.(T)
-	require.True(t, ok)
-
-	return value
-}
-
-func assertNodeAnnSent(t *testing.T, alice, bob *testNode) {
-	t.Helper()
-
-	for _, node := range []*testNode{alice, bob} {
-		nodeAnn, err := lnutils.RecvOrTimeout(
-			node.msgChan, time.Second*5,
-		)
-		require.NoError(t, err)
-		assertType[*lnwire.NodeAnnouncement](t, *nodeAnn)
-	}
-}
-
 func waitForOpenUpdate(t *testing.T, updateChan chan *lnrpc.OpenStatusUpdate) {
 	var openUpdate *lnrpc.OpenStatusUpdate
 	select {
@@ -1534,11 +1564,13 @@ func testNormalWorkflow(t *testing.T, chanType *lnwire.ChannelType) {
 	}
 
 	switch {
-	// For taproot channels, we expect them to only send a node
-	// announcement message at this point. These channels aren't advertised
-	// so we don't expect the other messages.
+	// For taproot channels, we expect the fundingManagers to start the
+	// signing of the ChannelAnnouncement2 instead of exchanging
+	// announcement signatures. As the option-scid-alias feature was
+	// negotiated, both nodes first add the edge back to the graph.
 	case isTaprootChanType(chanType):
-		assertNodeAnnSent(t, alice, bob)
+		assertAliasEdgeReAdded(t, alice, bob)
+		assertTaprootAnnouncementSignatures(t, alice, bob)
 
 	// For regular channels, we'll make sure the fundingManagers exchange
 	// announcement signatures.
@@ -4579,12 +4611,7 @@ func testZeroConf(t *testing.T, chanType *lnwire.ChannelType) {
 		Tx: fundingTx,
 	}
 
-	// For taproot channels, we don't expect them to be announced atm.
-	if !isTaprootChanType(chanType) {
-		assertChannelAnnouncements(
-			t, alice, bob, fundingAmt, nil, nil, nil, nil,
-		)
-	}
+	assertChannelAnnouncements(t, alice, bob, fundingAmt, nil, nil, nil, nil)
 
 	// Both Alice and Bob should send on reportScidChan.
 	select {
@@ -4609,11 +4636,11 @@ func testZeroConf(t *testing.T, chanType *lnwire.ChannelType) {
 	}
 
 	switch {
-	// For taproot channels, we expect them to only send a node
-	// announcement message at this point. These channels aren't advertised
-	// so we don't expect the other messages.
+	// For taproot channels, we expect the fundingManagers to start the
+	// signing of the ChannelAnnouncement2 instead of exchanging
+	// announcement signatures.
 	case isTaprootChanType(chanType):
-		assertNodeAnnSent(t, alice, bob)
+		assertTaprootAnnouncementSignatures(t, alice, bob)
 
 	// For regular channels, we'll make sure the fundingManagers exchange
 	// announcement signatures.
@@ -12,10 +12,13 @@
 // For version 0 we have the following optional data fields defined:
 //   - shortchanid: the short channel ID that a transaction is associated with,
 //     with its value set to the uint64 short channel id.
+//   - scheduleid: the ID of the consolidation schedule that created a
+//     transaction.
 package labels
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/btcsuite/btcwallet/wtxmgr"
 	"github.com/lightningnetwork/lnd/lnwire"
@@ -68,6 +71,9 @@ const (
 
 	// LabelTypeSweepTransaction is used to label sweeps.
 	LabelTypeSweepTransaction LabelType = "sweep"
+
+	// LabelTypeConsolidation is used to label UTXO consolidations.
+	LabelTypeConsolidation LabelType = "consolidation"
 )
 
 // LabelField is used to tag a value within a label.
@@ -76,6 +82,9 @@ type LabelField string
 const (
 	// ShortChanID is used to tag short channel id values in our labels.
 	ShortChanID LabelField = "shortchanid"
+
+	// ScheduleID is used to tag consolidation schedule ids in our labels.
+	ScheduleID LabelField = "scheduleid"
 )
 
 // MakeLabel creates a label with the provided type and short channel id. If
@@ -90,3 +99,35 @@ func MakeLabel(labelType LabelType, channelID *lnwire.ShortChannelID) string {
 	return fmt.Sprintf("%v:%v:%v-%v", LabelVersionZero, labelType,
 		ShortChanID, channelID.ToUint64())
 }
+
+// MakeConsolidationLabel creates a label for a consolidation transaction that
+// was created by the consolidation schedule with the given id:
+// version:consolidation:scheduleid-{id}.
+func MakeConsolidationLabel(scheduleID uint64) string {
+	return fmt.Sprintf("%v:%v:%v-%v", LabelVersionZero,
+		LabelTypeConsolidation, ScheduleID, scheduleID)
+}
+
+// ParseLabelType returns the label type of a label that was created by lnd. If
+// the label wasn't created by lnd, false is returned.
+func ParseLabelType(label string) (LabelType, bool) {
+	parts := strings.Split(label, ":")
+	if len(parts) < 2 {
+		return "", false
+	}
+
+	if parts[0] != fmt.Sprintf("%v", LabelVersionZero) {
+		return "", false
+	}
+
+	switch labelType := LabelType(parts[1]); labelType {
+	case LabelTypeChannelOpen, LabelTypeChannelClose,
+		LabelTypeJusticeTransaction, LabelTypeSweepTransaction,
+		LabelTypeConsolidation:
+
+		return labelType, true
+
+	default:
+		return "", false
+	}
+}
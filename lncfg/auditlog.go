@@ -0,0 +1,42 @@
+package lncfg
+
+import "fmt"
+
+const (
+	// defaultAuditLogMaxFileSize is the default size in MB after which the
+	// audit log file is rotated.
+	defaultAuditLogMaxFileSize = 10
+)
+
+// AuditLog holds the configuration of the RPC audit log.
+//
+//nolint:lll
+type AuditLog struct {
+	Enable      bool `long:"enable" description:"Record every call of a mutating RPC, together with the identity of its macaroon, in an append-only audit log in the log directory."`
+	MaxFileSize int  `long:"maxfilesize" description:"Maximum size of the audit log file in MB before it is rotated."`
+	MaxFiles    int  `long:"maxfiles" description:"Maximum number of rotated audit log files to keep (0 to keep all of them)."`
+}
+
+// Validate checks the values configured for the audit log.
+func (a *AuditLog) Validate() error {
+	if !a.Enable {
+		return nil
+	}
+
+	if a.MaxFileSize <= 0 {
+		return fmt.Errorf("audit log max file size must be positive")
+	}
+
+	if a.MaxFiles < 0 {
+		return fmt.Errorf("audit log max files cannot be negative")
+	}
+
+	return nil
+}
+
+// DefaultAuditLog returns the default configuration of the audit log.
+func DefaultAuditLog() *AuditLog {
+	return &AuditLog{
+		MaxFileSize: defaultAuditLogMaxFileSize,
+	}
+}
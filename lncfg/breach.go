@@ -0,0 +1,32 @@
+package lncfg
+
+import "fmt"
+
+const (
+	// BreachHtlcStrategyBatch sweeps the HTLC outputs of a breached
+	// commitment in the same justice transaction as its other outputs.
+	BreachHtlcStrategyBatch = "batch"
+
+	// BreachHtlcStrategySeparate sweeps every HTLC output of a breached
+	// commitment in a justice transaction of its own.
+	BreachHtlcStrategySeparate = "separate"
+)
+
+// Breach holds the configuration of the justice spends for breached channels.
+//
+//nolint:lll
+type Breach struct {
+	HtlcStrategy string `long:"htlcstrategy" description:"How to sweep the HTLC outputs of a revoked commitment, and the outputs of the second level transactions the breaching party may take them to. If set to 'batch', they are swept in the same justice transaction as the other revoked outputs of all breached channels. If set to 'separate', every HTLC output is swept in a transaction of its own with a fee rate derived from its own deadline, so that an HTLC pinned by the breaching party can't hold back the other justice spends." choice:"batch" choice:"separate"`
+}
+
+// Validate checks the values configured for the justice spends.
+func (b *Breach) Validate() error {
+	switch b.HtlcStrategy {
+	case BreachHtlcStrategyBatch, BreachHtlcStrategySeparate:
+		return nil
+
+	default:
+		return fmt.Errorf("invalid breach.htlcstrategy: %v",
+			b.HtlcStrategy)
+	}
+}
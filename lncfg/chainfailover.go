@@ -0,0 +1,96 @@
+package lncfg
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// defaultFailoverCheckInterval is the default interval in which the
+	// health of the primary and the fallback chain backend is checked.
+	defaultFailoverCheckInterval = 30 * time.Second
+
+	// defaultFailoverFailureThreshold is the default number of
+	// consecutive failed health checks after which a chain backend is
+	// considered to be down.
+	defaultFailoverFailureThreshold = 3
+
+	// defaultFailoverMaxBlockLag is the default number of blocks a chain
+	// backend may fall behind the other one before it's considered to be
+	// down.
+	defaultFailoverMaxBlockLag = 3
+
+	// defaultFailoverDisagreementThreshold is the default number of
+	// consecutive checks in which the chain backends must report
+	// different blocks at the same height before we alert.
+	defaultFailoverDisagreementThreshold = 3
+)
+
+// ChainFailover holds the configuration options for a fallback chain backend
+// that takes over if the primary one goes down.
+//
+//nolint:lll
+type ChainFailover struct {
+	Node                  string        `long:"node" description:"The blockchain interface to use as fallback for the primary one set with bitcoin.node. The fallback uses the configuration section of its node type, except for bitcoind, which is configured in chainfailover.bitcoind so that a second bitcoind can be used. If empty, there is no fallback." choice:"btcd" choice:"bitcoind" choice:"neutrino" choice:"electrum" choice:"esplora"`
+	CheckInterval         time.Duration `long:"checkinterval" description:"The interval in which the health of both chain backends is checked and their blocks are compared."`
+	FailureThreshold      int           `long:"failurethreshold" description:"The number of consecutive failed health checks after which a chain backend is considered to be down."`
+	MaxBlockLag           uint32        `long:"maxblocklag" description:"The number of blocks a chain backend may fall behind the other one before it's considered to be down. Set to 0 to disable the check."`
+	DisagreementThreshold int           `long:"disagreementthreshold" description:"The number of consecutive checks in which the chain backends report different blocks at the same height before an alert is logged."`
+
+	Bitcoind *Bitcoind `group:"bitcoind" namespace:"bitcoind"`
+}
+
+// Active returns true if a fallback chain backend is configured.
+func (c *ChainFailover) Active() bool {
+	return c.Node != ""
+}
+
+// Validate checks the values configured for the fallback chain backend given
+// the node type of the primary one.
+func (c *ChainFailover) Validate(primaryNode string) error {
+	if !c.Active() {
+		return nil
+	}
+
+	// Except for bitcoind, a node type only has a single configuration
+	// section, so the fallback can't be of the same type as the primary.
+	if c.Node == primaryNode && c.Node != "bitcoind" {
+		return fmt.Errorf("chainfailover.node must differ from "+
+			"bitcoin.node unless both are bitcoind, got %v",
+			c.Node)
+	}
+
+	if c.Node == "bitcoind" && c.Bitcoind.RPCHost == "" {
+		return fmt.Errorf("chainfailover.bitcoind.rpchost must be set")
+	}
+
+	if c.CheckInterval <= 0 {
+		return fmt.Errorf("chainfailover.checkinterval must be " +
+			"positive")
+	}
+
+	if c.FailureThreshold <= 0 {
+		return fmt.Errorf("chainfailover.failurethreshold must be " +
+			"positive")
+	}
+
+	if c.DisagreementThreshold <= 0 {
+		return fmt.Errorf("chainfailover.disagreementthreshold must " +
+			"be positive")
+	}
+
+	return nil
+}
+
+// DefaultChainFailover returns the default configuration of the fallback chain
+// backend, which is disabled. The given bitcoind configuration holds the
+// defaults of a fallback bitcoind.
+func DefaultChainFailover(bitcoind *Bitcoind) *ChainFailover {
+	return &ChainFailover{
+		CheckInterval:         defaultFailoverCheckInterval,
+		FailureThreshold:      defaultFailoverFailureThreshold,
+		MaxBlockLag:           defaultFailoverMaxBlockLag,
+		DisagreementThreshold: defaultFailoverDisagreementThreshold,
+		Bitcoind:              bitcoind,
+	}
+}
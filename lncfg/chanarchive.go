@@ -0,0 +1,51 @@
+package lncfg
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ChanArchiveModeArchive writes the state of closed channels to a
+	// compressed archive before it is removed from the database.
+	ChanArchiveModeArchive = "archive"
+
+	// ChanArchiveModeDelete removes the state of closed channels from the
+	// database without keeping a copy.
+	ChanArchiveModeDelete = "delete"
+)
+
+// ChanArchive holds the configuration of the policy for the state of closed
+// channels.
+//
+//nolint:lll
+type ChanArchive struct {
+	Mode string `long:"mode" description:"What to do with the final state of channels that are fully closed and resolved, which includes their last commitments, HTLCs, revocation state and forwarding packages. If not set, the state is kept in the channel database forever. If set to 'archive', it is moved to compressed archive files in chanarchive.dir. If set to 'delete', it is removed without keeping a copy. The close summaries, the static channel info and the final balances of the channels are always kept." choice:"archive" choice:"delete"`
+
+	Dir string `long:"dir" description:"The directory the archive files are written to. Defaults to the channel-archive directory next to the channel database."`
+
+	SafetyDepth uint32 `long:"safetydepth" description:"The number of blocks that must have been mined on top of the closing transaction of a fully resolved channel before its state is archived or deleted."`
+
+	Interval time.Duration `long:"interval" description:"How often to check for closed channels whose state can be archived or deleted."`
+}
+
+// Validate checks the values configured for the closed channel policy.
+func (c *ChanArchive) Validate() error {
+	if c.Mode == "" {
+		return nil
+	}
+
+	if c.SafetyDepth < 1 {
+		return fmt.Errorf("chanarchive.safetydepth must be positive")
+	}
+
+	if c.Interval <= 0 {
+		return fmt.Errorf("chanarchive.interval must be positive")
+	}
+
+	return nil
+}
+
+// Compile-time constraint to ensure ChanArchive implements the Validator
+// interface.
+var _ Validator = (*ChanArchive)(nil)
@@ -0,0 +1,102 @@
+package lncfg
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChanBackup holds the configuration of the additional locations the static
+// channel backup is written to, next to the main backup file.
+//
+//nolint:lll
+type ChanBackup struct {
+	Dir string `long:"dir" description:"If set, every new version of the static channel backup is additionally written to a timestamped file in this directory."`
+
+	MaxVersions int `long:"maxversions" description:"The number of backup versions to keep in chanbackup.dir. Older versions are deleted."`
+
+	S3 *ChanBackupS3 `group:"s3" namespace:"s3"`
+
+	Webhook *ChanBackupWebhook `group:"webhook" namespace:"webhook"`
+}
+
+// ChanBackupS3 holds the configuration for uploading static channel backups
+// to an S3-compatible object store.
+//
+//nolint:lll
+type ChanBackupS3 struct {
+	Endpoint string `long:"endpoint" description:"The base URL of the S3-compatible object store, e.g. https://s3.us-east-1.amazonaws.com or http://127.0.0.1:9000. Setting this option enables the S3 backup sink."`
+
+	Bucket string `long:"bucket" description:"The bucket to store the backups in."`
+
+	Prefix string `long:"prefix" description:"An optional key prefix for the backup objects, e.g. mynode/."`
+
+	Region string `long:"region" description:"The region used to sign requests."`
+
+	AccessKeyID string `long:"accesskeyid" description:"The access key ID used to sign requests."`
+
+	Secret string `long:"secret" description:"The secret access key used to sign requests."`
+
+	MaxVersions int `long:"maxversions" description:"The number of backup versions to keep in the bucket. Older versions are deleted."`
+
+	Timeout time.Duration `long:"timeout" description:"The timeout of a single request to the object store."`
+}
+
+// ChanBackupWebhook holds the configuration for posting static channel
+// backups to a webhook.
+//
+//nolint:lll
+type ChanBackupWebhook struct {
+	URL string `long:"url" description:"If set, every new version of the static channel backup is posted to this URL."`
+
+	MaxAttempts int `long:"maxattempts" description:"The number of times a backup is posted to the webhook before giving up."`
+
+	RetryDelay time.Duration `long:"retrydelay" description:"The delay before the first retry of a failed post. The delay is doubled after each attempt."`
+
+	Timeout time.Duration `long:"timeout" description:"The timeout of a single post to the webhook."`
+}
+
+// Validate checks the values configured for the channel backup sinks.
+func (c *ChanBackup) Validate() error {
+	if c.Dir != "" && c.MaxVersions < 1 {
+		return fmt.Errorf("chanbackup.maxversions must be positive")
+	}
+
+	if c.S3.Endpoint != "" {
+		if c.S3.Bucket == "" {
+			return fmt.Errorf("chanbackup.s3.bucket must be set")
+		}
+
+		if c.S3.MaxVersions < 1 {
+			return fmt.Errorf("chanbackup.s3.maxversions must be " +
+				"positive")
+		}
+
+		if c.S3.Timeout <= 0 {
+			return fmt.Errorf("chanbackup.s3.timeout must be " +
+				"positive")
+		}
+	}
+
+	if c.Webhook.URL != "" {
+		if c.Webhook.MaxAttempts < 1 {
+			return fmt.Errorf("chanbackup.webhook.maxattempts " +
+				"must be positive")
+		}
+
+		if c.Webhook.RetryDelay < 0 {
+			return fmt.Errorf("chanbackup.webhook.retrydelay " +
+				"must not be negative")
+		}
+
+		if c.Webhook.Timeout <= 0 {
+			return fmt.Errorf("chanbackup.webhook.timeout must " +
+				"be positive")
+		}
+	}
+
+	return nil
+}
+
+// Compile-time constraint to ensure ChanBackup implements the Validator
+// interface.
+var _ Validator = (*ChanBackup)(nil)
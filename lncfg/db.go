@@ -8,6 +8,8 @@ import (
 	"time"
 
 	"github.com/btcsuite/btclog"
+	"github.com/lightningnetwork/lnd/dbcompact"
+	"github.com/lightningnetwork/lnd/dbmigrate"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/kvdb/etcd"
 	"github.com/lightningnetwork/lnd/kvdb/postgres"
@@ -92,6 +94,10 @@ type DB struct {
 	PruneRevocation bool `long:"prune-revocation" description:"Run the optional migration that prunes the revocation logs to save disk space."`
 
 	NoRevLogAmtData bool `long:"no-rev-log-amt-data" description:"If set, the to-local and to-remote output amounts of revoked commitment transactions will not be stored in the revocation log. Note that once this data is lost, a watchtower client will not be able to back up the revoked state."`
+
+	OnlineCompaction bool `long:"online-compaction" description:"Allow the channel database to be compacted while lnd is running by using the CompactDatabase RPC. Only supported by the bolt backend."`
+
+	MigrateFromBolt bool `long:"migrate-from-bolt" description:"Copy the content of the existing bbolt database files into the configured sqlite or postgres backend, verify the copy and then exit. lnd must not be running while the migration is in progress. An interrupted migration continues where it left off when started again."`
 }
 
 // DefaultDB creates and returns a new default DB config.
@@ -163,6 +169,20 @@ func (db *DB) Validate() error {
 			"backend '%v'", db.Backend)
 	}
 
+	if db.OnlineCompaction && db.Backend != BoltBackend {
+		return fmt.Errorf("cannot use online-compaction with "+
+			"database backend '%v'", db.Backend)
+	}
+
+	// The bbolt migration can only copy into the SQL backends that keep
+	// their data outside of the bbolt files.
+	if db.MigrateFromBolt && db.Backend != PostgresBackend &&
+		db.Backend != SqliteBackend {
+
+		return fmt.Errorf("migrate-from-bolt requires the '%v' or "+
+			"'%v' database backend", PostgresBackend, SqliteBackend)
+	}
+
 	return nil
 }
 
@@ -619,40 +639,37 @@ func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 	}
 
 	// We're using all bbolt based databases by default.
-	boltBackend, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
-		DBPath:            chanDBPath,
-		DBFileName:        ChannelDBName,
-		DBTimeout:         db.Bolt.DBTimeout,
-		NoFreelistSync:    db.Bolt.NoFreelistSync,
-		AutoCompact:       db.Bolt.AutoCompact,
-		AutoCompactMinAge: db.Bolt.AutoCompactMinAge,
-	})
+	var boltBackend kvdb.Backend
+	boltBackend, err := db.openBoltBackend(chanDBPath, ChannelDBName)
 	if err != nil {
 		return nil, fmt.Errorf("error opening bolt DB: %w", err)
 	}
+
+	// If requested, we allow the channel DB to be compacted while it is
+	// in use.
+	if db.OnlineCompaction {
+		boltBackend = dbcompact.NewBackend(
+			boltBackend, &dbcompact.Config{
+				DBPath:         chanDBPath,
+				DBFileName:     ChannelDBName,
+				NoFreelistSync: db.Bolt.NoFreelistSync,
+				DBTimeout:      db.Bolt.DBTimeout,
+				MaxPause:       dbcompact.DefaultMaxPause,
+				Copy:           dbmigrate.DefaultConfig(),
+			},
+		)
+	}
 	closeFuncs[NSChannelDB] = boltBackend.Close
 
-	macaroonBackend, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
-		DBPath:            walletDBPath,
-		DBFileName:        MacaroonDBName,
-		DBTimeout:         db.Bolt.DBTimeout,
-		NoFreelistSync:    db.Bolt.NoFreelistSync,
-		AutoCompact:       db.Bolt.AutoCompact,
-		AutoCompactMinAge: db.Bolt.AutoCompactMinAge,
-	})
+	macaroonBackend, err := db.openBoltBackend(walletDBPath, MacaroonDBName)
 	if err != nil {
 		return nil, fmt.Errorf("error opening macaroon DB: %w", err)
 	}
 	closeFuncs[NSMacaroonDB] = macaroonBackend.Close
 
-	decayedLogBackend, err := kvdb.GetBoltBackend(&kvdb.BoltBackendConfig{
-		DBPath:            chanDBPath,
-		DBFileName:        DecayedLogDbName,
-		DBTimeout:         db.Bolt.DBTimeout,
-		NoFreelistSync:    db.Bolt.NoFreelistSync,
-		AutoCompact:       db.Bolt.AutoCompact,
-		AutoCompactMinAge: db.Bolt.AutoCompactMinAge,
-	})
+	decayedLogBackend, err := db.openBoltBackend(
+		chanDBPath, DecayedLogDbName,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("error opening decayed log DB: %w", err)
 	}
@@ -662,15 +679,8 @@ func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 	// handle it being nil properly in the main server.
 	var towerClientBackend kvdb.Backend
 	if towerClientEnabled {
-		towerClientBackend, err = kvdb.GetBoltBackend(
-			&kvdb.BoltBackendConfig{
-				DBPath:            chanDBPath,
-				DBFileName:        TowerClientDBName,
-				DBTimeout:         db.Bolt.DBTimeout,
-				NoFreelistSync:    db.Bolt.NoFreelistSync,
-				AutoCompact:       db.Bolt.AutoCompact,
-				AutoCompactMinAge: db.Bolt.AutoCompactMinAge,
-			},
+		towerClientBackend, err = db.openBoltBackend(
+			chanDBPath, TowerClientDBName,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error opening tower client "+
@@ -683,15 +693,8 @@ func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 	// handle it being nil properly in the main server.
 	var towerServerBackend kvdb.Backend
 	if towerServerEnabled {
-		towerServerBackend, err = kvdb.GetBoltBackend(
-			&kvdb.BoltBackendConfig{
-				DBPath:            towerServerDBPath,
-				DBFileName:        TowerServerDBName,
-				DBTimeout:         db.Bolt.DBTimeout,
-				NoFreelistSync:    db.Bolt.NoFreelistSync,
-				AutoCompact:       db.Bolt.AutoCompact,
-				AutoCompactMinAge: db.Bolt.AutoCompactMinAge,
-			},
+		towerServerBackend, err = db.openBoltBackend(
+			towerServerDBPath, TowerServerDBName,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("error opening tower server "+
@@ -722,6 +725,42 @@ func (db *DB) GetBackends(ctx context.Context, chanDBPath,
 	}, nil
 }
 
+// openBoltBackend opens the bolt database file with the given name. If a
+// compaction of the file was scheduled through the CompactDatabase RPC, the
+// file is compacted before it is opened, regardless of the auto compaction
+// settings.
+func (db *DB) openBoltBackend(dir, fileName string) (kvdb.Backend, error) {
+	cfg := &kvdb.BoltBackendConfig{
+		DBPath:            dir,
+		DBFileName:        fileName,
+		DBTimeout:         db.Bolt.DBTimeout,
+		NoFreelistSync:    db.Bolt.NoFreelistSync,
+		AutoCompact:       db.Bolt.AutoCompact,
+		AutoCompactMinAge: db.Bolt.AutoCompactMinAge,
+	}
+
+	scheduled := dbcompact.CompactionScheduled(dir, fileName)
+	if scheduled {
+		cfg.AutoCompact = true
+		cfg.AutoCompactMinAge = 0
+	}
+
+	backend, err := kvdb.GetBoltBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if scheduled {
+		err := dbcompact.ClearScheduledCompaction(dir, fileName)
+		if err != nil {
+			_ = backend.Close()
+			return nil, err
+		}
+	}
+
+	return backend, nil
+}
+
 // warnExistingBoltDBs checks if there is an existing bbolt database in the
 // given location and logs a warning if so.
 func warnExistingBoltDBs(log btclog.Logger, dbType, dir, fileName string) {
@@ -0,0 +1,97 @@
+package lncfg
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const (
+	// defaultElectrumPollInterval is the default interval in which an
+	// Electrum or Esplora server is polled for new blocks.
+	defaultElectrumPollInterval = 10 * time.Second
+
+	// defaultElectrumRequestTimeout is the default timeout of a single
+	// request to an Electrum or Esplora server.
+	defaultElectrumRequestTimeout = 30 * time.Second
+)
+
+// Electrum holds the configuration options for the daemon's connection to an
+// Electrum server.
+//
+//nolint:lll
+type Electrum struct {
+	Server         string        `long:"server" description:"The host:port of the Electrum server to connect to."`
+	TLS            bool          `long:"tls" description:"Connect to the Electrum server over TLS."`
+	TLSCertPath    string        `long:"tlscertpath" description:"Path to the certificate of the Electrum server. If set, the server must present exactly this certificate instead of one signed by a trusted CA, which allows self-signed certificates. Implies tls."`
+	PollInterval   time.Duration `long:"pollinterval" description:"The interval in which the server is polled for new blocks and transactions of watched scripts."`
+	RequestTimeout time.Duration `long:"requesttimeout" description:"The timeout of a single request to the server."`
+}
+
+// Validate checks the values configured for the Electrum backend.
+func (e *Electrum) Validate() error {
+	if e.Server == "" {
+		return fmt.Errorf("electrum.server must be set")
+	}
+
+	if e.PollInterval <= 0 {
+		return fmt.Errorf("electrum.pollinterval must be positive")
+	}
+
+	if e.RequestTimeout <= 0 {
+		return fmt.Errorf("electrum.requesttimeout must be positive")
+	}
+
+	return nil
+}
+
+// DefaultElectrum returns the default configuration of the Electrum backend.
+func DefaultElectrum() *Electrum {
+	return &Electrum{
+		PollInterval:   defaultElectrumPollInterval,
+		RequestTimeout: defaultElectrumRequestTimeout,
+	}
+}
+
+// Esplora holds the configuration options for the daemon's connection to an
+// Esplora HTTP API.
+//
+//nolint:lll
+type Esplora struct {
+	URL            string        `long:"url" description:"The base URL of the Esplora API, e.g. https://blockstream.info/api."`
+	PollInterval   time.Duration `long:"pollinterval" description:"The interval in which the API is polled for new blocks and transactions of watched scripts."`
+	RequestTimeout time.Duration `long:"requesttimeout" description:"The timeout of a single request to the API."`
+}
+
+// Validate checks the values configured for the Esplora backend.
+func (e *Esplora) Validate() error {
+	if e.URL == "" {
+		return fmt.Errorf("esplora.url must be set")
+	}
+
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return fmt.Errorf("invalid esplora.url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("esplora.url must be an http or https URL")
+	}
+
+	if e.PollInterval <= 0 {
+		return fmt.Errorf("esplora.pollinterval must be positive")
+	}
+
+	if e.RequestTimeout <= 0 {
+		return fmt.Errorf("esplora.requesttimeout must be positive")
+	}
+
+	return nil
+}
+
+// DefaultEsplora returns the default configuration of the Esplora backend.
+func DefaultEsplora() *Esplora {
+	return &Esplora{
+		PollInterval:   defaultElectrumPollInterval,
+		RequestTimeout: defaultElectrumRequestTimeout,
+	}
+}
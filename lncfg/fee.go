@@ -0,0 +1,105 @@
+package lncfg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+const (
+	// FeeSourceBackend is the fee estimation source backed by the chain
+	// backend.
+	FeeSourceBackend = "backend"
+
+	// FeeSourceWebAPI is the fee estimation source backed by the web API
+	// set with feeurl.
+	FeeSourceWebAPI = "webapi"
+
+	// FeeSourceMempool is the fee estimation source backed by the mempool
+	// histogram of a bitcoind chain backend.
+	FeeSourceMempool = "mempool"
+
+	// defaultFeeSourceTimeout is the default time a fee estimation source
+	// has to answer.
+	defaultFeeSourceTimeout = 5 * time.Second
+
+	// defaultFeeMaxStaleness is the default maximum age of a previous
+	// estimate that is used while a source fails to answer.
+	defaultFeeMaxStaleness = 10 * time.Minute
+)
+
+// Fee holds the configuration options for combining several fee estimation
+// sources.
+//
+//nolint:lll
+type Fee struct {
+	Sources                []string      `long:"source" description:"A fee estimation source to combine with the others. Can be specified multiple times. 'backend' is the estimator of the chain backend, 'webapi' the one at feeurl and 'mempool' one that derives fee rates from the mempool of a bitcoind chain backend, treating the confirmation target as a position in the mempool. If no source is set, the estimator at feeurl or else the one of the chain backend is used."`
+	Combine                string        `long:"combine" description:"How the fee rates of the sources are combined." choice:"median" choice:"max"`
+	SourceTimeout          time.Duration `long:"sourcetimeout" description:"The maximum time a fee estimation source has to answer before it's ignored."`
+	MaxStaleness           time.Duration `long:"maxstaleness" description:"The maximum age of a previous estimate of a source that is used while the source fails to answer. Set to 0 to never use previous estimates."`
+	MempoolRefreshInterval time.Duration `long:"mempoolrefreshinterval" description:"The interval in which the mempool source fetches the mempool of the bitcoind chain backend."`
+}
+
+// Active returns true if several fee estimation sources are combined.
+func (f *Fee) Active() bool {
+	return len(f.Sources) > 0
+}
+
+// HasSource returns true if the given fee estimation source is configured.
+func (f *Fee) HasSource(source string) bool {
+	for _, s := range f.Sources {
+		if s == source {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the values configured for the fee estimation sources.
+func (f *Fee) Validate() error {
+	seen := make(map[string]struct{}, len(f.Sources))
+	for _, source := range f.Sources {
+		switch source {
+		case FeeSourceBackend, FeeSourceWebAPI, FeeSourceMempool:
+
+		default:
+			return fmt.Errorf("unknown fee.source: %v", source)
+		}
+
+		if _, ok := seen[source]; ok {
+			return fmt.Errorf("duplicate fee.source: %v", source)
+		}
+		seen[source] = struct{}{}
+	}
+
+	if _, err := chainfee.ParseCombineMode(f.Combine); err != nil {
+		return err
+	}
+
+	if f.SourceTimeout <= 0 {
+		return fmt.Errorf("fee.sourcetimeout must be positive")
+	}
+
+	if f.MaxStaleness < 0 {
+		return fmt.Errorf("fee.maxstaleness must not be negative")
+	}
+
+	if f.MempoolRefreshInterval <= 0 {
+		return fmt.Errorf("fee.mempoolrefreshinterval must be positive")
+	}
+
+	return nil
+}
+
+// DefaultFee returns the default configuration of the fee estimation sources,
+// which doesn't combine any.
+func DefaultFee() *Fee {
+	return &Fee{
+		Combine:                chainfee.CombineMedian.String(),
+		SourceTimeout:          defaultFeeSourceTimeout,
+		MaxStaleness:           defaultFeeMaxStaleness,
+		MempoolRefreshInterval: chainfee.DefaultMempoolRefreshInterval,
+	}
+}
@@ -1,6 +1,7 @@
 package lncfg
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/lightningnetwork/lnd/discovery"
@@ -18,6 +19,16 @@ type Gossip struct {
 	ChannelUpdateInterval time.Duration `long:"channel-update-interval" description:"The interval used to determine how often lnd should allow a burst of new updates for a specific channel and direction."`
 
 	SubBatchDelay time.Duration `long:"sub-batch-delay" description:"The duration to wait before sending the next announcement batch if there are multiple. Use a small value if there are a lot announcements and they need to be broadcast quickly."`
+
+	PeerInboundBytesPerSec uint64 `long:"peer-inbound-bytes-per-sec" description:"The maximum rate in bytes per second at which we'll accept gossip from a single peer. Once exceeded, the peer's gossip is paused until the budget recovers and our sync queries to it are delayed. 0 means unlimited."`
+
+	PeerOutboundBytesPerSec uint64 `long:"peer-outbound-bytes-per-sec" description:"The maximum rate in bytes per second at which we'll send gossip to a single peer. Once exceeded, relayed announcements are dropped and replies to the peer's queries are delayed. Our own announcements are always sent. 0 means unlimited."`
+
+	PeerBurstBytes uint64 `long:"peer-burst-bytes" description:"The number of gossip bytes that can be exchanged with a peer in either direction before the per-peer rate limits apply. 0 means one second worth of traffic."`
+
+	MaxSyncHops uint32 `long:"max-sync-hops" description:"If set, only channels within this number of hops of our node are added to the graph. Channels that are further away are dropped before their signatures are verified. 0 means the full graph is synced."`
+
+	MinChanCapacity int64 `long:"min-chan-capacity" description:"If set, channels of other nodes with a capacity in satoshis below this value are not added to the graph."`
 }
 
 // Parse the pubkeys for the pinned syncers.
@@ -33,5 +44,9 @@ func (g *Gossip) Parse() error {
 
 	g.PinnedSyncers = pinnedSyncers
 
+	if g.MinChanCapacity < 0 {
+		return fmt.Errorf("min-chan-capacity must be positive")
+	}
+
 	return nil
 }
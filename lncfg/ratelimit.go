@@ -0,0 +1,100 @@
+package lncfg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lightningnetwork/lnd/macaroons"
+)
+
+const (
+	// defaultRPCRateLimitBurst is the default number of calls that can be
+	// made at once before the rate limit applies.
+	defaultRPCRateLimitBurst = 10
+)
+
+// RPCRateLimit holds the configuration of the limits enforced on the RPC calls
+// made with the macaroons of each root key ID.
+//
+//nolint:lll
+type RPCRateLimit struct {
+	Rate         float64  `long:"rate" description:"The maximum number of calls per second of each RPC method with macaroons of the same root key ID (0 to disable)."`
+	Burst        int      `long:"burst" description:"The number of calls of each RPC method that can be made at once before the rate limit applies."`
+	MethodLimits []string `long:"method" description:"Overrides the rate limit of a single RPC method, in the format <full method URI>=<rate>[,<burst>], e.g. /lnrpc.Lightning/SendPaymentSync=1,5. A rate of 0 disables the limit of the method. Can be specified multiple times."`
+	MaxStreams   uint32   `long:"maxstreams" description:"The maximum number of concurrent streams of each streaming RPC method with macaroons of the same root key ID (0 to disable)."`
+}
+
+// Validate checks the values configured for the RPC rate limits.
+func (r *RPCRateLimit) Validate() error {
+	if r.Rate < 0 {
+		return fmt.Errorf("rpc rate limit cannot be negative")
+	}
+
+	if r.Rate > 0 && r.Burst <= 0 {
+		return fmt.Errorf("rpc rate limit burst must be positive")
+	}
+
+	_, err := r.ParseMethodLimits()
+
+	return err
+}
+
+// DefaultLimit returns the rate limit of all methods that don't override it.
+func (r *RPCRateLimit) DefaultLimit() macaroons.RateLimit {
+	return macaroons.RateLimit{
+		Rate:  r.Rate,
+		Burst: r.Burst,
+	}
+}
+
+// ParseMethodLimits parses the rate limits of individual methods, keyed by
+// their full URI.
+func (r *RPCRateLimit) ParseMethodLimits() (map[string]macaroons.RateLimit,
+	error) {
+
+	limits := make(map[string]macaroons.RateLimit, len(r.MethodLimits))
+	for _, methodLimit := range r.MethodLimits {
+		method, limit, ok := strings.Cut(methodLimit, "=")
+		if !ok || !strings.HasPrefix(method, "/") {
+			return nil, fmt.Errorf("invalid rpc method rate limit "+
+				"%q, must be <method>=<rate>[,<burst>]",
+				methodLimit)
+		}
+
+		rateStr, burstStr, hasBurst := strings.Cut(limit, ",")
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("invalid rate in rpc method "+
+				"rate limit %q", methodLimit)
+		}
+
+		burst := r.Burst
+		if hasBurst {
+			burst, err = strconv.Atoi(burstStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid burst in rpc "+
+					"method rate limit %q", methodLimit)
+			}
+		}
+		if rate > 0 && burst <= 0 {
+			return nil, fmt.Errorf("burst of rpc method rate "+
+				"limit %q must be positive", methodLimit)
+		}
+
+		limits[method] = macaroons.RateLimit{
+			Rate:  rate,
+			Burst: burst,
+		}
+	}
+
+	return limits, nil
+}
+
+// DefaultRPCRateLimit returns the default configuration of the RPC rate
+// limits, which doesn't limit any call.
+func DefaultRPCRateLimit() *RPCRateLimit {
+	return &RPCRateLimit{
+		Burst: defaultRPCRateLimitBurst,
+	}
+}
@@ -2,6 +2,7 @@ package lncfg
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -21,6 +22,52 @@ type RemoteSigner struct {
 	TLSCertPath      string        `long:"tlscertpath" description:"The TLS certificate to use for establishing the remote signer's identity"`
 	Timeout          time.Duration `long:"timeout" description:"The timeout for connecting to and signing requests with the remote signer. Valid time units are {s, m, h}."`
 	MigrateWatchOnly bool          `long:"migrate-wallet-to-watch-only" description:"If a wallet with private key material already exists, migrate it into a watch-only wallet on first startup. WARNING: This cannot be undone! Make sure you have backed up your seed before you use this flag! All private keys will be purged from the wallet after first unlock with this flag!"`
+	Fallbacks        []string      `long:"fallback" description:"An additional remote signer that is used if the signers before it aren't reachable, in the format host:port,tlscertpath,macaroonpath. Can be specified multiple times, the signers are tried in the order they are specified. All signers must use the same seed/root key."`
+}
+
+// RemoteSignerEndpoint describes how to connect to a single remote signer.
+type RemoteSignerEndpoint struct {
+	// RPCHost is the remote signer's RPC host:port.
+	RPCHost string
+
+	// TLSCertPath is the TLS certificate to use for establishing the
+	// remote signer's identity.
+	TLSCertPath string
+
+	// MacaroonPath is the macaroon to use for authenticating with the
+	// remote signer.
+	MacaroonPath string
+}
+
+// Endpoints returns all configured remote signers in the order of their
+// priority, starting with the primary signer.
+func (r *RemoteSigner) Endpoints() ([]*RemoteSignerEndpoint, error) {
+	endpoints := []*RemoteSignerEndpoint{{
+		RPCHost:      r.RPCHost,
+		TLSCertPath:  r.TLSCertPath,
+		MacaroonPath: r.MacaroonPath,
+	}}
+
+	for _, fallback := range r.Fallbacks {
+		parts := strings.Split(fallback, ",")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("remote signer: invalid "+
+				"fallback %q, expected format "+
+				"host:port,tlscertpath,macaroonpath", fallback)
+		}
+
+		endpoints = append(endpoints, &RemoteSignerEndpoint{
+			RPCHost: strings.TrimSpace(parts[0]),
+			TLSCertPath: CleanAndExpandPath(
+				strings.TrimSpace(parts[1]),
+			),
+			MacaroonPath: CleanAndExpandPath(
+				strings.TrimSpace(parts[2]),
+			),
+		})
+	}
+
+	return endpoints, nil
 }
 
 // Validate checks the values configured for our remote RPC signer.
@@ -41,5 +88,9 @@ func (r *RemoteSigner) Validate() error {
 			"enabled")
 	}
 
+	if _, err := r.Endpoints(); err != nil {
+		return err
+	}
+
 	return nil
 }
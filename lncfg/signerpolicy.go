@@ -0,0 +1,64 @@
+package lncfg
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+// SignerPolicy holds the configuration of the policy a remote signer enforces
+// before it signs transactions for its watch-only node.
+//
+//nolint:lll
+type SignerPolicy struct {
+	Active bool `long:"active" description:"Check all transactions this node is asked to sign through the signrpc and walletrpc sub-servers against the signer policy. Only useful if this node is the remote signer of a watch-only node. Requests that violate the policy are refused and logged."`
+
+	AllowedDestinations []string `long:"allowed-destination" description:"An address wallet sends are allowed to pay to. Can be specified multiple times. If not set, wallet sends can pay to any address. Spends of channel funding outputs, like cooperative closes, can only pay to these addresses or to the wallet of the signer, even if not set. This includes commitments unless allowunverifiedcommitments is set."`
+
+	AllowChannelFunding bool `long:"allowchannelfunding" description:"Allow wallet sends to pay to any P2WSH or P2TR output even if it isn't in the list of allowed destinations, which is required to open channels. Such outputs still count against the send limit."`
+
+	SendLimit btcutil.Amount `long:"sendlimit" description:"The maximum amount in satoshis that wallet sends are allowed to pay to external destinations within the send window. If set to 0, there is no limit."`
+
+	SendWindow time.Duration `long:"sendwindow" description:"The duration of the rolling window the send limit applies to."`
+
+	MaxCommitFeeRate chainfee.SatPerVByte `long:"maxcommitfeerate" description:"The maximum fee rate in sat/vb of commitment transactions the signer signs."`
+
+	MaxCommitHTLCs int `long:"maxcommithtlcs" description:"The maximum number of HTLC outputs of commitment transactions the signer signs."`
+
+	AllowUnverifiedCommitments bool `long:"allowunverifiedcommitments" description:"Allow signing commitment transactions, which are only checked for revoked states, sane fees and HTLC counts. The signer doesn't know the keys of the channels, so it can't verify that the outputs of a commitment pay to the parties of the channel. Without this, commitments are checked against the allowed destinations like any other spend of a channel funding output, which refuses nearly all of them."`
+
+	AllowUnverifiedMuSig2 bool `long:"allowunverifiedmusig2" description:"Allow MuSig2 signing requests. These only contain the digest of the message to sign and can't be checked against the policy, but are required for taproot channels."`
+}
+
+// Validate checks the values configured for the signer policy.
+func (s *SignerPolicy) Validate() error {
+	if !s.Active {
+		return nil
+	}
+
+	if s.SendLimit < 0 {
+		return fmt.Errorf("signerpolicy.sendlimit must not be negative")
+	}
+
+	if s.SendLimit > 0 && s.SendWindow <= 0 {
+		return fmt.Errorf("signerpolicy.sendwindow must be positive")
+	}
+
+	if s.MaxCommitFeeRate == 0 {
+		return fmt.Errorf("signerpolicy.maxcommitfeerate must be " +
+			"positive")
+	}
+
+	if s.MaxCommitHTLCs <= 0 {
+		return fmt.Errorf("signerpolicy.maxcommithtlcs must be " +
+			"positive")
+	}
+
+	return nil
+}
+
+// Compile-time constraint to ensure SignerPolicy implements the Validator
+// interface.
+var _ Validator = (*SignerPolicy)(nil)
@@ -0,0 +1,119 @@
+package lncfg
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// UnlockProviderAgent fetches the wallet password from a key agent
+	// listening on a Unix socket.
+	UnlockProviderAgent = "agent"
+
+	// UnlockProviderPlugin fetches the wallet password from a plugin
+	// process.
+	UnlockProviderPlugin = "plugin"
+
+	// UnlockProviderSealed unseals the wallet password from a file bound
+	// to the machine ID.
+	UnlockProviderSealed = "sealed"
+
+	// DefaultUnlockKeyID is the default ID of the wallet password sent to
+	// key agents and plugins.
+	DefaultUnlockKeyID = "lnd"
+
+	// DefaultUnlockTimeout is the default time after which a request to
+	// the unlock provider is aborted.
+	DefaultUnlockTimeout = 30 * time.Second
+
+	// DefaultUnlockRetries is the default number of times fetching the
+	// password and unlocking the wallet is retried.
+	DefaultUnlockRetries = 3
+
+	// DefaultUnlockRetryInterval is the default time to wait before
+	// retrying to unlock the wallet.
+	DefaultUnlockRetryInterval = 10 * time.Second
+)
+
+// Unlock holds the configuration of the external provider the wallet
+// password is fetched from to unlock the wallet automatically.
+//
+//nolint:lll
+type Unlock struct {
+	Provider      string        `long:"provider" description:"The external provider the wallet password is fetched from to unlock the wallet automatically; agent fetches it from a key agent listening on a Unix socket, plugin from a plugin process (e.g. talking to a PKCS#11 token) and sealed from a file bound to the ID of this machine. Empty disables automatic unlocking through a provider."`
+	KeyID         string        `long:"keyid" description:"The ID of the wallet password sent to the key agent or plugin, for agents and plugins that keep the passwords of several wallets."`
+	AgentSocket   string        `long:"agentsocket" description:"The path of the Unix socket of the key agent."`
+	PluginPath    string        `long:"pluginpath" description:"The path of the executable of the unlock plugin."`
+	PluginArgs    []string      `long:"pluginarg" description:"An argument the unlock plugin is executed with. Can be specified multiple times."`
+	SealedFile    string        `long:"sealedfile" description:"The path of the file the wallet password is sealed in. The file is written when the wallet is created, unlocked or has its password changed over RPC."`
+	Timeout       time.Duration `long:"timeout" description:"The time after which a request to the unlock provider is aborted."`
+	Retries       int           `long:"retries" description:"The number of times fetching the password and unlocking the wallet is retried if it fails, for example because the key agent isn't running yet."`
+	RetryInterval time.Duration `long:"retryinterval" description:"The time to wait before retrying to unlock the wallet."`
+	RPCFallback   bool          `long:"rpcfallback" description:"Wait for the wallet to be unlocked over RPC if it can't be unlocked with the password of the provider, instead of exiting. The password used over RPC is stored in the provider."`
+	Manual        bool          `long:"manual" description:"Don't fetch the password from the provider on this start. Instead wait for the wallet to be created, unlocked or have its password changed over RPC and store the password in the provider. Used to set up the provider or to rotate the password with ChangePassword."`
+}
+
+// Enabled returns true if an unlock provider is configured.
+func (u *Unlock) Enabled() bool {
+	return u.Provider != ""
+}
+
+// Validate checks the values configured for the unlock provider.
+func (u *Unlock) Validate() error {
+	switch u.Provider {
+	case "":
+		if u.RPCFallback || u.Manual {
+			return fmt.Errorf("unlock.rpcfallback and " +
+				"unlock.manual require an unlock.provider")
+		}
+
+		return nil
+
+	case UnlockProviderAgent:
+		if u.AgentSocket == "" {
+			return fmt.Errorf("unlock.agentsocket must be set " +
+				"for the agent unlock provider")
+		}
+
+	case UnlockProviderPlugin:
+		if u.PluginPath == "" {
+			return fmt.Errorf("unlock.pluginpath must be set for " +
+				"the plugin unlock provider")
+		}
+
+	case UnlockProviderSealed:
+		if u.SealedFile == "" {
+			return fmt.Errorf("unlock.sealedfile must be set for " +
+				"the sealed unlock provider")
+		}
+
+	default:
+		return fmt.Errorf("unknown unlock.provider %q, must be one "+
+			"of %s, %s or %s", u.Provider, UnlockProviderAgent,
+			UnlockProviderPlugin, UnlockProviderSealed)
+	}
+
+	if u.Timeout <= 0 {
+		return fmt.Errorf("unlock.timeout must be positive")
+	}
+
+	if u.Retries < 0 {
+		return fmt.Errorf("unlock.retries cannot be negative")
+	}
+
+	if u.RetryInterval <= 0 {
+		return fmt.Errorf("unlock.retryinterval must be positive")
+	}
+
+	return nil
+}
+
+// DefaultUnlock returns the default configuration of the unlock provider.
+func DefaultUnlock() *Unlock {
+	return &Unlock{
+		KeyID:         DefaultUnlockKeyID,
+		Timeout:       DefaultUnlockTimeout,
+		Retries:       DefaultUnlockRetries,
+		RetryInterval: DefaultUnlockRetryInterval,
+	}
+}
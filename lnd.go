@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
 	runtimePprof "runtime/pprof"
 	"strings"
@@ -29,6 +30,7 @@ import (
 	"github.com/lightningnetwork/lnd/lncfg"
 	"github.com/lightningnetwork/lnd/lnrpc"
 	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/rpcwallet"
 	"github.com/lightningnetwork/lnd/macaroons"
 	"github.com/lightningnetwork/lnd/monitoring"
 	"github.com/lightningnetwork/lnd/rpcperms"
@@ -256,6 +258,24 @@ func Main(cfg *Config, lisCfg ListenerCfg, implCfg *ImplementationCfg,
 		return mkErr("error initializing DBs: %v", err)
 	}
 
+	// If requested, we copy the content of the bbolt databases into the
+	// configured SQL backend and exit. This must happen before any of the
+	// databases are opened.
+	if cfg.DB.MigrateFromBolt {
+		ltndLog.Infof("Migrating bbolt databases to %v backend",
+			cfg.DB.Backend)
+
+		if err := migrateBoltDBs(ctx, cfg); err != nil {
+			return mkErr("error migrating bbolt databases: %v", err)
+		}
+
+		ltndLog.Infof("Migration of bbolt databases complete, " +
+			"restart lnd without db.migrate-from-bolt to use the " +
+			"migrated data")
+
+		return nil
+	}
+
 	tlsManagerCfg := &TLSManagerCfg{
 		TLSCertPath:        cfg.TLSCertPath,
 		TLSKeyPath:         cfg.TLSKeyPath,
@@ -325,6 +345,47 @@ func Main(cfg *Config, lisCfg ListenerCfg, implCfg *ImplementationCfg,
 		}
 	}()
 
+	// If enabled, all calls of mutating RPCs are recorded in the audit
+	// log, which lives next to the regular log file.
+	if cfg.AuditLog.Enable {
+		auditLog, err := rpcperms.NewAuditLog(&rpcperms.AuditLogConfig{
+			Path: filepath.Join(
+				cfg.LogDir, defaultAuditLogFilename,
+			),
+			MaxFileSize: cfg.AuditLog.MaxFileSize,
+			MaxFiles:    cfg.AuditLog.MaxFiles,
+		})
+		if err != nil {
+			return mkErr("error creating audit log: %v", err)
+		}
+		if err := auditLog.Start(); err != nil {
+			return mkErr("error starting audit log: %v", err)
+		}
+		defer func() {
+			if err := auditLog.Stop(); err != nil {
+				ltndLog.Warnf("error stopping audit log: %v",
+					err)
+			}
+		}()
+
+		interceptorChain.SetAuditLog(auditLog)
+	}
+
+	// Enforce the configured rate limits and stream quotas of RPC calls.
+	// The limiter is installed even if none are configured, as the rate
+	// limit and max streams caveats of macaroons must always be enforced.
+	methodLimits, err := cfg.RPCRateLimit.ParseMethodLimits()
+	if err != nil {
+		return mkErr("error parsing rpc rate limits: %v", err)
+	}
+	interceptorChain.SetRateLimiter(rpcperms.NewRateLimiter(
+		&rpcperms.RateLimiterConfig{
+			DefaultLimit: cfg.RPCRateLimit.DefaultLimit(),
+			MethodLimits: methodLimits,
+			MaxStreams:   cfg.RPCRateLimit.MaxStreams,
+		},
+	))
+
 	// Allow the user to overwrite some defaults of the gRPC library related
 	// to connection keepalive (server side and client side pings).
 	serverKeepalive := keepalive.ServerParameters{
@@ -684,6 +745,15 @@ func Main(cfg *Config, lisCfg ListenerCfg, implCfg *ImplementationCfg,
 	// We transition the server state to Active, as the server is up.
 	interceptorChain.SetServerActive()
 
+	// If we're delegating signing to remote signers, the state reflects
+	// whether any of them can be reached.
+	rpcKeyRing, ok := activeChainControl.Wc.(*rpcwallet.RPCKeyRing)
+	if ok {
+		rpcKeyRing.SetAvailabilityNotifier(
+			interceptorChain.SetSignerAvailable,
+		)
+	}
+
 	// Now that the server has started, if the autopilot mode is currently
 	// active, then we'll start the autopilot agent immediately. It will be
 	// stopped together with the autopilot service.
@@ -892,7 +962,7 @@ func startRestProxy(cfg *Config, rpcServer *rpcServer, restDialOpts []grpc.DialO
 			UnmarshalOptions: *lnrpc.RESTJsonUnmarshalOpts,
 		},
 	)
-	mux := proxy.NewServeMux(
+	muxOpts := []proxy.ServeMuxOption{
 		customMarshalerOption,
 
 		// Don't allow falling back to other HTTP methods, we want exact
@@ -901,7 +971,16 @@ func startRestProxy(cfg *Config, rpcServer *rpcServer, restDialOpts []grpc.DialO
 		// reason for not specifying the correct method in the first
 		// place.
 		proxy.WithDisablePathLengthFallback(),
-	)
+	}
+
+	// The audit log only trusts the address of REST clients forwarded by
+	// our own proxy, which identifies itself with the metadata it adds.
+	if auditLog := rpcServer.interceptorChain.AuditLog(); auditLog != nil {
+		muxOpts = append(
+			muxOpts, proxy.WithMetadata(auditLog.RestProxyMetadata),
+		)
+	}
+	mux := proxy.NewServeMux(muxOpts...)
 
 	// Register our services with the REST proxy.
 	err := rpcServer.RegisterWithRestProxy(
@@ -347,6 +347,11 @@ func (s *Server) AddHoldInvoice(ctx context.Context,
 		return nil, err
 	}
 
+	// Make sure the macaroon of the request allows the invoice amount.
+	if err := s.cfg.MacService.AuthorizeInvoice(ctx, value); err != nil {
+		return nil, err
+	}
+
 	// Convert the passed routing hints to the required format.
 	routeHints, err := CreateZpay32HopHints(invoice.RouteHints)
 	if err != nil {
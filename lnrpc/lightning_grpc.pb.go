@@ -32,6 +32,11 @@ type LightningClient interface {
 	// GetTransactions returns a list describing all the known transactions
 	// relevant to the wallet.
 	GetTransactions(ctx context.Context, in *GetTransactionsRequest, opts ...grpc.CallOption) (*TransactionDetails, error)
+	// lncli: `accountingreport`
+	// ListAccountingEntries returns every change to the balance of the node in
+	// the given time range, on chain and off chain, classified by what caused
+	// it.
+	ListAccountingEntries(ctx context.Context, in *ListAccountingEntriesRequest, opts ...grpc.CallOption) (*ListAccountingEntriesResponse, error)
 	// lncli: `estimatefee`
 	// EstimateFee asks the chain backend to estimate the fee rate and total fees
 	// for a transaction that pays to multiple specified outputs.
@@ -112,12 +117,36 @@ type LightningClient interface {
 	// whether it's in a recovery mode, whether the recovery is finished, and the
 	// progress made so far.
 	GetRecoveryInfo(ctx context.Context, in *GetRecoveryInfoRequest, opts ...grpc.CallOption) (*GetRecoveryInfoResponse, error)
+	// lncli: `getdbstats`
+	// GetDatabaseStats returns the size of the database files and the amount of
+	// data stored in the main buckets of the channel database, such as the
+	// channel state, the revocation logs, the payments, the channel graph and the
+	// forwarding log.
+	GetDatabaseStats(ctx context.Context, in *GetDatabaseStatsRequest, opts ...grpc.CallOption) (*GetDatabaseStatsResponse, error)
+	// lncli: `compactdb`
+	// CompactDatabase compacts the bolt database files to reclaim the space of
+	// deleted data. By default, the compaction is scheduled for the next restart
+	// of lnd. If online is set, the channel database is compacted right away,
+	// which requires lnd to be started with db.online-compaction.
+	CompactDatabase(ctx context.Context, in *CompactDatabaseRequest, opts ...grpc.CallOption) (*CompactDatabaseResponse, error)
 	// lncli: `pendingchannels`
 	// PendingChannels returns a list of all the channels that are currently
 	// considered "pending". A channel is pending if it has finished the funding
 	// workflow and is waiting for confirmations for the funding txn, or is in the
 	// process of closure, either initiated cooperatively or non-cooperatively.
 	PendingChannels(ctx context.Context, in *PendingChannelsRequest, opts ...grpc.CallOption) (*PendingChannelsResponse, error)
+	// lncli: `listresolvers`
+	// ListContractResolvers returns the contract resolvers that are active on the
+	// channels pending force closing. For each resolver it reports its
+	// checkpointed state, the outpoint it is watching, the height at which it
+	// can take its next step and the last input it offered to the sweeper.
+	ListContractResolvers(ctx context.Context, in *ListContractResolversRequest, opts ...grpc.CallOption) (*ListContractResolversResponse, error)
+	// lncli: `reevaluateresolver`
+	// ReevaluateContractResolver stops an active contract resolver, reloads it
+	// from its last checkpoint and launches it again, making it re-evaluate its
+	// contract. This can be used to kick a resolver that appears to be stuck,
+	// for example to offer its input to the sweeper again.
+	ReevaluateContractResolver(ctx context.Context, in *ReevaluateContractResolverRequest, opts ...grpc.CallOption) (*ReevaluateContractResolverResponse, error)
 	// lncli: `listchannels`
 	// ListChannels returns a description of all the open channels that this node
 	// is a participant in.
@@ -131,6 +160,26 @@ type LightningClient interface {
 	// ClosedChannels returns a description of all the closed channels that
 	// this node was a participant in.
 	ClosedChannels(ctx context.Context, in *ClosedChannelsRequest, opts ...grpc.CallOption) (*ClosedChannelsResponse, error)
+	// lncli: `exportchanarchive`
+	// ExportChannelArchive returns the final state of the closed channels that
+	// was moved to the channel archive, including their last commitments and
+	// HTLCs. Channels are only archived if chanarchive.mode is set to archive.
+	ExportChannelArchive(ctx context.Context, in *ExportChannelArchiveRequest, opts ...grpc.CallOption) (*ExportChannelArchiveResponse, error)
+	// lncli: `closehistory`
+	// CloseHistory returns the full financial outcome of channels that were
+	// closed on chain. Each record accounts for the close fee, the fees paid to
+	// sweep anchors and htlcs, time locked amounts and when they were recovered,
+	// and the total gain or loss compared to the last local balance. Records are
+	// built up as contracts are resolved.
+	CloseHistory(ctx context.Context, in *CloseHistoryRequest, opts ...grpc.CallOption) (*CloseHistoryResponse, error)
+	// SubscribeBreachEvents creates a uni-directional stream from the server to
+	// the client in which the progress of the justice spends for channels whose
+	// revoked commitment was broadcast by the remote party is sent over. Events
+	// are sent when a breach is detected and confirmed, when the fee preference
+	// of the justice spends changes as the deadline approaches, when an HTLC is
+	// taken to the second level, when outputs are swept or lost, and once the
+	// funds are secured.
+	SubscribeBreachEvents(ctx context.Context, in *BreachEventSubscription, opts ...grpc.CallOption) (Lightning_SubscribeBreachEventsClient, error)
 	// OpenChannelSync is a synchronous version of the OpenChannel RPC call. This
 	// call is meant to be consumed by clients to the REST proxy. As with all
 	// other sync calls, all byte slices are intended to be populated as hex
@@ -185,6 +234,13 @@ type LightningClient interface {
 	// never broadcast. Only available for non-externally funded channels in dev
 	// build.
 	AbandonChannel(ctx context.Context, in *AbandonChannelRequest, opts ...grpc.CallOption) (*AbandonChannelResponse, error)
+	// lncli: `simulateforceclose`
+	// SimulateForceClose simulates a force close of an open channel at its
+	// current state without broadcasting or signing anything. It reports the
+	// weights of the commitment transaction and the HTLC transactions and the fees
+	// needed to confirm and sweep them at a given fee rate, along with the amount
+	// that would be recovered.
+	SimulateForceClose(ctx context.Context, in *SimulateForceCloseRequest, opts ...grpc.CallOption) (*SimulateForceCloseResponse, error)
 	// Deprecated: Do not use.
 	// lncli: `sendpayment`
 	// Deprecated, use routerrpc.SendPaymentV2. SendPayment dispatches a
@@ -363,6 +419,12 @@ type LightningClient interface {
 	// ups, but the updated set of encrypted multi-chan backups with the closed
 	// channel(s) removed.
 	SubscribeChannelBackups(ctx context.Context, in *ChannelBackupSubscription, opts ...grpc.CallOption) (Lightning_SubscribeChannelBackupsClient, error)
+	// lncli: `listchanbackupversions`
+	// ListChannelBackupVersions returns the versions of the multi-channel backup
+	// that are kept by the configured backup sinks that store a history of
+	// backups, such as a local backup directory or an S3 bucket. The versions are
+	// sorted newest first.
+	ListChannelBackupVersions(ctx context.Context, in *ListChannelBackupVersionsRequest, opts ...grpc.CallOption) (*ListChannelBackupVersionsResponse, error)
 	// lncli: `bakemacaroon`
 	// BakeMacaroon allows the creation of a new macaroon with custom read and
 	// write permissions. No first-party caveats are added since this can be done
@@ -379,6 +441,15 @@ type LightningClient interface {
 	// ListPermissions lists all RPC method URIs and their required macaroon
 	// permissions to access them.
 	ListPermissions(ctx context.Context, in *ListPermissionsRequest, opts ...grpc.CallOption) (*ListPermissionsResponse, error)
+	// lncli: `auditlog list`
+	// ListAuditLog returns the entries of the RPC audit log, which records every
+	// call of a mutating RPC together with the identity of its macaroon. The
+	// audit log must be enabled with the auditlog.enable option.
+	ListAuditLog(ctx context.Context, in *ListAuditLogRequest, opts ...grpc.CallOption) (*ListAuditLogResponse, error)
+	// lncli: `auditlog subscribe`
+	// SubscribeAuditLog streams every new entry of the RPC audit log, which can
+	// be used to feed it into external log collectors.
+	SubscribeAuditLog(ctx context.Context, in *SubscribeAuditLogRequest, opts ...grpc.CallOption) (Lightning_SubscribeAuditLogClient, error)
 	// CheckMacaroonPermissions checks whether a request follows the constraints
 	// imposed on the macaroon and that the macaroon is authorized to follow the
 	// provided permissions.
@@ -453,6 +524,15 @@ func (c *lightningClient) GetTransactions(ctx context.Context, in *GetTransactio
 	return out, nil
 }
 
+func (c *lightningClient) ListAccountingEntries(ctx context.Context, in *ListAccountingEntriesRequest, opts ...grpc.CallOption) (*ListAccountingEntriesResponse, error) {
+	out := new(ListAccountingEntriesResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ListAccountingEntries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *lightningClient) EstimateFee(ctx context.Context, in *EstimateFeeRequest, opts ...grpc.CallOption) (*EstimateFeeResponse, error) {
 	out := new(EstimateFeeResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/EstimateFee", in, out, opts...)
@@ -634,6 +714,24 @@ func (c *lightningClient) GetRecoveryInfo(ctx context.Context, in *GetRecoveryIn
 	return out, nil
 }
 
+func (c *lightningClient) GetDatabaseStats(ctx context.Context, in *GetDatabaseStatsRequest, opts ...grpc.CallOption) (*GetDatabaseStatsResponse, error) {
+	out := new(GetDatabaseStatsResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/GetDatabaseStats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lightningClient) CompactDatabase(ctx context.Context, in *CompactDatabaseRequest, opts ...grpc.CallOption) (*CompactDatabaseResponse, error) {
+	out := new(CompactDatabaseResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/CompactDatabase", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *lightningClient) PendingChannels(ctx context.Context, in *PendingChannelsRequest, opts ...grpc.CallOption) (*PendingChannelsResponse, error) {
 	out := new(PendingChannelsResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/PendingChannels", in, out, opts...)
@@ -643,6 +741,24 @@ func (c *lightningClient) PendingChannels(ctx context.Context, in *PendingChanne
 	return out, nil
 }
 
+func (c *lightningClient) ListContractResolvers(ctx context.Context, in *ListContractResolversRequest, opts ...grpc.CallOption) (*ListContractResolversResponse, error) {
+	out := new(ListContractResolversResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ListContractResolvers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lightningClient) ReevaluateContractResolver(ctx context.Context, in *ReevaluateContractResolverRequest, opts ...grpc.CallOption) (*ReevaluateContractResolverResponse, error) {
+	out := new(ReevaluateContractResolverResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ReevaluateContractResolver", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *lightningClient) ListChannels(ctx context.Context, in *ListChannelsRequest, opts ...grpc.CallOption) (*ListChannelsResponse, error) {
 	out := new(ListChannelsResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ListChannels", in, out, opts...)
@@ -693,6 +809,56 @@ func (c *lightningClient) ClosedChannels(ctx context.Context, in *ClosedChannels
 	return out, nil
 }
 
+func (c *lightningClient) ExportChannelArchive(ctx context.Context, in *ExportChannelArchiveRequest, opts ...grpc.CallOption) (*ExportChannelArchiveResponse, error) {
+	out := new(ExportChannelArchiveResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ExportChannelArchive", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lightningClient) CloseHistory(ctx context.Context, in *CloseHistoryRequest, opts ...grpc.CallOption) (*CloseHistoryResponse, error) {
+	out := new(CloseHistoryResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/CloseHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lightningClient) SubscribeBreachEvents(ctx context.Context, in *BreachEventSubscription, opts ...grpc.CallOption) (Lightning_SubscribeBreachEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[3], "/lnrpc.Lightning/SubscribeBreachEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lightningSubscribeBreachEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Lightning_SubscribeBreachEventsClient interface {
+	Recv() (*BreachEvent, error)
+	grpc.ClientStream
+}
+
+type lightningSubscribeBreachEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *lightningSubscribeBreachEventsClient) Recv() (*BreachEvent, error) {
+	m := new(BreachEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *lightningClient) OpenChannelSync(ctx context.Context, in *OpenChannelRequest, opts ...grpc.CallOption) (*ChannelPoint, error) {
 	out := new(ChannelPoint)
 	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/OpenChannelSync", in, out, opts...)
@@ -703,7 +869,7 @@ func (c *lightningClient) OpenChannelSync(ctx context.Context, in *OpenChannelRe
 }
 
 func (c *lightningClient) OpenChannel(ctx context.Context, in *OpenChannelRequest, opts ...grpc.CallOption) (Lightning_OpenChannelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[3], "/lnrpc.Lightning/OpenChannel", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[4], "/lnrpc.Lightning/OpenChannel", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -753,7 +919,7 @@ func (c *lightningClient) FundingStateStep(ctx context.Context, in *FundingTrans
 }
 
 func (c *lightningClient) ChannelAcceptor(ctx context.Context, opts ...grpc.CallOption) (Lightning_ChannelAcceptorClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[4], "/lnrpc.Lightning/ChannelAcceptor", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[5], "/lnrpc.Lightning/ChannelAcceptor", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -784,7 +950,7 @@ func (x *lightningChannelAcceptorClient) Recv() (*ChannelAcceptRequest, error) {
 }
 
 func (c *lightningClient) CloseChannel(ctx context.Context, in *CloseChannelRequest, opts ...grpc.CallOption) (Lightning_CloseChannelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[5], "/lnrpc.Lightning/CloseChannel", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[6], "/lnrpc.Lightning/CloseChannel", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -824,9 +990,18 @@ func (c *lightningClient) AbandonChannel(ctx context.Context, in *AbandonChannel
 	return out, nil
 }
 
+func (c *lightningClient) SimulateForceClose(ctx context.Context, in *SimulateForceCloseRequest, opts ...grpc.CallOption) (*SimulateForceCloseResponse, error) {
+	out := new(SimulateForceCloseResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/SimulateForceClose", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Deprecated: Do not use.
 func (c *lightningClient) SendPayment(ctx context.Context, opts ...grpc.CallOption) (Lightning_SendPaymentClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[6], "/lnrpc.Lightning/SendPayment", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[7], "/lnrpc.Lightning/SendPayment", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -867,7 +1042,7 @@ func (c *lightningClient) SendPaymentSync(ctx context.Context, in *SendRequest,
 
 // Deprecated: Do not use.
 func (c *lightningClient) SendToRoute(ctx context.Context, opts ...grpc.CallOption) (Lightning_SendToRouteClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[7], "/lnrpc.Lightning/SendToRoute", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[8], "/lnrpc.Lightning/SendToRoute", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -934,7 +1109,7 @@ func (c *lightningClient) LookupInvoice(ctx context.Context, in *PaymentHash, op
 }
 
 func (c *lightningClient) SubscribeInvoices(ctx context.Context, in *InvoiceSubscription, opts ...grpc.CallOption) (Lightning_SubscribeInvoicesClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[8], "/lnrpc.Lightning/SubscribeInvoices", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[9], "/lnrpc.Lightning/SubscribeInvoices", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1065,7 +1240,7 @@ func (c *lightningClient) StopDaemon(ctx context.Context, in *StopRequest, opts
 }
 
 func (c *lightningClient) SubscribeChannelGraph(ctx context.Context, in *GraphTopologySubscription, opts ...grpc.CallOption) (Lightning_SubscribeChannelGraphClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[9], "/lnrpc.Lightning/SubscribeChannelGraph", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[10], "/lnrpc.Lightning/SubscribeChannelGraph", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1169,7 +1344,7 @@ func (c *lightningClient) RestoreChannelBackups(ctx context.Context, in *Restore
 }
 
 func (c *lightningClient) SubscribeChannelBackups(ctx context.Context, in *ChannelBackupSubscription, opts ...grpc.CallOption) (Lightning_SubscribeChannelBackupsClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[10], "/lnrpc.Lightning/SubscribeChannelBackups", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[11], "/lnrpc.Lightning/SubscribeChannelBackups", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1200,6 +1375,15 @@ func (x *lightningSubscribeChannelBackupsClient) Recv() (*ChanBackupSnapshot, er
 	return m, nil
 }
 
+func (c *lightningClient) ListChannelBackupVersions(ctx context.Context, in *ListChannelBackupVersionsRequest, opts ...grpc.CallOption) (*ListChannelBackupVersionsResponse, error) {
+	out := new(ListChannelBackupVersionsResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ListChannelBackupVersions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *lightningClient) BakeMacaroon(ctx context.Context, in *BakeMacaroonRequest, opts ...grpc.CallOption) (*BakeMacaroonResponse, error) {
 	out := new(BakeMacaroonResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/BakeMacaroon", in, out, opts...)
@@ -1236,6 +1420,47 @@ func (c *lightningClient) ListPermissions(ctx context.Context, in *ListPermissio
 	return out, nil
 }
 
+func (c *lightningClient) ListAuditLog(ctx context.Context, in *ListAuditLogRequest, opts ...grpc.CallOption) (*ListAuditLogResponse, error) {
+	out := new(ListAuditLogResponse)
+	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/ListAuditLog", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *lightningClient) SubscribeAuditLog(ctx context.Context, in *SubscribeAuditLogRequest, opts ...grpc.CallOption) (Lightning_SubscribeAuditLogClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[12], "/lnrpc.Lightning/SubscribeAuditLog", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &lightningSubscribeAuditLogClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Lightning_SubscribeAuditLogClient interface {
+	Recv() (*AuditLogEntry, error)
+	grpc.ClientStream
+}
+
+type lightningSubscribeAuditLogClient struct {
+	grpc.ClientStream
+}
+
+func (x *lightningSubscribeAuditLogClient) Recv() (*AuditLogEntry, error) {
+	m := new(AuditLogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *lightningClient) CheckMacaroonPermissions(ctx context.Context, in *CheckMacPermRequest, opts ...grpc.CallOption) (*CheckMacPermResponse, error) {
 	out := new(CheckMacPermResponse)
 	err := c.cc.Invoke(ctx, "/lnrpc.Lightning/CheckMacaroonPermissions", in, out, opts...)
@@ -1246,7 +1471,7 @@ func (c *lightningClient) CheckMacaroonPermissions(ctx context.Context, in *Chec
 }
 
 func (c *lightningClient) RegisterRPCMiddleware(ctx context.Context, opts ...grpc.CallOption) (Lightning_RegisterRPCMiddlewareClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[11], "/lnrpc.Lightning/RegisterRPCMiddleware", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[13], "/lnrpc.Lightning/RegisterRPCMiddleware", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1286,7 +1511,7 @@ func (c *lightningClient) SendCustomMessage(ctx context.Context, in *SendCustomM
 }
 
 func (c *lightningClient) SubscribeCustomMessages(ctx context.Context, in *SubscribeCustomMessagesRequest, opts ...grpc.CallOption) (Lightning_SubscribeCustomMessagesClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[12], "/lnrpc.Lightning/SubscribeCustomMessages", opts...)
+	stream, err := c.cc.NewStream(ctx, &Lightning_ServiceDesc.Streams[14], "/lnrpc.Lightning/SubscribeCustomMessages", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -1353,6 +1578,11 @@ type LightningServer interface {
 	// GetTransactions returns a list describing all the known transactions
 	// relevant to the wallet.
 	GetTransactions(context.Context, *GetTransactionsRequest) (*TransactionDetails, error)
+	// lncli: `accountingreport`
+	// ListAccountingEntries returns every change to the balance of the node in
+	// the given time range, on chain and off chain, classified by what caused
+	// it.
+	ListAccountingEntries(context.Context, *ListAccountingEntriesRequest) (*ListAccountingEntriesResponse, error)
 	// lncli: `estimatefee`
 	// EstimateFee asks the chain backend to estimate the fee rate and total fees
 	// for a transaction that pays to multiple specified outputs.
@@ -1433,12 +1663,36 @@ type LightningServer interface {
 	// whether it's in a recovery mode, whether the recovery is finished, and the
 	// progress made so far.
 	GetRecoveryInfo(context.Context, *GetRecoveryInfoRequest) (*GetRecoveryInfoResponse, error)
+	// lncli: `getdbstats`
+	// GetDatabaseStats returns the size of the database files and the amount of
+	// data stored in the main buckets of the channel database, such as the
+	// channel state, the revocation logs, the payments, the channel graph and the
+	// forwarding log.
+	GetDatabaseStats(context.Context, *GetDatabaseStatsRequest) (*GetDatabaseStatsResponse, error)
+	// lncli: `compactdb`
+	// CompactDatabase compacts the bolt database files to reclaim the space of
+	// deleted data. By default, the compaction is scheduled for the next restart
+	// of lnd. If online is set, the channel database is compacted right away,
+	// which requires lnd to be started with db.online-compaction.
+	CompactDatabase(context.Context, *CompactDatabaseRequest) (*CompactDatabaseResponse, error)
 	// lncli: `pendingchannels`
 	// PendingChannels returns a list of all the channels that are currently
 	// considered "pending". A channel is pending if it has finished the funding
 	// workflow and is waiting for confirmations for the funding txn, or is in the
 	// process of closure, either initiated cooperatively or non-cooperatively.
 	PendingChannels(context.Context, *PendingChannelsRequest) (*PendingChannelsResponse, error)
+	// lncli: `listresolvers`
+	// ListContractResolvers returns the contract resolvers that are active on the
+	// channels pending force closing. For each resolver it reports its
+	// checkpointed state, the outpoint it is watching, the height at which it
+	// can take its next step and the last input it offered to the sweeper.
+	ListContractResolvers(context.Context, *ListContractResolversRequest) (*ListContractResolversResponse, error)
+	// lncli: `reevaluateresolver`
+	// ReevaluateContractResolver stops an active contract resolver, reloads it
+	// from its last checkpoint and launches it again, making it re-evaluate its
+	// contract. This can be used to kick a resolver that appears to be stuck,
+	// for example to offer its input to the sweeper again.
+	ReevaluateContractResolver(context.Context, *ReevaluateContractResolverRequest) (*ReevaluateContractResolverResponse, error)
 	// lncli: `listchannels`
 	// ListChannels returns a description of all the open channels that this node
 	// is a participant in.
@@ -1452,6 +1706,26 @@ type LightningServer interface {
 	// ClosedChannels returns a description of all the closed channels that
 	// this node was a participant in.
 	ClosedChannels(context.Context, *ClosedChannelsRequest) (*ClosedChannelsResponse, error)
+	// lncli: `exportchanarchive`
+	// ExportChannelArchive returns the final state of the closed channels that
+	// was moved to the channel archive, including their last commitments and
+	// HTLCs. Channels are only archived if chanarchive.mode is set to archive.
+	ExportChannelArchive(context.Context, *ExportChannelArchiveRequest) (*ExportChannelArchiveResponse, error)
+	// lncli: `closehistory`
+	// CloseHistory returns the full financial outcome of channels that were
+	// closed on chain. Each record accounts for the close fee, the fees paid to
+	// sweep anchors and htlcs, time locked amounts and when they were recovered,
+	// and the total gain or loss compared to the last local balance. Records are
+	// built up as contracts are resolved.
+	CloseHistory(context.Context, *CloseHistoryRequest) (*CloseHistoryResponse, error)
+	// SubscribeBreachEvents creates a uni-directional stream from the server to
+	// the client in which the progress of the justice spends for channels whose
+	// revoked commitment was broadcast by the remote party is sent over. Events
+	// are sent when a breach is detected and confirmed, when the fee preference
+	// of the justice spends changes as the deadline approaches, when an HTLC is
+	// taken to the second level, when outputs are swept or lost, and once the
+	// funds are secured.
+	SubscribeBreachEvents(*BreachEventSubscription, Lightning_SubscribeBreachEventsServer) error
 	// OpenChannelSync is a synchronous version of the OpenChannel RPC call. This
 	// call is meant to be consumed by clients to the REST proxy. As with all
 	// other sync calls, all byte slices are intended to be populated as hex
@@ -1506,6 +1780,13 @@ type LightningServer interface {
 	// never broadcast. Only available for non-externally funded channels in dev
 	// build.
 	AbandonChannel(context.Context, *AbandonChannelRequest) (*AbandonChannelResponse, error)
+	// lncli: `simulateforceclose`
+	// SimulateForceClose simulates a force close of an open channel at its
+	// current state without broadcasting or signing anything. It reports the
+	// weights of the commitment transaction and the HTLC transactions and the fees
+	// needed to confirm and sweep them at a given fee rate, along with the amount
+	// that would be recovered.
+	SimulateForceClose(context.Context, *SimulateForceCloseRequest) (*SimulateForceCloseResponse, error)
 	// Deprecated: Do not use.
 	// lncli: `sendpayment`
 	// Deprecated, use routerrpc.SendPaymentV2. SendPayment dispatches a
@@ -1684,6 +1965,12 @@ type LightningServer interface {
 	// ups, but the updated set of encrypted multi-chan backups with the closed
 	// channel(s) removed.
 	SubscribeChannelBackups(*ChannelBackupSubscription, Lightning_SubscribeChannelBackupsServer) error
+	// lncli: `listchanbackupversions`
+	// ListChannelBackupVersions returns the versions of the multi-channel backup
+	// that are kept by the configured backup sinks that store a history of
+	// backups, such as a local backup directory or an S3 bucket. The versions are
+	// sorted newest first.
+	ListChannelBackupVersions(context.Context, *ListChannelBackupVersionsRequest) (*ListChannelBackupVersionsResponse, error)
 	// lncli: `bakemacaroon`
 	// BakeMacaroon allows the creation of a new macaroon with custom read and
 	// write permissions. No first-party caveats are added since this can be done
@@ -1700,6 +1987,15 @@ type LightningServer interface {
 	// ListPermissions lists all RPC method URIs and their required macaroon
 	// permissions to access them.
 	ListPermissions(context.Context, *ListPermissionsRequest) (*ListPermissionsResponse, error)
+	// lncli: `auditlog list`
+	// ListAuditLog returns the entries of the RPC audit log, which records every
+	// call of a mutating RPC together with the identity of its macaroon. The
+	// audit log must be enabled with the auditlog.enable option.
+	ListAuditLog(context.Context, *ListAuditLogRequest) (*ListAuditLogResponse, error)
+	// lncli: `auditlog subscribe`
+	// SubscribeAuditLog streams every new entry of the RPC audit log, which can
+	// be used to feed it into external log collectors.
+	SubscribeAuditLog(*SubscribeAuditLogRequest, Lightning_SubscribeAuditLogServer) error
 	// CheckMacaroonPermissions checks whether a request follows the constraints
 	// imposed on the macaroon and that the macaroon is authorized to follow the
 	// provided permissions.
@@ -1753,6 +2049,9 @@ func (UnimplementedLightningServer) ChannelBalance(context.Context, *ChannelBala
 func (UnimplementedLightningServer) GetTransactions(context.Context, *GetTransactionsRequest) (*TransactionDetails, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTransactions not implemented")
 }
+func (UnimplementedLightningServer) ListAccountingEntries(context.Context, *ListAccountingEntriesRequest) (*ListAccountingEntriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAccountingEntries not implemented")
+}
 func (UnimplementedLightningServer) EstimateFee(context.Context, *EstimateFeeRequest) (*EstimateFeeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method EstimateFee not implemented")
 }
@@ -1798,9 +2097,21 @@ func (UnimplementedLightningServer) GetDebugInfo(context.Context, *GetDebugInfoR
 func (UnimplementedLightningServer) GetRecoveryInfo(context.Context, *GetRecoveryInfoRequest) (*GetRecoveryInfoResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetRecoveryInfo not implemented")
 }
+func (UnimplementedLightningServer) GetDatabaseStats(context.Context, *GetDatabaseStatsRequest) (*GetDatabaseStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDatabaseStats not implemented")
+}
+func (UnimplementedLightningServer) CompactDatabase(context.Context, *CompactDatabaseRequest) (*CompactDatabaseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompactDatabase not implemented")
+}
 func (UnimplementedLightningServer) PendingChannels(context.Context, *PendingChannelsRequest) (*PendingChannelsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PendingChannels not implemented")
 }
+func (UnimplementedLightningServer) ListContractResolvers(context.Context, *ListContractResolversRequest) (*ListContractResolversResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListContractResolvers not implemented")
+}
+func (UnimplementedLightningServer) ReevaluateContractResolver(context.Context, *ReevaluateContractResolverRequest) (*ReevaluateContractResolverResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReevaluateContractResolver not implemented")
+}
 func (UnimplementedLightningServer) ListChannels(context.Context, *ListChannelsRequest) (*ListChannelsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListChannels not implemented")
 }
@@ -1810,6 +2121,15 @@ func (UnimplementedLightningServer) SubscribeChannelEvents(*ChannelEventSubscrip
 func (UnimplementedLightningServer) ClosedChannels(context.Context, *ClosedChannelsRequest) (*ClosedChannelsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ClosedChannels not implemented")
 }
+func (UnimplementedLightningServer) ExportChannelArchive(context.Context, *ExportChannelArchiveRequest) (*ExportChannelArchiveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportChannelArchive not implemented")
+}
+func (UnimplementedLightningServer) CloseHistory(context.Context, *CloseHistoryRequest) (*CloseHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CloseHistory not implemented")
+}
+func (UnimplementedLightningServer) SubscribeBreachEvents(*BreachEventSubscription, Lightning_SubscribeBreachEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBreachEvents not implemented")
+}
 func (UnimplementedLightningServer) OpenChannelSync(context.Context, *OpenChannelRequest) (*ChannelPoint, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method OpenChannelSync not implemented")
 }
@@ -1831,6 +2151,9 @@ func (UnimplementedLightningServer) CloseChannel(*CloseChannelRequest, Lightning
 func (UnimplementedLightningServer) AbandonChannel(context.Context, *AbandonChannelRequest) (*AbandonChannelResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method AbandonChannel not implemented")
 }
+func (UnimplementedLightningServer) SimulateForceClose(context.Context, *SimulateForceCloseRequest) (*SimulateForceCloseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SimulateForceClose not implemented")
+}
 func (UnimplementedLightningServer) SendPayment(Lightning_SendPaymentServer) error {
 	return status.Errorf(codes.Unimplemented, "method SendPayment not implemented")
 }
@@ -1918,6 +2241,9 @@ func (UnimplementedLightningServer) RestoreChannelBackups(context.Context, *Rest
 func (UnimplementedLightningServer) SubscribeChannelBackups(*ChannelBackupSubscription, Lightning_SubscribeChannelBackupsServer) error {
 	return status.Errorf(codes.Unimplemented, "method SubscribeChannelBackups not implemented")
 }
+func (UnimplementedLightningServer) ListChannelBackupVersions(context.Context, *ListChannelBackupVersionsRequest) (*ListChannelBackupVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListChannelBackupVersions not implemented")
+}
 func (UnimplementedLightningServer) BakeMacaroon(context.Context, *BakeMacaroonRequest) (*BakeMacaroonResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method BakeMacaroon not implemented")
 }
@@ -1930,6 +2256,12 @@ func (UnimplementedLightningServer) DeleteMacaroonID(context.Context, *DeleteMac
 func (UnimplementedLightningServer) ListPermissions(context.Context, *ListPermissionsRequest) (*ListPermissionsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListPermissions not implemented")
 }
+func (UnimplementedLightningServer) ListAuditLog(context.Context, *ListAuditLogRequest) (*ListAuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditLog not implemented")
+}
+func (UnimplementedLightningServer) SubscribeAuditLog(*SubscribeAuditLogRequest, Lightning_SubscribeAuditLogServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeAuditLog not implemented")
+}
 func (UnimplementedLightningServer) CheckMacaroonPermissions(context.Context, *CheckMacPermRequest) (*CheckMacPermResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CheckMacaroonPermissions not implemented")
 }
@@ -2015,6 +2347,24 @@ func _Lightning_GetTransactions_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_ListAccountingEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAccountingEntriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ListAccountingEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ListAccountingEntries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ListAccountingEntries(ctx, req.(*ListAccountingEntriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Lightning_EstimateFee_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(EstimateFeeRequest)
 	if err := dec(in); err != nil {
@@ -2291,6 +2641,42 @@ func _Lightning_GetRecoveryInfo_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_GetDatabaseStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDatabaseStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).GetDatabaseStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/GetDatabaseStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).GetDatabaseStats(ctx, req.(*GetDatabaseStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lightning_CompactDatabase_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompactDatabaseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).CompactDatabase(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/CompactDatabase",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).CompactDatabase(ctx, req.(*CompactDatabaseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Lightning_PendingChannels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PendingChannelsRequest)
 	if err := dec(in); err != nil {
@@ -2309,6 +2695,42 @@ func _Lightning_PendingChannels_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_ListContractResolvers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListContractResolversRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ListContractResolvers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ListContractResolvers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ListContractResolvers(ctx, req.(*ListContractResolversRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lightning_ReevaluateContractResolver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReevaluateContractResolverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ReevaluateContractResolver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ReevaluateContractResolver",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ReevaluateContractResolver(ctx, req.(*ReevaluateContractResolverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Lightning_ListChannels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListChannelsRequest)
 	if err := dec(in); err != nil {
@@ -2366,6 +2788,63 @@ func _Lightning_ClosedChannels_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_ExportChannelArchive_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportChannelArchiveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ExportChannelArchive(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ExportChannelArchive",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ExportChannelArchive(ctx, req.(*ExportChannelArchiveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lightning_CloseHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CloseHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).CloseHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/CloseHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).CloseHistory(ctx, req.(*CloseHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lightning_SubscribeBreachEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BreachEventSubscription)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LightningServer).SubscribeBreachEvents(m, &lightningSubscribeBreachEventsServer{stream})
+}
+
+type Lightning_SubscribeBreachEventsServer interface {
+	Send(*BreachEvent) error
+	grpc.ServerStream
+}
+
+type lightningSubscribeBreachEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *lightningSubscribeBreachEventsServer) Send(m *BreachEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Lightning_OpenChannelSync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(OpenChannelRequest)
 	if err := dec(in); err != nil {
@@ -2506,6 +2985,24 @@ func _Lightning_AbandonChannel_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_SimulateForceClose_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SimulateForceCloseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).SimulateForceClose(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/SimulateForceClose",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).SimulateForceClose(ctx, req.(*SimulateForceCloseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Lightning_SendPayment_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(LightningServer).SendPayment(&lightningSendPaymentServer{stream})
 }
@@ -3053,6 +3550,24 @@ func (x *lightningSubscribeChannelBackupsServer) Send(m *ChanBackupSnapshot) err
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Lightning_ListChannelBackupVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListChannelBackupVersionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ListChannelBackupVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ListChannelBackupVersions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ListChannelBackupVersions(ctx, req.(*ListChannelBackupVersionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Lightning_BakeMacaroon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(BakeMacaroonRequest)
 	if err := dec(in); err != nil {
@@ -3125,6 +3640,45 @@ func _Lightning_ListPermissions_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Lightning_ListAuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LightningServer).ListAuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/lnrpc.Lightning/ListAuditLog",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LightningServer).ListAuditLog(ctx, req.(*ListAuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Lightning_SubscribeAuditLog_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeAuditLogRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LightningServer).SubscribeAuditLog(m, &lightningSubscribeAuditLogServer{stream})
+}
+
+type Lightning_SubscribeAuditLogServer interface {
+	Send(*AuditLogEntry) error
+	grpc.ServerStream
+}
+
+type lightningSubscribeAuditLogServer struct {
+	grpc.ServerStream
+}
+
+func (x *lightningSubscribeAuditLogServer) Send(m *AuditLogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Lightning_CheckMacaroonPermissions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CheckMacPermRequest)
 	if err := dec(in); err != nil {
@@ -3263,6 +3817,10 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTransactions",
 			Handler:    _Lightning_GetTransactions_Handler,
 		},
+		{
+			MethodName: "ListAccountingEntries",
+			Handler:    _Lightning_ListAccountingEntries_Handler,
+		},
 		{
 			MethodName: "EstimateFee",
 			Handler:    _Lightning_EstimateFee_Handler,
@@ -3315,10 +3873,26 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetRecoveryInfo",
 			Handler:    _Lightning_GetRecoveryInfo_Handler,
 		},
+		{
+			MethodName: "GetDatabaseStats",
+			Handler:    _Lightning_GetDatabaseStats_Handler,
+		},
+		{
+			MethodName: "CompactDatabase",
+			Handler:    _Lightning_CompactDatabase_Handler,
+		},
 		{
 			MethodName: "PendingChannels",
 			Handler:    _Lightning_PendingChannels_Handler,
 		},
+		{
+			MethodName: "ListContractResolvers",
+			Handler:    _Lightning_ListContractResolvers_Handler,
+		},
+		{
+			MethodName: "ReevaluateContractResolver",
+			Handler:    _Lightning_ReevaluateContractResolver_Handler,
+		},
 		{
 			MethodName: "ListChannels",
 			Handler:    _Lightning_ListChannels_Handler,
@@ -3327,6 +3901,14 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ClosedChannels",
 			Handler:    _Lightning_ClosedChannels_Handler,
 		},
+		{
+			MethodName: "ExportChannelArchive",
+			Handler:    _Lightning_ExportChannelArchive_Handler,
+		},
+		{
+			MethodName: "CloseHistory",
+			Handler:    _Lightning_CloseHistory_Handler,
+		},
 		{
 			MethodName: "OpenChannelSync",
 			Handler:    _Lightning_OpenChannelSync_Handler,
@@ -3343,6 +3925,10 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AbandonChannel",
 			Handler:    _Lightning_AbandonChannel_Handler,
 		},
+		{
+			MethodName: "SimulateForceClose",
+			Handler:    _Lightning_SimulateForceClose_Handler,
+		},
 		{
 			MethodName: "SendPaymentSync",
 			Handler:    _Lightning_SendPaymentSync_Handler,
@@ -3439,6 +4025,10 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RestoreChannelBackups",
 			Handler:    _Lightning_RestoreChannelBackups_Handler,
 		},
+		{
+			MethodName: "ListChannelBackupVersions",
+			Handler:    _Lightning_ListChannelBackupVersions_Handler,
+		},
 		{
 			MethodName: "BakeMacaroon",
 			Handler:    _Lightning_BakeMacaroon_Handler,
@@ -3455,6 +4045,10 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListPermissions",
 			Handler:    _Lightning_ListPermissions_Handler,
 		},
+		{
+			MethodName: "ListAuditLog",
+			Handler:    _Lightning_ListAuditLog_Handler,
+		},
 		{
 			MethodName: "CheckMacaroonPermissions",
 			Handler:    _Lightning_CheckMacaroonPermissions_Handler,
@@ -3488,6 +4082,11 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Lightning_SubscribeChannelEvents_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SubscribeBreachEvents",
+			Handler:       _Lightning_SubscribeBreachEvents_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "OpenChannel",
 			Handler:       _Lightning_OpenChannel_Handler,
@@ -3531,6 +4130,11 @@ var Lightning_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Lightning_SubscribeChannelBackups_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "SubscribeAuditLog",
+			Handler:       _Lightning_SubscribeAuditLog_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "RegisterRPCMiddleware",
 			Handler:       _Lightning_RegisterRPCMiddleware_Handler,
@@ -335,12 +335,25 @@ func (s *Server) SendPaymentV2(req *SendPaymentRequest,
 	// Get the payment hash.
 	payHash := payment.Identifier()
 
+	// Make sure the macaroon of the request allows the payment. The fee
+	// limit counts against the limits as well, as that is the amount the
+	// payment can cost us in the worst case.
+	release, err := s.cfg.MacService.AuthorizePayment(
+		stream.Context(), payment.Amount+payment.FeeLimit,
+		payment.Target,
+	)
+	if err != nil {
+		return err
+	}
+
 	// Init the payment in db.
 	paySession, shardTracker, err := s.cfg.Router.PreparePayment(payment)
 	if err != nil {
 		log.Errorf("SendPayment async error for payment %x: %v",
 			payment.Identifier(), err)
 
+		s.releaseSpend(payHash, release)
+
 		// Transform user errors to grpc code.
 		if errors.Is(err, channeldb.ErrPaymentExists) ||
 			errors.Is(err, channeldb.ErrPaymentInFlight) ||
@@ -358,8 +371,20 @@ func (s *Server) SendPaymentV2(req *SendPaymentRequest,
 	// miss events.
 	sub, err := s.subscribePayment(payHash)
 	if err != nil {
+		s.releaseSpend(payHash, release)
+		return err
+	}
+
+	// Make sure the payment no longer counts against the spending windows
+	// of the macaroon if it fails, even if the client doesn't track it
+	// until the end.
+	releaseSub, err := s.subscribePayment(payHash)
+	if err != nil {
+		sub.Close()
+		s.releaseSpend(payHash, release)
 		return err
 	}
+	go s.releaseSpendOnFailure(releaseSub, payHash, release)
 
 	// Send the payment asynchronously.
 	s.cfg.Router.SendPaymentAsync(payment, paySession, shardTracker)
@@ -835,6 +860,15 @@ func (s *Server) SendToRouteV2(ctx context.Context,
 		return nil, err
 	}
 
+	// Make sure the macaroon of the request allows the payment.
+	release, err := s.cfg.MacService.AuthorizePayment(
+		ctx, route.TotalAmount,
+		route.Hops[len(route.Hops)-1].PubKeyBytes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	var attempt *channeldb.HTLCAttempt
 
 	// Pass route to the router. This call returns the full htlc attempt
@@ -848,6 +882,13 @@ func (s *Server) SendToRouteV2(ctx context.Context,
 	} else {
 		attempt, err = s.cfg.Router.SendToRoute(hash, route)
 	}
+
+	// If the attempt failed, or was never made, the payment no longer
+	// counts against the spending windows of the macaroon.
+	if attempt == nil || attempt.Failure != nil {
+		s.releaseSpend(hash, release)
+	}
+
 	if attempt != nil {
 		rpcAttempt, err := s.cfg.RouterBackend.MarshalHTLCAttempt(
 			*attempt,
@@ -1274,6 +1315,45 @@ func (s *Server) subscribePayment(identifier lntypes.Hash) (
 	return sub, nil
 }
 
+// releaseSpend removes a failed payment from the spending windows of the
+// macaroon it was authorized with.
+func (s *Server) releaseSpend(identifier lntypes.Hash, release func() error) {
+	if err := release(); err != nil {
+		log.Errorf("Unable to release spend of payment %v: %v",
+			identifier, err)
+	}
+}
+
+// releaseSpendOnFailure waits for the payment of the given subscription to
+// reach a final state and removes it from the spending windows of the macaroon
+// it was authorized with if it failed.
+//
+// NOTE: This MUST be run as a goroutine.
+func (s *Server) releaseSpendOnFailure(
+	subscription routing.ControlTowerSubscriber, identifier lntypes.Hash,
+	release func() error) {
+
+	defer subscription.Close()
+
+	for {
+		select {
+		case item, ok := <-subscription.Updates():
+			if !ok {
+				return
+			}
+
+			payment := item.(*channeldb.MPPayment)
+			if payment.Status == channeldb.StatusFailed {
+				s.releaseSpend(identifier, release)
+				return
+			}
+
+		case <-s.quit:
+			return
+		}
+	}
+}
+
 // trackPayment writes payment status updates to the provided stream.
 func (s *Server) trackPayment(subscription routing.ControlTowerSubscriber,
 	identifier lntypes.Hash, stream Router_TrackPaymentV2Server,
@@ -6,6 +6,7 @@ package signrpc
 import (
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet/signerpolicy"
 	"github.com/lightningnetwork/lnd/macaroons"
 )
 
@@ -36,4 +37,9 @@ type Config struct {
 	// KeyRing is an interface that the signer will use to derive any keys
 	// for signing messages.
 	KeyRing keychain.SecretKeyRing
+
+	// SignerPolicy is the policy engine that all transactions and MuSig2
+	// signing requests are checked against before they're signed. If it
+	// is nil, all requests are signed.
+	SignerPolicy *signerpolicy.Engine
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/lnwallet/signerpolicy"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/macaroons"
 	"google.golang.org/grpc"
@@ -467,6 +468,14 @@ func (s *Server) SignOutputRaw(_ context.Context, in *SignReq) (*SignResp,
 		}
 	}
 
+	// Make sure the transaction complies with the signer policy before we
+	// sign anything. SignOutputRaw only signs with the keys of lnd's
+	// internal key families, never with on-chain wallet keys.
+	err = s.checkSignerPolicy(&txToSign, signDescs, false)
+	if err != nil {
+		return nil, err
+	}
+
 	// Now that we've mapped all the proper sign descriptors, we can
 	// request signatures for each of them, passing in the transaction to
 	// be signed.
@@ -569,6 +578,14 @@ func (s *Server) ComputeInputScript(ctx context.Context,
 		})
 	}
 
+	// ComputeInputScript only signs for outputs of the on-chain wallet, so
+	// the transaction is a wallet send as far as the signer policy is
+	// concerned.
+	err := s.checkSignerPolicy(&txToSign, signDescs, true)
+	if err != nil {
+		return nil, err
+	}
+
 	// With all of our signDescs assembled, we can now generate a valid
 	// input script for each of them, and collate the responses to return
 	// back to the caller.
@@ -1104,6 +1121,14 @@ func (s *Server) MuSig2Sign(_ context.Context,
 	}
 	copy(msg[:], in.MessageDigest)
 
+	// The message digest can't be checked against the signer policy, so
+	// the policy decides whether we sign it at all.
+	if s.cfg.SignerPolicy != nil {
+		if err := s.cfg.SignerPolicy.CheckMuSig2Sign(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create our own partial signature with the local signing key.
 	partialSig, err := s.cfg.Signer.MuSig2Sign(sessionID, msg, in.Cleanup)
 	if err != nil {
@@ -1204,6 +1229,51 @@ func parseRawKeyBytes(rawKeyBytes []byte) (*btcec.PublicKey, error) {
 	}
 }
 
+// checkSignerPolicy checks the transaction against the signer policy, if one
+// is configured. The previous outputs of the inputs are taken from the sign
+// descriptors. None of the outputs are considered to pay back to the wallet.
+func (s *Server) checkSignerPolicy(tx *wire.MsgTx,
+	signDescs []*input.SignDescriptor, walletKeys bool) error {
+
+	if s.cfg.SignerPolicy == nil {
+		return nil
+	}
+
+	inputs := make([]signerpolicy.Input, len(tx.TxIn))
+	for _, signDesc := range signDescs {
+		idx := signDesc.InputIndex
+		if idx < 0 || idx >= len(inputs) {
+			return fmt.Errorf("invalid input index %d", idx)
+		}
+
+		inputs[idx] = signerpolicy.Input{
+			PrevOut:    signDesc.Output,
+			WalletKey:  walletKeys,
+			FundingKey: isFundingSpend(signDesc),
+		}
+	}
+
+	return s.cfg.SignerPolicy.CheckTransaction(tx, inputs, nil)
+}
+
+// isFundingSpend returns true if the sign descriptor spends a channel funding
+// output, which is the case if it's signed with a key of the multisig key
+// family or its witness script is a multisig script.
+func isFundingSpend(signDesc *input.SignDescriptor) bool {
+	// Without a public key, the signer derives the key from the key
+	// locator, which then must be of the multisig family.
+	keyDesc := signDesc.KeyDesc
+	if keyDesc.PubKey == nil &&
+		keyDesc.Family == keychain.KeyFamilyMultiSig {
+
+		return true
+	}
+
+	isMultiSig, err := txscript.IsMultisigScript(signDesc.WitnessScript)
+
+	return err == nil && isMultiSig
+}
+
 // parseMuSig2SessionID parses a MuSig2 session ID from a raw byte slice.
 func parseMuSig2SessionID(rawID []byte) (input.MuSig2SessionID, error) {
 	sessionID := input.MuSig2SessionID{}
@@ -4,13 +4,17 @@
 package walletrpc
 
 import (
+	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcwallet/wallet"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/signerpolicy"
 	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/lightningnetwork/lnd/subscribe"
 	"github.com/lightningnetwork/lnd/sweep"
+	"github.com/lightningnetwork/lnd/walletunlocker"
 )
 
 const (
@@ -31,6 +35,11 @@ type Config struct {
 	// directory, named DefaultWalletKitMacFilename.
 	WalletKitMacPath string `long:"walletkitmacaroonpath" description:"Path to the wallet kit macaroon"`
 
+	// PayjoinEndpoint is the externally reachable URL of the payjoin
+	// endpoint that is served by the REST listener. Payjoin URIs can only
+	// be created if it is set.
+	PayjoinEndpoint string `long:"payjoinendpoint" description:"The externally reachable URL (https or onion) under which the /v2/wallet/payjoin path of the REST listener is served to payjoin senders"`
+
 	// NetworkDir is the main network directory wherein the signer rpc
 	// server will find the macaroon named DefaultWalletKitMacFilename.
 	NetworkDir string
@@ -76,4 +85,21 @@ type Config struct {
 	// CoinSelectionStrategy is the strategy that is used for selecting
 	// coins when funding a transaction.
 	CoinSelectionStrategy wallet.CoinSelectionStrategy
+
+	// SignerPolicy is the policy engine that all PSBTs are checked against
+	// before they're signed. If it is nil, all PSBTs are signed.
+	SignerPolicy *signerpolicy.Engine
+
+	// PsbtFundingVerify hands a PSBT to the pending channel with the given
+	// ID, which is used to fund channels with payjoin transactions.
+	PsbtFundingVerify func(pendingChanID [32]byte, packet *psbt.Packet,
+		skipFinalize bool) error
+
+	// SubscribeChannelEvents subscribes to the channel events of the
+	// channel notifier.
+	SubscribeChannelEvents func() (*subscribe.Client, error)
+
+	// NamedWallets manages the named wallets that can be selected instead
+	// of the main wallet. It is nil if named wallets aren't supported.
+	NamedWallets *walletunlocker.NamedWalletManager
 }
@@ -0,0 +1,556 @@
+//go:build walletrpc
+// +build walletrpc
+
+package walletrpc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/labels"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
+)
+
+const (
+	// consolidationPollInterval is the interval at which the consolidator
+	// checks whether a new block was found.
+	consolidationPollInterval = time.Minute
+
+	// defaultConsolidationConfTarget is the confirmation target that is
+	// used to estimate the current fee rate if a schedule doesn't specify
+	// one.
+	defaultConsolidationConfTarget = 6
+
+	// defaultConsolidationMaxInputs is the maximum number of UTXOs spent
+	// by a single consolidation transaction if a schedule doesn't specify
+	// one.
+	defaultConsolidationMaxInputs = 100
+)
+
+var (
+	// errUnknownSchedule is returned if a consolidation schedule with the
+	// given ID doesn't exist.
+	errUnknownSchedule = errors.New("unknown consolidation schedule")
+)
+
+// consolidationSchedule describes which UTXOs of an account are consolidated
+// and at which fee rate.
+type consolidationSchedule struct {
+	// id is the unique ID of the schedule.
+	id uint64
+
+	// maxFeeRate is the highest fee rate at which a consolidation is
+	// published.
+	maxFeeRate chainfee.SatPerKWeight
+
+	// confTarget is the confirmation target used to estimate the current
+	// fee rate.
+	confTarget uint32
+
+	// minUtxos is the minimum number of eligible UTXOs that are required
+	// for a consolidation.
+	minUtxos uint32
+
+	// maxInputs is the maximum number of UTXOs spent by a single
+	// consolidation.
+	maxInputs uint32
+
+	// maxUtxoValue is the maximum value of an UTXO to be consolidated. If
+	// it is zero, UTXOs of any value are consolidated.
+	maxUtxoValue btcutil.Amount
+
+	// account is the name of the account the UTXOs of are consolidated.
+	account string
+
+	// labelFilter is an optional string that the label of the transaction
+	// that created an UTXO must contain for it to be consolidated.
+	labelFilter string
+
+	// numConsolidations is the number of consolidations published by the
+	// schedule.
+	numConsolidations uint32
+
+	// lastTxid is the txid of the last consolidation published by the
+	// schedule.
+	lastTxid *chainhash.Hash
+
+	// lastErr is the error of the last consolidation attempt.
+	lastErr error
+}
+
+// consolidator merges small UTXOs of the wallet into fewer outputs whenever
+// the fee rate drops below the ceiling of a consolidation schedule.
+type consolidator struct {
+	started sync.Once
+	stopped sync.Once
+
+	cfg *Config
+
+	mu        sync.Mutex
+	nextID    uint64
+	schedules map[uint64]*consolidationSchedule
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newConsolidator creates a new consolidator that uses the wallet, fee
+// estimator and chain of the given config.
+func newConsolidator(cfg *Config) *consolidator {
+	return &consolidator{
+		cfg:       cfg,
+		nextID:    1,
+		schedules: make(map[uint64]*consolidationSchedule),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start launches the goroutine that consolidates UTXOs on new blocks.
+func (c *consolidator) Start() {
+	c.started.Do(func() {
+		c.wg.Add(1)
+		go c.consolidationLoop()
+	})
+}
+
+// Stop stops the consolidator and waits for a running consolidation to
+// finish.
+func (c *consolidator) Stop() {
+	c.stopped.Do(func() {
+		close(c.quit)
+		c.wg.Wait()
+	})
+}
+
+// addSchedule adds the given consolidation schedule and returns its ID.
+func (c *consolidator) addSchedule(s *consolidationSchedule) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s.id = c.nextID
+	c.nextID++
+	c.schedules[s.id] = s
+
+	return s.id
+}
+
+// cancelSchedule removes the consolidation schedule with the given ID.
+func (c *consolidator) cancelSchedule(id uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.schedules[id]; !ok {
+		return errUnknownSchedule
+	}
+	delete(c.schedules, id)
+
+	return nil
+}
+
+// listSchedules returns copies of the active consolidation schedules, ordered
+// by their ID.
+func (c *consolidator) listSchedules() []consolidationSchedule {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	schedules := make([]consolidationSchedule, 0, len(c.schedules))
+	for _, s := range c.schedules {
+		schedules = append(schedules, *s)
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].id < schedules[j].id
+	})
+
+	return schedules
+}
+
+// consolidationLoop runs all consolidation schedules once for every new block.
+//
+// NOTE: This MUST be run as a goroutine.
+func (c *consolidator) consolidationLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(consolidationPollInterval)
+	defer ticker.Stop()
+
+	var lastHeight int32
+	for {
+		select {
+		case <-ticker.C:
+			_, height, err := c.cfg.Chain.GetBestBlock()
+			if err != nil {
+				log.Errorf("Unable to fetch best block: %v",
+					err)
+
+				continue
+			}
+
+			// Fee estimates only change with new blocks, so we
+			// only have to check the schedules once per block.
+			if height == lastHeight {
+				continue
+			}
+			lastHeight = height
+
+			for _, s := range c.listSchedules() {
+				c.runSchedule(s)
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// runSchedule attempts a consolidation for the given schedule and records the
+// result if the schedule is still active.
+func (c *consolidator) runSchedule(s consolidationSchedule) {
+	txid, err := c.consolidate(&s)
+	if err != nil {
+		log.Errorf("Consolidation of schedule %d failed: %v", s.id,
+			err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	active, ok := c.schedules[s.id]
+	if !ok {
+		return
+	}
+
+	active.lastErr = err
+	if txid != nil {
+		active.numConsolidations++
+		active.lastTxid = txid
+	}
+}
+
+// consolidate publishes a consolidation transaction for the given schedule if
+// the current fee rate is low enough and there are enough eligible UTXOs. The
+// txid of the transaction is returned, or nil if no consolidation was
+// published.
+func (c *consolidator) consolidate(
+	s *consolidationSchedule) (*chainhash.Hash, error) {
+
+	feeRate, err := c.cfg.FeeEstimator.EstimateFeePerKW(s.confTarget)
+	if err != nil {
+		return nil, fmt.Errorf("unable to estimate fee rate: %w", err)
+	}
+
+	if feeRate > s.maxFeeRate {
+		log.Debugf("Skipping consolidation of schedule %d, fee rate "+
+			"%v is above %v", s.id, feeRate, s.maxFeeRate)
+
+		return nil, nil
+	}
+
+	// We hold the coin selection lock until the transaction is published,
+	// so no other transaction can spend the selected UTXOs in the
+	// meantime.
+	var txid *chainhash.Hash
+	err = c.cfg.CoinSelectionLocker.WithCoinSelectLock(func() error {
+		var err error
+		txid, err = c.consolidateLocked(s, feeRate)
+
+		return err
+	})
+
+	return txid, err
+}
+
+// consolidateLocked selects, signs and publishes a consolidation transaction.
+//
+// NOTE: The coin selection lock must be held.
+func (c *consolidator) consolidateLocked(s *consolidationSchedule,
+	feeRate chainfee.SatPerKWeight) (*chainhash.Hash, error) {
+
+	utxos, err := c.cfg.Wallet.ListUnspentWitness(
+		1, math.MaxInt32, s.account,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := c.cfg.Wallet.ListLeasedOutputs()
+	if err != nil {
+		return nil, err
+	}
+	leased := make(map[wire.OutPoint]struct{}, len(leases))
+	for _, lease := range leases {
+		leased[lease.Outpoint] = struct{}{}
+	}
+
+	var coinInfo map[wire.OutPoint]*chanfunding.CoinInfo
+	if s.labelFilter != "" {
+		coinInfo, err = lnwallet.FetchCoinInfo(c.cfg.Wallet, s.account)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	selected := selectConsolidationUtxos(
+		utxos, leased, coinInfo, s, feeRate,
+	)
+	if uint32(len(selected)) < s.minUtxos {
+		log.Debugf("Skipping consolidation of schedule %d, only %d "+
+			"of %d UTXOs eligible", s.id, len(selected),
+			s.minUtxos)
+
+		return nil, nil
+	}
+
+	// The UTXOs are merged into a new internal address of the account. We
+	// use a taproot address for the default account and the type of the
+	// UTXOs for custom accounts, which only support a single type. The
+	// address is only derived once all checks passed, so skipped runs
+	// don't use up addresses of the account.
+	addrType := lnwallet.TaprootPubkey
+	if s.account != lnwallet.DefaultAccountName {
+		addrType = selected[0].AddressType
+	}
+
+	var (
+		weightEstimate input.TxWeightEstimator
+		total          btcutil.Amount
+		outpoints      = make([]*wire.OutPoint, len(selected))
+		sequences      = make([]uint32, len(selected))
+	)
+	for i, utxo := range selected {
+		err := addUtxoWeight(&weightEstimate, utxo.AddressType)
+		if err != nil {
+			return nil, err
+		}
+
+		total += utxo.Value
+		outpoints[i] = &selected[i].OutPoint
+		sequences[i] = wire.MaxTxInSequenceNum
+	}
+	scriptSize, err := addOutputWeight(&weightEstimate, addrType)
+	if err != nil {
+		return nil, err
+	}
+
+	fee := feeRate.FeeForWeight(int64(weightEstimate.Weight()))
+	if total-fee < lnwallet.DustLimitForSize(scriptSize) {
+		return nil, fmt.Errorf("consolidated output of %v would be "+
+			"dust", total-fee)
+	}
+
+	// The reserve for anchor channels can only be spent from the default
+	// account, so just like the wallet does before publishing a
+	// transaction, we make sure its balance after the consolidation still
+	// covers the reserve.
+	numAnchorChans, err := c.cfg.CurrentNumAnchorChans()
+	if err != nil {
+		return nil, err
+	}
+	reserve := c.cfg.Wallet.RequiredReserve(uint32(numAnchorChans))
+
+	defaultUtxos, err := c.cfg.Wallet.ListUnspentWitness(
+		0, math.MaxInt32, lnwallet.DefaultAccountName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	balance := balanceAfterConsolidation(
+		defaultUtxos, selected, s.account, total-fee,
+	)
+	if balance < reserve {
+		log.Debugf("Skipping consolidation of schedule %d, default "+
+			"account balance of %v after paying a fee of %v would "+
+			"be below the reserve %v", s.id, balance, fee, reserve)
+
+		return nil, nil
+	}
+
+	addr, err := c.cfg.Wallet.NewAddress(addrType, true, s.account)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	packet, err := psbt.New(
+		outpoints, []*wire.TxOut{{
+			Value:    int64(total - fee),
+			PkScript: pkScript,
+		}}, 2, 0, sequences,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for i, utxo := range selected {
+		packet.Inputs[i].WitnessUtxo = &wire.TxOut{
+			Value:    int64(utxo.Value),
+			PkScript: utxo.PkScript,
+		}
+	}
+
+	err = c.cfg.Wallet.FinalizePsbt(packet, s.account)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign consolidation: %w", err)
+	}
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.cfg.Wallet.PublishTransaction(
+		tx, labels.MakeConsolidationLabel(s.id),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to publish consolidation: %w",
+			err)
+	}
+
+	txid := tx.TxHash()
+	log.Infof("Consolidated %d UTXOs worth %v of schedule %d in tx %v "+
+		"paying a fee of %v", len(selected), total, s.id, txid, fee)
+
+	return &txid, nil
+}
+
+// balanceAfterConsolidation returns the balance of the default account after
+// the given UTXOs of the account are consolidated into an output of the given
+// value.
+func balanceAfterConsolidation(defaultUtxos, selected []*lnwallet.Utxo,
+	account string, outputValue btcutil.Amount) btcutil.Amount {
+
+	spent := make(map[wire.OutPoint]struct{}, len(selected))
+	for _, utxo := range selected {
+		spent[utxo.OutPoint] = struct{}{}
+	}
+
+	var balance btcutil.Amount
+	for _, utxo := range defaultUtxos {
+		if _, ok := spent[utxo.OutPoint]; ok {
+			continue
+		}
+
+		balance += utxo.Value
+	}
+
+	// The consolidated output only adds to the balance of the default
+	// account if it's the account that is consolidated.
+	if account == lnwallet.DefaultAccountName {
+		balance += outputValue
+	}
+
+	return balance
+}
+
+// selectConsolidationUtxos returns the UTXOs that are eligible for a
+// consolidation of the given schedule, smallest first. Leased UTXOs and UTXOs
+// that aren't worth the fee for spending them are never selected.
+func selectConsolidationUtxos(utxos []*lnwallet.Utxo,
+	leased map[wire.OutPoint]struct{},
+	coinInfo map[wire.OutPoint]*chanfunding.CoinInfo,
+	s *consolidationSchedule,
+	feeRate chainfee.SatPerKWeight) []*lnwallet.Utxo {
+
+	var selected []*lnwallet.Utxo
+	for _, utxo := range utxos {
+		if _, ok := leased[utxo.OutPoint]; ok {
+			continue
+		}
+
+		if s.maxUtxoValue != 0 && utxo.Value > s.maxUtxoValue {
+			continue
+		}
+
+		if s.labelFilter != "" {
+			info, ok := coinInfo[utxo.OutPoint]
+			if !ok || !strings.Contains(info.Label, s.labelFilter) {
+				continue
+			}
+		}
+
+		// We only spend UTXOs that are worth more than the fee of the
+		// input that spends them.
+		var baseEstimate, withInput input.TxWeightEstimator
+		baseEstimate.AddP2WKHInput()
+		withInput = baseEstimate
+		err := addUtxoWeight(&withInput, utxo.AddressType)
+		if err != nil {
+			continue
+		}
+		inputWeight := int64(
+			withInput.Weight() - baseEstimate.Weight(),
+		)
+		if utxo.Value <= feeRate.FeeForWeight(inputWeight) {
+			continue
+		}
+
+		selected = append(selected, utxo)
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		return selected[i].Value < selected[j].Value
+	})
+	if uint32(len(selected)) > s.maxInputs {
+		selected = selected[:s.maxInputs]
+	}
+
+	return selected
+}
+
+// addUtxoWeight adds the weight of an input that spends a wallet UTXO of the
+// given address type to the weight estimate.
+func addUtxoWeight(weightEstimate *input.TxWeightEstimator,
+	addrType lnwallet.AddressType) error {
+
+	switch addrType {
+	case lnwallet.WitnessPubKey:
+		weightEstimate.AddP2WKHInput()
+
+	case lnwallet.NestedWitnessPubKey:
+		weightEstimate.AddNestedP2WKHInput()
+
+	case lnwallet.TaprootPubkey:
+		weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+
+	default:
+		return fmt.Errorf("unsupported address type %v", addrType)
+	}
+
+	return nil
+}
+
+// addOutputWeight adds the weight of an output paying to an address of the
+// given type to the weight estimate and returns the size of its pkScript.
+func addOutputWeight(weightEstimate *input.TxWeightEstimator,
+	addrType lnwallet.AddressType) (int, error) {
+
+	switch addrType {
+	case lnwallet.WitnessPubKey:
+		weightEstimate.AddP2WKHOutput()
+		return input.P2WPKHSize, nil
+
+	case lnwallet.NestedWitnessPubKey:
+		weightEstimate.AddP2SHOutput()
+		return input.P2SHSize, nil
+
+	case lnwallet.TaprootPubkey:
+		weightEstimate.AddP2TROutput()
+		return input.P2TRSize, nil
+
+	default:
+		return 0, fmt.Errorf("unsupported address type %v", addrType)
+	}
+}
@@ -0,0 +1,151 @@
+//go:build walletrpc
+// +build walletrpc
+
+package walletrpc
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSelectConsolidationUtxos tests that only the eligible UTXOs of a
+// consolidation schedule are selected, smallest first.
+func TestSelectConsolidationUtxos(t *testing.T) {
+	t.Parallel()
+
+	const feeRate = chainfee.SatPerKWeight(1000)
+
+	makeUtxo := func(index uint32, value btcutil.Amount,
+		addrType lnwallet.AddressType) *lnwallet.Utxo {
+
+		return &lnwallet.Utxo{
+			AddressType: addrType,
+			Value:       value,
+			OutPoint:    wire.OutPoint{Index: index},
+		}
+	}
+
+	utxos := []*lnwallet.Utxo{
+		makeUtxo(0, 30_000, lnwallet.WitnessPubKey),
+		makeUtxo(1, 10_000, lnwallet.TaprootPubkey),
+		makeUtxo(2, 20_000, lnwallet.NestedWitnessPubKey),
+		makeUtxo(3, 50_000, lnwallet.WitnessPubKey),
+		makeUtxo(4, 1_000_000, lnwallet.WitnessPubKey),
+
+		// This UTXO isn't worth the fee for spending it.
+		makeUtxo(5, 100, lnwallet.WitnessPubKey),
+
+		// UTXOs of unknown types are never selected.
+		makeUtxo(6, 40_000, lnwallet.UnknownAddressType),
+	}
+
+	leased := map[wire.OutPoint]struct{}{
+		utxos[3].OutPoint: {},
+	}
+
+	coinInfo := map[wire.OutPoint]*chanfunding.CoinInfo{
+		utxos[0].OutPoint: {Label: "0:sweep"},
+		utxos[1].OutPoint: {Label: "0:sweep"},
+		utxos[2].OutPoint: {Label: "external"},
+	}
+
+	testCases := []struct {
+		name     string
+		schedule consolidationSchedule
+		expected []uint32
+	}{
+		{
+			name: "all eligible",
+			schedule: consolidationSchedule{
+				maxInputs: 100,
+			},
+			expected: []uint32{1, 2, 0, 4},
+		},
+		{
+			name: "max value",
+			schedule: consolidationSchedule{
+				maxInputs:    100,
+				maxUtxoValue: 30_000,
+			},
+			expected: []uint32{1, 2, 0},
+		},
+		{
+			name: "label filter",
+			schedule: consolidationSchedule{
+				maxInputs:   100,
+				labelFilter: "sweep",
+			},
+			expected: []uint32{1, 0},
+		},
+		{
+			name: "max inputs",
+			schedule: consolidationSchedule{
+				maxInputs: 2,
+			},
+			expected: []uint32{1, 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			selected := selectConsolidationUtxos(
+				utxos, leased, coinInfo, &tc.schedule, feeRate,
+			)
+
+			indexes := make([]uint32, 0, len(selected))
+			for _, utxo := range selected {
+				indexes = append(indexes, utxo.OutPoint.Index)
+			}
+			require.Equal(t, tc.expected, indexes)
+		})
+	}
+}
+
+// TestBalanceAfterConsolidation tests that the balance checked against the
+// anchor reserve is the one of the default account after the consolidation.
+func TestBalanceAfterConsolidation(t *testing.T) {
+	t.Parallel()
+
+	makeUtxo := func(index uint32, value btcutil.Amount) *lnwallet.Utxo {
+		return &lnwallet.Utxo{
+			Value:    value,
+			OutPoint: wire.OutPoint{Index: index},
+		}
+	}
+
+	defaultUtxos := []*lnwallet.Utxo{
+		makeUtxo(0, 10_000),
+		makeUtxo(1, 20_000),
+		makeUtxo(2, 100_000),
+	}
+
+	// Consolidating UTXOs of the default account only reduces its balance
+	// by the fee.
+	balance := balanceAfterConsolidation(
+		defaultUtxos, defaultUtxos[:2], lnwallet.DefaultAccountName,
+		29_000,
+	)
+	require.Equal(t, btcutil.Amount(129_000), balance)
+
+	// The UTXOs and the consolidated output of other accounts don't count
+	// towards the balance of the default account, no matter how large
+	// they are.
+	otherUtxos := []*lnwallet.Utxo{
+		makeUtxo(3, 1_000_000),
+		makeUtxo(4, 2_000_000),
+	}
+	balance = balanceAfterConsolidation(
+		defaultUtxos, otherUtxos, "custom", 2_999_000,
+	)
+	require.Equal(t, btcutil.Amount(130_000), balance)
+}
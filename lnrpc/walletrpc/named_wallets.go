@@ -0,0 +1,238 @@
+//go:build walletrpc
+// +build walletrpc
+
+package walletrpc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/aezeed"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/sweep"
+	"github.com/lightningnetwork/lnd/walletunlocker"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultNamedRecoveryWindow is the number of addresses looked ahead
+	// when a named wallet is restored from an existing seed and no
+	// recovery window is given.
+	defaultNamedRecoveryWindow = 2500
+)
+
+var (
+	// ErrNamedWalletsUnsupported is returned if named wallets are
+	// requested but not supported by the wallet backend.
+	ErrNamedWalletsUnsupported = errors.New("named wallets are only " +
+		"supported with a local wallet and the bolt database backend")
+)
+
+// namedWallets returns the manager of the named wallets.
+func (w *WalletKit) namedWallets() (*walletunlocker.NamedWalletManager,
+	error) {
+
+	if w.cfg.NamedWallets == nil {
+		return nil, ErrNamedWalletsUnsupported
+	}
+
+	return w.cfg.NamedWallets, nil
+}
+
+// selectWallet returns the wallet with the given name and its coin selection
+// locker. The main wallet is returned if the name is empty or the name of the
+// main wallet.
+func (w *WalletKit) selectWallet(name string) (lnwallet.WalletController,
+	sweep.CoinSelectionLocker, error) {
+
+	if name == "" || name == walletunlocker.DefaultWalletName {
+		return w.cfg.Wallet, w.cfg.CoinSelectionLocker, nil
+	}
+
+	manager, err := w.namedWallets()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namedWallet, err := manager.Wallet(name)
+	if err != nil {
+		return nil, nil, namedWalletStatus(err)
+	}
+
+	return namedWallet, namedWallet, nil
+}
+
+// namedWalletStatus maps the errors of the named wallet manager to gRPC
+// status errors.
+func namedWalletStatus(err error) error {
+	switch {
+	case errors.Is(err, walletunlocker.ErrNamedWalletNotFound):
+		return status.Error(codes.NotFound, err.Error())
+
+	case errors.Is(err, walletunlocker.ErrNamedWalletExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+
+	case errors.Is(err, walletunlocker.ErrNamedWalletLocked),
+		errors.Is(err, walletunlocker.ErrNamedWalletUnlocked):
+
+		return status.Error(codes.FailedPrecondition, err.Error())
+
+	default:
+		return err
+	}
+}
+
+// CreateNamedWallet creates a new named on-chain wallet with its own seed and
+// password and unlocks it.
+func (w *WalletKit) CreateNamedWallet(_ context.Context,
+	req *CreateNamedWalletRequest) (*CreateNamedWalletResponse, error) {
+
+	manager, err := w.namedWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := walletunlocker.ValidateWalletName(req.Name); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var (
+		cipherSeed     *aezeed.CipherSeed
+		mnemonic       []string
+		recoveryWindow uint32
+	)
+	switch {
+	case req.RecoveryWindow < 0:
+		return nil, status.Error(codes.InvalidArgument, "recovery "+
+			"window cannot be negative")
+
+	// Restore the wallet from the existing seed, which is decrypted with
+	// the passphrase.
+	case len(req.CipherSeedMnemonic) > 0:
+		if len(req.CipherSeedMnemonic) != aezeed.NumMnemonicWords {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"mnemonic must be %d words, got %d",
+				aezeed.NumMnemonicWords,
+				len(req.CipherSeedMnemonic))
+		}
+
+		var seedMnemonic aezeed.Mnemonic
+		copy(seedMnemonic[:], req.CipherSeedMnemonic)
+
+		cipherSeed, err = seedMnemonic.ToCipherSeed(
+			req.AezeedPassphrase,
+		)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument,
+				err.Error())
+		}
+
+		recoveryWindow = defaultNamedRecoveryWindow
+		if req.RecoveryWindow > 0 {
+			recoveryWindow = uint32(req.RecoveryWindow)
+		}
+
+	// Otherwise, we generate a new seed and return its mnemonic.
+	default:
+		var entropy [aezeed.EntropySize]byte
+		if _, err := rand.Read(entropy[:]); err != nil {
+			return nil, err
+		}
+
+		cipherSeed, err = aezeed.New(
+			keychain.CurrentKeyDerivationVersion, &entropy,
+			time.Now(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		seedMnemonic, err := cipherSeed.ToMnemonic(req.AezeedPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic = seedMnemonic[:]
+	}
+
+	err = manager.Create(
+		req.Name, req.WalletPassword, cipherSeed, recoveryWindow,
+	)
+	if err != nil {
+		return nil, namedWalletStatus(err)
+	}
+
+	log.Infof("Created named wallet %v", req.Name)
+
+	return &CreateNamedWalletResponse{
+		CipherSeedMnemonic: mnemonic,
+	}, nil
+}
+
+// UnlockNamedWallet unlocks a named wallet with its password.
+func (w *WalletKit) UnlockNamedWallet(_ context.Context,
+	req *UnlockNamedWalletRequest) (*UnlockNamedWalletResponse, error) {
+
+	manager, err := w.namedWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	err = manager.Unlock(req.Name, req.WalletPassword, 0)
+	if err != nil {
+		return nil, namedWalletStatus(err)
+	}
+
+	log.Infof("Unlocked named wallet %v", req.Name)
+
+	return &UnlockNamedWalletResponse{}, nil
+}
+
+// LockNamedWallet locks an unlocked named wallet.
+func (w *WalletKit) LockNamedWallet(_ context.Context,
+	req *LockNamedWalletRequest) (*LockNamedWalletResponse, error) {
+
+	manager, err := w.namedWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := manager.Lock(req.Name); err != nil {
+		return nil, namedWalletStatus(err)
+	}
+
+	log.Infof("Locked named wallet %v", req.Name)
+
+	return &LockNamedWalletResponse{}, nil
+}
+
+// ListNamedWallets returns all named wallets and whether they are unlocked.
+func (w *WalletKit) ListNamedWallets(_ context.Context,
+	_ *ListNamedWalletsRequest) (*ListNamedWalletsResponse, error) {
+
+	manager, err := w.namedWallets()
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := manager.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list named wallets: %w", err)
+	}
+
+	wallets := make([]*NamedWallet, 0, len(infos))
+	for _, info := range infos {
+		wallets = append(wallets, &NamedWallet{
+			Name:     info.Name,
+			Unlocked: info.Unlocked,
+		})
+	}
+
+	return &ListNamedWalletsResponse{
+		Wallets: wallets,
+	}, nil
+}
@@ -0,0 +1,122 @@
+//go:build walletrpc
+// +build walletrpc
+
+package walletrpc
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channelnotifier"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/payjoin"
+)
+
+const (
+	// payjoinPath is the path of the REST listener payjoin senders post
+	// their original PSBTs to.
+	payjoinPath = "/v2/wallet/payjoin"
+
+	// payjoinFundingTimeout is the time we wait for the peer of a channel
+	// that is funded by a payjoin to sign the commitment transaction.
+	payjoinFundingTimeout = 30 * time.Second
+)
+
+// pendingOpenEvent is the channel event that is sent once the peer of a new
+// channel has signed the commitment transaction.
+type pendingOpenEvent = channelnotifier.PendingOpenChannelEvent
+
+// mempoolChecker is implemented by wallets that can check whether a
+// transaction would be accepted to the mempool.
+type mempoolChecker interface {
+	CheckMempoolAcceptance(tx *wire.MsgTx) error
+}
+
+// newPayjoinReceiver creates the payjoin receiver of the wallet.
+func newPayjoinReceiver(cfg *Config) *payjoin.Receiver {
+	receiverCfg := &payjoin.ReceiverConfig{
+		Wallet:              cfg.Wallet,
+		CoinSelectionLocker: cfg.CoinSelectionLocker,
+	}
+
+	// The mempool check is implemented by the base wallet, which is
+	// embedded in the lightning wallet.
+	wallet := cfg.Wallet
+	if lnWallet, ok := wallet.(*lnwallet.LightningWallet); ok {
+		wallet = lnWallet.WalletController
+	}
+	if checker, ok := wallet.(mempoolChecker); ok {
+		receiverCfg.CheckMempoolAcceptance =
+			checker.CheckMempoolAcceptance
+	}
+
+	if cfg.PsbtFundingVerify != nil && cfg.SubscribeChannelEvents != nil {
+		receiverCfg.FundChannel = func(pendingChanID [32]byte,
+			packet *psbt.Packet) error {
+
+			return fundPayjoinChannel(cfg, pendingChanID, packet)
+		}
+	}
+
+	return payjoin.NewReceiver(receiverCfg)
+}
+
+// fundPayjoinChannel hands the payjoin proposal to the pending channel and
+// waits until the peer has signed the commitment transaction, so the inputs of
+// the wallet are never spent to a funding output we can't close.
+func fundPayjoinChannel(cfg *Config, pendingChanID [32]byte,
+	packet *psbt.Packet) error {
+
+	client, err := cfg.SubscribeChannelEvents()
+	if err != nil {
+		return err
+	}
+	defer client.Cancel()
+
+	err = cfg.PsbtFundingVerify(pendingChanID, packet, true)
+	if err != nil {
+		return err
+	}
+
+	txid := packet.UnsignedTx.TxHash()
+	timeout := time.After(payjoinFundingTimeout)
+	for {
+		select {
+		case update, ok := <-client.Updates():
+			if !ok {
+				return fmt.Errorf("channel event " +
+					"subscription canceled")
+			}
+
+			event, ok := update.(pendingOpenEvent)
+			if !ok || event.ChannelPoint.Hash != txid {
+				continue
+			}
+
+			return nil
+
+		case <-timeout:
+			return fmt.Errorf("peer didn't sign the commitment " +
+				"transaction in time")
+		}
+	}
+}
+
+// servePayjoin serves the payjoin requests of senders on the REST listener.
+func (r *ServerShell) servePayjoin(w http.ResponseWriter, req *http.Request,
+	_ map[string]string) {
+
+	r.payjoinMtx.RLock()
+	receiver := r.payjoinReceiver
+	r.payjoinMtx.RUnlock()
+
+	if receiver == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	receiver.ServeHTTP(w, req)
+}
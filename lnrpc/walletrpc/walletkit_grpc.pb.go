@@ -216,6 +216,53 @@ type WalletKitClient interface {
 	// overwrite the exiting transaction label. Labels must not be empty, and
 	// cannot exceed 500 characters.
 	LabelTransaction(ctx context.Context, in *LabelTransactionRequest, opts ...grpc.CallOption) (*LabelTransactionResponse, error)
+	// lncli: `wallet consolidation schedule`
+	// ScheduleConsolidation schedules the consolidation of small UTXOs of an
+	// account. Whenever a new block is found and the estimated fee rate is at or
+	// below the given ceiling, all eligible UTXOs are merged into a single new
+	// output of the same account, as long as there are at least the given number
+	// of them. Leased UTXOs are never spent, and no consolidation is published
+	// that would reduce the balance of the default account below the reserve
+	// required for anchor channels. Consolidation transactions are labelled with
+	// the ID of their schedule. Schedules are only held in memory: a schedule
+	// stays active until it is canceled or lnd is restarted, after which it must
+	// be created again.
+	ScheduleConsolidation(ctx context.Context, in *ScheduleConsolidationRequest, opts ...grpc.CallOption) (*ScheduleConsolidationResponse, error)
+	// lncli: `wallet consolidation list`
+	// ListConsolidations returns the active consolidation schedules and the
+	// consolidation transactions the wallet has published.
+	ListConsolidations(ctx context.Context, in *ListConsolidationsRequest, opts ...grpc.CallOption) (*ListConsolidationsResponse, error)
+	// lncli: `wallet consolidation cancel`
+	// CancelConsolidation cancels an active consolidation schedule.
+	CancelConsolidation(ctx context.Context, in *CancelConsolidationRequest, opts ...grpc.CallOption) (*CancelConsolidationResponse, error)
+	// lncli: `wallet payjoin newuri`
+	// NewPayjoinUri creates a BIP 21 URI with a payjoin (BIP 78) endpoint for a
+	// new address of the wallet. When a sender posts its original transaction
+	// to the payjoin endpoint, the wallet contributes one of its own inputs to
+	// the payment. If a pending channel is specified, the inputs of the wallet
+	// also fund the channel with the payjoin transaction. The payjoin endpoint
+	// must be configured with the walletrpc.payjoinendpoint option.
+	NewPayjoinUri(ctx context.Context, in *NewPayjoinUriRequest, opts ...grpc.CallOption) (*NewPayjoinUriResponse, error)
+	// lncli: `wallet named create`
+	// CreateNamedWallet creates a new named on-chain wallet next to the main
+	// wallet of lnd and unlocks it. Each named wallet has its own aezeed seed,
+	// optionally protected with a passphrase, and its own password. If no
+	// mnemonic is given, a new seed is generated and returned. Named wallets can
+	// be selected in ListUnspent, NextAddr, SendOutputs and FinalizePsbt and fund
+	// channels with the funding_wallet field of OpenChannel. Named wallets are
+	// only supported with the bolt database backend.
+	CreateNamedWallet(ctx context.Context, in *CreateNamedWalletRequest, opts ...grpc.CallOption) (*CreateNamedWalletResponse, error)
+	// lncli: `wallet named unlock`
+	// UnlockNamedWallet unlocks a named wallet with its password. Named wallets
+	// are locked when lnd starts.
+	UnlockNamedWallet(ctx context.Context, in *UnlockNamedWalletRequest, opts ...grpc.CallOption) (*UnlockNamedWalletResponse, error)
+	// lncli: `wallet named lock`
+	// LockNamedWallet locks an unlocked named wallet. Its funds can't be spent
+	// until it is unlocked again.
+	LockNamedWallet(ctx context.Context, in *LockNamedWalletRequest, opts ...grpc.CallOption) (*LockNamedWalletResponse, error)
+	// lncli: `wallet named list`
+	// ListNamedWallets returns all named wallets and whether they are unlocked.
+	ListNamedWallets(ctx context.Context, in *ListNamedWalletsRequest, opts ...grpc.CallOption) (*ListNamedWalletsResponse, error)
 	// lncli: `wallet psbt fund`
 	// FundPsbt creates a fully populated PSBT that contains enough inputs to fund
 	// the outputs specified in the template. There are three ways a user can
@@ -246,6 +293,12 @@ type WalletKitClient interface {
 	// publishing the transaction) or to unlock/release the locked UTXOs in case of
 	// an error on the caller's side.
 	FundPsbt(ctx context.Context, in *FundPsbtRequest, opts ...grpc.CallOption) (*FundPsbtResponse, error)
+	// lncli: `wallet selectcoins`
+	// SelectCoins performs a dry run of the coin selection for a transaction
+	// paying to the given outputs. It returns the inputs that would be spent
+	// together with the fee, the change and the waste of the selection. No coins
+	// are locked and no change address is generated.
+	SelectCoins(ctx context.Context, in *SelectCoinsRequest, opts ...grpc.CallOption) (*SelectCoinsResponse, error)
 	// SignPsbt expects a partial transaction with all inputs and outputs fully
 	// declared and tries to sign all unsigned inputs that have all required fields
 	// (UTXO information, BIP32 derivation information, witness or sig scripts)
@@ -497,6 +550,78 @@ func (c *walletKitClient) LabelTransaction(ctx context.Context, in *LabelTransac
 	return out, nil
 }
 
+func (c *walletKitClient) ScheduleConsolidation(ctx context.Context, in *ScheduleConsolidationRequest, opts ...grpc.CallOption) (*ScheduleConsolidationResponse, error) {
+	out := new(ScheduleConsolidationResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/ScheduleConsolidation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) ListConsolidations(ctx context.Context, in *ListConsolidationsRequest, opts ...grpc.CallOption) (*ListConsolidationsResponse, error) {
+	out := new(ListConsolidationsResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/ListConsolidations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) CancelConsolidation(ctx context.Context, in *CancelConsolidationRequest, opts ...grpc.CallOption) (*CancelConsolidationResponse, error) {
+	out := new(CancelConsolidationResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/CancelConsolidation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) NewPayjoinUri(ctx context.Context, in *NewPayjoinUriRequest, opts ...grpc.CallOption) (*NewPayjoinUriResponse, error) {
+	out := new(NewPayjoinUriResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/NewPayjoinUri", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) CreateNamedWallet(ctx context.Context, in *CreateNamedWalletRequest, opts ...grpc.CallOption) (*CreateNamedWalletResponse, error) {
+	out := new(CreateNamedWalletResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/CreateNamedWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) UnlockNamedWallet(ctx context.Context, in *UnlockNamedWalletRequest, opts ...grpc.CallOption) (*UnlockNamedWalletResponse, error) {
+	out := new(UnlockNamedWalletResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/UnlockNamedWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) LockNamedWallet(ctx context.Context, in *LockNamedWalletRequest, opts ...grpc.CallOption) (*LockNamedWalletResponse, error) {
+	out := new(LockNamedWalletResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/LockNamedWallet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *walletKitClient) ListNamedWallets(ctx context.Context, in *ListNamedWalletsRequest, opts ...grpc.CallOption) (*ListNamedWalletsResponse, error) {
+	out := new(ListNamedWalletsResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/ListNamedWallets", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *walletKitClient) FundPsbt(ctx context.Context, in *FundPsbtRequest, opts ...grpc.CallOption) (*FundPsbtResponse, error) {
 	out := new(FundPsbtResponse)
 	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/FundPsbt", in, out, opts...)
@@ -506,6 +631,15 @@ func (c *walletKitClient) FundPsbt(ctx context.Context, in *FundPsbtRequest, opt
 	return out, nil
 }
 
+func (c *walletKitClient) SelectCoins(ctx context.Context, in *SelectCoinsRequest, opts ...grpc.CallOption) (*SelectCoinsResponse, error) {
+	out := new(SelectCoinsResponse)
+	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/SelectCoins", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *walletKitClient) SignPsbt(ctx context.Context, in *SignPsbtRequest, opts ...grpc.CallOption) (*SignPsbtResponse, error) {
 	out := new(SignPsbtResponse)
 	err := c.cc.Invoke(ctx, "/walletrpc.WalletKit/SignPsbt", in, out, opts...)
@@ -724,6 +858,53 @@ type WalletKitServer interface {
 	// overwrite the exiting transaction label. Labels must not be empty, and
 	// cannot exceed 500 characters.
 	LabelTransaction(context.Context, *LabelTransactionRequest) (*LabelTransactionResponse, error)
+	// lncli: `wallet consolidation schedule`
+	// ScheduleConsolidation schedules the consolidation of small UTXOs of an
+	// account. Whenever a new block is found and the estimated fee rate is at or
+	// below the given ceiling, all eligible UTXOs are merged into a single new
+	// output of the same account, as long as there are at least the given number
+	// of them. Leased UTXOs are never spent, and no consolidation is published
+	// that would reduce the balance of the default account below the reserve
+	// required for anchor channels. Consolidation transactions are labelled with
+	// the ID of their schedule. Schedules are only held in memory: a schedule
+	// stays active until it is canceled or lnd is restarted, after which it must
+	// be created again.
+	ScheduleConsolidation(context.Context, *ScheduleConsolidationRequest) (*ScheduleConsolidationResponse, error)
+	// lncli: `wallet consolidation list`
+	// ListConsolidations returns the active consolidation schedules and the
+	// consolidation transactions the wallet has published.
+	ListConsolidations(context.Context, *ListConsolidationsRequest) (*ListConsolidationsResponse, error)
+	// lncli: `wallet consolidation cancel`
+	// CancelConsolidation cancels an active consolidation schedule.
+	CancelConsolidation(context.Context, *CancelConsolidationRequest) (*CancelConsolidationResponse, error)
+	// lncli: `wallet payjoin newuri`
+	// NewPayjoinUri creates a BIP 21 URI with a payjoin (BIP 78) endpoint for a
+	// new address of the wallet. When a sender posts its original transaction
+	// to the payjoin endpoint, the wallet contributes one of its own inputs to
+	// the payment. If a pending channel is specified, the inputs of the wallet
+	// also fund the channel with the payjoin transaction. The payjoin endpoint
+	// must be configured with the walletrpc.payjoinendpoint option.
+	NewPayjoinUri(context.Context, *NewPayjoinUriRequest) (*NewPayjoinUriResponse, error)
+	// lncli: `wallet named create`
+	// CreateNamedWallet creates a new named on-chain wallet next to the main
+	// wallet of lnd and unlocks it. Each named wallet has its own aezeed seed,
+	// optionally protected with a passphrase, and its own password. If no
+	// mnemonic is given, a new seed is generated and returned. Named wallets can
+	// be selected in ListUnspent, NextAddr, SendOutputs and FinalizePsbt and fund
+	// channels with the funding_wallet field of OpenChannel. Named wallets are
+	// only supported with the bolt database backend.
+	CreateNamedWallet(context.Context, *CreateNamedWalletRequest) (*CreateNamedWalletResponse, error)
+	// lncli: `wallet named unlock`
+	// UnlockNamedWallet unlocks a named wallet with its password. Named wallets
+	// are locked when lnd starts.
+	UnlockNamedWallet(context.Context, *UnlockNamedWalletRequest) (*UnlockNamedWalletResponse, error)
+	// lncli: `wallet named lock`
+	// LockNamedWallet locks an unlocked named wallet. Its funds can't be spent
+	// until it is unlocked again.
+	LockNamedWallet(context.Context, *LockNamedWalletRequest) (*LockNamedWalletResponse, error)
+	// lncli: `wallet named list`
+	// ListNamedWallets returns all named wallets and whether they are unlocked.
+	ListNamedWallets(context.Context, *ListNamedWalletsRequest) (*ListNamedWalletsResponse, error)
 	// lncli: `wallet psbt fund`
 	// FundPsbt creates a fully populated PSBT that contains enough inputs to fund
 	// the outputs specified in the template. There are three ways a user can
@@ -754,6 +935,12 @@ type WalletKitServer interface {
 	// publishing the transaction) or to unlock/release the locked UTXOs in case of
 	// an error on the caller's side.
 	FundPsbt(context.Context, *FundPsbtRequest) (*FundPsbtResponse, error)
+	// lncli: `wallet selectcoins`
+	// SelectCoins performs a dry run of the coin selection for a transaction
+	// paying to the given outputs. It returns the inputs that would be spent
+	// together with the fee, the change and the waste of the selection. No coins
+	// are locked and no change address is generated.
+	SelectCoins(context.Context, *SelectCoinsRequest) (*SelectCoinsResponse, error)
 	// SignPsbt expects a partial transaction with all inputs and outputs fully
 	// declared and tries to sign all unsigned inputs that have all required fields
 	// (UTXO information, BIP32 derivation information, witness or sig scripts)
@@ -858,9 +1045,36 @@ func (UnimplementedWalletKitServer) ListSweeps(context.Context, *ListSweepsReque
 func (UnimplementedWalletKitServer) LabelTransaction(context.Context, *LabelTransactionRequest) (*LabelTransactionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method LabelTransaction not implemented")
 }
+func (UnimplementedWalletKitServer) ScheduleConsolidation(context.Context, *ScheduleConsolidationRequest) (*ScheduleConsolidationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScheduleConsolidation not implemented")
+}
+func (UnimplementedWalletKitServer) ListConsolidations(context.Context, *ListConsolidationsRequest) (*ListConsolidationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListConsolidations not implemented")
+}
+func (UnimplementedWalletKitServer) CancelConsolidation(context.Context, *CancelConsolidationRequest) (*CancelConsolidationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelConsolidation not implemented")
+}
+func (UnimplementedWalletKitServer) NewPayjoinUri(context.Context, *NewPayjoinUriRequest) (*NewPayjoinUriResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NewPayjoinUri not implemented")
+}
+func (UnimplementedWalletKitServer) CreateNamedWallet(context.Context, *CreateNamedWalletRequest) (*CreateNamedWalletResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateNamedWallet not implemented")
+}
+func (UnimplementedWalletKitServer) UnlockNamedWallet(context.Context, *UnlockNamedWalletRequest) (*UnlockNamedWalletResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnlockNamedWallet not implemented")
+}
+func (UnimplementedWalletKitServer) LockNamedWallet(context.Context, *LockNamedWalletRequest) (*LockNamedWalletResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LockNamedWallet not implemented")
+}
+func (UnimplementedWalletKitServer) ListNamedWallets(context.Context, *ListNamedWalletsRequest) (*ListNamedWalletsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNamedWallets not implemented")
+}
 func (UnimplementedWalletKitServer) FundPsbt(context.Context, *FundPsbtRequest) (*FundPsbtResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method FundPsbt not implemented")
 }
+func (UnimplementedWalletKitServer) SelectCoins(context.Context, *SelectCoinsRequest) (*SelectCoinsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SelectCoins not implemented")
+}
 func (UnimplementedWalletKitServer) SignPsbt(context.Context, *SignPsbtRequest) (*SignPsbtResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SignPsbt not implemented")
 }
@@ -1312,6 +1526,150 @@ func _WalletKit_LabelTransaction_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WalletKit_ScheduleConsolidation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScheduleConsolidationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).ScheduleConsolidation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/ScheduleConsolidation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).ScheduleConsolidation(ctx, req.(*ScheduleConsolidationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_ListConsolidations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListConsolidationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).ListConsolidations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/ListConsolidations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).ListConsolidations(ctx, req.(*ListConsolidationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_CancelConsolidation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelConsolidationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).CancelConsolidation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/CancelConsolidation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).CancelConsolidation(ctx, req.(*CancelConsolidationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_NewPayjoinUri_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewPayjoinUriRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).NewPayjoinUri(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/NewPayjoinUri",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).NewPayjoinUri(ctx, req.(*NewPayjoinUriRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_CreateNamedWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateNamedWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).CreateNamedWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/CreateNamedWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).CreateNamedWallet(ctx, req.(*CreateNamedWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_UnlockNamedWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockNamedWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).UnlockNamedWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/UnlockNamedWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).UnlockNamedWallet(ctx, req.(*UnlockNamedWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_LockNamedWallet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LockNamedWalletRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).LockNamedWallet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/LockNamedWallet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).LockNamedWallet(ctx, req.(*LockNamedWalletRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _WalletKit_ListNamedWallets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNamedWalletsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).ListNamedWallets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/ListNamedWallets",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).ListNamedWallets(ctx, req.(*ListNamedWalletsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _WalletKit_FundPsbt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(FundPsbtRequest)
 	if err := dec(in); err != nil {
@@ -1330,6 +1688,24 @@ func _WalletKit_FundPsbt_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _WalletKit_SelectCoins_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SelectCoinsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WalletKitServer).SelectCoins(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/walletrpc.WalletKit/SelectCoins",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WalletKitServer).SelectCoins(ctx, req.(*SelectCoinsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _WalletKit_SignPsbt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SignPsbtRequest)
 	if err := dec(in); err != nil {
@@ -1469,10 +1845,46 @@ var WalletKit_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "LabelTransaction",
 			Handler:    _WalletKit_LabelTransaction_Handler,
 		},
+		{
+			MethodName: "ScheduleConsolidation",
+			Handler:    _WalletKit_ScheduleConsolidation_Handler,
+		},
+		{
+			MethodName: "ListConsolidations",
+			Handler:    _WalletKit_ListConsolidations_Handler,
+		},
+		{
+			MethodName: "CancelConsolidation",
+			Handler:    _WalletKit_CancelConsolidation_Handler,
+		},
+		{
+			MethodName: "NewPayjoinUri",
+			Handler:    _WalletKit_NewPayjoinUri_Handler,
+		},
+		{
+			MethodName: "CreateNamedWallet",
+			Handler:    _WalletKit_CreateNamedWallet_Handler,
+		},
+		{
+			MethodName: "UnlockNamedWallet",
+			Handler:    _WalletKit_UnlockNamedWallet_Handler,
+		},
+		{
+			MethodName: "LockNamedWallet",
+			Handler:    _WalletKit_LockNamedWallet_Handler,
+		},
+		{
+			MethodName: "ListNamedWallets",
+			Handler:    _WalletKit_ListNamedWallets_Handler,
+		},
 		{
 			MethodName: "FundPsbt",
 			Handler:    _WalletKit_FundPsbt_Handler,
 		},
+		{
+			MethodName: "SelectCoins",
+			Handler:    _WalletKit_SelectCoins_Handler,
+		},
 		{
 			MethodName: "SignPsbt",
 			Handler:    _WalletKit_SignPsbt_Handler,
@@ -12,9 +12,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -40,6 +44,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
 	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/lightningnetwork/lnd/payjoin"
 	"github.com/lightningnetwork/lnd/sweep"
 	"google.golang.org/grpc"
 	"gopkg.in/macaroon-bakery.v2/bakery"
@@ -113,6 +118,41 @@ var (
 			Entity: "onchain",
 			Action: "write",
 		}},
+		"/walletrpc.WalletKit/ScheduleConsolidation": {{
+			Entity: "onchain",
+			Action: "write",
+		}},
+		"/walletrpc.WalletKit/ListConsolidations": {{
+			Entity: "onchain",
+			Action: "read",
+		}},
+		"/walletrpc.WalletKit/CancelConsolidation": {{
+			Entity: "onchain",
+			Action: "write",
+		}},
+		"/walletrpc.WalletKit/NewPayjoinUri": {{
+			Entity: "address",
+			Action: "write",
+		}, {
+			Entity: "onchain",
+			Action: "write",
+		}},
+		"/walletrpc.WalletKit/CreateNamedWallet": {{
+			Entity: "onchain",
+			Action: "write",
+		}},
+		"/walletrpc.WalletKit/UnlockNamedWallet": {{
+			Entity: "onchain",
+			Action: "write",
+		}},
+		"/walletrpc.WalletKit/LockNamedWallet": {{
+			Entity: "onchain",
+			Action: "write",
+		}},
+		"/walletrpc.WalletKit/ListNamedWallets": {{
+			Entity: "onchain",
+			Action: "read",
+		}},
 		"/walletrpc.WalletKit/LeaseOutput": {{
 			Entity: "onchain",
 			Action: "write",
@@ -145,6 +185,10 @@ var (
 			Entity: "onchain",
 			Action: "write",
 		}},
+		"/walletrpc.WalletKit/SelectCoins": {{
+			Entity: "onchain",
+			Action: "read",
+		}},
 		"/walletrpc.WalletKit/SignPsbt": {{
 			Entity: "onchain",
 			Action: "write",
@@ -237,6 +281,11 @@ var (
 // have the necessary dependencies to populate the actual sub-server.
 type ServerShell struct {
 	WalletKitServer
+
+	// payjoinMtx guards the payjoin receiver, which is only available once
+	// the sub-server is created, while the REST path is registered before.
+	payjoinMtx      sync.RWMutex
+	payjoinReceiver *payjoin.Receiver
 }
 
 // WalletKit is a sub-RPC server that exposes a tool kit which allows clients
@@ -247,6 +296,17 @@ type WalletKit struct {
 	UnimplementedWalletKitServer
 
 	cfg *Config
+
+	// consolidator runs the UTXO consolidation schedules.
+	consolidator *consolidator
+
+	// payjoin is the receiver of payjoin requests. It is nil if no payjoin
+	// endpoint is configured.
+	payjoin *payjoin.Receiver
+
+	// payjoinEndpoint is the URL of the payjoin endpoint that is added to
+	// payjoin URIs.
+	payjoinEndpoint *url.URL
 }
 
 // A compile time check to ensure that WalletKit fully implements the
@@ -295,7 +355,17 @@ func New(cfg *Config) (*WalletKit, lnrpc.MacaroonPerms, error) {
 	}
 
 	walletKit := &WalletKit{
-		cfg: cfg,
+		cfg:          cfg,
+		consolidator: newConsolidator(cfg),
+	}
+
+	if cfg.PayjoinEndpoint != "" {
+		endpoint, err := payjoin.ParseEndpoint(cfg.PayjoinEndpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		walletKit.payjoinEndpoint = endpoint
+		walletKit.payjoin = newPayjoinReceiver(cfg)
 	}
 
 	return walletKit, macPermissions, nil
@@ -305,6 +375,8 @@ func New(cfg *Config) (*WalletKit, lnrpc.MacaroonPerms, error) {
 //
 // NOTE: This is part of the lnrpc.SubServer interface.
 func (w *WalletKit) Start() error {
+	w.consolidator.Start()
+
 	return nil
 }
 
@@ -312,6 +384,12 @@ func (w *WalletKit) Start() error {
 //
 // NOTE: This is part of the lnrpc.SubServer interface.
 func (w *WalletKit) Stop() error {
+	w.consolidator.Stop()
+
+	if w.payjoin != nil {
+		w.payjoin.Stop()
+	}
+
 	return nil
 }
 
@@ -356,6 +434,15 @@ func (r *ServerShell) RegisterWithRestServer(ctx context.Context,
 		return err
 	}
 
+	// Payjoin senders post their original PSBTs to a plain HTTP endpoint
+	// without macaroon authentication.
+	err = mux.HandlePath(http.MethodPost, payjoinPath, r.servePayjoin)
+	if err != nil {
+		log.Errorf("Could not register payjoin endpoint with root "+
+			"REST server: %v", err)
+		return err
+	}
+
 	log.Debugf("WalletKit REST server successfully registered with " +
 		"root REST server")
 	return nil
@@ -377,6 +464,11 @@ func (r *ServerShell) CreateSubServer(configRegistry lnrpc.SubServerConfigDispat
 	}
 
 	r.WalletKitServer = subServer
+
+	r.payjoinMtx.Lock()
+	r.payjoinReceiver = subServer.payjoin
+	r.payjoinMtx.Unlock()
+
 	return subServer, macPermissions, nil
 }
 
@@ -417,15 +509,20 @@ func (w *WalletKit) ListUnspent(ctx context.Context,
 		return nil, err
 	}
 
-	// With our arguments validated, we'll query the internal wallet for
+	wallet, coinSelectionLocker, err := w.selectWallet(req.Wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	// With our arguments validated, we'll query the selected wallet for
 	// the set of UTXOs that match our query.
 	//
-	// We'll acquire the global coin selection lock to ensure there aren't
-	// any other concurrent processes attempting to lock any UTXOs which may
-	// be shown available to us.
+	// We'll acquire the coin selection lock of the wallet to ensure there
+	// aren't any other concurrent processes attempting to lock any UTXOs
+	// which may be shown available to us.
 	var utxos []*lnwallet.Utxo
-	err = w.cfg.CoinSelectionLocker.WithCoinSelectLock(func() error {
-		utxos, err = w.cfg.Wallet.ListUnspentWitness(
+	err = coinSelectionLocker.WithCoinSelectLock(func() error {
+		utxos, err = wallet.ListUnspentWitness(
 			minConfs, maxConfs, req.Account,
 		)
 
@@ -612,7 +709,12 @@ func (w *WalletKit) NextAddr(ctx context.Context,
 		addrType = lnwallet.TaprootPubkey
 	}
 
-	addr, err := w.cfg.Wallet.NewAddress(addrType, req.Change, account)
+	wallet, _, err := w.selectWallet(req.Wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := wallet.NewAddress(addrType, req.Change, account)
 	if err != nil {
 		return nil, err
 	}
@@ -772,26 +874,38 @@ func (w *WalletKit) SendOutputs(ctx context.Context,
 		return nil, err
 	}
 
-	// Before sending out funds we need to ensure that the remainder of our
-	// wallet funds would cover for the anchor reserve requirement. We'll
-	// also take unconfirmed funds into account.
-	walletBalance, err := w.cfg.Wallet.ConfirmedBalance(
-		0, lnwallet.DefaultAccountName,
-	)
+	wallet, _, err := w.selectWallet(req.Wallet)
 	if err != nil {
 		return nil, err
 	}
 
-	// We'll get the currently required reserve amount.
-	reserve, err := w.RequiredReserve(ctx, &RequiredReserveRequest{})
-	if err != nil {
-		return nil, err
-	}
+	// Before sending out funds we need to ensure that the remainder of our
+	// wallet funds would cover for the anchor reserve requirement. We'll
+	// also take unconfirmed funds into account. The reserve is only kept
+	// in the main wallet, which is used to fee bump anchor channels.
+	if wallet == w.cfg.Wallet {
+		walletBalance, err := w.cfg.Wallet.ConfirmedBalance(
+			0, lnwallet.DefaultAccountName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		// We'll get the currently required reserve amount.
+		reserve, err := w.RequiredReserve(
+			ctx, &RequiredReserveRequest{},
+		)
+		if err != nil {
+			return nil, err
+		}
 
-	// Then we check if our current wallet balance undershoots the required
-	// reserve if we'd send out the outputs specified in the request.
-	if int64(walletBalance)-totalOutputValue < reserve.RequiredReserve {
-		return nil, ErrInsufficientReserve
+		// Then we check if our current wallet balance undershoots the
+		// required reserve if we'd send out the outputs specified in
+		// the request.
+		balanceLeft := int64(walletBalance) - totalOutputValue
+		if balanceLeft < reserve.RequiredReserve {
+			return nil, ErrInsufficientReserve
+		}
 	}
 
 	label, err := labels.ValidateAPI(req.Label)
@@ -809,7 +923,7 @@ func (w *WalletKit) SendOutputs(ctx context.Context,
 	// Now that we have the outputs mapped and checked for the reserve
 	// requirement, we can request that the wallet attempts to create this
 	// transaction.
-	tx, err := w.cfg.Wallet.SendOutputs(
+	tx, err := wallet.SendOutputs(
 		outputsToCreate, chainfee.SatPerKWeight(req.SatPerKw), minConfs,
 		label, coinSelectionStrategy,
 	)
@@ -841,15 +955,55 @@ func (w *WalletKit) EstimateFee(ctx context.Context,
 			"than 1")
 	}
 
-	satPerKw, err := w.cfg.FeeEstimator.EstimateFeePerKW(
+	estimator, ok := w.cfg.FeeEstimator.(chainfee.DetailedEstimator)
+	if !req.Detail || !ok {
+		satPerKw, err := w.cfg.FeeEstimator.EstimateFeePerKW(
+			uint32(req.ConfTarget),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp := &EstimateFeeResponse{
+			SatPerKw: int64(satPerKw),
+		}
+
+		// If the fee estimator doesn't combine several sources, its
+		// own estimate is the only answer.
+		if req.Detail {
+			resp.Sources = []*FeeSourceEstimate{{
+				Name:     "default",
+				SatPerKw: int64(satPerKw),
+			}}
+		}
+
+		return resp, nil
+	}
+
+	satPerKw, estimates, err := estimator.EstimateFeeDetails(
 		uint32(req.ConfTarget),
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	sources := make([]*FeeSourceEstimate, 0, len(estimates))
+	for _, estimate := range estimates {
+		source := &FeeSourceEstimate{
+			Name:       estimate.Name,
+			SatPerKw:   int64(estimate.FeeRate),
+			AgeSeconds: int64(estimate.Age.Seconds()),
+		}
+		if estimate.Err != nil {
+			source.Error = estimate.Err.Error()
+		}
+
+		sources = append(sources, source)
+	}
+
 	return &EstimateFeeResponse{
 		SatPerKw: int64(satPerKw),
+		Sources:  sources,
 	}, nil
 }
 
@@ -1147,6 +1301,247 @@ func (w *WalletKit) LabelTransaction(ctx context.Context,
 	return &LabelTransactionResponse{}, err
 }
 
+// ScheduleConsolidation schedules the consolidation of small UTXOs of an
+// account whenever the fee rate is at or below the given ceiling.
+func (w *WalletKit) ScheduleConsolidation(ctx context.Context,
+	req *ScheduleConsolidationRequest) (*ScheduleConsolidationResponse,
+	error) {
+
+	if req.MaxSatPerVbyte == 0 {
+		return nil, fmt.Errorf("max_sat_per_vbyte must be set")
+	}
+
+	// Consolidating a single UTXO would only pay fees.
+	if req.MinUtxos < 2 {
+		return nil, fmt.Errorf("min_utxos must be at least 2")
+	}
+
+	if req.MaxUtxoValueSat < 0 {
+		return nil, fmt.Errorf("max_utxo_value_sat must not be " +
+			"negative")
+	}
+
+	account := req.Account
+	if account == "" {
+		account = lnwallet.DefaultAccountName
+	}
+	accounts, err := w.cfg.Wallet.ListAccounts(account, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("unknown account %v", account)
+	}
+
+	confTarget := req.TargetConf
+	if confTarget == 0 {
+		confTarget = defaultConsolidationConfTarget
+	}
+
+	maxInputs := req.MaxInputs
+	if maxInputs == 0 {
+		maxInputs = defaultConsolidationMaxInputs
+	}
+	if maxInputs < req.MinUtxos {
+		return nil, fmt.Errorf("max_inputs must not be below min_utxos")
+	}
+
+	maxFeeRate := chainfee.SatPerKVByte(
+		req.MaxSatPerVbyte * 1000,
+	).FeePerKWeight()
+
+	id := w.consolidator.addSchedule(&consolidationSchedule{
+		maxFeeRate:   maxFeeRate,
+		confTarget:   confTarget,
+		minUtxos:     req.MinUtxos,
+		maxInputs:    maxInputs,
+		maxUtxoValue: btcutil.Amount(req.MaxUtxoValueSat),
+		account:      account,
+		labelFilter:  req.LabelFilter,
+	})
+
+	log.Infof("Scheduled consolidation %d of account %v at fee rates up "+
+		"to %v, the schedule is not persisted and is removed on "+
+		"restart", id, account, maxFeeRate)
+
+	// Schedules are only held in memory by the consolidator, which we
+	// let the caller know so they can create it again after a restart.
+	return &ScheduleConsolidationResponse{
+		ScheduleId: id,
+		Persistent: false,
+	}, nil
+}
+
+// ListConsolidations returns the active consolidation schedules and the
+// consolidation transactions the wallet has published.
+func (w *WalletKit) ListConsolidations(ctx context.Context,
+	_ *ListConsolidationsRequest) (*ListConsolidationsResponse, error) {
+
+	schedules := w.consolidator.listSchedules()
+	rpcSchedules := make([]*ConsolidationSchedule, 0, len(schedules))
+	for _, s := range schedules {
+		rpcSchedule := &ConsolidationSchedule{
+			ScheduleId: s.id,
+			MaxSatPerVbyte: uint64(
+				s.maxFeeRate.FeePerKVByte() / 1000,
+			),
+			MinUtxos:          s.minUtxos,
+			Account:           s.account,
+			LabelFilter:       s.labelFilter,
+			TargetConf:        s.confTarget,
+			MaxUtxoValueSat:   int64(s.maxUtxoValue),
+			MaxInputs:         s.maxInputs,
+			NumConsolidations: s.numConsolidations,
+		}
+		if s.lastTxid != nil {
+			rpcSchedule.LastTxid = s.lastTxid.String()
+		}
+		if s.lastErr != nil {
+			rpcSchedule.LastError = s.lastErr.Error()
+		}
+
+		rpcSchedules = append(rpcSchedules, rpcSchedule)
+	}
+
+	// The history of the consolidations is recorded in the labels of their
+	// transactions.
+	transactions, err := w.cfg.Wallet.ListTransactionDetails(
+		0, btcwallet.UnconfirmedHeight, "",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	labelPrefix := labels.MakeLabel(labels.LabelTypeConsolidation, nil)
+	var consolidations []*lnwallet.TransactionDetail
+	for _, tx := range transactions {
+		if strings.HasPrefix(tx.Label, labelPrefix) {
+			consolidations = append(consolidations, tx)
+		}
+	}
+
+	return &ListConsolidationsResponse{
+		Schedules:    rpcSchedules,
+		Transactions: lnrpc.RPCTransactionDetails(consolidations),
+	}, nil
+}
+
+// CancelConsolidation cancels an active consolidation schedule.
+func (w *WalletKit) CancelConsolidation(ctx context.Context,
+	req *CancelConsolidationRequest) (*CancelConsolidationResponse, error) {
+
+	if err := w.consolidator.cancelSchedule(req.ScheduleId); err != nil {
+		return nil, fmt.Errorf("unable to cancel schedule %d: %w",
+			req.ScheduleId, err)
+	}
+
+	log.Infof("Canceled consolidation %d", req.ScheduleId)
+
+	return &CancelConsolidationResponse{}, nil
+}
+
+// NewPayjoinUri creates a BIP 21 URI with a payjoin endpoint for a new address
+// of the wallet.
+func (w *WalletKit) NewPayjoinUri(_ context.Context,
+	req *NewPayjoinUriRequest) (*NewPayjoinUriResponse, error) {
+
+	if w.payjoin == nil {
+		return nil, fmt.Errorf("payjoin endpoint not configured")
+	}
+	if req.AmountSat < 0 {
+		return nil, fmt.Errorf("amount must not be negative")
+	}
+
+	account := lnwallet.DefaultAccountName
+	if req.Account != "" {
+		account = req.Account
+	}
+
+	addrType := lnwallet.WitnessPubKey
+	switch req.Type {
+	case AddressType_NESTED_WITNESS_PUBKEY_HASH:
+		addrType = lnwallet.NestedWitnessPubKey
+
+	case AddressType_HYBRID_NESTED_WITNESS_PUBKEY_HASH:
+		return nil, fmt.Errorf("invalid address type for payjoin "+
+			"URI: %v", req.Type)
+
+	case AddressType_TAPROOT_PUBKEY:
+		addrType = lnwallet.TaprootPubkey
+	}
+
+	paymentReq := &payjoin.PaymentRequest{
+		Amount:  btcutil.Amount(req.AmountSat),
+		Account: account,
+		Label:   req.Label,
+	}
+
+	if len(req.PendingChanId) > 0 {
+		if len(req.PendingChanId) != 32 {
+			return nil, fmt.Errorf("pending channel ID must be " +
+				"32 bytes")
+		}
+		if req.FundingAmountSat <= 0 {
+			return nil, fmt.Errorf("funding amount must be " +
+				"positive")
+		}
+
+		fundingAddr, err := btcutil.DecodeAddress(
+			req.FundingAddress, w.cfg.ChainParams,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("invalid funding address: %w",
+				err)
+		}
+		fundingScript, err := txscript.PayToAddrScript(fundingAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		paymentReq.ChannelFunding = &payjoin.ChannelFunding{
+			Output: &wire.TxOut{
+				Value:    req.FundingAmountSat,
+				PkScript: fundingScript,
+			},
+		}
+		copy(
+			paymentReq.ChannelFunding.PendingChanID[:],
+			req.PendingChanId,
+		)
+	}
+
+	if req.ExpirySeconds > 0 {
+		paymentReq.Expiry = time.Now().Add(
+			time.Duration(req.ExpirySeconds) * time.Second,
+		)
+	}
+
+	addr, err := w.cfg.Wallet.NewAddress(addrType, false, account)
+	if err != nil {
+		return nil, err
+	}
+	paymentReq.Address = addr
+
+	if err := w.payjoin.AddRequest(paymentReq); err != nil {
+		return nil, err
+	}
+
+	// Our receiver never substitutes the payment output, so we tell the
+	// sender it doesn't have to allow it.
+	uri := &payjoin.URI{
+		Address:                   addr,
+		Amount:                    paymentReq.Amount,
+		Label:                     req.Label,
+		Endpoint:                  w.payjoinEndpoint,
+		DisableOutputSubstitution: true,
+	}
+
+	return &NewPayjoinUriResponse{
+		Uri:     uri.String(),
+		Address: addr.String(),
+	}, nil
+}
+
 // FundPsbt creates a fully populated PSBT that contains enough inputs to fund
 // the outputs specified in the template. There are three ways a user can
 // specify what we call the template (a list of inputs and outputs to use in the
@@ -1188,33 +1583,11 @@ func (w *WalletKit) FundPsbt(_ context.Context,
 	}
 
 	// Determine the desired transaction fee.
-	var feeSatPerKW chainfee.SatPerKWeight
-	switch {
-	// Estimate the fee by the target number of blocks to confirmation.
-	case req.GetTargetConf() != 0:
-		targetConf := req.GetTargetConf()
-		if targetConf < 2 {
-			return nil, fmt.Errorf("confirmation target must be " +
-				"greater than 1")
-		}
-
-		feeSatPerKW, err = w.cfg.FeeEstimator.EstimateFeePerKW(
-			targetConf,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("could not estimate fee: %w",
-				err)
-		}
-
-	// Convert the fee to sat/kW from the specified sat/vByte.
-	case req.GetSatPerVbyte() != 0:
-		feeSatPerKW = chainfee.SatPerKVByte(
-			req.GetSatPerVbyte() * 1000,
-		).FeePerKWeight()
-
-	default:
-		return nil, fmt.Errorf("fee definition missing, need to " +
-			"specify either target_conf or sat_per_vbyte")
+	feeSatPerKW, err := w.determineFeeRate(
+		req.GetTargetConf(), req.GetSatPerVbyte(),
+	)
+	if err != nil {
+		return nil, err
 	}
 
 	// Then, we'll extract the minimum number of confirmations that each
@@ -1335,33 +1708,9 @@ func (w *WalletKit) FundPsbt(_ context.Context,
 	case req.GetRaw() != nil:
 		tpl := req.GetRaw()
 
-		txOut := make([]*wire.TxOut, 0, len(tpl.Outputs))
-		for addrStr, amt := range tpl.Outputs {
-			addr, err := btcutil.DecodeAddress(
-				addrStr, w.cfg.ChainParams,
-			)
-			if err != nil {
-				return nil, fmt.Errorf("error parsing address "+
-					"%s for network %s: %v", addrStr,
-					w.cfg.ChainParams.Name, err)
-			}
-
-			if !addr.IsForNet(w.cfg.ChainParams) {
-				return nil, fmt.Errorf("address is not for %s",
-					w.cfg.ChainParams.Name)
-			}
-
-			pkScript, err := txscript.PayToAddrScript(addr)
-			if err != nil {
-				return nil, fmt.Errorf("error getting pk "+
-					"script for address %s: %w", addrStr,
-					err)
-			}
-
-			txOut = append(txOut, &wire.TxOut{
-				Value:    int64(amt),
-				PkScript: pkScript,
-			})
+		txOut, err := w.parseOutputs(tpl.Outputs)
+		if err != nil {
+			return nil, err
 		}
 
 		txIn := make([]*wire.OutPoint, len(tpl.Inputs))
@@ -1393,6 +1742,206 @@ func (w *WalletKit) FundPsbt(_ context.Context,
 	}
 }
 
+// determineFeeRate returns the fee rate for the given confirmation target or
+// the given fee rate in sat/vbyte, one of which must be specified.
+func (w *WalletKit) determineFeeRate(targetConf uint32,
+	satPerVbyte uint64) (chainfee.SatPerKWeight, error) {
+
+	switch {
+	// Estimate the fee by the target number of blocks to confirmation.
+	case targetConf != 0:
+		if targetConf < 2 {
+			return 0, fmt.Errorf("confirmation target must be " +
+				"greater than 1")
+		}
+
+		feeSatPerKW, err := w.cfg.FeeEstimator.EstimateFeePerKW(
+			targetConf,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("could not estimate fee: %w", err)
+		}
+
+		return feeSatPerKW, nil
+
+	// Convert the fee to sat/kW from the specified sat/vByte.
+	case satPerVbyte != 0:
+		return chainfee.SatPerKVByte(satPerVbyte * 1000).FeePerKWeight(),
+			nil
+
+	default:
+		return 0, fmt.Errorf("fee definition missing, need to " +
+			"specify either target_conf or sat_per_vbyte")
+	}
+}
+
+// parseOutputs converts a map of addresses to amounts into transaction
+// outputs.
+func (w *WalletKit) parseOutputs(outputs map[string]uint64) ([]*wire.TxOut,
+	error) {
+
+	txOut := make([]*wire.TxOut, 0, len(outputs))
+	for addrStr, amt := range outputs {
+		addr, err := btcutil.DecodeAddress(addrStr, w.cfg.ChainParams)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing address %s for "+
+				"network %s: %v", addrStr,
+				w.cfg.ChainParams.Name, err)
+		}
+
+		if !addr.IsForNet(w.cfg.ChainParams) {
+			return nil, fmt.Errorf("address is not for %s",
+				w.cfg.ChainParams.Name)
+		}
+
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("error getting pk script for "+
+				"address %s: %w", addrStr, err)
+		}
+
+		txOut = append(txOut, &wire.TxOut{
+			Value:    int64(amt),
+			PkScript: pkScript,
+		})
+	}
+
+	return txOut, nil
+}
+
+// SelectCoins performs a dry run of the coin selection for a transaction
+// paying to the given outputs. It returns the inputs that would be spent
+// together with the fee, the change and the waste of the selection. No coins
+// are locked and no change address is generated.
+func (w *WalletKit) SelectCoins(_ context.Context,
+	req *SelectCoinsRequest) (*SelectCoinsResponse, error) {
+
+	strategy, err := lnrpc.UnmarshallCoinSelectionStrategy(
+		req.CoinSelectionStrategy, w.cfg.CoinSelectionStrategy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	feeRate, err := w.determineFeeRate(
+		req.GetTargetConf(), req.GetSatPerVbyte(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	minConfs, err := lnrpc.ExtractMinConfs(
+		req.GetMinConfs(), req.GetSpendUnconfirmed(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	account := lnwallet.DefaultAccountName
+	if req.Account != "" {
+		account = req.Account
+	}
+
+	if len(req.Outputs) == 0 {
+		return nil, fmt.Errorf("no outputs specified")
+	}
+	outputs, err := w.parseOutputs(req.Outputs)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		outputSum btcutil.Amount
+		estimator input.TxWeightEstimator
+	)
+	for _, out := range outputs {
+		estimator.AddOutput(out.PkScript)
+		outputSum += btcutil.Amount(out.Value)
+	}
+
+	changeType := chanfunding.P2WKHChangeAddress
+	changeDustLimit := lnwallet.DustLimitForSize(input.P2WPKHSize)
+	if req.ChangeType == ChangeAddressType_CHANGE_ADDRESS_TYPE_P2TR {
+		changeType = chanfunding.P2TRChangeAddress
+		changeDustLimit = lnwallet.DustLimitForSize(input.P2TRSize)
+	}
+
+	var resp *SelectCoinsResponse
+	err = w.cfg.CoinSelectionLocker.WithCoinSelectLock(func() error {
+		utxos, err := w.cfg.Wallet.ListUnspentWitness(
+			minConfs, defaultMaxConf, account,
+		)
+		if err != nil {
+			return err
+		}
+
+		coins := make([]base.Coin, len(utxos))
+		utxoIndex := make(map[wire.OutPoint]*lnwallet.Utxo, len(utxos))
+		for i, utxo := range utxos {
+			coins[i] = base.Coin{
+				TxOut: wire.TxOut{
+					Value:    int64(utxo.Value),
+					PkScript: utxo.PkScript,
+				},
+				OutPoint: utxo.OutPoint,
+			}
+			utxoIndex[utxo.OutPoint] = utxo
+		}
+
+		strategy, err := lnwallet.StrategyWithCoinInfo(
+			w.cfg.Wallet, strategy, account,
+		)
+		if err != nil {
+			return err
+		}
+
+		selectedCoins, changeAmt, err := chanfunding.CoinSelect(
+			feeRate, outputSum, changeDustLimit, coins, strategy,
+			estimator, changeType,
+		)
+		if err != nil {
+			return fmt.Errorf("error selecting coins: %w", err)
+		}
+
+		waste, err := chanfunding.SelectionWaste(
+			selectedCoins, outputSum, changeAmt, feeRate,
+			chanfunding.DefaultLongTermFeeRate, estimator,
+			changeType,
+		)
+		if err != nil {
+			return err
+		}
+
+		var (
+			inputSum btcutil.Amount
+			selected = make([]*lnwallet.Utxo, len(selectedCoins))
+		)
+		for i, coin := range selectedCoins {
+			inputSum += btcutil.Amount(coin.Value)
+			selected[i] = utxoIndex[coin.OutPoint]
+		}
+
+		inputs, err := lnrpc.MarshalUtxos(selected, w.cfg.ChainParams)
+		if err != nil {
+			return err
+		}
+
+		resp = &SelectCoinsResponse{
+			Inputs:    inputs,
+			FeeSat:    int64(inputSum - outputSum - changeAmt),
+			ChangeSat: int64(changeAmt),
+			WasteSat:  int64(waste),
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 // fundPsbtInternalWallet uses the "old" PSBT funding method of the internal
 // wallet that does not allow specifying custom inputs while selecting coins.
 func (w *WalletKit) fundPsbtInternalWallet(account string,
@@ -1616,6 +2165,13 @@ func (w *WalletKit) fundPsbtCoinSelect(account string, changeIndex int32,
 			}
 		}
 
+		strategy, err := lnwallet.StrategyWithCoinInfo(
+			w.cfg.Wallet, strategy, account,
+		)
+		if err != nil {
+			return err
+		}
+
 		selectedCoins, changeAmount, err := chanfunding.CoinSelect(
 			feeRate, fundingAmount, changeDustLimit, coins,
 			strategy, estimator, changeType,
@@ -1884,6 +2440,12 @@ func (w *WalletKit) SignPsbt(_ context.Context, req *SignPsbtRequest) (
 		}
 	}
 
+	// Make sure the packet complies with the signer policy before we sign
+	// anything.
+	if err := w.checkSignerPolicy(packet); err != nil {
+		return nil, err
+	}
+
 	// Let the wallet do the heavy lifting. This will sign all inputs that
 	// we have the UTXO for. If some inputs can't be signed and don't have
 	// witness data attached, they will just be skipped.
@@ -1940,10 +2502,21 @@ func (w *WalletKit) FinalizePsbt(_ context.Context,
 		return nil, fmt.Errorf("PSBT is already fully signed")
 	}
 
+	// Make sure the packet complies with the signer policy before we sign
+	// anything.
+	if err := w.checkSignerPolicy(packet); err != nil {
+		return nil, err
+	}
+
+	wallet, _, err := w.selectWallet(req.Wallet)
+	if err != nil {
+		return nil, err
+	}
+
 	// Let the wallet do the heavy lifting. This will sign all inputs that
 	// we have the UTXO for. If some inputs can't be signed and don't have
 	// witness data attached, this will fail.
-	err = w.cfg.Wallet.FinalizePsbt(packet, account)
+	err = wallet.FinalizePsbt(packet, account)
 	if err != nil {
 		return nil, fmt.Errorf("error finalizing PSBT: %w", err)
 	}
@@ -1973,6 +2546,47 @@ func (w *WalletKit) FinalizePsbt(_ context.Context,
 	}, nil
 }
 
+// ownOutputVerifier is implemented by wallets that can verify that an output
+// pays to one of their keys, given the BIP32 derivation path of the key.
+type ownOutputVerifier interface {
+	// IsOwnOutput returns true if the output pays to the key with the
+	// given BIP32 derivation path.
+	IsOwnOutput(txOut *wire.TxOut, path []uint32) bool
+}
+
+// checkSignerPolicy checks the packet against the signer policy, if one is
+// configured. An output is considered to pay back to our wallet if the packet
+// contains a BIP32 derivation path for it that our wallet can verify.
+func (w *WalletKit) checkSignerPolicy(packet *psbt.Packet) error {
+	if w.cfg.SignerPolicy == nil {
+		return nil
+	}
+
+	verifier, canVerify := w.cfg.Wallet.(ownOutputVerifier)
+	ownOutput := func(idx int) bool {
+		if !canVerify || idx >= len(packet.Outputs) {
+			return false
+		}
+
+		txOut := packet.UnsignedTx.TxOut[idx]
+		out := &packet.Outputs[idx]
+		for _, derivation := range out.Bip32Derivation {
+			if verifier.IsOwnOutput(txOut, derivation.Bip32Path) {
+				return true
+			}
+		}
+		for _, derivation := range out.TaprootBip32Derivation {
+			if verifier.IsOwnOutput(txOut, derivation.Bip32Path) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return w.cfg.SignerPolicy.CheckPsbt(packet, ownOutput)
+}
+
 // marshalWalletAccount converts the properties of an account into its RPC
 // representation.
 func marshalWalletAccount(internalScope waddrmgr.KeyScope,
@@ -220,10 +220,21 @@ func (hn *HarnessNode) WaitUntilStarted() error {
 	})
 }
 
-// WaitUntilServerActive waits until the lnd daemon is fully started.
+// WaitUntilServerActive waits until the lnd daemon is fully started. A node
+// using remote signers is also fully started if none of them can be reached,
+// in which case its state is SIGNER_UNAVAILABLE instead of SERVER_ACTIVE.
 func (hn *HarnessNode) WaitUntilServerActive() error {
 	return hn.waitTillServerState(func(s lnrpc.WalletState) bool {
-		return s == lnrpc.WalletState_SERVER_ACTIVE
+		return s == lnrpc.WalletState_SERVER_ACTIVE ||
+			s == lnrpc.WalletState_SIGNER_UNAVAILABLE
+	})
+}
+
+// WaitUntilSignerUnavailable waits until the lnd daemon is fully started, but
+// none of its remote signers can be reached.
+func (hn *HarnessNode) WaitUntilSignerUnavailable() error {
+	return hn.waitTillServerState(func(s lnrpc.WalletState) bool {
+		return s == lnrpc.WalletState_SIGNER_UNAVAILABLE
 	})
 }
 
@@ -11,6 +11,8 @@ import (
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/lightninglabs/neutrino"
 	"github.com/lightninglabs/neutrino/headerfs"
+	"github.com/lightningnetwork/lnd/blockcache"
+	"github.com/lightningnetwork/lnd/electrum"
 	"github.com/lightningnetwork/lnd/lntypes"
 	"github.com/lightningnetwork/lnd/lnwallet"
 )
@@ -25,6 +27,18 @@ var (
 	ErrOutputNotFound = errors.New("target output was not found")
 )
 
+// NewBlockChainIO returns a BlockChainIO that queries the chain through the
+// given chain source. It is used for chain backends that don't operate a
+// wallet.
+func NewBlockChainIO(chainSource chain.Interface,
+	blockCache *blockcache.BlockCache) lnwallet.BlockChainIO {
+
+	return &BtcWallet{
+		chain:      chainSource,
+		blockCache: blockCache,
+	}
+}
+
 // GetBestBlock returns the current height and hash of the best known block
 // within the main chain.
 //
@@ -122,6 +136,21 @@ func (b *BtcWallet) GetUtxo(op *wire.OutPoint, pkScript []byte,
 			PkScript: pkScript,
 		}, nil
 
+	case *electrum.ChainClient:
+		txOut, err := backend.GetUtxo(op, pkScript)
+		switch {
+		case errors.Is(err, electrum.ErrOutputSpent):
+			return nil, ErrOutputSpent
+
+		case errors.Is(err, electrum.ErrOutputNotFound):
+			return nil, ErrOutputNotFound
+
+		case err != nil:
+			return nil, err
+		}
+
+		return txOut, nil
+
 	default:
 		return nil, fmt.Errorf("unknown backend")
 	}
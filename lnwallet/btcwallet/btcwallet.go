@@ -31,6 +31,7 @@ import (
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 )
 
 const (
@@ -995,10 +996,89 @@ func (b *BtcWallet) SendOutputs(outputs []*wire.TxOut,
 		return nil, lnwallet.ErrInvalidMinconf
 	}
 
-	return b.wallet.SendOutputs(
+	selectedUtxos, err := b.preselectCoins(
+		outputs, feeRate, minConfs, lnwallet.DefaultAccountName,
+		chanfunding.P2WKHChangeAddress, strategy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.wallet.SendOutputsWithInput(
 		outputs, nil, defaultAccount, minConfs, feeSatPerKB,
-		strategy, label,
+		strategy, label, selectedUtxos,
+	)
+}
+
+// preselectCoins selects the inputs for the given outputs with our own coin
+// selection if the strategy is a chanfunding.ContextualStrategy. The wallet
+// only uses a strategy to arrange the coins, so the selected coins need to be
+// passed to it as the inputs to spend. For any other strategy, no coins are
+// selected and the wallet performs the coin selection.
+//
+// NOTE: This method requires the global coin selection lock to be held.
+func (b *BtcWallet) preselectCoins(outputs []*wire.TxOut,
+	feeRate chainfee.SatPerKWeight, minConfs int32, accountName string,
+	changeType chanfunding.ChangeAddressType,
+	strategy base.CoinSelectionStrategy) ([]wire.OutPoint, error) {
+
+	if _, ok := strategy.(chanfunding.ContextualStrategy); !ok {
+		return nil, nil
+	}
+
+	strategy, err := lnwallet.StrategyWithCoinInfo(
+		b, strategy, accountName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos, err := b.ListUnspentWitness(
+		minConfs, math.MaxInt32, accountName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	coins := make([]base.Coin, len(utxos))
+	for i, utxo := range utxos {
+		coins[i] = base.Coin{
+			TxOut: wire.TxOut{
+				Value:    int64(utxo.Value),
+				PkScript: utxo.PkScript,
+			},
+			OutPoint: utxo.OutPoint,
+		}
+	}
+
+	var (
+		amt       btcutil.Amount
+		estimator input.TxWeightEstimator
+	)
+	for _, output := range outputs {
+		amt += btcutil.Amount(output.Value)
+		estimator.AddTxOutput(output)
+	}
+
+	dustLimit := lnwallet.DustLimitForSize(input.P2WPKHSize)
+	if changeType == chanfunding.P2TRChangeAddress {
+		dustLimit = lnwallet.DustLimitForSize(input.P2TRSize)
+	}
+
+	selected, _, err := chanfunding.CoinSelect(
+		feeRate, amt, dustLimit, coins, strategy, estimator,
+		changeType,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	outpoints := make([]wire.OutPoint, len(selected))
+	for i, coin := range selected {
+		outpoints[i] = coin.OutPoint
+	}
+
+	return outpoints, nil
 }
 
 // CreateSimpleTx creates a Bitcoin transaction paying to the specified
@@ -1047,9 +1127,17 @@ func (b *BtcWallet) CreateSimpleTx(outputs []*wire.TxOut,
 		}
 	}
 
+	selectedUtxos, err := b.preselectCoins(
+		outputs, feeRate, minConfs, lnwallet.DefaultAccountName,
+		chanfunding.P2WKHChangeAddress, strategy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	return b.wallet.CreateSimpleTx(
 		nil, defaultAccount, outputs, minConfs, feeSatPerKB,
-		strategy, dryRun,
+		strategy, dryRun, base.WithCustomSelectUtxos(selectedUtxos),
 	)
 }
 
@@ -1306,6 +1394,37 @@ func (b *BtcWallet) PublishTransaction(tx *wire.MsgTx, label string) error {
 	return mapRpcclientError(err)
 }
 
+// CheckMempoolAcceptance checks whether the transaction would be accepted to
+// the mempool of the chain backend without publishing it. Backends without a
+// mempool or without support for the mempool acceptance test accept every
+// transaction.
+func (b *BtcWallet) CheckMempoolAcceptance(tx *wire.MsgTx) error {
+	if b.chain.BackEnd() == "neutrino" {
+		return nil
+	}
+
+	results, err := b.chain.TestMempoolAccept([]*wire.MsgTx{tx}, 0)
+	if err != nil {
+		if errors.Is(err, rpcclient.ErrBackendVersion) {
+			return nil
+		}
+
+		return err
+	}
+
+	if len(results) != 1 {
+		return fmt.Errorf("expected 1 result from TestMempoolAccept, "+
+			"instead got %v", len(results))
+	}
+
+	if !results[0].Allowed {
+		err := rpcclient.MapRPCErr(errors.New(results[0].RejectReason))
+		return mapRpcclientError(err)
+	}
+
+	return nil
+}
+
 // LabelTransaction adds a label to a transaction. If the tx already
 // has a label, this call will fail unless the overwrite parameter
 // is set. Labels must not be empty, and they are limited to 500 chars.
@@ -19,6 +19,7 @@ import (
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 )
 
 var (
@@ -131,6 +132,28 @@ func (b *BtcWallet) FundPsbt(packet *psbt.Packet, minConfs int32,
 		opts = append(opts, wallet.WithCustomChangeScope(changeScope))
 	}
 
+	// If the packet doesn't contain any inputs, the wallet performs coin
+	// selection, unless we need to select the coins ourselves for the
+	// given strategy.
+	if len(packet.UnsignedTx.TxIn) == 0 {
+		changeType := chanfunding.P2WKHChangeAddress
+		if changeScope != nil &&
+			*changeScope == waddrmgr.KeyScopeBIP0086 {
+
+			changeType = chanfunding.P2TRChangeAddress
+		}
+
+		selectedUtxos, err := b.preselectCoins(
+			packet.UnsignedTx.TxOut, feeRate, minConfs,
+			accountName, changeType, strategy,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		opts = append(opts, wallet.WithCustomSelectUtxos(selectedUtxos))
+	}
+
 	// Let the wallet handle coin selection and/or fee estimation based on
 	// the partial TX information in the packet.
 	return b.wallet.FundPsbt(
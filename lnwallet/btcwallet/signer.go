@@ -1,6 +1,7 @@
 package btcwallet
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -201,6 +202,60 @@ func (b *BtcWallet) deriveKeyByBIP32Path(path []uint32) (*btcec.PrivateKey,
 	return privKey, nil
 }
 
+// IsOwnOutput returns true if the given output pays to the key described by
+// the BIP32 derivation path, using the address type of the path's key scope.
+// This allows verifying that an output pays back to the wallet, even if the
+// address was never derived by this wallet instance, for example because it
+// was derived by a watch-only instance of the same wallet.
+func (b *BtcWallet) IsOwnOutput(txOut *wire.TxOut, path []uint32) bool {
+	privKey, err := b.deriveKeyByBIP32Path(path)
+	if err != nil {
+		return false
+	}
+	pubKey := privKey.PubKey()
+	pubKeyHash := btcutil.Hash160(pubKey.SerializeCompressed())
+
+	var addr btcutil.Address
+	switch path[0] - hdkeychain.HardenedKeyStart {
+	case keychain.BIP0043Purpose, waddrmgr.KeyScopeBIP0084.Purpose:
+		addr, err = btcutil.NewAddressWitnessPubKeyHash(
+			pubKeyHash, b.netParams,
+		)
+
+	case waddrmgr.KeyScopeBIP0049Plus.Purpose:
+		var witnessScript []byte
+		witnessScript, err = input.WitnessPubKeyHash(
+			pubKey.SerializeCompressed(),
+		)
+		if err != nil {
+			return false
+		}
+
+		addr, err = btcutil.NewAddressScriptHash(
+			witnessScript, b.netParams,
+		)
+
+	case waddrmgr.KeyScopeBIP0086.Purpose:
+		taprootKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+		addr, err = btcutil.NewAddressTaproot(
+			schnorr.SerializePubKey(taprootKey), b.netParams,
+		)
+
+	default:
+		return false
+	}
+	if err != nil {
+		return false
+	}
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(pkScript, txOut.PkScript)
+}
+
 // assertHardened makes sure each given element is >= 2^31.
 func assertHardened(elements ...uint32) error {
 	for idx, element := range elements {
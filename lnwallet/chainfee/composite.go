@@ -0,0 +1,378 @@
+package chainfee
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// errNoFeeSource is returned by the CompositeEstimator if none of its
+	// sources produced a usable fee estimate.
+	errNoFeeSource = errors.New("no fee estimation source available")
+
+	// errSourceTimeout is reported for a source of the CompositeEstimator
+	// that didn't answer within the configured timeout.
+	errSourceTimeout = errors.New("fee estimation source timed out")
+)
+
+// CombineMode defines how the CompositeEstimator combines the fee rates of
+// its sources into a single fee rate.
+type CombineMode uint8
+
+const (
+	// CombineMedian uses the median of the fee rates of all sources.
+	CombineMedian CombineMode = iota
+
+	// CombineMax uses the highest fee rate of all sources.
+	CombineMax
+)
+
+// String returns a human-readable name of the combine mode.
+func (c CombineMode) String() string {
+	switch c {
+	case CombineMedian:
+		return "median"
+
+	case CombineMax:
+		return "max"
+
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(c))
+	}
+}
+
+// ParseCombineMode parses the name of a combine mode as returned by String.
+func ParseCombineMode(mode string) (CombineMode, error) {
+	switch mode {
+	case "median":
+		return CombineMedian, nil
+
+	case "max":
+		return CombineMax, nil
+
+	default:
+		return 0, fmt.Errorf("unknown fee combine mode: %v", mode)
+	}
+}
+
+// NamedEstimator is a fee estimation source of the CompositeEstimator.
+type NamedEstimator struct {
+	// Name identifies the source in logs and in the detailed estimates.
+	Name string
+
+	// Estimator is the fee estimator that is queried.
+	Estimator Estimator
+}
+
+// SourceEstimate is the answer of a single source of the CompositeEstimator.
+type SourceEstimate struct {
+	// Name is the name of the source.
+	Name string
+
+	// FeeRate is the fee rate the source estimated. It is zero if Err is
+	// set.
+	FeeRate SatPerKWeight
+
+	// Age is the time since the source produced FeeRate. It is zero for a
+	// fresh answer and non-zero if the source failed to answer and its
+	// previous estimate for the same target is used instead.
+	Age time.Duration
+
+	// Err is set if the source didn't produce a usable fee rate, in which
+	// case it isn't taken into account.
+	Err error
+}
+
+// DetailedEstimator is an Estimator that combines several sources and is able
+// to report the answer of each of them.
+type DetailedEstimator interface {
+	Estimator
+
+	// EstimateFeeDetails returns the combined fee rate for the given
+	// confirmation target along with the answer of each source.
+	EstimateFeeDetails(numBlocks uint32) (SatPerKWeight, []SourceEstimate,
+		error)
+}
+
+// CompositeConfig houses the parameters of a CompositeEstimator.
+type CompositeConfig struct {
+	// Sources are the fee estimators that are combined.
+	Sources []NamedEstimator
+
+	// Mode determines how the fee rates of the sources are combined.
+	Mode CombineMode
+
+	// SourceTimeout is the maximum time a source has to answer. A source
+	// that doesn't answer in time is treated like a failed one.
+	SourceTimeout time.Duration
+
+	// MaxStaleness is the maximum age of a previous estimate of a source
+	// that is used while the source fails to answer. If zero, previous
+	// estimates are never used.
+	MaxStaleness time.Duration
+}
+
+// cachedEstimate is the last fee rate a source returned for a target.
+type cachedEstimate struct {
+	feeRate   SatPerKWeight
+	timestamp time.Time
+}
+
+// CompositeEstimator is an Estimator that queries several fee estimation
+// sources concurrently and combines their answers. This makes the estimate
+// resilient against a single source lagging behind or being unavailable.
+type CompositeEstimator struct {
+	cfg CompositeConfig
+
+	// cacheMtx guards cache.
+	cacheMtx sync.Mutex
+
+	// cache holds the last estimate of each source, keyed by the index of
+	// the source and the confirmation target.
+	cache map[int]map[uint32]cachedEstimate
+}
+
+// NewCompositeEstimator creates a new CompositeEstimator that combines the
+// configured sources.
+func NewCompositeEstimator(cfg CompositeConfig) (*CompositeEstimator, error) {
+	if len(cfg.Sources) == 0 {
+		return nil, errors.New("at least one fee estimation source " +
+			"is required")
+	}
+
+	if cfg.SourceTimeout <= 0 {
+		return nil, errors.New("source timeout must be positive")
+	}
+
+	if cfg.Mode != CombineMedian && cfg.Mode != CombineMax {
+		return nil, fmt.Errorf("unknown fee combine mode: %v", cfg.Mode)
+	}
+
+	cache := make(map[int]map[uint32]cachedEstimate, len(cfg.Sources))
+	for i := range cfg.Sources {
+		cache[i] = make(map[uint32]cachedEstimate)
+	}
+
+	return &CompositeEstimator{
+		cfg:   cfg,
+		cache: cache,
+	}, nil
+}
+
+// Start starts all sources of the estimator.
+//
+// NOTE: This method is part of the Estimator interface.
+func (c *CompositeEstimator) Start() error {
+	for i, source := range c.cfg.Sources {
+		err := source.Estimator.Start()
+		if err == nil {
+			continue
+		}
+
+		// Stop the sources that were already started before bailing
+		// out.
+		for _, started := range c.cfg.Sources[:i] {
+			if err := started.Estimator.Stop(); err != nil {
+				log.Errorf("Unable to stop fee source %v: %v",
+					started.Name, err)
+			}
+		}
+
+		return fmt.Errorf("unable to start fee source %v: %w",
+			source.Name, err)
+	}
+
+	return nil
+}
+
+// Stop stops all sources of the estimator.
+//
+// NOTE: This method is part of the Estimator interface.
+func (c *CompositeEstimator) Stop() error {
+	var firstErr error
+	for _, source := range c.cfg.Sources {
+		err := source.Estimator.Stop()
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("unable to stop fee source %v: "+
+				"%w", source.Name, err)
+		}
+	}
+
+	return firstErr
+}
+
+// EstimateFeePerKW takes in a target for the number of blocks until an initial
+// confirmation and returns the combined fee estimate of all sources expressed
+// in sat/kw.
+//
+// NOTE: This method is part of the Estimator interface.
+func (c *CompositeEstimator) EstimateFeePerKW(
+	numBlocks uint32) (SatPerKWeight, error) {
+
+	feeRate, _, err := c.EstimateFeeDetails(numBlocks)
+
+	return feeRate, err
+}
+
+// EstimateFeeDetails queries all sources for the given confirmation target and
+// returns their combined fee rate along with the answer of each of them.
+//
+// NOTE: This method is part of the DetailedEstimator interface.
+func (c *CompositeEstimator) EstimateFeeDetails(
+	numBlocks uint32) (SatPerKWeight, []SourceEstimate, error) {
+
+	type result struct {
+		feeRate SatPerKWeight
+		err     error
+	}
+
+	// Query all sources concurrently, so a slow source only delays the
+	// estimate by the source timeout.
+	resultChans := make([]chan result, len(c.cfg.Sources))
+	for i, source := range c.cfg.Sources {
+		resultChan := make(chan result, 1)
+		resultChans[i] = resultChan
+
+		go func(i int, estimator Estimator) {
+			feeRate, err := estimator.EstimateFeePerKW(numBlocks)
+
+			// Even if we stopped waiting for the source, its
+			// answer is cached so it can be used next time.
+			if err == nil {
+				c.cacheEstimate(i, numBlocks, feeRate)
+			}
+
+			resultChan <- result{feeRate: feeRate, err: err}
+		}(i, source.Estimator)
+	}
+
+	timeout := time.After(c.cfg.SourceTimeout)
+	estimates := make([]SourceEstimate, len(c.cfg.Sources))
+	for i, source := range c.cfg.Sources {
+		estimate := SourceEstimate{
+			Name: source.Name,
+		}
+
+		select {
+		case res := <-resultChans[i]:
+			estimate.FeeRate = res.feeRate
+			estimate.Err = res.err
+
+		case <-timeout:
+			estimate.Err = errSourceTimeout
+		}
+
+		// If the source didn't answer, fall back to its previous
+		// estimate as long as it isn't too old.
+		if estimate.Err != nil {
+			log.Debugf("Fee source %v failed for conf target %v: "+
+				"%v", source.Name, numBlocks, estimate.Err)
+
+			cached, ok := c.cachedEstimate(i, numBlocks)
+			if ok {
+				estimate.FeeRate = cached.feeRate
+				estimate.Age = time.Since(cached.timestamp)
+				estimate.Err = nil
+			}
+		}
+
+		estimates[i] = estimate
+	}
+
+	feeRates := make([]SatPerKWeight, 0, len(estimates))
+	for _, estimate := range estimates {
+		if estimate.Err == nil {
+			feeRates = append(feeRates, estimate.FeeRate)
+		}
+	}
+
+	if len(feeRates) == 0 {
+		return 0, estimates, errNoFeeSource
+	}
+
+	feeRate := combineFeeRates(feeRates, c.cfg.Mode)
+
+	// The combined fee rate must be accepted by all sources' backends.
+	if relayFee := c.RelayFeePerKW(); feeRate < relayFee {
+		feeRate = relayFee
+	}
+
+	log.Debugf("Returning %v sat/kw for conf target of %v from %d of %d "+
+		"fee sources", int64(feeRate), numBlocks, len(feeRates),
+		len(estimates))
+
+	return feeRate, estimates, nil
+}
+
+// RelayFeePerKW returns the highest minimum fee rate required by any source
+// for transactions to be relayed.
+//
+// NOTE: This method is part of the Estimator interface.
+func (c *CompositeEstimator) RelayFeePerKW() SatPerKWeight {
+	relayFee := FeePerKwFloor
+	for _, source := range c.cfg.Sources {
+		fee := source.Estimator.RelayFeePerKW()
+		if fee > relayFee {
+			relayFee = fee
+		}
+	}
+
+	return relayFee
+}
+
+// cacheEstimate stores the fee rate a source returned for a target.
+func (c *CompositeEstimator) cacheEstimate(source int, numBlocks uint32,
+	feeRate SatPerKWeight) {
+
+	c.cacheMtx.Lock()
+	defer c.cacheMtx.Unlock()
+
+	c.cache[source][numBlocks] = cachedEstimate{
+		feeRate:   feeRate,
+		timestamp: time.Now(),
+	}
+}
+
+// cachedEstimate returns the previous estimate of a source for a target if it
+// is younger than the configured maximum staleness.
+func (c *CompositeEstimator) cachedEstimate(source int,
+	numBlocks uint32) (cachedEstimate, bool) {
+
+	c.cacheMtx.Lock()
+	defer c.cacheMtx.Unlock()
+
+	cached, ok := c.cache[source][numBlocks]
+	if !ok || time.Since(cached.timestamp) > c.cfg.MaxStaleness {
+		return cachedEstimate{}, false
+	}
+
+	return cached, true
+}
+
+// combineFeeRates combines the given non-empty list of fee rates according to
+// the combine mode.
+func combineFeeRates(feeRates []SatPerKWeight, mode CombineMode) SatPerKWeight {
+	sorted := make([]SatPerKWeight, len(feeRates))
+	copy(sorted, feeRates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	if mode == CombineMax {
+		return sorted[len(sorted)-1]
+	}
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[middle]
+	}
+
+	return (sorted[middle-1] + sorted[middle]) / 2
+}
+
+// A compile-time assertion to ensure that CompositeEstimator implements the
+// DetailedEstimator interface.
+var _ DetailedEstimator = (*CompositeEstimator)(nil)
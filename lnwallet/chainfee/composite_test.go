@@ -0,0 +1,196 @@
+package chainfee
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestCombineFeeRates checks that fee rates are combined by median or max.
+func TestCombineFeeRates(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		feeRates []SatPerKWeight
+		mode     CombineMode
+		expected SatPerKWeight
+	}{
+		{
+			name:     "single median",
+			feeRates: []SatPerKWeight{1000},
+			mode:     CombineMedian,
+			expected: 1000,
+		},
+		{
+			name:     "odd median",
+			feeRates: []SatPerKWeight{3000, 1000, 2000},
+			mode:     CombineMedian,
+			expected: 2000,
+		},
+		{
+			name:     "even median",
+			feeRates: []SatPerKWeight{4000, 1000, 2000, 3000},
+			mode:     CombineMedian,
+			expected: 2500,
+		},
+		{
+			name:     "max",
+			feeRates: []SatPerKWeight{1000, 3000, 2000},
+			mode:     CombineMax,
+			expected: 3000,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			feeRate := combineFeeRates(tc.feeRates, tc.mode)
+			require.Equal(t, tc.expected, feeRate)
+		})
+	}
+}
+
+// TestCompositeEstimator checks that the CompositeEstimator combines the
+// answers of its sources, enforces the highest relay fee and ignores sources
+// that fail or time out unless a recent previous answer is available.
+func TestCompositeEstimator(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 100 * time.Millisecond
+
+	fast := &mockEstimator{}
+	fast.On("RelayFeePerKW").Return(SatPerKWeight(300))
+	fast.On("EstimateFeePerKW", uint32(2)).Return(
+		SatPerKWeight(1000), nil,
+	)
+	fast.On("EstimateFeePerKW", uint32(6)).Return(
+		SatPerKWeight(200), nil,
+	)
+
+	// The failing source answers for the first target once, so its
+	// answer is cached, and fails afterwards.
+	failing := &mockEstimator{}
+	failing.On("RelayFeePerKW").Return(SatPerKWeight(253))
+	failing.On("EstimateFeePerKW", uint32(2)).Return(
+		SatPerKWeight(5000), nil,
+	).Once()
+	failing.On("EstimateFeePerKW", uint32(2)).Return(
+		SatPerKWeight(0), errors.New("unavailable"),
+	)
+	failing.On("EstimateFeePerKW", uint32(6)).Return(
+		SatPerKWeight(0), errors.New("unavailable"),
+	)
+
+	slow := &mockEstimator{}
+	slow.On("RelayFeePerKW").Return(SatPerKWeight(253))
+	slow.On("EstimateFeePerKW", uint32(2)).Return(
+		SatPerKWeight(9000), nil,
+	).After(5 * timeout)
+	slow.On("EstimateFeePerKW", uint32(6)).Return(
+		SatPerKWeight(9000), nil,
+	).After(5 * timeout)
+
+	estimator, err := NewCompositeEstimator(CompositeConfig{
+		Sources: []NamedEstimator{
+			{Name: "fast", Estimator: fast},
+			{Name: "failing", Estimator: failing},
+			{Name: "slow", Estimator: slow},
+		},
+		Mode:          CombineMax,
+		SourceTimeout: timeout,
+		MaxStaleness:  time.Hour,
+	})
+	require.NoError(t, err)
+
+	// The relay fee is the highest one of all sources.
+	require.Equal(t, SatPerKWeight(300), estimator.RelayFeePerKW())
+
+	// The slow source times out, so only the fast and the failing source
+	// are taken into account.
+	feeRate, estimates, err := estimator.EstimateFeeDetails(2)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKWeight(5000), feeRate)
+	require.Len(t, estimates, 3)
+	require.Equal(t, "slow", estimates[2].Name)
+	require.ErrorIs(t, estimates[2].Err, errSourceTimeout)
+
+	// Now the failing source fails, so its previous answer is used.
+	feeRate, estimates, err = estimator.EstimateFeeDetails(2)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKWeight(5000), feeRate)
+	require.NoError(t, estimates[1].Err)
+	require.NotZero(t, estimates[1].Age)
+
+	// There's no previous answer of the failing source for the second
+	// target, and the combined fee rate is raised to the relay fee.
+	feeRate, estimates, err = estimator.EstimateFeeDetails(6)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKWeight(300), feeRate)
+	require.Error(t, estimates[1].Err)
+}
+
+// TestCompositeEstimatorStaleness checks that previous answers older than the
+// maximum staleness aren't used.
+func TestCompositeEstimatorStaleness(t *testing.T) {
+	t.Parallel()
+
+	source := &mockEstimator{}
+	source.On("RelayFeePerKW").Return(FeePerKwFloor)
+	source.On("EstimateFeePerKW", uint32(2)).Return(
+		SatPerKWeight(1000), nil,
+	).Once()
+	source.On("EstimateFeePerKW", uint32(2)).Return(
+		SatPerKWeight(0), errors.New("unavailable"),
+	)
+
+	estimator, err := NewCompositeEstimator(CompositeConfig{
+		Sources: []NamedEstimator{
+			{Name: "source", Estimator: source},
+		},
+		Mode:          CombineMedian,
+		SourceTimeout: time.Second,
+		MaxStaleness:  time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	feeRate, err := estimator.EstimateFeePerKW(2)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKWeight(1000), feeRate)
+
+	// Once the previous answer is too old, there's no source left.
+	time.Sleep(10 * time.Millisecond)
+	_, err = estimator.EstimateFeePerKW(2)
+	require.ErrorIs(t, err, errNoFeeSource)
+}
+
+// TestCompositeEstimatorStart checks that the sources which were already
+// started are stopped again if a source fails to start.
+func TestCompositeEstimatorStart(t *testing.T) {
+	t.Parallel()
+
+	started := &mockEstimator{}
+	started.On("Start").Return(nil)
+	started.On("Stop").Return(nil)
+
+	failing := &mockEstimator{}
+	failing.On("Start").Return(errors.New("unavailable"))
+
+	estimator, err := NewCompositeEstimator(CompositeConfig{
+		Sources: []NamedEstimator{
+			{Name: "started", Estimator: started},
+			{Name: "failing", Estimator: failing},
+		},
+		SourceTimeout: time.Second,
+	})
+	require.NoError(t, err)
+
+	require.Error(t, estimator.Start())
+
+	started.AssertExpectations(t)
+	failing.AssertExpectations(t)
+}
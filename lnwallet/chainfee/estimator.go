@@ -422,7 +422,15 @@ func (b *BitcoindEstimator) Start() error {
 // requires for a tx to enter its mempool. The returned fee will be the
 // maximum of the minimum relay fee and the minimum mempool fee.
 func (b *BitcoindEstimator) fetchMinMempoolFee() (SatPerKWeight, error) {
-	resp, err := b.bitcoindConn.RawRequest("getmempoolinfo", nil)
+	return fetchBitcoindMinMempoolFee(b.bitcoindConn)
+}
+
+// fetchBitcoindMinMempoolFee fetches the minimum fee that the given bitcoind
+// node requires for a tx to enter its mempool.
+func fetchBitcoindMinMempoolFee(
+	bitcoindConn *rpcclient.Client) (SatPerKWeight, error) {
+
+	resp, err := bitcoindConn.RawRequest("getmempoolinfo", nil)
 	if err != nil {
 		return 0, err
 	}
@@ -631,6 +639,151 @@ func chooseMinFee(minRelayFeeFunc func() SatPerKWeight,
 // Estimator interface.
 var _ Estimator = (*BitcoindEstimator)(nil)
 
+// ElectrumFeeSource is the part of an Electrum or Esplora chain backend that
+// provides fee estimates.
+type ElectrumFeeSource interface {
+	// EstimateFee returns the estimated fee rate in sat/kvB for a
+	// transaction to confirm within the given number of blocks.
+	EstimateFee(numBlocks uint32) (btcutil.Amount, error)
+
+	// RelayFee returns the minimum fee rate in sat/kvB required for
+	// transactions to be relayed.
+	RelayFee() (btcutil.Amount, error)
+}
+
+// ElectrumEstimator is an implementation of the Estimator interface backed by
+// an Electrum or Esplora server. This implementation will proxy any fee
+// estimation requests to the server.
+type ElectrumEstimator struct {
+	// fallbackFeePerKW is the fall back fee rate in sat/kw that is returned
+	// if the server does not yet have enough data to actually produce fee
+	// estimates.
+	fallbackFeePerKW SatPerKWeight
+
+	// minFeeManager is used to query the current minimum fee, in sat/kw,
+	// that we should enforce. This will be used to determine fee rate for
+	// a transaction when the estimated fee rate is too low to allow the
+	// transaction to propagate through the network.
+	minFeeManager *minFeeManager
+
+	feeSource ElectrumFeeSource
+}
+
+// NewElectrumEstimator creates a new ElectrumEstimator given a fee source and
+// a fall back fee rate. The fallback fee rate is used in the occasion that
+// the server has insufficient data, or returns zero for a fee estimate.
+func NewElectrumEstimator(feeSource ElectrumFeeSource,
+	fallBackFeeRate SatPerKWeight) *ElectrumEstimator {
+
+	return &ElectrumEstimator{
+		fallbackFeePerKW: fallBackFeeRate,
+		feeSource:        feeSource,
+	}
+}
+
+// Start signals the Estimator to start any processes or goroutines
+// it needs to perform its duty.
+//
+// NOTE: This method is part of the Estimator interface.
+func (e *ElectrumEstimator) Start() error {
+	minRelayFeeManager, err := newMinFeeManager(
+		defaultUpdateInterval, e.fetchMinRelayFee,
+	)
+	if err != nil {
+		return err
+	}
+	e.minFeeManager = minRelayFeeManager
+
+	return nil
+}
+
+// fetchMinRelayFee fetches and returns the minimum relay fee in sat/kw from
+// the server.
+func (e *ElectrumEstimator) fetchMinRelayFee() (SatPerKWeight, error) {
+	relayFee, err := e.feeSource.RelayFee()
+	if err != nil {
+		return 0, err
+	}
+
+	// The fee rate is expressed in sat/kb, so we'll manually convert it to
+	// our desired sat/kw rate.
+	return SatPerKVByte(relayFee).FeePerKWeight(), nil
+}
+
+// Stop stops any spawned goroutines and cleans up the resources used by the
+// fee estimator. The fee source is shared with the chain backend, so it isn't
+// closed here.
+//
+// NOTE: This method is part of the Estimator interface.
+func (e *ElectrumEstimator) Stop() error {
+	return nil
+}
+
+// EstimateFeePerKW takes in a target for the number of blocks until an initial
+// confirmation and returns the estimated fee expressed in sat/kw.
+//
+// NOTE: This method is part of the Estimator interface.
+func (e *ElectrumEstimator) EstimateFeePerKW(
+	numBlocks uint32) (SatPerKWeight, error) {
+
+	feeEstimate, err := e.fetchEstimate(numBlocks)
+	switch {
+	// If the server doesn't have enough data, or returns an error, then
+	// to return a proper value, then we'll return the default fall back
+	// fee rate.
+	case err != nil:
+		log.Errorf("unable to query estimator: %v", err)
+		fallthrough
+
+	case feeEstimate == 0:
+		return e.fallbackFeePerKW, nil
+	}
+
+	return feeEstimate, nil
+}
+
+// RelayFeePerKW returns the minimum fee rate required for transactions to be
+// relayed.
+//
+// NOTE: This method is part of the Estimator interface.
+func (e *ElectrumEstimator) RelayFeePerKW() SatPerKWeight {
+	return e.minFeeManager.fetchMinFee()
+}
+
+// fetchEstimate returns a fee estimate for a transaction to be confirmed in
+// confTarget blocks. The estimate is returned in sat/kw.
+func (e *ElectrumEstimator) fetchEstimate(confTarget uint32) (SatPerKWeight,
+	error) {
+
+	satPerKB, err := e.feeSource.EstimateFee(confTarget)
+	if err != nil {
+		return 0, err
+	}
+
+	// Since we use fee rates in sat/kw internally, we'll convert the
+	// estimated fee rate from its sat/kb representation to sat/kw.
+	satPerKw := SatPerKVByte(satPerKB).FeePerKWeight()
+
+	// Finally, we'll enforce our fee floor.
+	absoluteMinFee := e.RelayFeePerKW()
+	if satPerKw < absoluteMinFee {
+		log.Debugf("Estimated fee rate of %v sat/kw is too low, "+
+			"using fee floor of %v sat/kw instead", satPerKw,
+			absoluteMinFee)
+
+		satPerKw = absoluteMinFee
+	}
+
+	log.Debugf("Returning %v sat/kw for conf target of %v",
+		int64(satPerKw), confTarget)
+
+	return satPerKw, nil
+}
+
+// A compile-time assertion to ensure that ElectrumEstimator implements the
+// Estimator interface.
+var _ Estimator = (*ElectrumEstimator)(nil)
+
 // WebAPIFeeSource is an interface allows the WebAPIEstimator to query an
 // arbitrary HTTP-based fee estimator. Each new set/network will gain an
 // implementation of this interface in order to allow the WebAPIEstimator to
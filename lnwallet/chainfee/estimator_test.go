@@ -3,6 +3,7 @@ package chainfee
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil"
@@ -90,6 +91,48 @@ func TestStaticFeeEstimator(t *testing.T) {
 	}
 }
 
+// TestElectrumEstimator checks that the ElectrumEstimator converts the fee
+// rates of its source, enforces the relay fee floor and falls back to the
+// fallback fee rate if the source has no estimate.
+func TestElectrumEstimator(t *testing.T) {
+	t.Parallel()
+
+	const fallbackFeeRate = SatPerKWeight(12_500)
+
+	source := &mockElectrumFeeSource{}
+	source.On("RelayFee").Return(btcutil.Amount(2_000), nil)
+	source.On("EstimateFee", uint32(2)).Return(btcutil.Amount(20_000), nil)
+	source.On("EstimateFee", uint32(6)).Return(btcutil.Amount(1_000), nil)
+	source.On("EstimateFee", uint32(144)).Return(
+		btcutil.Amount(0), errors.New("no fee estimate"),
+	)
+
+	estimator := NewElectrumEstimator(source, fallbackFeeRate)
+	require.NoError(t, estimator.Start())
+	defer estimator.Stop()
+
+	// The relay fee is converted from sat/kvB to sat/kw.
+	relayFee := SatPerKVByte(2_000).FeePerKWeight()
+	require.Equal(t, relayFee, estimator.RelayFeePerKW())
+
+	// Estimates above the relay fee are converted as well.
+	feeRate, err := estimator.EstimateFeePerKW(2)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKVByte(20_000).FeePerKWeight(), feeRate)
+
+	// Estimates below the relay fee are raised to it.
+	feeRate, err = estimator.EstimateFeePerKW(6)
+	require.NoError(t, err)
+	require.Equal(t, relayFee, feeRate)
+
+	// If the source has no estimate, the fallback fee rate is used.
+	feeRate, err = estimator.EstimateFeePerKW(144)
+	require.NoError(t, err)
+	require.Equal(t, fallbackFeeRate, feeRate)
+
+	source.AssertExpectations(t)
+}
+
 // TestSparseConfFeeSource checks that SparseConfFeeSource generates URLs and
 // parses API responses as expected.
 func TestSparseConfFeeSource(t *testing.T) {
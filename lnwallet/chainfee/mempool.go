@@ -0,0 +1,425 @@
+package chainfee
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/rpcclient"
+)
+
+const (
+	// blockWeightTarget is the weight of the transactions that fit into a
+	// block. It's the maximum block weight minus the weight bitcoind
+	// reserves for the coinbase transaction.
+	blockWeightTarget = blockchain.MaxBlockWeight - 4000
+
+	// DefaultMempoolRefreshInterval is the default interval in which the
+	// MempoolEstimator fetches a new snapshot of the mempool.
+	DefaultMempoolRefreshInterval = time.Minute
+
+	// maxSnapshotAgeFactor is the number of refresh intervals after which
+	// a mempool snapshot is considered to be too old to be used.
+	maxSnapshotAgeFactor = 3
+)
+
+var (
+	// errNoMempoolSnapshot is returned by the MempoolEstimator if it
+	// wasn't able to fetch the mempool yet.
+	errNoMempoolSnapshot = errors.New("no mempool snapshot available")
+
+	// errStaleMempoolSnapshot is returned by the MempoolEstimator if it
+	// failed to refresh its mempool snapshot for too long.
+	errStaleMempoolSnapshot = errors.New("mempool snapshot is stale")
+)
+
+// MempoolEntry is a transaction in the mempool of the backend node.
+type MempoolEntry struct {
+	// Weight is the weight of the transaction.
+	Weight int64
+
+	// FeeRate is the fee rate at which a miner would include the
+	// transaction into a block.
+	FeeRate SatPerKWeight
+}
+
+// MempoolSource is the backend of a MempoolEstimator.
+type MempoolSource interface {
+	// FetchMempool returns all transactions in the mempool.
+	FetchMempool() ([]MempoolEntry, error)
+
+	// FetchMinMempoolFee returns the minimum fee rate that a transaction
+	// requires to enter the mempool.
+	FetchMinMempoolFee() (SatPerKWeight, error)
+}
+
+// histogramBucket is the total weight of all mempool transactions paying at
+// least a certain fee rate.
+type histogramBucket struct {
+	feeRate SatPerKWeight
+
+	// cumulativeWeight is the weight of all transactions that pay at
+	// least feeRate.
+	cumulativeWeight int64
+}
+
+// mempoolSnapshot is a fee rate histogram of the mempool at a point in time.
+type mempoolSnapshot struct {
+	// buckets is sorted by descending fee rate, so by ascending cumulative
+	// weight.
+	buckets []histogramBucket
+
+	timestamp time.Time
+}
+
+// newMempoolSnapshot builds the fee rate histogram of the given mempool
+// transactions.
+func newMempoolSnapshot(entries []MempoolEntry) *mempoolSnapshot {
+	sorted := make([]MempoolEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].FeeRate > sorted[j].FeeRate
+	})
+
+	var (
+		buckets []histogramBucket
+		total   int64
+	)
+	for _, entry := range sorted {
+		total += entry.Weight
+
+		// Transactions paying the same fee rate are merged into a
+		// single bucket.
+		last := len(buckets) - 1
+		if last >= 0 && buckets[last].feeRate == entry.FeeRate {
+			buckets[last].cumulativeWeight = total
+			continue
+		}
+
+		buckets = append(buckets, histogramBucket{
+			feeRate:          entry.FeeRate,
+			cumulativeWeight: total,
+		})
+	}
+
+	return &mempoolSnapshot{
+		buckets:   buckets,
+		timestamp: time.Now(),
+	}
+}
+
+// feeRateAt returns the fee rate a transaction must pay to be placed within
+// the given weight from the top of the mempool. The second return value is
+// false if the mempool doesn't reach the given weight, so any fee rate would
+// do.
+func (m *mempoolSnapshot) feeRateAt(weight int64) (SatPerKWeight, bool) {
+	idx := sort.Search(len(m.buckets), func(i int) bool {
+		return m.buckets[i].cumulativeWeight >= weight
+	})
+	if idx == len(m.buckets) {
+		return 0, false
+	}
+
+	// We need to outbid the transactions at the given position.
+	return m.buckets[idx].feeRate + 1, true
+}
+
+// MempoolEstimator is an implementation of the Estimator interface that
+// derives fee rates from a histogram of the backend node's mempool rather than
+// from past blocks. Instead of a confirmation target, the number of blocks
+// passed to it is a position in the mempool: the returned fee rate places a
+// transaction within the first blocks that would be mined from the current
+// mempool. This reacts to sudden congestion immediately, while an estimator
+// based on past blocks lags behind.
+type MempoolEstimator struct {
+	started sync.Once
+	stopped sync.Once
+
+	source MempoolSource
+
+	refreshInterval time.Duration
+
+	// minFeeManager is used to query the current minimum fee, in sat/kw,
+	// that we should enforce.
+	minFeeManager *minFeeManager
+
+	// snapshotMtx guards snapshot.
+	snapshotMtx sync.RWMutex
+	snapshot    *mempoolSnapshot
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewMempoolEstimator creates a new MempoolEstimator that fetches a snapshot of
+// the source's mempool in the given interval.
+func NewMempoolEstimator(source MempoolSource,
+	refreshInterval time.Duration) *MempoolEstimator {
+
+	return &MempoolEstimator{
+		source:          source,
+		refreshInterval: refreshInterval,
+		quit:            make(chan struct{}),
+	}
+}
+
+// Start fetches the first mempool snapshot and starts refreshing it
+// periodically.
+//
+// NOTE: This method is part of the Estimator interface.
+func (m *MempoolEstimator) Start() error {
+	var err error
+	m.started.Do(func() {
+		log.Info("Starting mempool fee estimator")
+
+		m.minFeeManager, err = newMinFeeManager(
+			defaultUpdateInterval, m.source.FetchMinMempoolFee,
+		)
+		if err != nil {
+			return
+		}
+
+		// A failure to fetch the mempool isn't fatal, we'll try again
+		// in the next interval.
+		if err := m.refresh(); err != nil {
+			log.Errorf("Unable to fetch mempool: %v", err)
+		}
+
+		m.wg.Add(1)
+		go m.refresher()
+	})
+
+	return err
+}
+
+// Stop stops refreshing the mempool snapshot.
+//
+// NOTE: This method is part of the Estimator interface.
+func (m *MempoolEstimator) Stop() error {
+	m.stopped.Do(func() {
+		log.Info("Stopping mempool fee estimator")
+
+		close(m.quit)
+		m.wg.Wait()
+	})
+
+	return nil
+}
+
+// refresher periodically fetches a new mempool snapshot.
+//
+// NOTE: This MUST be run as a goroutine.
+func (m *MempoolEstimator) refresher() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := m.refresh(); err != nil {
+				log.Errorf("Unable to fetch mempool: %v", err)
+			}
+
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// refresh fetches the mempool and replaces the current snapshot with it.
+func (m *MempoolEstimator) refresh() error {
+	entries, err := m.source.FetchMempool()
+	if err != nil {
+		return err
+	}
+
+	snapshot := newMempoolSnapshot(entries)
+
+	log.Debugf("Fetched mempool with %d transactions in %d fee rate "+
+		"buckets", len(entries), len(snapshot.buckets))
+
+	m.snapshotMtx.Lock()
+	m.snapshot = snapshot
+	m.snapshotMtx.Unlock()
+
+	return nil
+}
+
+// EstimateFeePerKW returns the fee rate in sat/kw a transaction must pay to be
+// placed within the first numBlocks blocks of the current mempool.
+//
+// NOTE: This method is part of the Estimator interface.
+func (m *MempoolEstimator) EstimateFeePerKW(
+	numBlocks uint32) (SatPerKWeight, error) {
+
+	if numBlocks < minBlockTarget {
+		return 0, fmt.Errorf("block position must be at least %v",
+			minBlockTarget)
+	}
+
+	if numBlocks > maxBlockTarget {
+		log.Debugf("Block position %d exceeds the max value, use %d "+
+			"instead.", numBlocks, maxBlockTarget)
+		numBlocks = maxBlockTarget
+	}
+
+	m.snapshotMtx.RLock()
+	snapshot := m.snapshot
+	m.snapshotMtx.RUnlock()
+
+	switch {
+	case snapshot == nil:
+		return 0, errNoMempoolSnapshot
+
+	case time.Since(snapshot.timestamp) >
+		maxSnapshotAgeFactor*m.refreshInterval:
+
+		return 0, errStaleMempoolSnapshot
+	}
+
+	relayFee := m.RelayFeePerKW()
+
+	// If the mempool doesn't fill the requested number of blocks, a
+	// transaction paying the minimum fee rate makes it in.
+	feeRate, ok := snapshot.feeRateAt(int64(numBlocks) * blockWeightTarget)
+	if !ok || feeRate < relayFee {
+		feeRate = relayFee
+	}
+
+	log.Debugf("Returning %v sat/kw for block position %v", int64(feeRate),
+		numBlocks)
+
+	return feeRate, nil
+}
+
+// RelayFeePerKW returns the minimum fee rate required for transactions to be
+// relayed.
+//
+// NOTE: This method is part of the Estimator interface.
+func (m *MempoolEstimator) RelayFeePerKW() SatPerKWeight {
+	return m.minFeeManager.fetchMinFee()
+}
+
+// A compile-time assertion to ensure that MempoolEstimator implements the
+// Estimator interface.
+var _ Estimator = (*MempoolEstimator)(nil)
+
+// BitcoindMempoolSource is a MempoolSource backed by the RPC interface of a
+// bitcoind node.
+type BitcoindMempoolSource struct {
+	bitcoindConn *rpcclient.Client
+}
+
+// NewBitcoindMempoolSource creates a new BitcoindMempoolSource given a fully
+// populated rpc config that is able to successfully connect and authenticate
+// with the bitcoind node.
+func NewBitcoindMempoolSource(
+	rpcConfig rpcclient.ConnConfig) (*BitcoindMempoolSource, error) {
+
+	rpcConfig.DisableConnectOnNew = true
+	rpcConfig.DisableAutoReconnect = false
+	rpcConfig.DisableTLS = true
+	rpcConfig.HTTPPostMode = true
+	chainConn, err := rpcclient.New(&rpcConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BitcoindMempoolSource{
+		bitcoindConn: chainConn,
+	}, nil
+}
+
+// bitcoindMempoolEntry is the part of a verbose "getrawmempool" entry that
+// we're interested in.
+type bitcoindMempoolEntry struct {
+	Weight       int64 `json:"weight"`
+	VSize        int64 `json:"vsize"`
+	AncestorSize int64 `json:"ancestorsize"`
+	Fees         struct {
+		Modified float64 `json:"modified"`
+		Ancestor float64 `json:"ancestor"`
+	} `json:"fees"`
+}
+
+// FetchMempool returns all transactions in the mempool of the bitcoind node.
+//
+// NOTE: This method is part of the MempoolSource interface.
+func (b *BitcoindMempoolSource) FetchMempool() ([]MempoolEntry, error) {
+	verbose, err := json.Marshal(true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.bitcoindConn.RawRequest(
+		"getrawmempool", []json.RawMessage{verbose},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var mempool map[string]bitcoindMempoolEntry
+	if err := json.Unmarshal(resp, &mempool); err != nil {
+		return nil, err
+	}
+
+	entries := make([]MempoolEntry, 0, len(mempool))
+	for txid, tx := range mempool {
+		if tx.VSize <= 0 || tx.AncestorSize <= 0 {
+			return nil, fmt.Errorf("invalid size of mempool "+
+				"transaction %v", txid)
+		}
+
+		fee, err := btcutil.NewAmount(tx.Fees.Modified)
+		if err != nil {
+			return nil, err
+		}
+		ancestorFee, err := btcutil.NewAmount(tx.Fees.Ancestor)
+		if err != nil {
+			return nil, err
+		}
+
+		// A transaction can't be mined before its unconfirmed
+		// ancestors, so a miner includes it at the lower of its own
+		// fee rate and the one of its ancestor package.
+		feeRate := SatPerKVByte(fee * 1000 / btcutil.Amount(tx.VSize))
+		ancestorFeeRate := SatPerKVByte(
+			ancestorFee * 1000 / btcutil.Amount(tx.AncestorSize),
+		)
+		if ancestorFeeRate < feeRate {
+			feeRate = ancestorFeeRate
+		}
+
+		weight := tx.Weight
+		if weight == 0 {
+			weight = tx.VSize * blockchain.WitnessScaleFactor
+		}
+
+		entries = append(entries, MempoolEntry{
+			Weight:  weight,
+			FeeRate: feeRate.FeePerKWeight(),
+		})
+	}
+
+	return entries, nil
+}
+
+// FetchMinMempoolFee returns the minimum fee rate that a transaction requires
+// to enter the mempool of the bitcoind node. It's the maximum of the minimum
+// relay fee and the minimum mempool fee.
+//
+// NOTE: This method is part of the MempoolSource interface.
+func (b *BitcoindMempoolSource) FetchMinMempoolFee() (SatPerKWeight, error) {
+	return fetchBitcoindMinMempoolFee(b.bitcoindConn)
+}
+
+// A compile-time assertion to ensure that BitcoindMempoolSource implements the
+// MempoolSource interface.
+var _ MempoolSource = (*BitcoindMempoolSource)(nil)
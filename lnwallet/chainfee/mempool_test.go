@@ -0,0 +1,77 @@
+package chainfee
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestMempoolEstimator checks that the MempoolEstimator returns the fee rate
+// required to be placed within the requested number of blocks of the mempool.
+func TestMempoolEstimator(t *testing.T) {
+	t.Parallel()
+
+	const relayFee = SatPerKWeight(500)
+
+	// The mempool holds one block worth of transactions at 10000 sat/kw,
+	// and half a block at 5000 and 2000 sat/kw each.
+	half := int64(blockWeightTarget / 2)
+	entries := []MempoolEntry{
+		{Weight: half, FeeRate: 2000},
+		{Weight: half, FeeRate: 10000},
+		{Weight: half / 2, FeeRate: 5000},
+		{Weight: half / 2, FeeRate: 5000},
+		{Weight: half, FeeRate: 10000},
+		{Weight: half, FeeRate: 2000},
+	}
+
+	source := &mockMempoolSource{}
+	source.On("FetchMinMempoolFee").Return(relayFee, nil)
+	source.On("FetchMempool").Return(entries, nil)
+
+	estimator := NewMempoolEstimator(source, DefaultMempoolRefreshInterval)
+	require.NoError(t, estimator.Start())
+	defer estimator.Stop()
+
+	require.Equal(t, relayFee, estimator.RelayFeePerKW())
+
+	// To make it into the first block, we need to outbid the transactions
+	// filling it.
+	feeRate, err := estimator.EstimateFeePerKW(1)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKWeight(10001), feeRate)
+
+	// The second block is filled with the remaining transactions.
+	feeRate, err = estimator.EstimateFeePerKW(2)
+	require.NoError(t, err)
+	require.Equal(t, SatPerKWeight(2001), feeRate)
+
+	// The mempool doesn't fill three blocks, so the relay fee is enough.
+	feeRate, err = estimator.EstimateFeePerKW(3)
+	require.NoError(t, err)
+	require.Equal(t, relayFee, feeRate)
+
+	// A block position of zero is invalid.
+	_, err = estimator.EstimateFeePerKW(0)
+	require.Error(t, err)
+}
+
+// TestMempoolEstimatorNoSnapshot checks that the MempoolEstimator returns an
+// error instead of an estimate if it wasn't able to fetch the mempool.
+func TestMempoolEstimatorNoSnapshot(t *testing.T) {
+	t.Parallel()
+
+	source := &mockMempoolSource{}
+	source.On("FetchMinMempoolFee").Return(FeePerKwFloor, nil)
+	source.On("FetchMempool").Return(
+		[]MempoolEntry(nil), errors.New("unavailable"),
+	)
+
+	estimator := NewMempoolEstimator(source, DefaultMempoolRefreshInterval)
+	require.NoError(t, estimator.Start())
+	defer estimator.Stop()
+
+	_, err := estimator.EstimateFeePerKW(1)
+	require.ErrorIs(t, err, errNoMempoolSnapshot)
+}
@@ -1,6 +1,9 @@
 package chainfee
 
-import "github.com/stretchr/testify/mock"
+import (
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/stretchr/testify/mock"
+)
 
 type mockFeeSource struct {
 	mock.Mock
@@ -15,3 +18,79 @@ func (m *mockFeeSource) GetFeeMap() (map[uint32]uint32, error) {
 
 	return args.Get(0).(map[uint32]uint32), args.Error(1)
 }
+
+type mockElectrumFeeSource struct {
+	mock.Mock
+}
+
+// A compile-time assertion to ensure that mockElectrumFeeSource implements
+// the ElectrumFeeSource interface.
+var _ ElectrumFeeSource = (*mockElectrumFeeSource)(nil)
+
+func (m *mockElectrumFeeSource) EstimateFee(
+	numBlocks uint32) (btcutil.Amount, error) {
+
+	args := m.Called(numBlocks)
+
+	return args.Get(0).(btcutil.Amount), args.Error(1)
+}
+
+func (m *mockElectrumFeeSource) RelayFee() (btcutil.Amount, error) {
+	args := m.Called()
+
+	return args.Get(0).(btcutil.Amount), args.Error(1)
+}
+
+type mockEstimator struct {
+	mock.Mock
+}
+
+// A compile-time assertion to ensure that mockEstimator implements the
+// Estimator interface.
+var _ Estimator = (*mockEstimator)(nil)
+
+func (m *mockEstimator) EstimateFeePerKW(
+	numBlocks uint32) (SatPerKWeight, error) {
+
+	args := m.Called(numBlocks)
+
+	return args.Get(0).(SatPerKWeight), args.Error(1)
+}
+
+func (m *mockEstimator) Start() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *mockEstimator) Stop() error {
+	args := m.Called()
+
+	return args.Error(0)
+}
+
+func (m *mockEstimator) RelayFeePerKW() SatPerKWeight {
+	args := m.Called()
+
+	return args.Get(0).(SatPerKWeight)
+}
+
+type mockMempoolSource struct {
+	mock.Mock
+}
+
+// A compile-time assertion to ensure that mockMempoolSource implements the
+// MempoolSource interface.
+var _ MempoolSource = (*mockMempoolSource)(nil)
+
+func (m *mockMempoolSource) FetchMempool() ([]MempoolEntry, error) {
+	args := m.Called()
+
+	return args.Get(0).([]MempoolEntry), args.Error(1)
+}
+
+func (m *mockMempoolSource) FetchMinMempoolFee() (SatPerKWeight, error) {
+	args := m.Called()
+
+	return args.Get(0).(SatPerKWeight), args.Error(1)
+}
@@ -23,6 +23,13 @@ type CoinSource interface {
 	CoinFromOutPoint(wire.OutPoint) (*wallet.Coin, error)
 }
 
+// CoinInfoSource is an optional interface of a CoinSource that provides the
+// wallet metadata of its coins to the coin selection strategies that use it.
+type CoinInfoSource interface {
+	// CoinInfo returns the wallet metadata of all coins of the source.
+	CoinInfo() (map[wire.OutPoint]*CoinInfo, error)
+}
+
 // CoinSelectionLocker is an interface that allows the caller to perform an
 // operation, which is synchronized with all coin selection attempts. This can
 // be used when an operation requires that all coin selection operations cease
@@ -88,6 +88,29 @@ func selectInputs(amt btcutil.Amount, coins []wallet.Coin,
 	return 0, nil, &ErrInsufficientFunds{amt, satSelected}
 }
 
+// addInputWeight adds the weight of spending an output with the given script
+// to the weight estimator. An error is returned for scripts the wallet can't
+// spend.
+func addInputWeight(weightEstimate *input.TxWeightEstimator,
+	pkScript []byte) error {
+
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(pkScript):
+		weightEstimate.AddP2WKHInput()
+
+	case txscript.IsPayToScriptHash(pkScript):
+		weightEstimate.AddNestedP2WKHInput()
+
+	case txscript.IsPayToTaproot(pkScript):
+		weightEstimate.AddTaprootKeySpendInput(txscript.SigHashDefault)
+
+	default:
+		return &errUnsupportedInput{pkScript}
+	}
+
+	return nil
+}
+
 // calculateFees returns for the specified utxos and fee rate two fee
 // estimates, one calculated using a change output and one without. The weight
 // added to the estimator from a change output is for a P2WKH output.
@@ -97,20 +120,9 @@ func calculateFees(utxos []wallet.Coin, feeRate chainfee.SatPerKWeight,
 
 	weightEstimate := existingWeight
 	for _, utxo := range utxos {
-		switch {
-		case txscript.IsPayToWitnessPubKeyHash(utxo.PkScript):
-			weightEstimate.AddP2WKHInput()
-
-		case txscript.IsPayToScriptHash(utxo.PkScript):
-			weightEstimate.AddNestedP2WKHInput()
-
-		case txscript.IsPayToTaproot(utxo.PkScript):
-			weightEstimate.AddTaprootKeySpendInput(
-				txscript.SigHashDefault,
-			)
-
-		default:
-			return 0, 0, &errUnsupportedInput{utxo.PkScript}
+		err := addInputWeight(&weightEstimate, utxo.PkScript)
+		if err != nil {
+			return 0, 0, err
 		}
 	}
 
@@ -159,12 +171,34 @@ func sanityCheckFee(totalOut, fee btcutil.Amount) error {
 // CoinSelect attempts to select a sufficient amount of coins, including a
 // change output to fund amt satoshis, adhering to the specified fee rate. The
 // specified fee rate should be expressed in sat/kw for coin selection to
-// function properly.
+// function properly. If the strategy is a ContextualStrategy, a selection that
+// doesn't need a change output is preferred.
 func CoinSelect(feeRate chainfee.SatPerKWeight, amt, dustLimit btcutil.Amount,
 	coins []wallet.Coin, strategy wallet.CoinSelectionStrategy,
 	existingWeight input.TxWeightEstimator,
 	changeType ChangeAddressType) ([]wallet.Coin, btcutil.Amount, error) {
 
+	if s, ok := strategy.(ContextualStrategy); ok {
+		return contextualCoinSelect(
+			feeRate, amt, dustLimit, coins, s, existingWeight,
+			changeType,
+		)
+	}
+
+	return coinSelect(
+		feeRate, amt, dustLimit, coins, strategy, existingWeight,
+		changeType,
+	)
+}
+
+// coinSelect selects the coins in the order the strategy arranges them until
+// they fund amt and the fees, including a change output if there is enough
+// left over for one.
+func coinSelect(feeRate chainfee.SatPerKWeight, amt, dustLimit btcutil.Amount,
+	coins []wallet.Coin, strategy wallet.CoinSelectionStrategy,
+	existingWeight input.TxWeightEstimator,
+	changeType ChangeAddressType) ([]wallet.Coin, btcutil.Amount, error) {
+
 	amtNeeded := amt
 	for {
 		// First perform an initial round of coin selection to estimate
@@ -284,6 +318,13 @@ func CoinSelectSubtractFees(feeRate chainfee.SatPerKWeight, amt,
 	changeType ChangeAddressType) ([]wallet.Coin, btcutil.Amount,
 	btcutil.Amount, error) {
 
+	// Strategies that pick the coins for the amount only select from their
+	// preferred set of coins. As everything goes to the output, there is
+	// no point in looking for a selection without change.
+	if s, ok := strategy.(ContextualStrategy); ok {
+		coins = s.candidateSets(coins, amt)[0]
+	}
+
 	// First perform an initial round of coin selection to estimate
 	// the required fee.
 	totalSat, selectedUtxos, err := selectInputs(
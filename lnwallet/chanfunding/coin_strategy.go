@@ -0,0 +1,583 @@
+package chanfunding
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+const (
+	// DefaultLongTermFeeRate is the fee rate we expect to pay for spending
+	// a coin in the long run, which is 10 sat/vbyte. Spending a coin at a
+	// higher fee rate is considered a waste of fees, spending it at a lower
+	// fee rate saves fees compared to spending it later.
+	DefaultLongTermFeeRate = chainfee.SatPerKWeight(2500)
+
+	// maxBranchAndBoundTries is the maximum number of branches the branch
+	// and bound search visits before it gives up.
+	maxBranchAndBoundTries = 100_000
+)
+
+var (
+	// CoinSelectionBranchAndBound is a coin selection strategy that
+	// searches for the set of coins that funds the target without a change
+	// output and wastes the least fees. If there is no such set, the
+	// largest coins are selected first.
+	CoinSelectionBranchAndBound = &BranchAndBoundCoinSelector{}
+
+	// CoinSelectionPrivacy is a coin selection strategy that avoids
+	// spending coins with different labels, address types or from
+	// different accounts together and never spends unconfirmed coins.
+	CoinSelectionPrivacy = &PrivacyCoinSelector{}
+
+	// errNoChangelessSelection is returned if there is no set of coins
+	// that funds the target without a change output.
+	errNoChangelessSelection = errors.New("no changeless selection found")
+)
+
+// CoinInfo is the wallet metadata of a coin that is used by coin selection
+// strategies to decide which coins can be spent together.
+type CoinInfo struct {
+	// Account is the name of the wallet account the coin belongs to.
+	Account string
+
+	// Label is the label of the transaction that created the coin.
+	Label string
+
+	// Confirmations is the number of confirmations of the coin.
+	Confirmations int64
+}
+
+// ContextualStrategy is a coin selection strategy that picks the coins for a
+// specific target amount instead of only arranging them. It is applied by
+// CoinSelect and the functions built on top of it. As btcwallet only calls
+// ArrangeCoins, callers that let btcwallet create the transaction need to
+// select the coins with CoinSelect first.
+type ContextualStrategy interface {
+	wallet.CoinSelectionStrategy
+
+	// NeedsCoinInfo returns true if the strategy makes use of the wallet
+	// metadata of the coins.
+	NeedsCoinInfo() bool
+
+	// WithCoinInfo returns a copy of the strategy that uses the given
+	// wallet metadata of the coins.
+	WithCoinInfo(info map[wire.OutPoint]*CoinInfo) ContextualStrategy
+
+	// candidateSets returns the sets of coins that may be spent together
+	// to fund the given amount, in the order of preference. At least one
+	// set is always returned.
+	candidateSets(coins []wallet.Coin, amt btcutil.Amount) [][]wallet.Coin
+}
+
+// BranchAndBoundCoinSelector is an implementation of the ContextualStrategy
+// that prefers the selection without a change output that wastes the least
+// fees.
+type BranchAndBoundCoinSelector struct{}
+
+// A compile-time assertion to ensure BranchAndBoundCoinSelector meets the
+// ContextualStrategy interface.
+var _ ContextualStrategy = (*BranchAndBoundCoinSelector)(nil)
+
+// ArrangeCoins arranges the coins that are worth spending at the given fee
+// rate largest first. This is the order in which coins are selected if there
+// is no selection without a change output.
+//
+// NOTE: This is part of the wallet.CoinSelectionStrategy interface.
+func (*BranchAndBoundCoinSelector) ArrangeCoins(eligible []wallet.Coin,
+	feeSatPerKb btcutil.Amount) ([]wallet.Coin, error) {
+
+	return largestPositiveFirst(eligible, feeSatPerKb), nil
+}
+
+// NeedsCoinInfo returns false as the coins are selected by their value only.
+//
+// NOTE: This is part of the ContextualStrategy interface.
+func (*BranchAndBoundCoinSelector) NeedsCoinInfo() bool {
+	return false
+}
+
+// WithCoinInfo returns the strategy itself as it doesn't use the coin info.
+//
+// NOTE: This is part of the ContextualStrategy interface.
+func (b *BranchAndBoundCoinSelector) WithCoinInfo(
+	_ map[wire.OutPoint]*CoinInfo) ContextualStrategy {
+
+	return b
+}
+
+// candidateSets returns all coins as a single set.
+//
+// NOTE: This is part of the ContextualStrategy interface.
+func (*BranchAndBoundCoinSelector) candidateSets(coins []wallet.Coin,
+	_ btcutil.Amount) [][]wallet.Coin {
+
+	return [][]wallet.Coin{coins}
+}
+
+// PrivacyCoinSelector is an implementation of the ContextualStrategy that
+// avoids linking coins that an observer can't tell belong together. Coins are
+// grouped by their account, label and address type and a transaction only
+// spends coins of a single group if any of them can fund it. Coins that are
+// known to be unconfirmed are never spent.
+type PrivacyCoinSelector struct {
+	coinInfo map[wire.OutPoint]*CoinInfo
+}
+
+// A compile-time assertion to ensure PrivacyCoinSelector meets the
+// ContextualStrategy interface.
+var _ ContextualStrategy = (*PrivacyCoinSelector)(nil)
+
+// ArrangeCoins arranges the confirmed coins group by group, starting with the
+// group of the largest total value, so that as few groups as possible are
+// mixed. The coins of a group are arranged largest first.
+//
+// NOTE: This is part of the wallet.CoinSelectionStrategy interface.
+func (p *PrivacyCoinSelector) ArrangeCoins(eligible []wallet.Coin,
+	feeSatPerKb btcutil.Amount) ([]wallet.Coin, error) {
+
+	groups := p.groups(eligible)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return sumCoins(groups[i]) > sumCoins(groups[j])
+	})
+
+	var arranged []wallet.Coin
+	for _, group := range groups {
+		arranged = append(
+			arranged, largestPositiveFirst(group, feeSatPerKb)...,
+		)
+	}
+
+	return arranged, nil
+}
+
+// NeedsCoinInfo returns true as the coins are grouped by their wallet
+// metadata.
+//
+// NOTE: This is part of the ContextualStrategy interface.
+func (*PrivacyCoinSelector) NeedsCoinInfo() bool {
+	return true
+}
+
+// WithCoinInfo returns a copy of the strategy that groups the coins by the
+// given wallet metadata.
+//
+// NOTE: This is part of the ContextualStrategy interface.
+func (*PrivacyCoinSelector) WithCoinInfo(
+	info map[wire.OutPoint]*CoinInfo) ContextualStrategy {
+
+	return &PrivacyCoinSelector{coinInfo: info}
+}
+
+// candidateSets returns the groups that can fund the amount on their own,
+// smallest first, followed by all confirmed coins as a last resort.
+//
+// NOTE: This is part of the ContextualStrategy interface.
+func (p *PrivacyCoinSelector) candidateSets(coins []wallet.Coin,
+	amt btcutil.Amount) [][]wallet.Coin {
+
+	groups := p.groups(coins)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return sumCoins(groups[i]) < sumCoins(groups[j])
+	})
+
+	var (
+		sets      [][]wallet.Coin
+		confirmed []wallet.Coin
+	)
+	for _, group := range groups {
+		if sumCoins(group) >= amt {
+			sets = append(sets, group)
+		}
+
+		confirmed = append(confirmed, group...)
+	}
+
+	// Mixing the groups is the last resort in case none of them can fund
+	// the amount on its own.
+	if len(sets) == 0 || len(groups) > 1 {
+		sets = append(sets, confirmed)
+	}
+
+	return sets
+}
+
+// groups returns the confirmed coins grouped by their account, label and
+// address type. Coins without wallet metadata are grouped by their address
+// type only.
+func (p *PrivacyCoinSelector) groups(coins []wallet.Coin) [][]wallet.Coin {
+	type groupKey struct {
+		account string
+		label   string
+		class   txscript.ScriptClass
+	}
+
+	var (
+		keys   []groupKey
+		groups = make(map[groupKey][]wallet.Coin)
+	)
+	for _, coin := range coins {
+		key := groupKey{
+			class: txscript.GetScriptClass(coin.PkScript),
+		}
+
+		if info, ok := p.coinInfo[coin.OutPoint]; ok {
+			if info.Confirmations == 0 {
+				continue
+			}
+
+			key.account = info.Account
+			key.label = info.Label
+		}
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], coin)
+	}
+
+	result := make([][]wallet.Coin, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, groups[key])
+	}
+
+	return result
+}
+
+// contextualCoinSelect selects coins from the candidate sets of the strategy.
+// A changeless selection from any of the sets is preferred over a selection
+// with change from the preferred set.
+func contextualCoinSelect(feeRate chainfee.SatPerKWeight, amt,
+	dustLimit btcutil.Amount, coins []wallet.Coin,
+	strategy ContextualStrategy, existingWeight input.TxWeightEstimator,
+	changeType ChangeAddressType) ([]wallet.Coin, btcutil.Amount, error) {
+
+	sets := strategy.candidateSets(coins, amt)
+
+	// If the change goes to an existing output, there is no change output
+	// we could avoid.
+	if changeType != ExistingChangeAddress {
+		for _, set := range sets {
+			selected, err := selectChangeless(
+				feeRate, amt, dustLimit, set, existingWeight,
+				changeType,
+			)
+			if err == nil {
+				return selected, 0, nil
+			}
+		}
+	}
+
+	var err error
+	for _, set := range sets {
+		var (
+			selected  []wallet.Coin
+			changeAmt btcutil.Amount
+		)
+		selected, changeAmt, err = coinSelect(
+			feeRate, amt, dustLimit, set, strategy, existingWeight,
+			changeType,
+		)
+		if err == nil {
+			return selected, changeAmt, nil
+		}
+	}
+
+	return nil, 0, err
+}
+
+// bnbCandidate is a coin considered by the branch and bound search.
+type bnbCandidate struct {
+	coin wallet.Coin
+
+	// effectiveValue is the value of the coin minus the fee for spending
+	// it.
+	effectiveValue btcutil.Amount
+
+	// waste is the fee for spending the coin now minus the fee for
+	// spending it at the long term fee rate.
+	waste btcutil.Amount
+}
+
+// selectChangeless uses a branch and bound search to find the set of coins
+// that funds amt and the fees without a change output and has the least
+// waste. The excess value of such a set goes to fees, it is at most the
+// amount a change output would have had to be worth to not be dust.
+func selectChangeless(feeRate chainfee.SatPerKWeight, amt,
+	dustLimit btcutil.Amount, coins []wallet.Coin,
+	existingWeight input.TxWeightEstimator,
+	changeType ChangeAddressType) ([]wallet.Coin, error) {
+
+	// The search works with the effective values of the coins, so the
+	// target is the amount plus the fee of the transaction without any
+	// inputs. We account for the witness header, as all inputs we can spend
+	// are witness inputs.
+	txFee := feeRate.FeeForWeight(
+		int64(existingWeight.Weight() + input.WitnessHeaderSize),
+	)
+	target := amt + txFee
+
+	// A set of coins is changeless as long as the change output wouldn't be
+	// worth its fee plus the dust limit.
+	changeFee := feeRate.FeeForWeight(changeOutputWeight(changeType))
+	upper := target + changeFee + dustLimit - 1
+
+	candidates := make([]bnbCandidate, 0, len(coins))
+	for _, coin := range coins {
+		weight, err := inputWeight(coin.PkScript)
+		if err != nil {
+			continue
+		}
+
+		fee := feeRate.FeeForWeight(weight)
+		effectiveValue := btcutil.Amount(coin.Value) - fee
+		if effectiveValue <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, bnbCandidate{
+			coin:           coin,
+			effectiveValue: effectiveValue,
+			waste: fee - DefaultLongTermFeeRate.FeeForWeight(
+				weight,
+			),
+		})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].effectiveValue >
+			candidates[j].effectiveValue
+	})
+
+	// remaining[i] is the total effective value of the candidates from i
+	// onwards, it allows us to cut branches that can't reach the target.
+	remaining := make([]btcutil.Amount, len(candidates)+1)
+	for i := len(candidates) - 1; i >= 0; i-- {
+		remaining[i] = remaining[i+1] + candidates[i].effectiveValue
+	}
+
+	var (
+		tries     int
+		current   []int
+		best      []int
+		bestWaste btcutil.Amount
+	)
+
+	var search func(idx int, value, waste btcutil.Amount)
+	search = func(idx int, value, waste btcutil.Amount) {
+		tries++
+		if tries > maxBranchAndBoundTries || value > upper {
+			return
+		}
+
+		// The excess value is given up to fees, so it counts as waste.
+		// Adding more coins only increases the excess.
+		if value >= target {
+			waste += value - target
+			if best == nil || waste < bestWaste {
+				best = append([]int(nil), current...)
+				bestWaste = waste
+			}
+
+			return
+		}
+
+		if idx == len(candidates) || value+remaining[idx] < target {
+			return
+		}
+
+		// If the fee rate is above the long term fee rate, every coin
+		// we add increases the waste.
+		if feeRate > DefaultLongTermFeeRate && best != nil &&
+			waste >= bestWaste {
+
+			return
+		}
+
+		current = append(current, idx)
+		search(
+			idx+1, value+candidates[idx].effectiveValue,
+			waste+candidates[idx].waste,
+		)
+		current = current[:len(current)-1]
+
+		// Leaving out this coin but adding an equivalent one instead
+		// leads to the same results, so we skip those.
+		next := idx + 1
+		for next < len(candidates) &&
+			candidates[next].effectiveValue ==
+				candidates[idx].effectiveValue &&
+			candidates[next].waste == candidates[idx].waste {
+
+			next++
+		}
+		search(next, value, waste)
+	}
+	search(0, 0, 0)
+
+	if best == nil {
+		return nil, errNoChangelessSelection
+	}
+
+	selected := make([]wallet.Coin, len(best))
+	for i, idx := range best {
+		selected[i] = candidates[idx].coin
+	}
+
+	// The search uses an estimate of the weight each input adds, so we
+	// make sure the selection is changeless with the exact fees.
+	feeNoChange, feeWithChange, err := calculateFees(
+		selected, feeRate, existingWeight, changeType,
+	)
+	if err != nil {
+		return nil, err
+	}
+	changeAmt, needMore, err := CalculateChangeAmount(
+		sumCoins(selected), amt, feeNoChange, feeWithChange, dustLimit,
+		changeType,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if changeAmt != 0 || needMore != 0 {
+		return nil, errNoChangelessSelection
+	}
+
+	log.Debugf("Selected %d coins without change with a waste of %v",
+		len(selected), bestWaste)
+
+	return selected, nil
+}
+
+// SelectionWaste returns the waste of funding amt with the given coins and
+// change amount. The waste is the fee for spending the coins now minus the fee
+// for spending them at the long term fee rate, plus either the cost of creating
+// and later spending the change output or, without change, the excess value
+// that goes to fees. A lower waste is better, it is negative if spending the
+// coins now is cheaper than spending them later.
+func SelectionWaste(coins []wallet.Coin, amt, changeAmt btcutil.Amount,
+	feeRate, longTermFeeRate chainfee.SatPerKWeight,
+	existingWeight input.TxWeightEstimator,
+	changeType ChangeAddressType) (btcutil.Amount, error) {
+
+	var waste btcutil.Amount
+	for _, coin := range coins {
+		weight, err := inputWeight(coin.PkScript)
+		if err != nil {
+			return 0, err
+		}
+
+		waste += feeRate.FeeForWeight(weight) -
+			longTermFeeRate.FeeForWeight(weight)
+	}
+
+	feeNoChange, feeWithChange, err := calculateFees(
+		coins, feeRate, existingWeight, changeType,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	// Change that goes to an existing output doesn't cost anything extra.
+	case changeType == ExistingChangeAddress:
+
+	case changeAmt > 0:
+		waste += feeWithChange - feeNoChange
+		waste += longTermFeeRate.FeeForWeight(
+			changeSpendWeight(changeType),
+		)
+
+	default:
+		waste += sumCoins(coins) - amt - feeNoChange
+	}
+
+	return waste, nil
+}
+
+// inputWeight returns the weight an input that spends an output with the given
+// script adds to a transaction that already has witness inputs.
+func inputWeight(pkScript []byte) (int64, error) {
+	var base input.TxWeightEstimator
+	base.AddP2WKHInput()
+
+	withInput := base
+	if err := addInputWeight(&withInput, pkScript); err != nil {
+		return 0, err
+	}
+
+	return int64(withInput.Weight() - base.Weight()), nil
+}
+
+// changeOutputWeight returns the weight a change output of the given type adds
+// to a transaction.
+func changeOutputWeight(changeType ChangeAddressType) int64 {
+	switch changeType {
+	case P2TRChangeAddress:
+		return input.P2TROutputSize * 4
+
+	case P2WKHChangeAddress:
+		return input.P2WKHOutputSize * 4
+
+	default:
+		return 0
+	}
+}
+
+// changeSpendWeight returns the weight of the input that spends a change
+// output of the given type later.
+func changeSpendWeight(changeType ChangeAddressType) int64 {
+	var base input.TxWeightEstimator
+	base.AddP2WKHInput()
+
+	withInput := base
+	switch changeType {
+	case P2TRChangeAddress:
+		withInput.AddTaprootKeySpendInput(txscript.SigHashDefault)
+
+	case P2WKHChangeAddress:
+		withInput.AddP2WKHInput()
+	}
+
+	return int64(withInput.Weight() - base.Weight())
+}
+
+// largestPositiveFirst returns the coins that are worth more than the fee for
+// spending them at the given fee rate, largest first.
+func largestPositiveFirst(coins []wallet.Coin,
+	feeSatPerKb btcutil.Amount) []wallet.Coin {
+
+	feeRate := chainfee.SatPerKVByte(feeSatPerKb).FeePerKWeight()
+
+	positive := make([]wallet.Coin, 0, len(coins))
+	for _, coin := range coins {
+		weight, err := inputWeight(coin.PkScript)
+		if err != nil {
+			continue
+		}
+
+		if btcutil.Amount(coin.Value) > feeRate.FeeForWeight(weight) {
+			positive = append(positive, coin)
+		}
+	}
+	sort.SliceStable(positive, func(i, j int) bool {
+		return positive[i].Value > positive[j].Value
+	})
+
+	return positive
+}
+
+// sumCoins returns the total value of the given coins.
+func sumCoins(coins []wallet.Coin) btcutil.Amount {
+	var total btcutil.Amount
+	for _, coin := range coins {
+		total += btcutil.Amount(coin.Value)
+	}
+
+	return total
+}
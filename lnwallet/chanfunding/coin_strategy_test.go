@@ -0,0 +1,313 @@
+package chanfunding
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/stretchr/testify/require"
+)
+
+// testCoin returns a P2WKH coin of the given value with a unique outpoint.
+func testCoin(index uint32, value btcutil.Amount) wallet.Coin {
+	return wallet.Coin{
+		TxOut: wire.TxOut{
+			PkScript: p2wkhScript,
+			Value:    int64(value),
+		},
+		OutPoint: wire.OutPoint{Index: index},
+	}
+}
+
+// TestBranchAndBoundCoinSelect tests that the branch and bound strategy
+// prefers a selection without change and falls back to a selection with change
+// if there is none.
+func TestBranchAndBoundCoinSelect(t *testing.T) {
+	t.Parallel()
+
+	const (
+		feeRate   = chainfee.SatPerKWeight(100)
+		dustLimit = btcutil.Amount(1000)
+		fullCoin  = btcutil.SatoshiPerBitcoin
+	)
+
+	type testCase struct {
+		name        string
+		outputValue btcutil.Amount
+		coins       []wallet.Coin
+
+		expectedInput  []btcutil.Amount
+		expectedChange btcutil.Amount
+	}
+
+	testCases := []testCase{
+		{
+			// The two smaller coins match the amount and the fees
+			// exactly, so they are preferred over the large coin
+			// the largest first strategy would pick.
+			name: "exact match",
+			coins: []wallet.Coin{
+				testCoin(0, 1*fullCoin),
+				testCoin(1, 0.3*fullCoin),
+				testCoin(2, 0.2*fullCoin),
+			},
+			outputValue: 0.5*fullCoin -
+				fundingFee(feeRate, 2, false),
+
+			expectedInput: []btcutil.Amount{
+				0.3 * fullCoin, 0.2 * fullCoin,
+			},
+		},
+		{
+			// The excess value of the two smaller coins isn't
+			// enough for a change output above the dust limit, so
+			// it goes to the fees.
+			name: "excess below dust",
+			coins: []wallet.Coin{
+				testCoin(0, 1*fullCoin),
+				testCoin(1, 0.3*fullCoin),
+				testCoin(2, 0.2*fullCoin),
+			},
+			outputValue: 0.5*fullCoin -
+				fundingFee(feeRate, 2, false) - 500,
+
+			expectedInput: []btcutil.Amount{
+				0.3 * fullCoin, 0.2 * fullCoin,
+			},
+		},
+		{
+			// There is no selection without change, so we fall
+			// back to the largest coin first.
+			name: "change needed",
+			coins: []wallet.Coin{
+				testCoin(0, 0.3*fullCoin),
+				testCoin(1, 1*fullCoin),
+			},
+			outputValue: 0.5 * fullCoin,
+
+			expectedInput: []btcutil.Amount{
+				1 * fullCoin,
+			},
+			expectedChange: 0.5*fullCoin -
+				fundingFee(feeRate, 1, true),
+		},
+	}
+
+	fundingOutputEstimate := input.TxWeightEstimator{}
+	fundingOutputEstimate.AddP2WSHOutput()
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			selected, changeAmt, err := CoinSelect(
+				feeRate, test.outputValue, dustLimit,
+				test.coins, CoinSelectionBranchAndBound,
+				fundingOutputEstimate,
+				defaultChanFundingChangeType,
+			)
+			require.NoError(t, err)
+
+			require.Len(t, selected, len(test.expectedInput))
+			for i, coin := range selected {
+				require.EqualValues(
+					t, test.expectedInput[i], coin.Value,
+				)
+			}
+
+			require.EqualValues(t, test.expectedChange, changeAmt)
+		})
+	}
+}
+
+// TestPrivacyCoinSelect tests that the privacy strategy only mixes coins of
+// different groups if none of the groups can fund the amount on its own and
+// that it never spends unconfirmed coins.
+func TestPrivacyCoinSelect(t *testing.T) {
+	t.Parallel()
+
+	const (
+		feeRate   = chainfee.SatPerKWeight(100)
+		dustLimit = btcutil.Amount(1000)
+		fullCoin  = btcutil.SatoshiPerBitcoin
+	)
+
+	coins := []wallet.Coin{
+		testCoin(0, 0.4*fullCoin),
+		testCoin(1, 0.3*fullCoin),
+		testCoin(2, 0.6*fullCoin),
+		testCoin(3, 2*fullCoin),
+	}
+	strategy := CoinSelectionPrivacy.WithCoinInfo(
+		map[wire.OutPoint]*CoinInfo{
+			coins[0].OutPoint: {Label: "a", Confirmations: 1},
+			coins[1].OutPoint: {Label: "a", Confirmations: 1},
+			coins[2].OutPoint: {Label: "b", Confirmations: 1},
+			coins[3].OutPoint: {Label: "c", Confirmations: 0},
+		},
+	)
+
+	type testCase struct {
+		name        string
+		outputValue btcutil.Amount
+
+		expectedInput  []btcutil.Amount
+		expectedChange btcutil.Amount
+		expectErr      bool
+	}
+
+	testCases := []testCase{
+		{
+			// Both groups can fund the amount, the smaller one is
+			// used.
+			name:        "smallest group",
+			outputValue: 0.5 * fullCoin,
+
+			expectedInput: []btcutil.Amount{
+				0.6 * fullCoin,
+			},
+			expectedChange: 0.1*fullCoin -
+				fundingFee(feeRate, 1, true),
+		},
+		{
+			// Only the group of two coins can fund the amount,
+			// without change.
+			name: "single group changeless",
+			outputValue: 0.7*fullCoin -
+				fundingFee(feeRate, 2, false),
+
+			expectedInput: []btcutil.Amount{
+				0.4 * fullCoin, 0.3 * fullCoin,
+			},
+		},
+		{
+			// None of the groups can fund the amount on its own,
+			// so they are mixed, starting with the larger group.
+			// The unconfirmed coin is never used.
+			name:        "mixed groups",
+			outputValue: 0.9 * fullCoin,
+
+			expectedInput: []btcutil.Amount{
+				0.4 * fullCoin, 0.3 * fullCoin, 0.6 * fullCoin,
+			},
+			expectedChange: 0.4*fullCoin -
+				fundingFee(feeRate, 3, true),
+		},
+		{
+			// Only the unconfirmed coin could fund the amount.
+			name:        "unconfirmed coin excluded",
+			outputValue: 1.5 * fullCoin,
+			expectErr:   true,
+		},
+	}
+
+	fundingOutputEstimate := input.TxWeightEstimator{}
+	fundingOutputEstimate.AddP2WSHOutput()
+
+	for _, test := range testCases {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			selected, changeAmt, err := CoinSelect(
+				feeRate, test.outputValue, dustLimit, coins,
+				strategy, fundingOutputEstimate,
+				defaultChanFundingChangeType,
+			)
+			if test.expectErr {
+				require.Error(t, err)
+
+				return
+			}
+			require.NoError(t, err)
+
+			require.Len(t, selected, len(test.expectedInput))
+			for i, coin := range selected {
+				require.EqualValues(
+					t, test.expectedInput[i], coin.Value,
+				)
+			}
+
+			require.EqualValues(t, test.expectedChange, changeAmt)
+		})
+	}
+}
+
+// TestSelectionWaste tests the waste of selections with and without change.
+func TestSelectionWaste(t *testing.T) {
+	t.Parallel()
+
+	const (
+		feeRate         = chainfee.SatPerKWeight(5000)
+		longTermFeeRate = chainfee.SatPerKWeight(1000)
+		amt             = btcutil.Amount(100_000)
+	)
+
+	fundingOutputEstimate := input.TxWeightEstimator{}
+	fundingOutputEstimate.AddP2WSHOutput()
+
+	coins := []wallet.Coin{testCoin(0, 150_000), testCoin(1, 50_000)}
+
+	var inputWaste btcutil.Amount
+	for _, coin := range coins {
+		weight, err := inputWeight(coin.PkScript)
+		require.NoError(t, err)
+
+		inputWaste += feeRate.FeeForWeight(weight) -
+			longTermFeeRate.FeeForWeight(weight)
+	}
+
+	feeNoChange, feeWithChange, err := calculateFees(
+		coins, feeRate, fundingOutputEstimate, P2TRChangeAddress,
+	)
+	require.NoError(t, err)
+
+	// With change, the cost of creating and spending the change output is
+	// added.
+	waste, err := SelectionWaste(
+		coins, amt, 1000, feeRate, longTermFeeRate,
+		fundingOutputEstimate, P2TRChangeAddress,
+	)
+	require.NoError(t, err)
+	require.Equal(
+		t, inputWaste+feeWithChange-feeNoChange+
+			longTermFeeRate.FeeForWeight(
+				changeSpendWeight(P2TRChangeAddress),
+			), waste,
+	)
+
+	// Without change, the excess value is added.
+	waste, err = SelectionWaste(
+		coins, amt, 0, feeRate, longTermFeeRate,
+		fundingOutputEstimate, P2TRChangeAddress,
+	)
+	require.NoError(t, err)
+	require.Equal(t, inputWaste+100_000-feeNoChange, waste)
+
+	// Change that goes to an existing output doesn't add any waste.
+	waste, err = SelectionWaste(
+		coins, amt, 1000, feeRate, longTermFeeRate,
+		fundingOutputEstimate, ExistingChangeAddress,
+	)
+	require.NoError(t, err)
+	require.Equal(t, inputWaste, waste)
+
+	// Spending the coins at a fee rate below the long term fee rate has a
+	// negative waste.
+	lowFeeRate := longTermFeeRate / 2
+	lowFeeNoChange, _, err := calculateFees(
+		coins, lowFeeRate, fundingOutputEstimate, P2TRChangeAddress,
+	)
+	require.NoError(t, err)
+
+	waste, err = SelectionWaste(
+		coins, sumCoins(coins)-lowFeeNoChange, 0, lowFeeRate,
+		longTermFeeRate, fundingOutputEstimate, P2TRChangeAddress,
+	)
+	require.NoError(t, err)
+	require.Negative(t, waste)
+}
@@ -258,6 +258,12 @@ type WalletConfig struct {
 	// DustLimit is the current dust limit. We'll use this to ensure that
 	// we don't make dust outputs on the funding transaction.
 	DustLimit btcutil.Amount
+
+	// ChangeAddr is an optional closure that provides the change address
+	// instead of the ChangeAddr of the request. It is used when the coins
+	// are taken from a wallet other than the one the request was created
+	// by, so that the change goes back to the funding wallet.
+	ChangeAddr func() (btcutil.Address, error)
 }
 
 // WalletAssembler is an instance of the Assembler interface that is backed by
@@ -277,6 +283,30 @@ func NewWalletAssembler(cfg WalletConfig) *WalletAssembler {
 	}
 }
 
+// coinSelectionStrategy returns the configured coin selection strategy. If the
+// strategy makes use of the wallet metadata of the coins, it is provided with
+// the metadata if the coin source supports it.
+func (w *WalletAssembler) coinSelectionStrategy() (wallet.CoinSelectionStrategy,
+	error) {
+
+	strategy, ok := w.cfg.CoinSelectionStrategy.(ContextualStrategy)
+	if !ok || !strategy.NeedsCoinInfo() {
+		return w.cfg.CoinSelectionStrategy, nil
+	}
+
+	infoSource, ok := w.cfg.CoinSource.(CoinInfoSource)
+	if !ok {
+		return strategy, nil
+	}
+
+	info, err := infoSource.CoinInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	return strategy.WithCoinInfo(info), nil
+}
+
 // ProvisionChannel is the main entry point to begin a funding workflow given a
 // fully populated request. The internal WalletAssembler will perform coin
 // selection in a goroutine safe manner, returning an Intent that will allow
@@ -365,6 +395,11 @@ func (w *WalletAssembler) ProvisionChannel(r *Request) (Intent, error) {
 			coins = manuallySelectedCoins
 		}
 
+		strategy, err := w.coinSelectionStrategy()
+		if err != nil {
+			return err
+		}
+
 		// Perform coin selection over our available, unlocked unspent
 		// outputs in order to find enough coins to meet the funding
 		// amount requirements.
@@ -434,7 +469,7 @@ func (w *WalletAssembler) ProvisionChannel(r *Request) (Intent, error) {
 				err = CoinSelectUpToAmount(
 				r.FeeRate, r.MinFundAmt, r.FundUpToMaxAmt,
 				reserve, w.cfg.DustLimit, coins,
-				w.cfg.CoinSelectionStrategy,
+				strategy,
 				fundingOutputWeight, changeType,
 			)
 			if err != nil {
@@ -469,7 +504,7 @@ func (w *WalletAssembler) ProvisionChannel(r *Request) (Intent, error) {
 			selectedCoins, localContributionAmt, changeAmt,
 				err = CoinSelectSubtractFees(
 				r.FeeRate, r.LocalAmt, dustLimit, coins,
-				w.cfg.CoinSelectionStrategy,
+				strategy,
 				fundingOutputWeight, changeType,
 			)
 			if err != nil {
@@ -483,7 +518,7 @@ func (w *WalletAssembler) ProvisionChannel(r *Request) (Intent, error) {
 			localContributionAmt = r.LocalAmt
 			selectedCoins, changeAmt, err = CoinSelect(
 				r.FeeRate, r.LocalAmt, dustLimit, coins,
-				w.cfg.CoinSelectionStrategy,
+				strategy,
 				fundingOutputWeight, changeType,
 			)
 			if err != nil {
@@ -503,7 +538,12 @@ func (w *WalletAssembler) ProvisionChannel(r *Request) (Intent, error) {
 		// coin selection.
 		var changeOutput *wire.TxOut
 		if changeAmt != 0 {
-			changeAddr, err := r.ChangeAddr()
+			newChangeAddr := r.ChangeAddr
+			if w.cfg.ChangeAddr != nil {
+				newChangeAddr = w.cfg.ChangeAddr
+			}
+
+			changeAddr, err := newChangeAddr()
 			if err != nil {
 				return err
 			}
@@ -33,9 +33,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/macaroons"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
-	"google.golang.org/grpc/status"
 	"gopkg.in/macaroon.v2"
 )
 
@@ -60,10 +58,9 @@ type RPCKeyRing struct {
 
 	netParams *chaincfg.Params
 
-	rpcTimeout time.Duration
-
-	signerClient signrpc.SignerClient
-	walletClient walletrpc.WalletKitClient
+	// signers are the remote signers the signing operations are delegated
+	// to, in the order of their priority.
+	signers *signerPool
 }
 
 var _ keychain.SecretKeyRing = (*RPCKeyRing)(nil)
@@ -74,30 +71,43 @@ var _ lnwallet.WalletController = (*RPCKeyRing)(nil)
 // NewRPCKeyRing creates a new remote signing secret key ring that uses the
 // given watch-only base wallet to keep track of addresses and transactions but
 // delegates any signing or ECDH operations to the remove signer through RPC.
+// If more than one remote signer is configured, the operations are delegated
+// to the first reachable signer in the order of their priority.
 func NewRPCKeyRing(watchOnlyKeyRing keychain.SecretKeyRing,
 	watchOnlyWalletController lnwallet.WalletController,
 	remoteSigner *lncfg.RemoteSigner,
 	netParams *chaincfg.Params) (*RPCKeyRing, error) {
 
-	rpcConn, err := connectRPC(
-		remoteSigner.RPCHost, remoteSigner.TLSCertPath,
-		remoteSigner.MacaroonPath, remoteSigner.Timeout,
-	)
+	endpoints, err := remoteSigner.Endpoints()
 	if err != nil {
-		return nil, fmt.Errorf("error connecting to the remote "+
-			"signing node through RPC: %v", err)
+		return nil, err
+	}
+
+	signers, err := newSignerPool(endpoints, remoteSigner.Timeout)
+	if err != nil {
+		return nil, err
 	}
 
 	return &RPCKeyRing{
 		WalletController: watchOnlyWalletController,
 		watchOnlyKeyRing: watchOnlyKeyRing,
 		netParams:        netParams,
-		rpcTimeout:       remoteSigner.Timeout,
-		signerClient:     signrpc.NewSignerClient(rpcConn),
-		walletClient:     walletrpc.NewWalletKitClient(rpcConn),
+		signers:          signers,
 	}, nil
 }
 
+// SetAvailabilityNotifier registers a function that is called whenever
+// signing becomes unavailable because none of the remote signers can be
+// reached, or available again. It is called right away with the current
+// availability.
+func (r *RPCKeyRing) SetAvailabilityNotifier(notify func(available bool)) {
+	r.signers.Lock()
+	defer r.signers.Unlock()
+
+	r.signers.notifyAvailability = notify
+	notify(r.signers.available)
+}
+
 // NewAddress returns the next external or internal address for the
 // wallet dictated by the value of the `change` parameter. If change is
 // true, then an internal address should be used, otherwise an external
@@ -196,19 +206,27 @@ func (r *RPCKeyRing) SendOutputs(outputs []*wire.TxOut,
 // input/output/fee value validation, PSBT finalization). Any input that is
 // incomplete will be skipped.
 func (r *RPCKeyRing) SignPsbt(packet *psbt.Packet) ([]uint32, error) {
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
+	// Let the remote signer know which outputs are our change, so it can
+	// tell them apart from external destinations.
+	r.addOutputDerivations(packet)
 
 	var buf bytes.Buffer
 	if err := packet.Serialize(&buf); err != nil {
 		return nil, fmt.Errorf("error serializing PSBT: %w", err)
 	}
 
-	resp, err := r.walletClient.SignPsbt(ctxt, &walletrpc.SignPsbtRequest{
+	req := &walletrpc.SignPsbtRequest{
 		FundedPsbt: buf.Bytes(),
+	}
+
+	var resp *walletrpc.SignPsbtResponse
+	err := r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.walletClient.SignPsbt(ctx, req)
+
+		return err
 	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error signing PSBT in remote signer "+
 			"instance: %v", err)
 	}
@@ -399,9 +417,6 @@ func (r *RPCKeyRing) DeriveKey(
 func (r *RPCKeyRing) ECDH(keyDesc keychain.KeyDescriptor,
 	pubKey *btcec.PublicKey) ([32]byte, error) {
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
-
 	key := [32]byte{}
 	req := &signrpc.SharedKeyRequest{
 		EphemeralPubkey: pubKey.SerializeCompressed(),
@@ -417,9 +432,14 @@ func (r *RPCKeyRing) ECDH(keyDesc keychain.KeyDescriptor,
 		req.KeyDesc.RawKeyBytes = keyDesc.PubKey.SerializeCompressed()
 	}
 
-	resp, err := r.signerClient.DeriveSharedKey(ctxt, req)
+	var resp *signrpc.SharedKeyResponse
+	err := r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.signerClient.DeriveSharedKey(ctx, req)
+
+		return err
+	})
 	if err != nil {
-		considerShutdown(err)
 		return key, fmt.Errorf("error deriving shared key in remote "+
 			"signer instance: %v", err)
 	}
@@ -437,19 +457,23 @@ func (r *RPCKeyRing) ECDH(keyDesc keychain.KeyDescriptor,
 func (r *RPCKeyRing) SignMessage(keyLoc keychain.KeyLocator,
 	msg []byte, doubleHash bool) (*ecdsa.Signature, error) {
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
-
-	resp, err := r.signerClient.SignMessage(ctxt, &signrpc.SignMessageReq{
+	req := &signrpc.SignMessageReq{
 		Msg: msg,
 		KeyLoc: &signrpc.KeyLocator{
 			KeyFamily: int32(keyLoc.Family),
 			KeyIndex:  int32(keyLoc.Index),
 		},
 		DoubleHash: doubleHash,
+	}
+
+	var resp *signrpc.SignMessageResp
+	err := r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.signerClient.SignMessage(ctx, req)
+
+		return err
 	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error signing message in remote "+
 			"signer instance: %v", err)
 	}
@@ -483,10 +507,7 @@ func (r *RPCKeyRing) SignMessageCompact(keyLoc keychain.KeyLocator,
 			"locator %v, can only sign with node key", keyLoc)
 	}
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
-
-	resp, err := r.signerClient.SignMessage(ctxt, &signrpc.SignMessageReq{
+	req := &signrpc.SignMessageReq{
 		Msg: msg,
 		KeyLoc: &signrpc.KeyLocator{
 			KeyFamily: int32(keyLoc.Family),
@@ -494,9 +515,16 @@ func (r *RPCKeyRing) SignMessageCompact(keyLoc keychain.KeyLocator,
 		},
 		DoubleHash: doubleHash,
 		CompactSig: true,
+	}
+
+	var resp *signrpc.SignMessageResp
+	err := r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.signerClient.SignMessage(ctx, req)
+
+		return err
 	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error signing message in remote "+
 			"signer instance: %v", err)
 	}
@@ -516,10 +544,7 @@ func (r *RPCKeyRing) SignMessageSchnorr(keyLoc keychain.KeyLocator,
 	msg []byte, doubleHash bool, taprootTweak []byte,
 	tag []byte) (*schnorr.Signature, error) {
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
-
-	resp, err := r.signerClient.SignMessage(ctxt, &signrpc.SignMessageReq{
+	req := &signrpc.SignMessageReq{
 		Msg: msg,
 		KeyLoc: &signrpc.KeyLocator{
 			KeyFamily: int32(keyLoc.Family),
@@ -529,9 +554,16 @@ func (r *RPCKeyRing) SignMessageSchnorr(keyLoc keychain.KeyLocator,
 		SchnorrSig:         true,
 		SchnorrSigTapTweak: taprootTweak,
 		Tag:                tag,
+	}
+
+	var resp *signrpc.SignMessageResp
+	err := r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.signerClient.SignMessage(ctx, req)
+
+		return err
 	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error signing message in remote "+
 			"signer instance: %v", err)
 	}
@@ -711,12 +743,20 @@ func (r *RPCKeyRing) MuSig2CreateSession(bipVersion input.MuSig2Version,
 		req.PregeneratedLocalNonce = localNonces.SecNonce[:]
 	}
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
+	// The session only exists on the signer that created it, so we need
+	// to remember which one that was.
+	var (
+		resp   *signrpc.MuSig2SessionResponse
+		signer *remoteSigner
+	)
+	err = r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.signerClient.MuSig2CreateSession(ctx, req)
+		signer = s
 
-	resp, err := r.signerClient.MuSig2CreateSession(ctxt, req)
+		return err
+	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error creating MuSig2 session in "+
 			"remote signer instance: %v", err)
 	}
@@ -730,6 +770,8 @@ func (r *RPCKeyRing) MuSig2CreateSession(bipVersion input.MuSig2Version,
 	copy(info.SessionID[:], resp.SessionId)
 	copy(info.PublicNonce[:], resp.LocalPublicNonces)
 
+	r.signers.addSession(info.SessionID, signer, req)
+
 	info.CombinedKey, err = schnorr.ParsePubKey(resp.CombinedKey)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing combined key: %w", err)
@@ -765,16 +807,26 @@ func (r *RPCKeyRing) MuSig2RegisterNonces(sessionID input.MuSig2SessionID,
 		copy(req.OtherSignerPublicNonces[idx], nonce[:])
 	}
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
+	var resp *signrpc.MuSig2RegisterNoncesResponse
+	err := r.signers.callSession(sessionID, func(ctx context.Context,
+		s *remoteSigner) error {
+
+		var err error
+		resp, err = s.signerClient.MuSig2RegisterNonces(ctx, req)
 
-	resp, err := r.signerClient.MuSig2RegisterNonces(ctxt, req)
+		return err
+	})
 	if err != nil {
-		considerShutdown(err)
 		return false, fmt.Errorf("error registering MuSig2 nonces in "+
 			"remote signer instance: %v", err)
 	}
 
+	// The nonces need to be registered again if the session is ever
+	// recreated on another signer.
+	r.signers.updateSession(sessionID, func(session *muSig2Session) {
+		session.nonceReqs = append(session.nonceReqs, req)
+	})
+
 	return resp.HaveAllNonces, nil
 }
 
@@ -796,16 +848,30 @@ func (r *RPCKeyRing) MuSig2Sign(sessionID input.MuSig2SessionID,
 		Cleanup:       cleanUp,
 	}
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
+	// Once we asked for a signature, the signer might have used its nonce,
+	// so the session must never be recreated on another signer.
+	r.signers.updateSession(sessionID, func(session *muSig2Session) {
+		session.signAttempted = true
+	})
 
-	resp, err := r.signerClient.MuSig2Sign(ctxt, req)
+	var resp *signrpc.MuSig2SignResponse
+	err := r.signers.callSession(sessionID, func(ctx context.Context,
+		s *remoteSigner) error {
+
+		var err error
+		resp, err = s.signerClient.MuSig2Sign(ctx, req)
+
+		return err
+	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error signing MuSig2 session in "+
 			"remote signer instance: %v", err)
 	}
 
+	if cleanUp {
+		r.signers.removeSession(sessionID)
+	}
+
 	partialSig, err := input.DeserializePartialSignature(
 		resp.LocalPartialSignature,
 	)
@@ -840,12 +906,16 @@ func (r *RPCKeyRing) MuSig2CombineSig(sessionID input.MuSig2SessionID,
 		req.OtherPartialSignatures[idx] = rawSig[:]
 	}
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
+	var resp *signrpc.MuSig2CombineSigResponse
+	err := r.signers.callSession(sessionID, func(ctx context.Context,
+		s *remoteSigner) error {
 
-	resp, err := r.signerClient.MuSig2CombineSig(ctxt, req)
+		var err error
+		resp, err = s.signerClient.MuSig2CombineSig(ctx, req)
+
+		return err
+	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, false, fmt.Errorf("error combining MuSig2 "+
 			"signatures in remote signer instance: %v", err)
 	}
@@ -856,6 +926,9 @@ func (r *RPCKeyRing) MuSig2CombineSig(sessionID input.MuSig2SessionID,
 		return nil, resp.HaveAllSignatures, nil
 	}
 
+	// The signer removes the session once it is complete.
+	r.signers.removeSession(sessionID)
+
 	finalSig, err := schnorr.ParseSignature(resp.FinalSignature)
 	if err != nil {
 		return nil, false, fmt.Errorf("error parsing final signature: "+
@@ -871,12 +944,19 @@ func (r *RPCKeyRing) MuSig2Cleanup(sessionID input.MuSig2SessionID) error {
 		SessionId: sessionID[:],
 	}
 
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
+	// There's no point in recreating the session on another signer just to
+	// remove it, so we only ask the signer that holds it.
+	signer, err := r.signers.sessionSigner(sessionID)
+	if err == nil {
+		err = r.signers.callSigner(signer, func(ctx context.Context,
+			s *remoteSigner) error {
 
-	_, err := r.signerClient.MuSig2Cleanup(ctxt, req)
+			_, err := s.signerClient.MuSig2Cleanup(ctx, req)
+			return err
+		})
+	}
+	r.signers.removeSession(sessionID)
 	if err != nil {
-		considerShutdown(err)
 		return fmt.Errorf("error cleaning up MuSig2 session in remote "+
 			"signer instance: %v", err)
 	}
@@ -893,6 +973,7 @@ func (r *RPCKeyRing) remoteSign(tx *wire.MsgTx, signDesc *input.SignDescriptor,
 	if err != nil {
 		return nil, fmt.Errorf("error converting TX into PSBT: %w", err)
 	}
+	r.addOutputDerivations(packet)
 
 	// We need to add witness information for all inputs! Otherwise, we'll
 	// have a problem when attempting to sign a taproot input!
@@ -1152,19 +1233,21 @@ func (r *RPCKeyRing) remoteSign(tx *wire.MsgTx, signDesc *input.SignDescriptor,
 	}
 
 	// Okay, let's sign the input by the remote signer now.
-	ctxt, cancel := context.WithTimeout(context.Background(), r.rpcTimeout)
-	defer cancel()
-
 	var buf bytes.Buffer
 	if err := packet.Serialize(&buf); err != nil {
 		return nil, fmt.Errorf("error serializing PSBT: %w", err)
 	}
 
-	resp, err := r.walletClient.SignPsbt(
-		ctxt, &walletrpc.SignPsbtRequest{FundedPsbt: buf.Bytes()},
-	)
+	req := &walletrpc.SignPsbtRequest{FundedPsbt: buf.Bytes()}
+
+	var resp *walletrpc.SignPsbtResponse
+	err = r.signers.call(func(ctx context.Context, s *remoteSigner) error {
+		var err error
+		resp, err = s.walletClient.SignPsbt(ctx, req)
+
+		return err
+	})
 	if err != nil {
-		considerShutdown(err)
 		return nil, fmt.Errorf("error signing PSBT in remote signer "+
 			"instance: %v", err)
 	}
@@ -1185,6 +1268,46 @@ func (r *RPCKeyRing) remoteSign(tx *wire.MsgTx, signDesc *input.SignDescriptor,
 	return extractSignature(in, signDesc.SignMethod)
 }
 
+// addOutputDerivations adds the BIP32 derivation information to all outputs of
+// the packet that pay to our wallet and don't have it yet. A remote signer that
+// enforces a signer policy uses it to verify which outputs are change.
+func (r *RPCKeyRing) addOutputDerivations(packet *psbt.Packet) {
+	for idx, txOut := range packet.UnsignedTx.TxOut {
+		out := &packet.Outputs[idx]
+		if len(out.Bip32Derivation) > 0 ||
+			len(out.TaprootBip32Derivation) > 0 {
+
+			continue
+		}
+
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			txOut.PkScript, r.netParams,
+		)
+		if err != nil || len(addrs) != 1 {
+			continue
+		}
+
+		managedAddr, err := r.AddressInfo(addrs[0])
+		if err != nil {
+			continue
+		}
+
+		derivation, trDerivation, _, err :=
+			btcwallet.Bip32DerivationFromAddress(managedAddr)
+		if err != nil {
+			continue
+		}
+
+		if txscript.IsPayToTaproot(txOut.PkScript) {
+			out.TaprootBip32Derivation = append(
+				out.TaprootBip32Derivation, trDerivation,
+			)
+			continue
+		}
+		out.Bip32Derivation = append(out.Bip32Derivation, derivation)
+	}
+}
+
 // extractSignature attempts to extract the signature from the PSBT input,
 // looking at different fields depending on the signing method that was used.
 func extractSignature(in *psbt.PInput,
@@ -1342,26 +1465,3 @@ func packetFromTx(original *wire.MsgTx) (*psbt.Packet, error) {
 
 	return packet, nil
 }
-
-// considerShutdown inspects the error and issues a shutdown (through logging
-// a critical error, which will cause the logger to issue a clean shutdown
-// request) if the error looks like a connection or general availability error
-// and not some application specific problem.
-func considerShutdown(err error) {
-	statusErr, isStatusErr := status.FromError(err)
-	switch {
-	// The context attached to the client request has timed out. This can be
-	// due to not being able to reach the signing server, or it's taking too
-	// long to respond. In either case, request a shutdown.
-	case err == context.DeadlineExceeded:
-		fallthrough
-
-	// The signing server's context timed out before the client's due to
-	// clock skew, request a shutdown anyway.
-	case isStatusErr && statusErr.Code() == codes.DeadlineExceeded:
-		log.Critical("RPC signing timed out: %v", err)
-
-	case isStatusErr && statusErr.Code() == codes.Unavailable:
-		log.Critical("RPC signing server not available: %v", err)
-	}
-}
@@ -0,0 +1,554 @@
+package rpcwallet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lncfg"
+	"github.com/lightningnetwork/lnd/lnrpc/signrpc"
+	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrNoSignerAvailable is returned if none of the configured remote
+	// signers can be reached.
+	ErrNoSignerAvailable = errors.New("no remote signer available")
+
+	// ErrMuSig2SessionLost is returned if the remote signer that holds a
+	// MuSig2 session can't be reached anymore and the session can't be
+	// recreated on another signer.
+	ErrMuSig2SessionLost = errors.New("MuSig2 session lost with " +
+		"unreachable remote signer")
+)
+
+// remoteSigner is a single remote signer the watch-only wallet can delegate
+// signing operations to.
+type remoteSigner struct {
+	endpoint *lncfg.RemoteSignerEndpoint
+
+	// conn is the RPC connection to the signer. It is nil if the signer
+	// couldn't be reached yet.
+	conn *grpc.ClientConn
+
+	signerClient signrpc.SignerClient
+	walletClient walletrpc.WalletKitClient
+
+	// healthy is false if the last call to the signer failed because it
+	// wasn't reachable. Unhealthy signers are only used again once a
+	// health check succeeded.
+	healthy bool
+}
+
+// muSig2Session keeps track of a MuSig2 session that was created on one of
+// the remote signers. MuSig2 sessions only exist in the memory of the signer
+// that created them.
+type muSig2Session struct {
+	// signer is the remote signer that holds the session.
+	signer *remoteSigner
+
+	// createReq is the request the session was created with.
+	createReq *signrpc.MuSig2SessionRequest
+
+	// nonceReqs are all nonce registrations of the session.
+	nonceReqs []*signrpc.MuSig2RegisterNoncesRequest
+
+	// signAttempted is set once a partial signature was requested. From
+	// then on the session must never be recreated on another signer, as
+	// that could reuse the same nonce for a different message.
+	signAttempted bool
+
+	// staleSigners are signers that held the session before it was moved
+	// to another signer.
+	staleSigners []*remoteSigner
+}
+
+// signerPool manages the connections to all configured remote signers and
+// fails over to the next signer in the order of their priority if a signer
+// can't be reached.
+type signerPool struct {
+	sync.Mutex
+
+	signers []*remoteSigner
+
+	timeout time.Duration
+
+	sessions map[input.MuSig2SessionID]*muSig2Session
+
+	// available is false if none of the signers is reachable.
+	available bool
+
+	// notifyAvailability is called whenever the signing availability
+	// changes.
+	notifyAvailability func(bool)
+}
+
+// newSignerPool connects to all given remote signers. An error is returned if
+// none of them can be reached.
+func newSignerPool(endpoints []*lncfg.RemoteSignerEndpoint,
+	timeout time.Duration) (*signerPool, error) {
+
+	p := &signerPool{
+		timeout:  timeout,
+		sessions: make(map[input.MuSig2SessionID]*muSig2Session),
+	}
+
+	var firstErr error
+	for _, endpoint := range endpoints {
+		signer := &remoteSigner{
+			endpoint: endpoint,
+		}
+		p.signers = append(p.signers, signer)
+
+		if err := p.connect(signer, timeout); err != nil {
+			log.Warnf("Unable to connect to remote signer %v: %v",
+				endpoint.RPCHost, err)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if _, err := p.activeSigner(); err != nil {
+		return nil, fmt.Errorf("error connecting to the remote "+
+			"signing node through RPC: %w", firstErr)
+	}
+	p.available = true
+
+	return p, nil
+}
+
+// connect establishes the connection to the signer if it doesn't exist yet
+// and marks the signer as healthy.
+func (p *signerPool) connect(signer *remoteSigner,
+	timeout time.Duration) error {
+
+	if signer.conn == nil {
+		conn, err := connectRPC(
+			signer.endpoint.RPCHost, signer.endpoint.TLSCertPath,
+			signer.endpoint.MacaroonPath, timeout,
+		)
+		if err != nil {
+			return err
+		}
+
+		signer.conn = conn
+		signer.signerClient = signrpc.NewSignerClient(conn)
+		signer.walletClient = walletrpc.NewWalletKitClient(conn)
+	}
+
+	signer.healthy = true
+
+	return nil
+}
+
+// activeSigner returns the healthy signer with the highest priority.
+//
+// NOTE: The caller must hold the pool's mutex.
+func (p *signerPool) activeSigner() (*remoteSigner, error) {
+	for _, signer := range p.signers {
+		if signer.healthy {
+			return signer, nil
+		}
+	}
+
+	return nil, ErrNoSignerAvailable
+}
+
+// markUnreachable marks the signer as unhealthy after a call failed because
+// the signer couldn't be reached.
+func (p *signerPool) markUnreachable(signer *remoteSigner, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if signer.healthy {
+		log.Errorf("Remote signer %v not reachable, failing over: %v",
+			signer.endpoint.RPCHost, err)
+	}
+	signer.healthy = false
+
+	p.updateAvailability()
+}
+
+// updateAvailability notifies about a change of the signing availability.
+//
+// NOTE: The caller must hold the pool's mutex.
+func (p *signerPool) updateAvailability() {
+	_, err := p.activeSigner()
+	available := err == nil
+	if available == p.available {
+		return
+	}
+	p.available = available
+
+	if available {
+		log.Infof("Remote signing available again")
+	} else {
+		log.Errorf("No remote signer reachable, signing unavailable")
+	}
+
+	if p.notifyAvailability != nil {
+		p.notifyAvailability(available)
+	}
+}
+
+// call executes the given RPC call on the active signer. If the signer can't
+// be reached, the call is retried with the next signer in the order of their
+// priority.
+func (p *signerPool) call(
+	fn func(context.Context, *remoteSigner) error) error {
+
+	for {
+		p.Lock()
+		signer, err := p.activeSigner()
+		p.Unlock()
+		if err != nil {
+			return p.callAny(fn)
+		}
+
+		err = p.callSigner(signer, fn)
+		if !isUnreachable(err) {
+			return err
+		}
+	}
+}
+
+// callAny is the last resort if none of the signers is considered healthy. It
+// tries all signers we ever connected to, as their connections reconnect by
+// themselves, which means a signer might be back before the next health check
+// notices.
+func (p *signerPool) callAny(
+	fn func(context.Context, *remoteSigner) error) error {
+
+	lastErr := ErrNoSignerAvailable
+	for _, signer := range p.signers {
+		p.Lock()
+		connected := signer.conn != nil
+		p.Unlock()
+		if !connected {
+			continue
+		}
+
+		ctxt, cancel := context.WithTimeout(
+			context.Background(), p.timeout,
+		)
+		err := fn(ctxt, signer)
+		cancel()
+
+		if isUnreachable(err) {
+			lastErr = fmt.Errorf("%w: %v", ErrNoSignerAvailable,
+				err)
+			continue
+		}
+
+		p.Lock()
+		log.Infof("Remote signer %v reachable again",
+			signer.endpoint.RPCHost)
+		signer.healthy = true
+		p.updateAvailability()
+		p.Unlock()
+
+		return err
+	}
+
+	return lastErr
+}
+
+// callSigner executes the given RPC call on the given signer and marks the
+// signer as unhealthy if it can't be reached.
+func (p *signerPool) callSigner(signer *remoteSigner,
+	fn func(context.Context, *remoteSigner) error) error {
+
+	ctxt, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	err := fn(ctxt, signer)
+	if isUnreachable(err) {
+		p.markUnreachable(signer, err)
+	}
+
+	return err
+}
+
+// callSession executes the given RPC call on the signer that holds the MuSig2
+// session. If that signer can't be reached, the session is recreated on the
+// next available signer, as long as no partial signature was requested yet.
+func (p *signerPool) callSession(sessionID input.MuSig2SessionID,
+	fn func(context.Context, *remoteSigner) error) error {
+
+	p.Lock()
+	session, ok := p.sessions[sessionID]
+	p.Unlock()
+
+	// Sessions we don't know about can only be handled by the active
+	// signer, if at all.
+	if !ok {
+		return p.call(fn)
+	}
+
+	for {
+		p.Lock()
+		signer := session.signer
+		healthy := signer.healthy
+		p.Unlock()
+
+		if healthy {
+			err := p.callSigner(signer, fn)
+			if !isUnreachable(err) {
+				return err
+			}
+		}
+
+		if err := p.moveSession(sessionID, session); err != nil {
+			return err
+		}
+	}
+}
+
+// moveSession recreates a MuSig2 session on the active signer by replaying
+// its creation and all nonce registrations.
+func (p *signerPool) moveSession(sessionID input.MuSig2SessionID,
+	session *muSig2Session) error {
+
+	p.Lock()
+	defer p.Unlock()
+
+	// A session can only be recreated with the same ID if the local nonce
+	// was pregenerated by us. And it must never be recreated once a
+	// signature might have been produced with its nonce.
+	if session.signAttempted ||
+		len(session.createReq.PregeneratedLocalNonce) == 0 {
+
+		return fmt.Errorf("%w: session %x", ErrMuSig2SessionLost,
+			sessionID[:])
+	}
+
+	signer, err := p.activeSigner()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Moving MuSig2 session %x from remote signer %v to %v",
+		sessionID[:], session.signer.endpoint.RPCHost,
+		signer.endpoint.RPCHost)
+
+	ctxt, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	err = p.replaySession(ctxt, signer, sessionID, session)
+	switch {
+	case isUnreachable(err):
+		signer.healthy = false
+		p.updateAvailability()
+
+		// The next attempt will pick the next signer.
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("unable to recreate MuSig2 session %x: %w",
+			sessionID[:], err)
+	}
+
+	session.staleSigners = append(session.staleSigners, session.signer)
+	session.signer = signer
+
+	return nil
+}
+
+// replaySession creates the session on the given signer and registers all
+// nonces that were registered with the original session.
+func (p *signerPool) replaySession(ctx context.Context, signer *remoteSigner,
+	sessionID input.MuSig2SessionID, session *muSig2Session) error {
+
+	resp, err := signer.signerClient.MuSig2CreateSession(
+		ctx, session.createReq,
+	)
+	if err != nil {
+		return err
+	}
+
+	var newID input.MuSig2SessionID
+	copy(newID[:], resp.SessionId)
+	if newID != sessionID {
+		return fmt.Errorf("recreated session has different ID %x",
+			newID[:])
+	}
+
+	for _, req := range session.nonceReqs {
+		_, err := signer.signerClient.MuSig2RegisterNonces(ctx, req)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addSession starts tracking a MuSig2 session that was created on the given
+// signer.
+func (p *signerPool) addSession(sessionID input.MuSig2SessionID,
+	signer *remoteSigner, req *signrpc.MuSig2SessionRequest) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.sessions[sessionID] = &muSig2Session{
+		signer:    signer,
+		createReq: req,
+	}
+}
+
+// sessionSigner returns the signer that holds the MuSig2 session. For
+// sessions we don't know about, the active signer is returned.
+func (p *signerPool) sessionSigner(
+	sessionID input.MuSig2SessionID) (*remoteSigner, error) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if session, ok := p.sessions[sessionID]; ok {
+		return session.signer, nil
+	}
+
+	return p.activeSigner()
+}
+
+// updateSession applies the given update to a tracked MuSig2 session.
+func (p *signerPool) updateSession(sessionID input.MuSig2SessionID,
+	update func(*muSig2Session)) {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if session, ok := p.sessions[sessionID]; ok {
+		update(session)
+	}
+}
+
+// removeSession stops tracking a MuSig2 session and removes it from all
+// signers that held it before it was moved, as far as they're reachable.
+func (p *signerPool) removeSession(sessionID input.MuSig2SessionID) {
+	p.Lock()
+	session, ok := p.sessions[sessionID]
+	delete(p.sessions, sessionID)
+	p.Unlock()
+
+	if !ok {
+		return
+	}
+
+	req := &signrpc.MuSig2CleanupRequest{
+		SessionId: sessionID[:],
+	}
+	for _, signer := range session.staleSigners {
+		err := p.callSigner(signer, func(ctx context.Context,
+			s *remoteSigner) error {
+
+			_, err := s.signerClient.MuSig2Cleanup(ctx, req)
+			return err
+		})
+		if err != nil {
+			log.Debugf("Unable to clean up MuSig2 session %x on "+
+				"stale remote signer %v: %v", sessionID[:],
+				signer.endpoint.RPCHost, err)
+		}
+	}
+}
+
+// healthCheck tries to reach all signers and marks the reachable ones as
+// healthy again, which makes the signer with the highest priority the active
+// one. An error is only returned if none of the signers is reachable.
+func (p *signerPool) healthCheck(timeout time.Duration) error {
+	var lastErr error
+	for _, signer := range p.signers {
+		// The health check connects from scratch, as an existing
+		// connection wouldn't tell us if the signer is reachable right
+		// now.
+		conn, err := connectRPC(
+			signer.endpoint.RPCHost, signer.endpoint.TLSCertPath,
+			signer.endpoint.MacaroonPath, timeout,
+		)
+		if err != nil {
+			log.Debugf("Health check of remote signer %v failed: "+
+				"%v", signer.endpoint.RPCHost, err)
+
+			p.Lock()
+			signer.healthy = false
+			p.Unlock()
+
+			lastErr = err
+			continue
+		}
+
+		p.Lock()
+		if !signer.healthy {
+			log.Infof("Remote signer %v reachable again",
+				signer.endpoint.RPCHost)
+		}
+		signer.healthy = true
+
+		// If we never managed to connect to the signer before, we keep
+		// the connection.
+		if signer.conn == nil {
+			signer.conn = conn
+			signer.signerClient = signrpc.NewSignerClient(conn)
+			signer.walletClient = walletrpc.NewWalletKitClient(conn)
+			conn = nil
+		}
+		p.Unlock()
+
+		if conn == nil {
+			continue
+		}
+		if err := conn.Close(); err != nil {
+			log.Warnf("Failed to close health check connection to "+
+				"remote signer %v: %v", signer.endpoint.RPCHost,
+				err)
+		}
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.updateAvailability()
+	if !p.available {
+		return fmt.Errorf("%w: %v", ErrNoSignerAvailable, lastErr)
+	}
+
+	return nil
+}
+
+// isUnreachable returns true if the error looks like the remote signer
+// couldn't be reached and not like some application specific problem.
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	statusErr, isStatusErr := status.FromError(err)
+	switch {
+	// The context attached to the client request has timed out. This can be
+	// due to not being able to reach the signing server, or it's taking too
+	// long to respond.
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+
+	// The signing server's context timed out before the client's due to
+	// clock skew.
+	case isStatusErr && statusErr.Code() == codes.DeadlineExceeded:
+		return true
+
+	case isStatusErr && statusErr.Code() == codes.Unavailable:
+		return true
+
+	default:
+		return false
+	}
+}
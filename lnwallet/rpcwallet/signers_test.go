@@ -0,0 +1,241 @@
+package rpcwallet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lncfg"
+	"github.com/lightningnetwork/lnd/lnrpc/signrpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var testSessionID = input.MuSig2SessionID{1, 2, 3}
+
+// mockSignerClient is a signer client that only implements the MuSig2 calls
+// and can be taken down.
+type mockSignerClient struct {
+	signrpc.SignerClient
+
+	down     bool
+	sessions map[input.MuSig2SessionID]int
+	signed   int
+}
+
+func newMockSignerClient() *mockSignerClient {
+	return &mockSignerClient{
+		sessions: make(map[input.MuSig2SessionID]int),
+	}
+}
+
+func (m *mockSignerClient) MuSig2CreateSession(_ context.Context,
+	_ *signrpc.MuSig2SessionRequest,
+	_ ...grpc.CallOption) (*signrpc.MuSig2SessionResponse, error) {
+
+	if m.down {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+
+	m.sessions[testSessionID] = 0
+
+	return &signrpc.MuSig2SessionResponse{
+		SessionId: testSessionID[:],
+	}, nil
+}
+
+func (m *mockSignerClient) MuSig2RegisterNonces(_ context.Context,
+	req *signrpc.MuSig2RegisterNoncesRequest,
+	_ ...grpc.CallOption) (*signrpc.MuSig2RegisterNoncesResponse, error) {
+
+	if m.down {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+
+	var sessionID input.MuSig2SessionID
+	copy(sessionID[:], req.SessionId)
+	if _, ok := m.sessions[sessionID]; !ok {
+		return nil, status.Error(codes.NotFound, "unknown session")
+	}
+	m.sessions[sessionID]++
+
+	return &signrpc.MuSig2RegisterNoncesResponse{
+		HaveAllNonces: true,
+	}, nil
+}
+
+func (m *mockSignerClient) MuSig2Sign(_ context.Context,
+	_ *signrpc.MuSig2SignRequest,
+	_ ...grpc.CallOption) (*signrpc.MuSig2SignResponse, error) {
+
+	if m.down {
+		return nil, status.Error(codes.Unavailable, "down")
+	}
+	m.signed++
+
+	return &signrpc.MuSig2SignResponse{}, nil
+}
+
+// newTestPool creates a signer pool with the given mock signer clients in the
+// order of their priority.
+func newTestPool(clients ...*mockSignerClient) (*signerPool, *[]bool) {
+	p := &signerPool{
+		timeout:   time.Second,
+		sessions:  make(map[input.MuSig2SessionID]*muSig2Session),
+		available: true,
+	}
+	for idx, client := range clients {
+		p.signers = append(p.signers, &remoteSigner{
+			endpoint: &lncfg.RemoteSignerEndpoint{
+				RPCHost: string(rune('a' + idx)),
+			},
+			conn:         &grpc.ClientConn{},
+			signerClient: client,
+			healthy:      true,
+		})
+	}
+
+	var notifications []bool
+	p.notifyAvailability = func(available bool) {
+		notifications = append(notifications, available)
+	}
+
+	return p, &notifications
+}
+
+// createSession creates the test session through the pool and registers one
+// nonce.
+func createSession(t *testing.T, p *signerPool, pregenerated bool) {
+	req := &signrpc.MuSig2SessionRequest{}
+	if pregenerated {
+		req.PregeneratedLocalNonce = []byte{1}
+	}
+
+	var signer *remoteSigner
+	err := p.call(func(ctx context.Context, s *remoteSigner) error {
+		_, err := s.signerClient.MuSig2CreateSession(ctx, req)
+		signer = s
+
+		return err
+	})
+	require.NoError(t, err)
+	p.addSession(testSessionID, signer, req)
+
+	require.NoError(t, registerNonce(p))
+}
+
+func registerNonce(p *signerPool) error {
+	req := &signrpc.MuSig2RegisterNoncesRequest{
+		SessionId: testSessionID[:],
+	}
+	err := p.callSession(testSessionID, func(ctx context.Context,
+		s *remoteSigner) error {
+
+		_, err := s.signerClient.MuSig2RegisterNonces(ctx, req)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	p.updateSession(testSessionID, func(session *muSig2Session) {
+		session.nonceReqs = append(session.nonceReqs, req)
+	})
+
+	return nil
+}
+
+func sign(p *signerPool) error {
+	p.updateSession(testSessionID, func(session *muSig2Session) {
+		session.signAttempted = true
+	})
+
+	return p.callSession(testSessionID, func(ctx context.Context,
+		s *remoteSigner) error {
+
+		_, err := s.signerClient.MuSig2Sign(
+			ctx, &signrpc.MuSig2SignRequest{},
+		)
+		return err
+	})
+}
+
+// TestSignerFailover tests that calls fail over to the next signer in the
+// order of their priority and that loss of all signers is reported.
+func TestSignerFailover(t *testing.T) {
+	t.Parallel()
+
+	primary, fallback := newMockSignerClient(), newMockSignerClient()
+	p, notifications := newTestPool(primary, fallback)
+
+	createSession(t, p, false)
+	require.Contains(t, primary.sessions, testSessionID)
+	require.NotContains(t, fallback.sessions, testSessionID)
+
+	// The primary goes down, so new sessions are created on the fallback.
+	primary.down = true
+	delete(p.sessions, testSessionID)
+	createSession(t, p, false)
+	require.Contains(t, fallback.sessions, testSessionID)
+	require.False(t, p.signers[0].healthy)
+	require.Empty(t, *notifications)
+
+	// Once all signers are down, signing is unavailable.
+	fallback.down = true
+	err := p.call(func(ctx context.Context, s *remoteSigner) error {
+		_, err := s.signerClient.MuSig2CreateSession(
+			ctx, &signrpc.MuSig2SessionRequest{},
+		)
+		return err
+	})
+	require.ErrorIs(t, err, ErrNoSignerAvailable)
+	require.Equal(t, []bool{false}, *notifications)
+
+	// As soon as any signer is reachable again, it is used again.
+	primary.down = false
+	delete(p.sessions, testSessionID)
+	createSession(t, p, false)
+	require.True(t, p.signers[0].healthy)
+	require.Equal(t, []bool{false, true}, *notifications)
+}
+
+// TestMuSig2SessionFailover tests that MuSig2 sessions are only moved to
+// another signer if that can't lead to nonce reuse.
+func TestMuSig2SessionFailover(t *testing.T) {
+	t.Parallel()
+
+	// A session with a nonce that was generated by the signer can't be
+	// recreated with the same ID.
+	primary, fallback := newMockSignerClient(), newMockSignerClient()
+	p, _ := newTestPool(primary, fallback)
+
+	createSession(t, p, false)
+	primary.down = true
+	require.ErrorIs(t, registerNonce(p), ErrMuSig2SessionLost)
+
+	// A session with a pregenerated nonce is moved to the fallback, with
+	// all nonces registered again.
+	primary, fallback = newMockSignerClient(), newMockSignerClient()
+	p, _ = newTestPool(primary, fallback)
+
+	createSession(t, p, true)
+	primary.down = true
+	require.NoError(t, registerNonce(p))
+	require.Equal(t, 2, fallback.sessions[testSessionID])
+	require.Equal(t, p.signers[1], p.sessions[testSessionID].signer)
+
+	require.NoError(t, sign(p))
+	require.Equal(t, 1, fallback.signed)
+
+	// Once a signature was requested, the session is never moved again.
+	primary, fallback = newMockSignerClient(), newMockSignerClient()
+	p, _ = newTestPool(primary, fallback)
+
+	createSession(t, p, true)
+	primary.down = true
+	require.ErrorIs(t, sign(p), ErrMuSig2SessionLost)
+	require.Zero(t, fallback.signed)
+}
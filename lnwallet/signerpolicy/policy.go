@@ -0,0 +1,455 @@
+package signerpolicy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+const (
+	// DefaultSendWindow is the default duration of the rolling window the
+	// velocity limit of wallet sends applies to.
+	DefaultSendWindow = 24 * time.Hour
+
+	// DefaultMaxCommitFeeRate is the default maximum fee rate of
+	// commitment transactions the signer signs.
+	DefaultMaxCommitFeeRate = chainfee.SatPerKWeight(250_000)
+
+	// DefaultMaxCommitHTLCs is the default maximum number of HTLC outputs
+	// of commitment transactions the signer signs.
+	DefaultMaxCommitHTLCs = input.MaxHTLCNumber
+
+	// RevokedDepth is the number of newer remote commitment states the
+	// signer must have seen for a channel before it considers a
+	// commitment state revoked. The remote party only revokes a state once
+	// we signed the one after the next, and our own commitment can lag
+	// behind the remote commitment by one state.
+	RevokedDepth = 2
+
+	// maxCommitNonHTLCOutputs is the maximum number of outputs of a
+	// commitment transaction that aren't HTLCs: the to_local and to_remote
+	// outputs and two anchors.
+	maxCommitNonHTLCOutputs = 4
+
+	// minCommitOutputValue is the smallest value an output of a commitment
+	// transaction can have, which is the dust limit of a P2WKH output.
+	minCommitOutputValue = 294
+)
+
+var (
+	// ErrPolicyViolation is the error all policy rejections wrap.
+	ErrPolicyViolation = errors.New("signer policy violation")
+
+	// ErrRevokedCommitment is returned if the signer is asked to sign a
+	// commitment state it considers revoked.
+	ErrRevokedCommitment = fmt.Errorf("%w: revoked commitment",
+		ErrPolicyViolation)
+
+	// ErrInvalidCommitment is returned if a commitment transaction fails
+	// the fee or HTLC sanity checks.
+	ErrInvalidCommitment = fmt.Errorf("%w: invalid commitment",
+		ErrPolicyViolation)
+
+	// ErrDestinationNotAllowed is returned if a wallet send pays to a
+	// destination that isn't on the allowlist.
+	ErrDestinationNotAllowed = fmt.Errorf("%w: destination not allowed",
+		ErrPolicyViolation)
+
+	// ErrVelocityExceeded is returned if a wallet send exceeds the amount
+	// that can be sent within the velocity window.
+	ErrVelocityExceeded = fmt.Errorf("%w: velocity limit exceeded",
+		ErrPolicyViolation)
+
+	// ErrFundingSpendNotAllowed is returned if a transaction spends a
+	// channel funding output and pays to a destination that is neither
+	// allowed nor our own.
+	ErrFundingSpendNotAllowed = fmt.Errorf("%w: funding output spend "+
+		"not allowed", ErrPolicyViolation)
+
+	// ErrUnverifiedMuSig2 is returned if a MuSig2 signature is requested
+	// but unverified MuSig2 signing isn't allowed.
+	ErrUnverifiedMuSig2 = fmt.Errorf("%w: MuSig2 signing requests can't "+
+		"be verified", ErrPolicyViolation)
+)
+
+// Config holds the configuration of the policy engine.
+type Config struct {
+	// DB is the database the channel states and wallet sends are tracked
+	// in.
+	DB kvdb.Backend
+
+	// Clock is used to determine the velocity window of wallet sends.
+	Clock clock.Clock
+
+	// AllowedDestinations is the set of output scripts wallet sends can
+	// pay to. If it is empty, wallet sends can pay to any destination.
+	// Spends of channel funding outputs can only pay to these scripts or to
+	// our own wallet, unless they're commitments and unverified
+	// commitments are allowed.
+	AllowedDestinations map[string]struct{}
+
+	// IsOwnScript reports whether an output script pays to the wallet of
+	// the signer. If it is nil, only the outputs the caller of
+	// CheckTransaction identifies are considered our own.
+	IsOwnScript func(pkScript []byte) bool
+
+	// AllowChannelFunding exempts P2WSH and P2TR outputs, which includes
+	// all channel funding outputs, from the destination allowlist. They
+	// still count against the velocity limit.
+	AllowChannelFunding bool
+
+	// SendLimit is the maximum amount wallet sends can pay to external
+	// destinations within SendWindow. If it is zero, there is no limit.
+	SendLimit btcutil.Amount
+
+	// SendWindow is the duration of the rolling window the SendLimit
+	// applies to.
+	SendWindow time.Duration
+
+	// MaxCommitFeeRate is the maximum fee rate of commitment transactions.
+	MaxCommitFeeRate chainfee.SatPerKWeight
+
+	// MaxCommitHTLCs is the maximum number of HTLC outputs of commitment
+	// transactions.
+	MaxCommitHTLCs int
+
+	// AllowUnverifiedCommitments allows signing spends of channel funding
+	// outputs that are shaped like commitments with only the fee, HTLC,
+	// dust and revocation checks. The signer doesn't know the keys of the
+	// channels, so it can't verify that the outputs of a commitment pay to
+	// the parties of the channel. Without it, commitments are checked like
+	// any other spend of a funding output.
+	AllowUnverifiedCommitments bool
+
+	// AllowUnverifiedMuSig2 allows MuSig2 signing requests, which only
+	// contain a message digest and therefore can't be checked against the
+	// policy. They're required for taproot channels.
+	AllowUnverifiedMuSig2 bool
+}
+
+// Input describes an input of a transaction the signer is asked to sign.
+type Input struct {
+	// PrevOut is the output the input spends, if it is known.
+	PrevOut *wire.TxOut
+
+	// WalletKey is true if the input is signed with a key of the on-chain
+	// wallet, which makes the transaction a wallet send.
+	WalletKey bool
+
+	// FundingKey is true if the input is signed with the multisig key of
+	// a channel funding output.
+	FundingKey bool
+}
+
+// Engine checks the transactions and signing requests of a remote signer
+// against the configured policy. Since the signer only sees the transactions
+// it is asked to sign, it keeps track of the commitment states of channels by
+// itself.
+type Engine struct {
+	cfg   *Config
+	store *store
+}
+
+// New creates a new policy engine.
+func New(cfg *Config) (*Engine, error) {
+	store, err := newStore(cfg.DB)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize signer policy "+
+			"store: %w", err)
+	}
+
+	return &Engine{
+		cfg:   cfg,
+		store: store,
+	}, nil
+}
+
+// CheckTransaction checks a transaction the signer is asked to sign against
+// the policy. The ownOutput function reports whether an output pays back to
+// the wallet of the signer. Every rejection is logged with its reason.
+func (e *Engine) CheckTransaction(tx *wire.MsgTx, inputs []Input,
+	ownOutput func(int) bool) error {
+
+	err := e.checkTransaction(tx, inputs, ownOutput)
+	if err != nil {
+		log.Warnf("Refusing to sign transaction %v: %v", tx.TxHash(),
+			err)
+	}
+
+	return err
+}
+
+// CheckPsbt checks a packet the signer is asked to sign against the policy.
+// Inputs with a BIP32 derivation path in one of the BIP49, BIP84 or BIP86 key
+// scopes are considered inputs of the on-chain wallet, and inputs with a path
+// of lnd's multisig key family are considered channel funding outputs.
+func (e *Engine) CheckPsbt(packet *psbt.Packet,
+	ownOutput func(int) bool) error {
+
+	inputs := make([]Input, len(packet.Inputs))
+	for i := range packet.Inputs {
+		in := &packet.Inputs[i]
+
+		inputs[i].PrevOut = in.WitnessUtxo
+		if inputs[i].PrevOut == nil && in.NonWitnessUtxo != nil {
+			prevOut := packet.UnsignedTx.TxIn[i].PreviousOutPoint
+			prevTx := in.NonWitnessUtxo
+			if int(prevOut.Index) < len(prevTx.TxOut) {
+				inputs[i].PrevOut = prevTx.TxOut[prevOut.Index]
+			}
+		}
+
+		for _, derivation := range in.Bip32Derivation {
+			if isWalletPath(derivation.Bip32Path) {
+				inputs[i].WalletKey = true
+			}
+			if isFundingPath(derivation.Bip32Path) {
+				inputs[i].FundingKey = true
+			}
+		}
+		for _, derivation := range in.TaprootBip32Derivation {
+			if isWalletPath(derivation.Bip32Path) {
+				inputs[i].WalletKey = true
+			}
+		}
+	}
+
+	return e.CheckTransaction(packet.UnsignedTx, inputs, ownOutput)
+}
+
+// CheckMuSig2Sign checks whether a MuSig2 signing request can be served.
+func (e *Engine) CheckMuSig2Sign() error {
+	if e.cfg.AllowUnverifiedMuSig2 {
+		return nil
+	}
+
+	log.Warnf("Refusing to sign MuSig2 session: %v", ErrUnverifiedMuSig2)
+
+	return ErrUnverifiedMuSig2
+}
+
+// checkTransaction applies the commitment checks to commitment transactions if
+// unverified commitments are allowed, the destination checks to any other
+// spends of channel funding outputs, and the destination and velocity checks
+// to wallet sends.
+func (e *Engine) checkTransaction(tx *wire.MsgTx, inputs []Input,
+	ownOutput func(int) bool) error {
+
+	if len(inputs) != len(tx.TxIn) {
+		return fmt.Errorf("%w: expected %d inputs, got %d",
+			ErrPolicyViolation, len(tx.TxIn), len(inputs))
+	}
+
+	isOwnOutput := func(i int) bool {
+		if ownOutput != nil && ownOutput(i) {
+			return true
+		}
+
+		return e.cfg.IsOwnScript != nil &&
+			e.cfg.IsOwnScript(tx.TxOut[i].PkScript)
+	}
+
+	// Anyone can create a transaction shaped like a commitment, so the
+	// shape alone doesn't exempt it from the destination checks.
+	if IsCommitment(tx) && inputs[0].FundingKey &&
+		e.cfg.AllowUnverifiedCommitments {
+
+		return e.checkCommitment(tx, inputs[0])
+	}
+
+	for _, in := range inputs {
+		if in.FundingKey {
+			return e.checkFundingSpend(tx, isOwnOutput)
+		}
+	}
+
+	for _, in := range inputs {
+		if in.WalletKey {
+			return e.checkWalletSend(tx, isOwnOutput)
+		}
+	}
+
+	return nil
+}
+
+// checkFundingSpend makes sure a spend of a channel funding output, such as a
+// cooperative close, only pays to our own wallet or to explicitly allowed
+// destinations. Otherwise a compromised watch-only node could have the signer
+// sign away the channel funds.
+func (e *Engine) checkFundingSpend(tx *wire.MsgTx,
+	ownOutput func(int) bool) error {
+
+	for i, txOut := range tx.TxOut {
+		if ownOutput(i) {
+			continue
+		}
+
+		pkScript := string(txOut.PkScript)
+		if _, ok := e.cfg.AllowedDestinations[pkScript]; ok {
+			continue
+		}
+
+		return fmt.Errorf("%w: output %d pays to %x",
+			ErrFundingSpendNotAllowed, i, txOut.PkScript)
+	}
+
+	return nil
+}
+
+// checkCommitment makes sure a commitment transaction isn't revoked and
+// passes the fee and HTLC sanity checks.
+func (e *Engine) checkCommitment(tx *wire.MsgTx, fundingInput Input) error {
+	numHTLCs := len(tx.TxOut) - maxCommitNonHTLCOutputs
+	if numHTLCs > e.cfg.MaxCommitHTLCs {
+		return fmt.Errorf("%w: commitment has %d outputs, at most %d "+
+			"HTLCs allowed", ErrInvalidCommitment, len(tx.TxOut),
+			e.cfg.MaxCommitHTLCs)
+	}
+
+	var outputSum int64
+	for i, txOut := range tx.TxOut {
+		if txOut.Value < minCommitOutputValue {
+			return fmt.Errorf("%w: output %d with value %d is "+
+				"below dust", ErrInvalidCommitment, i,
+				txOut.Value)
+		}
+		outputSum += txOut.Value
+	}
+
+	if fundingInput.PrevOut == nil {
+		return fmt.Errorf("%w: funding output unknown",
+			ErrInvalidCommitment)
+	}
+
+	fee := btcutil.Amount(fundingInput.PrevOut.Value - outputSum)
+	if fee < 0 {
+		return fmt.Errorf("%w: outputs exceed funding amount",
+			ErrInvalidCommitment)
+	}
+
+	weight := int64(tx.SerializeSizeStripped())*4 +
+		input.WitnessCommitmentTxWeight
+	feeRate := chainfee.SatPerKWeight(int64(fee) * 1000 / weight)
+	if feeRate > e.cfg.MaxCommitFeeRate {
+		return fmt.Errorf("%w: fee rate %v exceeds maximum of %v",
+			ErrInvalidCommitment, feeRate, e.cfg.MaxCommitFeeRate)
+	}
+
+	// The state is only recorded once all other checks passed. All
+	// commitments of a channel share the same obfuscator, so we can track
+	// the states by their obscured numbers.
+	obscured := lnwallet.GetStateNumHint(
+		tx, [lnwallet.StateHintSize]byte{},
+	)
+
+	return e.store.recordCommitment(
+		tx.TxIn[0].PreviousOutPoint, obscured, tx.TxHash(),
+		RevokedDepth,
+	)
+}
+
+// checkWalletSend makes sure a wallet send only pays to allowed destinations
+// and doesn't exceed the velocity limit.
+func (e *Engine) checkWalletSend(tx *wire.MsgTx,
+	ownOutput func(int) bool) error {
+
+	var external btcutil.Amount
+	for i, txOut := range tx.TxOut {
+		if ownOutput(i) {
+			continue
+		}
+
+		external += btcutil.Amount(txOut.Value)
+
+		if !e.destinationAllowed(txOut.PkScript) {
+			return fmt.Errorf("%w: output %d pays to %x",
+				ErrDestinationNotAllowed, i, txOut.PkScript)
+		}
+	}
+
+	if e.cfg.SendLimit == 0 || external == 0 {
+		return nil
+	}
+
+	return e.store.recordSend(
+		tx.TxHash(), external, e.cfg.SendLimit, e.cfg.SendWindow,
+		e.cfg.Clock.Now(),
+	)
+}
+
+// destinationAllowed returns true if wallet sends can pay to the given output
+// script.
+func (e *Engine) destinationAllowed(pkScript []byte) bool {
+	if len(e.cfg.AllowedDestinations) == 0 {
+		return true
+	}
+
+	if _, ok := e.cfg.AllowedDestinations[string(pkScript)]; ok {
+		return true
+	}
+
+	if !e.cfg.AllowChannelFunding {
+		return false
+	}
+
+	return txscript.IsPayToWitnessScriptHash(pkScript) ||
+		txscript.IsPayToTaproot(pkScript)
+}
+
+// IsCommitment returns true if the transaction has the structure of a channel
+// commitment transaction: a single input with the state hint encoded in its
+// sequence and the locktime of the transaction.
+func IsCommitment(tx *wire.MsgTx) bool {
+	if len(tx.TxIn) != 1 {
+		return false
+	}
+
+	sequence := tx.TxIn[0].Sequence
+
+	return sequence&0xff000000 == wire.SequenceLockTimeDisabled &&
+		tx.LockTime&0xff000000 == lnwallet.TimelockShift
+}
+
+// isFundingPath returns true if the BIP32 path belongs to lnd's multisig key
+// family, which is used for channel funding outputs.
+func isFundingPath(path []uint32) bool {
+	if len(path) < 3 {
+		return false
+	}
+
+	return path[0] == hdkeychain.HardenedKeyStart+keychain.BIP0043Purpose &&
+		path[2] == hdkeychain.HardenedKeyStart+
+			uint32(keychain.KeyFamilyMultiSig)
+}
+
+// isWalletPath returns true if the BIP32 path is in one of the key scopes of
+// the on-chain wallet.
+func isWalletPath(path []uint32) bool {
+	if len(path) == 0 || path[0] < hdkeychain.HardenedKeyStart {
+		return false
+	}
+
+	switch path[0] - hdkeychain.HardenedKeyStart {
+	case waddrmgr.KeyScopeBIP0049Plus.Purpose,
+		waddrmgr.KeyScopeBIP0084.Purpose,
+		waddrmgr.KeyScopeBIP0086.Purpose:
+
+		return true
+
+	default:
+		return false
+	}
+}
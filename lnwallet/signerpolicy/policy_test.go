@@ -0,0 +1,452 @@
+package signerpolicy
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/stretchr/testify/require"
+)
+
+const fundingAmt = 1_000_000
+
+var (
+	testObfuscator = [lnwallet.StateHintSize]byte{1, 2, 3, 4, 5, 6}
+
+	testChanPoint = wire.OutPoint{Hash: [32]byte{1}, Index: 1}
+
+	// The output scripts are only compared byte by byte, so they don't
+	// need to be valid.
+	p2wkhScript  = append([]byte{0x00, 0x14}, make([]byte, 20)...)
+	p2wshScript  = append([]byte{0x00, 0x20}, make([]byte, 32)...)
+	allowedAddr  = append([]byte{0x00, 0x14}, repeat(0xaa, 20)...)
+	changeScript = append([]byte{0x00, 0x14}, repeat(0xcc, 20)...)
+)
+
+// repeat returns a slice of n copies of the given byte.
+func repeat(b byte, n int) []byte {
+	s := make([]byte, n)
+	for i := range s {
+		s[i] = b
+	}
+
+	return s
+}
+
+// newTestEngine creates a policy engine backed by a temporary bolt database.
+func newTestEngine(t *testing.T, cfg *Config) *Engine {
+	db, err := kvdb.Create(
+		kvdb.BoltBackendName, filepath.Join(t.TempDir(), "policy.db"),
+		true, kvdb.DefaultDBTimeout,
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	cfg.DB = db
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewDefaultClock()
+	}
+	if cfg.MaxCommitFeeRate == 0 {
+		cfg.MaxCommitFeeRate = DefaultMaxCommitFeeRate
+	}
+	if cfg.MaxCommitHTLCs == 0 {
+		cfg.MaxCommitHTLCs = DefaultMaxCommitHTLCs
+	}
+
+	engine, err := New(cfg)
+	require.NoError(t, err)
+
+	return engine
+}
+
+// commitTx creates a commitment transaction of the test channel for the given
+// state with a to_local and to_remote output and the given fee.
+func commitTx(t *testing.T, stateNum uint64, fee int64) (*wire.MsgTx,
+	[]Input) {
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{PreviousOutPoint: testChanPoint})
+
+	toLocal := (fundingAmt - fee) / 2
+	tx.AddTxOut(wire.NewTxOut(toLocal, p2wshScript))
+	tx.AddTxOut(wire.NewTxOut(fundingAmt-fee-toLocal, p2wkhScript))
+
+	err := lnwallet.SetStateNumHint(tx, stateNum, testObfuscator)
+	require.NoError(t, err)
+
+	return tx, []Input{{
+		PrevOut:    wire.NewTxOut(fundingAmt, p2wshScript),
+		FundingKey: true,
+	}}
+}
+
+// walletSend creates a transaction that spends a wallet output and pays the
+// given amounts to the given scripts.
+func walletSend(outputs map[string]int64) (*wire.MsgTx, []Input) {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Hash: [32]byte{2}},
+	})
+	for script, amt := range outputs {
+		tx.AddTxOut(wire.NewTxOut(amt, []byte(script)))
+	}
+
+	return tx, []Input{{
+		PrevOut:   wire.NewTxOut(fundingAmt, p2wkhScript),
+		WalletKey: true,
+	}}
+}
+
+// coopClose creates a transaction shaped like a cooperative close of the test
+// channel that pays the given amounts to the given scripts. Unlike
+// commitments, it has a final sequence and no locktime.
+func coopClose(outputs map[string]int64) (*wire.MsgTx, []Input) {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: testChanPoint,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	for script, amt := range outputs {
+		tx.AddTxOut(wire.NewTxOut(amt, []byte(script)))
+	}
+
+	return tx, []Input{{
+		PrevOut:    wire.NewTxOut(fundingAmt, p2wshScript),
+		FundingKey: true,
+	}}
+}
+
+// isChange reports the outputs paying to the change script as our own.
+func isChange(tx *wire.MsgTx) func(int) bool {
+	return func(idx int) bool {
+		return string(tx.TxOut[idx].PkScript) == string(changeScript)
+	}
+}
+
+// TestRevokedCommitment tests that a commitment state is refused once two
+// newer states of the same channel were signed.
+func TestRevokedCommitment(t *testing.T) {
+	t.Parallel()
+
+	engine := newTestEngine(t, &Config{AllowUnverifiedCommitments: true})
+
+	check := func(stateNum uint64, local bool) error {
+		tx, inputs := commitTx(t, stateNum, 1000)
+		require.True(t, IsCommitment(tx))
+
+		// Our own commitment of the same state pays the balances to
+		// different scripts.
+		if local {
+			tx.TxOut[0].PkScript = p2wkhScript
+			tx.TxOut[1].PkScript = p2wshScript
+		}
+
+		return engine.CheckTransaction(tx, inputs, nil)
+	}
+
+	require.NoError(t, check(10, false))
+	require.NoError(t, check(11, false))
+
+	// Remote commitments can be signed again, and our own commitment can
+	// lag behind the remote one by one state.
+	require.NoError(t, check(10, false))
+	require.NoError(t, check(11, false))
+	require.NoError(t, check(10, true))
+
+	require.NoError(t, check(12, false))
+	require.ErrorIs(t, check(10, false), ErrRevokedCommitment)
+	require.ErrorIs(t, check(10, false), ErrPolicyViolation)
+	require.ErrorIs(t, check(10, true), ErrRevokedCommitment)
+	require.NoError(t, check(11, false))
+	require.NoError(t, check(12, false))
+
+	// States we haven't seen are only signed if they follow the most
+	// recent remote commitment, even if their obscured numbers look like
+	// they could.
+	require.ErrorIs(t, check(3, false), ErrRevokedCommitment)
+	require.ErrorIs(t, check(9, true), ErrRevokedCommitment)
+	require.ErrorIs(t, check(14, false), ErrRevokedCommitment)
+
+	// Once we signed our own commitment, older ones are refused.
+	require.NoError(t, check(12, true))
+	require.ErrorIs(t, check(11, true), ErrRevokedCommitment)
+	require.NoError(t, check(12, true))
+
+	require.NoError(t, check(13, false))
+	require.NoError(t, check(14, false))
+}
+
+// TestCommitmentSanity tests the fee and HTLC checks of commitments.
+func TestCommitmentSanity(t *testing.T) {
+	t.Parallel()
+
+	engine := newTestEngine(t, &Config{
+		MaxCommitHTLCs:             2,
+		AllowUnverifiedCommitments: true,
+	})
+
+	// A fee of half the channel capacity is way above the maximum fee
+	// rate.
+	tx, inputs := commitTx(t, 1, fundingAmt/2)
+	err := engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrInvalidCommitment)
+
+	// Outputs that exceed the funding amount are refused as well.
+	tx, inputs = commitTx(t, 1, -1000)
+	err = engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrInvalidCommitment)
+
+	// The commitment can't have more HTLC outputs than configured.
+	tx, inputs = commitTx(t, 1, 1000)
+	for i := 0; i < 5; i++ {
+		tx.AddTxOut(wire.NewTxOut(1000, p2wshScript))
+		tx.TxOut[0].Value -= 1000
+	}
+	err = engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrInvalidCommitment)
+
+	// And none of its outputs can be dust.
+	tx, inputs = commitTx(t, 1, 1000)
+	tx.AddTxOut(wire.NewTxOut(100, p2wshScript))
+	tx.TxOut[0].Value -= 100
+	err = engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrInvalidCommitment)
+
+	// Rejected commitments don't advance the channel state.
+	tx, inputs = commitTx(t, 1, 1000)
+	require.NoError(t, engine.CheckTransaction(tx, inputs, nil))
+}
+
+// TestUnverifiedCommitments tests that transactions shaped like commitments
+// are checked like any other spend unless unverified commitments are allowed.
+func TestUnverifiedCommitments(t *testing.T) {
+	t.Parallel()
+
+	engine := newTestEngine(t, &Config{
+		AllowedDestinations: map[string]struct{}{
+			string(allowedAddr): {},
+		},
+	})
+
+	// A "commitment" that pays the whole channel to a foreign address is
+	// just a funding output spend.
+	tx, inputs := commitTx(t, 1, 1000)
+	tx.TxOut = []*wire.TxOut{wire.NewTxOut(fundingAmt-1000, p2wkhScript)}
+	require.True(t, IsCommitment(tx))
+
+	err := engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrFundingSpendNotAllowed)
+
+	// The same goes for a wallet send shaped like a commitment.
+	inputs[0].FundingKey = false
+	inputs[0].WalletKey = true
+	err = engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrDestinationNotAllowed)
+
+	// Only once unverified commitments are allowed, spends of funding
+	// outputs shaped like commitments are signed.
+	engine.cfg.AllowUnverifiedCommitments = true
+	err = engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrDestinationNotAllowed)
+
+	inputs[0].FundingKey = true
+	inputs[0].WalletKey = false
+	require.NoError(t, engine.CheckTransaction(tx, inputs, nil))
+}
+
+// TestAllowedDestinations tests that wallet sends can only pay to allowed
+// destinations or back to the wallet.
+func TestAllowedDestinations(t *testing.T) {
+	t.Parallel()
+
+	engine := newTestEngine(t, &Config{
+		AllowedDestinations: map[string]struct{}{
+			string(allowedAddr): {},
+		},
+	})
+
+	tx, inputs := walletSend(map[string]int64{
+		string(allowedAddr):  1000,
+		string(changeScript): 5000,
+	})
+	require.NoError(t, engine.CheckTransaction(tx, inputs, isChange(tx)))
+
+	tx, inputs = walletSend(map[string]int64{
+		string(p2wkhScript): 1000,
+	})
+	err := engine.CheckTransaction(tx, inputs, isChange(tx))
+	require.ErrorIs(t, err, ErrDestinationNotAllowed)
+
+	// Channel funding outputs are only allowed if enabled.
+	tx, inputs = walletSend(map[string]int64{
+		string(p2wshScript): 1000,
+	})
+	err = engine.CheckTransaction(tx, inputs, isChange(tx))
+	require.ErrorIs(t, err, ErrDestinationNotAllowed)
+
+	engine.cfg.AllowChannelFunding = true
+	require.NoError(t, engine.CheckTransaction(tx, inputs, isChange(tx)))
+
+	// Transactions that don't spend wallet outputs aren't wallet sends.
+	inputs[0].WalletKey = false
+	tx.TxOut[0].PkScript = p2wkhScript
+	require.NoError(t, engine.CheckTransaction(tx, inputs, nil))
+}
+
+// TestFundingSpend tests that spends of channel funding outputs that aren't
+// commitments can only pay to our own wallet or allowed destinations.
+func TestFundingSpend(t *testing.T) {
+	t.Parallel()
+
+	ownScript := append([]byte{0x00, 0x14}, repeat(0xdd, 20)...)
+	engine := newTestEngine(t, &Config{
+		AllowedDestinations: map[string]struct{}{
+			string(allowedAddr): {},
+		},
+		AllowChannelFunding: true,
+		IsOwnScript: func(pkScript []byte) bool {
+			return string(pkScript) == string(ownScript)
+		},
+	})
+
+	// A cooperative close paying to a foreign address is refused, even
+	// if the rest goes to our wallet.
+	tx, inputs := coopClose(map[string]int64{
+		string(ownScript):   500_000,
+		string(p2wkhScript): 499_000,
+	})
+	require.False(t, IsCommitment(tx))
+	err := engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrFundingSpendNotAllowed)
+	require.ErrorIs(t, err, ErrPolicyViolation)
+
+	// The channel funding exemption of wallet sends doesn't apply to
+	// funding output spends.
+	tx, inputs = coopClose(map[string]int64{
+		string(p2wshScript): 999_000,
+	})
+	err = engine.CheckTransaction(tx, inputs, nil)
+	require.ErrorIs(t, err, ErrFundingSpendNotAllowed)
+
+	// Outputs that pay to our wallet, either according to the caller or
+	// the wallet itself, or to allowed destinations are fine.
+	tx, inputs = coopClose(map[string]int64{
+		string(ownScript):    500_000,
+		string(allowedAddr):  300_000,
+		string(changeScript): 199_000,
+	})
+	require.NoError(t, engine.CheckTransaction(tx, inputs, isChange(tx)))
+
+	// Without any allowed destinations, only our own outputs are fine.
+	engine.cfg.AllowedDestinations = nil
+	err = engine.CheckTransaction(tx, inputs, isChange(tx))
+	require.ErrorIs(t, err, ErrFundingSpendNotAllowed)
+
+	tx, inputs = coopClose(map[string]int64{
+		string(ownScript): 999_000,
+	})
+	require.NoError(t, engine.CheckTransaction(tx, inputs, nil))
+}
+
+// TestVelocityLimit tests that the amount sent to external destinations is
+// limited within the rolling send window.
+func TestVelocityLimit(t *testing.T) {
+	t.Parallel()
+
+	testClock := clock.NewTestClock(time.Unix(1700000000, 0))
+	engine := newTestEngine(t, &Config{
+		Clock:      testClock,
+		SendLimit:  btcutil.Amount(10_000),
+		SendWindow: time.Hour,
+	})
+
+	send := func(amt int64) (*wire.MsgTx, []Input) {
+		return walletSend(map[string]int64{
+			string(p2wkhScript):  amt,
+			string(changeScript): 50_000,
+		})
+	}
+
+	tx1, inputs1 := send(6000)
+	require.NoError(t, engine.CheckTransaction(tx1, inputs1, isChange(tx1)))
+
+	// Signing the same transaction again doesn't count twice.
+	require.NoError(t, engine.CheckTransaction(tx1, inputs1, isChange(tx1)))
+
+	testClock.SetTime(testClock.Now().Add(30 * time.Minute))
+	tx2, inputs2 := send(4000)
+	require.NoError(t, engine.CheckTransaction(tx2, inputs2, isChange(tx2)))
+
+	tx3, inputs3 := send(1)
+	err := engine.CheckTransaction(tx3, inputs3, isChange(tx3))
+	require.ErrorIs(t, err, ErrVelocityExceeded)
+
+	// Once the first send drops out of the window, its amount can be sent
+	// again.
+	testClock.SetTime(testClock.Now().Add(31 * time.Minute))
+	tx4, inputs4 := send(6000)
+	require.NoError(t, engine.CheckTransaction(tx4, inputs4, isChange(tx4)))
+}
+
+// TestCheckPsbt tests that inputs with a wallet derivation path make a packet
+// a wallet send.
+func TestCheckPsbt(t *testing.T) {
+	t.Parallel()
+
+	engine := newTestEngine(t, &Config{
+		AllowedDestinations: map[string]struct{}{
+			string(allowedAddr): {},
+		},
+	})
+
+	tx, _ := walletSend(map[string]int64{
+		string(p2wkhScript): 1000,
+	})
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	require.NoError(t, err)
+	packet.Inputs[0].WitnessUtxo = wire.NewTxOut(fundingAmt, p2wkhScript)
+
+	// Without a derivation path of the wallet, the packet is signed.
+	require.NoError(t, engine.CheckPsbt(packet, nil))
+
+	packet.Inputs[0].Bip32Derivation = []*psbt.Bip32Derivation{{
+		Bip32Path: []uint32{
+			hdkeychain.HardenedKeyStart + 84,
+			hdkeychain.HardenedKeyStart,
+			hdkeychain.HardenedKeyStart, 0, 0,
+		},
+	}}
+	err = engine.CheckPsbt(packet, nil)
+	require.ErrorIs(t, err, ErrDestinationNotAllowed)
+
+	// Inputs with a path of the multisig key family are funding outputs.
+	packet.Inputs[0].Bip32Derivation = []*psbt.Bip32Derivation{{
+		Bip32Path: []uint32{
+			hdkeychain.HardenedKeyStart + 1017,
+			hdkeychain.HardenedKeyStart,
+			hdkeychain.HardenedKeyStart, 0, 0,
+		},
+	}}
+	err = engine.CheckPsbt(packet, nil)
+	require.ErrorIs(t, err, ErrFundingSpendNotAllowed)
+}
+
+// TestMuSig2 tests that MuSig2 signing requests are only served if allowed.
+func TestMuSig2(t *testing.T) {
+	t.Parallel()
+
+	engine := newTestEngine(t, &Config{})
+	require.ErrorIs(t, engine.CheckMuSig2Sign(), ErrUnverifiedMuSig2)
+
+	engine.cfg.AllowUnverifiedMuSig2 = true
+	require.NoError(t, engine.CheckMuSig2Sign())
+}
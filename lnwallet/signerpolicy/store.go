@@ -0,0 +1,347 @@
+package signerpolicy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+var (
+	// policyBucket is the top level bucket that holds all state of the
+	// signer policy.
+	policyBucket = []byte("signer-policy")
+
+	// channelStateBucket is a sub bucket of the policy bucket that holds
+	// one bucket per channel, keyed by the funding outpoint. Each channel
+	// bucket stores the obscured state numbers of all remote commitments
+	// that were signed, together with their height relative to the first
+	// one and their txid.
+	channelStateBucket = []byte("channel-states")
+
+	// remoteTipKey is the key within a channel bucket that stores the
+	// height and obscured state number of the most recent remote
+	// commitment, together with the bits of its state number that are
+	// known from the state transitions seen so far.
+	remoteTipKey = []byte("remote-tip")
+
+	// localTipKey is the key within a channel bucket that stores the
+	// height of the most recent local commitment that was signed.
+	localTipKey = []byte("local-tip")
+
+	// sendsBucket is a sub bucket of the policy bucket that holds the
+	// wallet sends within the velocity window. The sends are stored with a
+	// key of the form <unix-nano-timestamp><txid> and the amount sent to
+	// external destinations as value.
+	sendsBucket = []byte("sends")
+
+	// errBucketNotFound is returned if the policy buckets weren't created.
+	errBucketNotFound = errors.New("signer policy bucket not found")
+)
+
+// store persists the channel states and wallet sends the signer policy needs
+// to keep track of.
+type store struct {
+	db kvdb.Backend
+}
+
+// newStore creates the policy buckets if they don't exist yet.
+func newStore(db kvdb.Backend) (*store, error) {
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		policy, err := tx.CreateTopLevelBucket(policyBucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = policy.CreateBucketIfNotExists(channelStateBucket)
+		if err != nil {
+			return err
+		}
+
+		_, err = policy.CreateBucketIfNotExists(sendsBucket)
+		return err
+	}, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return &store{db: db}, nil
+}
+
+// remoteTip is the most recent remote commitment of a channel.
+type remoteTip struct {
+	// height is the height of the commitment, relative to the first
+	// commitment of the channel the signer has seen.
+	height uint64
+
+	// obscured is the obscured state number of the commitment.
+	obscured uint64
+
+	// knownMask marks the bits of the commitment's state number that are
+	// known from the state transitions seen so far, knownBits holds their
+	// values.
+	knownMask uint64
+	knownBits uint64
+}
+
+// next returns the tip after the state with the given obscured number, if that
+// state directly follows the tip. Going from state n to n+1 flips the trailing
+// ones of n and its lowest zero bit, so the obscured numbers of two
+// consecutive states differ in their lowest k bits only. As going from n to
+// n-1 does the same, the bits of the state number learned from earlier
+// transitions are used to tell the next state from older ones.
+func (r *remoteTip) next(obscured uint64) (*remoteTip, bool) {
+	mask := r.obscured ^ obscured
+	if mask == 0 || mask&(mask+1) != 0 {
+		return nil, false
+	}
+
+	// The tip's state number must end in a zero followed by ones for the
+	// new state to be the next one.
+	top := mask ^ (mask >> 1)
+	expected := mask ^ top
+	if (r.knownBits^expected)&r.knownMask&mask != 0 {
+		return nil, false
+	}
+
+	return &remoteTip{
+		height:    r.height + 1,
+		obscured:  obscured,
+		knownMask: r.knownMask | mask,
+		knownBits: r.knownBits&^mask | top,
+	}, true
+}
+
+// encode serializes the tip.
+func (r *remoteTip) encode() []byte {
+	var b [32]byte
+	binary.BigEndian.PutUint64(b[:8], r.height)
+	binary.BigEndian.PutUint64(b[8:16], r.obscured)
+	binary.BigEndian.PutUint64(b[16:24], r.knownMask)
+	binary.BigEndian.PutUint64(b[24:], r.knownBits)
+
+	return b[:]
+}
+
+// decodeRemoteTip deserializes a tip.
+func decodeRemoteTip(b []byte) (*remoteTip, error) {
+	if len(b) != 32 {
+		return nil, fmt.Errorf("invalid remote tip length %d", len(b))
+	}
+
+	return &remoteTip{
+		height:    binary.BigEndian.Uint64(b[:8]),
+		obscured:  binary.BigEndian.Uint64(b[8:16]),
+		knownMask: binary.BigEndian.Uint64(b[16:24]),
+		knownBits: binary.BigEndian.Uint64(b[24:]),
+	}, nil
+}
+
+// recordCommitment records that a commitment with the given obscured state
+// number and txid of the channel with the given funding outpoint is about to
+// be signed.
+//
+// The signer can't decode the state numbers, but it can tell the state that
+// directly follows the most recent remote commitment. A commitment that was
+// already signed with a different txid is our own commitment at that height,
+// as both parties' commitments share the state numbers. An error is returned
+// if the commitment is at least revokedDepth states below the most recent
+// remote commitment, below our most recent own commitment or neither a known
+// state nor the next one.
+func (s *store) recordCommitment(chanPoint wire.OutPoint, obscured uint64,
+	txid chainhash.Hash, revokedDepth uint64) error {
+
+	var chanKey bytes.Buffer
+	if err := writeOutpoint(&chanKey, &chanPoint); err != nil {
+		return err
+	}
+
+	var stateKey [8]byte
+	binary.BigEndian.PutUint64(stateKey[:], obscured)
+
+	return kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		policy := tx.ReadWriteBucket(policyBucket)
+		if policy == nil {
+			return errBucketNotFound
+		}
+		states := policy.NestedReadWriteBucket(channelStateBucket)
+		if states == nil {
+			return errBucketNotFound
+		}
+
+		channel, err := states.CreateBucketIfNotExists(chanKey.Bytes())
+		if err != nil {
+			return err
+		}
+
+		// The first commitment we see for a channel becomes its tip.
+		tipBytes := channel.Get(remoteTipKey)
+		if tipBytes == nil {
+			tip := &remoteTip{height: 1, obscured: obscured}
+			return putRemoteState(channel, stateKey[:], tip, txid)
+		}
+
+		tip, err := decodeRemoteTip(tipBytes)
+		if err != nil {
+			return err
+		}
+
+		stateBytes := channel.Get(stateKey[:])
+		if stateBytes == nil {
+			next, ok := tip.next(obscured)
+			if !ok {
+				return fmt.Errorf("%w: unknown state that "+
+					"doesn't follow remote height %d",
+					ErrRevokedCommitment, tip.height)
+			}
+
+			return putRemoteState(channel, stateKey[:], next, txid)
+		}
+
+		if len(stateBytes) != 8+chainhash.HashSize {
+			return fmt.Errorf("invalid commitment state length %d",
+				len(stateBytes))
+		}
+
+		height := binary.BigEndian.Uint64(stateBytes[:8])
+		if tip.height-height >= revokedDepth {
+			return fmt.Errorf("%w: state superseded by %d newer "+
+				"states", ErrRevokedCommitment,
+				tip.height-height)
+		}
+
+		// The remote commitment is signed again, for example after a
+		// reconnect.
+		if bytes.Equal(stateBytes[8:], txid[:]) {
+			return nil
+		}
+
+		// Otherwise this is our own commitment, which must not be
+		// older than the last one we signed.
+		if localBytes := channel.Get(localTipKey); localBytes != nil {
+			localHeight := binary.BigEndian.Uint64(localBytes)
+			if height < localHeight {
+				return fmt.Errorf("%w: local state superseded "+
+					"by %d newer states",
+					ErrRevokedCommitment,
+					localHeight-height)
+			}
+		}
+
+		var localBytes [8]byte
+		binary.BigEndian.PutUint64(localBytes[:], height)
+
+		return channel.Put(localTipKey, localBytes[:])
+	}, func() {})
+}
+
+// putRemoteState stores a new remote commitment state as the tip of a channel.
+func putRemoteState(channel kvdb.RwBucket, stateKey []byte, tip *remoteTip,
+	txid chainhash.Hash) error {
+
+	state := make([]byte, 8+chainhash.HashSize)
+	binary.BigEndian.PutUint64(state[:8], tip.height)
+	copy(state[8:], txid[:])
+
+	if err := channel.Put(stateKey, state); err != nil {
+		return err
+	}
+
+	return channel.Put(remoteTipKey, tip.encode())
+}
+
+// recordSend records a wallet send of the given amount to external
+// destinations, if that doesn't exceed the amount that can be sent within the
+// velocity window. A transaction is only counted once, no matter how often its
+// inputs are signed.
+func (s *store) recordSend(txid chainhash.Hash, amt btcutil.Amount,
+	limit btcutil.Amount, window time.Duration, now time.Time) error {
+
+	startKey := sendKey(now.Add(-window), chainhash.Hash{})
+
+	return kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		policy := tx.ReadWriteBucket(policyBucket)
+		if policy == nil {
+			return errBucketNotFound
+		}
+		sends := policy.NestedReadWriteBucket(sendsBucket)
+		if sends == nil {
+			return errBucketNotFound
+		}
+
+		var (
+			expired [][]byte
+			sent    btcutil.Amount
+			known   bool
+		)
+		err := sends.ForEach(func(k, v []byte) error {
+			if bytes.Compare(k, startKey) < 0 {
+				expired = append(
+					expired, append([]byte(nil), k...),
+				)
+				return nil
+			}
+
+			if bytes.Equal(k[8:], txid[:]) {
+				known = true
+			}
+			sent += btcutil.Amount(binary.BigEndian.Uint64(v))
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := sends.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		// Transactions with more than one input are signed in more
+		// than one request.
+		if known {
+			return nil
+		}
+
+		if sent+amt > limit {
+			return fmt.Errorf("%w: sending %v would exceed limit "+
+				"of %v within %v, already sent %v",
+				ErrVelocityExceeded, amt, limit, window, sent)
+		}
+
+		var value [8]byte
+		binary.BigEndian.PutUint64(value[:], uint64(amt))
+
+		return sends.Put(sendKey(now, txid), value[:])
+	}, func() {})
+}
+
+// sendKey returns the key of a wallet send made at the given time.
+func sendKey(t time.Time, txid chainhash.Hash) []byte {
+	key := make([]byte, 8+chainhash.HashSize)
+	binary.BigEndian.PutUint64(key[:8], uint64(t.UnixNano()))
+	copy(key[8:], txid[:])
+
+	return key
+}
+
+// writeOutpoint serializes an outpoint as a channel bucket key.
+func writeOutpoint(w *bytes.Buffer, op *wire.OutPoint) error {
+	if _, err := w.Write(op.Hash[:]); err != nil {
+		return err
+	}
+
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], op.Index)
+	_, err := w.Write(index[:])
+
+	return err
+}
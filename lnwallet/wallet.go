@@ -2577,6 +2577,75 @@ func (c *CoinSource) CoinFromOutPoint(op wire.OutPoint) (*wallet.Coin, error) {
 	}, nil
 }
 
+// CoinInfo returns the wallet metadata of all coins of the default account.
+//
+// NOTE: This is part of the chanfunding.CoinInfoSource interface.
+func (c *CoinSource) CoinInfo() (map[wire.OutPoint]*chanfunding.CoinInfo,
+	error) {
+
+	return FetchCoinInfo(c.wallet, DefaultAccountName)
+}
+
+// FetchCoinInfo returns the wallet metadata of the unspent outputs of the
+// given account that coin selection strategies use to decide which coins can
+// be spent together.
+//
+// NOTE: This method requires the global coin selection lock to be held.
+func FetchCoinInfo(w WalletController,
+	account string) (map[wire.OutPoint]*chanfunding.CoinInfo, error) {
+
+	utxos, err := w.ListUnspentWitness(0, math.MaxInt32, account)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		info   = make(map[wire.OutPoint]*chanfunding.CoinInfo)
+		labels = make(map[chainhash.Hash]string)
+	)
+	for _, utxo := range utxos {
+		label, ok := labels[utxo.Hash]
+		if !ok {
+			details, err := w.GetTransactionDetails(&utxo.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			label = details.Label
+			labels[utxo.Hash] = label
+		}
+
+		info[utxo.OutPoint] = &chanfunding.CoinInfo{
+			Account:       account,
+			Label:         label,
+			Confirmations: utxo.Confirmations,
+		}
+	}
+
+	return info, nil
+}
+
+// StrategyWithCoinInfo provides the given coin selection strategy with the
+// wallet metadata of the coins of the given account if it makes use of it.
+//
+// NOTE: This method requires the global coin selection lock to be held.
+func StrategyWithCoinInfo(w WalletController,
+	strategy wallet.CoinSelectionStrategy,
+	account string) (wallet.CoinSelectionStrategy, error) {
+
+	s, ok := strategy.(chanfunding.ContextualStrategy)
+	if !ok || !s.NeedsCoinInfo() {
+		return strategy, nil
+	}
+
+	info, err := FetchCoinInfo(w, account)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.WithCoinInfo(info), nil
+}
+
 // shimKeyRing is a wrapper struct that's used to provide the proper multi-sig
 // key for an initiated external funding flow.
 type shimKeyRing struct {
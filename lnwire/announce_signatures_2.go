@@ -0,0 +1,139 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// AnnounceSignatures2 is the message that is exchanged between the two peers
+// of a taproot channel to create the MuSig2 signature of its
+// ChannelAnnouncement2. The signature is created by all four node and bitcoin
+// keys of the channel, so the exchange takes two rounds: first each peer sends
+// the public nonces of its node and bitcoin key, then once the nonces of the
+// remote peer are known, each peer sends the sum of the partial signatures of
+// its two keys.
+type AnnounceSignatures2 struct {
+	// ChannelID is the unique description of the funding transaction.
+	// Channel id is better for users and debugging and short channel id is
+	// used for quick test on existence of the particular utxo inside the
+	// blockchain, because it contains information about block.
+	ChannelID ChannelID
+
+	// ShortChannelID is the unique description of the funding transaction.
+	// It is constructed with the most significant 3 bytes as the block
+	// height, the next 3 bytes indicating the transaction index within the
+	// block, and the least significant two bytes indicating the output
+	// index which pays to the channel.
+	ShortChannelID ShortChannelID
+
+	// NodeNonce is the public MuSig2 nonce of the node key of the sender.
+	// It is sent in the first round of the exchange.
+	NodeNonce tlv.OptionalRecordT[tlv.TlvType1, Musig2Nonce]
+
+	// BitcoinNonce is the public MuSig2 nonce of the bitcoin key of the
+	// sender. It is sent in the first round of the exchange.
+	BitcoinNonce tlv.OptionalRecordT[tlv.TlvType3, Musig2Nonce]
+
+	// PartialSignature is the sum of the MuSig2 partial signatures of the
+	// node and bitcoin key of the sender over the ChannelAnnouncement2 of
+	// the channel. It is sent in the second round of the exchange.
+	PartialSignature tlv.OptionalRecordT[tlv.TlvType5, PartialSig]
+
+	// ExtraOpaqueData is the set of data that was appended to this
+	// message, some of which we may not actually know how to iterate or
+	// parse.
+	ExtraOpaqueData ExtraOpaqueData
+}
+
+// A compile time check to ensure AnnounceSignatures2 implements the
+// lnwire.Message interface.
+var _ Message = (*AnnounceSignatures2)(nil)
+
+// HasNonces returns true if the message carries the public nonces of the
+// sender.
+func (a *AnnounceSignatures2) HasNonces() bool {
+	return a.NodeNonce.IsSome() && a.BitcoinNonce.IsSome()
+}
+
+// Decode deserializes a serialized AnnounceSignatures2 stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (a *AnnounceSignatures2) Decode(r io.Reader, _ uint32) error {
+	err := ReadElements(r, &a.ChannelID, &a.ShortChannelID)
+	if err != nil {
+		return err
+	}
+
+	var tlvRecords ExtraOpaqueData
+	if err := ReadElements(r, &tlvRecords); err != nil {
+		return err
+	}
+
+	var (
+		nodeNonce    = a.NodeNonce.Zero()
+		bitcoinNonce = a.BitcoinNonce.Zero()
+		partialSig   = a.PartialSignature.Zero()
+	)
+	typeMap, err := tlvRecords.ExtractRecords(
+		&nodeNonce, &bitcoinNonce, &partialSig,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := typeMap[a.NodeNonce.TlvType()]; ok {
+		a.NodeNonce = tlv.SomeRecordT(nodeNonce)
+	}
+	if _, ok := typeMap[a.BitcoinNonce.TlvType()]; ok {
+		a.BitcoinNonce = tlv.SomeRecordT(bitcoinNonce)
+	}
+	if _, ok := typeMap[a.PartialSignature.TlvType()]; ok {
+		a.PartialSignature = tlv.SomeRecordT(partialSig)
+	}
+
+	a.ExtraOpaqueData, err = unknownRecordsFromTypeMap(typeMap)
+
+	return err
+}
+
+// Encode serializes the target AnnounceSignatures2 into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (a *AnnounceSignatures2) Encode(w *bytes.Buffer, _ uint32) error {
+	if err := WriteChannelID(w, a.ChannelID); err != nil {
+		return err
+	}
+
+	if err := WriteShortChannelID(w, a.ShortChannelID); err != nil {
+		return err
+	}
+
+	var records []tlv.RecordProducer
+	a.NodeNonce.WhenSome(func(r tlv.RecordT[tlv.TlvType1, Musig2Nonce]) {
+		records = append(records, &r)
+	})
+	a.BitcoinNonce.WhenSome(
+		func(r tlv.RecordT[tlv.TlvType3, Musig2Nonce]) {
+			records = append(records, &r)
+		},
+	)
+	a.PartialSignature.WhenSome(
+		func(r tlv.RecordT[tlv.TlvType5, PartialSig]) {
+			records = append(records, &r)
+		},
+	)
+
+	return encodeTLVStream(w, a.ExtraOpaqueData, records...)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (a *AnnounceSignatures2) MsgType() MessageType {
+	return MsgAnnounceSignatures2
+}
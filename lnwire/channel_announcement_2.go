@@ -0,0 +1,175 @@
+package lnwire
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// ChannelAnnouncement2 is the message used to announce the existence of a
+// taproot channel between two peers in the overlay. Unlike the original
+// ChannelAnnouncement, all fields besides the signature are encoded as a single
+// TLV stream, and the announcement is authenticated by a single Schnorr
+// signature that is the MuSig2 aggregate of the node and bitcoin keys of both
+// peers.
+type ChannelAnnouncement2 struct {
+	// Signature is the MuSig2 aggregate Schnorr signature of the node and
+	// bitcoin keys of both peers over the tagged hash of the TLV stream of
+	// the message.
+	Signature Sig
+
+	// ChainHash denotes the target chain that this channel was opened
+	// within. This value should be the genesis hash of the target chain.
+	ChainHash tlv.RecordT[tlv.TlvType0, [32]byte]
+
+	// Features is the feature vector that encodes the features supported
+	// by the channel.
+	Features tlv.RecordT[tlv.TlvType2, FeatureVector]
+
+	// ShortChannelID is the unique description of the funding transaction,
+	// or where exactly it's located within the target blockchain.
+	ShortChannelID tlv.RecordT[tlv.TlvType4, ShortChannelID]
+
+	// Capacity is the number of satoshis of the funding output.
+	Capacity tlv.RecordT[tlv.TlvType6, uint64]
+
+	// NodeID1 is the public key of the node that is numerically-lesser
+	// than NodeID2.
+	NodeID1 tlv.RecordT[tlv.TlvType8, [33]byte]
+
+	// NodeID2 is the public key of the node that is numerically-greater
+	// than NodeID1.
+	NodeID2 tlv.RecordT[tlv.TlvType10, [33]byte]
+
+	// BitcoinKey1 is the funding key of the node with NodeID1.
+	BitcoinKey1 tlv.OptionalRecordT[tlv.TlvType12, [33]byte]
+
+	// BitcoinKey2 is the funding key of the node with NodeID2.
+	BitcoinKey2 tlv.OptionalRecordT[tlv.TlvType14, [33]byte]
+
+	// MerkleRootHash is the optional tapscript root that the internal
+	// funding key is tweaked with.
+	MerkleRootHash tlv.OptionalRecordT[tlv.TlvType16, [32]byte]
+
+	// ExtraOpaqueData holds the records of the TLV stream of the message
+	// that we don't know of. By holding onto them, we ensure that we're
+	// able to properly validate the signature that covers them.
+	ExtraOpaqueData ExtraOpaqueData
+}
+
+// A compile time check to ensure ChannelAnnouncement2 implements the
+// lnwire.Message interface.
+var _ Message = (*ChannelAnnouncement2)(nil)
+
+// Decode deserializes a serialized ChannelAnnouncement2 stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (c *ChannelAnnouncement2) Decode(r io.Reader, _ uint32) error {
+	if err := ReadElement(r, &c.Signature); err != nil {
+		return err
+	}
+	c.Signature.ForceSchnorr()
+
+	var tlvRecords ExtraOpaqueData
+	if err := ReadElements(r, &tlvRecords); err != nil {
+		return err
+	}
+
+	// The features record may be omitted if no features are set, so we
+	// start out with an empty vector.
+	c.Features.Val = *NewFeatureVector(nil, Features)
+
+	var (
+		btcKey1    = c.BitcoinKey1.Zero()
+		btcKey2    = c.BitcoinKey2.Zero()
+		merkleRoot = c.MerkleRootHash.Zero()
+	)
+	typeMap, err := tlvRecords.ExtractRecords(
+		&c.ChainHash, &c.Features, &c.ShortChannelID, &c.Capacity,
+		&c.NodeID1, &c.NodeID2, &btcKey1, &btcKey2, &merkleRoot,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := typeMap[c.BitcoinKey1.TlvType()]; ok {
+		c.BitcoinKey1 = tlv.SomeRecordT(btcKey1)
+	}
+	if _, ok := typeMap[c.BitcoinKey2.TlvType()]; ok {
+		c.BitcoinKey2 = tlv.SomeRecordT(btcKey2)
+	}
+	if _, ok := typeMap[c.MerkleRootHash.TlvType()]; ok {
+		c.MerkleRootHash = tlv.SomeRecordT(merkleRoot)
+	}
+
+	c.ExtraOpaqueData, err = unknownRecordsFromTypeMap(typeMap)
+
+	return err
+}
+
+// Encode serializes the target ChannelAnnouncement2 into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (c *ChannelAnnouncement2) Encode(w *bytes.Buffer, _ uint32) error {
+	if err := WriteSig(w, c.Signature); err != nil {
+		return err
+	}
+
+	return c.encodeTLVStream(w)
+}
+
+// encodeTLVStream writes the TLV stream of the message, which holds all of its
+// fields besides the signature, to the passed buffer.
+func (c *ChannelAnnouncement2) encodeTLVStream(w *bytes.Buffer) error {
+	records := []tlv.RecordProducer{
+		&c.ChainHash, &c.Features, &c.ShortChannelID, &c.Capacity,
+		&c.NodeID1, &c.NodeID2,
+	}
+	c.BitcoinKey1.WhenSome(func(r tlv.RecordT[tlv.TlvType12, [33]byte]) {
+		records = append(records, &r)
+	})
+	c.BitcoinKey2.WhenSome(func(r tlv.RecordT[tlv.TlvType14, [33]byte]) {
+		records = append(records, &r)
+	})
+	c.MerkleRootHash.WhenSome(func(r tlv.RecordT[tlv.TlvType16, [32]byte]) {
+		records = append(records, &r)
+	})
+
+	return encodeTLVStream(w, c.ExtraOpaqueData, records...)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (c *ChannelAnnouncement2) MsgType() MessageType {
+	return MsgChannelAnnouncement2
+}
+
+// DataToSign returns the part of the message that is covered by the
+// signature, which is the complete TLV stream of the message.
+func (c *ChannelAnnouncement2) DataToSign() ([]byte, error) {
+	var w bytes.Buffer
+	if err := c.encodeTLVStream(&w); err != nil {
+		return nil, err
+	}
+
+	return w.Bytes(), nil
+}
+
+// DigestToSign computes the tagged hash of the message that is signed by the
+// MuSig2 aggregate key of the channel.
+func (c *ChannelAnnouncement2) DigestToSign() (*chainhash.Hash, error) {
+	data, err := c.DataToSign()
+	if err != nil {
+		return nil, err
+	}
+
+	return MsgHash(
+		channelAnnouncement2MsgName, signatureFieldName, data,
+	), nil
+}
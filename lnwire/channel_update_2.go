@@ -0,0 +1,239 @@
+package lnwire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// ChanUpdateDisableFlags is a bitfield that signals in which directions a
+// channel edge announced with a ChannelUpdate2 is disabled.
+type ChanUpdateDisableFlags uint8
+
+const (
+	// ChanUpdateDisableIncoming is a bit that indicates that the sender of
+	// the update will not accept HTLCs that are forwarded to it over the
+	// channel.
+	ChanUpdateDisableIncoming ChanUpdateDisableFlags = 1 << iota
+
+	// ChanUpdateDisableOutgoing is a bit that indicates that the sender of
+	// the update will not forward HTLCs over the channel.
+	ChanUpdateDisableOutgoing
+)
+
+// IsEnabled returns true if the channel edge isn't disabled in either
+// direction.
+func (c ChanUpdateDisableFlags) IsEnabled() bool {
+	return c == 0
+}
+
+// IncomingDisabled returns true if the incoming direction of the channel edge
+// is disabled.
+func (c ChanUpdateDisableFlags) IncomingDisabled() bool {
+	return c&ChanUpdateDisableIncoming == ChanUpdateDisableIncoming
+}
+
+// OutgoingDisabled returns true if the outgoing direction of the channel edge
+// is disabled.
+func (c ChanUpdateDisableFlags) OutgoingDisabled() bool {
+	return c&ChanUpdateDisableOutgoing == ChanUpdateDisableOutgoing
+}
+
+// String returns the bitfield flags as a string.
+func (c ChanUpdateDisableFlags) String() string {
+	return fmt.Sprintf("%08b", uint8(c))
+}
+
+// Record returns a TLV record that can be used to encode/decode the disable
+// flags. The type of the record is expected to be set by the generic
+// tlv.RecordT wrapper.
+func (c *ChanUpdateDisableFlags) Record() tlv.Record {
+	return tlv.MakeStaticRecord(
+		0, c, 1, eDisableFlags, dDisableFlags,
+	)
+}
+
+// eDisableFlags is a TLV encoder for the disable flags.
+func eDisableFlags(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*ChanUpdateDisableFlags); ok {
+		return tlv.EUint8T(w, uint8(*v), buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.ChanUpdateDisableFlags")
+}
+
+// dDisableFlags is a TLV decoder for the disable flags.
+func dDisableFlags(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*ChanUpdateDisableFlags); ok && l == 1 {
+		var flags uint8
+		if err := tlv.DUint8(r, &flags, buf, l); err != nil {
+			return err
+		}
+
+		*v = ChanUpdateDisableFlags(flags)
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(
+		val, "lnwire.ChanUpdateDisableFlags", l, 1,
+	)
+}
+
+// ChannelUpdate2 is the message of the taproot gossip protocol that is used
+// to announce the routing policy of one of the directions of a channel that
+// was announced with a ChannelAnnouncement2. Updates are ordered by the block
+// height they are created at instead of a timestamp, and are authenticated by
+// a Schnorr signature of the node key of the sender.
+type ChannelUpdate2 struct {
+	// Signature is the Schnorr signature of the node key of the sender over
+	// the tagged hash of the TLV stream of the message.
+	Signature Sig
+
+	// ChainHash denotes the target chain that this channel was opened
+	// within. This value should be the genesis hash of the target chain.
+	ChainHash tlv.RecordT[tlv.TlvType0, [32]byte]
+
+	// ShortChannelID is the unique description of the funding transaction.
+	ShortChannelID tlv.RecordT[tlv.TlvType2, ShortChannelID]
+
+	// BlockHeight allows ordering in the case of multiple updates. We
+	// should ignore the message if the block height is not greater than
+	// the one of the last-received update.
+	BlockHeight tlv.RecordT[tlv.TlvType4, uint32]
+
+	// DisabledFlags signals in which directions the channel edge is
+	// disabled.
+	DisabledFlags tlv.RecordT[tlv.TlvType6, ChanUpdateDisableFlags]
+
+	// SecondPeer is true if the update was created by the node with
+	// NodeID2 of the channel announcement, and false otherwise.
+	SecondPeer tlv.RecordT[tlv.TlvType8, bool]
+
+	// CLTVExpiryDelta is the minimum number of blocks this node requires
+	// to be added to the expiry of HTLCs.
+	CLTVExpiryDelta tlv.RecordT[tlv.TlvType10, uint16]
+
+	// HTLCMinimumMsat is the minimum HTLC value which will be accepted.
+	HTLCMinimumMsat tlv.RecordT[tlv.TlvType12, MilliSatoshi]
+
+	// HTLCMaximumMsat is the maximum HTLC value which will be accepted.
+	HTLCMaximumMsat tlv.RecordT[tlv.TlvType14, MilliSatoshi]
+
+	// FeeBaseMsat is the base fee that must be used for incoming HTLCs to
+	// this particular channel.
+	FeeBaseMsat tlv.RecordT[tlv.TlvType16, uint32]
+
+	// FeeProportionalMillionths is the fee rate that will be charged per
+	// millionth of a satoshi.
+	FeeProportionalMillionths tlv.RecordT[tlv.TlvType18, uint32]
+
+	// ExtraOpaqueData holds the records of the TLV stream of the message
+	// that we don't know of. By holding onto them, we ensure that we're
+	// able to properly validate the signature that covers them.
+	ExtraOpaqueData ExtraOpaqueData
+}
+
+// A compile time check to ensure ChannelUpdate2 implements the lnwire.Message
+// interface.
+var _ Message = (*ChannelUpdate2)(nil)
+
+// Decode deserializes a serialized ChannelUpdate2 stored in the passed
+// io.Reader observing the specified protocol version.
+//
+// This is part of the lnwire.Message interface.
+func (c *ChannelUpdate2) Decode(r io.Reader, _ uint32) error {
+	if err := ReadElement(r, &c.Signature); err != nil {
+		return err
+	}
+	c.Signature.ForceSchnorr()
+
+	var tlvRecords ExtraOpaqueData
+	if err := ReadElements(r, &tlvRecords); err != nil {
+		return err
+	}
+
+	typeMap, err := tlvRecords.ExtractRecords(c.records()...)
+	if err != nil {
+		return err
+	}
+
+	c.ExtraOpaqueData, err = unknownRecordsFromTypeMap(typeMap)
+
+	return err
+}
+
+// records returns the known records of the message.
+func (c *ChannelUpdate2) records() []tlv.RecordProducer {
+	return []tlv.RecordProducer{
+		&c.ChainHash, &c.ShortChannelID, &c.BlockHeight,
+		&c.DisabledFlags, &c.SecondPeer, &c.CLTVExpiryDelta,
+		&c.HTLCMinimumMsat, &c.HTLCMaximumMsat, &c.FeeBaseMsat,
+		&c.FeeProportionalMillionths,
+	}
+}
+
+// Encode serializes the target ChannelUpdate2 into the passed io.Writer
+// observing the protocol version specified.
+//
+// This is part of the lnwire.Message interface.
+func (c *ChannelUpdate2) Encode(w *bytes.Buffer, _ uint32) error {
+	if err := WriteSig(w, c.Signature); err != nil {
+		return err
+	}
+
+	return encodeTLVStream(w, c.ExtraOpaqueData, c.records()...)
+}
+
+// MsgType returns the integer uniquely identifying this message type on the
+// wire.
+//
+// This is part of the lnwire.Message interface.
+func (c *ChannelUpdate2) MsgType() MessageType {
+	return MsgChannelUpdate2
+}
+
+// DataToSign returns the part of the message that is covered by the
+// signature, which is the complete TLV stream of the message.
+func (c *ChannelUpdate2) DataToSign() ([]byte, error) {
+	var w bytes.Buffer
+	err := encodeTLVStream(&w, c.ExtraOpaqueData, c.records()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.Bytes(), nil
+}
+
+// DigestToSign computes the tagged hash of the message that is signed by the
+// node key of the sender.
+func (c *ChannelUpdate2) DigestToSign() (*chainhash.Hash, error) {
+	data, err := c.DataToSign()
+	if err != nil {
+		return nil, err
+	}
+
+	return MsgHash(channelUpdate2MsgName, signatureFieldName, data), nil
+}
+
+// SignatureTag returns the tag of the tagged hash that is signed by the sender
+// of the update.
+func (c *ChannelUpdate2) SignatureTag() []byte {
+	return MsgHashTag(channelUpdate2MsgName, signatureFieldName)
+}
+
+// Direction returns the channel update flags of the ChannelUpdate that
+// describes the same direction of the channel, which is used to identify the
+// edge the update applies to.
+func (c *ChannelUpdate2) Direction() ChanUpdateChanFlags {
+	if c.SecondPeer.Val {
+		return ChanUpdateDirection
+	}
+
+	return 0
+}
@@ -120,3 +120,67 @@ func EncodeMessageExtraData(extraData *ExtraOpaqueData,
 	// are all properly sorted.
 	return extraData.PackRecords(recordProducers...)
 }
+
+// rawRecordProducer is a tlv.RecordProducer for a record whose value we only
+// know in its raw, encoded form.
+type rawRecordProducer struct {
+	record tlv.Record
+}
+
+// Record returns the underlying raw record.
+//
+// NOTE: This is part of the tlv.RecordProducer interface.
+func (r *rawRecordProducer) Record() tlv.Record {
+	return r.record
+}
+
+// rawRecordProducers returns a record producer for each of the records of the
+// type map that weren't parsed into a known record. Those records have a nil
+// value in the type map.
+func rawRecordProducers(typeMap tlv.TypeMap) []tlv.RecordProducer {
+	var producers []tlv.RecordProducer
+	for recordType, value := range typeMap {
+		if value == nil {
+			continue
+		}
+
+		value := value
+		producers = append(producers, &rawRecordProducer{
+			record: tlv.MakePrimitiveRecord(recordType, &value),
+		})
+	}
+
+	return producers
+}
+
+// RecordProducers parses the raw bytes as a TLV stream and returns a record
+// producer for each of the records found within it. This allows records that
+// we don't know of to be re-encoded alongside the known records of a message
+// that is made up of a single TLV stream.
+func (e *ExtraOpaqueData) RecordProducers() ([]tlv.RecordProducer, error) {
+	typeMap, err := e.ExtractRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	return rawRecordProducers(typeMap), nil
+}
+
+// unknownRecordsFromTypeMap packs all records of the given type map that
+// weren't parsed into a known record into a new ExtraOpaqueData instance. If
+// there are no such records, nil is returned.
+func unknownRecordsFromTypeMap(typeMap tlv.TypeMap) (ExtraOpaqueData,
+	error) {
+
+	producers := rawRecordProducers(typeMap)
+	if len(producers) == 0 {
+		return nil, nil
+	}
+
+	var extraData ExtraOpaqueData
+	if err := extraData.PackRecords(producers...); err != nil {
+		return nil, err
+	}
+
+	return extraData, nil
+}
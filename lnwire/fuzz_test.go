@@ -114,6 +114,17 @@ func FuzzAnnounceSignatures(f *testing.F) {
 	})
 }
 
+func FuzzAnnounceSignatures2(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Prefix with MsgAnnounceSignatures2.
+		data = prefixWithMsgType(data, MsgAnnounceSignatures2)
+
+		// Pass the message into our general fuzz harness for wire
+		// messages!
+		harness(t, data)
+	})
+}
+
 func FuzzChannelAnnouncement(f *testing.F) {
 	f.Fuzz(func(t *testing.T, data []byte) {
 		// Prefix with MsgChannelAnnouncement.
@@ -136,6 +147,17 @@ func FuzzChannelReestablish(f *testing.F) {
 	})
 }
 
+func FuzzChannelAnnouncement2(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Prefix with MsgChannelAnnouncement2.
+		data = prefixWithMsgType(data, MsgChannelAnnouncement2)
+
+		// Pass the message into our general fuzz harness for wire
+		// messages!
+		harness(t, data)
+	})
+}
+
 func FuzzChannelUpdate(f *testing.F) {
 	f.Fuzz(func(t *testing.T, data []byte) {
 		// Prefix with MsgChannelUpdate.
@@ -147,6 +169,17 @@ func FuzzChannelUpdate(f *testing.F) {
 	})
 }
 
+func FuzzChannelUpdate2(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Prefix with MsgChannelUpdate2.
+		data = prefixWithMsgType(data, MsgChannelUpdate2)
+
+		// Pass the message into our general fuzz harness for wire
+		// messages!
+		harness(t, data)
+	})
+}
+
 func FuzzClosingSigned(f *testing.F) {
 	f.Fuzz(func(t *testing.T, data []byte) {
 		// Prefix with MsgClosingSigned.
@@ -0,0 +1,87 @@
+package lnwire
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/tlv"
+)
+
+// GossipVersion is the version of the gossip protocol a channel was announced
+// with.
+type GossipVersion uint8
+
+const (
+	// GossipVersion1 is the original gossip protocol, which announces
+	// P2WSH channels that are authenticated by four ECDSA signatures.
+	GossipVersion1 GossipVersion = iota
+
+	// GossipVersion2 is the taproot gossip protocol, which announces P2TR
+	// channels that are authenticated by a single MuSig2 Schnorr
+	// signature of the node and bitcoin keys of both channel peers.
+	GossipVersion2
+)
+
+// String returns a human readable representation of the gossip version.
+func (v GossipVersion) String() string {
+	switch v {
+	case GossipVersion1:
+		return "v1"
+
+	case GossipVersion2:
+		return "v2"
+
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// msgHashTag is the tag prefix of all the tagged hashes that are signed
+	// by the messages of the taproot gossip protocol.
+	msgHashTag = "lightning"
+
+	// signatureFieldName is the name of the signature field of the
+	// messages of the taproot gossip protocol.
+	signatureFieldName = "signature"
+
+	// channelAnnouncement2MsgName is the name of the ChannelAnnouncement2
+	// message as used in the tag of the hash signed by the announcement.
+	channelAnnouncement2MsgName = "channel_announcement_2"
+
+	// channelUpdate2MsgName is the name of the ChannelUpdate2 message as
+	// used in the tag of the hash signed by the update.
+	channelUpdate2MsgName = "channel_update_2"
+)
+
+// MsgHash computes the BIP-340 tagged hash of the given message, using
+// "lightning"||messageName||fieldName as the tag. This is the digest that is
+// signed by the Schnorr signatures of the taproot gossip messages.
+func MsgHash(msgName, fieldName string, msg []byte) *chainhash.Hash {
+	return chainhash.TaggedHash(MsgHashTag(msgName, fieldName), msg)
+}
+
+// MsgHashTag returns the tag used to compute the tagged hash of the field of
+// the given message.
+func MsgHashTag(msgName, fieldName string) []byte {
+	return []byte(msgHashTag + msgName + fieldName)
+}
+
+// encodeTLVStream encodes the given records along with the unknown records of
+// the passed extra data as a single, canonically ordered TLV stream.
+func encodeTLVStream(w *bytes.Buffer, extraData ExtraOpaqueData,
+	records ...tlv.RecordProducer) error {
+
+	producers, err := extraData.RecordProducers()
+	if err != nil {
+		return err
+	}
+	producers = append(producers, records...)
+
+	var tlvStream ExtraOpaqueData
+	if err := EncodeMessageExtraData(&tlvStream, producers...); err != nil {
+		return err
+	}
+
+	return WriteBytes(w, tlvStream)
+}
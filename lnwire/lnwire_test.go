@@ -116,6 +116,38 @@ func somePartialSigWithNonce(t *testing.T,
 	)
 }
 
+// randSchnorrSig returns a random 64-byte signature of the schnorr type.
+func randSchnorrSig(t *testing.T, r *rand.Rand) Sig {
+	var sigBytes [64]byte
+	_, err := r.Read(sigBytes[:])
+	require.NoError(t, err)
+
+	sig, err := NewSigFromSchnorrRawSignature(sigBytes[:])
+	require.NoError(t, err)
+
+	return sig
+}
+
+// randUnknownRecords returns, half of the time, a TLV stream holding an odd
+// record that is unknown to the messages of the taproot gossip protocol.
+func randUnknownRecords(t *testing.T, r *rand.Rand) ExtraOpaqueData {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+
+	value := make([]byte, r.Intn(64))
+	_, err := r.Read(value)
+	require.NoError(t, err)
+
+	var extraData ExtraOpaqueData
+	err = extraData.PackRecords(&rawRecordProducer{
+		record: tlv.MakePrimitiveRecord(tlv.Type(1001), &value),
+	})
+	require.NoError(t, err)
+
+	return extraData
+}
+
 func randAlias(r *rand.Rand) NodeAlias {
 	var a NodeAlias
 	for i := range a {
@@ -1104,6 +1136,112 @@ func TestLightningWireProtocol(t *testing.T) {
 
 			v[0] = reflect.ValueOf(req)
 		},
+		MsgChannelAnnouncement2: func(v []reflect.Value, r *rand.Rand) {
+			req := ChannelAnnouncement2{
+				Signature:       randSchnorrSig(t, r),
+				ExtraOpaqueData: randUnknownRecords(t, r),
+			}
+
+			_, err := r.Read(req.ChainHash.Val[:])
+			require.NoError(t, err)
+
+			req.Features.Val = *NewFeatureVector(
+				randRawFeatureVector(r), Features,
+			)
+			req.ShortChannelID.Val = NewShortChanIDFromInt(
+				uint64(r.Int63()),
+			)
+			req.Capacity.Val = uint64(r.Int63())
+
+			req.NodeID1.Val, err = randRawKey()
+			require.NoError(t, err)
+			req.NodeID2.Val, err = randRawKey()
+			require.NoError(t, err)
+
+			if r.Intn(2) == 0 {
+				key1, err := randRawKey()
+				require.NoError(t, err)
+				key2, err := randRawKey()
+				require.NoError(t, err)
+
+				req.BitcoinKey1 = tlv.SomeRecordT(
+					tlv.NewPrimitiveRecord[tlv.TlvType12](
+						key1,
+					),
+				)
+				req.BitcoinKey2 = tlv.SomeRecordT(
+					tlv.NewPrimitiveRecord[tlv.TlvType14](
+						key2,
+					),
+				)
+			}
+
+			if r.Intn(2) == 0 {
+				var root [32]byte
+				_, err := r.Read(root[:])
+				require.NoError(t, err)
+
+				req.MerkleRootHash = tlv.SomeRecordT(
+					tlv.NewPrimitiveRecord[tlv.TlvType16](
+						root,
+					),
+				)
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgChannelUpdate2: func(v []reflect.Value, r *rand.Rand) {
+			req := ChannelUpdate2{
+				Signature:       randSchnorrSig(t, r),
+				ExtraOpaqueData: randUnknownRecords(t, r),
+			}
+
+			_, err := r.Read(req.ChainHash.Val[:])
+			require.NoError(t, err)
+
+			req.ShortChannelID.Val = NewShortChanIDFromInt(
+				uint64(r.Int63()),
+			)
+			req.BlockHeight.Val = r.Uint32()
+			req.DisabledFlags.Val = ChanUpdateDisableFlags(
+				r.Intn(4),
+			)
+			req.SecondPeer.Val = r.Intn(2) == 0
+			req.CLTVExpiryDelta.Val = uint16(r.Int31())
+			req.HTLCMinimumMsat.Val = MilliSatoshi(r.Int63())
+			req.HTLCMaximumMsat.Val = MilliSatoshi(r.Int63())
+			req.FeeBaseMsat.Val = r.Uint32()
+			req.FeeProportionalMillionths.Val = r.Uint32()
+
+			v[0] = reflect.ValueOf(req)
+		},
+		MsgAnnounceSignatures2: func(v []reflect.Value, r *rand.Rand) {
+			req := AnnounceSignatures2{
+				ShortChannelID: NewShortChanIDFromInt(
+					uint64(r.Int63()),
+				),
+				ExtraOpaqueData: randUnknownRecords(t, r),
+			}
+
+			_, err := r.Read(req.ChannelID[:])
+			require.NoError(t, err)
+
+			if r.Intn(2) == 0 {
+				req.NodeNonce = someLocalNonce[tlv.TlvType1](r)
+				req.BitcoinNonce = someLocalNonce[tlv.TlvType3](
+					r,
+				)
+			} else {
+				sig, err := randPartialSig(r)
+				require.NoError(t, err)
+
+				req.PartialSignature = tlv.SomeRecordT(
+					tlv.NewRecordT[tlv.TlvType5](*sig),
+				)
+			}
+
+			v[0] = reflect.ValueOf(req)
+		},
 		MsgChannelReestablish: func(v []reflect.Value, r *rand.Rand) {
 			req := ChannelReestablish{
 				NextLocalCommitHeight:  uint64(r.Int63()),
@@ -1553,6 +1691,24 @@ func TestLightningWireProtocol(t *testing.T) {
 				return mainScenario(&m)
 			},
 		},
+		{
+			msgType: MsgChannelAnnouncement2,
+			scenario: func(m ChannelAnnouncement2) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgChannelUpdate2,
+			scenario: func(m ChannelUpdate2) bool {
+				return mainScenario(&m)
+			},
+		},
+		{
+			msgType: MsgAnnounceSignatures2,
+			scenario: func(m AnnounceSignatures2) bool {
+				return mainScenario(&m)
+			},
+		},
 		{
 			msgType: MsgGossipTimestampRange,
 			scenario: func(m GossipTimestampRange) bool {
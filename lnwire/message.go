@@ -51,11 +51,14 @@ const (
 	MsgNodeAnnouncement                    = 257
 	MsgChannelUpdate                       = 258
 	MsgAnnounceSignatures                  = 259
+	MsgAnnounceSignatures2                 = 260
 	MsgQueryShortChanIDs                   = 261
 	MsgReplyShortChanIDsEnd                = 262
 	MsgQueryChannelRange                   = 263
 	MsgReplyChannelRange                   = 264
 	MsgGossipTimestampRange                = 265
+	MsgChannelAnnouncement2                = 267
+	MsgChannelUpdate2                      = 271
 	MsgKickoffSig                          = 777
 )
 
@@ -134,6 +137,12 @@ func (t MessageType) String() string {
 		return "Ping"
 	case MsgAnnounceSignatures:
 		return "AnnounceSignatures"
+	case MsgAnnounceSignatures2:
+		return "AnnounceSignatures2"
+	case MsgChannelAnnouncement2:
+		return "ChannelAnnouncement2"
+	case MsgChannelUpdate2:
+		return "ChannelUpdate2"
 	case MsgPong:
 		return "Pong"
 	case MsgUpdateFee:
@@ -263,6 +272,12 @@ func makeEmptyMessage(msgType MessageType) (Message, error) {
 		msg = &Ping{}
 	case MsgAnnounceSignatures:
 		msg = &AnnounceSignatures{}
+	case MsgAnnounceSignatures2:
+		msg = &AnnounceSignatures2{}
+	case MsgChannelAnnouncement2:
+		msg = &ChannelAnnouncement2{}
+	case MsgChannelUpdate2:
+		msg = &ChannelUpdate2{}
 	case MsgPong:
 		msg = &Pong{}
 	case MsgQueryShortChanIDs:
@@ -2,8 +2,10 @@ package lnwire
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/tlv"
 )
 
 const (
@@ -48,4 +50,38 @@ func (m MilliSatoshi) String() string {
 	return fmt.Sprintf("%v mSAT", uint64(m))
 }
 
+// Record returns a TLV record that can be used to encode/decode a MilliSatoshi
+// amount to/from a TLV stream. The type of the record is expected to be set by
+// the generic tlv.RecordT wrapper.
+func (m *MilliSatoshi) Record() tlv.Record {
+	return tlv.MakeStaticRecord(0, m, 8, eMilliSatoshi, dMilliSatoshi)
+}
+
+// eMilliSatoshi is a TLV encoder for a MilliSatoshi amount.
+func eMilliSatoshi(w io.Writer, val interface{}, buf *[8]byte) error {
+	if v, ok := val.(*MilliSatoshi); ok {
+		return tlv.EUint64T(w, uint64(*v), buf)
+	}
+
+	return tlv.NewTypeForEncodingErr(val, "lnwire.MilliSatoshi")
+}
+
+// dMilliSatoshi is a TLV decoder for a MilliSatoshi amount.
+func dMilliSatoshi(r io.Reader, val interface{}, buf *[8]byte,
+	l uint64) error {
+
+	if v, ok := val.(*MilliSatoshi); ok && l == 8 {
+		var amt uint64
+		if err := tlv.DUint64(r, &amt, buf, l); err != nil {
+			return err
+		}
+
+		*v = MilliSatoshi(amt)
+
+		return nil
+	}
+
+	return tlv.NewTypeForDecodingErr(val, "lnwire.MilliSatoshi", l, 8)
+}
+
 // TODO(roasbeef): extend with arithmetic operations?
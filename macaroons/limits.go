@@ -0,0 +1,685 @@
+package macaroons
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/fn"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"gopkg.in/macaroon-bakery.v2/bakery/checkers"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+const (
+	// CondMaxPayment is the first party caveat condition that limits the
+	// amount of a single payment, including the maximum routing fee, to
+	// the given number of satoshis.
+	CondMaxPayment = "lnd-max-payment"
+
+	// CondSpendLimit is the first party caveat condition that limits the
+	// total amount of all payments within a rolling time window. The
+	// condition is encoded as "<satoshis> <window-seconds>".
+	CondSpendLimit = "lnd-spend-limit"
+
+	// CondDestinations is the first party caveat condition that restricts
+	// payments to a comma separated list of hex encoded destination node
+	// public keys.
+	CondDestinations = "lnd-destinations"
+
+	// CondChannels is the first party caveat condition that restricts the
+	// channels that can be closed or have their policy updated to a comma
+	// separated list of channel points.
+	CondChannels = "lnd-channels"
+
+	// CondMaxInvoice is the first party caveat condition that limits the
+	// amount of invoices to the given number of satoshis.
+	CondMaxInvoice = "lnd-max-invoice"
+
+	// CondRateLimit is the first party caveat condition that limits the
+	// rate of calls of each RPC method. The condition is encoded as
+	// "<calls-per-second> <burst>".
+	CondRateLimit = "lnd-rate-limit"
+
+	// CondMaxStreams is the first party caveat condition that limits the
+	// number of concurrent streams of each streaming RPC method.
+	CondMaxStreams = "lnd-max-streams"
+)
+
+var (
+	// ErrPaymentLimitExceeded is returned if a payment exceeds the
+	// maximum payment amount of a macaroon.
+	ErrPaymentLimitExceeded = errors.New("payment amount exceeds " +
+		"macaroon limit")
+
+	// ErrDestinationNotAllowed is returned if a payment is sent to a
+	// destination that isn't allowed by a macaroon.
+	ErrDestinationNotAllowed = errors.New("payment destination not " +
+		"allowed by macaroon")
+
+	// ErrChannelNotAllowed is returned if a channel operation is not
+	// allowed by a macaroon.
+	ErrChannelNotAllowed = errors.New("channel not allowed by macaroon")
+
+	// ErrInvoiceLimitExceeded is returned if an invoice exceeds the maximum
+	// invoice amount of a macaroon.
+	ErrInvoiceLimitExceeded = errors.New("invoice amount exceeds " +
+		"macaroon limit")
+)
+
+// SpendLimit limits the total amount of all payments made with a macaroon
+// within a rolling time window.
+type SpendLimit struct {
+	// Amount is the maximum amount that can be spent within the window.
+	Amount lnwire.MilliSatoshi
+
+	// Window is the duration of the rolling time window.
+	Window time.Duration
+
+	// ID identifies the spending window of the limit. It commits to the
+	// macaroon ID and all caveats up to and including the spend limit
+	// caveat, so the window is shared by all macaroons derived from the
+	// one the limit was added to.
+	ID [32]byte
+}
+
+// RateLimit is a token bucket limit of the calls of an RPC method.
+type RateLimit struct {
+	// Rate is the sustained number of calls per second.
+	Rate float64
+
+	// Burst is the number of calls that can be made at once.
+	Burst int
+
+	// ID identifies the token buckets of a rate limit caveat. Like the ID
+	// of a spending window, it commits to the macaroon ID and all caveats
+	// up to and including the rate limit caveat, so the buckets are shared
+	// by all macaroons derived from the one the limit was added to. It is
+	// zero for limits that don't come from a caveat.
+	ID [32]byte
+}
+
+// StreamLimit limits the number of concurrent streams of each streaming RPC
+// method.
+type StreamLimit struct {
+	// MaxStreams is the maximum number of concurrent streams.
+	MaxStreams uint32
+
+	// ID identifies the stream counters of the limit. It commits to the
+	// same data as the ID of a rate limit.
+	ID [32]byte
+}
+
+// Limits are the amount, destination and channel restrictions of a macaroon.
+// If a macaroon contains multiple caveats of the same kind, the most
+// restrictive combination of them applies.
+type Limits struct {
+	// MaxPayment is the maximum amount of a single payment.
+	MaxPayment fn.Option[lnwire.MilliSatoshi]
+
+	// SpendLimits are the spending windows all payments are counted
+	// against.
+	SpendLimits []SpendLimit
+
+	// Destinations is the set of nodes payments can be sent to.
+	Destinations fn.Option[map[[33]byte]struct{}]
+
+	// Channels is the set of channels that can be closed or updated.
+	Channels fn.Option[map[wire.OutPoint]struct{}]
+
+	// MaxInvoice is the maximum amount of an invoice.
+	MaxInvoice fn.Option[lnwire.MilliSatoshi]
+
+	// RateLimits are the rate limits of the calls of each RPC method.
+	RateLimits []RateLimit
+
+	// StreamLimits are the limits of the number of concurrent streams of
+	// each streaming RPC method.
+	StreamLimits []StreamLimit
+}
+
+// MaxPaymentConstraint limits the amount of a single payment, including the
+// maximum routing fee.
+func MaxPaymentConstraint(amt btcutil.Amount) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		caveat := checkers.Condition(CondMaxPayment, formatAmount(amt))
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// SpendLimitConstraint limits the total amount of all payments, including the
+// maximum routing fees, within a rolling time window.
+func SpendLimitConstraint(amt btcutil.Amount,
+	window time.Duration) func(*macaroon.Macaroon) error {
+
+	return func(mac *macaroon.Macaroon) error {
+		seconds := int64(window / time.Second)
+		if seconds <= 0 {
+			return fmt.Errorf("spending window must be at least " +
+				"one second")
+		}
+
+		caveat := checkers.Condition(
+			CondSpendLimit, fmt.Sprintf("%s %d",
+				formatAmount(amt), seconds),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// DestinationsConstraint restricts payments to the given destination nodes.
+func DestinationsConstraint(
+	pubKeys ...[33]byte) func(*macaroon.Macaroon) error {
+
+	return func(mac *macaroon.Macaroon) error {
+		if len(pubKeys) == 0 {
+			return fmt.Errorf("at least one destination is " +
+				"required")
+		}
+
+		encoded := make([]string, 0, len(pubKeys))
+		for _, pubKey := range pubKeys {
+			encoded = append(encoded, hex.EncodeToString(pubKey[:]))
+		}
+
+		caveat := checkers.Condition(
+			CondDestinations, strings.Join(encoded, ","),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// ChannelsConstraint restricts the channels that can be closed or have their
+// policy updated.
+func ChannelsConstraint(
+	chanPoints ...wire.OutPoint) func(*macaroon.Macaroon) error {
+
+	return func(mac *macaroon.Macaroon) error {
+		if len(chanPoints) == 0 {
+			return fmt.Errorf("at least one channel is required")
+		}
+
+		encoded := make([]string, 0, len(chanPoints))
+		for _, chanPoint := range chanPoints {
+			encoded = append(encoded, chanPoint.String())
+		}
+
+		caveat := checkers.Condition(
+			CondChannels, strings.Join(encoded, ","),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// MaxInvoiceConstraint limits the amount of invoices.
+func MaxInvoiceConstraint(amt btcutil.Amount) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		caveat := checkers.Condition(CondMaxInvoice, formatAmount(amt))
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// RateLimitConstraint limits the rate of calls of each RPC method.
+func RateLimitConstraint(limit RateLimit) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		if limit.Rate <= 0 || limit.Burst <= 0 {
+			return fmt.Errorf("rate and burst of a rate limit " +
+				"must be positive")
+		}
+
+		caveat := checkers.Condition(
+			CondRateLimit, fmt.Sprintf("%s %d",
+				strconv.FormatFloat(limit.Rate, 'f', -1, 64),
+				limit.Burst),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// MaxStreamsConstraint limits the number of concurrent streams of each
+// streaming RPC method.
+func MaxStreamsConstraint(maxStreams uint32) func(*macaroon.Macaroon) error {
+	return func(mac *macaroon.Macaroon) error {
+		caveat := checkers.Condition(
+			CondMaxStreams, strconv.FormatUint(
+				uint64(maxStreams), 10,
+			),
+		)
+		return mac.AddFirstPartyCaveat([]byte(caveat))
+	}
+}
+
+// LimitCheckers returns the checkers for all amount, destination and channel
+// caveats. The checkers only make sure the caveats are well formed, as they
+// can only be enforced by the RPC calls they apply to.
+func LimitCheckers() []Checker {
+	checker := func(name string, parse func(string) error) Checker {
+		return func() (string, checkers.Func) {
+			return name, func(_ context.Context, _,
+				arg string) error {
+
+				return parse(arg)
+			}
+		}
+	}
+
+	return []Checker{
+		checker(CondMaxPayment, func(arg string) error {
+			_, err := parseAmount(arg)
+			return err
+		}),
+		checker(CondSpendLimit, func(arg string) error {
+			_, _, err := parseSpendLimit(arg)
+			return err
+		}),
+		checker(CondDestinations, func(arg string) error {
+			_, err := parseDestinations(arg)
+			return err
+		}),
+		checker(CondChannels, func(arg string) error {
+			_, err := parseChannels(arg)
+			return err
+		}),
+		checker(CondMaxInvoice, func(arg string) error {
+			_, err := parseAmount(arg)
+			return err
+		}),
+		checker(CondRateLimit, func(arg string) error {
+			_, err := parseRateLimit(arg)
+			return err
+		}),
+		checker(CondMaxStreams, func(arg string) error {
+			_, err := parseMaxStreams(arg)
+			return err
+		}),
+	}
+}
+
+// ParseLimits extracts the amount, destination and channel restrictions from
+// the caveats of the given macaroon.
+func ParseLimits(mac *macaroon.Macaroon) (*Limits, error) {
+	limits := &Limits{}
+
+	// The IDs of spending windows, rate limits and stream limits commit
+	// to the macaroon ID and all caveats up to the one that adds the
+	// limit.
+	limitHash := sha256.New()
+	_, _ = limitHash.Write(mac.Id())
+
+	for _, caveat := range mac.Caveats() {
+		// Third party caveats can't contain any limits.
+		if len(caveat.VerificationId) > 0 {
+			continue
+		}
+
+		_, _ = limitHash.Write(caveat.Id)
+
+		cond, arg, err := checkers.ParseCaveat(string(caveat.Id))
+		if err != nil {
+			return nil, err
+		}
+
+		switch cond {
+		case CondMaxPayment:
+			amt, err := parseAmount(arg)
+			if err != nil {
+				return nil, err
+			}
+			limits.MaxPayment = minAmount(limits.MaxPayment, amt)
+
+		case CondSpendLimit:
+			amt, window, err := parseSpendLimit(arg)
+			if err != nil {
+				return nil, err
+			}
+
+			limit := SpendLimit{
+				Amount: amt,
+				Window: window,
+			}
+			copy(limit.ID[:], limitHash.Sum(nil))
+			limits.SpendLimits = append(limits.SpendLimits, limit)
+
+		case CondDestinations:
+			dests, err := parseDestinations(arg)
+			if err != nil {
+				return nil, err
+			}
+			limits.Destinations = intersect(
+				limits.Destinations, dests,
+			)
+
+		case CondChannels:
+			chans, err := parseChannels(arg)
+			if err != nil {
+				return nil, err
+			}
+			limits.Channels = intersect(limits.Channels, chans)
+
+		case CondMaxInvoice:
+			amt, err := parseAmount(arg)
+			if err != nil {
+				return nil, err
+			}
+			limits.MaxInvoice = minAmount(limits.MaxInvoice, amt)
+
+		case CondRateLimit:
+			limit, err := parseRateLimit(arg)
+			if err != nil {
+				return nil, err
+			}
+			copy(limit.ID[:], limitHash.Sum(nil))
+			limits.RateLimits = append(limits.RateLimits, limit)
+
+		case CondMaxStreams:
+			maxStreams, err := parseMaxStreams(arg)
+			if err != nil {
+				return nil, err
+			}
+			limit := StreamLimit{MaxStreams: maxStreams}
+			copy(limit.ID[:], limitHash.Sum(nil))
+			limits.StreamLimits = append(limits.StreamLimits, limit)
+		}
+	}
+
+	return limits, nil
+}
+
+// LimitsFromContext extracts the restrictions of the macaroon of an incoming
+// gRPC request. If the request doesn't contain a macaroon, no restrictions
+// apply.
+func LimitsFromContext(ctx context.Context) (*Limits, error) {
+	macHex, err := RawMacaroonFromContext(ctx)
+	if err != nil {
+		return &Limits{}, nil
+	}
+
+	macBytes, err := hex.DecodeString(macHex)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := &macaroon.Macaroon{}
+	if err := mac.UnmarshalBinary(macBytes); err != nil {
+		return nil, err
+	}
+
+	return ParseLimits(mac)
+}
+
+// CheckPayment checks a payment of the given amount, including the maximum
+// routing fee, to the given destination against the maximum payment amount
+// and the allowed destinations. The spending windows are not checked.
+func (l *Limits) CheckPayment(amt lnwire.MilliSatoshi, dest [33]byte) error {
+	maxAmt := l.MaxPayment.UnwrapOr(amt)
+	if amt > maxAmt {
+		return fmt.Errorf("%w: %v > %v", ErrPaymentLimitExceeded, amt,
+			maxAmt)
+	}
+
+	allowed := fn.MapOptionZ(
+		l.Destinations, func(dests map[[33]byte]struct{}) bool {
+			_, ok := dests[dest]
+			return ok
+		},
+	)
+	if l.Destinations.IsSome() && !allowed {
+		return fmt.Errorf("%w: %x", ErrDestinationNotAllowed, dest)
+	}
+
+	return nil
+}
+
+// CheckChannel checks whether the given channel can be closed or updated. A
+// nil channel point denotes an operation that affects all channels, which is
+// only allowed if there are no channel restrictions.
+func (l *Limits) CheckChannel(chanPoint *wire.OutPoint) error {
+	if l.Channels.IsNone() {
+		return nil
+	}
+
+	if chanPoint == nil {
+		return fmt.Errorf("%w: operation affects all channels",
+			ErrChannelNotAllowed)
+	}
+
+	allowed := fn.MapOptionZ(
+		l.Channels, func(chans map[wire.OutPoint]struct{}) bool {
+			_, ok := chans[*chanPoint]
+			return ok
+		},
+	)
+	if !allowed {
+		return fmt.Errorf("%w: %v", ErrChannelNotAllowed, chanPoint)
+	}
+
+	return nil
+}
+
+// CheckInvoice checks whether an invoice with the given amount can be created.
+// An amount of zero denotes an invoice without a fixed amount, which is only
+// allowed if there is no maximum invoice amount.
+func (l *Limits) CheckInvoice(amt lnwire.MilliSatoshi) error {
+	if l.MaxInvoice.IsNone() {
+		return nil
+	}
+
+	maxAmt := l.MaxInvoice.UnsafeFromSome()
+	if amt == 0 {
+		return fmt.Errorf("%w: invoice without an amount",
+			ErrInvoiceLimitExceeded)
+	}
+	if amt > maxAmt {
+		return fmt.Errorf("%w: %v > %v", ErrInvoiceLimitExceeded, amt,
+			maxAmt)
+	}
+
+	return nil
+}
+
+// formatAmount encodes an amount in satoshis for a caveat.
+func formatAmount(amt btcutil.Amount) string {
+	return strconv.FormatInt(int64(amt), 10)
+}
+
+// parseAmount decodes an amount in satoshis of a caveat.
+func parseAmount(arg string) (lnwire.MilliSatoshi, error) {
+	sat, err := strconv.ParseUint(arg, 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", arg, err)
+	}
+
+	return lnwire.NewMSatFromSatoshis(btcutil.Amount(sat)), nil
+}
+
+// parseSpendLimit decodes the amount and window of a spend limit caveat.
+func parseSpendLimit(arg string) (lnwire.MilliSatoshi, time.Duration, error) {
+	parts := strings.Split(arg, " ")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid spend limit %q", arg)
+	}
+
+	amt, err := parseAmount(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+
+	seconds, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil || seconds == 0 {
+		return 0, 0, fmt.Errorf("invalid spending window %q",
+			parts[1])
+	}
+
+	return amt, time.Duration(seconds) * time.Second, nil
+}
+
+// parseDestinations decodes the node public keys of a destinations caveat.
+func parseDestinations(arg string) (map[[33]byte]struct{}, error) {
+	dests := make(map[[33]byte]struct{})
+	for _, encoded := range strings.Split(arg, ",") {
+		pubKey, err := hex.DecodeString(encoded)
+		if err != nil || len(pubKey) != 33 {
+			return nil, fmt.Errorf("invalid destination %q",
+				encoded)
+		}
+
+		var dest [33]byte
+		copy(dest[:], pubKey)
+		dests[dest] = struct{}{}
+	}
+
+	return dests, nil
+}
+
+// parseChannels decodes the channel points of a channels caveat.
+func parseChannels(arg string) (map[wire.OutPoint]struct{}, error) {
+	chans := make(map[wire.OutPoint]struct{})
+	for _, encoded := range strings.Split(arg, ",") {
+		chanPoint, err := wire.NewOutPointFromString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid channel point %q: %w",
+				encoded, err)
+		}
+
+		chans[*chanPoint] = struct{}{}
+	}
+
+	return chans, nil
+}
+
+// parseRateLimit decodes the rate and burst of a rate limit caveat.
+func parseRateLimit(arg string) (RateLimit, error) {
+	parts := strings.Split(arg, " ")
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q", arg)
+	}
+
+	rate, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate %q", parts[0])
+	}
+
+	burst, err := strconv.ParseUint(parts[1], 10, 31)
+	if err != nil || burst == 0 {
+		return RateLimit{}, fmt.Errorf("invalid burst %q", parts[1])
+	}
+
+	return RateLimit{Rate: rate, Burst: int(burst)}, nil
+}
+
+// parseMaxStreams decodes the number of streams of a max streams caveat.
+func parseMaxStreams(arg string) (uint32, error) {
+	maxStreams, err := strconv.ParseUint(arg, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max streams %q: %w", arg, err)
+	}
+
+	return uint32(maxStreams), nil
+}
+
+// minAmount returns the smaller of the current limit, if any, and amt.
+func minAmount(current fn.Option[lnwire.MilliSatoshi],
+	amt lnwire.MilliSatoshi) fn.Option[lnwire.MilliSatoshi] {
+
+	if current.UnwrapOr(amt) < amt {
+		return current
+	}
+
+	return fn.Some(amt)
+}
+
+// intersect returns the intersection of the current set, if any, and set.
+func intersect[K comparable](current fn.Option[map[K]struct{}],
+	set map[K]struct{}) fn.Option[map[K]struct{}] {
+
+	if current.IsNone() {
+		return fn.Some(set)
+	}
+
+	result := make(map[K]struct{})
+	for k := range current.UnsafeFromSome() {
+		if _, ok := set[k]; ok {
+			result[k] = struct{}{}
+		}
+	}
+
+	return fn.Some(result)
+}
+
+// AuthorizePayment checks a payment of the given amount, including the
+// maximum routing fee, to the given destination against the restrictions of
+// the macaroon of the request and records it in the spending windows of the
+// macaroon. The returned function must be called if the payment failed, so it
+// no longer counts against the spending windows. A nil service doesn't
+// restrict any payments.
+func (svc *Service) AuthorizePayment(ctx context.Context,
+	amt lnwire.MilliSatoshi, dest [33]byte) (func() error, error) {
+
+	noop := func() error { return nil }
+	if svc == nil {
+		return noop, nil
+	}
+
+	limits, err := LimitsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limits.CheckPayment(amt, dest); err != nil {
+		return nil, err
+	}
+
+	if len(limits.SpendLimits) == 0 {
+		return noop, nil
+	}
+
+	if svc.SpendStore == nil {
+		return nil, fmt.Errorf("%w: spending windows not available",
+			ErrSpendLimitExceeded)
+	}
+
+	return svc.SpendStore.Reserve(limits.SpendLimits, amt)
+}
+
+// AuthorizeChannel checks whether the macaroon of the request allows closing
+// or updating the given channel. A nil channel point denotes an operation that
+// affects all channels. A nil service doesn't restrict any channels.
+func (svc *Service) AuthorizeChannel(ctx context.Context,
+	chanPoint *wire.OutPoint) error {
+
+	if svc == nil {
+		return nil
+	}
+
+	limits, err := LimitsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return limits.CheckChannel(chanPoint)
+}
+
+// AuthorizeInvoice checks whether the macaroon of the request allows creating
+// an invoice with the given amount. A nil service doesn't restrict any
+// invoices.
+func (svc *Service) AuthorizeInvoice(ctx context.Context,
+	amt lnwire.MilliSatoshi) error {
+
+	if svc == nil {
+		return nil
+	}
+
+	limits, err := LimitsFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return limits.CheckInvoice(amt)
+}
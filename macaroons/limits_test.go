@@ -0,0 +1,269 @@
+package macaroons_test
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+	macaroon "gopkg.in/macaroon.v2"
+)
+
+var (
+	testDestA = [33]byte{2, 1}
+	testDestB = [33]byte{2, 2}
+	testDestC = [33]byte{2, 3}
+
+	testChanA = wire.OutPoint{Hash: [32]byte{1}, Index: 0}
+	testChanB = wire.OutPoint{Hash: [32]byte{2}, Index: 1}
+)
+
+// macaroonContext returns an incoming gRPC context that carries the given
+// macaroon.
+func macaroonContext(t *testing.T, mac *macaroon.Macaroon) context.Context {
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	md := metadata.New(map[string]string{
+		"macaroon": hex.EncodeToString(macBytes),
+	})
+
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// TestParseLimits tests that the most restrictive combination of repeated
+// limit caveats applies.
+func TestParseLimits(t *testing.T) {
+	t.Parallel()
+
+	mac, err := macaroons.AddConstraints(
+		createDummyMacaroon(t),
+		macaroons.MaxPaymentConstraint(1000),
+		macaroons.DestinationsConstraint(testDestA, testDestB),
+		macaroons.SpendLimitConstraint(5000, time.Hour),
+		macaroons.MaxPaymentConstraint(2000),
+		macaroons.DestinationsConstraint(testDestB, testDestC),
+		macaroons.ChannelsConstraint(testChanA),
+		macaroons.MaxInvoiceConstraint(300),
+		macaroons.RateLimitConstraint(macaroons.RateLimit{
+			Rate: 0.5, Burst: 10,
+		}),
+		macaroons.RateLimitConstraint(macaroons.RateLimit{
+			Rate: 2, Burst: 5,
+		}),
+		macaroons.MaxStreamsConstraint(3),
+		macaroons.MaxStreamsConstraint(4),
+	)
+	require.NoError(t, err)
+
+	limits, err := macaroons.ParseLimits(mac)
+	require.NoError(t, err)
+
+	// All rate and stream limits apply, each with its own ID.
+	require.Len(t, limits.RateLimits, 2)
+	require.Equal(t, 0.5, limits.RateLimits[0].Rate)
+	require.Equal(t, 10, limits.RateLimits[0].Burst)
+	require.Equal(t, 2.0, limits.RateLimits[1].Rate)
+	require.Equal(t, 5, limits.RateLimits[1].Burst)
+	require.NotEqual(
+		t, limits.RateLimits[0].ID, limits.RateLimits[1].ID,
+	)
+	require.Len(t, limits.StreamLimits, 2)
+	require.Equal(t, uint32(3), limits.StreamLimits[0].MaxStreams)
+	require.Equal(t, uint32(4), limits.StreamLimits[1].MaxStreams)
+
+	maxPayment := lnwire.NewMSatFromSatoshis(1000)
+	require.Equal(t, maxPayment, limits.MaxPayment.UnsafeFromSome())
+	require.Equal(
+		t, lnwire.NewMSatFromSatoshis(300),
+		limits.MaxInvoice.UnsafeFromSome(),
+	)
+
+	// Only the destination that is in both lists is allowed.
+	require.NoError(t, limits.CheckPayment(maxPayment, testDestB))
+	require.ErrorIs(
+		t, limits.CheckPayment(maxPayment, testDestA),
+		macaroons.ErrDestinationNotAllowed,
+	)
+	require.ErrorIs(
+		t, limits.CheckPayment(maxPayment+1, testDestB),
+		macaroons.ErrPaymentLimitExceeded,
+	)
+
+	// Global channel operations aren't allowed if the macaroon is
+	// restricted to a set of channels.
+	require.NoError(t, limits.CheckChannel(&testChanA))
+	require.ErrorIs(
+		t, limits.CheckChannel(&testChanB),
+		macaroons.ErrChannelNotAllowed,
+	)
+	require.ErrorIs(
+		t, limits.CheckChannel(nil), macaroons.ErrChannelNotAllowed,
+	)
+
+	// Invoices without an amount could be paid with any amount.
+	require.ErrorIs(
+		t, limits.CheckInvoice(0), macaroons.ErrInvoiceLimitExceeded,
+	)
+	require.ErrorIs(
+		t, limits.CheckInvoice(lnwire.NewMSatFromSatoshis(301)),
+		macaroons.ErrInvoiceLimitExceeded,
+	)
+
+	// The spending window must be shared by all macaroons derived from
+	// the one the limit was added to, but not with its siblings.
+	require.Len(t, limits.SpendLimits, 1)
+	require.Equal(t, time.Hour, limits.SpendLimits[0].Window)
+
+	derived, err := macaroons.AddConstraints(
+		mac, macaroons.TimeoutConstraint(60),
+	)
+	require.NoError(t, err)
+	derivedLimits, err := macaroons.ParseLimits(derived)
+	require.NoError(t, err)
+	require.Equal(t, limits.SpendLimits, derivedLimits.SpendLimits)
+
+	sibling, err := macaroons.AddConstraints(
+		createDummyMacaroon(t),
+		macaroons.SpendLimitConstraint(5000, time.Hour),
+	)
+	require.NoError(t, err)
+	siblingLimits, err := macaroons.ParseLimits(sibling)
+	require.NoError(t, err)
+	require.NotEqual(
+		t, limits.SpendLimits[0].ID, siblingLimits.SpendLimits[0].ID,
+	)
+
+	// A macaroon without any limit caveats isn't restricted.
+	limits, err = macaroons.ParseLimits(createDummyMacaroon(t))
+	require.NoError(t, err)
+	require.NoError(t, limits.CheckPayment(maxPayment, testDestA))
+	require.NoError(t, limits.CheckChannel(nil))
+	require.NoError(t, limits.CheckInvoice(0))
+	require.Empty(t, limits.RateLimits)
+	require.Empty(t, limits.StreamLimits)
+}
+
+// TestLimitCheckers tests that macaroons with well formed limit caveats pass
+// the validation, while malformed ones are rejected.
+func TestLimitCheckers(t *testing.T) {
+	t.Parallel()
+
+	db := setupTestRootKeyStorage(t)
+	rootKeyStore, err := macaroons.NewRootKeyStorage(db)
+	require.NoError(t, err)
+	service, err := macaroons.NewService(
+		rootKeyStore, "lnd", false, macaroons.LimitCheckers()...,
+	)
+	require.NoError(t, err)
+	defer service.Close()
+
+	require.NoError(t, service.CreateUnlock(&defaultPw))
+
+	bakedMac, err := service.NewMacaroon(
+		context.Background(), macaroons.DefaultRootKeyID, testOperation,
+	)
+	require.NoError(t, err)
+
+	mac, err := macaroons.AddConstraints(
+		bakedMac.M(),
+		macaroons.MaxPaymentConstraint(1000),
+		macaroons.SpendLimitConstraint(5000, time.Hour),
+		macaroons.DestinationsConstraint(testDestA),
+		macaroons.ChannelsConstraint(testChanA, testChanB),
+		macaroons.MaxInvoiceConstraint(300),
+		macaroons.RateLimitConstraint(macaroons.RateLimit{
+			Rate: 1, Burst: 1,
+		}),
+		macaroons.MaxStreamsConstraint(1),
+	)
+	require.NoError(t, err)
+
+	err = service.ValidateMacaroon(
+		macaroonContext(t, mac), []bakery.Op{testOperation}, "Foo",
+	)
+	require.NoError(t, err)
+
+	badMac := bakedMac.M().Clone()
+	err = badMac.AddFirstPartyCaveat(
+		[]byte(macaroons.CondMaxPayment + " lots"),
+	)
+	require.NoError(t, err)
+
+	err = service.ValidateMacaroon(
+		macaroonContext(t, badMac), []bakery.Op{testOperation}, "Foo",
+	)
+	require.Error(t, err)
+}
+
+// TestAuthorizePayment tests that payments are counted against the rolling
+// spending window of a macaroon.
+func TestAuthorizePayment(t *testing.T) {
+	t.Parallel()
+
+	testClock := clock.NewTestClock(time.Unix(1700000000, 0))
+	spendStore, err := macaroons.NewSpendStore(
+		setupTestRootKeyStorage(t), testClock,
+	)
+	require.NoError(t, err)
+
+	service := &macaroons.Service{
+		SpendStore: spendStore,
+	}
+
+	mac, err := macaroons.AddConstraints(
+		createDummyMacaroon(t),
+		macaroons.SpendLimitConstraint(1000, time.Hour),
+	)
+	require.NoError(t, err)
+	ctx := macaroonContext(t, mac)
+
+	sat := lnwire.NewMSatFromSatoshis
+
+	_, err = service.AuthorizePayment(ctx, sat(600), testDestA)
+	require.NoError(t, err)
+
+	testClock.SetTime(testClock.Now().Add(30 * time.Minute))
+	release, err := service.AuthorizePayment(ctx, sat(400), testDestA)
+	require.NoError(t, err)
+
+	// The window is exhausted now.
+	_, err = service.AuthorizePayment(ctx, sat(1), testDestA)
+	require.ErrorIs(t, err, macaroons.ErrSpendLimitExceeded)
+
+	// Releasing a failed payment frees up its amount again.
+	require.NoError(t, release())
+	_, err = service.AuthorizePayment(ctx, sat(400), testDestA)
+	require.NoError(t, err)
+
+	// Once the first payment drops out of the window, its amount can be
+	// spent again.
+	testClock.SetTime(testClock.Now().Add(31 * time.Minute))
+	_, err = service.AuthorizePayment(ctx, sat(600), testDestA)
+	require.NoError(t, err)
+
+	limits, err := macaroons.ParseLimits(mac)
+	require.NoError(t, err)
+	spent, err := spendStore.Spent(limits.SpendLimits[0])
+	require.NoError(t, err)
+	require.Equal(t, sat(1000), spent)
+
+	// A macaroon without a spend limit isn't tracked at all, and a nil
+	// service doesn't restrict anything.
+	_, err = service.AuthorizePayment(
+		macaroonContext(t, createDummyMacaroon(t)), sat(5000),
+		testDestA,
+	)
+	require.NoError(t, err)
+
+	var nilService *macaroons.Service
+	_, err = nilService.AuthorizePayment(ctx, sat(5000), testDestA)
+	require.NoError(t, err)
+}
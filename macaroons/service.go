@@ -98,6 +98,11 @@ type Service struct {
 	// StatelessInit denotes if the service was initialized in the stateless
 	// mode where no macaroon files should be created on disk.
 	StatelessInit bool
+
+	// SpendStore keeps track of the payments made within the spending
+	// windows of macaroons. If it is nil, payments made with a macaroon
+	// that has a spend limit are rejected.
+	SpendStore *SpendStore
 }
 
 // NewService returns a service backed by the macaroon DB backend. The `checks`
@@ -0,0 +1,225 @@
+package macaroons
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/lightningnetwork/lnd/lnwire"
+)
+
+var (
+	// spendingBucketName is the name of the top level bucket that holds
+	// the payments made within the spending windows of macaroons. It
+	// contains one sub bucket per spending window, keyed by the ID of the
+	// window. The payments are stored with a key of the form
+	// <unix-nano-timestamp><sequence> and their amount as value.
+	spendingBucketName = []byte("macaroon-spending")
+
+	// ErrSpendLimitExceeded is returned if a payment would exceed the
+	// amount that can be spent within the spending window of a macaroon.
+	ErrSpendLimitExceeded = errors.New("payment exceeds macaroon spend " +
+		"limit")
+)
+
+// SpendStore keeps track of the payments made within the spending windows of
+// macaroons.
+type SpendStore struct {
+	db    kvdb.Backend
+	clock clock.Clock
+}
+
+// NewSpendStore creates a new spend store that is backed by the given macaroon
+// database.
+func NewSpendStore(db kvdb.Backend, clock clock.Clock) (*SpendStore, error) {
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		_, err := tx.CreateTopLevelBucket(spendingBucketName)
+		return err
+	}, func() {})
+	if err != nil {
+		return nil, err
+	}
+
+	return &SpendStore{
+		db:    db,
+		clock: clock,
+	}, nil
+}
+
+// Reserve records a payment of the given amount in all given spending windows,
+// if that doesn't exceed any of their limits. Payments that dropped out of a
+// window are removed first. The returned function removes the payment from the
+// windows again and must be called if the payment failed.
+func (s *SpendStore) Reserve(limits []SpendLimit,
+	amt lnwire.MilliSatoshi) (func() error, error) {
+
+	if len(limits) == 0 {
+		return func() error { return nil }, nil
+	}
+
+	now := s.clock.Now()
+
+	type entry struct {
+		window [32]byte
+		key    []byte
+	}
+	var entries []entry
+
+	err := kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+		spending := tx.ReadWriteBucket(spendingBucketName)
+		if spending == nil {
+			return fmt.Errorf("spending bucket not found")
+		}
+
+		for _, limit := range limits {
+			window, err := spending.CreateBucketIfNotExists(
+				limit.ID[:],
+			)
+			if err != nil {
+				return err
+			}
+
+			spent, err := pruneWindow(
+				window, now.Add(-limit.Window),
+			)
+			if err != nil {
+				return err
+			}
+
+			if spent+amt > limit.Amount {
+				return fmt.Errorf("%w: %v already spent of "+
+					"%v within %v", ErrSpendLimitExceeded,
+					spent, limit.Amount, limit.Window)
+			}
+
+			seq, err := window.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			key := windowKey(now)
+			binary.BigEndian.PutUint64(key[8:], seq)
+
+			var value [8]byte
+			binary.BigEndian.PutUint64(value[:], uint64(amt))
+			if err := window.Put(key, value[:]); err != nil {
+				return err
+			}
+
+			entries = append(entries, entry{
+				window: limit.ID,
+				key:    key,
+			})
+		}
+
+		return nil
+	}, func() {
+		entries = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	release := func() error {
+		return kvdb.Update(s.db, func(tx kvdb.RwTx) error {
+			spending := tx.ReadWriteBucket(spendingBucketName)
+			if spending == nil {
+				return fmt.Errorf("spending bucket not found")
+			}
+
+			for _, e := range entries {
+				window := spending.NestedReadWriteBucket(
+					e.window[:],
+				)
+				if window == nil {
+					continue
+				}
+
+				if err := window.Delete(e.key); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}, func() {})
+	}
+
+	return release, nil
+}
+
+// Spent returns the total amount spent within the given spending window.
+func (s *SpendStore) Spent(limit SpendLimit) (lnwire.MilliSatoshi, error) {
+	start := s.clock.Now().Add(-limit.Window)
+
+	var spent lnwire.MilliSatoshi
+	startKey := windowKey(start)
+	err := kvdb.View(s.db, func(tx kvdb.RTx) error {
+		spending := tx.ReadBucket(spendingBucketName)
+		if spending == nil {
+			return fmt.Errorf("spending bucket not found")
+		}
+
+		window := spending.NestedReadBucket(limit.ID[:])
+		if window == nil {
+			return nil
+		}
+
+		return window.ForEach(func(k, v []byte) error {
+			if bytes.Compare(k, startKey) < 0 {
+				return nil
+			}
+
+			spent += lnwire.MilliSatoshi(binary.BigEndian.Uint64(v))
+			return nil
+		})
+	}, func() {
+		spent = 0
+	})
+
+	return spent, err
+}
+
+// pruneWindow removes all payments made before the given start time from the
+// spending window and returns the total amount of the remaining payments.
+func pruneWindow(window kvdb.RwBucket,
+	start time.Time) (lnwire.MilliSatoshi, error) {
+
+	var (
+		expired [][]byte
+		spent   lnwire.MilliSatoshi
+	)
+	startKey := windowKey(start)
+	err := window.ForEach(func(k, v []byte) error {
+		if bytes.Compare(k, startKey) < 0 {
+			expired = append(expired, append([]byte(nil), k...))
+			return nil
+		}
+
+		spent += lnwire.MilliSatoshi(binary.BigEndian.Uint64(v))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, k := range expired {
+		if err := window.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+
+	return spent, nil
+}
+
+// windowKey returns the key of a payment made at the given time with a
+// sequence number of zero.
+func windowKey(t time.Time) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(t.UnixNano()))
+
+	return key
+}
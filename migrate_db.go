@@ -0,0 +1,146 @@
+package lnd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/lightningnetwork/lnd/dbmigrate"
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/lightningnetwork/lnd/lncfg"
+)
+
+// boltMigration describes a single bbolt database file and the namespace of
+// the SQL backend its content is migrated to.
+type boltMigration struct {
+	// dir is the directory of both the bbolt file and the sqlite file.
+	dir string
+
+	// boltFileName is the file name of the bbolt source database.
+	boltFileName string
+
+	// sqliteFileName is the file name of the sqlite destination database.
+	// This is ignored for postgres.
+	sqliteFileName string
+
+	// namespace is the kvdb namespace of the destination.
+	namespace string
+}
+
+// boltMigrations returns all bbolt databases of the node together with the
+// location their content is stored in when using a SQL backend. This mirrors
+// the layout of lncfg.DB.GetBackends.
+func boltMigrations(cfg *Config) []boltMigration {
+	chanDBPath := cfg.graphDatabaseDir()
+	walletDBPath := cfg.networkDir
+	towerServerDBPath := filepath.Join(
+		cfg.Watchtower.TowerDir, BitcoinChainName,
+		lncfg.NormalizeNetwork(cfg.ActiveNetParams.Name),
+	)
+
+	return []boltMigration{{
+		dir:            chanDBPath,
+		boltFileName:   lncfg.ChannelDBName,
+		sqliteFileName: lncfg.SqliteChannelDBName,
+		namespace:      lncfg.NSChannelDB,
+	}, {
+		dir:            chanDBPath,
+		boltFileName:   lncfg.DecayedLogDbName,
+		sqliteFileName: lncfg.SqliteChannelDBName,
+		namespace:      lncfg.NSDecayedLogDB,
+	}, {
+		dir:            chanDBPath,
+		boltFileName:   lncfg.TowerClientDBName,
+		sqliteFileName: lncfg.SqliteChannelDBName,
+		namespace:      lncfg.NSTowerClientDB,
+	}, {
+		dir:            walletDBPath,
+		boltFileName:   lncfg.MacaroonDBName,
+		sqliteFileName: lncfg.SqliteChainDBName,
+		namespace:      lncfg.NSMacaroonDB,
+	}, {
+		dir:            walletDBPath,
+		boltFileName:   lncfg.WalletDBName,
+		sqliteFileName: lncfg.SqliteChainDBName,
+		namespace:      lncfg.NSWalletDB,
+	}, {
+		dir:            towerServerDBPath,
+		boltFileName:   lncfg.TowerServerDBName,
+		sqliteFileName: lncfg.SqliteTowerDBName,
+		namespace:      lncfg.NSTowerServerDB,
+	}}
+}
+
+// openMigrationTarget opens the SQL backend the given bbolt database is
+// migrated to.
+func openMigrationTarget(ctx context.Context, db *lncfg.DB,
+	m boltMigration) (kvdb.Backend, error) {
+
+	switch db.Backend {
+	case lncfg.PostgresBackend:
+		return kvdb.Open(
+			kvdb.PostgresBackendName, ctx,
+			lncfg.GetPostgresConfigKVDB(db.Postgres), m.namespace,
+		)
+
+	case lncfg.SqliteBackend:
+		return kvdb.Open(
+			kvdb.SqliteBackendName, ctx,
+			lncfg.GetSqliteConfigKVDB(db.Sqlite), m.dir,
+			m.sqliteFileName, m.namespace,
+		)
+
+	default:
+		return nil, fmt.Errorf("cannot migrate to database backend "+
+			"'%v'", db.Backend)
+	}
+}
+
+// migrateBoltDBs copies the content of all existing bbolt database files into
+// the configured SQL backend. Database files that don't exist are skipped.
+func migrateBoltDBs(ctx context.Context, cfg *Config) error {
+	migrationCfg := dbmigrate.DefaultConfig()
+
+	for _, m := range boltMigrations(cfg) {
+		name := filepath.Join(m.dir, m.boltFileName)
+
+		src, err := dbmigrate.OpenBoltSource(
+			m.dir, m.boltFileName, dbmigrate.DefaultSourceTimeout,
+		)
+		if errors.Is(err, dbmigrate.ErrSourceNotFound) {
+			ltndLog.Infof("Database %v not found, skipping", name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		dst, err := openMigrationTarget(ctx, cfg.DB, m)
+		if err != nil {
+			_ = src.Close()
+
+			return fmt.Errorf("unable to open %v destination for "+
+				"%v: %w", cfg.DB.Backend, name, err)
+		}
+
+		err = dbmigrate.Migrate(ctx, name, src, dst, migrationCfg)
+
+		// We close both databases before checking the error, so the
+		// next database is never opened while this one is still open.
+		if closeErr := dst.Close(); closeErr != nil {
+			ltndLog.Errorf("Unable to close destination of %v: %v",
+				name, closeErr)
+		}
+		if closeErr := src.Close(); closeErr != nil {
+			ltndLog.Errorf("Unable to close %v: %v", name,
+				closeErr)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
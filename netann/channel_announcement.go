@@ -3,8 +3,10 @@ package netann
 import (
 	"bytes"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/lightningnetwork/lnd/channeldb/models"
 	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/tlv"
 )
 
 // CreateChanAnnouncement is a helper function which creates all channel
@@ -84,3 +86,87 @@ func CreateChanAnnouncement(chanProof *models.ChannelAuthProof,
 
 	return chanAnn, edge1Ann, edge2Ann, nil
 }
+
+// UnsignedChanAnnouncement2 reconstructs the unsigned ChannelAnnouncement2 of
+// a taproot channel from its edge info.
+func UnsignedChanAnnouncement2(
+	chanInfo *models.ChannelEdgeInfo) (*lnwire.ChannelAnnouncement2, error) {
+
+	chanAnn := &lnwire.ChannelAnnouncement2{
+		ExtraOpaqueData: chanInfo.ExtraOpaqueData,
+	}
+	chanAnn.ChainHash.Val = chanInfo.ChainHash
+	chanAnn.ShortChannelID.Val = lnwire.NewShortChanIDFromInt(
+		chanInfo.ChannelID,
+	)
+	chanAnn.Capacity.Val = uint64(chanInfo.Capacity)
+	chanAnn.NodeID1.Val = chanInfo.NodeKey1Bytes
+	chanAnn.NodeID2.Val = chanInfo.NodeKey2Bytes
+	chanAnn.BitcoinKey1 = tlv.SomeRecordT(
+		tlv.NewPrimitiveRecord[tlv.TlvType12](
+			chanInfo.BitcoinKey1Bytes,
+		),
+	)
+	chanAnn.BitcoinKey2 = tlv.SomeRecordT(
+		tlv.NewPrimitiveRecord[tlv.TlvType14](
+			chanInfo.BitcoinKey2Bytes,
+		),
+	)
+	chanInfo.MerkleRootHash.WhenSome(func(root chainhash.Hash) {
+		chanAnn.MerkleRootHash = tlv.SomeRecordT(
+			tlv.NewPrimitiveRecord[tlv.TlvType16, [32]byte](root),
+		)
+	})
+
+	features := lnwire.NewRawFeatureVector()
+	err := features.Decode(bytes.NewReader(chanInfo.Features))
+	if err != nil {
+		return nil, err
+	}
+	chanAnn.Features.Val = *lnwire.NewFeatureVector(
+		features, lnwire.Features,
+	)
+
+	return chanAnn, nil
+}
+
+// CreateChanAnnouncement2 is the taproot gossip equivalent of
+// CreateChanAnnouncement. It re-creates the authenticated
+// ChannelAnnouncement2 of a taproot channel along with the ChannelUpdate2 of
+// each of its policies.
+func CreateChanAnnouncement2(chanProof *models.ChannelAuthProof,
+	chanInfo *models.ChannelEdgeInfo,
+	e1, e2 *models.ChannelEdgePolicy) (*lnwire.ChannelAnnouncement2,
+	*lnwire.ChannelUpdate2, *lnwire.ChannelUpdate2, error) {
+
+	chanAnn, err := UnsignedChanAnnouncement2(chanInfo)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	chanAnn.Signature, err = lnwire.NewSigFromSchnorrRawSignature(
+		chanProof.SchnorrSigBytes,
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Since it's up to a node's policy as to whether they advertise the
+	// edge in a direction, we don't create an advertisement if the edge is
+	// nil.
+	var edge1Ann, edge2Ann *lnwire.ChannelUpdate2
+	if e1 != nil {
+		edge1Ann, err = ChannelUpdate2FromEdge(chanInfo, e1)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if e2 != nil {
+		edge2Ann, err = ChannelUpdate2FromEdge(chanInfo, e2)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	return chanAnn, edge1Ann, edge2Ann, nil
+}
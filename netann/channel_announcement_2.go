@@ -0,0 +1,219 @@
+package netann
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/routing"
+)
+
+var (
+	// ErrChanAnn2NoncesMissing is returned when a partial signature is
+	// requested before the nonces of the remote peer are known.
+	ErrChanAnn2NoncesMissing = errors.New("remote nonces of channel " +
+		"announcement session not yet known")
+
+	// ErrChanAnn2NotSigned is returned when the partial signature of the
+	// remote peer is combined before we created our own.
+	ErrChanAnn2NotSigned = errors.New("channel announcement session " +
+		"has no local partial signature yet")
+)
+
+// ChanAnn2Session drives the creation of the MuSig2 signature of the
+// ChannelAnnouncement2 of one of our taproot channels. The announcement is
+// signed by the aggregate of the node and bitcoin keys of both peers, of which
+// we control two. We therefore run one MuSig2 session for each of our keys,
+// and hand the remote peer the sum of their partial signatures, so that to the
+// remote peer it looks like we only control a single key.
+type ChanAnn2Session struct {
+	signer input.MuSig2Signer
+
+	// ann is the unsigned announcement of the channel.
+	ann *lnwire.ChannelAnnouncement2
+
+	// digest is the message digest that is signed by the session.
+	digest [32]byte
+
+	// aggKey is the aggregate key of the four keys of the channel.
+	aggKey *btcec.PublicKey
+
+	// nodeSession and bitcoinSession are the MuSig2 sessions of our node
+	// and bitcoin key respectively.
+	nodeSession    *input.MuSig2SessionInfo
+	bitcoinSession *input.MuSig2SessionInfo
+
+	// localSig is the sum of the partial signatures of our two keys, and
+	// finalNonce is the aggregate nonce of all four signers. Both are only
+	// set once we've signed the announcement.
+	localSig   *btcec.ModNScalar
+	finalNonce *btcec.PublicKey
+}
+
+// NewChanAnn2Session creates a new signing session for the passed unsigned
+// announcement, using the node and bitcoin keys described by the given key
+// locators.
+func NewChanAnn2Session(signer input.MuSig2Signer,
+	ann *lnwire.ChannelAnnouncement2, nodeKeyLoc,
+	bitcoinKeyLoc keychain.KeyLocator) (*ChanAnn2Session, error) {
+
+	keys, err := routing.ChannelAnn2Keys(ann)
+	if err != nil {
+		return nil, err
+	}
+
+	aggKey, err := routing.ChannelAnn2AggregateKey(ann)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := ann.DigestToSign()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeSession, err := signer.MuSig2CreateSession(
+		input.MuSig2Version100RC2, nodeKeyLoc, keys,
+		&input.MuSig2Tweaks{}, nil, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create node key session: %w",
+			err)
+	}
+
+	// We already know the nonce of our node key, so we'll pass it along
+	// when creating the session of our bitcoin key.
+	bitcoinSession, err := signer.MuSig2CreateSession(
+		input.MuSig2Version100RC2, bitcoinKeyLoc, keys,
+		&input.MuSig2Tweaks{},
+		[][musig2.PubNonceSize]byte{nodeSession.PublicNonce}, nil,
+	)
+	if err != nil {
+		_ = signer.MuSig2Cleanup(nodeSession.SessionID)
+
+		return nil, fmt.Errorf("unable to create bitcoin key "+
+			"session: %w", err)
+	}
+
+	_, err = signer.MuSig2RegisterNonces(
+		nodeSession.SessionID,
+		[][musig2.PubNonceSize]byte{bitcoinSession.PublicNonce},
+	)
+	if err != nil {
+		_ = signer.MuSig2Cleanup(nodeSession.SessionID)
+		_ = signer.MuSig2Cleanup(bitcoinSession.SessionID)
+
+		return nil, err
+	}
+
+	return &ChanAnn2Session{
+		signer:         signer,
+		ann:            ann,
+		digest:         *digest,
+		aggKey:         aggKey,
+		nodeSession:    nodeSession,
+		bitcoinSession: bitcoinSession,
+	}, nil
+}
+
+// LocalNonces returns the public nonces of our node and bitcoin key, which
+// are to be sent to the remote peer.
+func (s *ChanAnn2Session) LocalNonces() (lnwire.Musig2Nonce,
+	lnwire.Musig2Nonce) {
+
+	return s.nodeSession.PublicNonce, s.bitcoinSession.PublicNonce
+}
+
+// Sign registers the public nonces of the node and bitcoin key of the remote
+// peer, and returns the sum of the partial signatures of our two keys.
+func (s *ChanAnn2Session) Sign(remoteNodeNonce,
+	remoteBitcoinNonce lnwire.Musig2Nonce) (*lnwire.PartialSig, error) {
+
+	if s.localSig != nil {
+		sig := lnwire.NewPartialSig(*s.localSig)
+		return &sig, nil
+	}
+
+	remoteNonces := [][musig2.PubNonceSize]byte{
+		remoteNodeNonce, remoteBitcoinNonce,
+	}
+
+	var partialSigs []*musig2.PartialSignature
+	for _, session := range []*input.MuSig2SessionInfo{
+		s.nodeSession, s.bitcoinSession,
+	} {
+
+		haveAll, err := s.signer.MuSig2RegisterNonces(
+			session.SessionID, remoteNonces,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if !haveAll {
+			return nil, ErrChanAnn2NoncesMissing
+		}
+
+		// As we combine the final signature ourselves, the session
+		// can be cleaned up right away.
+		partialSig, err := s.signer.MuSig2Sign(
+			session.SessionID, s.digest, true,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		partialSigs = append(partialSigs, partialSig)
+	}
+
+	var localSig btcec.ModNScalar
+	localSig.Add2(partialSigs[0].S, partialSigs[1].S)
+
+	s.localSig = &localSig
+	s.finalNonce = partialSigs[0].R
+
+	sig := lnwire.NewPartialSig(localSig)
+
+	return &sig, nil
+}
+
+// CombineSig combines our partial signature with the one of the remote peer,
+// and returns the final signature of the announcement after verifying it.
+func (s *ChanAnn2Session) CombineSig(
+	remoteSig lnwire.PartialSig) (*schnorr.Signature, error) {
+
+	if s.localSig == nil {
+		return nil, ErrChanAnn2NotSigned
+	}
+
+	sig := musig2.CombineSigs(s.finalNonce, []*musig2.PartialSignature{
+		{S: s.localSig, R: s.finalNonce},
+		{S: &remoteSig.Sig, R: s.finalNonce},
+	})
+
+	if !sig.Verify(s.digest[:], s.aggKey) {
+		return nil, fmt.Errorf("invalid signature for channel "+
+			"announcement of channel %v", s.ann.ShortChannelID.Val)
+	}
+
+	return sig, nil
+}
+
+// Cleanup removes the MuSig2 sessions of the announcement from the signer if
+// they weren't already removed by signing.
+func (s *ChanAnn2Session) Cleanup() error {
+	if s.localSig != nil {
+		return nil
+	}
+
+	err := s.signer.MuSig2Cleanup(s.nodeSession.SessionID)
+	if err != nil {
+		return err
+	}
+
+	return s.signer.MuSig2Cleanup(s.bitcoinSession.SessionID)
+}
@@ -0,0 +1,226 @@
+package netann_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb/models"
+	"github.com/lightningnetwork/lnd/fn"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lntest/mock"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/lightningnetwork/lnd/netann"
+	"github.com/lightningnetwork/lnd/routing"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testNodeKeyLoc = keychain.KeyLocator{
+		Family: keychain.KeyFamilyNodeKey,
+	}
+	testBitcoinKeyLoc = keychain.KeyLocator{
+		Family: keychain.KeyFamilyMultiSig,
+	}
+)
+
+// chanAnn2Peer is one of the peers of a taproot channel that takes part in
+// the signing of its announcement.
+type chanAnn2Peer struct {
+	nodeKey    *btcec.PrivateKey
+	bitcoinKey *btcec.PrivateKey
+	signer     input.MuSig2Signer
+}
+
+func newChanAnn2Peer(t *testing.T) *chanAnn2Peer {
+	nodeKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	bitcoinKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+
+	keyFetcher := func(
+		desc *keychain.KeyDescriptor) (*btcec.PrivateKey, error) {
+
+		if desc.KeyLocator == testNodeKeyLoc {
+			return nodeKey, nil
+		}
+
+		return bitcoinKey, nil
+	}
+
+	return &chanAnn2Peer{
+		nodeKey:    nodeKey,
+		bitcoinKey: bitcoinKey,
+		signer:     input.NewMusigSessionManager(keyFetcher),
+	}
+}
+
+// newTestChanAnn2 creates an unsigned announcement of a channel between the
+// two passed peers.
+func newTestChanAnn2(t *testing.T,
+	peers [2]*chanAnn2Peer) *models.ChannelEdgeInfo {
+
+	sort.Slice(peers[:], func(i, j int) bool {
+		return bytes.Compare(
+			peers[i].nodeKey.PubKey().SerializeCompressed(),
+			peers[j].nodeKey.PubKey().SerializeCompressed(),
+		) < 0
+	})
+
+	var features bytes.Buffer
+	rawFeatures := lnwire.NewRawFeatureVector(
+		lnwire.SimpleTaprootChannelsRequiredStaging,
+	)
+	require.NoError(t, rawFeatures.Encode(&features))
+
+	chanInfo := &models.ChannelEdgeInfo{
+		ChainHash:      chainhash.Hash{0x1},
+		ChannelID:      1234,
+		ChannelPoint:   wire.OutPoint{Index: 1},
+		Capacity:       btcutil.SatoshiPerBitcoin,
+		Features:       features.Bytes(),
+		Version:        lnwire.GossipVersion2,
+		MerkleRootHash: fn.Some(chainhash.Hash{0x2}),
+	}
+	chanInfo.AddNodeKeys(
+		peers[0].nodeKey.PubKey(), peers[1].nodeKey.PubKey(),
+		peers[0].bitcoinKey.PubKey(), peers[1].bitcoinKey.PubKey(),
+	)
+
+	return chanInfo
+}
+
+// TestChanAnn2Session asserts that two peers can create the MuSig2 signature
+// of the announcement of their taproot channel, and that the resulting
+// announcement is valid.
+func TestChanAnn2Session(t *testing.T) {
+	t.Parallel()
+
+	alice, bob := newChanAnn2Peer(t), newChanAnn2Peer(t)
+	chanInfo := newTestChanAnn2(t, [2]*chanAnn2Peer{alice, bob})
+
+	ann, err := netann.UnsignedChanAnnouncement2(chanInfo)
+	require.NoError(t, err)
+
+	aliceSession, err := netann.NewChanAnn2Session(
+		alice.signer, ann, testNodeKeyLoc, testBitcoinKeyLoc,
+	)
+	require.NoError(t, err)
+	bobSession, err := netann.NewChanAnn2Session(
+		bob.signer, ann, testNodeKeyLoc, testBitcoinKeyLoc,
+	)
+	require.NoError(t, err)
+
+	// Combining a signature before signing ourselves should fail.
+	_, err = aliceSession.CombineSig(lnwire.PartialSig{})
+	require.ErrorIs(t, err, netann.ErrChanAnn2NotSigned)
+
+	// First, the peers exchange their nonces, which allows both of them to
+	// create their partial signature.
+	aliceNodeNonce, aliceBitcoinNonce := aliceSession.LocalNonces()
+	bobNodeNonce, bobBitcoinNonce := bobSession.LocalNonces()
+
+	aliceSig, err := aliceSession.Sign(bobNodeNonce, bobBitcoinNonce)
+	require.NoError(t, err)
+	bobSig, err := bobSession.Sign(aliceNodeNonce, aliceBitcoinNonce)
+	require.NoError(t, err)
+
+	// Signing again should return the same partial signature.
+	aliceSig2, err := aliceSession.Sign(bobNodeNonce, bobBitcoinNonce)
+	require.NoError(t, err)
+	require.Equal(t, aliceSig, aliceSig2)
+
+	// Then, once the partial signatures are exchanged, both peers should
+	// arrive at the same valid signature.
+	aliceFinalSig, err := aliceSession.CombineSig(*bobSig)
+	require.NoError(t, err)
+	bobFinalSig, err := bobSession.CombineSig(*aliceSig)
+	require.NoError(t, err)
+	require.Equal(
+		t, aliceFinalSig.Serialize(), bobFinalSig.Serialize(),
+	)
+
+	require.NoError(t, aliceSession.Cleanup())
+
+	// Finally, the announcement that is recreated from the stored proof
+	// should pass validation.
+	proof := &models.ChannelAuthProof{
+		SchnorrSigBytes: aliceFinalSig.Serialize(),
+	}
+	signedAnn, _, _, err := netann.CreateChanAnnouncement2(
+		proof, chanInfo, nil, nil,
+	)
+	require.NoError(t, err)
+	require.NoError(t, routing.ValidateChannelAnn2(signedAnn))
+
+	// A signature over a different announcement must be rejected.
+	signedAnn.Capacity.Val++
+	require.Error(t, routing.ValidateChannelAnn2(signedAnn))
+
+	// An invalid partial signature of the remote peer should be caught
+	// when combining it.
+	var invalidSig lnwire.PartialSig
+	invalidSig.Sig.SetInt(1)
+	_, err = aliceSession.CombineSig(invalidSig)
+	require.Error(t, err)
+}
+
+// TestSignChannelUpdate2 asserts that a ChannelUpdate2 that is signed with
+// SignChannelUpdate2 passes validation.
+func TestSignChannelUpdate2(t *testing.T) {
+	t.Parallel()
+
+	nodeKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	signer := &mock.SecretKeyRing{RootKey: nodeKey}
+
+	chanInfo := &models.ChannelEdgeInfo{
+		ChainHash: chainhash.Hash{0x1},
+		ChannelID: 1234,
+		Capacity:  btcutil.SatoshiPerBitcoin,
+		Version:   lnwire.GossipVersion2,
+	}
+	policy := &models.ChannelEdgePolicy{
+		ChannelID:                 chanInfo.ChannelID,
+		ChannelFlags:              lnwire.ChanUpdateDirection,
+		MessageFlags:              lnwire.ChanUpdateRequiredMaxHtlc,
+		TimeLockDelta:             40,
+		MinHTLC:                   1000,
+		MaxHTLC:                   lnwire.NewMSatFromSatoshis(1000),
+		FeeBaseMSat:               1000,
+		FeeProportionalMillionths: 1,
+		Version:                   lnwire.GossipVersion2,
+		BlockHeight:               800_000,
+		DisableFlags:              lnwire.ChanUpdateDisableIncoming,
+	}
+
+	update := netann.UnsignedChannelUpdate2FromEdge(chanInfo, policy)
+	require.True(t, update.SecondPeer.Val)
+	require.Equal(t, policy.BlockHeight, update.BlockHeight.Val)
+
+	err = netann.SignChannelUpdate2(signer, testNodeKeyLoc, update)
+	require.NoError(t, err)
+
+	require.NoError(t, routing.ValidateChannelUpdate2Ann(
+		nodeKey.PubKey(), chanInfo.Capacity, update,
+	))
+
+	// The update must not validate against another key.
+	otherKey, err := btcec.NewPrivateKey()
+	require.NoError(t, err)
+	require.Error(t, routing.VerifyChannelUpdate2Signature(
+		update, otherKey.PubKey(),
+	))
+
+	// Re-creating the update from the policy with its signature should
+	// result in the same update.
+	policy.SigBytes = update.Signature.ToSignatureBytes()
+	update2, err := netann.ChannelUpdate2FromEdge(chanInfo, policy)
+	require.NoError(t, err)
+	require.Equal(t, update, update2)
+}
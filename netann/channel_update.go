@@ -152,3 +152,50 @@ func ChannelUpdateFromEdge(info *models.ChannelEdgeInfo,
 
 	return update, nil
 }
+
+// UnsignedChannelUpdate2FromEdge reconstructs an unsigned ChannelUpdate2 from
+// the given edge info and policy of a channel that was announced with the
+// taproot gossip protocol.
+func UnsignedChannelUpdate2FromEdge(info *models.ChannelEdgeInfo,
+	policy *models.ChannelEdgePolicy) *lnwire.ChannelUpdate2 {
+
+	update := &lnwire.ChannelUpdate2{
+		ExtraOpaqueData: policy.ExtraOpaqueData,
+	}
+	update.ChainHash.Val = info.ChainHash
+	update.ShortChannelID.Val = lnwire.NewShortChanIDFromInt(
+		policy.ChannelID,
+	)
+	update.BlockHeight.Val = policy.BlockHeight
+	update.DisabledFlags.Val = policy.DisableFlags
+	update.SecondPeer.Val =
+		policy.ChannelFlags&lnwire.ChanUpdateDirection != 0
+	update.CLTVExpiryDelta.Val = policy.TimeLockDelta
+	update.HTLCMinimumMsat.Val = policy.MinHTLC
+	update.HTLCMaximumMsat.Val = policy.MaxHTLC
+	update.FeeBaseMsat.Val = uint32(policy.FeeBaseMSat)
+	update.FeeProportionalMillionths.Val = uint32(
+		policy.FeeProportionalMillionths,
+	)
+
+	return update
+}
+
+// ChannelUpdate2FromEdge reconstructs a signed ChannelUpdate2 from the given
+// edge info and policy of a channel that was announced with the taproot gossip
+// protocol.
+func ChannelUpdate2FromEdge(info *models.ChannelEdgeInfo,
+	policy *models.ChannelEdgePolicy) (*lnwire.ChannelUpdate2, error) {
+
+	update := UnsignedChannelUpdate2FromEdge(info, policy)
+
+	var err error
+	update.Signature, err = lnwire.NewSigFromSchnorrRawSignature(
+		policy.SigBytes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
@@ -3,6 +3,7 @@ package netann
 import (
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/keychain"
 	"github.com/lightningnetwork/lnd/lnwallet"
@@ -35,3 +36,39 @@ func SignAnnouncement(signer lnwallet.MessageSigner, keyLoc keychain.KeyLocator,
 
 	return signer.SignMessage(keyLoc, data, true)
 }
+
+// SchnorrMessageSigner is an interface that abstracts away the creation of
+// the Schnorr signatures of the messages of the taproot gossip protocol.
+type SchnorrMessageSigner interface {
+	// SignMessageSchnorr signs the given message, single or double SHA256
+	// hashing it first, or computing its tagged hash if a tag is given,
+	// with the private key described in the key locator and the optional
+	// Taproot tweak applied to the private key.
+	SignMessageSchnorr(keyLoc keychain.KeyLocator, msg []byte,
+		doubleHash bool, taprootTweak []byte,
+		tag []byte) (*schnorr.Signature, error)
+}
+
+// SignChannelUpdate2 signs the passed lnwire.ChannelUpdate2 with the node key
+// described by the key locator, and sets the signature of the update.
+//
+// NOTE: This method modifies the given update.
+func SignChannelUpdate2(signer SchnorrMessageSigner,
+	keyLoc keychain.KeyLocator, update *lnwire.ChannelUpdate2) error {
+
+	data, err := update.DataToSign()
+	if err != nil {
+		return fmt.Errorf("unable to get data to sign: %w", err)
+	}
+
+	sig, err := signer.SignMessageSchnorr(
+		keyLoc, data, false, nil, update.SignatureTag(),
+	)
+	if err != nil {
+		return err
+	}
+
+	update.Signature, err = lnwire.NewSigFromSignature(sig)
+
+	return err
+}
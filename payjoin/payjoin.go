@@ -0,0 +1,277 @@
+// Package payjoin implements the sender and the receiver side of the payjoin
+// protocol as specified in BIP 78. In a payjoin the receiver of a payment adds
+// some of its own inputs to the transaction of the sender, which breaks the
+// common input ownership heuristic of chain analysis.
+//
+// The sender creates and signs a regular transaction, the original PSBT, and
+// posts it to the payjoin endpoint of the receiver. The receiver answers with
+// the payjoin proposal, a PSBT that spends the inputs of the original PSBT and
+// the inputs it contributed. After checking that the proposal doesn't take
+// more of its money than agreed on, the sender signs and broadcasts it. If
+// anything goes wrong, the sender broadcasts the original transaction, which
+// the receiver also does if the proposal isn't broadcast in time.
+package payjoin
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/input"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+const (
+	// Version is the version of the payjoin protocol that is supported.
+	Version = 1
+
+	// maxBodySize is the maximum size of a PSBT that is exchanged by the
+	// sender and the receiver.
+	maxBodySize = 1 << 20
+)
+
+// ErrorCode is a well known error code a payjoin receiver responds with.
+type ErrorCode string
+
+const (
+	// ErrUnavailable is returned if the receiver can't create a proposal
+	// at the moment.
+	ErrUnavailable ErrorCode = "unavailable"
+
+	// ErrNotEnoughMoney is returned if the receiver doesn't have enough
+	// funds for a proposal.
+	ErrNotEnoughMoney ErrorCode = "not-enough-money"
+
+	// ErrVersionUnsupported is returned if the receiver doesn't support
+	// the requested protocol version.
+	ErrVersionUnsupported ErrorCode = "version-unsupported"
+
+	// ErrOriginalPsbtRejected is returned if the receiver rejects the
+	// original PSBT.
+	ErrOriginalPsbtRejected ErrorCode = "original-psbt-rejected"
+)
+
+// ReceiverError is an error a payjoin receiver responds with.
+type ReceiverError struct {
+	// Code is the well known code of the error.
+	Code ErrorCode `json:"errorCode"`
+
+	// Message describes the error.
+	Message string `json:"message"`
+
+	// Supported lists the supported protocol versions if the code is
+	// ErrVersionUnsupported.
+	Supported []int `json:"supported,omitempty"`
+}
+
+// Error returns a human readable description of the error.
+//
+// NOTE: This is part of the error interface.
+func (e *ReceiverError) Error() string {
+	return fmt.Sprintf("payjoin receiver error %v: %v", e.Code, e.Message)
+}
+
+// newReceiverError creates a new receiver error with the given code.
+func newReceiverError(code ErrorCode, format string,
+	args ...interface{}) *ReceiverError {
+
+	return &ReceiverError{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Params are the parameters the sender passes to the receiver along with the
+// original PSBT.
+type Params struct {
+	// AdditionalFeeOutputIndex is the index of the output of the sender
+	// the receiver may deduct an additional fee from. It is -1 if there is
+	// no such output.
+	AdditionalFeeOutputIndex int
+
+	// MaxAdditionalFeeContribution is the maximum additional fee the
+	// sender is willing to pay for the inputs of the receiver.
+	MaxAdditionalFeeContribution btcutil.Amount
+
+	// MinFeeRate is the minimum fee rate of the proposal.
+	MinFeeRate chainfee.SatPerKWeight
+
+	// DisableOutputSubstitution forbids the receiver to change the
+	// payment output.
+	DisableOutputSubstitution bool
+}
+
+// encode returns the query parameters of the params.
+func (p *Params) encode() url.Values {
+	values := url.Values{}
+	values.Set("v", strconv.Itoa(Version))
+
+	if p.AdditionalFeeOutputIndex >= 0 {
+		values.Set(
+			"additionalfeeoutputindex",
+			strconv.Itoa(p.AdditionalFeeOutputIndex),
+		)
+		values.Set(
+			"maxadditionalfeecontribution",
+			strconv.FormatInt(
+				int64(p.MaxAdditionalFeeContribution), 10,
+			),
+		)
+	}
+
+	if p.MinFeeRate > 0 {
+		satPerVByte := float64(p.MinFeeRate.FeePerKVByte()) / 1000
+		values.Set(
+			"minfeerate",
+			strconv.FormatFloat(satPerVByte, 'f', -1, 64),
+		)
+	}
+
+	if p.DisableOutputSubstitution {
+		values.Set("disableoutputsubstitution", "true")
+	}
+
+	return values
+}
+
+// parseParams parses the query parameters the sender passed to the receiver.
+func parseParams(values url.Values) (*Params, error) {
+	if v := values.Get("v"); v != strconv.Itoa(Version) {
+		return nil, &ReceiverError{
+			Code: ErrVersionUnsupported,
+			Message: fmt.Sprintf("version %q is not supported",
+				v),
+			Supported: []int{Version},
+		}
+	}
+
+	params := &Params{
+		AdditionalFeeOutputIndex: -1,
+	}
+
+	if index := values.Get("additionalfeeoutputindex"); index != "" {
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 {
+			return nil, newReceiverError(
+				ErrOriginalPsbtRejected, "invalid "+
+					"additionalfeeoutputindex %q", index,
+			)
+		}
+		params.AdditionalFeeOutputIndex = i
+
+		maxFee := values.Get("maxadditionalfeecontribution")
+		fee, err := strconv.ParseInt(maxFee, 10, 64)
+		if err != nil || fee < 0 {
+			return nil, newReceiverError(
+				ErrOriginalPsbtRejected, "invalid "+
+					"maxadditionalfeecontribution %q",
+				maxFee,
+			)
+		}
+		params.MaxAdditionalFeeContribution = btcutil.Amount(fee)
+	}
+
+	if minFeeRate := values.Get("minfeerate"); minFeeRate != "" {
+		satPerVByte, err := strconv.ParseFloat(minFeeRate, 64)
+		if err != nil || satPerVByte < 0 {
+			return nil, newReceiverError(
+				ErrOriginalPsbtRejected, "invalid minfeerate "+
+					"%q", minFeeRate,
+			)
+		}
+		params.MinFeeRate = chainfee.SatPerKVByte(
+			satPerVByte * 1000,
+		).FeePerKWeight()
+	}
+
+	params.DisableOutputSubstitution =
+		values.Get("disableoutputsubstitution") == "true"
+
+	return params, nil
+}
+
+// InputWeight returns the weight an input that spends an output with the given
+// script adds to a transaction that already has witness inputs. Only the
+// script types of the wallet are supported.
+func InputWeight(pkScript []byte) (int64, error) {
+	var base input.TxWeightEstimator
+	base.AddP2WKHInput()
+
+	withInput := base
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.WitnessV0PubKeyHashTy:
+		withInput.AddP2WKHInput()
+
+	case txscript.ScriptHashTy:
+		withInput.AddNestedP2WKHInput()
+
+	case txscript.WitnessV1TaprootTy:
+		withInput.AddTaprootKeySpendInput(txscript.SigHashDefault)
+
+	default:
+		return 0, fmt.Errorf("unsupported input script %x", pkScript)
+	}
+
+	return int64(withInput.Weight() - base.Weight()), nil
+}
+
+// inputUtxo returns the output the input with the given index of the packet
+// spends.
+func inputUtxo(packet *psbt.Packet, idx int) (*wire.TxOut, error) {
+	in := packet.Inputs[idx]
+	switch {
+	case in.WitnessUtxo != nil:
+		return in.WitnessUtxo, nil
+
+	case in.NonWitnessUtxo != nil:
+		prevOut := packet.UnsignedTx.TxIn[idx].PreviousOutPoint
+		if in.NonWitnessUtxo.TxHash() != prevOut.Hash ||
+			int(prevOut.Index) >= len(in.NonWitnessUtxo.TxOut) {
+
+			return nil, fmt.Errorf("input %d has invalid UTXO "+
+				"information", idx)
+		}
+
+		return in.NonWitnessUtxo.TxOut[prevOut.Index], nil
+
+	default:
+		return nil, fmt.Errorf("input %d has no UTXO information", idx)
+	}
+}
+
+// fee returns the fee the packet pays, which is the value of its inputs minus
+// the value of its outputs.
+func fee(packet *psbt.Packet) (btcutil.Amount, error) {
+	var total int64
+	for idx := range packet.UnsignedTx.TxIn {
+		utxo, err := inputUtxo(packet, idx)
+		if err != nil {
+			return 0, err
+		}
+
+		total += utxo.Value
+	}
+
+	for _, out := range packet.UnsignedTx.TxOut {
+		total -= out.Value
+	}
+
+	if total < 0 {
+		return 0, fmt.Errorf("outputs exceed inputs by %v",
+			btcutil.Amount(-total))
+	}
+
+	return btcutil.Amount(total), nil
+}
+
+// isFinalized returns true if the input with the given index of the packet
+// has a final script.
+func isFinalized(packet *psbt.Packet, idx int) bool {
+	in := packet.Inputs[idx]
+
+	return len(in.FinalScriptSig) > 0 || len(in.FinalScriptWitness) > 0
+}
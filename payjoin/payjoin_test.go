@@ -0,0 +1,539 @@
+package payjoin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	base "github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/stretchr/testify/require"
+)
+
+// mockWallet is a wallet that owns a fixed set of P2WKH outputs and signs them
+// with dummy witnesses.
+type mockWallet struct {
+	mu        sync.Mutex
+	utxos     map[wire.OutPoint]*lnwallet.Utxo
+	leased    map[wire.OutPoint]struct{}
+	published chan *wire.MsgTx
+}
+
+func newMockWallet(utxos ...*lnwallet.Utxo) *mockWallet {
+	w := &mockWallet{
+		utxos:     make(map[wire.OutPoint]*lnwallet.Utxo),
+		leased:    make(map[wire.OutPoint]struct{}),
+		published: make(chan *wire.MsgTx, 1),
+	}
+	for _, utxo := range utxos {
+		w.utxos[utxo.OutPoint] = utxo
+	}
+
+	return w
+}
+
+func (w *mockWallet) FetchInputInfo(
+	prevOut *wire.OutPoint) (*lnwallet.Utxo, error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	utxo, ok := w.utxos[*prevOut]
+	if !ok {
+		return nil, lnwallet.ErrNotMine
+	}
+
+	return utxo, nil
+}
+
+func (w *mockWallet) ListUnspentWitness(_, _ int32,
+	_ string) ([]*lnwallet.Utxo, error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	utxos := make([]*lnwallet.Utxo, 0, len(w.utxos))
+	for _, utxo := range w.utxos {
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos, nil
+}
+
+func (w *mockWallet) ListLeasedOutputs() ([]*base.ListLeasedOutputResult,
+	error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	leases := make([]*base.ListLeasedOutputResult, 0, len(w.leased))
+	for op := range w.leased {
+		leases = append(leases, &base.ListLeasedOutputResult{
+			LockedOutput: &wtxmgr.LockedOutput{Outpoint: op},
+		})
+	}
+
+	return leases, nil
+}
+
+func (w *mockWallet) LeaseOutput(_ wtxmgr.LockID, op wire.OutPoint,
+	_ time.Duration) (time.Time, []byte, btcutil.Amount, error) {
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.leased[op]; ok {
+		return time.Time{}, nil, 0, wtxmgr.ErrOutputAlreadyLocked
+	}
+	w.leased[op] = struct{}{}
+
+	return time.Now(), nil, 0, nil
+}
+
+func (w *mockWallet) ReleaseOutput(_ wtxmgr.LockID, op wire.OutPoint) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.leased, op)
+
+	return nil
+}
+
+func (w *mockWallet) FinalizePsbt(packet *psbt.Packet, _ string) error {
+	for idx, txIn := range packet.UnsignedTx.TxIn {
+		if len(packet.Inputs[idx].FinalScriptWitness) > 0 {
+			continue
+		}
+
+		_, err := w.FetchInputInfo(&txIn.PreviousOutPoint)
+		if err != nil {
+			return err
+		}
+
+		packet.Inputs[idx].FinalScriptWitness = dummyWitness()
+	}
+
+	return nil
+}
+
+func (w *mockWallet) PublishTransaction(tx *wire.MsgTx, _ string) error {
+	w.published <- tx
+	return nil
+}
+
+// mockLocker is a coin selection locker without concurrent coin selections.
+type mockLocker struct{}
+
+func (m *mockLocker) WithCoinSelectLock(f func() error) error {
+	return f()
+}
+
+// p2wkhScript returns a P2WKH script with a key hash that consists of the
+// given byte.
+func p2wkhScript(b byte) []byte {
+	return append([]byte{0x00, 0x14}, bytes.Repeat([]byte{b}, 20)...)
+}
+
+// dummyWitness returns a serialized P2WKH witness of the maximum size.
+func dummyWitness() []byte {
+	var buf bytes.Buffer
+	_ = psbt.WriteTxWitness(&buf, [][]byte{
+		make([]byte, 73), make([]byte, 33),
+	})
+
+	return buf.Bytes()
+}
+
+// testContext is a payjoin receiver that is served over HTTPS, along with the
+// original PSBT of a sender that pays to it.
+type testContext struct {
+	wallet   *mockWallet
+	receiver *Receiver
+	endpoint *url.URL
+	server   *httptest.Server
+
+	original    *psbt.Packet
+	payeeScript []byte
+	params      *Params
+}
+
+// newTestContext creates a receiver that owns the given outputs and an
+// original PSBT that pays 50k sats to the receiver.
+func newTestContext(t *testing.T, cfg *ReceiverConfig,
+	utxos ...*lnwallet.Utxo) *testContext {
+
+	wallet := newMockWallet(utxos...)
+	cfg.Wallet = wallet
+	cfg.CoinSelectionLocker = &mockLocker{}
+
+	receiver := NewReceiver(cfg)
+	t.Cleanup(receiver.Stop)
+
+	server := httptest.NewTLSServer(receiver)
+	t.Cleanup(server.Close)
+
+	endpoint, err := url.Parse(server.URL + "/pj")
+	require.NoError(t, err)
+
+	payeeScript := p2wkhScript(0x01)
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		payeeScript[2:], &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	require.NoError(t, receiver.AddRequest(&PaymentRequest{
+		Address: addr,
+		Amount:  50_000,
+	}))
+
+	tx := wire.NewMsgTx(2)
+	tx.LockTime = 100
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 1},
+		Sequence:         wire.MaxTxInSequenceNum - 2,
+	})
+	tx.AddTxOut(&wire.TxOut{Value: 50_000, PkScript: payeeScript})
+	tx.AddTxOut(&wire.TxOut{Value: 49_000, PkScript: p2wkhScript(0x02)})
+
+	original, err := psbt.NewFromUnsignedTx(tx)
+	require.NoError(t, err)
+	original.Inputs[0].WitnessUtxo = &wire.TxOut{
+		Value:    100_000,
+		PkScript: p2wkhScript(0x03),
+	}
+	original.Inputs[0].FinalScriptWitness = dummyWitness()
+
+	originalWeight, err := finalizedWeight(original)
+	require.NoError(t, err)
+	feeRate := chainfee.SatPerKWeight(1_000 * 1000 / originalWeight)
+
+	inputWeight, err := InputWeight(p2wkhScript(0x00))
+	require.NoError(t, err)
+
+	return &testContext{
+		wallet:      wallet,
+		receiver:    receiver,
+		endpoint:    endpoint,
+		server:      server,
+		original:    original,
+		payeeScript: payeeScript,
+		params: &Params{
+			AdditionalFeeOutputIndex: 1,
+			MaxAdditionalFeeContribution: feeRate.FeeForWeight(
+				inputWeight,
+			),
+			MinFeeRate:                feeRate,
+			DisableOutputSubstitution: true,
+		},
+	}
+}
+
+// requestProposal requests a proposal for the original PSBT.
+func (c *testContext) requestProposal() (*psbt.Packet, error) {
+	return RequestProposal(
+		context.Background(), c.server.Client(), c.endpoint,
+		c.original, c.params,
+	)
+}
+
+// receiverUtxo returns an output of the receiver with the given value.
+func receiverUtxo(index uint32, value btcutil.Amount) *lnwallet.Utxo {
+	return &lnwallet.Utxo{
+		AddressType: lnwallet.WitnessPubKey,
+		Value:       value,
+		PkScript:    p2wkhScript(0x04),
+		OutPoint: wire.OutPoint{
+			Hash:  [32]byte{0x04},
+			Index: index,
+		},
+	}
+}
+
+// TestPayjoin tests that the sender accepts the proposal of an honest
+// receiver and that the fee is split as agreed on.
+func TestPayjoin(t *testing.T) {
+	t.Parallel()
+
+	ctx := newTestContext(
+		t, &ReceiverConfig{}, receiverUtxo(0, 30_000),
+		receiverUtxo(1, 1_000_000),
+	)
+
+	proposal, err := ctx.requestProposal()
+	require.NoError(t, err)
+	require.NoError(t, CheckProposal(
+		ctx.original, proposal, ctx.payeeScript, ctx.params,
+	))
+
+	// The smallest input of the receiver must have been added and leased.
+	require.Len(t, proposal.UnsignedTx.TxIn, 2)
+	require.Contains(t, ctx.wallet.leased, receiverUtxo(0, 0).OutPoint)
+
+	var receiverIdx, senderIdx int
+	for idx, txIn := range proposal.UnsignedTx.TxIn {
+		if txIn.PreviousOutPoint == receiverUtxo(0, 0).OutPoint {
+			receiverIdx = idx
+		} else {
+			senderIdx = idx
+		}
+	}
+	require.NotEmpty(t, proposal.Inputs[receiverIdx].FinalScriptWitness)
+	require.Empty(t, proposal.Inputs[senderIdx].FinalScriptWitness)
+	require.Nil(t, proposal.Inputs[senderIdx].WitnessUtxo)
+
+	// The sender pays the maximum additional fee, the receiver gets the
+	// rest of its input.
+	outputs := proposal.UnsignedTx.TxOut
+	senderFee := ctx.params.MaxAdditionalFeeContribution
+	require.EqualValues(t, 49_000-senderFee, outputs[1].Value)
+	require.Greater(t, outputs[0].Value, int64(50_000))
+
+	// After restoring the sender inputs, the proposal can be signed.
+	RestoreSenderInputs(ctx.original, proposal)
+	require.NotNil(t, proposal.Inputs[senderIdx].WitnessUtxo)
+
+	// The same request can't be paid twice.
+	_, err = ctx.requestProposal()
+	var receiverErr *ReceiverError
+	require.ErrorAs(t, err, &receiverErr)
+	require.Equal(t, ErrOriginalPsbtRejected, receiverErr.Code)
+}
+
+// TestCheckProposal tests that the sender rejects proposals of a malicious
+// receiver.
+func TestCheckProposal(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		modify      func(*psbt.Packet)
+		expectedErr string
+	}{
+		{
+			name: "payment output decreased",
+			modify: func(p *psbt.Packet) {
+				for _, out := range p.UnsignedTx.TxOut {
+					if out.Value > 50_000 {
+						out.Value = 49_000
+					}
+				}
+			},
+			expectedErr: "decreased the payment output",
+		},
+		{
+			name: "additional fee too high",
+			modify: func(p *psbt.Packet) {
+				p.UnsignedTx.TxOut[1].Value -= 1_000
+			},
+			expectedErr: "more than the maximum",
+		},
+		{
+			name: "sequence changed",
+			modify: func(p *psbt.Packet) {
+				p.UnsignedTx.TxIn[0].Sequence = 0
+			},
+			expectedErr: "different sequence",
+		},
+		{
+			name: "locktime changed",
+			modify: func(p *psbt.Packet) {
+				p.UnsignedTx.LockTime = 0
+			},
+			expectedErr: "lock time",
+		},
+		{
+			name: "receiver input not finalized",
+			modify: func(p *psbt.Packet) {
+				for idx := range p.Inputs {
+					p.Inputs[idx].FinalScriptWitness = nil
+				}
+			},
+			expectedErr: "is not finalized",
+		},
+		{
+			name: "sender input removed",
+			modify: func(p *psbt.Packet) {
+				for idx, in := range p.Inputs {
+					if in.FinalScriptWitness != nil {
+						continue
+					}
+
+					tx := p.UnsignedTx
+					tx.TxIn = append(
+						tx.TxIn[:idx],
+						tx.TxIn[idx+1:]...,
+					)
+					p.Inputs = append(
+						p.Inputs[:idx],
+						p.Inputs[idx+1:]...,
+					)
+
+					return
+				}
+			},
+			expectedErr: "missing inputs of the sender",
+		},
+		{
+			name: "output removed",
+			modify: func(p *psbt.Packet) {
+				p.UnsignedTx.TxOut = p.UnsignedTx.TxOut[:1]
+				p.Outputs = p.Outputs[:1]
+			},
+			expectedErr: "missing original output",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := newTestContext(
+				t, &ReceiverConfig{}, receiverUtxo(0, 30_000),
+			)
+
+			proposal, err := ctx.requestProposal()
+			require.NoError(t, err)
+
+			tc.modify(proposal)
+
+			err = CheckProposal(
+				ctx.original, proposal, ctx.payeeScript,
+				ctx.params,
+			)
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
+// TestReceiverRejections tests the errors the receiver responds with.
+func TestReceiverRejections(t *testing.T) {
+	t.Parallel()
+
+	// A receiver without inputs is unavailable.
+	ctx := newTestContext(t, &ReceiverConfig{})
+	_, err := ctx.requestProposal()
+	var receiverErr *ReceiverError
+	require.ErrorAs(t, err, &receiverErr)
+	require.Equal(t, ErrUnavailable, receiverErr.Code)
+
+	// A receiver that can't broadcast the original transaction rejects
+	// it.
+	ctx = newTestContext(t, &ReceiverConfig{
+		CheckMempoolAcceptance: func(*wire.MsgTx) error {
+			return errors.New("missing inputs")
+		},
+	}, receiverUtxo(0, 30_000))
+	_, err = ctx.requestProposal()
+	require.ErrorAs(t, err, &receiverErr)
+	require.Equal(t, ErrOriginalPsbtRejected, receiverErr.Code)
+
+	// The receiver never pays itself with its own inputs.
+	ctx = newTestContext(t, &ReceiverConfig{}, &lnwallet.Utxo{
+		AddressType: lnwallet.WitnessPubKey,
+		Value:       100_000,
+		PkScript:    p2wkhScript(0x03),
+		OutPoint:    wire.OutPoint{Index: 1},
+	})
+	_, err = ctx.requestProposal()
+	require.ErrorAs(t, err, &receiverErr)
+	require.Equal(t, ErrOriginalPsbtRejected, receiverErr.Code)
+
+	// An unknown protocol version is rejected with the supported ones.
+	_, err = parseParams(url.Values{"v": []string{"2"}})
+	require.ErrorAs(t, err, &receiverErr)
+	require.Equal(t, ErrVersionUnsupported, receiverErr.Code)
+	require.Equal(t, []int{Version}, receiverErr.Supported)
+}
+
+// TestPayjoinChannelFunding tests that the inputs of the receiver pay for a
+// funding output that is added to the proposal.
+func TestPayjoinChannelFunding(t *testing.T) {
+	t.Parallel()
+
+	fundingOutput := &wire.TxOut{
+		Value:    40_000,
+		PkScript: append([]byte{0x00, 0x20}, make([]byte, 32)...),
+	}
+
+	var funded *psbt.Packet
+	ctx := newTestContext(t, &ReceiverConfig{
+		FundChannel: func(_ [32]byte, packet *psbt.Packet) error {
+			funded = packet
+			return nil
+		},
+	}, receiverUtxo(0, 30_000), receiverUtxo(1, 25_000))
+
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(
+		p2wkhScript(0x05)[2:], &chaincfg.RegressionNetParams,
+	)
+	require.NoError(t, err)
+	require.NoError(t, ctx.receiver.AddRequest(&PaymentRequest{
+		Address: addr,
+		ChannelFunding: &ChannelFunding{
+			Output: fundingOutput,
+		},
+	}))
+	ctx.original.UnsignedTx.TxOut[0].PkScript = p2wkhScript(0x05)
+	ctx.payeeScript = p2wkhScript(0x05)
+
+	proposal, err := ctx.requestProposal()
+	require.NoError(t, err)
+	require.NoError(t, CheckProposal(
+		ctx.original, proposal, ctx.payeeScript, ctx.params,
+	))
+
+	// Both inputs of the receiver are needed for the funding output.
+	require.NotNil(t, funded)
+	require.Len(t, proposal.UnsignedTx.TxIn, 3)
+	require.Len(t, proposal.UnsignedTx.TxOut, 3)
+	require.Len(t, proposal.Outputs, 3)
+
+	var foundFunding bool
+	for _, out := range proposal.UnsignedTx.TxOut {
+		if bytes.Equal(out.PkScript, fundingOutput.PkScript) {
+			require.Equal(t, fundingOutput.Value, out.Value)
+			foundFunding = true
+		}
+	}
+	require.True(t, foundFunding)
+}
+
+// TestBroadcastOriginal tests that the receiver broadcasts the original
+// transaction if the proposal isn't broadcast.
+func TestBroadcastOriginal(t *testing.T) {
+	t.Parallel()
+
+	ctx := newTestContext(t, &ReceiverConfig{
+		BroadcastOriginalDelay: 10 * time.Millisecond,
+	}, receiverUtxo(0, 30_000))
+
+	_, err := ctx.requestProposal()
+	require.NoError(t, err)
+
+	select {
+	case tx := <-ctx.wallet.published:
+		require.Equal(
+			t, ctx.original.UnsignedTx.TxHash(), tx.TxHash(),
+		)
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("original transaction not broadcast")
+	}
+
+	// The input of the receiver must have been released again.
+	leases, err := ctx.wallet.ListLeasedOutputs()
+	require.NoError(t, err)
+	require.Empty(t, leases)
+}
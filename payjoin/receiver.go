@@ -0,0 +1,914 @@
+package payjoin
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	base "github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
+)
+
+const (
+	// DefaultBroadcastOriginalDelay is the default time the receiver waits
+	// for the sender to broadcast the payjoin transaction before it
+	// broadcasts the original transaction itself.
+	DefaultBroadcastOriginalDelay = 2 * time.Minute
+
+	// leaseDuration is the duration the inputs the receiver contributes to
+	// a proposal are leased for.
+	leaseDuration = 10 * time.Minute
+
+	// maxReceiverInputs is the maximum number of inputs the receiver
+	// contributes to fund a channel.
+	maxReceiverInputs = 10
+)
+
+var (
+	// LockID is the binary representation of the SHA256 hash of the
+	// string "lnd-payjoin" and is used for the UTXO leases of the inputs
+	// the receiver contributes to payjoin proposals.
+	LockID = wtxmgr.LockID(sha256.Sum256([]byte("lnd-payjoin")))
+
+	// ErrRequestExists is returned if a payment request for the same
+	// address is already registered.
+	ErrRequestExists = errors.New("payment request for address already " +
+		"exists")
+)
+
+// Wallet is the subset of the wallet the receiver needs to contribute and sign
+// inputs.
+type Wallet interface {
+	// FetchInputInfo returns the UTXO the given outpoint references if it
+	// belongs to the wallet.
+	FetchInputInfo(prevOut *wire.OutPoint) (*lnwallet.Utxo, error)
+
+	// ListUnspentWitness returns the unspent witness outputs of the given
+	// account that have the given number of confirmations.
+	ListUnspentWitness(minConfs, maxConfs int32,
+		accountFilter string) ([]*lnwallet.Utxo, error)
+
+	// ListLeasedOutputs returns all currently leased outputs.
+	ListLeasedOutputs() ([]*base.ListLeasedOutputResult, error)
+
+	// LeaseOutput leases an output to the given ID.
+	LeaseOutput(id wtxmgr.LockID, op wire.OutPoint,
+		duration time.Duration) (time.Time, []byte, btcutil.Amount,
+		error)
+
+	// ReleaseOutput releases the lease of an output.
+	ReleaseOutput(id wtxmgr.LockID, op wire.OutPoint) error
+
+	// FinalizePsbt signs and finalizes all inputs of the packet that
+	// belong to the given account.
+	FinalizePsbt(packet *psbt.Packet, account string) error
+
+	// PublishTransaction broadcasts the transaction.
+	PublishTransaction(tx *wire.MsgTx, label string) error
+}
+
+// ReceiverConfig holds the dependencies of the receiver.
+type ReceiverConfig struct {
+	// Wallet is the wallet that contributes and signs the inputs of the
+	// receiver.
+	Wallet Wallet
+
+	// CoinSelectionLocker guards the coin selection of the receiver
+	// against concurrent coin selections of the wallet.
+	CoinSelectionLocker chanfunding.CoinSelectionLocker
+
+	// CheckMempoolAcceptance checks that the original transaction would be
+	// accepted to the mempool, so it can be broadcast as a fallback. It is
+	// optional as not all chain backends support it.
+	CheckMempoolAcceptance func(tx *wire.MsgTx) error
+
+	// FundChannel hands the proposal to the pending channel with the given
+	// ID. It must return once the funding flow of the channel continues
+	// with the proposal as funding transaction. It is only needed for
+	// payment requests that fund a channel.
+	FundChannel func(pendingChanID [32]byte, packet *psbt.Packet) error
+
+	// BroadcastOriginalDelay is the time the receiver waits for the sender
+	// to broadcast the payjoin transaction before it broadcasts the
+	// original transaction itself. DefaultBroadcastOriginalDelay is used
+	// if it isn't set.
+	BroadcastOriginalDelay time.Duration
+}
+
+// ChannelFunding describes a channel that is funded by a payjoin.
+type ChannelFunding struct {
+	// PendingChanID is the ID of the pending channel that was opened with
+	// a PSBT funding shim that doesn't publish the funding transaction.
+	PendingChanID [32]byte
+
+	// Output is the funding output of the channel.
+	Output *wire.TxOut
+}
+
+// PaymentRequest is a payment the receiver expects. Each payment request can
+// only be paid once.
+type PaymentRequest struct {
+	// Address is the address the sender pays to.
+	Address btcutil.Address
+
+	// Amount is the minimum amount the sender must pay. It is ignored if
+	// it is zero.
+	Amount btcutil.Amount
+
+	// Account is the wallet account the inputs of the receiver are taken
+	// from.
+	Account string
+
+	// Label is the label of the original transaction if the receiver has
+	// to broadcast it.
+	Label string
+
+	// ChannelFunding is set if the payjoin funds a channel. The inputs of
+	// the receiver then pay for the funding output.
+	ChannelFunding *ChannelFunding
+
+	// Expiry is the time after which the request can't be paid with a
+	// payjoin anymore. It is ignored if it is zero.
+	Expiry time.Time
+}
+
+// Receiver contributes inputs to the original PSBTs of payjoin senders.
+type Receiver struct {
+	cfg *ReceiverConfig
+
+	mu       sync.Mutex
+	requests map[string]*PaymentRequest
+	timers   map[chainhash.Hash]*time.Timer
+
+	wg sync.WaitGroup
+}
+
+// NewReceiver creates a new payjoin receiver.
+func NewReceiver(cfg *ReceiverConfig) *Receiver {
+	if cfg.BroadcastOriginalDelay == 0 {
+		cfg.BroadcastOriginalDelay = DefaultBroadcastOriginalDelay
+	}
+
+	return &Receiver{
+		cfg:      cfg,
+		requests: make(map[string]*PaymentRequest),
+		timers:   make(map[chainhash.Hash]*time.Timer),
+	}
+}
+
+// Stop stops all pending broadcasts of original transactions.
+func (r *Receiver) Stop() {
+	r.mu.Lock()
+	for txid, timer := range r.timers {
+		if timer.Stop() {
+			r.wg.Done()
+		}
+		delete(r.timers, txid)
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// AddRequest registers a payment request the receiver creates payjoin
+// proposals for.
+func (r *Receiver) AddRequest(req *PaymentRequest) error {
+	pkScript, err := txscript.PayToAddrScript(req.Address)
+	if err != nil {
+		return err
+	}
+
+	if req.ChannelFunding != nil {
+		if r.cfg.FundChannel == nil {
+			return fmt.Errorf("channel funding is not supported")
+		}
+		if req.ChannelFunding.Output == nil {
+			return fmt.Errorf("channel funding output missing")
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.requests[string(pkScript)]; ok {
+		return ErrRequestExists
+	}
+	r.requests[string(pkScript)] = req
+
+	return nil
+}
+
+// ServeHTTP handles the original PSBTs that payjoin senders post.
+//
+// NOTE: This is part of the http.Handler interface.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodySize))
+	if err != nil {
+		writeError(w, newReceiverError(
+			ErrOriginalPsbtRejected, "unable to read body",
+		))
+		return
+	}
+
+	params, err := parseParams(req.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	original, err := psbt.NewFromRawBytes(
+		bytes.NewReader(bytes.TrimSpace(body)), true,
+	)
+	if err != nil {
+		writeError(w, newReceiverError(
+			ErrOriginalPsbtRejected, "invalid PSBT: %v", err,
+		))
+		return
+	}
+
+	proposal, err := r.ProcessOriginal(original, params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b64, err := proposal.B64Encode()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	if _, err := io.WriteString(w, b64); err != nil {
+		log.Errorf("Unable to write payjoin proposal: %v", err)
+	}
+}
+
+// writeError writes the error as a JSON response. Errors that aren't receiver
+// errors are only logged, the sender gets an unavailable error instead.
+func writeError(w http.ResponseWriter, err error) {
+	var receiverErr *ReceiverError
+	if !errors.As(err, &receiverErr) {
+		log.Errorf("Unable to create payjoin proposal: %v", err)
+
+		receiverErr = newReceiverError(
+			ErrUnavailable, "unable to create proposal",
+		)
+	} else {
+		log.Debugf("Rejecting payjoin request: %v", receiverErr)
+	}
+
+	status := http.StatusBadRequest
+	if receiverErr.Code == ErrUnavailable {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(receiverErr); err != nil {
+		log.Errorf("Unable to write payjoin error: %v", err)
+	}
+}
+
+// ProcessOriginal checks the original PSBT of a sender and creates a payjoin
+// proposal with inputs of the receiver. If the sender doesn't broadcast the
+// proposal in time, the receiver broadcasts the original transaction.
+func (r *Receiver) ProcessOriginal(original *psbt.Packet,
+	params *Params) (*psbt.Packet, error) {
+
+	originalTx, inputType, err := r.checkOriginal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	paymentIdx, req, err := r.takeRequest(originalTx)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.AdditionalFeeOutputIndex >= len(originalTx.TxOut) ||
+		params.AdditionalFeeOutputIndex == paymentIdx {
+
+		return nil, newReceiverError(
+			ErrOriginalPsbtRejected, "invalid additional fee "+
+				"output index %d",
+			params.AdditionalFeeOutputIndex,
+		)
+	}
+
+	originalFee, err := fee(original)
+	if err != nil {
+		return nil, newReceiverError(
+			ErrOriginalPsbtRejected, "%v", err,
+		)
+	}
+	originalWeight, err := finalizedWeight(original)
+	if err != nil {
+		return nil, newReceiverError(
+			ErrOriginalPsbtRejected, "%v", err,
+		)
+	}
+
+	account := req.Account
+	if account == "" {
+		account = lnwallet.DefaultAccountName
+	}
+
+	// Channel funding transactions must have a stable transaction ID, so
+	// only native SegWit inputs are allowed.
+	if req.ChannelFunding != nil &&
+		inputType == lnwallet.NestedWitnessPubKey {
+
+		return nil, newReceiverError(
+			ErrUnavailable, "channel funding requires native "+
+				"SegWit inputs",
+		)
+	}
+
+	calc := &feeCalculator{
+		originalFee: originalFee,
+		originalFeeRate: chainfee.SatPerKWeight(
+			originalFee * 1000 / btcutil.Amount(originalWeight),
+		),
+		originalWeight: originalWeight,
+		params:         params,
+	}
+	if feeIdx := params.AdditionalFeeOutputIndex; feeIdx >= 0 {
+		calc.feeOutput = originalTx.TxOut[feeIdx]
+	}
+	if req.ChannelFunding != nil {
+		calc.fundingOutput = req.ChannelFunding.Output
+	}
+
+	var utxos []*lnwallet.Utxo
+	err = r.cfg.CoinSelectionLocker.WithCoinSelectLock(func() error {
+		var err error
+		utxos, err = r.selectInputs(account, inputType, calc)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	releaseInputs := func() {
+		r.releaseInputs(utxos)
+	}
+
+	proposal, err := buildProposal(
+		original, utxos, paymentIdx, calc, req.ChannelFunding,
+	)
+	if err != nil {
+		releaseInputs()
+		return nil, err
+	}
+
+	if req.ChannelFunding != nil {
+		err := r.cfg.FundChannel(
+			req.ChannelFunding.PendingChanID, proposal,
+		)
+		if err != nil {
+			releaseInputs()
+			return nil, fmt.Errorf("unable to fund channel: %w",
+				err)
+		}
+	}
+
+	if err := r.cfg.Wallet.FinalizePsbt(proposal, account); err != nil {
+		releaseInputs()
+		return nil, fmt.Errorf("unable to sign proposal: %w", err)
+	}
+
+	// The sender must sign its inputs again, so we remove everything but
+	// the inputs of the receiver. We also don't reveal any information
+	// about our outputs.
+	senderInputs := make(map[wire.OutPoint]struct{})
+	for _, txIn := range originalTx.TxIn {
+		senderInputs[txIn.PreviousOutPoint] = struct{}{}
+	}
+	for idx, txIn := range proposal.UnsignedTx.TxIn {
+		if _, ok := senderInputs[txIn.PreviousOutPoint]; ok {
+			proposal.Inputs[idx] = psbt.PInput{}
+			continue
+		}
+
+		in := &proposal.Inputs[idx]
+		in.Bip32Derivation = nil
+		in.TaprootBip32Derivation = nil
+		in.TaprootInternalKey = nil
+	}
+	for idx := range proposal.Outputs {
+		proposal.Outputs[idx] = psbt.POutput{}
+	}
+
+	r.scheduleBroadcast(originalTx, utxos, req.Label)
+
+	log.Infof("Created payjoin proposal %v for original transaction %v "+
+		"with %d inputs", proposal.UnsignedTx.TxHash(),
+		originalTx.TxHash(), len(utxos))
+
+	return proposal, nil
+}
+
+// checkOriginal checks that the original PSBT is fully signed, could be
+// broadcast and doesn't spend any of our inputs. It returns the original
+// transaction and the address type of its inputs.
+func (r *Receiver) checkOriginal(original *psbt.Packet) (*wire.MsgTx,
+	lnwallet.AddressType, error) {
+
+	reject := func(format string, args ...interface{}) (*wire.MsgTx,
+		lnwallet.AddressType, error) {
+
+		return nil, lnwallet.UnknownAddressType, newReceiverError(
+			ErrOriginalPsbtRejected, format, args...,
+		)
+	}
+
+	if len(original.Inputs) == 0 ||
+		len(original.Inputs) != len(original.UnsignedTx.TxIn) ||
+		len(original.Outputs) != len(original.UnsignedTx.TxOut) {
+
+		return reject("malformed PSBT")
+	}
+
+	inputType := lnwallet.UnknownAddressType
+	for idx, txIn := range original.UnsignedTx.TxIn {
+		if !isFinalized(original, idx) {
+			return reject("input %d is not finalized", idx)
+		}
+
+		utxo, err := inputUtxo(original, idx)
+		if err != nil {
+			return reject("%v", err)
+		}
+
+		addrType := addressType(utxo.PkScript)
+		switch {
+		case addrType == lnwallet.UnknownAddressType:
+			return reject("input %d is of an unsupported type",
+				idx)
+
+		case idx > 0 && addrType != inputType:
+			return reject("inputs are of mixed types")
+		}
+		inputType = addrType
+
+		// We'd be paying ourselves with the inputs of the sender if
+		// they belonged to us.
+		prevOut := txIn.PreviousOutPoint
+		if _, err := r.cfg.Wallet.FetchInputInfo(&prevOut); err == nil {
+			return reject("input %d belongs to the receiver", idx)
+		}
+	}
+
+	originalTx, err := psbt.Extract(original)
+	if err != nil {
+		return reject("unable to extract transaction: %v", err)
+	}
+
+	if r.cfg.CheckMempoolAcceptance != nil {
+		if err := r.cfg.CheckMempoolAcceptance(originalTx); err != nil {
+			return reject("transaction not accepted to mempool: %v",
+				err)
+		}
+	}
+
+	return originalTx, inputType, nil
+}
+
+// takeRequest finds the payment request the original transaction pays and
+// removes it, so it can't be used again. It returns the index of the payment
+// output along with the request.
+func (r *Receiver) takeRequest(originalTx *wire.MsgTx) (int, *PaymentRequest,
+	error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, out := range originalTx.TxOut {
+		req, ok := r.requests[string(out.PkScript)]
+		if !ok {
+			continue
+		}
+
+		if !req.Expiry.IsZero() && time.Now().After(req.Expiry) {
+			delete(r.requests, string(out.PkScript))
+
+			return 0, nil, newReceiverError(
+				ErrOriginalPsbtRejected, "payment request "+
+					"expired",
+			)
+		}
+
+		if btcutil.Amount(out.Value) < req.Amount {
+			return 0, nil, newReceiverError(
+				ErrOriginalPsbtRejected, "payment of %v is "+
+					"below the requested %v",
+				btcutil.Amount(out.Value), req.Amount,
+			)
+		}
+
+		delete(r.requests, string(out.PkScript))
+
+		return idx, req, nil
+	}
+
+	return 0, nil, newReceiverError(
+		ErrOriginalPsbtRejected, "no output pays to a payment request",
+	)
+}
+
+// selectInputs selects and leases the inputs the receiver contributes. Without
+// channel funding, a single input is selected that pays for its own fee. With
+// channel funding, inputs are selected until they pay for the funding output.
+//
+// NOTE: This method requires the coin selection lock to be held.
+func (r *Receiver) selectInputs(account string,
+	inputType lnwallet.AddressType,
+	calc *feeCalculator) ([]*lnwallet.Utxo, error) {
+
+	utxos, err := r.cfg.Wallet.ListUnspentWitness(
+		1, math.MaxInt32, account,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	leases, err := r.cfg.Wallet.ListLeasedOutputs()
+	if err != nil {
+		return nil, err
+	}
+	leased := make(map[wire.OutPoint]struct{}, len(leases))
+	for _, lease := range leases {
+		leased[lease.Outpoint] = struct{}{}
+	}
+
+	candidates := make([]*lnwallet.Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		if _, ok := leased[utxo.OutPoint]; ok {
+			continue
+		}
+		if utxo.AddressType != inputType {
+			continue
+		}
+
+		candidates = append(candidates, utxo)
+	}
+	if len(candidates) == 0 {
+		return nil, newReceiverError(
+			ErrUnavailable, "no inputs available",
+		)
+	}
+
+	inputWeight, err := InputWeight(candidates[0].PkScript)
+	if err != nil {
+		return nil, err
+	}
+	calc.inputWeight = inputWeight
+
+	var selected []*lnwallet.Utxo
+	if calc.fundingOutput == nil {
+		// Take the smallest input that pays for its share of the fee,
+		// so the payment output doesn't decrease.
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Value < candidates[j].Value
+		})
+
+		for _, utxo := range candidates {
+			if utxo.Value > calc.receiverFee(1) {
+				selected = []*lnwallet.Utxo{utxo}
+				break
+			}
+		}
+	} else {
+		// Take the largest inputs until they pay for the funding
+		// output and their share of the fee.
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Value > candidates[j].Value
+		})
+
+		var total btcutil.Amount
+		required := btcutil.Amount(calc.fundingOutput.Value)
+		for _, utxo := range candidates {
+			if len(selected) == maxReceiverInputs {
+				break
+			}
+
+			selected = append(selected, utxo)
+			total += utxo.Value
+
+			if total >= required+calc.receiverFee(len(selected)) {
+				break
+			}
+		}
+
+		if total < required+calc.receiverFee(len(selected)) {
+			selected = nil
+		}
+	}
+	if len(selected) == 0 {
+		return nil, newReceiverError(
+			ErrNotEnoughMoney, "not enough funds available",
+		)
+	}
+
+	for idx, utxo := range selected {
+		_, _, _, err := r.cfg.Wallet.LeaseOutput(
+			LockID, utxo.OutPoint, leaseDuration,
+		)
+		if err != nil {
+			r.releaseInputs(selected[:idx])
+
+			return nil, fmt.Errorf("unable to lease input %v: %w",
+				utxo.OutPoint, err)
+		}
+	}
+
+	return selected, nil
+}
+
+// releaseInputs releases the leases of the given inputs.
+func (r *Receiver) releaseInputs(utxos []*lnwallet.Utxo) {
+	for _, utxo := range utxos {
+		err := r.cfg.Wallet.ReleaseOutput(LockID, utxo.OutPoint)
+		if err != nil {
+			log.Warnf("Unable to release input %v: %v",
+				utxo.OutPoint, err)
+		}
+	}
+}
+
+// scheduleBroadcast broadcasts the original transaction after the broadcast
+// delay unless the inputs of the receiver have been spent by then, which means
+// the sender broadcast the payjoin transaction.
+func (r *Receiver) scheduleBroadcast(originalTx *wire.MsgTx,
+	utxos []*lnwallet.Utxo, label string) {
+
+	txid := originalTx.TxHash()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wg.Add(1)
+	r.timers[txid] = time.AfterFunc(r.cfg.BroadcastOriginalDelay, func() {
+		defer r.wg.Done()
+
+		r.mu.Lock()
+		delete(r.timers, txid)
+		r.mu.Unlock()
+
+		r.broadcastOriginal(originalTx, utxos, label)
+	})
+}
+
+// broadcastOriginal broadcasts the original transaction if the payjoin
+// transaction didn't spend the inputs of the receiver.
+func (r *Receiver) broadcastOriginal(originalTx *wire.MsgTx,
+	utxos []*lnwallet.Utxo, label string) {
+
+	err := r.cfg.CoinSelectionLocker.WithCoinSelectLock(func() error {
+		unspent, err := r.cfg.Wallet.ListUnspentWitness(
+			0, math.MaxInt32, "",
+		)
+		if err != nil {
+			return err
+		}
+
+		spent := true
+		for _, utxo := range unspent {
+			if utxo.OutPoint == utxos[0].OutPoint {
+				spent = false
+				break
+			}
+		}
+
+		if spent {
+			log.Debugf("Payjoin proposal for original "+
+				"transaction %v was broadcast",
+				originalTx.TxHash())
+
+			return nil
+		}
+
+		r.releaseInputs(utxos)
+
+		log.Infof("Payjoin proposal wasn't broadcast, broadcasting "+
+			"original transaction %v", originalTx.TxHash())
+
+		return r.cfg.Wallet.PublishTransaction(originalTx, label)
+	})
+	if err != nil {
+		log.Errorf("Unable to broadcast original transaction %v: %v",
+			originalTx.TxHash(), err)
+	}
+}
+
+// feeCalculator calculates how the additional fee of a proposal is split
+// between the sender and the receiver.
+type feeCalculator struct {
+	originalFee     btcutil.Amount
+	originalFeeRate chainfee.SatPerKWeight
+	originalWeight  int64
+	params          *Params
+
+	// feeOutput is the output of the sender the additional fee of the
+	// sender is deducted from. It is nil if the sender doesn't contribute
+	// to the fee.
+	feeOutput *wire.TxOut
+
+	// fundingOutput is the funding output of the channel the proposal
+	// funds, if any.
+	fundingOutput *wire.TxOut
+
+	// inputWeight is the weight of a single input of the receiver.
+	inputWeight int64
+}
+
+// additionalFee returns the fee the proposal pays on top of the original fee
+// if the receiver contributes the given number of inputs.
+func (c *feeCalculator) additionalFee(numInputs int) btcutil.Amount {
+	weight := c.originalWeight + int64(numInputs)*c.inputWeight
+	if c.fundingOutput != nil {
+		weight += int64(c.fundingOutput.SerializeSize()) * 4
+	}
+
+	feeRate := c.originalFeeRate
+	if c.params.MinFeeRate > feeRate {
+		feeRate = c.params.MinFeeRate
+	}
+
+	additionalFee := feeRate.FeeForWeight(weight) - c.originalFee
+	if additionalFee < 0 {
+		return 0
+	}
+
+	return additionalFee
+}
+
+// senderFee returns the part of the additional fee the sender pays if the
+// receiver contributes the given number of inputs. The sender only pays for
+// the inputs of the receiver at the original fee rate.
+func (c *feeCalculator) senderFee(numInputs int) btcutil.Amount {
+	if c.feeOutput == nil {
+		return 0
+	}
+
+	senderFee := c.originalFeeRate.FeeForWeight(
+		int64(numInputs) * c.inputWeight,
+	)
+	if senderFee > c.params.MaxAdditionalFeeContribution {
+		senderFee = c.params.MaxAdditionalFeeContribution
+	}
+	if additionalFee := c.additionalFee(numInputs); senderFee >
+		additionalFee {
+
+		senderFee = additionalFee
+	}
+
+	// The fee output must not become dust.
+	dustLimit := lnwallet.DustLimitForSize(len(c.feeOutput.PkScript))
+	available := btcutil.Amount(c.feeOutput.Value) - dustLimit
+	if senderFee > available {
+		senderFee = available
+	}
+	if senderFee < 0 {
+		return 0
+	}
+
+	return senderFee
+}
+
+// receiverFee returns the part of the additional fee the receiver pays if it
+// contributes the given number of inputs.
+func (c *feeCalculator) receiverFee(numInputs int) btcutil.Amount {
+	return c.additionalFee(numInputs) - c.senderFee(numInputs)
+}
+
+// buildProposal creates the unsigned proposal by adding the inputs of the
+// receiver and the funding output to the original PSBT at random positions.
+func buildProposal(original *psbt.Packet, utxos []*lnwallet.Utxo,
+	paymentIdx int, calc *feeCalculator,
+	funding *ChannelFunding) (*psbt.Packet, error) {
+
+	proposal := &psbt.Packet{
+		UnsignedTx: original.UnsignedTx.Copy(),
+		Inputs:     make([]psbt.PInput, len(original.Inputs)),
+		Outputs:    make([]psbt.POutput, len(original.Outputs)),
+	}
+	copy(proposal.Inputs, original.Inputs)
+
+	tx := proposal.UnsignedTx
+
+	// The inputs of the receiver pay for the payment output, the funding
+	// output and the share of the fee of the receiver.
+	var inputTotal btcutil.Amount
+	for _, utxo := range utxos {
+		inputTotal += utxo.Value
+	}
+	numInputs := len(utxos)
+	change := inputTotal - calc.receiverFee(numInputs)
+	if funding != nil {
+		change -= btcutil.Amount(funding.Output.Value)
+	}
+	if change < 0 {
+		return nil, fmt.Errorf("inputs don't pay for the proposal")
+	}
+	tx.TxOut[paymentIdx].Value += int64(change)
+
+	if feeIdx := calc.params.AdditionalFeeOutputIndex; feeIdx >= 0 {
+		tx.TxOut[feeIdx].Value -= int64(calc.senderFee(numInputs))
+	}
+
+	sequence := tx.TxIn[0].Sequence
+	for _, utxo := range utxos {
+		idx, err := randomIndex(len(tx.TxIn) + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		txIn := &wire.TxIn{
+			PreviousOutPoint: utxo.OutPoint,
+			Sequence:         sequence,
+		}
+		pIn := psbt.PInput{
+			WitnessUtxo: &wire.TxOut{
+				Value:    int64(utxo.Value),
+				PkScript: utxo.PkScript,
+			},
+		}
+
+		tx.TxIn = append(tx.TxIn[:idx], append(
+			[]*wire.TxIn{txIn}, tx.TxIn[idx:]...,
+		)...)
+		proposal.Inputs = append(proposal.Inputs[:idx], append(
+			[]psbt.PInput{pIn}, proposal.Inputs[idx:]...,
+		)...)
+	}
+
+	if funding != nil {
+		idx, err := randomIndex(len(tx.TxOut) + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		fundingOutput := &wire.TxOut{
+			Value:    funding.Output.Value,
+			PkScript: funding.Output.PkScript,
+		}
+		tx.TxOut = append(tx.TxOut[:idx], append(
+			[]*wire.TxOut{fundingOutput}, tx.TxOut[idx:]...,
+		)...)
+		proposal.Outputs = append(proposal.Outputs, psbt.POutput{})
+	}
+
+	return proposal, nil
+}
+
+// randomIndex returns a random index in [0, n).
+func randomIndex(n int) (int, error) {
+	idx, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(idx.Int64()), nil
+}
+
+// addressType returns the wallet address type of the given output script.
+func addressType(pkScript []byte) lnwallet.AddressType {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.WitnessV0PubKeyHashTy:
+		return lnwallet.WitnessPubKey
+
+	case txscript.ScriptHashTy:
+		return lnwallet.NestedWitnessPubKey
+
+	case txscript.WitnessV1TaprootTy:
+		return lnwallet.TaprootPubkey
+
+	default:
+		return lnwallet.UnknownAddressType
+	}
+}
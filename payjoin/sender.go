@@ -0,0 +1,353 @@
+package payjoin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+)
+
+// RequestProposal posts the signed original PSBT to the payjoin endpoint of
+// the receiver and returns the payjoin proposal the receiver responds with.
+// The proposal MUST be checked with CheckProposal before it is signed.
+func RequestProposal(ctx context.Context, client *http.Client,
+	endpoint *url.URL, original *psbt.Packet,
+	params *Params) (*psbt.Packet, error) {
+
+	body, err := original.B64Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	reqURL := *endpoint
+	query := reqURL.Query()
+	for key, values := range params.encode() {
+		query[key] = values
+	}
+	reqURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, reqURL.String(), strings.NewReader(body),
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("payjoin request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read payjoin response: %w",
+			err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var receiverErr ReceiverError
+		err := json.Unmarshal(respBody, &receiverErr)
+		if err == nil && receiverErr.Code != "" {
+			return nil, &receiverErr
+		}
+
+		return nil, fmt.Errorf("payjoin receiver responded with %v",
+			resp.Status)
+	}
+
+	proposal, err := psbt.NewFromRawBytes(
+		bytes.NewReader(bytes.TrimSpace(respBody)), true,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payjoin proposal: %w", err)
+	}
+
+	return proposal, nil
+}
+
+// CheckProposal checks that the payjoin proposal of the receiver only adds
+// inputs and outputs the receiver pays for, apart from the additional fee the
+// sender agreed on with the params. The original PSBT must be finalized and
+// all its inputs must be of the same type. The payment output of the original
+// PSBT must pay to the payee script. Output substitution isn't supported, so
+// the payment output must still be part of the proposal.
+func CheckProposal(original, proposal *psbt.Packet, payeeScript []byte,
+	params *Params) error {
+
+	originalTx := original.UnsignedTx
+	proposalTx := proposal.UnsignedTx
+
+	if proposalTx.Version != originalTx.Version {
+		return fmt.Errorf("proposal changed the transaction version")
+	}
+	if proposalTx.LockTime != originalTx.LockTime {
+		return fmt.Errorf("proposal changed the lock time")
+	}
+	if len(proposal.Inputs) != len(proposalTx.TxIn) ||
+		len(proposal.Outputs) != len(proposalTx.TxOut) {
+
+		return fmt.Errorf("proposal is malformed")
+	}
+
+	// All inputs of the sender must be of the same type, so the inputs of
+	// the receiver can't be told apart.
+	senderInputs := make(map[wire.OutPoint]int, len(originalTx.TxIn))
+	var inputClass txscript.ScriptClass
+	for idx, txIn := range originalTx.TxIn {
+		utxo, err := inputUtxo(original, idx)
+		if err != nil {
+			return err
+		}
+
+		class := txscript.GetScriptClass(utxo.PkScript)
+		if idx > 0 && class != inputClass {
+			return fmt.Errorf("original inputs are of mixed types")
+		}
+		inputClass = class
+
+		senderInputs[txIn.PreviousOutPoint] = idx
+	}
+	sequence := originalTx.TxIn[0].Sequence
+
+	var (
+		numSenderInputs     int
+		receiverInputWeight int64
+	)
+	for idx, txIn := range proposalTx.TxIn {
+		if txIn.Sequence != sequence {
+			return fmt.Errorf("proposal input %d has a different "+
+				"sequence", idx)
+		}
+
+		// The inputs of the sender must not be signed by the
+		// receiver.
+		if _, ok := senderInputs[txIn.PreviousOutPoint]; ok {
+			in := proposal.Inputs[idx]
+			if isFinalized(proposal, idx) ||
+				len(in.PartialSigs) > 0 ||
+				len(in.TaprootKeySpendSig) > 0 {
+
+				return fmt.Errorf("proposal input %d of the "+
+					"sender is signed", idx)
+			}
+
+			numSenderInputs++
+
+			continue
+		}
+
+		// The inputs of the receiver must be finalized, so we never
+		// sign them, and they must be of the same type as ours.
+		if !isFinalized(proposal, idx) {
+			return fmt.Errorf("proposal input %d of the receiver "+
+				"is not finalized", idx)
+		}
+
+		utxo, err := inputUtxo(proposal, idx)
+		if err != nil {
+			return err
+		}
+		if txscript.GetScriptClass(utxo.PkScript) != inputClass {
+			return fmt.Errorf("proposal input %d of the receiver "+
+				"is of a different type", idx)
+		}
+
+		weight, err := InputWeight(utxo.PkScript)
+		if err != nil {
+			return err
+		}
+		receiverInputWeight += weight
+	}
+	if numSenderInputs != len(senderInputs) {
+		return fmt.Errorf("proposal is missing inputs of the sender")
+	}
+	if receiverInputWeight == 0 {
+		return fmt.Errorf("proposal doesn't contain inputs of the " +
+			"receiver")
+	}
+
+	// All outputs of the original PSBT must be part of the proposal. Only
+	// the payment output may have a higher and the additional fee output
+	// a lower value.
+	proposalOutputs := make(map[string][]int64)
+	for _, out := range proposalTx.TxOut {
+		script := string(out.PkScript)
+		proposalOutputs[script] = append(
+			proposalOutputs[script], out.Value,
+		)
+	}
+
+	var senderContribution btcutil.Amount
+	for idx, out := range originalTx.TxOut {
+		script := string(out.PkScript)
+		values := proposalOutputs[script]
+		if len(values) == 0 {
+			return fmt.Errorf("proposal is missing original "+
+				"output %d", idx)
+		}
+		value := values[0]
+		proposalOutputs[script] = values[1:]
+
+		switch {
+		case bytes.Equal(out.PkScript, payeeScript):
+			if value < out.Value {
+				return fmt.Errorf("proposal decreased the " +
+					"payment output")
+			}
+
+		case idx == params.AdditionalFeeOutputIndex:
+			if value > out.Value {
+				return fmt.Errorf("proposal increased the " +
+					"additional fee output")
+			}
+			senderContribution = btcutil.Amount(out.Value - value)
+
+		case value != out.Value:
+			return fmt.Errorf("proposal changed original output "+
+				"%d", idx)
+		}
+	}
+
+	if senderContribution > params.MaxAdditionalFeeContribution {
+		return fmt.Errorf("proposal takes an additional fee of %v, "+
+			"more than the maximum of %v", senderContribution,
+			params.MaxAdditionalFeeContribution)
+	}
+
+	originalFee, err := fee(original)
+	if err != nil {
+		return err
+	}
+	originalWeight, err := finalizedWeight(original)
+	if err != nil {
+		return err
+	}
+	originalFeeRate := chainfee.SatPerKWeight(
+		originalFee * 1000 / btcutil.Amount(originalWeight),
+	)
+
+	// The additional fee of the sender may only pay for the inputs of
+	// the receiver.
+	maxContribution := originalFeeRate.FeeForWeight(receiverInputWeight)
+	if senderContribution > maxContribution {
+		return fmt.Errorf("proposal takes an additional fee of %v, "+
+			"more than the %v the inputs of the receiver cost",
+			senderContribution, maxContribution)
+	}
+
+	// Finally, the fee rate of the proposal must not be below the minimum
+	// we asked for. We estimate the weight of the signed proposal with the
+	// witnesses of the original inputs.
+	proposalFee, err := proposalFee(original, proposal)
+	if err != nil {
+		return err
+	}
+	proposalWeight, err := mergedWeight(original, proposal)
+	if err != nil {
+		return err
+	}
+	minFee := params.MinFeeRate.FeeForWeight(proposalWeight)
+	if proposalFee < minFee {
+		return fmt.Errorf("proposal fee %v is below the minimum fee "+
+			"%v", proposalFee, minFee)
+	}
+
+	return nil
+}
+
+// RestoreSenderInputs copies the UTXO information of the inputs of the sender
+// from the original PSBT to the proposal, so they can be signed.
+func RestoreSenderInputs(original, proposal *psbt.Packet) {
+	senderInputs := make(map[wire.OutPoint]int)
+	for idx, txIn := range original.UnsignedTx.TxIn {
+		senderInputs[txIn.PreviousOutPoint] = idx
+	}
+
+	for idx, txIn := range proposal.UnsignedTx.TxIn {
+		originalIdx, ok := senderInputs[txIn.PreviousOutPoint]
+		if !ok {
+			continue
+		}
+
+		in := original.Inputs[originalIdx]
+		proposal.Inputs[idx] = psbt.PInput{
+			NonWitnessUtxo: in.NonWitnessUtxo,
+			WitnessUtxo:    in.WitnessUtxo,
+			SighashType:    in.SighashType,
+		}
+	}
+}
+
+// proposalFee returns the fee of the proposal, using the UTXO information of
+// the original PSBT for the inputs of the sender.
+func proposalFee(original, proposal *psbt.Packet) (btcutil.Amount, error) {
+	merged, err := mergeProposal(original, proposal)
+	if err != nil {
+		return 0, err
+	}
+
+	return fee(merged)
+}
+
+// mergedWeight returns the weight of the proposal with the witnesses of the
+// original PSBT for the inputs of the sender.
+func mergedWeight(original, proposal *psbt.Packet) (int64, error) {
+	merged, err := mergeProposal(original, proposal)
+	if err != nil {
+		return 0, err
+	}
+
+	return finalizedWeight(merged)
+}
+
+// mergeProposal returns a copy of the proposal with the inputs of the sender
+// taken from the finalized original PSBT.
+func mergeProposal(original, proposal *psbt.Packet) (*psbt.Packet, error) {
+	senderInputs := make(map[wire.OutPoint]int)
+	for idx, txIn := range original.UnsignedTx.TxIn {
+		senderInputs[txIn.PreviousOutPoint] = idx
+	}
+
+	merged := &psbt.Packet{
+		UnsignedTx: proposal.UnsignedTx.Copy(),
+		Inputs:     make([]psbt.PInput, len(proposal.Inputs)),
+		Outputs:    proposal.Outputs,
+	}
+	for idx, txIn := range merged.UnsignedTx.TxIn {
+		originalIdx, ok := senderInputs[txIn.PreviousOutPoint]
+		if !ok {
+			merged.Inputs[idx] = proposal.Inputs[idx]
+
+			continue
+		}
+
+		merged.Inputs[idx] = original.Inputs[originalIdx]
+	}
+
+	return merged, nil
+}
+
+// finalizedWeight returns the weight of the transaction of a finalized PSBT.
+func finalizedWeight(packet *psbt.Packet) (int64, error) {
+	// Extract doesn't modify the packet, it builds a new transaction from
+	// the final scripts.
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return 0, fmt.Errorf("unable to extract transaction: %w", err)
+	}
+
+	return blockchain.GetTransactionWeight(btcutil.NewTx(tx)), nil
+}
@@ -0,0 +1,161 @@
+package payjoin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+const (
+	// uriScheme is the scheme of BIP 21 URIs.
+	uriScheme = "bitcoin:"
+)
+
+var (
+	// ErrNoEndpoint is returned if a BIP 21 URI doesn't have a payjoin
+	// endpoint.
+	ErrNoEndpoint = errors.New("URI has no payjoin endpoint")
+)
+
+// URI is a BIP 21 URI with the payjoin parameters of BIP 78.
+type URI struct {
+	// Address is the address to pay to.
+	Address btcutil.Address
+
+	// Amount is the amount to pay. It is zero if the URI doesn't specify
+	// an amount.
+	Amount btcutil.Amount
+
+	// Label is an optional label of the receiver.
+	Label string
+
+	// Message is an optional message of the receiver.
+	Message string
+
+	// Endpoint is the payjoin endpoint of the receiver.
+	Endpoint *url.URL
+
+	// DisableOutputSubstitution is set if the receiver must not change the
+	// payment output.
+	DisableOutputSubstitution bool
+}
+
+// ParseURI parses a BIP 21 URI with a payjoin endpoint for the given network.
+func ParseURI(uri string, params *chaincfg.Params) (*URI, error) {
+	if len(uri) < len(uriScheme) ||
+		!strings.EqualFold(uri[:len(uriScheme)], uriScheme) {
+
+		return nil, fmt.Errorf("URI must start with %v", uriScheme)
+	}
+	uri = uri[len(uriScheme):]
+
+	addrStr, query, _ := strings.Cut(uri, "?")
+	addr, err := btcutil.DecodeAddress(addrStr, params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %w", err)
+	}
+	if !addr.IsForNet(params) {
+		return nil, fmt.Errorf("address %v is not valid for network "+
+			"%v", addr, params.Name)
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URI parameters: %w", err)
+	}
+
+	result := &URI{
+		Address: addr,
+		Label:   values.Get("label"),
+		Message: values.Get("message"),
+	}
+
+	for key := range values {
+		// Required parameters we don't know must make the URI fail.
+		if strings.HasPrefix(key, "req-") {
+			return nil, fmt.Errorf("unsupported required "+
+				"parameter %v", key)
+		}
+	}
+
+	if amount := values.Get("amount"); amount != "" {
+		btc, err := strconv.ParseFloat(amount, 64)
+		if err != nil || btc <= 0 {
+			return nil, fmt.Errorf("invalid amount %q", amount)
+		}
+
+		result.Amount, err = btcutil.NewAmount(btc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount %q: %w", amount,
+				err)
+		}
+	}
+
+	endpoint := values.Get("pj")
+	if endpoint == "" {
+		return nil, ErrNoEndpoint
+	}
+	result.Endpoint, err = ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	result.DisableOutputSubstitution = values.Get("pjos") == "0"
+
+	return result, nil
+}
+
+// ParseEndpoint parses the URL of a payjoin endpoint. Endpoints must use HTTPS
+// unless they are onion services.
+func ParseEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payjoin endpoint: %w", err)
+	}
+
+	switch {
+	case u.Scheme == "https":
+
+	case u.Scheme == "http" && strings.HasSuffix(u.Hostname(), ".onion"):
+
+	default:
+		return nil, fmt.Errorf("payjoin endpoint %v must use https "+
+			"or be an onion service", endpoint)
+	}
+
+	return u, nil
+}
+
+// String returns the BIP 21 encoding of the URI.
+func (u *URI) String() string {
+	values := url.Values{}
+	if u.Amount != 0 {
+		values.Set(
+			"amount",
+			strconv.FormatFloat(u.Amount.ToBTC(), 'f', -1, 64),
+		)
+	}
+	if u.Label != "" {
+		values.Set("label", u.Label)
+	}
+	if u.Message != "" {
+		values.Set("message", u.Message)
+	}
+	if u.Endpoint != nil {
+		values.Set("pj", u.Endpoint.String())
+	}
+	if u.DisableOutputSubstitution {
+		values.Set("pjos", "0")
+	}
+
+	// The parameters are encoded with %20 for spaces as BIP 21 doesn't
+	// allow the + encoding of HTML forms.
+	query := strings.ReplaceAll(values.Encode(), "+", "%20")
+
+	return uriScheme + u.Address.String() + "?" + query
+}
@@ -0,0 +1,96 @@
+package payjoin
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/stretchr/testify/require"
+)
+
+const testAddr = "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"
+
+// TestParseURI tests the parsing of BIP 21 URIs with payjoin parameters.
+func TestParseURI(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.RegressionNetParams
+
+	testCases := []struct {
+		name        string
+		uri         string
+		amount      btcutil.Amount
+		endpoint    string
+		disableOS   bool
+		expectedErr string
+	}{
+		{
+			name: "valid",
+			uri: "bitcoin:" + testAddr + "?amount=0.0001&" +
+				"pj=https://example.com/pj",
+			amount:   10_000,
+			endpoint: "https://example.com/pj",
+		},
+		{
+			name: "uppercase scheme and pjos",
+			uri: "BITCOIN:" + testAddr + "?pj=http://abc.onion/" +
+				"&pjos=0",
+			endpoint:  "http://abc.onion/",
+			disableOS: true,
+		},
+		{
+			name:        "no endpoint",
+			uri:         "bitcoin:" + testAddr + "?amount=1",
+			expectedErr: ErrNoEndpoint.Error(),
+		},
+		{
+			name: "plain http endpoint",
+			uri: "bitcoin:" + testAddr + "?pj=http://example.com/" +
+				"pj",
+			expectedErr: "must use https",
+		},
+		{
+			name: "unknown required parameter",
+			uri: "bitcoin:" + testAddr + "?req-foo=1&" +
+				"pj=https://example.com/pj",
+			expectedErr: "unsupported required parameter",
+		},
+		{
+			name:        "wrong scheme",
+			uri:         "lightning:" + testAddr,
+			expectedErr: "URI must start with",
+		},
+		{
+			name: "wrong network",
+			uri: "bitcoin:bc1qw508d6qejxtdg4y5r3zarvary0c5xw7" +
+				"kv8f3t4?pj=https://example.com/pj",
+			expectedErr: "not valid for network",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			uri, err := ParseURI(tc.uri, params)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tc.amount, uri.Amount)
+			require.Equal(t, tc.endpoint, uri.Endpoint.String())
+			require.Equal(
+				t, tc.disableOS, uri.DisableOutputSubstitution,
+			)
+
+			// Encoding the URI again must result in the same URI.
+			decoded, err := ParseURI(uri.String(), params)
+			require.NoError(t, err)
+			require.Equal(t, uri, decoded)
+		})
+	}
+}
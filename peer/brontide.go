@@ -1790,6 +1790,9 @@ out:
 			*lnwire.ChannelAnnouncement,
 			*lnwire.NodeAnnouncement,
 			*lnwire.AnnounceSignatures,
+			*lnwire.ChannelUpdate2,
+			*lnwire.ChannelAnnouncement2,
+			*lnwire.AnnounceSignatures2,
 			*lnwire.GossipTimestampRange,
 			*lnwire.QueryShortChanIDs,
 			*lnwire.QueryChannelRange,
@@ -2061,6 +2064,24 @@ func messageSummary(msg lnwire.Message) string {
 			msg.ShortChannelID.ToUint64(), msg.MessageFlags,
 			msg.ChannelFlags, time.Unix(int64(msg.Timestamp), 0))
 
+	case *lnwire.AnnounceSignatures2:
+		return fmt.Sprintf("chan_id=%v, short_chan_id=%v, "+
+			"has_partial_sig=%v", msg.ChannelID,
+			msg.ShortChannelID.ToUint64(),
+			msg.PartialSignature.IsSome())
+
+	case *lnwire.ChannelAnnouncement2:
+		return fmt.Sprintf("chain_hash=%v, short_chan_id=%v",
+			chainhash.Hash(msg.ChainHash.Val),
+			msg.ShortChannelID.Val.ToUint64())
+
+	case *lnwire.ChannelUpdate2:
+		return fmt.Sprintf("chain_hash=%v, short_chan_id=%v, "+
+			"second_peer=%v, disabled=%v, block_height=%v",
+			chainhash.Hash(msg.ChainHash.Val),
+			msg.ShortChannelID.Val.ToUint64(), msg.SecondPeer.Val,
+			msg.DisabledFlags.Val, msg.BlockHeight.Val)
+
 	case *lnwire.NodeAnnouncement:
 		return fmt.Sprintf("node=%x, update_time=%v",
 			msg.NodeID, time.Unix(int64(msg.Timestamp), 0))
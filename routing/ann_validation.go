@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/davecgh/go-spew/spew"
@@ -85,6 +86,83 @@ func ValidateChannelAnn(a *lnwire.ChannelAnnouncement) error {
 
 }
 
+// ChannelAnn2Keys returns the node and bitcoin keys of both peers of the
+// channel announced by the passed ChannelAnnouncement2, which together sign
+// the announcement. As we need the bitcoin keys to reconstruct the funding
+// output of the channel, announcements without them are rejected.
+func ChannelAnn2Keys(a *lnwire.ChannelAnnouncement2) ([]*btcec.PublicKey,
+	error) {
+
+	if a.BitcoinKey1.IsNone() || a.BitcoinKey2.IsNone() {
+		return nil, errors.New("channel announcement is missing " +
+			"bitcoin keys")
+	}
+
+	rawKeys := [][33]byte{
+		a.NodeID1.Val, a.NodeID2.Val,
+		a.BitcoinKey1.UnwrapOr(a.BitcoinKey1.Zero()).Val,
+		a.BitcoinKey2.UnwrapOr(a.BitcoinKey2.Zero()).Val,
+	}
+
+	keys := make([]*btcec.PublicKey, 0, len(rawKeys))
+	for _, rawKey := range rawKeys {
+		key, err := btcec.ParsePubKey(rawKey[:])
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// ChannelAnn2AggregateKey computes the MuSig2 aggregate of the node and
+// bitcoin keys of both peers of the announced channel, which is the key that
+// the signature of the ChannelAnnouncement2 is verified against.
+func ChannelAnn2AggregateKey(a *lnwire.ChannelAnnouncement2) (
+	*btcec.PublicKey, error) {
+
+	keys, err := ChannelAnn2Keys(a)
+	if err != nil {
+		return nil, err
+	}
+
+	aggKey, _, _, err := musig2.AggregateKeys(keys, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return aggKey.FinalKey, nil
+}
+
+// ValidateChannelAnn2 validates the taproot channel announcement message and
+// checks that its signature is a valid MuSig2 signature of the node and
+// bitcoin keys of both peers over the announcement.
+func ValidateChannelAnn2(a *lnwire.ChannelAnnouncement2) error {
+	aggKey, err := ChannelAnn2AggregateKey(a)
+	if err != nil {
+		return err
+	}
+
+	digest, err := a.DigestToSign()
+	if err != nil {
+		return err
+	}
+
+	sig, err := a.Signature.ToSignature()
+	if err != nil {
+		return err
+	}
+
+	if !sig.Verify(digest[:], aggKey) {
+		return errors.Errorf("can't verify signature of channel "+
+			"announcement for channel %v", a.ShortChannelID.Val)
+	}
+
+	return nil
+}
+
 // ValidateNodeAnn validates the node announcement by ensuring that the
 // attached signature is needed a signature of the node announcement under the
 // specified node public key.
@@ -188,3 +266,64 @@ func ValidateChannelUpdateFields(capacity btcutil.Amount,
 
 	return nil
 }
+
+// ValidateChannelUpdate2Ann validates the taproot channel update announcement
+// by checking (1) that the included signature covers the announcement and has
+// been signed by the node's private key, and (2) that the announcement's
+// fields are sane.
+func ValidateChannelUpdate2Ann(pubKey *btcec.PublicKey,
+	capacity btcutil.Amount, a *lnwire.ChannelUpdate2) error {
+
+	if err := ValidateChannelUpdate2Fields(capacity, a); err != nil {
+		return err
+	}
+
+	return VerifyChannelUpdate2Signature(a, pubKey)
+}
+
+// VerifyChannelUpdate2Signature verifies that the taproot channel update
+// message was signed by the party with the given node public key.
+func VerifyChannelUpdate2Signature(msg *lnwire.ChannelUpdate2,
+	pubKey *btcec.PublicKey) error {
+
+	digest, err := msg.DigestToSign()
+	if err != nil {
+		return fmt.Errorf("unable to reconstruct message data: %w", err)
+	}
+
+	nodeSig, err := msg.Signature.ToSignature()
+	if err != nil {
+		return err
+	}
+
+	if !nodeSig.Verify(digest[:], pubKey) {
+		return fmt.Errorf("invalid signature for channel update %v",
+			spew.Sdump(msg))
+	}
+
+	return nil
+}
+
+// ValidateChannelUpdate2Fields validates the fields of a taproot channel
+// update. Unlike the original channel update, the max HTLC field is always
+// present.
+func ValidateChannelUpdate2Fields(capacity btcutil.Amount,
+	msg *lnwire.ChannelUpdate2) error {
+
+	maxHtlc := msg.HTLCMaximumMsat.Val
+	if maxHtlc == 0 || maxHtlc < msg.HTLCMinimumMsat.Val {
+		return errors.Errorf("invalid max htlc for channel "+
+			"update %v", spew.Sdump(msg))
+	}
+
+	// For light clients, the capacity will not be set so we'll skip
+	// checking whether the MaxHTLC value respects the channel's
+	// capacity.
+	capacityMsat := lnwire.NewMSatFromSatoshis(capacity)
+	if capacityMsat != 0 && maxHtlc > capacityMsat {
+		return errors.Errorf("max_htlc (%v) for channel update "+
+			"greater than capacity (%v)", maxHtlc, capacityMsat)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/lightningnetwork/lnd/lnwire"
+	"github.com/stretchr/testify/require"
+)
+
+// TestValidateChannelUpdate2Fields asserts that the HTLC limits of taproot
+// channel updates are checked against each other and the channel capacity.
+func TestValidateChannelUpdate2Fields(t *testing.T) {
+	t.Parallel()
+
+	const capacity = btcutil.Amount(100_000)
+
+	testCases := []struct {
+		name    string
+		minHtlc lnwire.MilliSatoshi
+		maxHtlc lnwire.MilliSatoshi
+		valid   bool
+	}{
+		{
+			name:    "valid",
+			minHtlc: 1_000,
+			maxHtlc: 50_000_000,
+			valid:   true,
+		},
+		{
+			name:    "max htlc equals capacity",
+			minHtlc: 1_000,
+			maxHtlc: lnwire.NewMSatFromSatoshis(capacity),
+			valid:   true,
+		},
+		{
+			name:    "zero max htlc",
+			minHtlc: 0,
+			maxHtlc: 0,
+		},
+		{
+			name:    "max htlc below min htlc",
+			minHtlc: 2_000,
+			maxHtlc: 1_000,
+		},
+		{
+			name:    "max htlc above capacity",
+			minHtlc: 1_000,
+			maxHtlc: lnwire.NewMSatFromSatoshis(capacity) + 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var update lnwire.ChannelUpdate2
+			update.HTLCMinimumMsat.Val = tc.minHtlc
+			update.HTLCMaximumMsat.Val = tc.maxHtlc
+
+			err := ValidateChannelUpdate2Fields(capacity, &update)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
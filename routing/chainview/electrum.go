@@ -0,0 +1,477 @@
+package chainview
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/electrum"
+)
+
+// ElectrumFilteredChainView is an implementation of the FilteredChainView
+// interface which is backed by an Electrum or Esplora server. If the server
+// serves full blocks, they are filtered directly. Otherwise the spends of the
+// watched outputs are looked up in the histories of their funding scripts.
+type ElectrumFilteredChainView struct {
+	started int32 // To be used atomically.
+	stopped int32 // To be used atomically.
+
+	backend      electrum.Backend
+	pollInterval time.Duration
+
+	// bestHash and bestHeight identify the latest block added to the
+	// blockQueue. They are only accessed by the chainFilterer goroutine.
+	bestHash   chainhash.Hash
+	bestHeight uint32
+
+	// blockEventQueue is the ordered queue used to keep the order
+	// of connected and disconnected blocks sent to the reader of the
+	// chainView.
+	blockQueue *blockEventQueue
+
+	// filterUpdates is a channel in which updates to the utxo filter
+	// attached to this instance are sent over.
+	filterUpdates chan electrumFilterUpdate
+
+	// chainFilter is the set of utxo's that we're currently watching
+	// spends for within the chain, along with their funding scripts.
+	filterMtx   sync.RWMutex
+	chainFilter map[wire.OutPoint][]byte
+
+	// filterBlockReqs is a channel in which requests to filter select
+	// blocks will be sent over.
+	filterBlockReqs chan *filterBlockReq
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// A compile time check to ensure ElectrumFilteredChainView implements the
+// chainview.FilteredChainView.
+var _ FilteredChainView = (*ElectrumFilteredChainView)(nil)
+
+// NewElectrumFilteredChainView creates a new instance of a FilteredChainView
+// which polls the given backend for new blocks in the given interval.
+func NewElectrumFilteredChainView(backend electrum.Backend,
+	pollInterval time.Duration) *ElectrumFilteredChainView {
+
+	if pollInterval <= 0 {
+		pollInterval = electrum.DefaultPollInterval
+	}
+
+	return &ElectrumFilteredChainView{
+		backend:         backend,
+		pollInterval:    pollInterval,
+		blockQueue:      newBlockEventQueue(),
+		filterUpdates:   make(chan electrumFilterUpdate),
+		chainFilter:     make(map[wire.OutPoint][]byte),
+		filterBlockReqs: make(chan *filterBlockReq),
+		quit:            make(chan struct{}),
+	}
+}
+
+// Start starts all goroutines necessary for normal operation.
+//
+// NOTE: This is part of the FilteredChainView interface.
+func (e *ElectrumFilteredChainView) Start() error {
+	// Already started?
+	if atomic.AddInt32(&e.started, 1) != 1 {
+		return nil
+	}
+
+	log.Infof("FilteredChainView starting")
+
+	bestHash, bestHeight, err := e.backend.BestBlock()
+	if err != nil {
+		return err
+	}
+	e.bestHash = *bestHash
+	e.bestHeight = uint32(bestHeight)
+
+	e.blockQueue.Start()
+
+	e.wg.Add(1)
+	go e.chainFilterer()
+
+	return nil
+}
+
+// Stop stops all goroutines which we launched by the prior call to the Start
+// method. The backend itself isn't closed, as it's shared with the other
+// users of the chain backend.
+//
+// NOTE: This is part of the FilteredChainView interface.
+func (e *ElectrumFilteredChainView) Stop() error {
+	// Already shutting down?
+	if atomic.AddInt32(&e.stopped, 1) != 1 {
+		return nil
+	}
+
+	e.blockQueue.Stop()
+
+	log.Infof("FilteredChainView stopping")
+
+	close(e.quit)
+	e.wg.Wait()
+
+	return nil
+}
+
+// filterBlock returns the transactions of the given block that spend outputs
+// which are currently being watched. Additionally, the chain filter will also
+// be updated by removing any spent outputs.
+func (e *ElectrumFilteredChainView) filterBlock(hash *chainhash.Hash,
+	height uint32) ([]*wire.MsgTx, error) {
+
+	e.filterMtx.RLock()
+	if len(e.chainFilter) == 0 {
+		e.filterMtx.RUnlock()
+		return nil, nil
+	}
+	e.filterMtx.RUnlock()
+
+	txs, err := e.candidateTxs(hash, height)
+	if err != nil {
+		return nil, err
+	}
+
+	e.filterMtx.Lock()
+	defer e.filterMtx.Unlock()
+
+	var filteredTxns []*wire.MsgTx
+	for _, tx := range txs {
+		var txAlreadyFiltered bool
+		for _, txIn := range tx.TxIn {
+			prevOp := txIn.PreviousOutPoint
+			if _, ok := e.chainFilter[prevOp]; !ok {
+				continue
+			}
+
+			delete(e.chainFilter, prevOp)
+
+			// Only add this txn to our list of filtered txns if it
+			// is the first previous outpoint to cause a match.
+			if txAlreadyFiltered {
+				continue
+			}
+
+			filteredTxns = append(filteredTxns, tx.Copy())
+			txAlreadyFiltered = true
+		}
+	}
+
+	return filteredTxns, nil
+}
+
+// candidateTxs returns the transactions of the given block that might spend
+// a watched output. These are all transactions of the block if the backend
+// serves full blocks, or otherwise the transactions of the block found in the
+// histories of the funding scripts of the watched outputs.
+func (e *ElectrumFilteredChainView) candidateTxs(hash *chainhash.Hash,
+	height uint32) ([]*wire.MsgTx, error) {
+
+	block, err := e.backend.Block(hash)
+	switch {
+	case err == nil:
+		return block.Transactions, nil
+
+	case !errors.Is(err, electrum.ErrBlocksUnsupported):
+		return nil, err
+	}
+
+	e.filterMtx.RLock()
+	pkScripts := make(map[string][]byte, len(e.chainFilter))
+	for _, pkScript := range e.chainFilter {
+		pkScripts[electrum.ScriptHash(pkScript)] = pkScript
+	}
+	e.filterMtx.RUnlock()
+
+	var (
+		txs  []*wire.MsgTx
+		seen = make(map[chainhash.Hash]struct{})
+	)
+	for _, pkScript := range pkScripts {
+		history, err := e.backend.ScriptHistory(pkScript)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range history {
+			if uint32(item.Height) != height || !item.Confirmed() {
+				continue
+			}
+			if _, ok := seen[item.TxHash]; ok {
+				continue
+			}
+			seen[item.TxHash] = struct{}{}
+
+			item := item
+			tx, err := e.backend.Transaction(&item.TxHash)
+			if err != nil {
+				return nil, err
+			}
+			txs = append(txs, tx)
+		}
+	}
+
+	return txs, nil
+}
+
+// pollChain disconnects the blocks that are no longer part of the best chain
+// of the backend and connects the new ones.
+func (e *ElectrumFilteredChainView) pollChain() error {
+	tipHash, tipHeight, err := e.backend.BestBlock()
+	if err != nil {
+		return err
+	}
+	if *tipHash == e.bestHash {
+		return nil
+	}
+
+	// Rewind until our best block is part of the best chain again.
+	for e.bestHeight > 0 {
+		if e.bestHeight <= uint32(tipHeight) {
+			hash, err := e.backend.BlockHash(int32(e.bestHeight))
+			if err != nil {
+				return err
+			}
+			if *hash == e.bestHash {
+				break
+			}
+		}
+
+		header, _, err := e.backend.BlockHeader(&e.bestHash)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("got disconnected block at height %d: %v",
+			e.bestHeight, e.bestHash)
+
+		e.blockQueue.Add(&blockEvent{
+			eventType: disconnected,
+			block: &FilteredBlock{
+				Hash:   e.bestHash,
+				Height: e.bestHeight,
+			},
+		})
+
+		e.bestHash = header.PrevBlock
+		e.bestHeight--
+	}
+
+	for height := e.bestHeight + 1; height <= uint32(tipHeight); height++ {
+		hash, err := e.backend.BlockHash(int32(height))
+		if err != nil {
+			return err
+		}
+
+		txns, err := e.filterBlock(hash, height)
+		if err != nil {
+			return err
+		}
+
+		e.blockQueue.Add(&blockEvent{
+			eventType: connected,
+			block: &FilteredBlock{
+				Hash:         *hash,
+				Height:       height,
+				Transactions: txns,
+			},
+		})
+
+		e.bestHash = *hash
+		e.bestHeight = height
+	}
+
+	return nil
+}
+
+// FilterBlock takes a block hash, and returns a FilteredBlocks which is the
+// result of applying the current registered UTXO sub-set on the block
+// corresponding to that block hash. If any watched UTXO's are spent by the
+// selected block, then the internal chainFilter will also be updated.
+//
+// NOTE: This is part of the FilteredChainView interface.
+func (e *ElectrumFilteredChainView) FilterBlock(
+	blockHash *chainhash.Hash) (*FilteredBlock, error) {
+
+	req := &filterBlockReq{
+		blockHash: blockHash,
+		resp:      make(chan *FilteredBlock, 1),
+		err:       make(chan error, 1),
+	}
+
+	select {
+	case e.filterBlockReqs <- req:
+	case <-e.quit:
+		return nil, fmt.Errorf("FilteredChainView shutting down")
+	}
+
+	return <-req.resp, <-req.err
+}
+
+// chainFilterer is the primary goroutine which: polls for new blocks and
+// dispatches the relevant FilteredBlock notifications, updates the filter due
+// to requests by callers, and finally is able to perform targeted block
+// filtration.
+func (e *ElectrumFilteredChainView) chainFilterer() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		// The caller has just sent an update to the current chain
+		// filter, so we'll apply the update, possibly rewinding our
+		// state partially.
+		case update := <-e.filterUpdates:
+			// First, we'll add all the new UTXO's to the set of
+			// watched UTXO's, eliminating any duplicates in the
+			// process.
+			log.Tracef("Updating chain filter with new UTXO's: %v",
+				update.newUtxos)
+
+			e.filterMtx.Lock()
+			for _, newOp := range update.newUtxos {
+				pkScript := newOp.FundingPkScript
+				e.chainFilter[newOp.OutPoint] = pkScript
+			}
+			e.filterMtx.Unlock()
+
+			// If the update height matches our best known height,
+			// then we don't need to do any rewinding.
+			if update.updateHeight >= e.bestHeight {
+				continue
+			}
+
+			// Otherwise, we'll rewind the state to ensure the
+			// caller doesn't miss any relevant notifications.
+			// Starting from the height _after_ the update height,
+			// we'll walk forwards, filtering one block at a time.
+			startHeight := update.updateHeight + 1
+			for i := startHeight; i <= e.bestHeight; i++ {
+				blockHash, err := e.backend.BlockHash(int32(i))
+				if err != nil {
+					log.Warnf("Unable to get block hash "+
+						"for block at height %d: %v",
+						i, err)
+					continue
+				}
+
+				txns, err := e.filterBlock(blockHash, i)
+				if err != nil {
+					log.Warnf("Unable to filter block "+
+						"with hash %v at height %d: %v",
+						blockHash, i, err)
+					continue
+				}
+
+				// Only blocks with matching transactions are
+				// sent again.
+				if len(txns) == 0 {
+					continue
+				}
+
+				e.blockQueue.Add(&blockEvent{
+					eventType: connected,
+					block: &FilteredBlock{
+						Hash:         *blockHash,
+						Height:       i,
+						Transactions: txns,
+					},
+				})
+			}
+
+		// We've received a new request to manually filter a block.
+		case req := <-e.filterBlockReqs:
+			_, height, err := e.backend.BlockHeader(req.blockHash)
+			if err != nil {
+				req.err <- err
+				req.resp <- nil
+				continue
+			}
+
+			txns, err := e.filterBlock(
+				req.blockHash, uint32(height),
+			)
+			if err != nil {
+				req.err <- err
+				req.resp <- nil
+				continue
+			}
+
+			req.resp <- &FilteredBlock{
+				Hash:         *req.blockHash,
+				Height:       uint32(height),
+				Transactions: txns,
+			}
+			req.err <- nil
+
+		case <-ticker.C:
+			if err := e.pollChain(); err != nil {
+				log.Errorf("Unable to poll %v backend: %v",
+					e.backend.Name(), err)
+			}
+
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// electrumFilterUpdate is a message sent to the chainFilterer to update the
+// current chainFilter state. Unlike filterUpdate, it carries the funding
+// scripts of the outputs, as their spends are looked up by script.
+type electrumFilterUpdate struct {
+	newUtxos     []channeldb.EdgePoint
+	updateHeight uint32
+}
+
+// UpdateFilter updates the UTXO filter which is to be consulted when creating
+// FilteredBlocks to be sent to subscribed clients. This method is cumulative
+// meaning repeated calls to this method should _expand_ the size of the UTXO
+// sub-set currently being watched.  If the set updateHeight is _lower_ than
+// the best known height of the implementation, then the state should be
+// rewound to ensure all relevant notifications are dispatched.
+//
+// NOTE: This is part of the FilteredChainView interface.
+func (e *ElectrumFilteredChainView) UpdateFilter(ops []channeldb.EdgePoint,
+	updateHeight uint32) error {
+
+	select {
+	case e.filterUpdates <- electrumFilterUpdate{
+		newUtxos:     ops,
+		updateHeight: updateHeight,
+	}:
+		return nil
+
+	case <-e.quit:
+		return fmt.Errorf("chain filter shutting down")
+	}
+}
+
+// FilteredBlocks returns the channel that filtered blocks are to be sent over.
+// Each time a block is connected to the end of a main chain, and appropriate
+// FilteredBlock which contains the transactions which mutate our watched UTXO
+// set is to be returned.
+//
+// NOTE: This is part of the FilteredChainView interface.
+func (e *ElectrumFilteredChainView) FilteredBlocks() <-chan *FilteredBlock {
+	return e.blockQueue.newBlocks
+}
+
+// DisconnectedBlocks returns a receive only channel which will be sent upon
+// with the empty filtered blocks of blocks which are disconnected from the
+// main chain in the case of a re-org.
+//
+// NOTE: This is part of the FilteredChainView interface.
+func (e *ElectrumFilteredChainView) DisconnectedBlocks() <-chan *FilteredBlock {
+	return e.blockQueue.staleBlocks
+}
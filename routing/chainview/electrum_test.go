@@ -0,0 +1,199 @@
+package chainview
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/electrum"
+	"github.com/stretchr/testify/require"
+)
+
+var electrumTestScript = []byte{
+	// OP_0
+	0x00,
+	// OP_DATA_20
+	0x14,
+	// <20-byte hash>
+	0xec, 0x6f, 0x7a, 0x5a, 0xa8, 0xf2, 0xb1, 0x0c, 0xa5, 0x15,
+	0x3b, 0x2f, 0x0a, 0xc2, 0x5c, 0x98, 0x48, 0xbd, 0xf5, 0x1b,
+}
+
+// newElectrumTestTx creates a transaction that spends the given outpoints and
+// has the given number of outputs paying to the test script.
+func newElectrumTestTx(numOutputs int, prevOuts ...wire.OutPoint) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	for i := range prevOuts {
+		tx.AddTxIn(wire.NewTxIn(&prevOuts[i], nil, nil))
+	}
+	for i := 0; i < numOutputs; i++ {
+		tx.AddTxOut(wire.NewTxOut(10_000, electrumTestScript))
+	}
+
+	return tx
+}
+
+// newElectrumTestView creates and starts a chain view on top of the mock
+// backend.
+func newElectrumTestView(t *testing.T, backend electrum.Backend,
+	pollInterval time.Duration) *ElectrumFilteredChainView {
+
+	t.Helper()
+
+	chainView := NewElectrumFilteredChainView(backend, pollInterval)
+	require.NoError(t, chainView.Start())
+	t.Cleanup(func() {
+		require.NoError(t, chainView.Stop())
+	})
+
+	return chainView
+}
+
+// receiveBlock returns the next block sent on the given channel.
+func receiveBlock(t *testing.T, blocks <-chan *FilteredBlock) *FilteredBlock {
+	t.Helper()
+
+	select {
+	case block := <-blocks:
+		return block
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no block received")
+		return nil
+	}
+}
+
+// assertElectrumBlock checks that the filtered block is the block at the
+// given height of the mock backend and has the given transactions.
+func assertElectrumBlock(t *testing.T, backend *electrum.MockBackend,
+	block *FilteredBlock, height uint32, txs ...*wire.MsgTx) {
+
+	t.Helper()
+
+	hash, err := backend.BlockHash(int32(height))
+	require.NoError(t, err)
+	require.Equal(t, *hash, block.Hash)
+	require.Equal(t, height, block.Height)
+
+	txHashes := make([]chainhash.Hash, 0, len(block.Transactions))
+	for _, tx := range block.Transactions {
+		txHashes = append(txHashes, tx.TxHash())
+	}
+	expected := make([]chainhash.Hash, 0, len(txs))
+	for _, tx := range txs {
+		expected = append(expected, tx.TxHash())
+	}
+	require.Equal(t, expected, txHashes)
+}
+
+// TestElectrumFilteredChainView checks that the chain view finds spends of
+// watched outputs both in full blocks and in the histories of the funding
+// scripts, and reports reorgs.
+func TestElectrumFilteredChainView(t *testing.T) {
+	t.Parallel()
+
+	for _, blocksUnsupported := range []bool{false, true} {
+		blocksUnsupported := blocksUnsupported
+		name := fmt.Sprintf("blocks_unsupported=%v", blocksUnsupported)
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testElectrumFilteredChainView(t, blocksUnsupported)
+		})
+	}
+}
+
+func testElectrumFilteredChainView(t *testing.T, blocksUnsupported bool) {
+	backend := electrum.NewMockBackend(&chaincfg.RegressionNetParams)
+	backend.BlocksUnsupported = blocksUnsupported
+
+	// Fund two outputs and spend the second one before the chain view
+	// watches them.
+	fundingTx := newElectrumTestTx(2, wire.OutPoint{Index: 1})
+	backend.AddBlock(fundingTx)
+
+	ops := []channeldb.EdgePoint{
+		{
+			FundingPkScript: electrumTestScript,
+			OutPoint: wire.OutPoint{
+				Hash: fundingTx.TxHash(),
+			},
+		},
+		{
+			FundingPkScript: electrumTestScript,
+			OutPoint: wire.OutPoint{
+				Hash:  fundingTx.TxHash(),
+				Index: 1,
+			},
+		},
+	}
+	historicalSpend := newElectrumTestTx(1, ops[1].OutPoint)
+	backend.AddBlock(historicalSpend)
+
+	chainView := newElectrumTestView(t, backend, 10*time.Millisecond)
+
+	// Watching the outputs from a height before the spend rescans the
+	// block of the spend.
+	require.NoError(t, chainView.UpdateFilter(ops, 1))
+	block := receiveBlock(t, chainView.FilteredBlocks())
+	assertElectrumBlock(t, backend, block, 2, historicalSpend)
+
+	// A new block with the spend of the other output is connected along
+	// with the spend, but without the unrelated transaction.
+	spendTx := newElectrumTestTx(1, ops[0].OutPoint)
+	unrelatedTx := newElectrumTestTx(1, wire.OutPoint{Index: 2})
+	backend.AddBlock(unrelatedTx, spendTx)
+
+	block = receiveBlock(t, chainView.FilteredBlocks())
+	assertElectrumBlock(t, backend, block, 3, spendTx)
+
+	// After a reorg, the stale block is disconnected and the new one is
+	// connected.
+	staleHash, err := backend.BlockHash(3)
+	require.NoError(t, err)
+
+	backend.DisconnectBlocks(1)
+	backend.AddBlock()
+
+	block = receiveBlock(t, chainView.DisconnectedBlocks())
+	require.Equal(t, *staleHash, block.Hash)
+	require.EqualValues(t, 3, block.Height)
+
+	block = receiveBlock(t, chainView.FilteredBlocks())
+	assertElectrumBlock(t, backend, block, 3)
+}
+
+// TestElectrumFilterBlock checks that a single block can be filtered for
+// spends of the watched outputs.
+func TestElectrumFilterBlock(t *testing.T) {
+	t.Parallel()
+
+	backend := electrum.NewMockBackend(&chaincfg.RegressionNetParams)
+	backend.BlocksUnsupported = true
+
+	fundingTx := newElectrumTestTx(1, wire.OutPoint{Index: 1})
+	backend.AddBlock(fundingTx)
+
+	outPoint := wire.OutPoint{Hash: fundingTx.TxHash()}
+	spendTx := newElectrumTestTx(1, outPoint)
+	block := backend.AddBlock(spendTx)
+
+	// The chain view doesn't poll during the test.
+	chainView := newElectrumTestView(t, backend, time.Hour)
+
+	err := chainView.UpdateFilter([]channeldb.EdgePoint{{
+		FundingPkScript: electrumTestScript,
+		OutPoint:        outPoint,
+	}}, 2)
+	require.NoError(t, err)
+
+	blockHash := block.BlockHash()
+	filteredBlock, err := chainView.FilterBlock(&blockHash)
+	require.NoError(t, err)
+	assertElectrumBlock(t, backend, filteredBlock, 2, spendTx)
+}
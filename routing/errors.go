@@ -37,6 +37,10 @@ const (
 	// ErrParentValidationFailed signals that the validation of a
 	// dependent's parent failed, so the dependent must not be processed.
 	ErrParentValidationFailed
+
+	// ErrChannelTooSmall is returned when a channel of other nodes has a
+	// capacity below the minimum we're configured to add to our graph.
+	ErrChannelTooSmall
 )
 
 // routerError is a structure that represent the error inside the routing package,
@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-errors/errors"
@@ -22,6 +24,7 @@ import (
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
 	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/fn"
 	"github.com/lightningnetwork/lnd/htlcswitch"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/kvdb"
@@ -172,6 +175,12 @@ type ChannelGraphSource interface {
 	IsStaleEdgePolicy(chanID lnwire.ShortChannelID, timestamp time.Time,
 		flags lnwire.ChanUpdateChanFlags) bool
 
+	// IsStaleEdgePolicy2 returns true if the graph source has a policy
+	// announced with a ChannelUpdate2 for the passed channel ID (and
+	// flags) that was created at the same or a later block height.
+	IsStaleEdgePolicy2(chanID lnwire.ShortChannelID, blockHeight uint32,
+		flags lnwire.ChanUpdateChanFlags) bool
+
 	// MarkEdgeLive clears an edge from our zombie index, deeming it as
 	// live.
 	MarkEdgeLive(chanID lnwire.ShortChannelID) error
@@ -393,6 +402,12 @@ type Config struct {
 	// from blocking initial usage of the daemon.
 	AssumeChannelValid bool
 
+	// MinChannelCapacity, if non-zero, is the minimum capacity of the
+	// channels of other nodes that we'll add to our graph. Smaller
+	// channels are marked as zombies so that we don't continue to request
+	// them from our peers.
+	MinChannelCapacity btcutil.Amount
+
 	// PathFindingConfig defines global path finding parameters.
 	PathFindingConfig PathFindingConfig
 
@@ -1124,9 +1139,10 @@ func (r *ChannelRouter) handleNetworkUpdate(vb *ValidationBarrier,
 	if err != nil {
 		// We now decide to log an error or not. If allowDependents is
 		// false, it means there is an error and the error is neither
-		// ErrIgnored or ErrOutdated. In this case, we'll log an error.
-		// Otherwise, we'll add debug log only.
-		if allowDependents {
+		// ErrIgnored or ErrOutdated. In this case, we'll log an error,
+		// unless the channel was deliberately filtered for being too
+		// small. Otherwise, we'll add debug log only.
+		if allowDependents || IsError(err, ErrChannelTooSmall) {
 			log.Debugf("process network updates got: %v", err)
 		} else {
 			log.Errorf("process network updates got: %v", err)
@@ -1541,15 +1557,30 @@ func makeFundingScript(bitcoinKey1, bitcoinKey2 []byte,
 		lnwire.SimpleTaprootChannelsOptionalStaging,
 	) {
 
-		pubKey1, err := btcec.ParsePubKey(bitcoinKey1)
-		if err != nil {
-			return nil, err
-		}
-		pubKey2, err := btcec.ParsePubKey(bitcoinKey2)
-		if err != nil {
-			return nil, err
-		}
+		return makeTaprootFundingScript(
+			bitcoinKey1, bitcoinKey2, fn.None[chainhash.Hash](),
+		)
+	}
+
+	return legacyFundingScript()
+}
 
+// makeTaprootFundingScript is used to make the funding script of a taproot
+// channel. If a tapscript root is given, the aggregate funding key is tweaked
+// with it instead of the BIP 86 tweak.
+func makeTaprootFundingScript(bitcoinKey1, bitcoinKey2 []byte,
+	tapscriptRoot fn.Option[chainhash.Hash]) ([]byte, error) {
+
+	pubKey1, err := btcec.ParsePubKey(bitcoinKey1)
+	if err != nil {
+		return nil, err
+	}
+	pubKey2, err := btcec.ParsePubKey(bitcoinKey2)
+	if err != nil {
+		return nil, err
+	}
+
+	if tapscriptRoot.IsNone() {
 		fundingScript, _, err := input.GenTaprootFundingScript(
 			pubKey1, pubKey2, 0,
 		)
@@ -1560,7 +1591,32 @@ func makeFundingScript(bitcoinKey1, bitcoinKey2 []byte,
 		return fundingScript, nil
 	}
 
-	return legacyFundingScript()
+	root := tapscriptRoot.UnwrapOr(chainhash.Hash{})
+	combinedKey, _, _, err := musig2.AggregateKeys(
+		[]*btcec.PublicKey{pubKey1, pubKey2}, true,
+		musig2.WithTaprootKeyTweak(root[:]),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("unable to combine keys: %w", err)
+	}
+
+	return input.PayToTaprootScript(combinedKey.FinalKey)
+}
+
+// makeEdgeFundingScript returns the funding script of the passed edge. All
+// channels announced with the taproot gossip protocol are taproot channels.
+func makeEdgeFundingScript(edge *models.ChannelEdgeInfo) ([]byte, error) {
+	if edge.Version == lnwire.GossipVersion2 {
+		return makeTaprootFundingScript(
+			edge.BitcoinKey1Bytes[:], edge.BitcoinKey2Bytes[:],
+			edge.MerkleRootHash,
+		)
+	}
+
+	return makeFundingScript(
+		edge.BitcoinKey1Bytes[:], edge.BitcoinKey2Bytes[:],
+		edge.Features,
+	)
 }
 
 // processUpdate processes a new relate authenticated channel/edge, node or
@@ -1672,10 +1728,7 @@ func (r *ChannelRouter) processUpdate(msg interface{},
 		// Recreate witness output to be sure that declared in channel
 		// edge bitcoin keys and channel value corresponds to the
 		// reality.
-		fundingPkScript, err := makeFundingScript(
-			msg.BitcoinKey1Bytes[:], msg.BitcoinKey2Bytes[:],
-			msg.Features,
-		)
+		fundingPkScript, err := makeEdgeFundingScript(msg)
 		if err != nil {
 			return err
 		}
@@ -1726,6 +1779,30 @@ func (r *ChannelRouter) processUpdate(msg interface{},
 		// after commitment fees are dynamic.
 		msg.Capacity = btcutil.Amount(chanUtxo.Value)
 		msg.ChannelPoint = *fundingPoint
+
+		// If the channel is smaller than we care to know about, and
+		// isn't one of our own, we'll mark it as a zombie so we don't
+		// continue to request it. Unlike for invalid channels, we use
+		// its node keys so it can be resurrected if the minimum is
+		// lowered.
+		isSelfChannel := msg.NodeKey1Bytes == r.selfNode.PubKeyBytes ||
+			msg.NodeKey2Bytes == r.selfNode.PubKeyBytes
+		if msg.Capacity < r.cfg.MinChannelCapacity && !isSelfChannel {
+			err := r.cfg.Graph.MarkEdgeZombie(
+				msg.ChannelID, msg.NodeKey1Bytes,
+				msg.NodeKey2Bytes,
+			)
+			if err != nil {
+				return fmt.Errorf("unable to mark small "+
+					"chan(id=%v) as a zombie: %w",
+					msg.ChannelID, err)
+			}
+
+			return newErrf(ErrChannelTooSmall, "ignoring "+
+				"chan_id=%v with capacity %v below minimum "+
+				"of %v", msg.ChannelID, msg.Capacity,
+				r.cfg.MinChannelCapacity)
+		}
 		if err := r.cfg.Graph.AddChannelEdge(msg, op...); err != nil {
 			return errors.Errorf("unable to add edge: %v", err)
 		}
@@ -1800,6 +1877,21 @@ func (r *ChannelRouter) processUpdate(msg interface{},
 		// newer than what we already know of we can exit early.
 		switch {
 
+		// Policies that were announced with a ChannelUpdate2 are
+		// ordered by the block height they were created at instead.
+		case msg.Version == lnwire.GossipVersion2:
+			chanID := lnwire.NewShortChanIDFromInt(msg.ChannelID)
+			if r.IsStaleEdgePolicy2(
+				chanID, msg.BlockHeight, msg.ChannelFlags,
+			) {
+
+				return newErrf(ErrOutdated, "Ignoring "+
+					"outdated update (height=%v|flags=%v) "+
+					"for known chan_id=%v",
+					msg.BlockHeight, msg.ChannelFlags,
+					msg.ChannelID)
+			}
+
 		// A flag set of 0 indicates this is an announcement for the
 		// "first" node in the channel.
 		case msg.ChannelFlags&lnwire.ChanUpdateDirection == 0:
@@ -2941,6 +3033,13 @@ func (r *ChannelRouter) AddProof(chanID lnwire.ShortChannelID,
 	}
 
 	info.AuthProof = proof
+
+	// A Schnorr signature is only present in the proof of a channel that
+	// is announced with a ChannelAnnouncement2.
+	if len(proof.SchnorrSigBytes) != 0 {
+		info.Version = lnwire.GossipVersion2
+	}
+
 	return r.cfg.Graph.UpdateChannelEdge(info)
 }
 
@@ -2981,6 +3080,33 @@ func (r *ChannelRouter) IsKnownEdge(chanID lnwire.ShortChannelID) bool {
 	return exists || isZombie
 }
 
+// IsStaleEdgePolicy2 returns true if the graph source has a policy that was
+// announced with a ChannelUpdate2 for the passed channel ID (and flags) that
+// was created at the same or a later block height. Policies that were
+// announced with the original ChannelUpdate are always superseded.
+//
+// NOTE: This method is part of the ChannelGraphSource interface.
+func (r *ChannelRouter) IsStaleEdgePolicy2(chanID lnwire.ShortChannelID,
+	blockHeight uint32, flags lnwire.ChanUpdateChanFlags) bool {
+
+	_, e1, e2, err := r.cfg.Graph.FetchChannelEdgesByID(chanID.ToUint64())
+	if err != nil {
+		log.Debugf("Check stale edge policy got error: %v", err)
+		return false
+	}
+
+	policy := e1
+	if flags&lnwire.ChanUpdateDirection == lnwire.ChanUpdateDirection {
+		policy = e2
+	}
+
+	if policy == nil || policy.Version != lnwire.GossipVersion2 {
+		return false
+	}
+
+	return policy.BlockHeight >= blockHeight
+}
+
 // IsStaleEdgePolicy returns true if the graph source has a channel edge for
 // the passed channel ID (and flags) that have a more recent timestamp.
 //
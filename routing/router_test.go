@@ -12,8 +12,10 @@ import (
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/davecgh/go-spew/spew"
 	sphinx "github.com/lightningnetwork/lightning-onion"
@@ -21,7 +23,9 @@ import (
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
 	"github.com/lightningnetwork/lnd/clock"
+	"github.com/lightningnetwork/lnd/fn"
 	"github.com/lightningnetwork/lnd/htlcswitch"
+	"github.com/lightningnetwork/lnd/input"
 	lnmock "github.com/lightningnetwork/lnd/lntest/mock"
 	"github.com/lightningnetwork/lnd/lntest/wait"
 	"github.com/lightningnetwork/lnd/lntypes"
@@ -1349,6 +1353,137 @@ func TestIgnoreChannelEdgePolicyForUnknownChannel(t *testing.T) {
 	}
 }
 
+// TestMakeTaprootFundingScript asserts that the funding script of taproot
+// channels commits to the tapscript root of the channel if one is given.
+func TestMakeTaprootFundingScript(t *testing.T) {
+	t.Parallel()
+
+	key1 := bitcoinKey1.SerializeCompressed()
+	key2 := bitcoinKey2.SerializeCompressed()
+
+	// Without a tapscript root, the script should match the one of
+	// regular taproot channels.
+	script, err := makeTaprootFundingScript(
+		key1, key2, fn.None[chainhash.Hash](),
+	)
+	require.NoError(t, err)
+
+	expScript, _, err := input.GenTaprootFundingScript(
+		bitcoinKey1, bitcoinKey2, 0,
+	)
+	require.NoError(t, err)
+	require.Equal(t, expScript, script)
+
+	// With a tapscript root, the output key should be the internal key
+	// tweaked with the root.
+	root := chainhash.Hash{0x1}
+	script, err = makeTaprootFundingScript(key1, key2, fn.Some(root))
+	require.NoError(t, err)
+
+	internalKey, _, _, err := musig2.AggregateKeys(
+		[]*btcec.PublicKey{bitcoinKey1, bitcoinKey2}, true,
+	)
+	require.NoError(t, err)
+
+	outputKey := txscript.ComputeTaprootOutputKey(
+		internalKey.PreTweakedKey, root[:],
+	)
+	expScript, err = input.PayToTaprootScript(outputKey)
+	require.NoError(t, err)
+	require.Equal(t, expScript, script)
+}
+
+// TestTaprootEdgeAndPolicy2 asserts that edges of taproot channels that were
+// announced with the taproot gossip protocol are validated against their
+// taproot funding output, and that their policies are ordered by block
+// height.
+func TestTaprootEdgeAndPolicy2(t *testing.T) {
+	t.Parallel()
+
+	const startingBlockHeight = 101
+
+	testGraph, err := createTestGraphFromChannels(
+		t, true, []*testChannel{}, "roasbeef",
+	)
+	require.NoError(t, err, "unable to create graph")
+
+	ctx := createTestCtxFromGraphInstance(
+		t, startingBlockHeight, testGraph, false,
+	)
+
+	var pub1, pub2 [33]byte
+	copy(pub1[:], priv1.PubKey().SerializeCompressed())
+	copy(pub2[:], priv2.PubKey().SerializeCompressed())
+
+	// Create the taproot funding output of the channel, which commits to
+	// a tapscript root, and confirm it.
+	root := chainhash.Hash{0x2}
+	fundingScript, err := makeTaprootFundingScript(
+		pub1[:], pub2[:], fn.Some(root),
+	)
+	require.NoError(t, err)
+
+	fundingTx := wire.NewMsgTx(2)
+	fundingTx.AddTxOut(wire.NewTxOut(10000, fundingScript))
+	ctx.chain.addUtxo(
+		wire.OutPoint{Hash: fundingTx.TxHash()}, fundingTx.TxOut[0],
+	)
+
+	chanID := lnwire.ShortChannelID{BlockHeight: 500}
+	ctx.chain.addBlock(
+		&wire.MsgBlock{Transactions: []*wire.MsgTx{fundingTx}},
+		chanID.BlockHeight, chanID.BlockHeight,
+	)
+
+	edge := &models.ChannelEdgeInfo{
+		ChannelID:        chanID.ToUint64(),
+		NodeKey1Bytes:    pub1,
+		NodeKey2Bytes:    pub2,
+		BitcoinKey1Bytes: pub1,
+		BitcoinKey2Bytes: pub2,
+		Version:          lnwire.GossipVersion2,
+		MerkleRootHash:   fn.Some(root),
+	}
+	require.NoError(t, ctx.router.AddEdge(edge))
+
+	policy := &models.ChannelEdgePolicy{
+		SigBytes:                  bytes.Repeat([]byte{0x1}, 64),
+		ChannelID:                 edge.ChannelID,
+		LastUpdate:                testTime,
+		MessageFlags:              lnwire.ChanUpdateRequiredMaxHtlc,
+		TimeLockDelta:             10,
+		MinHTLC:                   1,
+		MaxHTLC:                   1000,
+		FeeBaseMSat:               10,
+		FeeProportionalMillionths: 10000,
+		Version:                   lnwire.GossipVersion2,
+		BlockHeight:               600,
+	}
+	require.NoError(t, ctx.router.UpdateEdge(policy))
+
+	// A policy of the same or a lower block height is stale, even if it
+	// was received later.
+	require.True(t, ctx.router.IsStaleEdgePolicy2(chanID, 600, 0))
+	require.False(t, ctx.router.IsStaleEdgePolicy2(chanID, 601, 0))
+
+	policy.LastUpdate = testTime.Add(time.Hour)
+	err = ctx.router.UpdateEdge(policy)
+	require.True(t, IsError(err, ErrOutdated))
+
+	// The policy of the other direction is still unknown.
+	require.False(t, ctx.router.IsStaleEdgePolicy2(
+		chanID, 600, lnwire.ChanUpdateDirection,
+	))
+
+	// A policy of a higher block height is applied.
+	policy.BlockHeight = 601
+	require.NoError(t, ctx.router.UpdateEdge(policy))
+
+	_, e1, _, err := ctx.router.GetChannelByID(chanID)
+	require.NoError(t, err)
+	require.Equal(t, uint32(601), e1.BlockHeight)
+}
+
 // TestAddEdgeUnknownVertexes tests that if an edge is added that contains two
 // vertexes which we don't know of, the edge should be available for use
 // regardless. This is due to the fact that we don't actually need node
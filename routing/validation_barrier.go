@@ -126,6 +126,21 @@ func (v *ValidationBarrier) InitJobDependencies(job interface{}) {
 			v.nodeAnnDependencies[route.Vertex(msg.NodeID1)] = signals
 			v.nodeAnnDependencies[route.Vertex(msg.NodeID2)] = signals
 		}
+	case *lnwire.ChannelAnnouncement2:
+		shortID := msg.ShortChannelID.Val
+		if _, ok := v.chanAnnFinSignal[shortID]; !ok {
+			signals := &validationSignals{
+				allow: make(chan struct{}),
+				deny:  make(chan struct{}),
+			}
+
+			v.chanAnnFinSignal[shortID] = signals
+			v.chanEdgeDependencies[shortID] = signals
+
+			node1, node2 := msg.NodeID1.Val, msg.NodeID2.Val
+			v.nodeAnnDependencies[route.Vertex(node1)] = signals
+			v.nodeAnnDependencies[route.Vertex(node2)] = signals
+		}
 	case *models.ChannelEdgeInfo:
 
 		shortID := lnwire.NewShortChanIDFromInt(msg.ChannelID)
@@ -148,6 +163,8 @@ func (v *ValidationBarrier) InitJobDependencies(job interface{}) {
 		return
 	case *lnwire.ChannelUpdate:
 		return
+	case *lnwire.ChannelUpdate2:
+		return
 	case *lnwire.NodeAnnouncement:
 		// TODO(roasbeef): node ann needs to wait on existing channel updates
 		return
@@ -156,6 +173,8 @@ func (v *ValidationBarrier) InitJobDependencies(job interface{}) {
 	case *lnwire.AnnounceSignatures:
 		// TODO(roasbeef): need to wait on chan ann?
 		return
+	case *lnwire.AnnounceSignatures2:
+		return
 	}
 }
 
@@ -208,6 +227,12 @@ func (v *ValidationBarrier) WaitForDependants(job interface{}) error {
 		jobDesc = fmt.Sprintf("job=lnwire.ChannelUpdate, scid=%v",
 			msg.ShortChannelID.ToUint64())
 
+	case *lnwire.ChannelUpdate2:
+		signals, ok = v.chanEdgeDependencies[msg.ShortChannelID.Val]
+
+		jobDesc = fmt.Sprintf("job=lnwire.ChannelUpdate2, scid=%v",
+			msg.ShortChannelID.Val.ToUint64())
+
 	case *lnwire.NodeAnnouncement:
 		vertex := route.Vertex(msg.NodeID)
 		signals, ok = v.nodeAnnDependencies[vertex]
@@ -218,8 +243,10 @@ func (v *ValidationBarrier) WaitForDependants(job interface{}) error {
 	// return directly.
 	case *lnwire.AnnounceSignatures:
 		// TODO(roasbeef): need to wait on chan ann?
+	case *lnwire.AnnounceSignatures2:
 	case *models.ChannelEdgeInfo:
 	case *lnwire.ChannelAnnouncement:
+	case *lnwire.ChannelAnnouncement2:
 	}
 
 	// Release the lock once the above read is finished.
@@ -287,6 +314,19 @@ func (v *ValidationBarrier) SignalDependants(job interface{}, allow bool) {
 		}
 
 		delete(v.chanEdgeDependencies, msg.ShortChannelID)
+	case *lnwire.ChannelAnnouncement2:
+		shortID := msg.ShortChannelID.Val
+		finSignals, ok := v.chanAnnFinSignal[shortID]
+		if ok {
+			if allow {
+				close(finSignals.allow)
+			} else {
+				close(finSignals.deny)
+			}
+			delete(v.chanAnnFinSignal, shortID)
+		}
+
+		delete(v.chanEdgeDependencies, shortID)
 
 	// For all other job types, we'll delete the tracking entries from the
 	// map, as if we reach this point, then all dependants have already
@@ -297,11 +337,15 @@ func (v *ValidationBarrier) SignalDependants(job interface{}, allow bool) {
 		delete(v.nodeAnnDependencies, route.Vertex(msg.NodeID))
 	case *lnwire.ChannelUpdate:
 		delete(v.chanEdgeDependencies, msg.ShortChannelID)
+	case *lnwire.ChannelUpdate2:
+		delete(v.chanEdgeDependencies, msg.ShortChannelID.Val)
 	case *models.ChannelEdgePolicy:
 		shortID := lnwire.NewShortChanIDFromInt(msg.ChannelID)
 		delete(v.chanEdgeDependencies, shortID)
 
 	case *lnwire.AnnounceSignatures:
 		return
+	case *lnwire.AnnounceSignatures2:
+		return
 	}
 }
@@ -0,0 +1,646 @@
+package rpcperms
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jrick/logrotate/rotator"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/subscribe"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+	"gopkg.in/macaroon.v2"
+)
+
+const (
+	// maxSummaryString is the maximum length of a string value in the
+	// request summary of an audit entry.
+	maxSummaryString = 256
+
+	// maxSummaryList is the maximum number of elements of a list that are
+	// included in the request summary of an audit entry.
+	maxSummaryList = 10
+
+	// redacted replaces the value of fields that aren't audited in the
+	// request summary of an audit entry.
+	redacted = "<redacted>"
+)
+
+// restProxyTokenKey is the metadata key of the token that identifies calls made
+// by our own REST proxy.
+const restProxyTokenKey = "lnd-rest-proxy-token"
+
+// auditedFields are the request fields of each method whose values are written
+// to the audit log, as paths of dot separated field names. A path of a message
+// field includes all of its subfields. The values of all other fields are
+// redacted, so that secrets such as passwords, seeds, keys or payment secrets
+// never end up in the log, even once new fields are added to a request.
+var auditedFields = map[string][]string{
+	"/lnrpc.Lightning/OpenChannel":     openChannelFields,
+	"/lnrpc.Lightning/OpenChannelSync": openChannelFields,
+	"/lnrpc.Lightning/CloseChannel": {
+		"channel_point", "force", "target_conf", "delivery_address",
+		"sat_per_vbyte", "max_fee_per_vbyte", "no_wait",
+	},
+	"/lnrpc.Lightning/AbandonChannel": {
+		"channel_point", "pending_funding_shim_only",
+		"i_know_what_i_am_doing",
+	},
+	"/lnrpc.Lightning/SendCoins": {
+		"addr", "amount", "target_conf", "sat_per_vbyte", "send_all",
+		"label", "min_confs", "spend_unconfirmed",
+		"coin_selection_strategy",
+	},
+	"/lnrpc.Lightning/SendMany": {
+		"addr_to_amount", "target_conf", "sat_per_vbyte", "label",
+		"min_confs", "spend_unconfirmed", "coin_selection_strategy",
+	},
+	"/lnrpc.Lightning/ConnectPeer": {
+		"addr", "perm", "timeout",
+	},
+	"/lnrpc.Lightning/DisconnectPeer": {
+		"pub_key",
+	},
+	"/lnrpc.Lightning/AddInvoice": {
+		"memo", "value", "value_msat", "description_hash", "expiry",
+		"fallback_addr", "cltv_expiry", "private", "is_keysend",
+		"is_amp",
+	},
+	"/lnrpc.Lightning/SendPayment":     sendPaymentFields,
+	"/lnrpc.Lightning/SendPaymentSync": sendPaymentFields,
+	"/lnrpc.Lightning/UpdateChannelPolicy": {
+		"global", "chan_point", "base_fee_msat", "fee_rate",
+		"fee_rate_ppm", "time_lock_delta", "max_htlc_msat",
+		"min_htlc_msat", "min_htlc_msat_specified",
+		"inbound_base_fee_msat", "inbound_fee_rate_ppm",
+	},
+	"/lnrpc.Lightning/BakeMacaroon": {
+		"permissions", "root_key_id", "allow_external_permissions",
+	},
+	"/routerrpc.Router/SendPaymentV2": {
+		"dest", "amt", "amt_msat", "payment_hash", "final_cltv_delta",
+		"timeout_seconds", "fee_limit_sat", "fee_limit_msat",
+		"outgoing_chan_id", "outgoing_chan_ids", "last_hop_pubkey",
+		"cltv_limit", "allow_self_payment", "max_parts", "amp",
+		"time_pref",
+	},
+	"/lnrpc.WalletUnlocker/InitWallet": {
+		"recovery_window", "stateless_init",
+		"extended_master_key_birthday_timestamp", "watch_only",
+	},
+	"/lnrpc.WalletUnlocker/UnlockWallet": {
+		"recovery_window", "stateless_init",
+	},
+	"/lnrpc.WalletUnlocker/ChangePassword": {
+		"stateless_init",
+	},
+}
+
+// openChannelFields are the audited fields of the requests that open a
+// channel.
+var openChannelFields = []string{
+	"sat_per_vbyte", "node_pubkey", "local_funding_amount", "push_sat",
+	"target_conf", "private", "min_htlc_msat", "remote_csv_delay",
+	"min_confs", "spend_unconfirmed", "close_address",
+	"funding_shim.chan_point_shim.amt",
+	"funding_shim.chan_point_shim.chan_point",
+	"funding_shim.chan_point_shim.thaw_height",
+	"funding_shim.psbt_shim.no_publish",
+	"remote_max_value_in_flight_msat", "remote_max_htlcs", "max_local_csv",
+	"commitment_type", "zero_conf", "scid_alias", "base_fee", "fee_rate",
+	"use_base_fee", "use_fee_rate", "remote_chan_reserve_sat", "fund_max",
+	"memo", "outpoints",
+}
+
+// sendPaymentFields are the audited fields of the requests of the deprecated
+// payment RPCs. The payment request isn't included, as it contains the
+// payment secret.
+var sendPaymentFields = []string{
+	"dest", "amt", "amt_msat", "payment_hash", "final_cltv_delta",
+	"fee_limit", "outgoing_chan_id", "last_hop_pubkey", "cltv_limit",
+	"allow_self_payment",
+}
+
+// AuditEntry is a single entry of the audit log, recording one call of a
+// mutating RPC.
+type AuditEntry struct {
+	// Timestamp is the time the call was received.
+	Timestamp time.Time `json:"timestamp"`
+
+	// Method is the full URI of the called method.
+	Method string `json:"method"`
+
+	// RootKeyID is the ID of the root key of the macaroon the call was
+	// authenticated with. It's empty if the call didn't carry a macaroon.
+	RootKeyID string `json:"root_key_id,omitempty"`
+
+	// Caveats are the first party caveats of the macaroon.
+	Caveats []string `json:"caveats,omitempty"`
+
+	// ClientIP is the IP address of the client. For calls made through
+	// the REST proxy, this is the address of the REST client.
+	ClientIP string `json:"client_ip,omitempty"`
+
+	// Request is a JSON summary of the request, with the values of all
+	// fields that aren't audited for the method redacted.
+	Request string `json:"request,omitempty"`
+
+	// Error is the error the call returned, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+
+	// Latency is the time it took to serve the call. For streaming calls
+	// this is the lifetime of the stream.
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// AuditLogConfig holds the configuration of the audit log.
+type AuditLogConfig struct {
+	// Path is the path of the audit log file. Rotated files are stored
+	// next to it.
+	Path string
+
+	// MaxFileSize is the size in MB after which the audit log file is
+	// rotated.
+	MaxFileSize int
+
+	// MaxFiles is the maximum number of rotated files to keep, 0 keeps all
+	// of them.
+	MaxFiles int
+}
+
+// AuditLog is an append-only log of all calls of mutating RPCs. Entries are
+// written as JSON lines to a rotating file and sent to all subscribers.
+type AuditLog struct {
+	started sync.Once
+	stopped sync.Once
+
+	cfg *AuditLogConfig
+
+	// ntfnServer is used to send new entries to subscribers.
+	ntfnServer *subscribe.Server
+
+	// mu guards the rotator, so entries are never interleaved and the log
+	// file isn't read while an entry is written.
+	mu      sync.Mutex
+	rotator *rotator.Rotator
+
+	// restProxyToken is the random token our REST proxy attaches to its
+	// calls. Only the client address forwarded by calls carrying it is
+	// trusted.
+	restProxyToken string
+}
+
+// NewAuditLog creates a new audit log.
+func NewAuditLog(cfg *AuditLogConfig) (*AuditLog, error) {
+	var token [32]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return nil, err
+	}
+
+	return &AuditLog{
+		cfg:            cfg,
+		ntfnServer:     subscribe.NewServer(),
+		restProxyToken: hex.EncodeToString(token[:]),
+	}, nil
+}
+
+// Start opens the audit log file.
+func (a *AuditLog) Start() error {
+	var err error
+	a.started.Do(func() {
+		err = os.MkdirAll(filepath.Dir(a.cfg.Path), 0700)
+		if err != nil {
+			return
+		}
+
+		a.rotator, err = rotator.New(
+			a.cfg.Path, int64(a.cfg.MaxFileSize*1024), false,
+			a.cfg.MaxFiles,
+		)
+		if err != nil {
+			return
+		}
+
+		err = a.ntfnServer.Start()
+	})
+
+	return err
+}
+
+// Stop closes the audit log file.
+func (a *AuditLog) Stop() error {
+	var err error
+	a.stopped.Do(func() {
+		if err = a.ntfnServer.Stop(); err != nil {
+			return
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.rotator != nil {
+			err = a.rotator.Close()
+		}
+	})
+
+	return err
+}
+
+// Record appends the entry to the audit log and sends it to all subscribers.
+func (a *AuditLog) Record(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	_, err = a.rotator.Write(line)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return a.ntfnServer.SendUpdate(entry)
+}
+
+// RestProxyMetadata returns the metadata our REST proxy attaches to the calls
+// it forwards, which marks the client address it forwards as trustworthy. It
+// is meant to be passed to the REST proxy with runtime.WithMetadata.
+func (a *AuditLog) RestProxyMetadata(context.Context,
+	*http.Request) metadata.MD {
+
+	return metadata.Pairs(restProxyTokenKey, a.restProxyToken)
+}
+
+// Subscribe returns a client that receives every new entry of the audit log.
+func (a *AuditLog) Subscribe() (*subscribe.Client, error) {
+	return a.ntfnServer.Subscribe()
+}
+
+// Query returns all entries of the audit log with a timestamp in the given
+// range, oldest first. A zero end time doesn't bound the range. If method is
+// set, only entries of that method are returned.
+func (a *AuditLog) Query(start, end time.Time,
+	method string) ([]*AuditEntry, error) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	files, err := a.logFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*AuditEntry
+	for _, file := range files {
+		fileEntries, err := readAuditFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range fileEntries {
+			switch {
+			case entry.Timestamp.Before(start):
+				continue
+
+			case !end.IsZero() && entry.Timestamp.After(end):
+				continue
+
+			case method != "" && entry.Method != method:
+				continue
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// logFiles returns the rotated audit log files followed by the current one,
+// oldest first.
+func (a *AuditLog) logFiles() ([]string, error) {
+	rolled, err := filepath.Glob(a.cfg.Path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	// Rotated files are compressed in the background, so a file can
+	// briefly exist both compressed and uncompressed. The uncompressed one
+	// is complete in that case.
+	files := make(map[int]string)
+	for _, name := range rolled {
+		suffix := strings.TrimPrefix(name, a.cfg.Path+".")
+		compressed := strings.HasSuffix(suffix, ".gz")
+		num, err := strconv.Atoi(strings.TrimSuffix(suffix, ".gz"))
+		if err != nil {
+			continue
+		}
+
+		if _, ok := files[num]; ok && compressed {
+			continue
+		}
+		files[num] = name
+	}
+
+	nums := make([]int, 0, len(files))
+	for num := range files {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+
+	paths := make([]string, 0, len(nums)+1)
+	for _, num := range nums {
+		paths = append(paths, files[num])
+	}
+
+	return append(paths, a.cfg.Path), nil
+}
+
+// readAuditFile reads all entries of a, possibly compressed, audit log file.
+func readAuditFile(path string) ([]*AuditEntry, error) {
+	f, err := os.Open(path)
+
+	// The rotated file might have been compressed since it was listed.
+	if os.IsNotExist(err) && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+		f, err = os.Open(path)
+	}
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %v: %w", path,
+				err)
+		}
+		defer gz.Close()
+
+		r = gz
+	}
+
+	var entries []*AuditEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		entry := &AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("invalid entry in %v: %w", path,
+				err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// newEntry creates the audit entry of a call, identifying the caller by its
+// macaroon and address.
+func (a *AuditLog) newEntry(ctx context.Context, method string,
+	req interface{}) *AuditEntry {
+
+	entry := &AuditEntry{
+		Timestamp: time.Now(),
+		Method:    method,
+		ClientIP:  a.clientIP(ctx),
+		Request:   summarizeRequest(method, req),
+	}
+
+	// A macaroon that can't be decoded is rejected by the macaroon
+	// interceptor, which is recorded as the result of the call.
+	mac, _, err := macaroonFromContext(ctx)
+	if err != nil || mac == nil {
+		return entry
+	}
+
+	for _, caveat := range mac.Caveats() {
+		if caveat.VerificationId != nil {
+			continue
+		}
+
+		entry.Caveats = append(entry.Caveats, string(caveat.Id))
+	}
+	entry.RootKeyID = rootKeyID(mac)
+
+	return entry
+}
+
+// rootKeyID returns the ID of the root key of a macaroon, or an empty string
+// if the macaroon ID can't be decoded.
+func rootKeyID(mac *macaroon.Macaroon) string {
+	rawID := mac.Id()
+	if len(rawID) == 0 || rawID[0] != byte(bakery.LatestVersion) {
+		return ""
+	}
+
+	macID := &lnrpc.MacaroonId{}
+	if err := proto.Unmarshal(rawID[1:], macID); err != nil {
+		return ""
+	}
+
+	return string(macID.StorageId)
+}
+
+// clientIP returns the IP address of the client of a call. Calls proxied by
+// our REST proxy carry the address of the REST client in their metadata, which
+// is ignored for all other calls as any client could set it.
+func (a *AuditLog) clientIP(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	forwarded := md.Get("x-forwarded-for")
+	if len(forwarded) > 0 && a.fromRestProxy(md) {
+		// The REST proxy appends the address of the REST client to
+		// the addresses forwarded by the client itself, so only the
+		// last one can be trusted.
+		addrs := strings.Split(forwarded[len(forwarded)-1], ",")
+		return strings.TrimSpace(addrs[len(addrs)-1])
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
+
+// fromRestProxy returns true if the call with the given metadata was made by
+// our REST proxy.
+func (a *AuditLog) fromRestProxy(md metadata.MD) bool {
+	for _, token := range md.Get(restProxyTokenKey) {
+		if subtle.ConstantTimeCompare(
+			[]byte(token), []byte(a.restProxyToken),
+		) == 1 {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// summarizeRequest returns a JSON summary of a request of the given method.
+// Only the values of the audited fields of the method are included, and long
+// values are truncated so the log can't be flooded.
+func summarizeRequest(method string, req interface{}) string {
+	msg, ok := req.(proto.Message)
+	if !ok || msg == nil {
+		return ""
+	}
+
+	audited := make(map[string]struct{})
+	for _, path := range auditedFields[method] {
+		audited[path] = struct{}{}
+	}
+
+	summary, err := json.Marshal(
+		summarizeMessage(msg.ProtoReflect(), "", audited),
+	)
+	if err != nil {
+		return ""
+	}
+
+	return string(summary)
+}
+
+// summarizeMessage summarizes all populated fields of a message whose path
+// starts with the given prefix. The values of fields that aren't audited are
+// redacted. A nil set of audited fields includes all values.
+func summarizeMessage(msg protoreflect.Message, prefix string,
+	audited map[string]struct{}) map[string]interface{} {
+
+	summary := make(map[string]interface{})
+	msg.Range(func(fd protoreflect.FieldDescriptor,
+		v protoreflect.Value) bool {
+
+		name := string(fd.Name())
+		path := prefix + name
+
+		_, isAudited := audited[path]
+		switch {
+		// Some of the subfields of a message might be audited, so
+		// we'll only summarize those.
+		case audited != nil && !isAudited &&
+			fd.Kind() == protoreflect.MessageKind &&
+			fd.Cardinality() != protoreflect.Repeated &&
+			hasAuditedSubfield(path, audited):
+
+			summary[name] = summarizeMessage(
+				v.Message(), path+".", audited,
+			)
+
+		case audited != nil && !isAudited:
+			summary[name] = redacted
+
+		case fd.IsMap():
+			entries := v.Map().Len()
+			summary[name] = fmt.Sprintf("<%d entries>", entries)
+
+		case fd.IsList():
+			list := v.List()
+			values := make([]interface{}, 0, maxSummaryList)
+			for i := 0; i < list.Len() && i < maxSummaryList; i++ {
+				values = append(
+					values, summarizeValue(fd, list.Get(i)),
+				)
+			}
+			if list.Len() > maxSummaryList {
+				values = append(values, fmt.Sprintf(
+					"<%d more>", list.Len()-maxSummaryList,
+				))
+			}
+			summary[name] = values
+
+		default:
+			summary[name] = summarizeValue(fd, v)
+		}
+
+		return true
+	})
+
+	return summary
+}
+
+// summarizeValue summarizes a single value of a field.
+func summarizeValue(fd protoreflect.FieldDescriptor,
+	v protoreflect.Value) interface{} {
+
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return summarizeMessage(v.Message(), "", nil)
+
+	// Short byte values are mostly public keys and hashes, which identify
+	// what the call acted on. Longer ones are transactions, PSBTs or
+	// blobs that aren't useful in an audit log.
+	case protoreflect.BytesKind:
+		b := v.Bytes()
+		if len(b) > 33 {
+			return fmt.Sprintf("<%d bytes>", len(b))
+		}
+
+		return hex.EncodeToString(b)
+
+	case protoreflect.StringKind:
+		s := v.String()
+		if len(s) > maxSummaryString {
+			return s[:maxSummaryString] + "..."
+		}
+
+		return s
+
+	case protoreflect.EnumKind:
+		enumValue := fd.Enum().Values().ByNumber(v.Enum())
+		if enumValue == nil {
+			return int32(v.Enum())
+		}
+
+		return string(enumValue.Name())
+
+	default:
+		return v.Interface()
+	}
+}
+
+// hasAuditedSubfield returns true if any subfield of the field with the given
+// path is audited.
+func hasAuditedSubfield(path string, audited map[string]struct{}) bool {
+	for auditedPath := range audited {
+		if strings.HasPrefix(auditedPath, path+".") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,297 @@
+package rpcperms
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btclog"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+	"gopkg.in/macaroon.v2"
+)
+
+// newTestAuditLog creates and starts an audit log in a temporary directory.
+func newTestAuditLog(t *testing.T) *AuditLog {
+	auditLog, err := NewAuditLog(&AuditLogConfig{
+		Path:        filepath.Join(t.TempDir(), "audit.log"),
+		MaxFileSize: 10,
+	})
+	require.NoError(t, err)
+	require.NoError(t, auditLog.Start())
+	t.Cleanup(func() {
+		require.NoError(t, auditLog.Stop())
+	})
+
+	return auditLog
+}
+
+// TestAuditLogQuery tests that entries are read back from the current and the
+// rotated audit log files in order.
+func TestAuditLogQuery(t *testing.T) {
+	t.Parallel()
+
+	auditLog := newTestAuditLog(t)
+
+	// Write an entry to a rotated, compressed file.
+	rotated := &AuditEntry{
+		Timestamp: time.Unix(100, 0),
+		Method:    "/lnrpc.Lightning/OpenChannelSync",
+	}
+	f, err := os.Create(auditLog.cfg.Path + ".1.gz")
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	require.NoError(t, json.NewEncoder(gz).Encode(rotated))
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	subscription, err := auditLog.Subscribe()
+	require.NoError(t, err)
+	defer subscription.Cancel()
+
+	entries := []*AuditEntry{{
+		Timestamp: time.Unix(200, 0),
+		Method:    "/lnrpc.Lightning/CloseChannel",
+		RootKeyID: "0",
+		Latency:   time.Second,
+	}, {
+		Timestamp: time.Unix(300, 0),
+		Method:    "/lnrpc.Lightning/OpenChannelSync",
+		Error:     "not enough funds",
+	}}
+	for _, entry := range entries {
+		require.NoError(t, auditLog.Record(entry))
+
+		select {
+		case update := <-subscription.Updates():
+			require.Equal(t, entry, update)
+
+		case <-time.After(time.Second):
+			t.Fatal("entry not sent to subscriber")
+		}
+	}
+
+	all, err := auditLog.Query(time.Time{}, time.Time{}, "")
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	require.Equal(t, rotated.Method, all[0].Method)
+	require.Equal(t, entries[0].RootKeyID, all[1].RootKeyID)
+	require.Equal(t, entries[0].Latency, all[1].Latency)
+	require.Equal(t, entries[1].Error, all[2].Error)
+
+	filtered, err := auditLog.Query(
+		time.Unix(100, 0), time.Unix(299, 0), rotated.Method,
+	)
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	require.True(t, filtered[0].Timestamp.Equal(rotated.Timestamp))
+}
+
+// TestSummarizeRequest tests that only the values of the audited fields of a
+// method are included in request summaries.
+func TestSummarizeRequest(t *testing.T) {
+	t.Parallel()
+
+	summarize := func(method string,
+		req proto.Message) map[string]interface{} {
+
+		t.Helper()
+
+		var fields map[string]interface{}
+		require.NoError(t, json.Unmarshal(
+			[]byte(summarizeRequest(method, req)), &fields,
+		))
+
+		return fields
+	}
+
+	// None of the secrets of a new wallet should be logged.
+	fields := summarize(
+		"/lnrpc.WalletUnlocker/InitWallet", &lnrpc.InitWalletRequest{
+			WalletPassword:     []byte("password"),
+			CipherSeedMnemonic: []string{"abandon", "ability"},
+			AezeedPassphrase:   []byte("passphrase"),
+			RecoveryWindow:     10,
+			ExtendedMasterKey:  "xprv9s21ZrQH143K",
+			MacaroonRootKey:    make([]byte, 32),
+		},
+	)
+	require.Equal(t, map[string]interface{}{
+		"wallet_password":      redacted,
+		"cipher_seed_mnemonic": redacted,
+		"aezeed_passphrase":    redacted,
+		"recovery_window":      float64(10),
+		"extended_master_key":  redacted,
+		"macaroon_root_key":    redacted,
+	}, fields)
+
+	// The preimage and payment secret of an invoice mustn't be logged
+	// either.
+	fields = summarize("/lnrpc.Lightning/AddInvoice", &lnrpc.Invoice{
+		Memo:        "coffee",
+		RPreimage:   make([]byte, 32),
+		Value:       1000,
+		PaymentAddr: make([]byte, 32),
+	})
+	require.Equal(t, map[string]interface{}{
+		"memo":         "coffee",
+		"r_preimage":   redacted,
+		"value":        float64(1000),
+		"payment_addr": redacted,
+	}, fields)
+
+	// Only the audited subfields of a message are included.
+	pubKey := make([]byte, 33)
+	psbtShim := &lnrpc.PsbtShim{
+		PendingChanId: make([]byte, 32),
+		BasePsbt:      make([]byte, 100),
+		NoPublish:     true,
+	}
+	fields = summarize(
+		"/lnrpc.Lightning/OpenChannelSync", &lnrpc.OpenChannelRequest{
+			NodePubkey:         pubKey,
+			LocalFundingAmount: 100_000,
+			Private:            true,
+			CommitmentType:     lnrpc.CommitmentType_ANCHORS,
+			FundingShim: &lnrpc.FundingShim{
+				Shim: &lnrpc.FundingShim_PsbtShim{
+					PsbtShim: psbtShim,
+				},
+			},
+		},
+	)
+	require.Equal(t, map[string]interface{}{
+		"node_pubkey":          hex.EncodeToString(pubKey),
+		"local_funding_amount": float64(100_000),
+		"private":              true,
+		"commitment_type":      "ANCHORS",
+		"funding_shim": map[string]interface{}{
+			"psbt_shim": map[string]interface{}{
+				"pending_chan_id": redacted,
+				"base_psbt":       redacted,
+				"no_publish":      true,
+			},
+		},
+	}, fields)
+
+	// The values of all fields of methods without audited fields are
+	// redacted.
+	fields = summarize(
+		"/lnrpc.Lightning/Unknown", &lnrpc.ConnectPeerRequest{
+			Perm: true,
+		},
+	)
+	require.Equal(t, map[string]interface{}{"perm": redacted}, fields)
+}
+
+// TestAuditClientIP tests that the forwarded address of a client is only
+// trusted for calls made by our REST proxy.
+func TestAuditClientIP(t *testing.T) {
+	t.Parallel()
+
+	auditLog := newTestAuditLog(t)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 10009},
+	})
+	forwarded := metadata.Pairs("x-forwarded-for", "1.1.1.1, 2.2.2.2")
+
+	// A client calling us directly can't forge its address.
+	direct := metadata.NewIncomingContext(ctx, forwarded)
+	require.Equal(t, "127.0.0.1", auditLog.clientIP(direct))
+
+	forged := metadata.NewIncomingContext(ctx, metadata.Join(
+		forwarded, metadata.Pairs(restProxyTokenKey, "forged"),
+	))
+	require.Equal(t, "127.0.0.1", auditLog.clientIP(forged))
+
+	// For calls of our REST proxy, the address it appended is used, not
+	// the ones the REST client forwarded itself.
+	proxied := metadata.NewIncomingContext(ctx, metadata.Join(
+		forwarded, auditLog.RestProxyMetadata(ctx, nil),
+	))
+	require.Equal(t, "2.2.2.2", auditLog.clientIP(proxied))
+}
+
+// TestAuditInterceptor tests that mutating calls are recorded with the
+// identity of their macaroon, and read-only calls aren't recorded.
+func TestAuditInterceptor(t *testing.T) {
+	t.Parallel()
+
+	chain := NewInterceptorChain(btclog.Disabled, false, nil)
+	require.NoError(t, chain.AddPermission(
+		"/lnrpc.Lightning/CloseChannel", []bakery.Op{{
+			Entity: "offchain", Action: "write",
+		}},
+	))
+	require.NoError(t, chain.AddPermission(
+		"/lnrpc.Lightning/GetInfo", []bakery.Op{{
+			Entity: "info", Action: "read",
+		}},
+	))
+
+	auditLog := newTestAuditLog(t)
+	chain.SetAuditLog(auditLog)
+
+	// Create a macaroon with a root key ID and a caveat, and attach it to
+	// the context of the call together with the address of the client.
+	macID, err := proto.Marshal(&lnrpc.MacaroonId{StorageId: []byte("7")})
+	require.NoError(t, err)
+	mac, err := macaroon.New(
+		[]byte("root key"),
+		append([]byte{byte(bakery.LatestVersion)}, macID...), "lnd",
+		macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+	require.NoError(t, mac.AddFirstPartyCaveat([]byte("ipaddr 1.2.3.4")))
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	ctx := metadata.NewIncomingContext(
+		context.Background(), metadata.Pairs(
+			"macaroon", hex.EncodeToString(macBytes),
+		),
+	)
+	ctx = peer.NewContext(ctx, &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 10009},
+	})
+
+	interceptor := chain.auditUnaryServerInterceptor()
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("channel not found")
+	}
+
+	for _, method := range []string{
+		"/lnrpc.Lightning/CloseChannel", "/lnrpc.Lightning/GetInfo",
+	} {
+		_, err = interceptor(
+			ctx, &lnrpc.CloseChannelRequest{Force: true},
+			&grpc.UnaryServerInfo{FullMethod: method}, handler,
+		)
+		require.Error(t, err)
+	}
+
+	entries, err := auditLog.Query(time.Time{}, time.Time{}, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	entry := entries[0]
+	require.Equal(t, "/lnrpc.Lightning/CloseChannel", entry.Method)
+	require.Equal(t, "7", entry.RootKeyID)
+	require.Equal(t, []string{"ipaddr 1.2.3.4"}, entry.Caveats)
+	require.Equal(t, "1.2.3.4", entry.ClientIP)
+	require.Equal(t, `{"force":true}`, entry.Request)
+	require.Equal(t, "channel not found", entry.Error)
+}
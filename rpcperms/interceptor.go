@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/btcsuite/btclog"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -48,6 +50,10 @@ const (
 
 	// serverActive means that the lnd server is ready to accept calls.
 	serverActive
+
+	// signerUnavailable means that the lnd server is active, but none of
+	// its remote signers can be reached.
+	signerUnavailable
 )
 
 var (
@@ -189,6 +195,15 @@ type InterceptorChain struct {
 	// middleware crashes.
 	mandatoryMiddleware []string
 
+	// auditLog is the log all calls of mutating RPCs are recorded in, nil
+	// if the audit log is disabled.
+	auditLog *AuditLog
+
+	// rateLimiter enforces the rate limits and stream quotas of RPC calls.
+	// Unless replaced by one with configured limits, it only enforces the
+	// limits of macaroon caveats.
+	rateLimiter *RateLimiter
+
 	quit chan struct{}
 	sync.RWMutex
 }
@@ -209,6 +224,7 @@ func NewInterceptorChain(log btclog.Logger, noMacaroons bool,
 		rpcsLog:                   log,
 		registeredMiddlewareNames: make(map[string]int),
 		mandatoryMiddleware:       mandatoryMiddleware,
+		rateLimiter:               NewRateLimiter(&RateLimiterConfig{}),
 		quit:                      make(chan struct{}),
 	}
 }
@@ -283,6 +299,27 @@ func (r *InterceptorChain) SetServerActive() {
 	_ = r.ntfnServer.SendUpdate(r.state)
 }
 
+// SetSignerAvailable moves the RPC state from serverActive to
+// signerUnavailable if the remote signers can't be reached anymore, and back
+// once they're reachable again. It doesn't change any other state.
+func (r *InterceptorChain) SetSignerAvailable(available bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	switch {
+	case !available && r.state == serverActive:
+		r.state = signerUnavailable
+
+	case available && r.state == signerUnavailable:
+		r.state = serverActive
+
+	default:
+		return
+	}
+
+	_ = r.ntfnServer.SendUpdate(r.state)
+}
+
 // rpcStateToWalletState converts rpcState to lnrpc.WalletState. Returns
 // WAITING_TO_START and an error on conversion error.
 func rpcStateToWalletState(state rpcState) (lnrpc.WalletState, error) {
@@ -302,6 +339,8 @@ func rpcStateToWalletState(state rpcState) (lnrpc.WalletState, error) {
 		walletState = lnrpc.WalletState_RPC_ACTIVE
 	case serverActive:
 		walletState = lnrpc.WalletState_SERVER_ACTIVE
+	case signerUnavailable:
+		walletState = lnrpc.WalletState_SIGNER_UNAVAILABLE
 
 	default:
 		return defaultState, fmt.Errorf("unknown wallet state %v", state)
@@ -411,6 +450,31 @@ func (r *InterceptorChain) MacaroonService() *macaroons.Service {
 	return r.svc
 }
 
+// SetAuditLog sets the log all calls of mutating RPCs are recorded in.
+func (r *InterceptorChain) SetAuditLog(auditLog *AuditLog) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.auditLog = auditLog
+}
+
+// AuditLog returns the audit log, or nil if it is disabled.
+func (r *InterceptorChain) AuditLog() *AuditLog {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.auditLog
+}
+
+// SetRateLimiter sets the rate limiter that enforces the rate limits and
+// stream quotas of RPC calls.
+func (r *InterceptorChain) SetRateLimiter(rateLimiter *RateLimiter) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.rateLimiter = rateLimiter
+}
+
 // AddPermission adds a new macaroon rule for the given method.
 func (r *InterceptorChain) AddPermission(method string, ops []bakery.Op) error {
 	r.Lock()
@@ -546,6 +610,15 @@ func (r *InterceptorChain) CreateServerOpts() []grpc.ServerOption {
 		strmInterceptors, errorLogStreamServerInterceptor(r.rpcsLog),
 	)
 
+	// The audit interceptors come before any check, so that rejected calls
+	// are recorded as well.
+	unaryInterceptors = append(
+		unaryInterceptors, r.auditUnaryServerInterceptor(),
+	)
+	strmInterceptors = append(
+		strmInterceptors, r.auditStreamServerInterceptor(),
+	)
+
 	// Next we'll add our RPC state check interceptors, that will check
 	// whether the attempted call is allowed in the current state.
 	unaryInterceptors = append(
@@ -564,6 +637,15 @@ func (r *InterceptorChain) CreateServerOpts() []grpc.ServerOption {
 		strmInterceptors, r.MacaroonStreamServerInterceptor(),
 	)
 
+	// The rate limit interceptors come after the macaroon check, so that
+	// calls with forged macaroons can't exhaust the limits of others.
+	unaryInterceptors = append(
+		unaryInterceptors, r.rateLimitUnaryServerInterceptor(),
+	)
+	strmInterceptors = append(
+		strmInterceptors, r.rateLimitStreamServerInterceptor(),
+	)
+
 	// Next, we'll add the interceptors for our custom macaroon caveat based
 	// middleware.
 	unaryInterceptors = append(
@@ -752,8 +834,9 @@ func (r *InterceptorChain) checkRPCState(srv interface{}) error {
 		return ErrRPCStarting
 
 	// If the RPC server or lnd server is active, we allow calls to any
-	// service except the WalletUnlocker.
-	case rpcActive, serverActive:
+	// service except the WalletUnlocker. Without a reachable remote signer
+	// calls that need to sign will fail, but all others still work.
+	case rpcActive, serverActive, signerUnavailable:
 		_, ok := srv.(lnrpc.WalletUnlockerServer)
 		if ok {
 			return ErrWalletUnlocked
@@ -1110,3 +1193,180 @@ func (w *serverStreamWrapper) RecvMsg(m interface{}) error {
 
 	return replaceProtoMsg(m, req)
 }
+
+// isAudited returns true if calls of the given method are recorded in the
+// audit log, which is the case for all methods that don't only read.
+func (r *InterceptorChain) isAudited(fullMethod string) bool {
+	// The State service only reports the state of lnd.
+	if strings.HasPrefix(fullMethod, "/lnrpc.State/") {
+		return false
+	}
+
+	r.RLock()
+	ops, ok := r.permissionMap[fullMethod]
+	r.RUnlock()
+
+	// Methods without permissions are the calls of the wallet unlocker
+	// that create or unlock the wallet, or unknown methods that are
+	// rejected by the macaroon interceptor.
+	if !ok {
+		return true
+	}
+
+	for _, op := range ops {
+		if op.Action != "read" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordAudit completes the audit entry of a call with its result and appends
+// it to the audit log.
+func (r *InterceptorChain) recordAudit(auditLog *AuditLog, entry *AuditEntry,
+	err error) {
+
+	entry.Latency = time.Since(entry.Timestamp)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if err := auditLog.Record(entry); err != nil {
+		r.rpcsLog.Errorf("Unable to record call of %v in audit log: %v",
+			entry.Method, err)
+	}
+}
+
+// auditUnaryServerInterceptor is a unary gRPC interceptor that records all
+// calls of mutating RPCs in the audit log.
+func (r *InterceptorChain) auditUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		auditLog := r.AuditLog()
+		if auditLog == nil || !r.isAudited(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		entry := auditLog.newEntry(ctx, info.FullMethod, req)
+		resp, err := handler(ctx, req)
+		r.recordAudit(auditLog, entry, err)
+
+		return resp, err
+	}
+}
+
+// auditServerStream wraps a server stream to capture the first request the
+// client sends, which is the request of server streaming RPCs.
+type auditServerStream struct {
+	grpc.ServerStream
+
+	entry *AuditEntry
+}
+
+// RecvMsg receives a message from the client and summarizes it in the audit
+// entry if it is the first one.
+//
+// NOTE: This is part of the grpc.ServerStream interface.
+func (s *auditServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil && s.entry.Request == "" {
+		s.entry.Request = summarizeRequest(s.entry.Method, m)
+	}
+
+	return err
+}
+
+// auditStreamServerInterceptor is a streaming gRPC interceptor that records
+// all calls of mutating RPCs in the audit log once the stream ends.
+func (r *InterceptorChain) auditStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		auditLog := r.AuditLog()
+		if auditLog == nil || !r.isAudited(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		entry := auditLog.newEntry(
+			ss.Context(), info.FullMethod, nil,
+		)
+		err := handler(srv, &auditServerStream{
+			ServerStream: ss,
+			entry:        entry,
+		})
+		r.recordAudit(auditLog, entry, err)
+
+		return err
+	}
+}
+
+// getRateLimiter returns the rate limiter, or nil if limits are disabled.
+func (r *InterceptorChain) getRateLimiter() *RateLimiter {
+	r.RLock()
+	defer r.RUnlock()
+
+	return r.rateLimiter
+}
+
+// rateLimitUnaryServerInterceptor is a unary gRPC interceptor that rejects
+// calls that exceed their rate limits.
+func (r *InterceptorChain) rateLimitUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+
+		rateLimiter := r.getRateLimiter()
+		if rateLimiter == nil {
+			return handler(ctx, req)
+		}
+
+		rootKeyID, limits, err := callIdentity(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		err = rateLimiter.Allow(rootKeyID, info.FullMethod, limits)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// rateLimitStreamServerInterceptor is a streaming gRPC interceptor that
+// rejects streams that exceed their rate limits or the maximum number of
+// concurrent streams.
+func (r *InterceptorChain) rateLimitStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream,
+		info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+
+		rateLimiter := r.getRateLimiter()
+		if rateLimiter == nil {
+			return handler(srv, ss)
+		}
+
+		rootKeyID, limits, err := callIdentity(ss.Context())
+		if err != nil {
+			return err
+		}
+
+		err = rateLimiter.Allow(rootKeyID, info.FullMethod, limits)
+		if err != nil {
+			return err
+		}
+
+		release, err := rateLimiter.AcquireStream(
+			rootKeyID, info.FullMethod, limits,
+		)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,251 @@
+package rpcperms
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lightningnetwork/lnd/macaroons"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxIdleBuckets is the number of token buckets after which buckets
+	// that have been refilled completely are removed. A full bucket is
+	// equivalent to a new one, so removing it doesn't loosen any limit.
+	maxIdleBuckets = 10000
+)
+
+// RateLimiterConfig holds the limits enforced on RPC calls. The limits apply
+// to all calls of a method made with macaroons of the same root key ID. Rate
+// limit and max streams caveats of a macaroon apply on top of them and are
+// shared by all macaroons derived from the one the caveat was added to.
+type RateLimiterConfig struct {
+	// DefaultLimit is the rate limit of all methods that don't have their
+	// own. A zero rate doesn't limit the calls.
+	DefaultLimit macaroons.RateLimit
+
+	// MethodLimits are the rate limits of individual methods, keyed by
+	// their full URI.
+	MethodLimits map[string]macaroons.RateLimit
+
+	// MaxStreams is the maximum number of concurrent streams of each
+	// streaming method. Zero doesn't limit the streams.
+	MaxStreams uint32
+}
+
+// limiterKey identifies the calls a limit is enforced on.
+type limiterKey struct {
+	rootKeyID string
+	method    string
+
+	// caveat is the ID of the caveat the calls are limited by, zero for
+	// the limits of the configuration.
+	caveat [32]byte
+}
+
+// bucket is the token bucket of the calls of a limiter key.
+type bucket struct {
+	limiter  *rate.Limiter
+	lastUsed time.Time
+}
+
+// full returns true if the bucket has been refilled completely since it was
+// last used.
+func (b *bucket) full(now time.Time) bool {
+	refill := float64(b.limiter.Burst()) / float64(b.limiter.Limit())
+	return now.Sub(b.lastUsed).Seconds() >= refill
+}
+
+// RateLimiter enforces token bucket rate limits and caps on concurrent
+// streams on RPC calls.
+type RateLimiter struct {
+	cfg *RateLimiterConfig
+
+	mu      sync.Mutex
+	buckets map[limiterKey]*bucket
+	streams map[limiterKey]uint32
+}
+
+// NewRateLimiter creates a new rate limiter.
+func NewRateLimiter(cfg *RateLimiterConfig) *RateLimiter {
+	return &RateLimiter{
+		cfg:     cfg,
+		buckets: make(map[limiterKey]*bucket),
+		streams: make(map[limiterKey]uint32),
+	}
+}
+
+// callLimits returns the limiter keys and rate limits that apply to a call.
+func (l *RateLimiter) callLimits(rootKeyID, method string,
+	limits *macaroons.Limits) ([]limiterKey, []macaroons.RateLimit) {
+
+	var (
+		keys       []limiterKey
+		rateLimits []macaroons.RateLimit
+	)
+
+	cfgLimit, ok := l.cfg.MethodLimits[method]
+	if !ok {
+		cfgLimit = l.cfg.DefaultLimit
+	}
+	if cfgLimit.Rate > 0 {
+		keys = append(keys, limiterKey{
+			rootKeyID: rootKeyID,
+			method:    method,
+		})
+		rateLimits = append(rateLimits, cfgLimit)
+	}
+
+	// Adding caveats to a macaroon must not reset the buckets of the
+	// caveats it already has, so each caveat is charged separately.
+	for _, caveatLimit := range limits.RateLimits {
+		keys = append(keys, limiterKey{
+			rootKeyID: rootKeyID,
+			method:    method,
+			caveat:    caveatLimit.ID,
+		})
+		rateLimits = append(rateLimits, caveatLimit)
+	}
+
+	return keys, rateLimits
+}
+
+// Allow takes a token from all buckets that apply to a call. If any of them
+// is empty, no token is taken and a ResourceExhausted error is returned.
+func (l *RateLimiter) Allow(rootKeyID, method string,
+	limits *macaroons.Limits) error {
+
+	keys, rateLimits := l.callLimits(rootKeyID, method, limits)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.pruneBuckets(now)
+
+	reservations := make([]*rate.Reservation, 0, len(keys))
+	for i, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{
+				limiter: rate.NewLimiter(
+					rate.Limit(rateLimits[i].Rate),
+					rateLimits[i].Burst,
+				),
+			}
+			l.buckets[key] = b
+		}
+		b.lastUsed = now
+
+		r := b.limiter.ReserveN(now, 1)
+		if !r.OK() || r.DelayFrom(now) > 0 {
+			r.CancelAt(now)
+			for _, reserved := range reservations {
+				reserved.CancelAt(now)
+			}
+
+			return status.Errorf(codes.ResourceExhausted, "rate "+
+				"limit of %v exceeded", method)
+		}
+
+		reservations = append(reservations, r)
+	}
+
+	return nil
+}
+
+// pruneBuckets removes all full buckets once there are too many of them.
+//
+// NOTE: Must be called with the mutex held.
+func (l *RateLimiter) pruneBuckets(now time.Time) {
+	if len(l.buckets) < maxIdleBuckets {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// AcquireStream registers a new stream of a method. If that exceeds the
+// maximum number of concurrent streams, a ResourceExhausted error is returned.
+// Otherwise the returned function must be called once the stream ends.
+func (l *RateLimiter) AcquireStream(rootKeyID, method string,
+	limits *macaroons.Limits) (func(), error) {
+
+	var (
+		keys       []limiterKey
+		maxStreams []uint32
+	)
+	if l.cfg.MaxStreams > 0 {
+		keys = append(keys, limiterKey{
+			rootKeyID: rootKeyID,
+			method:    method,
+		})
+		maxStreams = append(maxStreams, l.cfg.MaxStreams)
+	}
+	for _, caveatLimit := range limits.StreamLimits {
+		keys = append(keys, limiterKey{
+			rootKeyID: rootKeyID,
+			method:    method,
+			caveat:    caveatLimit.ID,
+		})
+		maxStreams = append(maxStreams, caveatLimit.MaxStreams)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, key := range keys {
+		if l.streams[key] >= maxStreams[i] {
+			return nil, status.Errorf(codes.ResourceExhausted,
+				"maximum of %d concurrent %v streams reached",
+				maxStreams[i], method)
+		}
+	}
+
+	for _, key := range keys {
+		l.streams[key]++
+	}
+
+	release := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		for _, key := range keys {
+			l.streams[key]--
+			if l.streams[key] == 0 {
+				delete(l.streams, key)
+			}
+		}
+	}
+
+	return release, nil
+}
+
+// callIdentity returns the root key ID and limits of the macaroon of a call.
+// Calls without a macaroon share the empty root key ID and have no limits.
+func callIdentity(ctx context.Context) (string, *macaroons.Limits, error) {
+	mac, _, err := macaroonFromContext(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if mac == nil {
+		return "", &macaroons.Limits{}, nil
+	}
+
+	limits, err := macaroons.ParseLimits(mac)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return rootKeyID(mac), limits, nil
+}
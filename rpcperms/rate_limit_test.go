@@ -0,0 +1,296 @@
+package rpcperms
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btclog"
+	"github.com/lightningnetwork/lnd/lnrpc"
+	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/macaroon-bakery.v2/bakery"
+	"gopkg.in/macaroon.v2"
+)
+
+const (
+	getInfo      = "/lnrpc.Lightning/GetInfo"
+	sendPayment  = "/lnrpc.Lightning/SendPaymentSync"
+	subscribeTxs = "/lnrpc.Lightning/SubscribeTransactions"
+)
+
+// requireExhausted asserts that the error is a ResourceExhausted status.
+func requireExhausted(t *testing.T, err error) {
+	t.Helper()
+
+	require.Error(t, err)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+// TestRateLimiterAllow tests that the rate limits of the configuration and
+// the caveats are enforced per root key ID and method.
+func TestRateLimiterAllow(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(&RateLimiterConfig{
+		DefaultLimit: macaroons.RateLimit{Rate: 0.001, Burst: 3},
+		MethodLimits: map[string]macaroons.RateLimit{
+			sendPayment: {Rate: 0.001, Burst: 1},
+			getInfo:     {},
+		},
+	})
+	noLimits := &macaroons.Limits{}
+
+	// The default limit applies to methods without their own.
+	addInvoice := "/lnrpc.Lightning/AddInvoice"
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.Allow("0", addInvoice, noLimits))
+	}
+	requireExhausted(t, limiter.Allow("0", addInvoice, noLimits))
+
+	// Other root key IDs and methods have their own buckets.
+	require.NoError(t, limiter.Allow("1", addInvoice, noLimits))
+	require.NoError(t, limiter.Allow("0", sendPayment, noLimits))
+	requireExhausted(t, limiter.Allow("0", sendPayment, noLimits))
+
+	// A zero rate disables the limit of a method.
+	for i := 0; i < 10; i++ {
+		require.NoError(t, limiter.Allow("0", getInfo, noLimits))
+	}
+
+	// A caveat limit applies on top of the configured one. A call denied
+	// by the caveat doesn't take a token of the configured limit.
+	caveatLimits := &macaroons.Limits{
+		RateLimits: []macaroons.RateLimit{{
+			Rate: 0.001, Burst: 1, ID: [32]byte{1},
+		}},
+	}
+	require.NoError(t, limiter.Allow("2", addInvoice, caveatLimits))
+	requireExhausted(t, limiter.Allow("2", addInvoice, caveatLimits))
+	require.NoError(t, limiter.Allow("2", addInvoice, noLimits))
+	require.NoError(t, limiter.Allow("2", addInvoice, noLimits))
+	requireExhausted(t, limiter.Allow("2", addInvoice, noLimits))
+
+	// The caveat limit also applies to methods that aren't limited by the
+	// configuration.
+	require.NoError(t, limiter.Allow("2", getInfo, caveatLimits))
+	requireExhausted(t, limiter.Allow("2", getInfo, caveatLimits))
+}
+
+// TestRateLimiterStreams tests that the number of concurrent streams is
+// capped, and that released streams free up their slot.
+func TestRateLimiterStreams(t *testing.T) {
+	t.Parallel()
+
+	limiter := NewRateLimiter(&RateLimiterConfig{
+		MaxStreams: 2,
+	})
+	noLimits := &macaroons.Limits{}
+
+	release1, err := limiter.AcquireStream("0", subscribeTxs, noLimits)
+	require.NoError(t, err)
+	release2, err := limiter.AcquireStream("0", subscribeTxs, noLimits)
+	require.NoError(t, err)
+
+	_, err = limiter.AcquireStream("0", subscribeTxs, noLimits)
+	requireExhausted(t, err)
+
+	// Another root key ID isn't affected.
+	release3, err := limiter.AcquireStream("1", subscribeTxs, noLimits)
+	require.NoError(t, err)
+	release3()
+
+	release1()
+	release1, err = limiter.AcquireStream("0", subscribeTxs, noLimits)
+	require.NoError(t, err)
+
+	// A caveat can restrict the streams further.
+	caveatLimits := &macaroons.Limits{
+		StreamLimits: []macaroons.StreamLimit{{
+			MaxStreams: 1, ID: [32]byte{1},
+		}},
+	}
+	release4, err := limiter.AcquireStream("1", subscribeTxs, caveatLimits)
+	require.NoError(t, err)
+	_, err = limiter.AcquireStream("1", subscribeTxs, caveatLimits)
+	requireExhausted(t, err)
+
+	release1()
+	release2()
+	release4()
+	require.Empty(t, limiter.streams)
+}
+
+// TestRateLimitInterceptor tests that the interceptors limit calls by the
+// root key ID and caveats of their macaroon.
+func TestRateLimitInterceptor(t *testing.T) {
+	t.Parallel()
+
+	chain := NewInterceptorChain(btclog.Disabled, false, nil)
+	chain.SetRateLimiter(NewRateLimiter(&RateLimiterConfig{}))
+
+	ctx := macaroonContext(t, limitedMacaroon(t))
+
+	unary := chain.rateLimitUnaryServerInterceptor()
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: getInfo}
+	for i := 0; i < 2; i++ {
+		_, err := unary(ctx, nil, info, handler)
+		require.NoError(t, err)
+	}
+	_, err := unary(ctx, nil, info, handler)
+	requireExhausted(t, err)
+
+	// A second stream is rejected while the first one is still open.
+	stream := chain.rateLimitStreamServerInterceptor()
+	streamInfo := &grpc.StreamServerInfo{FullMethod: subscribeTxs}
+	ss := &mockServerStream{ctx: ctx}
+	err = stream(nil, ss, streamInfo, func(interface{},
+		grpc.ServerStream) error {
+
+		return stream(nil, ss, streamInfo, func(interface{},
+			grpc.ServerStream) error {
+
+			return nil
+		})
+	})
+	requireExhausted(t, err)
+}
+
+// TestRateLimitInterceptorCaveatOnly tests that the rate limit caveat of a
+// macaroon is enforced by a new interceptor chain, even if no rate limiter
+// with configured limits was set.
+func TestRateLimitInterceptorCaveatOnly(t *testing.T) {
+	t.Parallel()
+
+	chain := NewInterceptorChain(btclog.Disabled, false, nil)
+	ctx := macaroonContext(t, limitedMacaroon(t))
+
+	unary := chain.rateLimitUnaryServerInterceptor()
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: getInfo}
+	for i := 0; i < 2; i++ {
+		_, err := unary(ctx, nil, info, handler)
+		require.NoError(t, err)
+	}
+	_, err := unary(ctx, nil, info, handler)
+	requireExhausted(t, err)
+
+	// Calls without a macaroon aren't limited.
+	for i := 0; i < 10; i++ {
+		_, err := unary(context.Background(), nil, info, handler)
+		require.NoError(t, err)
+	}
+}
+
+// TestRateLimitAttenuation tests that adding caveats to a macaroon doesn't
+// reset the limits of the caveats it already has.
+func TestRateLimitAttenuation(t *testing.T) {
+	t.Parallel()
+
+	chain := NewInterceptorChain(btclog.Disabled, false, nil)
+	mac := limitedMacaroon(t)
+
+	unary := chain.rateLimitUnaryServerInterceptor()
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return nil, nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: getInfo}
+	for i := 0; i < 2; i++ {
+		_, err := unary(macaroonContext(t, mac), nil, info, handler)
+		require.NoError(t, err)
+	}
+
+	// Neither a looser nor a stricter rate limit caveat gives the
+	// attenuated macaroons a fresh budget.
+	newLimits := []macaroons.RateLimit{
+		{Rate: 1000, Burst: 100},
+		{Rate: 0.0001, Burst: 1},
+	}
+	for _, limit := range newLimits {
+		attenuated := mac.Clone()
+		err := macaroons.RateLimitConstraint(limit)(attenuated)
+		require.NoError(t, err)
+
+		_, err = unary(
+			macaroonContext(t, attenuated), nil, info, handler,
+		)
+		requireExhausted(t, err)
+	}
+
+	// The same goes for the stream limit.
+	stream := chain.rateLimitStreamServerInterceptor()
+	streamInfo := &grpc.StreamServerInfo{FullMethod: subscribeTxs}
+	attenuated := mac.Clone()
+	require.NoError(t, macaroons.MaxStreamsConstraint(5)(attenuated))
+	err := stream(nil, &mockServerStream{ctx: macaroonContext(t, mac)},
+		streamInfo, func(interface{}, grpc.ServerStream) error {
+			ss := &mockServerStream{
+				ctx: macaroonContext(t, attenuated),
+			}
+			return stream(nil, ss, streamInfo, func(interface{},
+				grpc.ServerStream) error {
+
+				return nil
+			})
+		},
+	)
+	requireExhausted(t, err)
+}
+
+// limitedMacaroon returns a macaroon that limits calls to a burst of 2 and a
+// single concurrent stream.
+func limitedMacaroon(t *testing.T) *macaroon.Macaroon {
+	t.Helper()
+
+	macID, err := proto.Marshal(&lnrpc.MacaroonId{StorageId: []byte("3")})
+	require.NoError(t, err)
+	mac, err := macaroon.New(
+		[]byte("root key"),
+		append([]byte{byte(bakery.LatestVersion)}, macID...), "lnd",
+		macaroon.LatestVersion,
+	)
+	require.NoError(t, err)
+	err = macaroons.RateLimitConstraint(macaroons.RateLimit{
+		Rate: 0.001, Burst: 2,
+	})(mac)
+	require.NoError(t, err)
+	require.NoError(t, macaroons.MaxStreamsConstraint(1)(mac))
+
+	return mac
+}
+
+// macaroonContext returns an incoming context with the given macaroon.
+func macaroonContext(t *testing.T, mac *macaroon.Macaroon) context.Context {
+	t.Helper()
+
+	macBytes, err := mac.MarshalBinary()
+	require.NoError(t, err)
+
+	return metadata.NewIncomingContext(
+		context.Background(), metadata.Pairs(
+			"macaroon", hex.EncodeToString(macBytes),
+		),
+	)
+}
+
+// mockServerStream is a server stream that only has a context.
+type mockServerStream struct {
+	grpc.ServerStream
+
+	ctx context.Context
+}
+
+// Context returns the context of the stream.
+func (s *mockServerStream) Context() context.Context {
+	return s.ctx
+}
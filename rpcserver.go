@@ -34,16 +34,19 @@ import (
 	"github.com/btcsuite/btcwallet/wallet/txauthor"
 	"github.com/davecgh/go-spew/spew"
 	proxy "github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/lightningnetwork/lnd/accounting"
 	"github.com/lightningnetwork/lnd/autopilot"
 	"github.com/lightningnetwork/lnd/build"
 	"github.com/lightningnetwork/lnd/chainreg"
 	"github.com/lightningnetwork/lnd/chanacceptor"
+	"github.com/lightningnetwork/lnd/chanarchive"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/chanfitness"
 	"github.com/lightningnetwork/lnd/channeldb"
 	"github.com/lightningnetwork/lnd/channeldb/models"
 	"github.com/lightningnetwork/lnd/channelnotifier"
 	"github.com/lightningnetwork/lnd/contractcourt"
+	"github.com/lightningnetwork/lnd/dbcompact"
 	"github.com/lightningnetwork/lnd/discovery"
 	"github.com/lightningnetwork/lnd/feature"
 	"github.com/lightningnetwork/lnd/funding"
@@ -67,6 +70,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/macaroons"
+	"github.com/lightningnetwork/lnd/payjoin"
 	"github.com/lightningnetwork/lnd/peer"
 	"github.com/lightningnetwork/lnd/peernotifier"
 	"github.com/lightningnetwork/lnd/record"
@@ -76,6 +80,7 @@ import (
 	"github.com/lightningnetwork/lnd/signal"
 	"github.com/lightningnetwork/lnd/sweep"
 	"github.com/lightningnetwork/lnd/tlv"
+	"github.com/lightningnetwork/lnd/walletunlocker"
 	"github.com/lightningnetwork/lnd/watchtower"
 	"github.com/lightningnetwork/lnd/zpay32"
 	"github.com/tv42/zbase32"
@@ -214,6 +219,11 @@ var (
 	// macaroon related services are used.
 	errMacaroonDisabled = fmt.Errorf("macaroon authentication disabled, " +
 		"remove --no-macaroons flag to enable")
+
+	// errAuditLogDisabled is an error returned when the audit log is
+	// queried but not enabled.
+	errAuditLogDisabled = fmt.Errorf("audit log disabled, set " +
+		"--auditlog.enable to enable")
 )
 
 // stringInSlice returns true if a string is contained in the given slice.
@@ -329,6 +339,13 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "write",
 		}},
+		"/lnrpc.Lightning/SimulateForceClose": {{
+			Entity: "onchain",
+			Action: "read",
+		}, {
+			Entity: "offchain",
+			Action: "read",
+		}},
 		"/lnrpc.Lightning/GetInfo": {{
 			Entity: "info",
 			Action: "read",
@@ -350,6 +367,14 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "info",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/GetDatabaseStats": {{
+			Entity: "info",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/CompactDatabase": {{
+			Entity: "info",
+			Action: "write",
+		}},
 		"/lnrpc.Lightning/ListPeers": {{
 			Entity: "peers",
 			Action: "read",
@@ -370,6 +395,20 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/ListContractResolvers": {{
+			Entity: "onchain",
+			Action: "read",
+		}, {
+			Entity: "offchain",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/ReevaluateContractResolver": {{
+			Entity: "onchain",
+			Action: "write",
+		}, {
+			Entity: "offchain",
+			Action: "write",
+		}},
 		"/lnrpc.Lightning/ListChannels": {{
 			Entity: "offchain",
 			Action: "read",
@@ -382,6 +421,24 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/ExportChannelArchive": {{
+			Entity: "offchain",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/CloseHistory": {{
+			Entity: "offchain",
+			Action: "read",
+		}, {
+			Entity: "onchain",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/SubscribeBreachEvents": {{
+			Entity: "offchain",
+			Action: "read",
+		}, {
+			Entity: "onchain",
+			Action: "read",
+		}},
 		"/lnrpc.Lightning/SendPayment": {{
 			Entity: "offchain",
 			Action: "write",
@@ -422,6 +479,16 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "onchain",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/ListAccountingEntries": {{
+			Entity: "onchain",
+			Action: "read",
+		}, {
+			Entity: "offchain",
+			Action: "read",
+		}, {
+			Entity: "invoices",
+			Action: "read",
+		}},
 		"/lnrpc.Lightning/DescribeGraph": {{
 			Entity: "info",
 			Action: "read",
@@ -506,6 +573,10 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "offchain",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/ListChannelBackupVersions": {{
+			Entity: "offchain",
+			Action: "read",
+		}},
 		"/lnrpc.Lightning/ChannelAcceptor": {{
 			Entity: "onchain",
 			Action: "write",
@@ -529,6 +600,14 @@ func MainRPCServerPermissions() map[string][]bakery.Op {
 			Entity: "info",
 			Action: "read",
 		}},
+		"/lnrpc.Lightning/ListAuditLog": {{
+			Entity: "macaroon",
+			Action: "read",
+		}},
+		"/lnrpc.Lightning/SubscribeAuditLog": {{
+			Entity: "macaroon",
+			Action: "read",
+		}},
 		"/lnrpc.Lightning/CheckMacaroonPermissions": {{
 			Entity: "macaroon",
 			Action: "read",
@@ -763,7 +842,7 @@ func (r *rpcServer) addDeps(s *server, macService *macaroons.Service,
 		s.sweeper, tower, s.towerClientMgr, r.cfg.net.ResolveTCPAddr,
 		genInvoiceFeatures, genAmpInvoiceFeatures,
 		s.getNodeAnnouncement, s.updateAndBrodcastSelfNode, parseAddr,
-		rpcsLog, s.aliasMgr.GetPeerAlias,
+		rpcsLog, s.aliasMgr.GetPeerAlias, s.channelNotifier,
 	)
 	if err != nil {
 		return err
@@ -1110,6 +1189,186 @@ func (r *rpcServer) sendCoinsOnChain(paymentMap map[string]int64,
 	return &txHash, nil
 }
 
+// sendCoinsPayjoin pays to a payjoin URI. The original transaction is funded
+// and signed first, then the receiver is asked for a payjoin proposal. If the
+// receiver doesn't respond with a proposal that passes all checks, the
+// original transaction is broadcast instead.
+func (r *rpcServer) sendCoinsPayjoin(ctx context.Context, uri *payjoin.URI,
+	amount int64, feeRate chainfee.SatPerKWeight, minConfs int32,
+	label string, strategy wallet.CoinSelectionStrategy) (*chainhash.Hash,
+	error) {
+
+	// payjoinTimeout is the maximum time we wait for the receiver to
+	// respond with a proposal.
+	const payjoinTimeout = time.Minute
+
+	lnWallet := r.server.cc.Wallet
+
+	payeeScript, err := txscript.PayToAddrScript(uri.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// We fund the original transaction and lease its inputs, so they
+	// aren't used by other coin selections while we wait for the
+	// receiver.
+	var (
+		original    *psbt.Packet
+		changeIndex int32
+		leased      []wire.OutPoint
+	)
+	releaseInputs := func() {
+		_ = lnWallet.WithCoinSelectLock(func() error {
+			for _, op := range leased {
+				_ = lnWallet.ReleaseOutput(
+					chanfunding.LndInternalLockID, op,
+				)
+			}
+
+			return nil
+		})
+	}
+	err = lnWallet.WithCoinSelectLock(func() error {
+		tx := wire.NewMsgTx(2)
+		tx.AddTxOut(&wire.TxOut{Value: amount, PkScript: payeeScript})
+
+		packet, err := psbt.NewFromUnsignedTx(tx)
+		if err != nil {
+			return err
+		}
+
+		changeIndex, err = lnWallet.FundPsbt(
+			packet, minConfs, feeRate, lnwallet.DefaultAccountName,
+			nil, strategy,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, txIn := range packet.UnsignedTx.TxIn {
+			_, _, _, err := lnWallet.LeaseOutput(
+				chanfunding.LndInternalLockID,
+				txIn.PreviousOutPoint,
+				chanfunding.DefaultLockDuration,
+			)
+			if err != nil {
+				return err
+			}
+			leased = append(leased, txIn.PreviousOutPoint)
+		}
+
+		// The receiver may only increase the payment output, so the
+		// reserved value is checked against the original transaction.
+		reserveReq := lnwallet.CheckReservedValueTxReq{
+			Tx: packet.UnsignedTx,
+		}
+		if changeIndex >= 0 {
+			changeIdx := int(changeIndex)
+			reserveReq.ChangeIndex = &changeIdx
+		}
+		_, err = lnWallet.CheckReservedValueTx(reserveReq)
+		if err != nil {
+			return err
+		}
+
+		original = packet
+
+		return nil
+	})
+	if err != nil {
+		releaseInputs()
+		return nil, err
+	}
+	defer releaseInputs()
+
+	err = lnWallet.FinalizePsbt(original, lnwallet.DefaultAccountName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to sign original transaction: "+
+			"%w", err)
+	}
+	originalTx, err := psbt.Extract(original)
+	if err != nil {
+		return nil, err
+	}
+
+	// We allow the receiver to deduct the fee for one input of the same
+	// type as ours from our change output.
+	params := &payjoin.Params{
+		AdditionalFeeOutputIndex:  -1,
+		MinFeeRate:                feeRate,
+		DisableOutputSubstitution: true,
+	}
+	if changeIndex >= 0 && original.Inputs[0].WitnessUtxo != nil {
+		inputWeight, err := payjoin.InputWeight(
+			original.Inputs[0].WitnessUtxo.PkScript,
+		)
+		if err == nil {
+			params.AdditionalFeeOutputIndex = int(changeIndex)
+			params.MaxAdditionalFeeContribution =
+				feeRate.FeeForWeight(inputWeight)
+		}
+	}
+
+	client := &http.Client{
+		Timeout: payjoinTimeout,
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, network,
+				address string) (net.Conn, error) {
+
+				return r.cfg.net.Dial(
+					network, address, payjoinTimeout,
+				)
+			},
+		},
+	}
+
+	payjoinTx, err := func() (*wire.MsgTx, error) {
+		ctx, cancel := context.WithTimeout(ctx, payjoinTimeout)
+		defer cancel()
+
+		proposal, err := payjoin.RequestProposal(
+			ctx, client, uri.Endpoint, original, params,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		err = payjoin.CheckProposal(
+			original, proposal, payeeScript, params,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		payjoin.RestoreSenderInputs(original, proposal)
+		err = lnWallet.FinalizePsbt(
+			proposal, lnwallet.DefaultAccountName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		return psbt.Extract(proposal)
+	}()
+	if err == nil {
+		err = lnWallet.PublishTransaction(payjoinTx, label)
+		if err == nil {
+			txHash := payjoinTx.TxHash()
+			return &txHash, nil
+		}
+	}
+
+	rpcsLog.Warnf("Payjoin with %v failed, broadcasting original "+
+		"transaction: %v", uri.Endpoint, err)
+
+	if err := lnWallet.PublishTransaction(originalTx, label); err != nil {
+		return nil, err
+	}
+
+	txHash := originalTx.TxHash()
+	return &txHash, nil
+}
+
 // ListUnspent returns useful information about each unspent output owned by
 // the wallet, as reported by the underlying `ListUnspentWitness`; the
 // information returned is: outpoint, amount in satoshis, address, address
@@ -1258,15 +1517,46 @@ func (r *rpcServer) SendCoins(ctx context.Context,
 		return nil, err
 	}
 
+	// If we pay to a payjoin URI, the address and the amount are taken
+	// from the URI. An explicit amount overrides the one of the URI.
+	addr, amount := in.Addr, in.Amount
+	var payjoinURI *payjoin.URI
+	if in.PayjoinUri != "" {
+		switch {
+		case in.Addr != "":
+			return nil, fmt.Errorf("addr must not be set with a " +
+				"payjoin URI")
+
+		case in.SendAll:
+			return nil, fmt.Errorf("send_all is not supported " +
+				"with a payjoin URI")
+		}
+
+		payjoinURI, err = payjoin.ParseURI(
+			in.PayjoinUri, r.cfg.ActiveNetParams.Params,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		addr = payjoinURI.Address.String()
+		if amount == 0 {
+			amount = int64(payjoinURI.Amount)
+		}
+		if amount <= 0 {
+			return nil, fmt.Errorf("payjoin URI has no amount")
+		}
+	}
+
 	rpcsLog.Infof("[sendcoins] addr=%v, amt=%v, sat/kw=%v, min_confs=%v, "+
-		"send_all=%v",
-		in.Addr, btcutil.Amount(in.Amount), int64(feePerKw), minConfs,
-		in.SendAll)
+		"send_all=%v, payjoin=%v",
+		addr, btcutil.Amount(amount), int64(feePerKw), minConfs,
+		in.SendAll, payjoinURI != nil)
 
 	// Decode the address receiving the coins, we need to check whether the
 	// address is valid for this network.
 	targetAddr, err := btcutil.DecodeAddress(
-		in.Addr, r.cfg.ActiveNetParams.Params,
+		addr, r.cfg.ActiveNetParams.Params,
 	)
 	if err != nil {
 		return nil, err
@@ -1282,7 +1572,7 @@ func (r *rpcServer) SendCoins(ctx context.Context,
 	// If the destination address parses to a valid pubkey, we assume the user
 	// accidentally tried to send funds to a bare pubkey address. This check is
 	// here to prevent unintended transfers.
-	decodedAddr, _ := hex.DecodeString(in.Addr)
+	decodedAddr, _ := hex.DecodeString(addr)
 	_, err = btcec.ParsePubKey(decodedAddr)
 	if err == nil {
 		return nil, fmt.Errorf("cannot send coins to pubkeys")
@@ -1313,7 +1603,7 @@ func (r *rpcServer) SendCoins(ctx context.Context,
 	if in.SendAll {
 		// At this point, the amount shouldn't be set since we've been
 		// instructed to sweep all the coins from the wallet.
-		if in.Amount != 0 {
+		if amount != 0 {
 			return nil, fmt.Errorf("amount set while SendAll is " +
 				"active")
 		}
@@ -1413,7 +1703,7 @@ func (r *rpcServer) SendCoins(ctx context.Context,
 		}
 
 		rpcsLog.Debugf("Sweeping all coins from wallet to addr=%v, "+
-			"with tx=%v", in.Addr, spew.Sdump(sweepTxPkg.SweepTx))
+			"with tx=%v", addr, spew.Sdump(sweepTxPkg.SweepTx))
 
 		// As our sweep transaction was created, successfully, we'll
 		// now attempt to publish it, cancelling the sweep pkg to
@@ -1428,13 +1718,21 @@ func (r *rpcServer) SendCoins(ctx context.Context,
 
 		sweepTXID := sweepTxPkg.SweepTx.TxHash()
 		txid = &sweepTXID
+	} else if payjoinURI != nil {
+		txid, err = r.sendCoinsPayjoin(
+			ctx, payjoinURI, amount, feePerKw, minConfs, label,
+			coinSelectionStrategy,
+		)
+		if err != nil {
+			return nil, err
+		}
 	} else {
 
 		// We'll now construct out payment map, and use the wallet's
 		// coin selection synchronization method to ensure that no coin
 		// selection (funding, sweep alls, other sends) can proceed
 		// while we instruct the wallet to send this transaction.
-		paymentMap := map[string]int64{targetAddr.String(): in.Amount}
+		paymentMap := map[string]int64{targetAddr.String(): amount}
 		err := wallet.WithCoinSelectLock(func() error {
 			newTXID, err := r.sendCoinsOnChain(
 				paymentMap, feePerKw, minConfs,
@@ -1994,6 +2292,13 @@ func (r *rpcServer) parseOpenChannelReq(in *lnrpc.OpenChannelRequest,
 			"the channel opening")
 	}
 
+	// A named funding wallet replaces the funding logic of the main wallet,
+	// just like a funding shim, so both can't be combined.
+	if in.FundingShim != nil && in.FundingWallet != "" {
+		return nil, fmt.Errorf("cannot provide a funding shim while " +
+			"funding the channel from a named wallet")
+	}
+
 	// If the FundMax flag is set, ensure that the acceptable minimum local
 	// amount adheres to the amount to be pushed to the remote, and to
 	// current rules, while also respecting the settings for the maximum
@@ -2249,6 +2554,18 @@ func (r *rpcServer) parseOpenChannelReq(in *lnrpc.OpenChannelRequest,
 		}
 	}
 
+	// If the channel is funded from a named wallet, its coins are selected
+	// and signed by that wallet instead of the main one.
+	var chanFunder chanfunding.Assembler
+	if in.FundingWallet != "" &&
+		in.FundingWallet != walletunlocker.DefaultWalletName {
+
+		chanFunder, err = r.namedWalletAssembler(in.FundingWallet)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Instruct the server to trigger the necessary events to attempt to
 	// open a new channel. A stream is returned in place, this stream will
 	// be used to consume updates of the state of the pending channel.
@@ -2276,9 +2593,47 @@ func (r *rpcServer) parseOpenChannelReq(in *lnrpc.OpenChannelRequest,
 		MinFundAmt:        minFundAmt,
 		Memo:              []byte(in.Memo),
 		Outpoints:         outpoints,
+		ChanFunder:        chanFunder,
 	}, nil
 }
 
+// namedWalletAssembler returns an assembler that funds a channel from the
+// unlocked named wallet with the given name. The change of the funding
+// transaction is sent back to the named wallet.
+func (r *rpcServer) namedWalletAssembler(
+	name string) (chanfunding.Assembler, error) {
+
+	if r.server.cc.NamedWallets == nil {
+		return nil, fmt.Errorf("named wallets are only supported " +
+			"with a local wallet and the bolt database backend")
+	}
+
+	namedWallet, err := r.server.cc.NamedWallets.Wallet(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fund channel from wallet "+
+			"%v: %w", name, err)
+	}
+
+	// We use the P2WSH dust limit just like the main wallet does.
+	dustLimit := lnwallet.DustLimitForSize(input.P2WSHSize)
+	strategy := r.server.cc.Wallet.Cfg.CoinSelectionStrategy
+
+	return chanfunding.NewWalletAssembler(chanfunding.WalletConfig{
+		CoinSource:            namedWallet,
+		CoinSelectionStrategy: strategy,
+		CoinSelectLocker:      namedWallet,
+		CoinLeaser:            namedWallet,
+		Signer:                namedWallet,
+		DustLimit:             dustLimit,
+		ChangeAddr: func() (btcutil.Address, error) {
+			return namedWallet.NewAddress(
+				lnwallet.TaprootPubkey, true,
+				lnwallet.DefaultAccountName,
+			)
+		},
+	}), nil
+}
+
 // toWireOutpoints converts a list of outpoints from the rpc format to the wire
 // format.
 func toWireOutpoints(outpoints []*lnrpc.OutPoint) ([]wire.OutPoint, error) {
@@ -2559,6 +2914,12 @@ func (r *rpcServer) CloseChannel(in *lnrpc.CloseChannelRequest,
 	rpcsLog.Tracef("[closechannel] request for ChannelPoint(%v), force=%v",
 		chanPoint, force)
 
+	// Make sure the macaroon of the request allows closing the channel.
+	err = r.macService.AuthorizeChannel(updateStream.Context(), chanPoint)
+	if err != nil {
+		return err
+	}
+
 	var (
 		updateChan chan interface{}
 		errChan    chan error
@@ -2970,6 +3331,69 @@ func (r *rpcServer) AbandonChannel(_ context.Context,
 	return &lnrpc.AbandonChannelResponse{}, nil
 }
 
+// SimulateForceClose simulates a force close of an open channel at its current
+// state without broadcasting or signing anything.
+func (r *rpcServer) SimulateForceClose(_ context.Context,
+	in *lnrpc.SimulateForceCloseRequest) (*lnrpc.SimulateForceCloseResponse,
+	error) {
+
+	txid, err := lnrpc.GetChanPointFundingTxid(in.GetChannelPoint())
+	if err != nil {
+		return nil, err
+	}
+	chanPoint := wire.NewOutPoint(txid, in.ChannelPoint.OutputIndex)
+
+	feeRate, err := lnrpc.CalculateFeeRate(
+		0, in.SatPerVbyte, in.TargetConf, r.server.cc.FeeEstimator,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, bestHeight, err := r.server.cc.ChainIO.GetBestBlock()
+	if err != nil {
+		return nil, err
+	}
+
+	sim, err := r.server.chainArb.SimulateForceClose(
+		*chanPoint, feeRate, uint32(bestHeight),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	htlcs := make([]*lnrpc.SimulatedHtlc, 0, len(sim.Htlcs))
+	for _, htlc := range sim.Htlcs {
+		htlcs = append(htlcs, &lnrpc.SimulatedHtlc{
+			PaymentHash:       htlc.PaymentHash[:],
+			AmountSat:         int64(htlc.Amount),
+			Incoming:          htlc.Incoming,
+			CltvExpiry:        htlc.CltvExpiry,
+			PreimageKnown:     htlc.PreimageKnown,
+			SecondLevelWeight: htlc.SecondLevelWeight,
+			SecondLevelFeeSat: int64(htlc.SecondLevelFee),
+			MaturityHeight:    htlc.MaturityHeight,
+			SweepFeeSat:       int64(htlc.SweepFee),
+			Recoverable:       htlc.Recoverable,
+		})
+	}
+
+	return &lnrpc.SimulateForceCloseResponse{
+		CommitWeight:         sim.CommitWeight,
+		CommitFeeSat:         int64(sim.CommitFee),
+		SatPerKw:             int64(sim.FeeRate),
+		AnchorCpfpFeeSat:     int64(sim.AnchorCPFPFee),
+		LocalAmountSat:       int64(sim.LocalAmount),
+		LocalMaturityHeight:  sim.LocalMaturityHeight,
+		LocalSweepFeeSat:     int64(sim.LocalSweepFee),
+		Htlcs:                htlcs,
+		SweepBudgetSat:       int64(sim.SweepBudget),
+		RecoverableAmountSat: int64(sim.RecoverableAmount),
+		NetRecoverableSat:    int64(sim.NetRecoverable),
+		BestHeight:           uint32(bestHeight),
+	}, nil
+}
+
 // GetInfo returns general information concerning the lightning node including
 // its identity pubkey, alias, the chains it is connected to, and information
 // concerning the number of open+pending channels.
@@ -3115,6 +3539,9 @@ func (r *rpcServer) GetDebugInfo(_ context.Context,
 	return &lnrpc.GetDebugInfoResponse{
 		Config: flatConfig,
 		Log:    strings.Split(string(logContent), "\n"),
+		ChannelBackupSinks: marshalBackupSinkStatuses(
+			r.server.chanBackupSwapper.SinkStatuses(),
+		),
 	}, nil
 }
 
@@ -3176,7 +3603,10 @@ func (r *rpcServer) ListPeers(ctx context.Context,
 			nodePub,
 		)
 
-		var lnrpcSyncType lnrpc.Peer_SyncType
+		var (
+			lnrpcSyncType lnrpc.Peer_SyncType
+			gossipStats   *lnrpc.GossipSyncerStats
+		)
 		if !ok {
 			rpcsLog.Warnf("Gossip syncer for peer=%x not found",
 				nodePub)
@@ -3194,6 +3624,18 @@ func (r *rpcServer) ListPeers(ctx context.Context,
 				return nil, fmt.Errorf("unhandled sync type %v",
 					syncType)
 			}
+
+			stats := syncer.Stats()
+			gossipStats = &lnrpc.GossipSyncerStats{
+				MsgsReceived:        stats.MsgsReceived,
+				BytesReceived:       stats.BytesReceived,
+				MsgsSent:            stats.MsgsSent,
+				BytesSent:           stats.BytesSent,
+				StaleMsgsFiltered:   stats.StaleMsgsFiltered,
+				OutOfScopeMsgs:      stats.OutOfScopeMsgs,
+				OutboundMsgsDropped: stats.OutboundMsgsDropped,
+				InboundThrottles:    stats.InboundThrottles,
+			}
 		}
 
 		features := invoicesrpc.CreateRPCFeatures(
@@ -3212,6 +3654,7 @@ func (r *rpcServer) ListPeers(ctx context.Context,
 			SyncType:        lnrpcSyncType,
 			Features:        features,
 			LastPingPayload: serverPeer.LastRemotePingPayload(),
+			GossipStats:     gossipStats,
 		}
 
 		var peerErrors []interface{}
@@ -4207,29 +4650,185 @@ func (r *rpcServer) LookupHtlcResolution(
 	}, nil
 }
 
-// ListChannels returns a description of all the open channels that this node
-// is a participant in.
-func (r *rpcServer) ListChannels(ctx context.Context,
-	in *lnrpc.ListChannelsRequest) (*lnrpc.ListChannelsResponse, error) {
-
-	if in.ActiveOnly && in.InactiveOnly {
-		return nil, fmt.Errorf("either `active_only` or " +
-			"`inactive_only` can be set, but not both")
-	}
+// ListContractResolvers returns the contract resolvers that are active on the
+// channels pending force closing, along with what each of them is waiting for.
+func (r *rpcServer) ListContractResolvers(_ context.Context,
+	in *lnrpc.ListContractResolversRequest) (
+	*lnrpc.ListContractResolversResponse, error) {
 
-	if in.PublicOnly && in.PrivateOnly {
-		return nil, fmt.Errorf("either `public_only` or " +
-			"`private_only` can be set, but not both")
-	}
+	var states map[wire.OutPoint][]*contractcourt.ResolverState
+	if in.ChannelPoint != nil {
+		txid, err := lnrpc.GetChanPointFundingTxid(in.ChannelPoint)
+		if err != nil {
+			return nil, err
+		}
+		chanPoint := wire.NewOutPoint(txid, in.ChannelPoint.OutputIndex)
 
-	if len(in.Peer) > 0 && len(in.Peer) != 33 {
-		_, err := route.NewVertexFromBytes(in.Peer)
-		return nil, fmt.Errorf("invalid `peer` key: %w", err)
-	}
+		arbitrator, err := r.server.chainArb.GetChannelArbitrator(
+			*chanPoint,
+		)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound, "no arbitrator "+
+				"for channel %v", chanPoint)
+		}
 
-	resp := &lnrpc.ListChannelsResponse{}
+		chanStates, err := arbitrator.ResolverStates()
+		if err != nil {
+			return nil, err
+		}
 
-	dbChannels, err := r.server.chanStateDB.FetchAllOpenChannels()
+		states = map[wire.OutPoint][]*contractcourt.ResolverState{
+			*chanPoint: chanStates,
+		}
+	} else {
+		var err error
+		states, err = r.server.chainArb.ResolverStates()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &lnrpc.ListContractResolversResponse{}
+	for chanPoint, chanStates := range states {
+		if len(chanStates) == 0 {
+			continue
+		}
+
+		resolvers := make([]*lnrpc.ContractResolver, 0, len(chanStates))
+		for _, state := range chanStates {
+			resolver, err := marshallResolverState(state)
+			if err != nil {
+				return nil, err
+			}
+
+			resolvers = append(resolvers, resolver)
+		}
+
+		resp.Channels = append(
+			resp.Channels, &lnrpc.ChannelContractResolvers{
+				ChannelPoint: chanPoint.String(),
+				Resolvers:    resolvers,
+			},
+		)
+	}
+
+	sort.Slice(resp.Channels, func(i, j int) bool {
+		return resp.Channels[i].ChannelPoint <
+			resp.Channels[j].ChannelPoint
+	})
+
+	return resp, nil
+}
+
+// marshallResolverState converts the state of a contract resolver to its RPC
+// counterpart.
+func marshallResolverState(state *contractcourt.ResolverState) (
+	*lnrpc.ContractResolver, error) {
+
+	var resolverType lnrpc.ContractResolverType
+	switch state.Type {
+	case contractcourt.ResolverTypeCommitSweep:
+		resolverType = lnrpc.ContractResolverType_COMMIT_SWEEP_RESOLVER
+
+	case contractcourt.ResolverTypeHtlcTimeout:
+		resolverType = lnrpc.ContractResolverType_HTLC_TIMEOUT_RESOLVER
+
+	case contractcourt.ResolverTypeHtlcSuccess:
+		resolverType = lnrpc.ContractResolverType_HTLC_SUCCESS_RESOLVER
+
+	case contractcourt.ResolverTypeHtlcIncomingContest:
+		resolverType =
+			lnrpc.ContractResolverType_HTLC_INCOMING_CONTEST_RESOLVER
+
+	case contractcourt.ResolverTypeHtlcOutgoingContest:
+		resolverType =
+			lnrpc.ContractResolverType_HTLC_OUTGOING_CONTEST_RESOLVER
+
+	case contractcourt.ResolverTypeAnchor:
+		resolverType = lnrpc.ContractResolverType_ANCHOR_RESOLVER
+
+	case contractcourt.ResolverTypeBreach:
+		resolverType = lnrpc.ContractResolverType_BREACH_RESOLVER
+
+	default:
+		return nil, fmt.Errorf("unknown resolver type: %v", state.Type)
+	}
+
+	resolver := &lnrpc.ContractResolver{
+		ResolverKey:      state.Key,
+		Type:             resolverType,
+		Resolved:         state.Resolved,
+		Stage:            state.Stage,
+		Outpoint:         lnrpc.MarshalOutPoint(&state.Outpoint),
+		AmountSat:        int64(state.Amount),
+		NextActionHeight: state.NextActionHeight,
+	}
+
+	if req := state.SweepRequest; req != nil {
+		resolver.SweepRequest = &lnrpc.ResolverSweepRequest{
+			Outpoint:    lnrpc.MarshalOutPoint(&req.Outpoint),
+			WitnessType: req.WitnessType.String(),
+			AmountSat:   int64(req.Amount),
+			TargetConf:  req.Params.Fee.ConfTarget,
+			SatPerKw:    int64(req.Params.Fee.FeeRate),
+			Force:       req.Params.Force,
+			Timestamp:   req.Timestamp.Unix(),
+		}
+	}
+
+	return resolver, nil
+}
+
+// ReevaluateContractResolver stops an active contract resolver, reloads it from
+// its last checkpoint and launches it again.
+func (r *rpcServer) ReevaluateContractResolver(_ context.Context,
+	in *lnrpc.ReevaluateContractResolverRequest) (
+	*lnrpc.ReevaluateContractResolverResponse, error) {
+
+	txid, err := lnrpc.GetChanPointFundingTxid(in.GetChannelPoint())
+	if err != nil {
+		return nil, err
+	}
+	chanPoint := wire.NewOutPoint(txid, in.ChannelPoint.OutputIndex)
+
+	err = r.server.chainArb.ReevaluateResolver(*chanPoint, in.ResolverKey)
+	switch {
+	case errors.Is(err, contractcourt.ErrResolverNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+
+	case errors.Is(err, contractcourt.ErrResolverResolved):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+
+	case err != nil:
+		return nil, err
+	}
+
+	return &lnrpc.ReevaluateContractResolverResponse{}, nil
+}
+
+// ListChannels returns a description of all the open channels that this node
+// is a participant in.
+func (r *rpcServer) ListChannels(ctx context.Context,
+	in *lnrpc.ListChannelsRequest) (*lnrpc.ListChannelsResponse, error) {
+
+	if in.ActiveOnly && in.InactiveOnly {
+		return nil, fmt.Errorf("either `active_only` or " +
+			"`inactive_only` can be set, but not both")
+	}
+
+	if in.PublicOnly && in.PrivateOnly {
+		return nil, fmt.Errorf("either `public_only` or " +
+			"`private_only` can be set, but not both")
+	}
+
+	if len(in.Peer) > 0 && len(in.Peer) != 33 {
+		_, err := route.NewVertexFromBytes(in.Peer)
+		return nil, fmt.Errorf("invalid `peer` key: %w", err)
+	}
+
+	resp := &lnrpc.ListChannelsResponse{}
+
+	dbChannels, err := r.server.chanStateDB.FetchAllOpenChannels()
 	if err != nil {
 		return nil, err
 	}
@@ -4597,20 +5196,7 @@ func (r *rpcServer) createRPCClosedChannel(
 	}
 
 	// Convert the close type to rpc type.
-	switch dbChannel.CloseType {
-	case channeldb.CooperativeClose:
-		closeType = lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE
-	case channeldb.LocalForceClose:
-		closeType = lnrpc.ChannelCloseSummary_LOCAL_FORCE_CLOSE
-	case channeldb.RemoteForceClose:
-		closeType = lnrpc.ChannelCloseSummary_REMOTE_FORCE_CLOSE
-	case channeldb.BreachClose:
-		closeType = lnrpc.ChannelCloseSummary_BREACH_CLOSE
-	case channeldb.FundingCanceled:
-		closeType = lnrpc.ChannelCloseSummary_FUNDING_CANCELED
-	case channeldb.Abandoned:
-		closeType = lnrpc.ChannelCloseSummary_ABANDONED
-	}
+	closeType = marshalCloseType(dbChannel.CloseType)
 
 	dbScid := dbChannel.ShortChanID
 
@@ -4783,10 +5369,19 @@ func (r *rpcServer) getInitiators(chanPoint *wire.OutPoint) (
 
 	// If we successfully looked up the channel, determine initiator based
 	// on channels status.
+	openInitiator, closeInitiator = channelInitiators(histChan)
+
+	return openInitiator, closeInitiator, nil
+}
+
+// channelInitiators returns the open and close initiators of a closed channel
+// based on its historical state.
+func channelInitiators(histChan *channeldb.OpenChannel) (lnrpc.Initiator,
+	lnrpc.Initiator) {
+
+	openInitiator := lnrpc.Initiator_INITIATOR_REMOTE
 	if histChan.IsInitiator {
 		openInitiator = lnrpc.Initiator_INITIATOR_LOCAL
-	} else {
-		openInitiator = lnrpc.Initiator_INITIATOR_REMOTE
 	}
 
 	localInit := histChan.HasChanStatus(
@@ -4797,6 +5392,7 @@ func (r *rpcServer) getInitiators(chanPoint *wire.OutPoint) (
 		channeldb.ChanStatusRemoteCloseInitiator,
 	)
 
+	closeInitiator := lnrpc.Initiator_INITIATOR_UNKNOWN
 	switch {
 	// There is a possible case where closes were attempted by both parties.
 	// We return the initiator as both in this case to provide full
@@ -4811,7 +5407,334 @@ func (r *rpcServer) getInitiators(chanPoint *wire.OutPoint) (
 		closeInitiator = lnrpc.Initiator_INITIATOR_REMOTE
 	}
 
-	return openInitiator, closeInitiator, nil
+	return openInitiator, closeInitiator
+}
+
+// marshalCloseType converts a channel closure type to its RPC representation.
+func marshalCloseType(
+	closeType channeldb.ClosureType) lnrpc.ChannelCloseSummary_ClosureType {
+
+	switch closeType {
+	case channeldb.CooperativeClose:
+		return lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE
+	case channeldb.LocalForceClose:
+		return lnrpc.ChannelCloseSummary_LOCAL_FORCE_CLOSE
+	case channeldb.RemoteForceClose:
+		return lnrpc.ChannelCloseSummary_REMOTE_FORCE_CLOSE
+	case channeldb.BreachClose:
+		return lnrpc.ChannelCloseSummary_BREACH_CLOSE
+	case channeldb.FundingCanceled:
+		return lnrpc.ChannelCloseSummary_FUNDING_CANCELED
+	case channeldb.Abandoned:
+		return lnrpc.ChannelCloseSummary_ABANDONED
+	default:
+		return lnrpc.ChannelCloseSummary_COOPERATIVE_CLOSE
+	}
+}
+
+// ExportChannelArchive returns the final state of the closed channels that was
+// moved to the channel archive.
+func (r *rpcServer) ExportChannelArchive(_ context.Context,
+	req *lnrpc.ExportChannelArchiveRequest) (
+	*lnrpc.ExportChannelArchiveResponse, error) {
+
+	var filter *wire.OutPoint
+	if req.ChanPoint != "" {
+		chanPoint, err := wire.NewOutPointFromString(req.ChanPoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chan_point: %w", err)
+		}
+		filter = chanPoint
+	}
+
+	channels, err := chanarchive.ReadArchive(r.cfg.ChanArchive.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &lnrpc.ExportChannelArchiveResponse{
+		Channels: make([]*lnrpc.ArchivedChannel, 0, len(channels)),
+	}
+	for _, channel := range channels {
+		summary := channel.Summary
+		if filter != nil && summary.ChanPoint != *filter {
+			continue
+		}
+
+		openInitiator, closeInitiator := channelInitiators(
+			channel.Channel,
+		)
+
+		var remotePub string
+		if summary.RemotePub != nil {
+			remotePub = hex.EncodeToString(
+				summary.RemotePub.SerializeCompressed(),
+			)
+		}
+
+		localCommit, err := marshalArchivedCommitment(
+			&channel.Channel.LocalCommitment,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		remoteCommit, err := marshalArchivedCommitment(
+			&channel.Channel.RemoteCommitment,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Channels = append(resp.Channels, &lnrpc.ArchivedChannel{
+			Summary: &lnrpc.ChannelCloseSummary{
+				ChannelPoint:      summary.ChanPoint.String(),
+				ChanId:            summary.ShortChanID.ToUint64(),
+				ChainHash:         summary.ChainHash.String(),
+				ClosingTxHash:     summary.ClosingTXID.String(),
+				RemotePubkey:      remotePub,
+				Capacity:          int64(summary.Capacity),
+				CloseHeight:       summary.CloseHeight,
+				SettledBalance:    int64(summary.SettledBalance),
+				TimeLockedBalance: int64(summary.TimeLockedBalance),
+				CloseType:         marshalCloseType(summary.CloseType),
+				OpenInitiator:     openInitiator,
+				CloseInitiator:    closeInitiator,
+			},
+			LocalCommitment:  localCommit,
+			RemoteCommitment: remoteCommit,
+		})
+	}
+
+	return resp, nil
+}
+
+// marshalArchivedCommitment converts a commitment of an archived channel into
+// its RPC representation.
+func marshalArchivedCommitment(
+	commit *channeldb.ChannelCommitment) (*lnrpc.ArchivedCommitment, error) {
+
+	rpcCommit := &lnrpc.ArchivedCommitment{
+		CommitHeight:      commit.CommitHeight,
+		LocalBalanceMsat:  uint64(commit.LocalBalance),
+		RemoteBalanceMsat: uint64(commit.RemoteBalance),
+		CommitFeeSat:      int64(commit.CommitFee),
+		FeePerKw:          int64(commit.FeePerKw),
+		Htlcs:             make([]*lnrpc.ArchivedHTLC, 0, len(commit.Htlcs)),
+	}
+
+	if commit.CommitTx != nil {
+		var buf bytes.Buffer
+		if err := commit.CommitTx.Serialize(&buf); err != nil {
+			return nil, err
+		}
+		rpcCommit.CommitTx = buf.Bytes()
+	}
+
+	for _, htlc := range commit.Htlcs {
+		rHash := htlc.RHash
+		rpcCommit.Htlcs = append(rpcCommit.Htlcs, &lnrpc.ArchivedHTLC{
+			Incoming:         htlc.Incoming,
+			AmountMsat:       uint64(htlc.Amt),
+			HashLock:         rHash[:],
+			ExpirationHeight: htlc.RefundTimeout,
+			HtlcIndex:        htlc.HtlcIndex,
+		})
+	}
+
+	return rpcCommit, nil
+}
+
+// CloseHistory returns the close records of channels that were closed on
+// chain, which account for the full financial outcome of each close.
+func (r *rpcServer) CloseHistory(_ context.Context,
+	req *lnrpc.CloseHistoryRequest) (*lnrpc.CloseHistoryResponse, error) {
+
+	var chanPoint *wire.OutPoint
+	if req.ChannelPoint != nil {
+		txid, err := lnrpc.GetChanPointFundingTxid(req.ChannelPoint)
+		if err != nil {
+			return nil, err
+		}
+		chanPoint = wire.NewOutPoint(txid, req.ChannelPoint.OutputIndex)
+	}
+
+	if len(req.RemotePubkey) != 0 &&
+		len(req.RemotePubkey) != btcec.PubKeyBytesLenCompressed {
+
+		return nil, fmt.Errorf("invalid remote_pubkey length: %v",
+			len(req.RemotePubkey))
+	}
+
+	if req.EndHeight != 0 && req.StartHeight > req.EndHeight {
+		return nil, fmt.Errorf("start_height %v is above end_height "+
+			"%v", req.StartHeight, req.EndHeight)
+	}
+
+	closeTypes := make(map[lnrpc.ChannelCloseSummary_ClosureType]struct{})
+	for _, closeType := range req.CloseTypes {
+		closeTypes[closeType] = struct{}{}
+	}
+
+	records, err := r.server.chainArb.CloseRecords()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CloseHeight < records[j].CloseHeight
+	})
+
+	resp := &lnrpc.CloseHistoryResponse{
+		Records: make([]*lnrpc.ChannelCloseRecord, 0, len(records)),
+	}
+	for _, record := range records {
+		if chanPoint != nil && record.ChanPoint != *chanPoint {
+			continue
+		}
+
+		if len(req.RemotePubkey) != 0 && (record.RemotePub == nil ||
+			!bytes.Equal(record.RemotePub.SerializeCompressed(),
+				req.RemotePubkey)) {
+
+			continue
+		}
+
+		rpcCloseType := marshalCloseType(record.CloseType)
+		if _, ok := closeTypes[rpcCloseType]; len(closeTypes) != 0 &&
+			!ok {
+
+			continue
+		}
+
+		if record.CloseHeight < req.StartHeight ||
+			(req.EndHeight != 0 && record.CloseHeight > req.EndHeight) {
+
+			continue
+		}
+
+		if req.ResolvedOnly && !record.Resolved {
+			continue
+		}
+
+		rpcRecord, err := marshalCloseRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		resp.Records = append(resp.Records, rpcRecord)
+	}
+
+	return resp, nil
+}
+
+// marshalCloseRecord converts a close record into its RPC representation.
+func marshalCloseRecord(
+	record *channeldb.CloseRecord) (*lnrpc.ChannelCloseRecord, error) {
+
+	var remotePub string
+	if record.RemotePub != nil {
+		remotePub = hex.EncodeToString(
+			record.RemotePub.SerializeCompressed(),
+		)
+	}
+
+	rpcRecord := &lnrpc.ChannelCloseRecord{
+		ChannelPoint:         record.ChanPoint.String(),
+		RemotePubkey:         remotePub,
+		Capacity:             int64(record.Capacity),
+		ClosingTxHash:        record.ClosingTXID.String(),
+		CloseHeight:          record.CloseHeight,
+		CloseType:            marshalCloseType(record.CloseType),
+		CloseInitiator:       marshalCloseInitiator(record.Initiator),
+		CloseReason:          marshalCloseReason(record.Reason),
+		LastLocalBalanceSat:  int64(record.LastLocalBalance),
+		DirectBalanceSat:     int64(record.DirectBalance),
+		TimeLockedBalanceSat: int64(record.TimeLockedBalance),
+		CloseFeeSat:          int64(record.CloseFee),
+		AnchorFeeSat:         int64(record.AnchorFee),
+		SweepFeeSat:          int64(record.SweepFees()),
+		RecoveredSat:         int64(record.Recovered()),
+		NetChangeSat:         int64(record.NetChange()),
+		Resolved:             record.Resolved,
+		Outputs: make(
+			[]*lnrpc.CloseOutputRecord, 0, len(record.Outputs),
+		),
+	}
+
+	for _, output := range record.Outputs {
+		// The resolution conversion is shared with the resolver
+		// reports of closed channels.
+		res, err := rpcChannelResolution(&channeldb.ResolverReport{
+			OutPoint:        output.OutPoint,
+			Amount:          output.Amount,
+			ResolverType:    output.ResolverType,
+			ResolverOutcome: output.ResolverOutcome,
+			SpendTxID:       output.SweepTxID,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var recoveredTime int64
+		if !output.RecoveredTime.IsZero() {
+			recoveredTime = output.RecoveredTime.Unix()
+		}
+
+		rpcRecord.Outputs = append(rpcRecord.Outputs,
+			&lnrpc.CloseOutputRecord{
+				Outpoint:        res.Outpoint,
+				ResolutionType:  res.ResolutionType,
+				Outcome:         res.Outcome,
+				AmountSat:       res.AmountSat,
+				TimeLocked:      output.TimeLocked,
+				SweepFeeSat:     int64(output.SweepFee),
+				RecoveredSat:    int64(output.Recovered),
+				SweepTxid:       res.SweepTxid,
+				RecoveredHeight: output.RecoveredHeight,
+				RecoveredTime:   recoveredTime,
+			},
+		)
+	}
+
+	return rpcRecord, nil
+}
+
+// marshalCloseInitiator converts the close initiator of a close record into
+// its RPC representation.
+func marshalCloseInitiator(initiator channeldb.CloseInitiator) lnrpc.Initiator {
+	switch initiator {
+	case channeldb.CloseInitiatorLocal:
+		return lnrpc.Initiator_INITIATOR_LOCAL
+	case channeldb.CloseInitiatorRemote:
+		return lnrpc.Initiator_INITIATOR_REMOTE
+	case channeldb.CloseInitiatorBoth:
+		return lnrpc.Initiator_INITIATOR_BOTH
+	default:
+		return lnrpc.Initiator_INITIATOR_UNKNOWN
+	}
+}
+
+// marshalCloseReason converts the reason of a close into its RPC
+// representation.
+func marshalCloseReason(reason channeldb.CloseReason) lnrpc.CloseReason {
+	switch reason {
+	case channeldb.CloseReasonCooperative:
+		return lnrpc.CloseReason_CLOSE_REASON_COOPERATIVE
+	case channeldb.CloseReasonForceCloseRequest:
+		return lnrpc.CloseReason_CLOSE_REASON_FORCE_CLOSE_REQUEST
+	case channeldb.CloseReasonHtlcOnChain:
+		return lnrpc.CloseReason_CLOSE_REASON_HTLC_ON_CHAIN
+	case channeldb.CloseReasonRemoteForceClose:
+		return lnrpc.CloseReason_CLOSE_REASON_REMOTE_FORCE_CLOSE
+	case channeldb.CloseReasonBreach:
+		return lnrpc.CloseReason_CLOSE_REASON_BREACH
+	case channeldb.CloseReasonFundingCanceled:
+		return lnrpc.CloseReason_CLOSE_REASON_FUNDING_CANCELED
+	case channeldb.CloseReasonAbandoned:
+		return lnrpc.CloseReason_CLOSE_REASON_ABANDONED
+	default:
+		return lnrpc.CloseReason_CLOSE_REASON_UNKNOWN
+	}
 }
 
 // SubscribeChannelEvents returns a uni-directional stream (server -> client)
@@ -4940,10 +5863,135 @@ func (r *rpcServer) SubscribeChannelEvents(req *lnrpc.ChannelEventSubscription,
 			}
 			return updateStream.Context().Err()
 
-		case <-r.quit:
-			return nil
-		}
+		case <-r.quit:
+			return nil
+		}
+	}
+}
+
+// SubscribeBreachEvents returns a uni-directional stream (server -> client)
+// for notifying the client of the progress of the justice spends for breached
+// channels.
+func (r *rpcServer) SubscribeBreachEvents(req *lnrpc.BreachEventSubscription,
+	updateStream lnrpc.Lightning_SubscribeBreachEventsServer) error {
+
+	var chanPoint *wire.OutPoint
+	if req.ChannelPoint != nil {
+		txid, err := lnrpc.GetChanPointFundingTxid(req.ChannelPoint)
+		if err != nil {
+			return err
+		}
+		chanPoint = wire.NewOutPoint(txid, req.ChannelPoint.OutputIndex)
+	}
+
+	breachEventSub, err := r.server.breachArbitrator.SubscribeBreachEvents()
+	if err != nil {
+		return err
+	}
+
+	// Ensure that the resources for the client is cleaned up once either
+	// the server, or client exits.
+	defer breachEventSub.Cancel()
+
+	for {
+		select {
+		case e := <-breachEventSub.Updates():
+			event, ok := e.(*contractcourt.BreachEvent)
+			if !ok {
+				return fmt.Errorf("unexpected breach event "+
+					"update: %v", e)
+			}
+
+			if chanPoint != nil && event.ChanPoint != *chanPoint {
+				continue
+			}
+
+			update, err := marshalBreachEvent(event)
+			if err != nil {
+				return err
+			}
+
+			if err := updateStream.Send(update); err != nil {
+				return err
+			}
+
+		// The response stream's context for whatever reason has been
+		// closed. If context is closed by an exceeded deadline we will
+		// return an error.
+		case <-updateStream.Context().Done():
+			if errors.Is(updateStream.Context().Err(), context.Canceled) {
+				return nil
+			}
+			return updateStream.Context().Err()
+
+		case <-r.quit:
+			return nil
+		}
+	}
+}
+
+// marshalBreachEvent converts a breach event into its rpc representation.
+func marshalBreachEvent(
+	event *contractcourt.BreachEvent) (*lnrpc.BreachEvent, error) {
+
+	var eventType lnrpc.BreachEvent_EventType
+	switch event.Type {
+	case contractcourt.BreachEventDetected:
+		eventType = lnrpc.BreachEvent_DETECTED
+
+	case contractcourt.BreachEventConfirmed:
+		eventType = lnrpc.BreachEvent_CONFIRMED
+
+	case contractcourt.BreachEventFeeUpdated:
+		eventType = lnrpc.BreachEvent_FEE_UPDATED
+
+	case contractcourt.BreachEventSecondLevel:
+		eventType = lnrpc.BreachEvent_SECOND_LEVEL
+
+	case contractcourt.BreachEventOutputSwept:
+		eventType = lnrpc.BreachEvent_OUTPUT_SWEPT
+
+	case contractcourt.BreachEventOutputLost:
+		eventType = lnrpc.BreachEvent_OUTPUT_LOST
+
+	case contractcourt.BreachEventJusticeServed:
+		eventType = lnrpc.BreachEvent_JUSTICE_SERVED
+
+	default:
+		return nil, fmt.Errorf("unknown breach event type: %v",
+			event.Type)
+	}
+
+	update := &lnrpc.BreachEvent{
+		Type: eventType,
+		ChannelPoint: &lnrpc.ChannelPoint{
+			FundingTxid: &lnrpc.ChannelPoint_FundingTxidBytes{
+				FundingTxidBytes: event.ChanPoint.Hash[:],
+			},
+			OutputIndex: event.ChanPoint.Index,
+		},
+		BreachTxid:      event.BreachTxID.String(),
+		BreachHeight:    event.BreachHeight,
+		Height:          event.Height,
+		AmountSat:       int64(event.Amount),
+		DeadlineHeight:  event.Deadline,
+		ConfTarget:      event.ConfTarget,
+		PendingOutputs:  uint32(event.PendingOutputs),
+		RevokedFundsSat: int64(event.RevokedFunds),
+		TotalFundsSat:   int64(event.TotalFunds),
+	}
+
+	if event.OutPoint != nil {
+		update.Outpoint = lnrpc.MarshalOutPoint(event.OutPoint)
+	}
+	if event.WitnessType != nil {
+		update.WitnessType = event.WitnessType.String()
 	}
+	if event.SpendTxID != nil {
+		update.SpendTxid = event.SpendTxID.String()
+	}
+
+	return update, nil
 }
 
 // paymentStream enables different types of payment streams, such as:
@@ -4951,6 +5999,7 @@ func (r *rpcServer) SubscribeChannelEvents(req *lnrpc.ChannelEventSubscription,
 // execute sendPayment. We use this struct as a sort of bridge to enable code
 // re-use between SendPayment and SendToRoute.
 type paymentStream struct {
+	ctx  context.Context
 	recv func() (*rpcPaymentRequest, error)
 	send func(*lnrpc.SendResponse) error
 }
@@ -4970,6 +6019,7 @@ func (r *rpcServer) SendPayment(stream lnrpc.Lightning_SendPaymentServer) error
 	var lock sync.Mutex
 
 	return r.sendPayment(&paymentStream{
+		ctx: stream.Context(),
 		recv: func() (*rpcPaymentRequest, error) {
 			req, err := stream.Recv()
 			if err != nil {
@@ -4998,6 +6048,7 @@ func (r *rpcServer) SendToRoute(stream lnrpc.Lightning_SendToRouteServer) error
 	var lock sync.Mutex
 
 	return r.sendPayment(&paymentStream{
+		ctx: stream.Context(),
 		recv: func() (*rpcPaymentRequest, error) {
 			req, err := stream.Recv()
 			if err != nil {
@@ -5060,6 +6111,52 @@ type rpcPaymentIntent struct {
 	destCustomRecords record.CustomSet
 
 	route *route.Route
+
+	// releaseSpend removes the payment from the spending windows of the
+	// macaroon it was authorized with. It must be called if the payment
+	// failed.
+	releaseSpend func() error
+}
+
+// authorizePaymentIntent checks the payment intent against the amount and
+// destination restrictions of the macaroon of the request, and records it in
+// the spending windows of the macaroon.
+func (r *rpcServer) authorizePaymentIntent(ctx context.Context,
+	payIntent *rpcPaymentIntent) error {
+
+	// The fee limit counts against the limits as well, as that is the
+	// amount the payment can cost us in the worst case.
+	amt := payIntent.msat + payIntent.feeLimit
+	dest := payIntent.dest
+	if rt := payIntent.route; rt != nil {
+		if len(rt.Hops) == 0 {
+			return fmt.Errorf("route has no hops")
+		}
+
+		amt = rt.TotalAmount
+		dest = rt.Hops[len(rt.Hops)-1].PubKeyBytes
+	}
+
+	release, err := r.macService.AuthorizePayment(ctx, amt, dest)
+	if err != nil {
+		return err
+	}
+	payIntent.releaseSpend = release
+
+	return nil
+}
+
+// releasePaymentIntent removes a failed payment from the spending windows of
+// the macaroon it was authorized with.
+func releasePaymentIntent(payIntent *rpcPaymentIntent) {
+	if payIntent.releaseSpend == nil {
+		return
+	}
+
+	if err := payIntent.releaseSpend(); err != nil {
+		rpcsLog.Errorf("Unable to release spend of payment %x: %v",
+			payIntent.rHash[:], err)
+	}
 }
 
 // extractPaymentIntent attempts to parse the complete details required to
@@ -5352,6 +6449,7 @@ func (r *rpcServer) dispatchPaymentIntent(
 		}
 		err := payment.SetPaymentHash(payIntent.rHash)
 		if err != nil {
+			releasePaymentIntent(payIntent)
 			return nil, err
 		}
 
@@ -5375,6 +6473,7 @@ func (r *rpcServer) dispatchPaymentIntent(
 	// routing err.
 	if routerErr != nil {
 		rpcsLog.Warnf("Unable to send payment: %v", routerErr)
+		releasePaymentIntent(payIntent)
 
 		return &paymentIntentResponse{
 			Err: routerErr,
@@ -5464,6 +6563,11 @@ func (r *rpcServer) sendPayment(stream *paymentStream) error {
 				payIntent, err := r.extractPaymentIntent(
 					nextPayment,
 				)
+				if err == nil {
+					err = r.authorizePaymentIntent(
+						stream.ctx, &payIntent,
+					)
+				}
 				if err != nil {
 					if err := stream.send(&lnrpc.SendResponse{
 						PaymentError: err.Error(),
@@ -5655,6 +6759,11 @@ func (r *rpcServer) sendPaymentSync(ctx context.Context,
 		return nil, err
 	}
 
+	// Make sure the macaroon of the request allows the payment.
+	if err := r.authorizePaymentIntent(ctx, &payIntent); err != nil {
+		return nil, err
+	}
+
 	// With the payment validated, we'll now attempt to dispatch the
 	// payment.
 	resp, saveErr := r.dispatchPaymentIntent(&payIntent)
@@ -5711,6 +6820,11 @@ func (r *rpcServer) AddInvoice(ctx context.Context,
 		return nil, err
 	}
 
+	// Make sure the macaroon of the request allows the invoice amount.
+	if err := r.macService.AuthorizeInvoice(ctx, value); err != nil {
+		return nil, err
+	}
+
 	// Convert the passed routing hints to the required format.
 	routeHints, err := invoicesrpc.CreateZpay32HopHints(invoice.RouteHints)
 	if err != nil {
@@ -5985,6 +7099,164 @@ func (r *rpcServer) GetTransactions(ctx context.Context,
 	return lnrpc.RPCTransactionDetails(transactions), nil
 }
 
+// ListAccountingEntries returns every change to the balance of the node in the
+// given time range, on chain and off chain, classified by what caused it.
+func (r *rpcServer) ListAccountingEntries(ctx context.Context,
+	req *lnrpc.ListAccountingEntriesRequest) (
+	*lnrpc.ListAccountingEntriesResponse, error) {
+
+	if req.EndTime != 0 && req.EndTime < req.StartTime {
+		return nil, fmt.Errorf("end time %v is before start time %v",
+			req.EndTime, req.StartTime)
+	}
+
+	startTime := time.Unix(int64(req.StartTime), 0)
+	var endTime time.Time
+	if req.EndTime != 0 {
+		endTime = time.Unix(int64(req.EndTime), 0)
+	}
+
+	// A zero creation date doesn't filter the payments and invoices.
+	unixOrZero := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+
+		return t.Unix()
+	}
+
+	cfg := &accounting.Config{
+		ListTransactions: func() ([]*lnwallet.TransactionDetail,
+			error) {
+
+			return r.server.cc.Wallet.ListTransactionDetails(
+				0, btcwallet.UnconfirmedHeight, "",
+			)
+		},
+		FetchChannels: r.server.chanStateDB.FetchAllChannels,
+		FetchClosedChannels: func() ([]*channeldb.ChannelCloseSummary,
+			error) {
+
+			return r.server.chanStateDB.FetchClosedChannels(false)
+		},
+		FetchChannelReports: func(chanPoint wire.OutPoint) (
+			[]*channeldb.ResolverReport, error) {
+
+			reports, err := r.server.miscDB.FetchChannelReports(
+				*r.cfg.ActiveNetParams.GenesisHash, &chanPoint,
+			)
+			switch err {
+			// If the channel does not have its resolver outcomes
+			// stored, it has no reports.
+			case channeldb.ErrNoChainHashBucket,
+				channeldb.ErrNoChannelSummaries:
+
+				return nil, nil
+
+			default:
+				return reports, err
+			}
+		},
+		IsInitiator: func(chanPoint wire.OutPoint) (bool, error) {
+			histChan, err := r.server.chanStateDB.
+				FetchHistoricalChannel(&chanPoint)
+			switch err {
+			// The channel was closed in a pre-historic version of
+			// lnd, so we don't know who opened it.
+			case channeldb.ErrNoHistoricalBucket,
+				channeldb.ErrChannelNotFound:
+
+				return false, nil
+
+			case nil:
+				return histChan.IsInitiator, nil
+
+			default:
+				return false, err
+			}
+		},
+		ListSweeps: r.server.sweeper.ListSweeps,
+		ListPayments: func(end time.Time) ([]*channeldb.MPPayment,
+			error) {
+
+			resp, err := r.server.miscDB.QueryPayments(
+				channeldb.PaymentsQuery{
+					MaxPayments:     math.MaxUint64,
+					CreationDateEnd: unixOrZero(end),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			return resp.Payments, nil
+		},
+		ListInvoices: func(end time.Time) ([]invoices.Invoice, error) {
+			resp, err := r.server.invoicesDB.QueryInvoices(
+				ctx, invoices.InvoiceQuery{
+					NumMaxInvoices:  math.MaxUint64,
+					CreationDateEnd: unixOrZero(end),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			return resp.Invoices, nil
+		},
+		ListForwards: func(start, end time.Time, maxEvents uint64) (
+			[]channeldb.ForwardingEvent, error) {
+
+			if maxEvents == 0 || maxEvents > math.MaxUint32 {
+				maxEvents = math.MaxUint32
+			}
+
+			timeSlice, err := r.server.miscDB.ForwardingLog().Query(
+				channeldb.ForwardingEventQuery{
+					StartTime:    start,
+					EndTime:      end,
+					NumMaxEvents: uint32(maxEvents),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			return timeSlice.ForwardingEvents, nil
+		},
+	}
+
+	entries, err := accounting.ListEntries(cfg, accounting.Query{
+		StartTime:   startTime,
+		EndTime:     endTime,
+		IndexOffset: req.IndexOffset,
+		MaxEntries:  req.MaxEntries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &lnrpc.ListAccountingEntriesResponse{
+		Entries:         make([]*lnrpc.AccountingEntry, len(entries)),
+		LastIndexOffset: req.IndexOffset + uint64(len(entries)),
+	}
+	for i, entry := range entries {
+		resp.Entries[i] = &lnrpc.AccountingEntry{
+			Timestamp:   entry.Timestamp.Unix(),
+			Type:        lnrpc.AccountingEntryType(entry.Type),
+			OnChain:     entry.OnChain,
+			Txid:        entry.TxID,
+			BlockHeight: entry.BlockHeight,
+			Reference:   entry.Reference,
+			AmountMsat:  entry.AmountMsat,
+			FeeMsat:     entry.FeeMsat,
+			Label:       entry.Label,
+		}
+	}
+
+	return resp, nil
+}
+
 // DescribeGraph returns a description of the latest graph state from the PoV
 // of the node. The graph information is partitioned into two components: all
 // the nodes/vertexes, and all the edges that connect the vertexes themselves.
@@ -7059,6 +8331,21 @@ func (r *rpcServer) UpdateChannelPolicy(ctx context.Context,
 		return nil, fmt.Errorf("unknown scope: %v", scope)
 	}
 
+	// Make sure the macaroon of the request allows updating the targeted
+	// channels. A global update is only allowed if the macaroon isn't
+	// restricted to a set of channels.
+	if len(targetChans) == 0 {
+		if err := r.macService.AuthorizeChannel(ctx, nil); err != nil {
+			return nil, err
+		}
+	}
+	for i := range targetChans {
+		err := r.macService.AuthorizeChannel(ctx, &targetChans[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var feeRateFixed uint32
 
 	switch {
@@ -7664,6 +8951,209 @@ func (r *rpcServer) SubscribeChannelBackups(req *lnrpc.ChannelBackupSubscription
 	}
 }
 
+// GetDatabaseStats returns the size of the bolt database files and the amount
+// of data stored in the top level buckets of each database backend.
+func (r *rpcServer) GetDatabaseStats(_ context.Context,
+	_ *lnrpc.GetDatabaseStatsRequest) (*lnrpc.GetDatabaseStatsResponse,
+	error) {
+
+	resp := &lnrpc.GetDatabaseStatsResponse{
+		Backend: r.cfg.DB.Backend,
+	}
+
+	if r.cfg.DB.Backend == lncfg.BoltBackend {
+		for _, m := range boltMigrations(r.cfg) {
+			path := filepath.Join(m.dir, m.boltFileName)
+			info, err := os.Stat(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Files = append(resp.Files, &lnrpc.DatabaseFile{
+				Path: path,
+				Size: info.Size(),
+				CompactionScheduled: dbcompact.CompactionScheduled(
+					m.dir, m.boltFileName,
+				),
+			})
+		}
+	}
+
+	// Depending on the backend, several of the databases may share the
+	// same backend instance, which we only want to walk once.
+	dbs := r.server.dbs
+	backends := []struct {
+		name    string
+		backend kvdb.Backend
+	}{
+		{lncfg.NSChannelDB, dbs.ChanStateDB.Backend},
+		{"graphdb", dbs.GraphDB.Backend},
+		{"heighthintdb", dbs.HeightHintDB},
+		{lncfg.NSMacaroonDB, dbs.MacaroonDB},
+		{lncfg.NSDecayedLogDB, dbs.DecayedLogDB},
+	}
+	seen := make(map[kvdb.Backend]struct{}, len(backends))
+	for _, db := range backends {
+		if db.backend == nil {
+			continue
+		}
+		if _, ok := seen[db.backend]; ok {
+			continue
+		}
+		seen[db.backend] = struct{}{}
+
+		stats, err := channeldb.BackendBucketStats(db.backend)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch stats of %v: %w",
+				db.name, err)
+		}
+
+		for _, bucket := range stats {
+			rpcBucket := marshalDatabaseBucket(bucket)
+			rpcBucket.Database = db.name
+			resp.Buckets = append(resp.Buckets, rpcBucket)
+		}
+	}
+
+	return resp, nil
+}
+
+// marshalDatabaseBucket converts the stats of a database bucket and its
+// nested buckets into their RPC representation.
+func marshalDatabaseBucket(stats channeldb.BucketStats) *lnrpc.DatabaseBucket {
+	rpcBucket := &lnrpc.DatabaseBucket{
+		Name:       stats.Name,
+		NumBuckets: stats.NumBuckets,
+		NumKeys:    stats.NumKeys,
+		NumBytes:   stats.NumBytes,
+	}
+	for _, nested := range stats.Nested {
+		rpcBucket.NestedBuckets = append(
+			rpcBucket.NestedBuckets, marshalDatabaseBucket(nested),
+		)
+	}
+
+	return rpcBucket
+}
+
+// CompactDatabase compacts the bolt database files, either right away or at the
+// next restart of lnd.
+func (r *rpcServer) CompactDatabase(ctx context.Context,
+	req *lnrpc.CompactDatabaseRequest) (*lnrpc.CompactDatabaseResponse,
+	error) {
+
+	if r.cfg.DB.Backend != lncfg.BoltBackend {
+		return nil, fmt.Errorf("compaction is only supported by the "+
+			"%v backend", lncfg.BoltBackend)
+	}
+
+	if req.Online {
+		backend, ok := r.server.miscDB.Backend.(*dbcompact.Backend)
+		if !ok {
+			return nil, fmt.Errorf("online compaction must be " +
+				"enabled with db.online-compaction")
+		}
+
+		result, err := backend.Compact(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &lnrpc.CompactDatabaseResponse{
+			SizeBefore:      result.SizeBefore,
+			SizeAfter:       result.SizeAfter,
+			DurationMs:      result.Duration.Milliseconds(),
+			PauseDurationMs: result.PauseDuration.Milliseconds(),
+		}, nil
+	}
+
+	resp := &lnrpc.CompactDatabaseResponse{}
+	for _, m := range boltMigrations(r.cfg) {
+		// The wallet database isn't opened by us but by the wallet
+		// itself, so it can't be compacted on start.
+		if m.boltFileName == lncfg.WalletDBName {
+			continue
+		}
+
+		path := filepath.Join(m.dir, m.boltFileName)
+		if !lnrpc.FileExists(path) {
+			continue
+		}
+
+		err := dbcompact.ScheduleCompaction(m.dir, m.boltFileName)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.ScheduledFiles = append(resp.ScheduledFiles, path)
+	}
+
+	rpcsLog.Infof("Scheduled compaction of %v at next restart",
+		resp.ScheduledFiles)
+
+	return resp, nil
+}
+
+// ListChannelBackupVersions returns the versions of the multi-channel backup
+// that are kept by the configured backup sinks that store a history of
+// backups, newest first.
+func (r *rpcServer) ListChannelBackupVersions(_ context.Context,
+	req *lnrpc.ListChannelBackupVersionsRequest) (
+	*lnrpc.ListChannelBackupVersionsResponse, error) {
+
+	versions, err := r.server.chanBackupSwapper.ListVersions(req.Sink)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &lnrpc.ListChannelBackupVersionsResponse{
+		Versions: make([]*lnrpc.ChannelBackupVersion, 0, len(versions)),
+	}
+	for _, version := range versions {
+		resp.Versions = append(resp.Versions, &lnrpc.ChannelBackupVersion{
+			Sink:      version.Sink,
+			Name:      version.Name,
+			Timestamp: version.Timestamp.Unix(),
+			Size:      version.Size,
+		})
+	}
+
+	return resp, nil
+}
+
+// marshalBackupSinkStatuses converts the status of the channel backup sinks
+// into their RPC representation.
+func marshalBackupSinkStatuses(
+	statuses []chanbackup.SinkStatus) []*lnrpc.ChannelBackupSinkStatus {
+
+	// unixOrZero returns the unix timestamp of the given time, or zero if
+	// the time isn't set.
+	unixOrZero := func(t time.Time) int64 {
+		if t.IsZero() {
+			return 0
+		}
+
+		return t.Unix()
+	}
+
+	rpcStatuses := make([]*lnrpc.ChannelBackupSinkStatus, 0, len(statuses))
+	for _, status := range statuses {
+		rpcStatuses = append(rpcStatuses, &lnrpc.ChannelBackupSinkStatus{
+			Name:                 status.Name,
+			NumSuccesses:         status.NumSuccesses,
+			NumFailures:          status.NumFailures,
+			LastSuccessTimestamp: unixOrZero(status.LastSuccess),
+			LastFailureTimestamp: unixOrZero(status.LastFailure),
+			LastError:            status.LastError,
+		})
+	}
+
+	return rpcStatuses
+}
+
 // ChannelAcceptor dispatches a bi-directional streaming RPC in which
 // OpenChannel requests are sent to the client and the client responds with
 // a boolean that tells LND whether or not to accept the channel. This allows
@@ -7809,6 +9299,98 @@ func (r *rpcServer) ListMacaroonIDs(ctx context.Context,
 	return &lnrpc.ListMacaroonIDsResponse{RootKeyIds: rootKeyIDs}, nil
 }
 
+// ListAuditLog returns the entries of the RPC audit log.
+func (r *rpcServer) ListAuditLog(_ context.Context,
+	req *lnrpc.ListAuditLogRequest) (*lnrpc.ListAuditLogResponse, error) {
+
+	auditLog := r.interceptorChain.AuditLog()
+	if auditLog == nil {
+		return nil, errAuditLogDisabled
+	}
+
+	var endTime time.Time
+	if req.EndTime != 0 {
+		endTime = time.Unix(int64(req.EndTime), 0)
+	}
+
+	entries, err := auditLog.Query(
+		time.Unix(int64(req.StartTime), 0), endTime, req.Method,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &lnrpc.ListAuditLogResponse{
+		Entries: make([]*lnrpc.AuditLogEntry, len(entries)),
+	}
+	for i, entry := range entries {
+		resp.Entries[i] = marshalAuditEntry(entry)
+	}
+
+	return resp, nil
+}
+
+// SubscribeAuditLog streams every new entry of the RPC audit log.
+func (r *rpcServer) SubscribeAuditLog(_ *lnrpc.SubscribeAuditLogRequest,
+	updateStream lnrpc.Lightning_SubscribeAuditLogServer) error {
+
+	auditLog := r.interceptorChain.AuditLog()
+	if auditLog == nil {
+		return errAuditLogDisabled
+	}
+
+	subscription, err := auditLog.Subscribe()
+	if err != nil {
+		return err
+	}
+	defer subscription.Cancel()
+
+	for {
+		select {
+		case update := <-subscription.Updates():
+			entry, ok := update.(*rpcperms.AuditEntry)
+			if !ok {
+				continue
+			}
+
+			err := updateStream.Send(marshalAuditEntry(entry))
+			if err != nil {
+				return err
+			}
+
+		// The response stream's context for whatever reason has been
+		// closed. If context is closed by an exceeded deadline we will
+		// return an error.
+		case <-updateStream.Context().Done():
+			err := updateStream.Context().Err()
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+
+		case <-subscription.Quit():
+			return fmt.Errorf("audit log shutting down")
+
+		case <-r.quit:
+			return nil
+		}
+	}
+}
+
+// marshalAuditEntry converts an audit log entry to its RPC counterpart.
+func marshalAuditEntry(entry *rpcperms.AuditEntry) *lnrpc.AuditLogEntry {
+	return &lnrpc.AuditLogEntry{
+		TimestampNs: entry.Timestamp.UnixNano(),
+		Method:      entry.Method,
+		RootKeyId:   entry.RootKeyID,
+		Caveats:     entry.Caveats,
+		ClientIp:    entry.ClientIP,
+		Request:     entry.Request,
+		Error:       entry.Error,
+		LatencyNs:   int64(entry.Latency),
+	}
+}
+
 // DeleteMacaroonID removes a specific macaroon ID.
 func (r *rpcServer) DeleteMacaroonID(ctx context.Context,
 	req *lnrpc.DeleteMacaroonIDRequest) (
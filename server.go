@@ -29,6 +29,7 @@ import (
 	"github.com/lightningnetwork/lnd/brontide"
 	"github.com/lightningnetwork/lnd/chainreg"
 	"github.com/lightningnetwork/lnd/chanacceptor"
+	"github.com/lightningnetwork/lnd/chanarchive"
 	"github.com/lightningnetwork/lnd/chanbackup"
 	"github.com/lightningnetwork/lnd/chanfitness"
 	"github.com/lightningnetwork/lnd/channeldb"
@@ -247,6 +248,9 @@ type server struct {
 	// channel DB that haven't been separated out yet.
 	miscDB *channeldb.DB
 
+	// dbs holds all database instances lnd opened on start up.
+	dbs *DatabaseInstances
+
 	invoicesDB invoices.InvoiceDB
 
 	aliasMgr *aliasmgr.Manager
@@ -315,6 +319,14 @@ type server struct {
 	// channelNotifier to be notified of newly opened and closed channels.
 	chanSubSwapper *chanbackup.SubSwapper
 
+	// chanBackupSwapper writes the multi channel backup to the main backup
+	// file and any additional backup sinks, and tracks their status.
+	chanBackupSwapper *chanbackup.MultiSwapper
+
+	// chanArchiver archives or deletes the state of fully resolved closed
+	// channels. It is nil if no closed channel policy is configured.
+	chanArchiver *chanarchive.Archiver
+
 	// chanEventStore tracks the behaviour of channels and their remote peers to
 	// provide insights into their health and performance.
 	chanEventStore *chanfitness.ChannelEventStore
@@ -567,6 +579,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		chanStateDB:    dbs.ChanStateDB.ChannelStateDB(),
 		addrSource:     dbs.ChanStateDB,
 		miscDB:         dbs.ChanStateDB,
+		dbs:            dbs,
 		invoicesDB:     dbs.InvoiceDB,
 		cc:             cc,
 		sigPool:        lnwallet.NewSigPool(cfg.Workers.Sig, cc.Signer),
@@ -963,6 +976,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 	s.controlTower = routing.NewControlTower(paymentControl)
 
 	strictPruning := (cfg.Bitcoin.Node == "neutrino" ||
+		cfg.Bitcoin.Node == "electrum" ||
 		cfg.Routing.StrictZombiePruning)
 	s.chanRouter, err = routing.New(routing.Config{
 		Graph:               chanGraph,
@@ -983,6 +997,9 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		Clock:               clock.NewDefaultClock(),
 		StrictZombiePruning: strictPruning,
 		IsAlias:             aliasmgr.IsAlias,
+		MinChannelCapacity: btcutil.Amount(
+			cfg.Gossip.MinChanCapacity,
+		),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("can't create router: %w", err)
@@ -1019,6 +1036,8 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		WaitingProofStore:       waitingProofStore,
 		MessageStore:            gossipMessageStore,
 		AnnSigner:               s.nodeSigner,
+		SchnorrSigner:           cc.KeyRing,
+		MuSig2Signer:            cc.Signer,
 		RotateTicker:            ticker.New(discovery.DefaultSyncerRotationInterval),
 		HistoricalSyncTicker:    ticker.New(cfg.HistoricalSyncInterval),
 		NumActiveSyncers:        cfg.NumGraphSyncPeers,
@@ -1032,9 +1051,17 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		IsAlias:                 aliasmgr.IsAlias,
 		SignAliasUpdate:         s.signAliasUpdate,
 		FindBaseByAlias:         s.aliasMgr.FindBaseSCID,
+		GetAliases:              s.aliasMgr.GetAliases,
 		GetAlias:                s.aliasMgr.GetPeerAlias,
 		FindChannel:             s.findChannel,
 		IsStillZombieChannel:    s.chanRouter.IsZombieChannel,
+		Bandwidth: discovery.BandwidthBudget{
+			InboundBytesPerSec:  cfg.Gossip.PeerInboundBytesPerSec,
+			OutboundBytesPerSec: cfg.Gossip.PeerOutboundBytesPerSec,
+			BurstBytes:          cfg.Gossip.PeerBurstBytes,
+		},
+		MaxSyncHops:    cfg.Gossip.MaxSyncHops,
+		ForEachChannel: s.graphDB.ForEachChannel,
 	}, nodeKeyDesc)
 
 	s.localChanMgr = &localchans.Manager{
@@ -1064,19 +1091,20 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 	}
 
 	s.sweeper = sweep.New(&sweep.UtxoSweeperConfig{
-		FeeEstimator:         cc.FeeEstimator,
-		DetermineFeePerKw:    sweep.DetermineFeePerKw,
-		GenSweepScript:       newSweepPkScriptGen(cc.Wallet),
-		Signer:               cc.Wallet.Cfg.Signer,
-		Wallet:               newSweeperWallet(cc.Wallet),
-		TickerDuration:       cfg.Sweeper.BatchWindowDuration,
-		Notifier:             cc.ChainNotifier,
-		Store:                sweeperStore,
-		MaxInputsPerTx:       sweep.DefaultMaxInputsPerTx,
-		MaxSweepAttempts:     sweep.DefaultMaxSweepAttempts,
-		NextAttemptDeltaFunc: sweep.DefaultNextAttemptDeltaFunc,
-		MaxFeeRate:           cfg.Sweeper.MaxFeeRate,
-		FeeRateBucketSize:    sweep.DefaultFeeRateBucketSize,
+		FeeEstimator:          cc.FeeEstimator,
+		DetermineFeePerKw:     sweep.DetermineFeePerKw,
+		GenSweepScript:        newSweepPkScriptGen(cc.Wallet),
+		Signer:                cc.Wallet.Cfg.Signer,
+		Wallet:                newSweeperWallet(cc.Wallet),
+		CoinSelectionStrategy: cc.Wallet.Cfg.CoinSelectionStrategy,
+		TickerDuration:        cfg.Sweeper.BatchWindowDuration,
+		Notifier:              cc.ChainNotifier,
+		Store:                 sweeperStore,
+		MaxInputsPerTx:        sweep.DefaultMaxInputsPerTx,
+		MaxSweepAttempts:      sweep.DefaultMaxSweepAttempts,
+		NextAttemptDeltaFunc:  sweep.DefaultNextAttemptDeltaFunc,
+		MaxFeeRate:            cfg.Sweeper.MaxFeeRate,
+		FeeRateBucketSize:     sweep.DefaultFeeRateBucketSize,
 	})
 
 	s.utxoNursery = contractcourt.NewUtxoNursery(&contractcourt.NurseryConfig{
@@ -1106,6 +1134,13 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 	// breach events from the ChannelArbitrator to the BreachArbitrator,
 	contractBreaches := make(chan *contractcourt.ContractBreachEvent, 1)
 
+	justiceHtlcStrategy, err := contractcourt.ParseJusticeHtlcStrategy(
+		cfg.Breach.HtlcStrategy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	s.breachArbitrator = contractcourt.NewBreachArbitrator(
 		&contractcourt.BreachConfig{
 			CloseLink:          closeLink,
@@ -1119,6 +1154,8 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 			Store: contractcourt.NewRetributionStore(
 				dbs.ChanStateDB,
 			),
+			Sweeper:      s.sweeper,
+			HtlcStrategy: justiceHtlcStrategy,
 		},
 	)
 
@@ -1211,6 +1248,7 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 				return ErrServerShuttingDown
 			}
 		},
+		MempoolBreach: s.breachArbitrator.HandleMempoolBreach,
 		DisableChannel: func(chanPoint wire.OutPoint) error {
 			return s.chanStatusMgr.RequestDisable(chanPoint, false)
 		},
@@ -1225,6 +1263,11 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		SubscribeBreachComplete:       s.breachArbitrator.SubscribeBreachComplete, //nolint:lll
 		PutFinalHtlcOutcome:           s.chanStateDB.PutOnchainFinalHtlcOutcome,   //nolint: lll
 		HtlcNotifier:                  s.htlcNotifier,
+		FetchTxDetails: func(txid chainhash.Hash) (
+			*lnwallet.TransactionDetail, error) {
+
+			return cc.Wallet.GetTransactionDetails(&txid)
+		},
 	}, dbs.ChanStateDB)
 
 	// Select the configuration and funding parameters for Bitcoin.
@@ -1483,6 +1526,16 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		addrs:        dbs.ChanStateDB,
 	}
 	backupFile := chanbackup.NewMultiFile(cfg.BackupFilePath)
+	backupSinks, err := newChanBackupSinks(cfg.ChanBackup)
+	if err != nil {
+		return nil, err
+	}
+	s.chanBackupSwapper, err = chanbackup.NewMultiSwapper(
+		backupFile, backupSinks...,
+	)
+	if err != nil {
+		return nil, err
+	}
 	startingChans, err := chanbackup.FetchStaticChanBackups(
 		s.chanStateDB, s.addrSource,
 	)
@@ -1490,12 +1543,36 @@ func newServer(cfg *Config, listenAddrs []net.Addr,
 		return nil, err
 	}
 	s.chanSubSwapper, err = chanbackup.NewSubSwapper(
-		startingChans, chanNotifier, s.cc.KeyRing, backupFile,
+		startingChans, chanNotifier, s.cc.KeyRing, s.chanBackupSwapper,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.ChanArchive.Mode != "" {
+		mode := chanarchive.ModeArchive
+		if cfg.ChanArchive.Mode == lncfg.ChanArchiveModeDelete {
+			mode = chanarchive.ModeDelete
+		}
+
+		s.chanArchiver = chanarchive.New(&chanarchive.Config{
+			DB:          s.chanStateDB,
+			Mode:        mode,
+			Dir:         cfg.ChanArchive.Dir,
+			SafetyDepth: cfg.ChanArchive.SafetyDepth,
+			BestHeight: func() (uint32, error) {
+				_, height, err := s.cc.ChainIO.GetBestBlock()
+				if err != nil {
+					return 0, err
+				}
+
+				return uint32(height), nil
+			},
+			Ticker: ticker.New(cfg.ChanArchive.Interval),
+			Clock:  clock.NewDefaultClock(),
+		})
+	}
+
 	// Assemble a peer notifier which will provide clients with subscriptions
 	// to peer online and offline events.
 	s.peerNotifier = peernotifier.New()
@@ -1773,25 +1850,28 @@ func (s *server) createLivenessMonitor(cfg *Config, cc *chainreg.ChainControl) {
 	}
 
 	// If remote signing is enabled, add the healthcheck for the remote
-	// signing RPC interface.
-	if s.cfg.RemoteSigner != nil && s.cfg.RemoteSigner.Enable {
-		// Because we have two cascading timeouts here, we need to add
-		// some slack to the "outer" one of them in case the "inner"
-		// returns exactly on time.
+	// signing RPC interface. The check only fails if none of the remote
+	// signers can be reached, and it also brings signers back into use
+	// once they're reachable again.
+	rpcKeyRing, ok := cc.Wc.(*rpcwallet.RPCKeyRing)
+	if s.cfg.RemoteSigner != nil && s.cfg.RemoteSigner.Enable && ok {
+		// Because we have cascading timeouts here, we need to add some
+		// slack to the "outer" one of them in case the "inner" ones
+		// return exactly on time. Every signer is checked in turn.
 		overhead := time.Millisecond * 10
+		numSigners := time.Duration(1 + len(s.cfg.RemoteSigner.Fallbacks))
 
 		remoteSignerConnectionCheck := healthcheck.NewObservation(
 			"remote signer connection",
-			rpcwallet.HealthCheck(
-				s.cfg.RemoteSigner,
-
+			rpcKeyRing.HealthCheck(
 				// For the health check we might to be even
 				// stricter than the initial/normal connect, so
 				// we use the health check timeout here.
 				cfg.HealthChecks.RemoteSigner.Timeout,
 			),
 			cfg.HealthChecks.RemoteSigner.Interval,
-			cfg.HealthChecks.RemoteSigner.Timeout+overhead,
+			cfg.HealthChecks.RemoteSigner.Timeout*numSigners+
+				overhead,
 			cfg.HealthChecks.RemoteSigner.Backoff,
 			cfg.HealthChecks.RemoteSigner.Attempts,
 		)
@@ -2053,12 +2133,26 @@ func (s *server) Start() error {
 			}
 		}
 
+		if err := s.chanBackupSwapper.Start(); err != nil {
+			startErr = err
+			return
+		}
+		cleanup = cleanup.add(s.chanBackupSwapper.Stop)
+
 		if err := s.chanSubSwapper.Start(); err != nil {
 			startErr = err
 			return
 		}
 		cleanup = cleanup.add(s.chanSubSwapper.Stop)
 
+		if s.chanArchiver != nil {
+			if err := s.chanArchiver.Start(); err != nil {
+				startErr = err
+				return
+			}
+			cleanup = cleanup.add(s.chanArchiver.Stop)
+		}
+
 		if s.torController != nil {
 			if err := s.createNewHiddenService(); err != nil {
 				startErr = err
@@ -2276,6 +2370,16 @@ func (s *server) Stop() error {
 		if err := s.chanSubSwapper.Stop(); err != nil {
 			srvrLog.Warnf("failed to stop chanSubSwapper: %v", err)
 		}
+		if err := s.chanBackupSwapper.Stop(); err != nil {
+			srvrLog.Warnf("failed to stop chanBackupSwapper: %v",
+				err)
+		}
+		if s.chanArchiver != nil {
+			if err := s.chanArchiver.Stop(); err != nil {
+				srvrLog.Warnf("failed to stop chanArchiver: %v",
+					err)
+			}
+		}
 		if err := s.cc.ChainNotifier.Stop(); err != nil {
 			srvrLog.Warnf("Unable to stop ChainNotifier: %v", err)
 		}
@@ -4719,3 +4823,57 @@ func shouldPeerBootstrap(cfg *Config) bool {
 	// covering the bootstrapping process.
 	return !cfg.NoNetBootstrap && !isDevNetwork
 }
+
+// newChanBackupSinks creates the additional static channel backup sinks that
+// are enabled in the given config.
+func newChanBackupSinks(cfg *lncfg.ChanBackup) ([]chanbackup.Sink, error) {
+	var sinks []chanbackup.Sink
+
+	if cfg.Dir != "" {
+		dirSink, err := chanbackup.NewVersionedDir(
+			cfg.Dir, cfg.MaxVersions, clock.NewDefaultClock(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, dirSink)
+	}
+
+	if cfg.S3.Endpoint != "" {
+		s3Sink, err := chanbackup.NewS3Sink(&chanbackup.S3Config{
+			Endpoint:        cfg.S3.Endpoint,
+			Bucket:          cfg.S3.Bucket,
+			Prefix:          cfg.S3.Prefix,
+			Region:          cfg.S3.Region,
+			AccessKeyID:     cfg.S3.AccessKeyID,
+			SecretAccessKey: cfg.S3.Secret,
+			MaxVersions:     cfg.S3.MaxVersions,
+			Timeout:         cfg.S3.Timeout,
+			Clock:           clock.NewDefaultClock(),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, s3Sink)
+	}
+
+	if cfg.Webhook.URL != "" {
+		webhookSink, err := chanbackup.NewWebhookSink(
+			&chanbackup.WebhookConfig{
+				URL:         cfg.Webhook.URL,
+				MaxAttempts: cfg.Webhook.MaxAttempts,
+				RetryDelay:  cfg.Webhook.RetryDelay,
+				Timeout:     cfg.Webhook.Timeout,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, webhookSink)
+	}
+
+	return sinks, nil
+}
@@ -5,11 +5,15 @@ import (
 	"net"
 	"reflect"
 
+	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btclog"
 	"github.com/lightningnetwork/lnd/autopilot"
 	"github.com/lightningnetwork/lnd/chainreg"
 	"github.com/lightningnetwork/lnd/channeldb"
+	"github.com/lightningnetwork/lnd/channelnotifier"
+	"github.com/lightningnetwork/lnd/clock"
 	"github.com/lightningnetwork/lnd/htlcswitch"
 	"github.com/lightningnetwork/lnd/invoices"
 	"github.com/lightningnetwork/lnd/lncfg"
@@ -24,6 +28,7 @@ import (
 	"github.com/lightningnetwork/lnd/lnrpc/walletrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/watchtowerrpc"
 	"github.com/lightningnetwork/lnd/lnrpc/wtclientrpc"
+	"github.com/lightningnetwork/lnd/lnwallet/signerpolicy"
 	"github.com/lightningnetwork/lnd/lnwire"
 	"github.com/lightningnetwork/lnd/macaroons"
 	"github.com/lightningnetwork/lnd/netann"
@@ -122,7 +127,18 @@ func (s *subRPCServerConfigs) PopulateDependencies(cfg *Config,
 		modifiers ...netann.NodeAnnModifier) error,
 	parseAddr func(addr string) (net.Addr, error),
 	rpcLogger btclog.Logger,
-	getAlias func(lnwire.ChannelID) (lnwire.ShortChannelID, error)) error {
+	getAlias func(lnwire.ChannelID) (lnwire.ShortChannelID, error),
+	channelNotifier *channelnotifier.ChannelNotifier) error {
+
+	// The signer policy is shared by the signrpc and walletrpc
+	// sub-servers, so we create it once up front.
+	signerPolicy, err := newSignerPolicy(
+		cfg.SignerPolicy, chanStateDB.GetParentDB(), activeNetParams,
+		cc.Wallet.IsOurAddress,
+	)
+	if err != nil {
+		return err
+	}
 
 	// First, we'll use reflect to obtain a version of the config struct
 	// that allows us to programmatically inspect its fields.
@@ -163,6 +179,9 @@ func (s *subRPCServerConfigs) PopulateDependencies(cfg *Config,
 			subCfgValue.FieldByName("KeyRing").Set(
 				reflect.ValueOf(cc.KeyRing),
 			)
+			subCfgValue.FieldByName("SignerPolicy").Set(
+				reflect.ValueOf(signerPolicy),
+			)
 
 		case *walletrpc.Config:
 			subCfgValue := extractReflectValue(subCfg)
@@ -202,6 +221,20 @@ func (s *subRPCServerConfigs) PopulateDependencies(cfg *Config,
 					cc.Wallet.Cfg.CoinSelectionStrategy,
 				),
 			)
+			subCfgValue.FieldByName("SignerPolicy").Set(
+				reflect.ValueOf(signerPolicy),
+			)
+			subCfgValue.FieldByName("PsbtFundingVerify").Set(
+				reflect.ValueOf(cc.Wallet.PsbtFundingVerify),
+			)
+			subCfgValue.FieldByName("SubscribeChannelEvents").Set(
+				reflect.ValueOf(
+					channelNotifier.SubscribeChannelEvents,
+				),
+			)
+			subCfgValue.FieldByName("NamedWallets").Set(
+				reflect.ValueOf(cc.NamedWallets),
+			)
 
 		case *autopilotrpc.Config:
 			subCfgValue := extractReflectValue(subCfg)
@@ -382,6 +415,62 @@ func (s *subRPCServerConfigs) FetchConfig(subServerName string) (interface{}, bo
 	return configVal.Interface(), true
 }
 
+// newSignerPolicy creates the policy engine of a remote signer from its
+// configuration. If the policy isn't active, nil is returned.
+func newSignerPolicy(cfg *lncfg.SignerPolicy, db *channeldb.DB,
+	params *chaincfg.Params,
+	isOurAddress func(btcutil.Address) bool) (*signerpolicy.Engine, error) {
+
+	if !cfg.Active {
+		return nil, nil
+	}
+
+	allowed := make(map[string]struct{}, len(cfg.AllowedDestinations))
+	for _, addrStr := range cfg.AllowedDestinations {
+		addr, err := btcutil.DecodeAddress(addrStr, params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer policy "+
+				"destination %v: %w", addrStr, err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("signer policy destination %v "+
+				"is not for network %v", addrStr, params.Name)
+		}
+
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		allowed[string(pkScript)] = struct{}{}
+	}
+
+	// An output pays to our wallet if it pays to one of its addresses.
+	isOwnScript := func(pkScript []byte) bool {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+			pkScript, params,
+		)
+		if err != nil || len(addrs) != 1 {
+			return false
+		}
+
+		return isOurAddress(addrs[0])
+	}
+
+	return signerpolicy.New(&signerpolicy.Config{
+		DB:                         db,
+		Clock:                      clock.NewDefaultClock(),
+		AllowedDestinations:        allowed,
+		IsOwnScript:                isOwnScript,
+		AllowChannelFunding:        cfg.AllowChannelFunding,
+		SendLimit:                  cfg.SendLimit,
+		SendWindow:                 cfg.SendWindow,
+		MaxCommitFeeRate:           cfg.MaxCommitFeeRate.FeePerKWeight(),
+		MaxCommitHTLCs:             cfg.MaxCommitHTLCs,
+		AllowUnverifiedCommitments: cfg.AllowUnverifiedCommitments,
+		AllowUnverifiedMuSig2:      cfg.AllowUnverifiedMuSig2,
+	})
+}
+
 // extractReflectValue attempts to extract the value from an interface using
 // the reflect package. The resulting reflect.Value allows the caller to
 // programmatically examine and manipulate the underlying value.
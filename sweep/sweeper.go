@@ -12,6 +12,7 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
+	base "github.com/btcsuite/btcwallet/wallet"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/input"
@@ -261,6 +262,10 @@ type UtxoSweeperConfig struct {
 	// Wallet contains the wallet functions that sweeper requires.
 	Wallet Wallet
 
+	// CoinSelectionStrategy is the strategy used to pick the wallet utxos
+	// that are added to sweeps which don't have enough value on their own.
+	CoinSelectionStrategy base.CoinSelectionStrategy
+
 	// TickerDuration is used to create a channel that will be sent on when
 	// a certain time window has passed. During this time window, new
 	// inputs can still be added to the sweep tx that is about to be
@@ -1126,6 +1131,7 @@ func (s *UtxoSweeper) getInputLists(cluster inputCluster,
 			append(retryInputs, newInputs...),
 			cluster.sweepFeeRate, maxFeeRate,
 			s.cfg.MaxInputsPerTx, s.cfg.Wallet,
+			s.cfg.CoinSelectionStrategy,
 		)
 		if err != nil {
 			return nil, nil, fmt.Errorf("input partitionings: %w",
@@ -1137,6 +1143,7 @@ func (s *UtxoSweeper) getInputLists(cluster inputCluster,
 	newSets, err := generateInputPartitionings(
 		newInputs, cluster.sweepFeeRate, maxFeeRate,
 		s.cfg.MaxInputsPerTx, s.cfg.Wallet,
+		s.cfg.CoinSelectionStrategy,
 	)
 	if err != nil {
 		return nil, nil, fmt.Errorf("input partitionings: %w", err)
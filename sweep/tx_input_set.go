@@ -8,9 +8,11 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	base "github.com/btcsuite/btcwallet/wallet"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 )
 
 // addConstraints defines the constraints to apply when adding an input.
@@ -119,10 +121,16 @@ type txInputSet struct {
 	// wallet contains wallet functionality required by the input set to
 	// retrieve utxos.
 	wallet Wallet
+
+	// strategy is the coin selection strategy used to pick the wallet
+	// utxos that are added to the set. If it isn't a
+	// chanfunding.ContextualStrategy, the smallest utxos are added first.
+	strategy base.CoinSelectionStrategy
 }
 
 // newTxInputSet constructs a new, empty input set.
-func newTxInputSet(wallet Wallet, feePerKW, maxFeeRate chainfee.SatPerKWeight,
+func newTxInputSet(wallet Wallet, strategy base.CoinSelectionStrategy,
+	feePerKW, maxFeeRate chainfee.SatPerKWeight,
 	maxInputs int) *txInputSet {
 
 	state := txInputSetState{
@@ -133,6 +141,7 @@ func newTxInputSet(wallet Wallet, feePerKW, maxFeeRate chainfee.SatPerKWeight,
 	b := txInputSet{
 		maxInputs:       maxInputs,
 		wallet:          wallet,
+		strategy:        strategy,
 		txInputSetState: state,
 	}
 
@@ -371,8 +380,7 @@ func (t *txInputSet) tryAddWalletInputsIfNeeded() error {
 	}
 
 	// Retrieve wallet utxos. Only consider confirmed utxos to prevent
-	// problems around RBF rules for unconfirmed inputs. This currently
-	// ignores the configured coin selection strategy.
+	// problems around RBF rules for unconfirmed inputs.
 	utxos, err := t.wallet.ListUnspentWitnessFromDefaultAccount(
 		1, math.MaxInt32,
 	)
@@ -380,14 +388,19 @@ func (t *txInputSet) tryAddWalletInputsIfNeeded() error {
 		return err
 	}
 
-	// Sort the UTXOs by putting smaller values at the start of the slice
-	// to avoid locking large UTXO for sweeping.
-	//
-	// TODO(yy): add more choices to CoinSelectionStrategy and use the
-	// configured value here.
-	sort.Slice(utxos, func(i, j int) bool {
-		return utxos[i].Value < utxos[j].Value
-	})
+	// Strategies that pick coins for a target arrange the UTXOs, for the
+	// others we put smaller values at the start of the slice to avoid
+	// locking large UTXO for sweeping.
+	if strategy, ok := t.strategy.(chanfunding.ContextualStrategy); ok {
+		utxos, err = t.arrangeUtxos(strategy, utxos)
+		if err != nil {
+			return err
+		}
+	} else {
+		sort.Slice(utxos, func(i, j int) bool {
+			return utxos[i].Value < utxos[j].Value
+		})
+	}
 
 	for _, utxo := range utxos {
 		input, err := createWalletTxInput(utxo)
@@ -411,6 +424,50 @@ func (t *txInputSet) tryAddWalletInputsIfNeeded() error {
 	return nil
 }
 
+// arrangeUtxos arranges the wallet utxos with the given strategy. Strategies
+// that make use of the wallet metadata of the coins are provided with it if
+// the wallet supports it.
+func (t *txInputSet) arrangeUtxos(strategy chanfunding.ContextualStrategy,
+	utxos []*lnwallet.Utxo) ([]*lnwallet.Utxo, error) {
+
+	infoSource, ok := t.wallet.(chanfunding.CoinInfoSource)
+	if ok && strategy.NeedsCoinInfo() {
+		info, err := infoSource.CoinInfo()
+		if err != nil {
+			return nil, err
+		}
+
+		strategy = strategy.WithCoinInfo(info)
+	}
+
+	coins := make([]base.Coin, len(utxos))
+	byOutpoint := make(map[wire.OutPoint]*lnwallet.Utxo, len(utxos))
+	for i, utxo := range utxos {
+		coins[i] = base.Coin{
+			TxOut: wire.TxOut{
+				Value:    int64(utxo.Value),
+				PkScript: utxo.PkScript,
+			},
+			OutPoint: utxo.OutPoint,
+		}
+		byOutpoint[utxo.OutPoint] = utxo
+	}
+
+	arranged, err := strategy.ArrangeCoins(
+		coins, btcutil.Amount(t.feeRate.FeePerKVByte()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*lnwallet.Utxo, len(arranged))
+	for i, coin := range arranged {
+		result[i] = byOutpoint[coin.OutPoint]
+	}
+
+	return result, nil
+}
+
 // createWalletTxInput converts a wallet utxo into an object that can be added
 // to the other inputs to sweep.
 func createWalletTxInput(utxo *lnwallet.Utxo) (input.Input, error) {
@@ -10,6 +10,7 @@ import (
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
+	base "github.com/btcsuite/btcwallet/wallet"
 	"github.com/lightningnetwork/lnd/input"
 	"github.com/lightningnetwork/lnd/lnwallet"
 	"github.com/lightningnetwork/lnd/lnwallet/chainfee"
@@ -40,7 +41,8 @@ type inputSet []input.Input
 // dust limit are returned.
 func generateInputPartitionings(sweepableInputs []txInput,
 	feePerKW, maxFeeRate chainfee.SatPerKWeight, maxInputsPerTx int,
-	wallet Wallet) ([]inputSet, error) {
+	wallet Wallet, strategy base.CoinSelectionStrategy) ([]inputSet,
+	error) {
 
 	// Sort input by yield. We will start constructing input sets starting
 	// with the highest yield inputs. This is to prevent the construction
@@ -88,7 +90,7 @@ func generateInputPartitionings(sweepableInputs []txInput,
 		// condition that the tx will be published with the specified
 		// fee rate.
 		txInputs := newTxInputSet(
-			wallet, feePerKW, maxFeeRate, maxInputsPerTx,
+			wallet, strategy, feePerKW, maxFeeRate, maxInputsPerTx,
 		)
 
 		// From the set of sweepable inputs, keep adding inputs to the
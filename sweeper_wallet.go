@@ -2,7 +2,9 @@ package lnd
 
 import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/chanfunding"
 )
 
 // sweeperWallet is a wrapper around the LightningWallet that implements the
@@ -27,3 +29,14 @@ func (s *sweeperWallet) CancelRebroadcast(txid chainhash.Hash) {
 		s.Cfg.Rebroadcaster.MarkAsConfirmed(txid)
 	}
 }
+
+// CoinInfo returns the wallet metadata of all coins of the default account.
+//
+// NOTE: This is part of the chanfunding.CoinInfoSource interface.
+func (s *sweeperWallet) CoinInfo() (map[wire.OutPoint]*chanfunding.CoinInfo,
+	error) {
+
+	return lnwallet.FetchCoinInfo(
+		s.LightningWallet, lnwallet.DefaultAccountName,
+	)
+}
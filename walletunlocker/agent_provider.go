@@ -0,0 +1,84 @@
+package walletunlocker
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// AgentProvider fetches the wallet password from a key agent listening on a
+// Unix socket. Each request is sent over a new connection.
+type AgentProvider struct {
+	socket string
+	keyID  string
+}
+
+// A compile time check to ensure that AgentProvider implements the
+// PasswordProvider interface.
+var _ PasswordProvider = (*AgentProvider)(nil)
+
+// NewAgentProvider creates a provider that talks to the key agent listening
+// on the given socket.
+func NewAgentProvider(socket, keyID string) *AgentProvider {
+	return &AgentProvider{
+		socket: socket,
+		keyID:  keyID,
+	}
+}
+
+// request sends a request to the agent and returns the password of its
+// response.
+func (a *AgentProvider) request(ctx context.Context,
+	req *ProviderRequest) ([]byte, error) {
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", a.socket)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to key agent: %w",
+			err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writeRequest(conn, req); err != nil {
+		return nil, fmt.Errorf("unable to send request to key agent: "+
+			"%w", err)
+	}
+
+	password, err := readResponse(conn)
+	if err != nil {
+		return nil, fmt.Errorf("key agent: %w", err)
+	}
+
+	return password, nil
+}
+
+// Password fetches the wallet password from the agent.
+//
+// NOTE: This is part of the PasswordProvider interface.
+func (a *AgentProvider) Password(ctx context.Context) ([]byte, error) {
+	return a.request(ctx, &ProviderRequest{
+		Method: MethodGetPassword,
+		KeyID:  a.keyID,
+	})
+}
+
+// StorePassword replaces the wallet password kept by the agent.
+//
+// NOTE: This is part of the PasswordProvider interface.
+func (a *AgentProvider) StorePassword(ctx context.Context,
+	password []byte) error {
+
+	_, err := a.request(ctx, &ProviderRequest{
+		Method:   MethodStorePassword,
+		KeyID:    a.keyID,
+		Password: password,
+	})
+
+	return err
+}
@@ -0,0 +1,446 @@
+package walletunlocker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/lightningnetwork/lnd/aezeed"
+	"github.com/lightningnetwork/lnd/blockcache"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/lnwallet/btcwallet"
+)
+
+const (
+	// DefaultWalletName is the name of the main wallet of lnd, which is
+	// created and unlocked through the UnlockerService and funds all
+	// channels unless another wallet is selected.
+	DefaultWalletName = "default"
+
+	// NamedWalletsDir is the directory within the network directory the
+	// named wallets are stored in.
+	NamedWalletsDir = "wallets"
+
+	// namedWalletDBName is the name of the database file of a named
+	// wallet within its directory.
+	namedWalletDBName = "wallet.db"
+)
+
+var (
+	// namedWalletRegex matches the valid names of named wallets.
+	namedWalletRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+	// ErrNamedWalletNotFound is returned if no wallet with the requested
+	// name exists.
+	ErrNamedWalletNotFound = errors.New("named wallet not found")
+
+	// ErrNamedWalletExists is returned when creating a wallet with the
+	// name of an existing one.
+	ErrNamedWalletExists = errors.New("named wallet already exists")
+
+	// ErrNamedWalletLocked is returned when using a wallet that hasn't
+	// been unlocked.
+	ErrNamedWalletLocked = errors.New("named wallet is locked")
+
+	// ErrNamedWalletUnlocked is returned when unlocking a wallet that is
+	// already unlocked.
+	ErrNamedWalletUnlocked = errors.New("named wallet is already " +
+		"unlocked")
+)
+
+// NamedWalletConfig holds the configuration of the named wallets.
+type NamedWalletConfig struct {
+	// Dir is the directory the named wallets are stored in, each in a
+	// subdirectory with its name.
+	Dir string
+
+	// NetParams are the parameters of the chain the wallets are used on.
+	NetParams *chaincfg.Params
+
+	// CoinType is the BIP 44 coin type the keys of the wallets are
+	// derived with.
+	CoinType uint32
+
+	// NewChainSource creates the chain backend client of a wallet. Each
+	// wallet syncs with its own client.
+	NewChainSource func() (chain.Interface, error)
+
+	// BlockCache is the cache of blocks shared with the main wallet.
+	BlockCache *blockcache.BlockCache
+
+	// NoFreelistSync disables syncing the freelist of the wallet
+	// databases.
+	NoFreelistSync bool
+
+	// DBTimeout is the time after which opening a wallet database fails.
+	DBTimeout time.Duration
+
+	// CoinSelectionStrategy is the strategy used to select the coins of
+	// the wallets.
+	CoinSelectionStrategy wallet.CoinSelectionStrategy
+}
+
+// NamedWallet is an unlocked named wallet. Each named wallet has its own seed
+// and password and is stored in its own database.
+type NamedWallet struct {
+	*btcwallet.BtcWallet
+
+	name   string
+	loader *wallet.Loader
+
+	coinSelectMtx sync.Mutex
+}
+
+// Name returns the name of the wallet.
+func (w *NamedWallet) Name() string {
+	return w.name
+}
+
+// WithCoinSelectLock executes the passed function while no other coin
+// selection of the wallet is in progress.
+func (w *NamedWallet) WithCoinSelectLock(f func() error) error {
+	w.coinSelectMtx.Lock()
+	defer w.coinSelectMtx.Unlock()
+
+	return f()
+}
+
+// ListCoins returns all UTXOs of the default account of the wallet that have
+// between minConfs and maxConfs confirmations.
+//
+// NOTE: This is part of the chanfunding.CoinSource interface.
+func (w *NamedWallet) ListCoins(minConfs,
+	maxConfs int32) ([]wallet.Coin, error) {
+
+	utxos, err := w.ListUnspentWitness(
+		minConfs, maxConfs, lnwallet.DefaultAccountName,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	coins := make([]wallet.Coin, 0, len(utxos))
+	for _, utxo := range utxos {
+		coins = append(coins, wallet.Coin{
+			TxOut: wire.TxOut{
+				Value:    int64(utxo.Value),
+				PkScript: utxo.PkScript,
+			},
+			OutPoint: utxo.OutPoint,
+		})
+	}
+
+	return coins, nil
+}
+
+// CoinFromOutPoint returns the UTXO of the wallet with the given outpoint.
+//
+// NOTE: This is part of the chanfunding.CoinSource interface.
+func (w *NamedWallet) CoinFromOutPoint(op wire.OutPoint) (*wallet.Coin,
+	error) {
+
+	utxo, err := w.FetchInputInfo(&op)
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet.Coin{
+		TxOut: wire.TxOut{
+			Value:    int64(utxo.Value),
+			PkScript: utxo.PkScript,
+		},
+		OutPoint: utxo.OutPoint,
+	}, nil
+}
+
+// NamedWalletInfo describes a named wallet.
+type NamedWalletInfo struct {
+	// Name is the name of the wallet.
+	Name string
+
+	// Unlocked is true if the wallet is unlocked and can be used.
+	Unlocked bool
+}
+
+// NamedWalletManager manages the named wallets lnd keeps next to its main
+// wallet. Named wallets are created, unlocked and locked while lnd is running
+// and are all locked when it shuts down.
+type NamedWalletManager struct {
+	cfg *NamedWalletConfig
+
+	mu      sync.Mutex
+	wallets map[string]*NamedWallet
+}
+
+// NewNamedWalletManager creates a new manager of named wallets.
+func NewNamedWalletManager(cfg *NamedWalletConfig) *NamedWalletManager {
+	return &NamedWalletManager{
+		cfg:     cfg,
+		wallets: make(map[string]*NamedWallet),
+	}
+}
+
+// ValidateWalletName returns an error if the name isn't a valid name of a
+// named wallet.
+func ValidateWalletName(name string) error {
+	if name == DefaultWalletName {
+		return fmt.Errorf("wallet name %q is reserved for the main "+
+			"wallet", name)
+	}
+
+	if !namedWalletRegex.MatchString(name) {
+		return fmt.Errorf("invalid wallet name %q, must be at most 32 "+
+			"lowercase letters, digits, dashes or underscores",
+			name)
+	}
+
+	return nil
+}
+
+// newLoader creates the loader of the wallet with the given name.
+func (m *NamedWalletManager) newLoader(name string,
+	recoveryWindow uint32) (*wallet.Loader, error) {
+
+	return btcwallet.NewWalletLoader(
+		m.cfg.NetParams, recoveryWindow,
+		btcwallet.LoaderWithLocalWalletDB(
+			filepath.Join(m.cfg.Dir, name), m.cfg.NoFreelistSync,
+			m.cfg.DBTimeout,
+		),
+	)
+}
+
+// Create creates a new named wallet from the given seed and unlocks it.
+func (m *NamedWalletManager) Create(name string, password []byte,
+	seed *aezeed.CipherSeed, recoveryWindow uint32) error {
+
+	if err := ValidateWalletName(name); err != nil {
+		return err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	if !keychain.IsKnownVersion(seed.InternalVersion) {
+		return fmt.Errorf("invalid internal seed version %v, current "+
+			"max version is %v", seed.InternalVersion,
+			keychain.CurrentKeyDerivationVersion)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	loader, err := m.newLoader(name, recoveryWindow)
+	if err != nil {
+		return err
+	}
+	exists, err := loader.WalletExists()
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrNamedWalletExists
+	}
+
+	err = os.MkdirAll(filepath.Join(m.cfg.Dir, name), 0700)
+	if err != nil {
+		return err
+	}
+
+	w, err := loader.CreateNewWallet(
+		password, password, seed.Entropy[:], seed.BirthdayTime(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return m.start(name, loader, w, password, recoveryWindow)
+}
+
+// Unlock unlocks the named wallet with its password.
+func (m *NamedWalletManager) Unlock(name string, password []byte,
+	recoveryWindow uint32) error {
+
+	if err := ValidateWalletName(name); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.wallets[name]; ok {
+		return ErrNamedWalletUnlocked
+	}
+
+	loader, err := m.newLoader(name, recoveryWindow)
+	if err != nil {
+		return err
+	}
+	exists, err := loader.WalletExists()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNamedWalletNotFound
+	}
+
+	w, err := loader.OpenExistingWallet(password, false)
+	if err != nil {
+		return err
+	}
+
+	return m.start(name, loader, w, password, recoveryWindow)
+}
+
+// start starts syncing an opened wallet and makes it available.
+//
+// NOTE: Must be called with the mutex held.
+func (m *NamedWalletManager) start(name string, loader *wallet.Loader,
+	w *wallet.Wallet, password []byte, recoveryWindow uint32) error {
+
+	unload := func(err error) error {
+		if unloadErr := loader.UnloadWallet(); unloadErr != nil {
+			return fmt.Errorf("%w (unable to unload wallet: %v)",
+				err, unloadErr)
+		}
+
+		return err
+	}
+
+	chainSource, err := m.cfg.NewChainSource()
+	if err != nil {
+		return unload(err)
+	}
+
+	btcWallet, err := btcwallet.New(btcwallet.Config{
+		PrivatePass:           password,
+		PublicPass:            password,
+		RecoveryWindow:        recoveryWindow,
+		ChainSource:           chainSource,
+		NetParams:             m.cfg.NetParams,
+		CoinType:              m.cfg.CoinType,
+		Wallet:                w,
+		CoinSelectionStrategy: m.cfg.CoinSelectionStrategy,
+	}, m.cfg.BlockCache)
+	if err != nil {
+		return unload(err)
+	}
+
+	if err := btcWallet.Start(); err != nil {
+		return unload(err)
+	}
+
+	m.wallets[name] = &NamedWallet{
+		BtcWallet: btcWallet,
+		name:      name,
+		loader:    loader,
+	}
+
+	return nil
+}
+
+// Lock stops the named wallet and closes its database.
+func (m *NamedWalletManager) Lock(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[name]
+	if !ok {
+		return ErrNamedWalletLocked
+	}
+	delete(m.wallets, name)
+
+	return stopNamedWallet(w)
+}
+
+// stopNamedWallet stops the wallet and closes its database.
+func stopNamedWallet(w *NamedWallet) error {
+	if err := w.Stop(); err != nil {
+		return err
+	}
+
+	return w.loader.UnloadWallet()
+}
+
+// Wallet returns the unlocked named wallet with the given name.
+func (m *NamedWalletManager) Wallet(name string) (*NamedWallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, ok := m.wallets[name]
+	if ok {
+		return w, nil
+	}
+
+	// Tell apart wallets that don't exist from locked ones.
+	if err := ValidateWalletName(name); err != nil {
+		return nil, err
+	}
+	dbPath := filepath.Join(m.cfg.Dir, name, namedWalletDBName)
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, ErrNamedWalletNotFound
+	}
+
+	return nil, ErrNamedWalletLocked
+}
+
+// List returns all named wallets, sorted by their names.
+func (m *NamedWalletManager) List() ([]NamedWalletInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entries, err := os.ReadDir(m.cfg.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var infos []NamedWalletInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || ValidateWalletName(name) != nil {
+			continue
+		}
+
+		dbPath := filepath.Join(m.cfg.Dir, name, namedWalletDBName)
+		if _, err := os.Stat(dbPath); err != nil {
+			continue
+		}
+
+		_, unlocked := m.wallets[name]
+		infos = append(infos, NamedWalletInfo{
+			Name:     name,
+			Unlocked: unlocked,
+		})
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos, nil
+}
+
+// Stop locks all named wallets.
+func (m *NamedWalletManager) Stop() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var firstErr error
+	for name, w := range m.wallets {
+		if err := stopNamedWallet(w); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(m.wallets, name)
+	}
+
+	return firstErr
+}
@@ -0,0 +1,134 @@
+package walletunlocker_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/lightningnetwork/lnd/aezeed"
+	"github.com/lightningnetwork/lnd/blockcache"
+	"github.com/lightningnetwork/lnd/chainreg"
+	"github.com/lightningnetwork/lnd/keychain"
+	"github.com/lightningnetwork/lnd/lnwallet"
+	"github.com/lightningnetwork/lnd/walletunlocker"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestNamedWalletManager creates a manager of named wallets without a
+// chain backend.
+func newTestNamedWalletManager(t *testing.T,
+	dir string) *walletunlocker.NamedWalletManager {
+
+	manager := walletunlocker.NewNamedWalletManager(
+		&walletunlocker.NamedWalletConfig{
+			Dir:       dir,
+			NetParams: &chaincfg.RegressionNetParams,
+			CoinType:  keychain.CoinTypeTestnet,
+			NewChainSource: func() (chain.Interface, error) {
+				return &chainreg.NoChainSource{
+					BestBlockTime: time.Now(),
+				}, nil
+			},
+			BlockCache:     blockcache.NewBlockCache(1000),
+			NoFreelistSync: true,
+			DBTimeout:      time.Second * 10,
+		},
+	)
+	t.Cleanup(func() {
+		require.NoError(t, manager.Stop())
+	})
+
+	return manager
+}
+
+// TestNamedWallets tests that named wallets are created, locked and unlocked
+// with their own passwords and derive their keys from their own seeds.
+func TestNamedWallets(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	manager := newTestNamedWalletManager(t, dir)
+
+	seed, err := aezeed.New(
+		keychain.CurrentKeyDerivationVersion, &testEntropy, time.Now(),
+	)
+	require.NoError(t, err)
+
+	// Invalid names and passwords are rejected.
+	err = manager.Create(walletunlocker.DefaultWalletName, testPassword,
+		seed, 0)
+	require.ErrorContains(t, err, "reserved")
+	err = manager.Create("Cold Storage", testPassword, seed, 0)
+	require.ErrorContains(t, err, "invalid wallet name")
+	err = manager.Create("cold", []byte("short"), seed, 0)
+	require.Error(t, err)
+
+	require.NoError(t, manager.Create("cold", testPassword, seed, 0))
+	err = manager.Create("cold", testPassword, seed, 0)
+	require.ErrorIs(t, err, walletunlocker.ErrNamedWalletExists)
+
+	_, err = os.Stat(filepath.Join(dir, "cold", "wallet.db"))
+	require.NoError(t, err)
+
+	coldWallet, err := manager.Wallet("cold")
+	require.NoError(t, err)
+	addr, err := coldWallet.NewAddress(
+		lnwallet.TaprootPubkey, false, lnwallet.DefaultAccountName,
+	)
+	require.NoError(t, err)
+
+	// A second wallet from a different seed derives different addresses.
+	otherEntropy := testEntropy
+	otherEntropy[0] ^= 0xff
+	otherSeed, err := aezeed.New(
+		keychain.CurrentKeyDerivationVersion, &otherEntropy, time.Now(),
+	)
+	require.NoError(t, err)
+	require.NoError(t, manager.Create("hot", testPassword, otherSeed, 0))
+
+	hotWallet, err := manager.Wallet("hot")
+	require.NoError(t, err)
+	otherAddr, err := hotWallet.NewAddress(
+		lnwallet.TaprootPubkey, false, lnwallet.DefaultAccountName,
+	)
+	require.NoError(t, err)
+	require.NotEqual(t, addr.String(), otherAddr.String())
+
+	// Locked wallets can't be used until they're unlocked with their
+	// password.
+	require.NoError(t, manager.Lock("cold"))
+	_, err = manager.Wallet("cold")
+	require.ErrorIs(t, err, walletunlocker.ErrNamedWalletLocked)
+	_, err = manager.Wallet("missing")
+	require.ErrorIs(t, err, walletunlocker.ErrNamedWalletNotFound)
+
+	infos, err := manager.List()
+	require.NoError(t, err)
+	require.Equal(t, []walletunlocker.NamedWalletInfo{
+		{Name: "cold", Unlocked: false},
+		{Name: "hot", Unlocked: true},
+	}, infos)
+
+	err = manager.Unlock("cold", []byte("wrong-password"), 0)
+	require.Error(t, err)
+	err = manager.Unlock("missing", testPassword, 0)
+	require.ErrorIs(t, err, walletunlocker.ErrNamedWalletNotFound)
+
+	require.NoError(t, manager.Unlock("cold", testPassword, 0))
+	err = manager.Unlock("cold", testPassword, 0)
+	require.ErrorIs(t, err, walletunlocker.ErrNamedWalletUnlocked)
+
+	// The unlocked wallet still belongs to the same seed.
+	coldWallet, err = manager.Wallet("cold")
+	require.NoError(t, err)
+	nextAddr, err := coldWallet.NewAddress(
+		lnwallet.TaprootPubkey, false, lnwallet.DefaultAccountName,
+	)
+	require.NoError(t, err)
+	require.NotEqual(t, addr.String(), nextAddr.String())
+	require.True(t, coldWallet.IsOurAddress(addr))
+	require.False(t, hotWallet.IsOurAddress(addr))
+}
@@ -0,0 +1,87 @@
+package walletunlocker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PluginProvider fetches the wallet password from a plugin process, for
+// example one that talks to a PKCS#11 token. The plugin is executed for each
+// request, reads the request from its stdin and writes the response to its
+// stdout.
+type PluginProvider struct {
+	path  string
+	args  []string
+	keyID string
+}
+
+// A compile time check to ensure that PluginProvider implements the
+// PasswordProvider interface.
+var _ PasswordProvider = (*PluginProvider)(nil)
+
+// NewPluginProvider creates a provider that executes the plugin at the given
+// path with the given arguments.
+func NewPluginProvider(path string, args []string,
+	keyID string) *PluginProvider {
+
+	return &PluginProvider{
+		path:  path,
+		args:  args,
+		keyID: keyID,
+	}
+}
+
+// request executes the plugin with a request and returns the password of its
+// response.
+func (p *PluginProvider) request(ctx context.Context,
+	req *ProviderRequest) ([]byte, error) {
+
+	var stdin, stdout, stderr bytes.Buffer
+	if err := writeRequest(&stdin, req); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, p.path, p.args...)
+	cmd.Stdin = &stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("unlock plugin failed: %w: %s", err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	password, err := readResponse(&stdout)
+	if err != nil {
+		return nil, fmt.Errorf("unlock plugin: %w", err)
+	}
+
+	return password, nil
+}
+
+// Password fetches the wallet password from the plugin.
+//
+// NOTE: This is part of the PasswordProvider interface.
+func (p *PluginProvider) Password(ctx context.Context) ([]byte, error) {
+	return p.request(ctx, &ProviderRequest{
+		Method: MethodGetPassword,
+		KeyID:  p.keyID,
+	})
+}
+
+// StorePassword replaces the wallet password kept by the plugin.
+//
+// NOTE: This is part of the PasswordProvider interface.
+func (p *PluginProvider) StorePassword(ctx context.Context,
+	password []byte) error {
+
+	_, err := p.request(ctx, &ProviderRequest{
+		Method:   MethodStorePassword,
+		KeyID:    p.keyID,
+		Password: password,
+	})
+
+	return err
+}
@@ -0,0 +1,166 @@
+package walletunlocker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// ProviderAgent is the type of the provider that fetches the password
+	// from a key agent listening on a Unix socket.
+	ProviderAgent = "agent"
+
+	// ProviderPlugin is the type of the provider that fetches the password
+	// from a plugin process, for example one that talks to a PKCS#11
+	// token.
+	ProviderPlugin = "plugin"
+
+	// ProviderSealed is the type of the provider that unseals the password
+	// from a file bound to the ID of the machine.
+	ProviderSealed = "sealed"
+
+	// MethodGetPassword is the method of the requests that fetch the
+	// wallet password from an agent or plugin.
+	MethodGetPassword = "get_password"
+
+	// MethodStorePassword is the method of the requests that replace the
+	// wallet password kept by an agent or plugin.
+	MethodStorePassword = "store_password"
+
+	// maxMessageSize is the maximum size of a request or response
+	// exchanged with an agent or plugin.
+	maxMessageSize = 64 * 1024
+)
+
+// PasswordProvider is an external key management system that keeps the
+// wallet password, so that the wallet can be unlocked unattended without a
+// plaintext password file on disk.
+type PasswordProvider interface {
+	// Password fetches the wallet password.
+	Password(ctx context.Context) ([]byte, error)
+
+	// StorePassword replaces the wallet password kept by the provider. It
+	// is called after the wallet was created, unlocked or had its password
+	// changed over RPC.
+	StorePassword(ctx context.Context, password []byte) error
+}
+
+// ProviderConfig holds the configuration of a password provider.
+type ProviderConfig struct {
+	// Type is the type of the provider, one of ProviderAgent,
+	// ProviderPlugin or ProviderSealed.
+	Type string
+
+	// KeyID identifies the password of the wallet to agents and plugins
+	// that keep the passwords of several wallets.
+	KeyID string
+
+	// AgentSocket is the path of the Unix socket of the key agent.
+	AgentSocket string
+
+	// PluginPath is the path of the executable of the plugin.
+	PluginPath string
+
+	// PluginArgs are the arguments the plugin is executed with.
+	PluginArgs []string
+
+	// SealedFile is the path of the file the password is sealed in.
+	SealedFile string
+}
+
+// NewPasswordProvider creates the password provider of the given
+// configuration.
+func NewPasswordProvider(cfg *ProviderConfig) (PasswordProvider, error) {
+	switch cfg.Type {
+	case ProviderAgent:
+		return NewAgentProvider(cfg.AgentSocket, cfg.KeyID), nil
+
+	case ProviderPlugin:
+		return NewPluginProvider(
+			cfg.PluginPath, cfg.PluginArgs, cfg.KeyID,
+		), nil
+
+	case ProviderSealed:
+		return NewSealedFileProvider(cfg.SealedFile), nil
+
+	default:
+		return nil, fmt.Errorf("unknown unlock provider %q", cfg.Type)
+	}
+}
+
+// ProviderRequest is a request sent to an agent or plugin. Requests and
+// responses are exchanged as single JSON objects followed by a newline.
+type ProviderRequest struct {
+	// Method is the requested operation, MethodGetPassword or
+	// MethodStorePassword.
+	Method string `json:"method"`
+
+	// KeyID identifies the password of the wallet.
+	KeyID string `json:"key_id"`
+
+	// Password is the new password of a MethodStorePassword request.
+	Password []byte `json:"password,omitempty"`
+}
+
+// ProviderResponse is the response of an agent or plugin to a request.
+type ProviderResponse struct {
+	// Password is the password returned for a MethodGetPassword request.
+	Password []byte `json:"password,omitempty"`
+
+	// Error describes why the request failed, empty on success.
+	Error string `json:"error,omitempty"`
+}
+
+// writeRequest writes a request to an agent or plugin.
+func writeRequest(w io.Writer, req *ProviderRequest) error {
+	return json.NewEncoder(w).Encode(req)
+}
+
+// readResponse reads the response of an agent or plugin to a request and
+// returns the password it contains.
+func readResponse(r io.Reader) ([]byte, error) {
+	var resp ProviderResponse
+	err := json.NewDecoder(io.LimitReader(r, maxMessageSize)).Decode(&resp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return resp.Password, nil
+}
+
+// ServeProviderRequest reads a single request from r, serves it with the
+// given provider and writes the response to w. It allows agents and plugins
+// written in Go to speak the protocol lnd expects.
+func ServeProviderRequest(ctx context.Context, r io.Reader, w io.Writer,
+	provider PasswordProvider) error {
+
+	var req ProviderRequest
+	err := json.NewDecoder(io.LimitReader(r, maxMessageSize)).Decode(&req)
+	if err != nil {
+		return fmt.Errorf("unable to decode request: %w", err)
+	}
+
+	var resp ProviderResponse
+	switch req.Method {
+	case MethodGetPassword:
+		resp.Password, err = provider.Password(ctx)
+
+	case MethodStorePassword:
+		err = provider.StorePassword(ctx, req.Password)
+
+	default:
+		err = fmt.Errorf("unknown method %q", req.Method)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+
+	return json.NewEncoder(w).Encode(&resp)
+}
@@ -0,0 +1,170 @@
+package walletunlocker
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// testPluginEnv is the environment variable that makes the test binary
+	// act as an unlock plugin that keeps the password in the file it
+	// points to.
+	testPluginEnv = "LND_TEST_UNLOCK_PLUGIN_FILE"
+)
+
+// fileProvider is a password provider that keeps the password in a plain
+// file.
+type fileProvider struct {
+	path string
+}
+
+// Password reads the password from the file.
+func (f *fileProvider) Password(context.Context) ([]byte, error) {
+	return os.ReadFile(f.path)
+}
+
+// StorePassword writes the password to the file.
+func (f *fileProvider) StorePassword(_ context.Context,
+	password []byte) error {
+
+	return os.WriteFile(f.path, password, 0600)
+}
+
+// testProviderRoundTrip tests that a password stored in the provider is
+// returned by it.
+func testProviderRoundTrip(t *testing.T, provider PasswordProvider) {
+	ctx := context.Background()
+
+	require.NoError(t, provider.StorePassword(ctx, []byte("password")))
+	password, err := provider.Password(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []byte("password"), password)
+
+	require.NoError(t, provider.StorePassword(ctx, []byte("rotated")))
+	password, err = provider.Password(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []byte("rotated"), password)
+}
+
+// TestSealedFileProvider tests that a sealed password can only be unsealed
+// on the machine it was sealed on.
+func TestSealedFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	idFile := filepath.Join(dir, "machine-id")
+	require.NoError(t, os.WriteFile(idFile, []byte("machine-1\n"), 0600))
+
+	oldFiles := machineIDFiles
+	machineIDFiles = []string{filepath.Join(dir, "missing"), idFile}
+	t.Cleanup(func() {
+		machineIDFiles = oldFiles
+	})
+
+	provider := NewSealedFileProvider(filepath.Join(dir, "wallet.sealed"))
+	testProviderRoundTrip(t, provider)
+
+	// The password must not be stored in plaintext.
+	sealed, err := os.ReadFile(provider.path)
+	require.NoError(t, err)
+	require.NotContains(t, string(sealed), "rotated")
+
+	// On another machine, the password can't be unsealed.
+	require.NoError(t, os.WriteFile(idFile, []byte("machine-2\n"), 0600))
+	_, err = provider.Password(context.Background())
+	require.ErrorIs(t, err, ErrUnsealFailed)
+
+	// Without a machine ID, nothing can be sealed.
+	require.NoError(t, os.Remove(idFile))
+	err = provider.StorePassword(context.Background(), []byte("password"))
+	require.Error(t, err)
+}
+
+// TestAgentProvider tests that the password is exchanged with a key agent
+// over a Unix socket.
+func TestAgentProvider(t *testing.T) {
+	t.Parallel()
+
+	// Unix socket paths are limited in length, so we don't use the
+	// temporary directory of the test.
+	dir, err := os.MkdirTemp("", "agent")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, os.RemoveAll(dir))
+	})
+
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, listener.Close())
+	})
+
+	backend := &fileProvider{path: filepath.Join(dir, "password")}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			_ = ServeProviderRequest(
+				context.Background(), conn, conn, backend,
+			)
+			_ = conn.Close()
+		}
+	}()
+
+	testProviderRoundTrip(t, NewAgentProvider(socket, "lnd"))
+
+	// Errors of the agent are returned.
+	require.NoError(t, os.Remove(backend.path))
+	_, err = NewAgentProvider(socket, "lnd").Password(context.Background())
+	require.ErrorContains(t, err, "key agent")
+
+	// An agent that isn't running results in an error.
+	_, err = NewAgentProvider(
+		filepath.Join(dir, "missing.sock"), "lnd",
+	).Password(context.Background())
+	require.Error(t, err)
+}
+
+// TestPluginProvider tests that the password is exchanged with a plugin
+// process. The test binary itself acts as the plugin.
+func TestPluginProvider(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	t.Setenv(testPluginEnv, passwordFile)
+
+	provider := NewPluginProvider(
+		os.Args[0], []string{"-test.run=^TestHelperPlugin$"}, "lnd",
+	)
+	testProviderRoundTrip(t, provider)
+
+	// Errors of the plugin are returned.
+	require.NoError(t, os.Remove(passwordFile))
+	_, err := provider.Password(context.Background())
+	require.ErrorContains(t, err, "unlock plugin")
+}
+
+// TestHelperPlugin isn't a real test, it serves a single plugin request when
+// the test binary is executed by TestPluginProvider.
+func TestHelperPlugin(t *testing.T) {
+	passwordFile := os.Getenv(testPluginEnv)
+	if passwordFile == "" {
+		return
+	}
+
+	err := ServeProviderRequest(
+		context.Background(), os.Stdin, os.Stdout,
+		&fileProvider{path: passwordFile},
+	)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// Exit right away, so the test framework doesn't write to stdout.
+	os.Exit(0)
+}
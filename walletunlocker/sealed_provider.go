@@ -0,0 +1,202 @@
+package walletunlocker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// sealedVersion is the version of the format of sealed files.
+	sealedVersion byte = 0
+
+	// sealedSaltSize is the size of the random salt the sealing key is
+	// derived with.
+	sealedSaltSize = 32
+
+	// sealedKeyInfo is the context the sealing key is derived with.
+	sealedKeyInfo = "lnd sealed wallet password"
+)
+
+var (
+	// machineIDFiles are the files the ID of the machine is read from, in
+	// order of preference.
+	machineIDFiles = []string{
+		"/etc/machine-id",
+		"/var/lib/dbus/machine-id",
+	}
+
+	// ErrUnsealFailed is returned if a sealed password can't be decrypted,
+	// most likely because the file was sealed on another machine.
+	ErrUnsealFailed = errors.New("unable to unseal password, the file " +
+		"was sealed on another machine or is corrupted")
+)
+
+// SealedFileProvider keeps the wallet password in a file encrypted with a
+// key derived from the ID of the machine. Copies of the file, for example in
+// backups, can't be decrypted on other machines. As the machine ID can be
+// read by all local users, the file must only be readable by lnd.
+type SealedFileProvider struct {
+	path string
+}
+
+// A compile time check to ensure that SealedFileProvider implements the
+// PasswordProvider interface.
+var _ PasswordProvider = (*SealedFileProvider)(nil)
+
+// NewSealedFileProvider creates a provider that keeps the password sealed in
+// the file at the given path.
+func NewSealedFileProvider(path string) *SealedFileProvider {
+	return &SealedFileProvider{
+		path: path,
+	}
+}
+
+// machineID returns the ID of the machine lnd is running on.
+func machineID() ([]byte, error) {
+	for _, file := range machineIDFiles {
+		id, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		id = bytes.TrimSpace(id)
+		if len(id) > 0 {
+			return id, nil
+		}
+	}
+
+	return nil, errors.New("unable to read machine ID")
+}
+
+// sealingKey derives the key a password is sealed with from the machine ID
+// and the salt.
+func sealingKey(salt []byte) ([]byte, error) {
+	id, err := machineID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	kdf := hkdf.New(sha256.New, id, salt, []byte(sealedKeyInfo))
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// sealPassword encrypts the password with a key bound to the machine. The
+// sealed password is the version byte, the salt, the nonce and the
+// ciphertext.
+func sealPassword(password []byte) ([]byte, error) {
+	salt := make([]byte, sealedSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key, err := sealingKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append([]byte{sealedVersion}, salt...)
+	sealed = append(sealed, nonce...)
+
+	return aead.Seal(sealed, nonce, password, sealed[:1]), nil
+}
+
+// unsealPassword decrypts a password sealed by sealPassword.
+func unsealPassword(sealed []byte) ([]byte, error) {
+	headerSize := 1 + sealedSaltSize + chacha20poly1305.NonceSize
+	if len(sealed) < headerSize+chacha20poly1305.Overhead {
+		return nil, errors.New("sealed password too short")
+	}
+	if sealed[0] != sealedVersion {
+		return nil, fmt.Errorf("unknown sealed password version %d",
+			sealed[0])
+	}
+
+	key, err := sealingKey(sealed[1 : 1+sealedSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := aead.Open(
+		nil, sealed[1+sealedSaltSize:headerSize], sealed[headerSize:],
+		sealed[:1],
+	)
+	if err != nil {
+		return nil, ErrUnsealFailed
+	}
+
+	return password, nil
+}
+
+// Password unseals the wallet password from the file.
+//
+// NOTE: This is part of the PasswordProvider interface.
+func (s *SealedFileProvider) Password(_ context.Context) ([]byte, error) {
+	sealed, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sealed password: %w",
+			err)
+	}
+
+	return unsealPassword(sealed)
+}
+
+// StorePassword seals the wallet password in the file, replacing the
+// previous one atomically.
+//
+// NOTE: This is part of the PasswordProvider interface.
+func (s *SealedFileProvider) StorePassword(_ context.Context,
+	password []byte) error {
+
+	sealed, err := sealPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".sealed-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(sealed); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}